@@ -29,6 +29,12 @@ import {IDrandOracle} from "./IDrandOracle.sol";
 /// @dev Implements EIP-712 for secure message signing and verification
 /// @custom:security-contact security@example.com
 contract DrandOracle is IDrandOracle, Ownable2Step, Pausable, EIP712 {
+    /// @notice Capability flag of setRandomnessBatch
+    uint256 private constant CAPABILITY_BATCH = 1 << 0;
+
+    /// @notice Capability flag of setRandomnessCompact
+    uint256 private constant CAPABILITY_COMPACT = 1 << 1;
+
     /// @notice The Drand chain hash
     bytes32 public immutable CHAIN_HASH;
 
@@ -77,6 +83,56 @@ contract DrandOracle is IDrandOracle, Ownable2Step, Pausable, EIP712 {
     /// @param _signature The signature authorizing this update
     /// @dev Round must be greater than the latest recorded round
     function setRandomness(Random calldata _random, bytes calldata _signature) external whenNotPaused {
+        _setRandomness(_random, _signature);
+    }
+
+    /// @notice Sets new randomness data for consecutive rounds in one transaction
+    /// @param _randoms The drand round randomness results, in round order
+    /// @param _signatures The signatures authorizing each update
+    /// @dev Every round must follow the previous one, as with setRandomness
+    function setRandomnessBatch(Random[] calldata _randoms, bytes[] calldata _signatures) external whenNotPaused {
+        if (_randoms.length == 0 || _randoms.length != _signatures.length) {
+            revert InvalidInput();
+        }
+        for (uint256 i = 0; i < _randoms.length; i++) {
+            _setRandomness(_randoms[i], _signatures[i]);
+        }
+    }
+
+    /// @notice Sets new randomness data for the round following the latest round, deriving the round and
+    /// the randomness on-chain to save calldata
+    /// @param _timestamp The drand round timestamp
+    /// @param _roundSignature The drand round signature, whose SHA-256 hash is the randomness
+    /// @param _signature The signature authorizing this update
+    /// @dev The first round has no latest round to follow and must be set with setRandomness
+    function setRandomnessCompact(uint64 _timestamp, bytes calldata _roundSignature, bytes calldata _signature)
+        external
+        whenNotPaused
+    {
+        if (_latestRound == 0) {
+            revert InvalidRound();
+        }
+        _setRandomness(
+            Random({
+                round: _latestRound + 1,
+                timestamp: _timestamp,
+                randomness: sha256(_roundSignature),
+                signature: _roundSignature
+            }),
+            _signature
+        );
+    }
+
+    /// @notice Returns the optional features supported by the contract, as flags
+    /// @return The capability flags: bit 0 for setRandomnessBatch, bit 1 for setRandomnessCompact
+    function capabilities() external pure returns (uint256) {
+        return CAPABILITY_BATCH | CAPABILITY_COMPACT;
+    }
+
+    /// @notice Verifies and records the randomness data of the round following the latest round
+    /// @param _random The drand round randomness result
+    /// @param _signature The signature authorizing this update
+    function _setRandomness(Random memory _random, bytes calldata _signature) private {
         if (_random.randomness.length == 0 || _random.signature.length == 0 || _random.timestamp == 0) {
             revert InvalidInput();
         }
@@ -200,7 +256,7 @@ contract DrandOracle is IDrandOracle, Ownable2Step, Pausable, EIP712 {
     /// @notice Creates a typed data hash for randomness updates
     /// @param _random The drand round randomness result being set
     /// @return bytes32 The EIP-712 compliant hash
-    function _hashSetRandomness(Random calldata _random) private view returns (bytes32) {
+    function _hashSetRandomness(Random memory _random) private view returns (bytes32) {
         return _hashTypedDataV4(
             keccak256(
                 abi.encode(
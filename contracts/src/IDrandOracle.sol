@@ -41,6 +41,23 @@ interface IDrandOracle {
     /// @param _signature The signature authorizing this update
     function setRandomness(Random calldata _random, bytes calldata _signature) external;
 
+    /// @notice Sets new randomness data for consecutive rounds in one transaction
+    /// @param _randoms The drand round randomness results, in round order
+    /// @param _signatures The signatures authorizing each update
+    function setRandomnessBatch(Random[] calldata _randoms, bytes[] calldata _signatures) external;
+
+    /// @notice Sets new randomness data for the round following the latest round, deriving the round and
+    /// the randomness on-chain to save calldata
+    /// @param _timestamp The drand round timestamp
+    /// @param _roundSignature The drand round signature, whose SHA-256 hash is the randomness
+    /// @param _signature The signature authorizing this update
+    function setRandomnessCompact(uint64 _timestamp, bytes calldata _roundSignature, bytes calldata _signature)
+        external;
+
+    /// @notice Returns the optional features supported by the contract, as flags
+    /// @return The capability flags: bit 0 for setRandomnessBatch, bit 1 for setRandomnessCompact
+    function capabilities() external pure returns (uint256);
+
     /// @notice Retrieves the complete randomness data for a specific round
     /// @param _round The round number to query
     /// @return The Random struct containing the round's data
@@ -179,6 +179,122 @@ contract DrandOracleTest is Test {
         oracle.getRandomnessFromTimestamp(newTimestamp);
     }
 
+    function test_setRandomnessBatch_success() public {
+        uint64 round = 4493690;
+        uint64 timestamp = 1724995200;
+        IDrandOracle.Random[] memory randoms = new IDrandOracle.Random[](3);
+        bytes[] memory signatures = new bytes[](3);
+        for (uint64 i = 0; i < 3; i++) {
+            randoms[i] = IDrandOracle.Random({
+                round: round + i,
+                timestamp: timestamp + i * 3,
+                randomness: keccak256(abi.encode(round + i)),
+                signature: abi.encode(round + i)
+            });
+            signatures[i] = _signMessage(_hashSetRandomness(randoms[i]), signerPrivateKey);
+        }
+
+        oracle.setRandomnessBatch(randoms, signatures);
+
+        assertEq(oracle.earliestRound(), round);
+        assertEq(oracle.latestRound(), round + 2);
+        for (uint64 i = 0; i < 3; i++) {
+            IDrandOracle.Random memory retrievedData = oracle.getRandomnessFromRound(round + i);
+            assertEq(retrievedData.randomness, randoms[i].randomness);
+            assertEq(retrievedData.timestamp, randoms[i].timestamp);
+        }
+    }
+
+    function test_setRandomnessBatch_gap() public {
+        uint64 round = 4493690;
+        uint64 timestamp = 1724995200;
+        IDrandOracle.Random[] memory randoms = new IDrandOracle.Random[](2);
+        bytes[] memory signatures = new bytes[](2);
+        for (uint64 i = 0; i < 2; i++) {
+            randoms[i] = IDrandOracle.Random({
+                round: round + i * 2,
+                timestamp: timestamp + i * 6,
+                randomness: keccak256(abi.encode(round + i * 2)),
+                signature: abi.encode(round + i * 2)
+            });
+            signatures[i] = _signMessage(_hashSetRandomness(randoms[i]), signerPrivateKey);
+        }
+
+        vm.expectRevert(abi.encodeWithSelector(IDrandOracle.InvalidRound.selector));
+        oracle.setRandomnessBatch(randoms, signatures);
+    }
+
+    function test_setRandomnessBatch_lengthMismatch() public {
+        IDrandOracle.Random[] memory randoms = new IDrandOracle.Random[](1);
+        bytes[] memory signatures = new bytes[](2);
+
+        vm.expectRevert(abi.encodeWithSelector(IDrandOracle.InvalidInput.selector));
+        oracle.setRandomnessBatch(randoms, signatures);
+    }
+
+    function test_setRandomnessCompact_success() public {
+        uint64 round = 4493690;
+        uint64 timestamp = 1724995200;
+        IDrandOracle.Random memory first = IDrandOracle.Random({
+            round: round,
+            timestamp: timestamp,
+            randomness: sha256(abi.encode(round)),
+            signature: abi.encode(round)
+        });
+        oracle.setRandomness(first, _signMessage(_hashSetRandomness(first), signerPrivateKey));
+
+        IDrandOracle.Random memory next = IDrandOracle.Random({
+            round: round + 1,
+            timestamp: timestamp + 3,
+            randomness: sha256(abi.encode(round + 1)),
+            signature: abi.encode(round + 1)
+        });
+        bytes memory signature = _signMessage(_hashSetRandomness(next), signerPrivateKey);
+
+        oracle.setRandomnessCompact(next.timestamp, next.signature, signature);
+
+        IDrandOracle.Random memory retrievedData = oracle.getRandomnessFromRound(round + 1);
+        assertEq(retrievedData.randomness, next.randomness);
+        assertEq(retrievedData.signature, next.signature);
+        assertEq(retrievedData.timestamp, next.timestamp);
+        assertEq(oracle.latestRound(), round + 1);
+    }
+
+    function test_setRandomnessCompact_firstRound() public {
+        bytes memory roundSignature = abi.encode(uint64(1));
+
+        vm.expectRevert(abi.encodeWithSelector(IDrandOracle.InvalidRound.selector));
+        oracle.setRandomnessCompact(1724995200, roundSignature, new bytes(65));
+    }
+
+    function test_setRandomnessCompact_wrongRandomness() public {
+        uint64 round = 4493690;
+        uint64 timestamp = 1724995200;
+        IDrandOracle.Random memory first = IDrandOracle.Random({
+            round: round,
+            timestamp: timestamp,
+            randomness: sha256(abi.encode(round)),
+            signature: abi.encode(round)
+        });
+        oracle.setRandomness(first, _signMessage(_hashSetRandomness(first), signerPrivateKey));
+
+        // Signed over a randomness that is not the hash of the round signature
+        IDrandOracle.Random memory next = IDrandOracle.Random({
+            round: round + 1,
+            timestamp: timestamp + 3,
+            randomness: keccak256(abi.encode(round + 1)),
+            signature: abi.encode(round + 1)
+        });
+        bytes memory signature = _signMessage(_hashSetRandomness(next), signerPrivateKey);
+
+        vm.expectRevert(abi.encodeWithSelector(IDrandOracle.InvalidSignature.selector));
+        oracle.setRandomnessCompact(next.timestamp, next.signature, signature);
+    }
+
+    function test_capabilities() public view {
+        assertEq(oracle.capabilities(), 3);
+    }
+
     function _signMessage(bytes32 hash, uint256 privateKey) internal pure returns (bytes memory) {
         (uint8 v, bytes32 r, bytes32 s) = vm.sign(privateKey, hash);
         return abi.encodePacked(r, s, v);
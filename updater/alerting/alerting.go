@@ -0,0 +1,226 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/internal/httpsig"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const notifyTimeout = 10 * time.Second
+
+// Severity is the urgency of an alert
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Conditions that trigger alerts
+const (
+	ConditionLowBalance        = "low_balance"
+	ConditionRoundFailed       = "round_failed"
+	ConditionCircuitBreaker    = "circuit_breaker"
+	ConditionCompromise        = "upstream_compromise"
+	ConditionArchiveCorruption = "archive_corruption"
+	ConditionNotAuthorized     = "not_authorized"
+	ConditionCanaryRolledBack  = "canary_rolled_back"
+	ConditionKeyRotated        = "key_rotated"
+	ConditionReceiptMismatch   = "receipt_mismatch"
+)
+
+// DefaultConditions are the conditions alerted on when none are configured
+var DefaultConditions = []string{ConditionLowBalance, ConditionRoundFailed, ConditionCircuitBreaker, ConditionCompromise, ConditionArchiveCorruption, ConditionNotAuthorized, ConditionCanaryRolledBack, ConditionKeyRotated, ConditionReceiptMismatch}
+
+// Alert is a notification about an updater condition
+type Alert struct {
+	// Condition is the condition that triggered the alert, also used to deduplicate alerts
+	Condition string            `json:"condition"`
+	Severity  Severity          `json:"severity"`
+	Summary   string            `json:"summary"`
+	Details   map[string]string `json:"details,omitempty"`
+	Time      time.Time         `json:"time"`
+}
+
+// Notifier delivers alerts to an external service
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Dispatcher sends alerts for the enabled conditions to every notifier. Alerts
+// for the same condition are sent at most once per repeat interval.
+type Dispatcher struct {
+	notifiers      []Notifier
+	conditions     map[string]bool
+	repeatInterval time.Duration
+	// source identifies the updater instance in alerts, e.g. the chain ID
+	source map[string]string
+
+	// mu guards lastSent and repeatInterval, which can be changed while
+	// alerting
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+
+	// pending counts the notifications in flight, waited for by Flush
+	pending sync.WaitGroup
+}
+
+func NewDispatcher(notifiers []Notifier, conditions []string, repeatInterval time.Duration, source map[string]string) *Dispatcher {
+	enabled := make(map[string]bool, len(conditions))
+	for _, condition := range conditions {
+		enabled[condition] = true
+	}
+	return &Dispatcher{
+		notifiers:      notifiers,
+		conditions:     enabled,
+		repeatInterval: repeatInterval,
+		source:         source,
+		lastSent:       make(map[string]time.Time),
+	}
+}
+
+// SetRepeatInterval replaces the minimum interval between two alerts for the
+// same condition
+func (d *Dispatcher) SetRepeatInterval(interval time.Duration) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.repeatInterval = interval
+}
+
+// RepeatInterval returns the minimum interval between two alerts for the same
+// condition, 0 when d is nil
+func (d *Dispatcher) RepeatInterval() time.Duration {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.repeatInterval
+}
+
+// Notifiers returns the names of the configured notifiers
+func (d *Dispatcher) Notifiers() []string {
+	var names []string
+	for _, n := range d.notifiers {
+		names = append(names, n.Name())
+	}
+	return names
+}
+
+// Send delivers an alert in the background so that alerting never blocks the
+// updater, see Flush before exiting. Alerts for disabled or recently alerted
+// conditions are dropped.
+func (d *Dispatcher) Send(alert Alert) {
+	if d == nil || len(d.notifiers) == 0 || !d.conditions[alert.Condition] {
+		return
+	}
+	if alert.Time.IsZero() {
+		alert.Time = time.Now().UTC()
+	}
+
+	d.mu.Lock()
+	if last, ok := d.lastSent[alert.Condition]; ok && alert.Time.Sub(last) < d.repeatInterval {
+		d.mu.Unlock()
+		return
+	}
+	d.lastSent[alert.Condition] = alert.Time
+	d.mu.Unlock()
+
+	details := make(map[string]string, len(d.source)+len(alert.Details))
+	for k, v := range d.source {
+		details[k] = v
+	}
+	for k, v := range alert.Details {
+		details[k] = v
+	}
+	alert.Details = details
+
+	for _, notifier := range d.notifiers {
+		d.pending.Add(1)
+		go func(notifier Notifier) {
+			defer d.pending.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			if err := notifier.Notify(ctx, alert); err != nil {
+				log.Error().Err(err).Str("notifier", notifier.Name()).Str("condition", alert.Condition).Msg("Failed to send alert")
+				return
+			}
+			log.Info().Str("notifier", notifier.Name()).Str("condition", alert.Condition).Msg("Alert sent")
+		}(notifier)
+	}
+}
+
+// Flush waits for the alerts being sent, at most notifyTimeout, so that the
+// alerts raised by a failure are delivered before the process exits
+func (d *Dispatcher) Flush(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn().Msg("Timed out sending pending alerts")
+	}
+}
+
+// Resolve allows the next alert for a condition to be sent immediately, once
+// the condition has cleared
+func (d *Dispatcher) Resolve(condition string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	delete(d.lastSent, condition)
+	d.mu.Unlock()
+}
+
+// postJSON posts a JSON body, signed unless signer is nil, and fails on non
+// 2xx responses
+func postJSON(ctx context.Context, client *http.Client, url string, body any, headers map[string]string, signer *httpsig.Signer) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if err := signer.Sign(req, data); err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
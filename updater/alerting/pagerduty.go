@@ -0,0 +1,59 @@
+package alerting
+
+import (
+	"context"
+	"net/http"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyNotifier triggers incidents through the PagerDuty Events API v2.
+// Alerts for the same condition share a dedup key so PagerDuty groups them.
+type PagerDutyNotifier struct {
+	routingKey string
+	source     string
+	httpClient *http.Client
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp"`
+	Component     string            `json:"component"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// NewPagerDutyNotifier creates a PagerDuty notifier. source identifies the
+// updater instance in PagerDuty.
+func NewPagerDutyNotifier(routingKey, source string) *PagerDutyNotifier {
+	return &PagerDutyNotifier{routingKey: routingKey, source: source, httpClient: &http.Client{}}
+}
+
+func (n *PagerDutyNotifier) Name() string {
+	return "pagerduty"
+}
+
+func (n *PagerDutyNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.httpClient, pagerDutyEventsURL, pagerDutyEvent{
+		RoutingKey:  n.routingKey,
+		EventAction: "trigger",
+		DedupKey:    n.source + "/" + alert.Condition,
+		Payload: pagerDutyPayload{
+			Summary:       alert.Summary,
+			Source:        n.source,
+			Severity:      string(alert.Severity),
+			Timestamp:     alert.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+			Component:     "drand-oracle-updater",
+			CustomDetails: alert.Details,
+		},
+	}, nil, nil)
+}
@@ -0,0 +1,37 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SlackNotifier posts alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	httpClient *http.Client
+}
+
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{webhookURL: webhookURL, httpClient: &http.Client{}}
+}
+
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "*[%s] %s*", strings.ToUpper(string(alert.Severity)), alert.Summary)
+	keys := make([]string, 0, len(alert.Details))
+	for k := range alert.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&text, "\n• %s: `%s`", k, alert.Details[k])
+	}
+	return postJSON(ctx, n.httpClient, n.webhookURL, map[string]string{"text": text.String()}, nil, nil)
+}
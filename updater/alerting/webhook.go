@@ -0,0 +1,29 @@
+package alerting
+
+import (
+	"context"
+	"drand-oracle-updater/internal/httpsig"
+	"net/http"
+)
+
+// WebhookNotifier posts alerts as JSON to a generic webhook
+type WebhookNotifier struct {
+	url        string
+	headers    map[string]string
+	signer     *httpsig.Signer
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier sending the given headers with
+// every alert, signed by signer unless nil
+func NewWebhookNotifier(url string, headers map[string]string, signer *httpsig.Signer) *WebhookNotifier {
+	return &WebhookNotifier{url: url, headers: headers, signer: signer, httpClient: &http.Client{}}
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.httpClient, n.url, alert, n.headers, n.signer)
+}
@@ -0,0 +1,198 @@
+// Package chaintest runs simulated chains behaving like the chain families the
+// oracle is deployed to, so that code talking to a chain over JSON-RPC can be
+// tested against each family's fee and receipt quirks without real networks.
+//
+//	func TestSubmit(t *testing.T) {
+//		chaintest.Run(t, func(t *testing.T, backend *chaintest.Backend) {
+//			client := backend.Client
+//			...
+//		})
+//	}
+//
+// Every backend is a go-ethereum simulated backend behind an HTTP JSON-RPC
+// endpoint, which rewrites the responses of the family it simulates.
+package chaintest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Family is a chain family with its own fee and receipt behavior
+type Family string
+
+const (
+	// EVM is a vanilla EVM chain, such as Ethereum
+	EVM Family = "evm"
+	// OPStack is an OP Stack rollup, such as Optimism or Base. Receipts carry
+	// the L1 data fee, charged on top of the L2 gas fee.
+	OPStack Family = "op-stack"
+	// Arbitrum is an Arbitrum Nitro rollup. Gas estimates and gas used include
+	// the L1 data gas, priority fees are not paid, and every transaction pays
+	// the base fee.
+	Arbitrum Family = "arbitrum"
+)
+
+// Families are the chain families the backends simulate
+var Families = []Family{EVM, OPStack, Arbitrum}
+
+// ChainID is the chain ID of every backend
+var ChainID = big.NewInt(1337)
+
+// L1GasPrice is the L1 gas price of the rollup families' L1 data fee
+var L1GasPrice = big.NewInt(30_000_000_000)
+
+// fundedBalance is the balance of the backend's funded account
+var fundedBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+
+// Backend is a simulated chain of a family
+type Backend struct {
+	Family Family
+	// Sim is the simulated backend, to commit blocks and adjust the time
+	Sim *simulated.Backend
+	// URL is the HTTP JSON-RPC endpoint of the chain
+	URL string
+	// Client is connected to URL
+	Client *ethclient.Client
+	// Key is the key of an account funded with 1000 ether
+	Key *ecdsa.PrivateKey
+	// Address is the address of Key
+	Address common.Address
+
+	rpc *rpc.Client
+}
+
+// New starts a backend of a family. alloc adds accounts to the genesis block,
+// nil funds the backend's account only. The backend is closed on cleanup.
+func New(tb testing.TB, family Family, alloc types.GenesisAlloc) *Backend {
+	tb.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		tb.Fatalf("chaintest: generating key: %v", err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	genesis := types.GenesisAlloc{address: {Balance: fundedBalance}}
+	for account, data := range alloc {
+		genesis[account] = data
+	}
+
+	// The simulated backend does not expose its RPC client, so the responses
+	// are proxied through an IPC endpoint instead. Temporary directories of
+	// tests are often too long for a socket path.
+	dir, err := os.MkdirTemp("", "chaintest")
+	if err != nil {
+		tb.Fatalf("chaintest: creating IPC directory: %v", err)
+	}
+	tb.Cleanup(func() { os.RemoveAll(dir) })
+	ipcPath := filepath.Join(dir, "geth.ipc")
+	sim := simulated.NewBackend(genesis, func(nodeConf *node.Config, _ *ethconfig.Config) {
+		nodeConf.IPCPath = ipcPath
+	})
+	tb.Cleanup(func() { sim.Close() })
+
+	rpcClient, err := rpc.Dial(ipcPath)
+	if err != nil {
+		tb.Fatalf("chaintest: dialing simulated backend: %v", err)
+	}
+	tb.Cleanup(rpcClient.Close)
+	if family == Arbitrum {
+		// Arbitrum accepts transactions without priority fee, which the
+		// transaction pool and miner reject by default
+		if err := rpcClient.Call(nil, "miner_setGasPrice", (*hexutil.Big)(new(big.Int))); err != nil {
+			tb.Fatalf("chaintest: accepting transactions without priority fee: %v", err)
+		}
+	}
+
+	b := &Backend{
+		Family:  family,
+		Sim:     sim,
+		Key:     key,
+		Address: address,
+		rpc:     rpcClient,
+	}
+	server := httptest.NewServer(&proxy{backend: b})
+	tb.Cleanup(server.Close)
+	b.URL = server.URL
+	b.Client, err = ethclient.Dial(server.URL)
+	if err != nil {
+		tb.Fatalf("chaintest: dialing proxy: %v", err)
+	}
+	tb.Cleanup(b.Client.Close)
+	return b
+}
+
+// Run runs fn against a backend of every family, in parallel subtests named
+// after the families
+func Run(t *testing.T, fn func(t *testing.T, backend *Backend)) {
+	t.Helper()
+	for _, family := range Families {
+		t.Run(string(family), func(t *testing.T) {
+			t.Parallel()
+			fn(t, New(t, family, nil))
+		})
+	}
+}
+
+// Commit mines the pending transactions into a new block
+func (b *Backend) Commit() common.Hash {
+	return b.Sim.Commit()
+}
+
+// AutoCommit mines a block every interval until cleanup, for code waiting for
+// its transactions to be mined
+func (b *Backend) AutoCommit(tb testing.TB, interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	tb.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				b.Sim.Commit()
+			}
+		}
+	}()
+}
+
+// L1GasUsed is the L1 data gas of a transaction's calldata charged by the
+// rollup families: 16 per non-zero byte and 4 per zero byte
+func L1GasUsed(data []byte) uint64 {
+	var gas uint64
+	for _, c := range data {
+		if c == 0 {
+			gas += 4
+		} else {
+			gas += 16
+		}
+	}
+	return gas
+}
+
+// L1Fee is the L1 data fee of a transaction's calldata on OP Stack backends
+func L1Fee(data []byte) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(L1GasUsed(data)), L1GasPrice)
+}
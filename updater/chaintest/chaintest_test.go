@@ -0,0 +1,229 @@
+package chaintest_test
+
+import (
+	"context"
+	"drand-oracle-updater/chaintest"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// calldata mixes zero and non-zero bytes, which are charged differently
+var calldata = []byte{0x00, 0x01, 0x02, 0x00, 0xff, 0x00, 0x10}
+
+// intrinsicGas is the L2 gas of a transfer carrying data
+func intrinsicGas(t *testing.T, data []byte) uint64 {
+	t.Helper()
+	gas, err := core.IntrinsicGas(data, nil, false, true, true, true)
+	if err != nil {
+		t.Fatalf("intrinsic gas: %v", err)
+	}
+	return gas
+}
+
+// send mines a transfer carrying data from the funded account, returning its
+// receipt along with the raw receipt fields served by the backend
+func send(t *testing.T, b *chaintest.Backend, data []byte) (*types.Transaction, *types.Receipt, map[string]any) {
+	t.Helper()
+	ctx := context.Background()
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	nonce, err := b.Client.PendingNonceAt(ctx, b.Address)
+	if err != nil {
+		t.Fatalf("nonce: %v", err)
+	}
+	head, err := b.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatalf("head: %v", err)
+	}
+	tip, err := b.Client.SuggestGasTipCap(ctx)
+	if err != nil {
+		t.Fatalf("tip: %v", err)
+	}
+	gas, err := b.Client.EstimateGas(ctx, ethereum.CallMsg{From: b.Address, To: &to, Data: data})
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	tx, err := types.SignNewTx(b.Key, types.LatestSignerForChainID(chaintest.ChainID), &types.DynamicFeeTx{
+		ChainID:   chaintest.ChainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip),
+		Gas:       gas,
+		To:        &to,
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	if err := b.Client.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+	b.Commit()
+
+	receipt, err := b.Client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("receipt: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("transaction failed")
+	}
+	var fields map[string]any
+	if err := b.Client.Client().CallContext(ctx, &fields, "eth_getTransactionReceipt", tx.Hash()); err != nil {
+		t.Fatalf("raw receipt: %v", err)
+	}
+	return tx, receipt, fields
+}
+
+// uintField decodes a hex quantity of raw receipt fields
+func uintField(t *testing.T, fields map[string]any, name string) *big.Int {
+	t.Helper()
+	s, ok := fields[name].(string)
+	if !ok {
+		t.Fatalf("receipt field %s missing", name)
+	}
+	v, err := hexutil.DecodeBig(s)
+	if err != nil {
+		t.Fatalf("receipt field %s: %v", name, err)
+	}
+	return v
+}
+
+func TestEVM(t *testing.T) {
+	b := chaintest.New(t, chaintest.EVM, nil)
+	_, receipt, fields := send(t, b, calldata)
+
+	if want := intrinsicGas(t, calldata); receipt.GasUsed != want {
+		t.Errorf("gas used = %d, want %d", receipt.GasUsed, want)
+	}
+	for _, field := range []string{"l1Fee", "gasUsedForL1"} {
+		if _, ok := fields[field]; ok {
+			t.Errorf("receipt has rollup field %s", field)
+		}
+	}
+}
+
+func TestOPStack(t *testing.T) {
+	b := chaintest.New(t, chaintest.OPStack, nil)
+	_, receipt, fields := send(t, b, calldata)
+
+	// The L1 data fee is charged on top, the L2 gas is unchanged
+	if want := intrinsicGas(t, calldata); receipt.GasUsed != want {
+		t.Errorf("gas used = %d, want %d", receipt.GasUsed, want)
+	}
+	if got, want := uintField(t, fields, "l1Fee"), chaintest.L1Fee(calldata); got.Cmp(want) != 0 {
+		t.Errorf("l1Fee = %s, want %s", got, want)
+	}
+	if got, want := uintField(t, fields, "l1GasUsed").Uint64(), chaintest.L1GasUsed(calldata); got != want {
+		t.Errorf("l1GasUsed = %d, want %d", got, want)
+	}
+	if got := uintField(t, fields, "l1GasPrice"); got.Cmp(chaintest.L1GasPrice) != 0 {
+		t.Errorf("l1GasPrice = %s, want %s", got, chaintest.L1GasPrice)
+	}
+}
+
+func TestArbitrum(t *testing.T) {
+	b := chaintest.New(t, chaintest.Arbitrum, nil)
+	ctx := context.Background()
+
+	tip, err := b.Client.SuggestGasTipCap(ctx)
+	if err != nil {
+		t.Fatalf("tip: %v", err)
+	}
+	if tip.Sign() != 0 {
+		t.Errorf("suggested tip = %s, want 0", tip)
+	}
+	// Estimates are not exact, the L1 gas is compared to the estimate of a
+	// vanilla chain
+	l2Gas, l1Gas := intrinsicGas(t, calldata), chaintest.L1GasUsed(calldata)
+	evm := chaintest.New(t, chaintest.EVM, nil)
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	l2Estimate, err := evm.Client.EstimateGas(ctx, ethereum.CallMsg{From: evm.Address, To: &to, Data: calldata})
+	if err != nil {
+		t.Fatalf("vanilla estimate: %v", err)
+	}
+	estimate, err := b.Client.EstimateGas(ctx, ethereum.CallMsg{From: b.Address, To: &to, Data: calldata})
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	if estimate != l2Estimate+l1Gas {
+		t.Errorf("estimate = %d, want %d", estimate, l2Estimate+l1Gas)
+	}
+
+	_, receipt, fields := send(t, b, calldata)
+	if receipt.GasUsed != l2Gas+l1Gas {
+		t.Errorf("gas used = %d, want %d", receipt.GasUsed, l2Gas+l1Gas)
+	}
+	if got := uintField(t, fields, "gasUsedForL1").Uint64(); got != l1Gas {
+		t.Errorf("gasUsedForL1 = %d, want %d", got, l1Gas)
+	}
+	block, err := b.Client.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		t.Fatalf("block: %v", err)
+	}
+	if receipt.EffectiveGasPrice.Cmp(block.BaseFee) != 0 {
+		t.Errorf("effective gas price = %s, want the base fee %s", receipt.EffectiveGasPrice, block.BaseFee)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	ran := make(map[chaintest.Family]bool)
+	// The parallel subtests end before the cleanup of their parent
+	t.Cleanup(func() {
+		for _, family := range chaintest.Families {
+			if !ran[family] {
+				t.Errorf("family %s did not run", family)
+			}
+		}
+	})
+	chaintest.Run(t, func(t *testing.T, b *chaintest.Backend) {
+		if t.Name() != "TestRun/"+string(b.Family) {
+			t.Errorf("subtest %s runs family %s", t.Name(), b.Family)
+		}
+		chainID, err := b.Client.ChainID(context.Background())
+		if err != nil {
+			t.Fatalf("chain ID: %v", err)
+		}
+		if chainID.Cmp(chaintest.ChainID) != 0 {
+			t.Errorf("chain ID = %s, want %s", chainID, chaintest.ChainID)
+		}
+		mu.Lock()
+		ran[b.Family] = true
+		mu.Unlock()
+	})
+}
+
+func TestBatch(t *testing.T) {
+	chaintest.Run(t, func(t *testing.T, b *chaintest.Backend) {
+		tx, _, _ := send(t, b, calldata)
+		var chainID hexutil.Big
+		var fields map[string]any
+		batch := []rpc.BatchElem{
+			{Method: "eth_chainId", Result: &chainID},
+			{Method: "eth_getTransactionReceipt", Args: []any{tx.Hash()}, Result: &fields},
+		}
+		if err := b.Client.Client().BatchCallContext(context.Background(), batch); err != nil {
+			t.Fatalf("batch: %v", err)
+		}
+		for _, elem := range batch {
+			if elem.Error != nil {
+				t.Fatalf("%s: %v", elem.Method, elem.Error)
+			}
+		}
+		if (*big.Int)(&chainID).Cmp(chaintest.ChainID) != 0 {
+			t.Errorf("chain ID = %s, want %s", &chainID, chaintest.ChainID)
+		}
+		// Batched receipts are rewritten as single ones
+		_, hasL1Fee := fields["l1Fee"]
+		if want := b.Family == chaintest.OPStack; hasL1Fee != want {
+			t.Errorf("receipt has l1Fee = %v, want %v", hasL1Fee, want)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package chaintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// proxy serves JSON-RPC requests from the simulated backend, rewriting the
+// responses of the backend's family
+type proxy struct {
+	backend *Backend
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []request
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responses := make([]response, len(batch))
+		for i, req := range batch {
+			responses[i] = p.serve(r.Context(), req)
+		}
+		_ = json.NewEncoder(w).Encode(responses)
+		return
+	}
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(p.serve(r.Context(), req))
+}
+
+func (p *proxy) serve(ctx context.Context, req request) response {
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	result, err := p.call(ctx, req.Method, req.Params...)
+	if err == nil {
+		result, err = p.rewrite(ctx, req, result)
+	}
+	if err != nil {
+		resp.Error = &responseError{Code: -32000, Message: err.Error()}
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) {
+			resp.Error.Code = rpcErr.ErrorCode()
+		}
+		var dataErr rpc.DataError
+		if errors.As(err, &dataErr) {
+			resp.Error.Data = dataErr.ErrorData()
+		}
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// call forwards a call to the simulated backend
+func (p *proxy) call(ctx context.Context, method string, params ...json.RawMessage) (json.RawMessage, error) {
+	args := make([]any, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+	var result json.RawMessage
+	err := p.backend.rpc.CallContext(ctx, &result, method, args...)
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return result, err
+}
+
+// rewrite applies the quirks of the backend's family to a result
+func (p *proxy) rewrite(ctx context.Context, req request, result json.RawMessage) (json.RawMessage, error) {
+	switch p.backend.Family {
+	case OPStack:
+		if req.Method == "eth_getTransactionReceipt" {
+			return p.rewriteReceipt(ctx, result, p.opStackReceipt)
+		}
+	case Arbitrum:
+		switch req.Method {
+		case "eth_maxPriorityFeePerGas":
+			return json.Marshal((*hexutil.Big)(new(big.Int)))
+		case "eth_estimateGas":
+			return arbitrumEstimate(req, result)
+		case "eth_getTransactionReceipt":
+			return p.rewriteReceipt(ctx, result, p.arbitrumReceipt)
+		}
+	}
+	return result, nil
+}
+
+// receipt holds the receipt fields the quirks read, along with every field
+type receipt struct {
+	fields map[string]any
+	input  []byte
+}
+
+// rewriteReceipt applies fn to a receipt along with its transaction's input
+func (p *proxy) rewriteReceipt(ctx context.Context, result json.RawMessage, fn func(ctx context.Context, r *receipt) error) (json.RawMessage, error) {
+	if string(result) == "null" {
+		return result, nil
+	}
+	r := &receipt{}
+	if err := json.Unmarshal(result, &r.fields); err != nil {
+		return nil, err
+	}
+	hash, err := json.Marshal(r.fields["transactionHash"])
+	if err != nil {
+		return nil, err
+	}
+	txResult, err := p.call(ctx, "eth_getTransactionByHash", hash)
+	if err != nil {
+		return nil, err
+	}
+	var tx struct {
+		Input hexutil.Bytes `json:"input"`
+	}
+	if err := json.Unmarshal(txResult, &tx); err != nil {
+		return nil, err
+	}
+	r.input = tx.Input
+	if err := fn(ctx, r); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.fields)
+}
+
+// opStackReceipt adds the L1 data fee fields of OP Stack receipts
+func (p *proxy) opStackReceipt(_ context.Context, r *receipt) error {
+	r.fields["l1GasUsed"] = hexutil.Uint64(L1GasUsed(r.input))
+	r.fields["l1GasPrice"] = (*hexutil.Big)(L1GasPrice)
+	r.fields["l1Fee"] = (*hexutil.Big)(L1Fee(r.input))
+	r.fields["l1FeeScalar"] = "1"
+	return nil
+}
+
+// arbitrumReceipt adds the L1 data gas to the gas used and prices it all at
+// the base fee, as Arbitrum refunds priority fees
+func (p *proxy) arbitrumReceipt(ctx context.Context, r *receipt) error {
+	gasUsedForL1 := L1GasUsed(r.input)
+	for _, field := range []string{"gasUsed", "cumulativeGasUsed"} {
+		s, _ := r.fields[field].(string)
+		gas, err := hexutil.DecodeUint64(s)
+		if err != nil {
+			return err
+		}
+		r.fields[field] = hexutil.Uint64(gas + gasUsedForL1)
+	}
+	r.fields["gasUsedForL1"] = hexutil.Uint64(gasUsedForL1)
+
+	blockNumber, err := json.Marshal(r.fields["blockNumber"])
+	if err != nil {
+		return err
+	}
+	blockResult, err := p.call(ctx, "eth_getBlockByNumber", blockNumber, json.RawMessage("false"))
+	if err != nil {
+		return err
+	}
+	var block struct {
+		Number  hexutil.Uint64 `json:"number"`
+		BaseFee *hexutil.Big   `json:"baseFeePerGas"`
+	}
+	if err := json.Unmarshal(blockResult, &block); err != nil {
+		return err
+	}
+	if block.BaseFee != nil {
+		r.fields["effectiveGasPrice"] = block.BaseFee
+	}
+	r.fields["l1BlockNumber"] = block.Number
+	return nil
+}
+
+// arbitrumEstimate adds the L1 data gas of the estimated call to the estimate
+func arbitrumEstimate(req request, result json.RawMessage) (json.RawMessage, error) {
+	var estimate hexutil.Uint64
+	if err := json.Unmarshal(result, &estimate); err != nil {
+		return nil, err
+	}
+	if len(req.Params) > 0 {
+		var call struct {
+			Data  hexutil.Bytes `json:"data"`
+			Input hexutil.Bytes `json:"input"`
+		}
+		if err := json.Unmarshal(req.Params[0], &call); err != nil {
+			return nil, err
+		}
+		data := call.Input
+		if len(data) == 0 {
+			data = call.Data
+		}
+		estimate += hexutil.Uint64(L1GasUsed(data))
+	}
+	return json.Marshal(estimate)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/alerting"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/service"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// newAlertDispatcher builds the alert dispatcher of a pipeline from the
+// configured notifiers. It returns nil when no notifier is configured.
+func newAlertDispatcher(cfg config.Config, pipeline config.Pipeline) (*alerting.Dispatcher, error) {
+	source := fmt.Sprintf("drand-oracle-updater/%d/%s", cfg.ChainID, pipeline.OracleAddress)
+
+	var notifiers []alerting.Notifier
+	if cfg.AlertSlackWebhookURL != "" {
+		notifiers = append(notifiers, alerting.NewSlackNotifier(cfg.AlertSlackWebhookURL))
+	}
+	if cfg.AlertPagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, alerting.NewPagerDutyNotifier(cfg.AlertPagerDutyRoutingKey, source))
+	}
+	if cfg.AlertWebhookURL != "" {
+		headers := map[string]string{}
+		if cfg.AlertWebhookToken != "" {
+			headers["Authorization"] = "Bearer " + cfg.AlertWebhookToken
+		}
+		signer, err := newWebhookSigner(cfg)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, alerting.NewWebhookNotifier(cfg.AlertWebhookURL, headers, signer))
+	}
+	if len(notifiers) == 0 {
+		return nil, nil
+	}
+
+	conditions := cfg.AlertConditions
+	if len(conditions) == 0 {
+		conditions = alerting.DefaultConditions
+	}
+	for _, condition := range conditions {
+		switch condition {
+		case alerting.ConditionLowBalance, alerting.ConditionRoundFailed, alerting.ConditionCircuitBreaker,
+			alerting.ConditionCompromise, alerting.ConditionArchiveCorruption:
+		default:
+			return nil, fmt.Errorf("unknown alert condition %q", condition)
+		}
+	}
+
+	// Deployment labels are included in every alert payload
+	details := map[string]string{}
+	for k, v := range cfg.DeploymentLabels {
+		details[k] = v
+	}
+	details["chain_id"] = fmt.Sprintf("%d", cfg.ChainID)
+	details["oracle_address"] = pipeline.OracleAddress
+	details["pipeline"] = pipeline.Name
+
+	dispatcher := alerting.NewDispatcher(notifiers, conditions, cfg.AlertRepeatInterval, details)
+	log.Info().
+		Strs("notifiers", dispatcher.Notifiers()).
+		Strs("conditions", conditions).
+		Str("pipeline", pipeline.Name).
+		Msg("Alerting initialized")
+	return dispatcher, nil
+}
+
+// flushAlerts waits for the alerts the updaters are sending, so that the
+// alert of a failure is delivered before the process exits
+func flushAlerts(updaters []*service.Updater) {
+	var wg sync.WaitGroup
+	for _, updater := range updaters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			updater.FlushAlerts(context.Background())
+		}()
+	}
+	wg.Wait()
+}
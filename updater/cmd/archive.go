@@ -0,0 +1,56 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/objectstore"
+	"drand-oracle-updater/internal/records"
+	"errors"
+	"fmt"
+)
+
+// roundObjectsPending is the file of the state directory keeping the rounds
+// not uploaded to the archive bucket yet
+const roundObjectsPending = "round-objects.pending"
+
+// newRoundObjects returns the sink uploading every round of the drand chain
+// to the archive bucket, keeping the rounds not uploaded yet in pendingPath
+// if not empty, nil when ARCHIVE_S3_ENDPOINT is not set
+func newRoundObjects(cfg config.Config, chainHash, pendingPath string) (*records.RoundObjects, error) {
+	if err := verifyRoundObjects(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.ArchiveS3Endpoint == "" {
+		return nil, nil
+	}
+	client, err := objectstore.New(objectstore.Config{
+		Endpoint:        cfg.ArchiveS3Endpoint,
+		Bucket:          cfg.ArchiveS3Bucket,
+		Region:          cfg.ArchiveS3Region,
+		AccessKeyID:     cfg.ArchiveS3AccessKeyID,
+		SecretAccessKey: cfg.ArchiveS3SecretAccessKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive bucket: %w", err)
+	}
+	return records.NewRoundObjects(client, records.RoundObjectsOptions{
+		Prefix:      cfg.ArchiveS3Prefix,
+		ChainHash:   chainHash,
+		EraRounds:   cfg.ArchiveEraRounds,
+		PublicURL:   cfg.ArchiveS3PublicURL,
+		PendingPath: pendingPath,
+	}), nil
+}
+
+// verifyRoundObjects checks the archive bucket configuration without
+// uploading
+func verifyRoundObjects(cfg config.Config) error {
+	switch {
+	case cfg.ArchiveS3Endpoint != "" && cfg.ArchiveS3Bucket == "":
+		return errors.New("ARCHIVE_S3_BUCKET is required with ARCHIVE_S3_ENDPOINT")
+	case cfg.ArchiveS3AccessKeyID != "" && cfg.ArchiveS3SecretAccessKey == "":
+		return errors.New("ARCHIVE_S3_SECRET_ACCESS_KEY is required with ARCHIVE_S3_ACCESS_KEY_ID")
+	case cfg.ArchiveS3Endpoint != "" && cfg.ArchiveEraRounds == 0:
+		return errors.New("ARCHIVE_ERA_ROUNDS must be positive")
+	}
+	return nil
+}
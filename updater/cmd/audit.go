@@ -0,0 +1,102 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/audit"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// newAuditLog returns the audit log of the submission decisions, nil when
+// neither AUDIT_LOG_FILE nor AUDIT_S3_ENDPOINT is set. The head of its chain
+// is kept in the state directory.
+func newAuditLog(cfg config.Config) (*audit.Log, error) {
+	if err := verifyAuditLog(cfg); err != nil {
+		return nil, err
+	}
+	var sink audit.Sink
+	switch {
+	case cfg.AuditLogFile != "":
+		fileSink, err := audit.NewFileSink(cfg.AuditLogFile)
+		if err != nil {
+			return nil, fmt.Errorf("error opening audit log: %w", err)
+		}
+		sink = fileSink
+		log.Info().Str("file", cfg.AuditLogFile).Msg("Recording submission decisions to the audit log")
+	case cfg.AuditS3Endpoint != "":
+		s3Sink, err := audit.NewS3Sink(audit.S3Config{
+			Endpoint:        cfg.AuditS3Endpoint,
+			Bucket:          cfg.AuditS3Bucket,
+			Prefix:          cfg.AuditS3Prefix,
+			Region:          cfg.AuditS3Region,
+			AccessKeyID:     cfg.AuditS3AccessKeyID,
+			SecretAccessKey: cfg.AuditS3SecretAccessKey,
+			FlushInterval:   cfg.AuditS3FlushInterval,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sink = s3Sink
+		log.Info().
+			Str("bucket", cfg.AuditS3Bucket).
+			Str("prefix", cfg.AuditS3Prefix).
+			Dur("flush_interval", cfg.AuditS3FlushInterval).
+			Msg("Recording submission decisions to the audit bucket")
+	default:
+		return nil, nil
+	}
+	auditLog, err := audit.New(sink, filepath.Join(cfg.StateDir, "audit-head.json"))
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	return auditLog, nil
+}
+
+// verifyAuditLog checks the audit log configuration without opening its sink
+func verifyAuditLog(cfg config.Config) error {
+	switch {
+	case cfg.AuditLogFile != "" && cfg.AuditS3Endpoint != "":
+		return fmt.Errorf("AUDIT_LOG_FILE and AUDIT_S3_ENDPOINT are mutually exclusive")
+	case cfg.AuditS3Endpoint != "" && cfg.AuditS3Bucket == "":
+		return fmt.Errorf("AUDIT_S3_BUCKET is required with AUDIT_S3_ENDPOINT")
+	case cfg.AuditS3AccessKeyID != "" && cfg.AuditS3SecretAccessKey == "":
+		return fmt.Errorf("AUDIT_S3_SECRET_ACCESS_KEY is required with AUDIT_S3_ACCESS_KEY_ID")
+	}
+	return nil
+}
+
+// closeAuditLog closes the audit log, if any, flushing its buffered entries
+func closeAuditLog(auditLog *audit.Log) {
+	if auditLog == nil {
+		return
+	}
+	if err := auditLog.Close(); err != nil {
+		log.Error().Err(err).Msg("error closing audit log")
+	}
+}
+
+// runVerifyAudit checks the hash chain of an audit log file
+func runVerifyAudit(args []string) {
+	fs := flag.NewFlagSet("verify-audit", flag.ExitOnError)
+	prevHash := fs.String("prev-hash", "", "hash of the entry preceding the first entry of the file, when it does not start the chain")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal().Msg("usage: verify-audit [--prev-hash HASH] FILE")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal().Err(err).Msg("error opening audit log")
+	}
+	defer f.Close()
+	count, err := audit.Verify(f, *prevHash)
+	if err != nil {
+		log.Fatal().Err(err).Int("verified", count).Msg("Audit log chain is broken")
+	}
+	log.Info().Int("entries", count).Msg("Audit log chain verified")
+}
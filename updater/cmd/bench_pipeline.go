@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/internal/pipeline"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pipelineLatencies are the simulated latencies of the stages of a round
+type pipelineLatencies struct {
+	Fetch     time.Duration `json:"fetch"`
+	Verify    time.Duration `json:"verify"`
+	Sign      time.Duration `json:"sign"`
+	Broadcast time.Duration `json:"broadcast"`
+	BlockTime time.Duration `json:"block_time"`
+}
+
+// scaled divides the latencies by scale
+func (l pipelineLatencies) scaled(scale float64) pipelineLatencies {
+	div := func(d time.Duration) time.Duration { return time.Duration(float64(d) / scale) }
+	return pipelineLatencies{
+		Fetch:     div(l.Fetch),
+		Verify:    div(l.Verify),
+		Sign:      div(l.Sign),
+		Broadcast: div(l.Broadcast),
+		BlockTime: div(l.BlockTime),
+	}
+}
+
+// pipelineConfig is a configuration of the catch-up and submission pipelines
+type pipelineConfig struct {
+	Name         string `json:"name"`
+	FetchWorkers int    `json:"fetch_workers"`
+	SignWorkers  int    `json:"sign_workers"`
+	Depth        int    `json:"depth"`
+}
+
+// pipelineBenchmark is the outcome of a configuration
+type pipelineBenchmark struct {
+	pipelineConfig
+	Rounds int `json:"rounds"`
+	// Elapsed is the time the rounds took at the real latencies
+	Elapsed         time.Duration `json:"elapsed"`
+	RoundsPerSecond float64       `json:"rounds_per_second"`
+	Speedup         float64       `json:"speedup"`
+}
+
+// benchRound is a round going through the simulated pipeline
+type benchRound struct {
+	round  uint64
+	signed bool
+	// broadcastAt is the simulated time the round's transaction was broadcast
+	broadcastAt time.Time
+}
+
+// runBenchPipeline measures the catch-up throughput of pipeline
+// configurations against simulated drand relays, signer and chain, to size
+// CATCHUP_FETCH_WORKERS, CATCHUP_SIGN_WORKERS and SUBMISSION_PIPELINE_DEPTH.
+// Nothing is fetched, signed nor sent.
+func runBenchPipeline(args []string) {
+	fs := flag.NewFlagSet("bench-pipeline", flag.ExitOnError)
+	rounds := fs.Int("rounds", 200, "number of catch-up rounds")
+	var latencies pipelineLatencies
+	fs.DurationVar(&latencies.Fetch, "fetch", 150*time.Millisecond, "latency of a drand relay request")
+	fs.DurationVar(&latencies.Verify, "verify", 5*time.Millisecond, "time to verify a beacon")
+	fs.DurationVar(&latencies.Sign, "sign", 30*time.Millisecond, "latency of an EIP-712 signature, higher with a remote signer")
+	fs.DurationVar(&latencies.Broadcast, "broadcast", 60*time.Millisecond, "latency of a transaction broadcast")
+	fs.DurationVar(&latencies.BlockTime, "block-time", 2*time.Second, "block time of the chain")
+	fetchWorkers := fs.Int("fetch-workers", 4, "CATCHUP_FETCH_WORKERS of the staged configuration")
+	signWorkers := fs.Int("sign-workers", 2, "CATCHUP_SIGN_WORKERS of the staged configuration")
+	depth := fs.Int("depth", 8, "SUBMISSION_PIPELINE_DEPTH of the staged configuration")
+	scale := fs.Float64("scale", 20, "speed-up of the simulated latencies, the results being reported at the real latencies")
+	format := fs.String("format", "table", "output format: table or json")
+	_ = fs.Parse(args)
+
+	if *rounds <= 0 || *scale <= 0 {
+		log.Fatal().Msg("--rounds and --scale must be positive")
+	}
+	if *format != "table" && *format != "json" {
+		log.Fatal().Str("format", *format).Msg("--format must be table or json")
+	}
+
+	configs := []pipelineConfig{
+		{Name: "sequential", FetchWorkers: 1, SignWorkers: 0, Depth: 1},
+		{Name: "staged", FetchWorkers: *fetchWorkers, SignWorkers: *signWorkers, Depth: *depth},
+	}
+	var results []pipelineBenchmark
+	for _, config := range configs {
+		elapsed, err := benchPipeline(context.Background(), config, *rounds, latencies.scaled(*scale))
+		if err != nil {
+			log.Fatal().Err(err).Str("config", config.Name).Msg("error benchmarking pipeline")
+		}
+		elapsed = time.Duration(float64(elapsed) * *scale)
+		results = append(results, pipelineBenchmark{
+			pipelineConfig:  config,
+			Rounds:          *rounds,
+			Elapsed:         elapsed,
+			RoundsPerSecond: float64(*rounds) / elapsed.Seconds(),
+		})
+	}
+	// The sequential configuration is the baseline
+	for i := range results {
+		results[i].Speedup = results[i].RoundsPerSecond / results[0].RoundsPerSecond
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			Latencies pipelineLatencies   `json:"latencies"`
+			Results   []pipelineBenchmark `json:"results"`
+		}{latencies, results}); err != nil {
+			log.Fatal().Err(err).Msg("error writing report")
+		}
+		return
+	}
+
+	fmt.Printf("Simulated %d catch-up rounds: fetch %s, verify %s, sign %s, broadcast %s, block time %s\n\n",
+		*rounds, latencies.Fetch, latencies.Verify, latencies.Sign, latencies.Broadcast, latencies.BlockTime)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONFIG\tFETCH\tSIGN\tDEPTH\tELAPSED\tROUNDS/S\tSPEEDUP")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%.2f\t%.1fx\n",
+			r.Name, r.FetchWorkers, r.SignWorkers, r.Depth, r.Elapsed.Round(100*time.Millisecond), r.RoundsPerSecond, r.Speedup)
+	}
+	_ = w.Flush()
+}
+
+// benchPipeline runs rounds through the stages of a configuration with
+// simulated latencies, and returns the time until the last one was mined.
+// The stages mirror the updater: fetch and verify, sign ahead when enabled,
+// then broadcast in order, at most depth rounds waiting to be mined.
+func benchPipeline(ctx context.Context, config pipelineConfig, rounds int, latencies pipelineLatencies) (time.Duration, error) {
+	start := time.Now()
+	// minedAt returns the time of the first block produced after t
+	minedAt := func(t time.Time) time.Time {
+		blocks := t.Sub(start)/latencies.BlockTime + 1
+		return start.Add(blocks * latencies.BlockTime)
+	}
+	sleep := func(ctx context.Context, d time.Duration) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+			return nil
+		}
+	}
+
+	in := make(chan *benchRound)
+	go func() {
+		defer close(in)
+		for round := 1; round <= rounds; round++ {
+			in <- &benchRound{round: uint64(round)}
+		}
+	}()
+
+	stages := []pipeline.Stage[*benchRound]{{
+		Name:    "fetch",
+		Workers: config.FetchWorkers,
+		Process: func(ctx context.Context, rd *benchRound) (*benchRound, error) {
+			return rd, sleep(ctx, latencies.Fetch+latencies.Verify)
+		},
+	}}
+	if config.SignWorkers > 0 {
+		stages = append(stages, pipeline.Stage[*benchRound]{
+			Name:    "sign",
+			Workers: config.SignWorkers,
+			Process: func(ctx context.Context, rd *benchRound) (*benchRound, error) {
+				rd.signed = true
+				return rd, sleep(ctx, latencies.Sign)
+			},
+		})
+	}
+	// inFlight bounds the rounds broadcast and not mined yet, as the
+	// submission pipeline does
+	inFlight := make(chan struct{}, max(config.Depth, 1))
+	stages = append(stages, pipeline.Stage[*benchRound]{
+		Name:    "broadcast",
+		Workers: 1,
+		Process: func(ctx context.Context, rd *benchRound) (*benchRound, error) {
+			select {
+			case inFlight <- struct{}{}:
+			case <-ctx.Done():
+				return rd, ctx.Err()
+			}
+			// A round not signed ahead is signed as it is submitted
+			broadcast := latencies.Broadcast
+			if !rd.signed {
+				broadcast += latencies.Sign
+			}
+			if err := sleep(ctx, broadcast); err != nil {
+				return rd, err
+			}
+			rd.broadcastAt = time.Now()
+			return rd, nil
+		},
+	}, pipeline.Stage[*benchRound]{
+		Name:    "confirm",
+		Workers: max(config.Depth, 1),
+		Process: func(ctx context.Context, rd *benchRound) (*benchRound, error) {
+			defer func() { <-inFlight }()
+			return rd, sleep(ctx, time.Until(minedAt(rd.broadcastAt)))
+		},
+	})
+
+	// Transactions are mined in nonce order, so rounds must be broadcast in
+	// round order
+	var previous time.Time
+	for result := range pipeline.Run(ctx, in, stages...) {
+		if result.Err != nil {
+			return 0, fmt.Errorf("round %d failed at %s: %w", result.Item.round, result.Stage, result.Err)
+		}
+		if result.Item.broadcastAt.Before(previous) {
+			return 0, errors.New("rounds broadcast out of order")
+		}
+		previous = result.Item.broadcastAt
+	}
+	return time.Since(start), nil
+}
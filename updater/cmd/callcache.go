@@ -0,0 +1,38 @@
+package main
+
+import (
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/callcache"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// newCallCache returns the cache of a pipeline's oracle view calls, nil when
+// RPC_CACHE_TTL is not set
+func newCallCache(cfg config.Config, rpcClient *ethclient.Client, pipeline string) (*callcache.Backend, error) {
+	if cfg.RPCCacheTTL <= 0 {
+		return nil, nil
+	}
+	contractABI, err := binding.BindingMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(cfg.DeploymentLabels)+1)
+	for k, v := range cfg.DeploymentLabels {
+		labels[k] = v
+	}
+	labels["pipeline"] = pipeline
+	cache, err := callcache.New(rpcClient, contractABI, callcache.Options{
+		TTL:          cfg.RPCCacheTTL,
+		Mutable:      []string{"latestRound", "earliestRound", "signer", "paused", "owner", "pendingOwner"},
+		Static:       []string{"CHAIN_HASH"},
+		MetricLabels: labels,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("pipeline", pipeline).Dur("ttl", cfg.RPCCacheTTL).Msg("Caching oracle view calls")
+	return cache, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/gas"
+	"drand-oracle-updater/internal/canary"
+	"drand-oracle-updater/internal/service"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// canaryGasStrategy names the canary's gas strategy apart from the updater's
+// in logs and metrics
+type canaryGasStrategy struct {
+	gas.Strategy
+}
+
+func (s canaryGasStrategy) Name() string {
+	return "canary_" + s.Strategy.Name()
+}
+
+// newCanary returns the canary rollout of a pipeline, nil without
+// CANARY_PERCENT
+func newCanary(cfg config.Config, rpcClient *ethclient.Client, pipeline string) (*service.Canary, error) {
+	if cfg.CanaryPercent == 0 {
+		return nil, nil
+	}
+	if cfg.CanaryGasStrategy == "" && cfg.CanaryGasPriceMultiplier == 0 && cfg.CanaryBatchSize == 0 {
+		return nil, errors.New("CANARY_PERCENT needs a CANARY_GAS_STRATEGY, CANARY_GAS_PRICE_MULTIPLIER or CANARY_BATCH_SIZE to roll out")
+	}
+
+	rollout := &service.Canary{BatchSize: cfg.CanaryBatchSize}
+	var changes []string
+	if cfg.CanaryGasStrategy != "" || cfg.CanaryGasPriceMultiplier != 0 {
+		name := cfg.CanaryGasStrategy
+		if name == "" {
+			name = cfg.GasStrategy
+		}
+		if name == "" {
+			name = gas.DefaultStrategy(cfg.ChainID)
+		}
+		multiplier := cfg.CanaryGasPriceMultiplier
+		if multiplier == 0 {
+			multiplier = cfg.GasPriceMultiplier
+		}
+		strategy, err := buildGasStrategy(cfg, rpcClient, name)
+		if err != nil {
+			return nil, err
+		}
+		maxGasPrice, ok := new(big.Int).SetString(cfg.MaxGasPriceWei, 10)
+		if !ok || maxGasPrice.Sign() < 0 {
+			return nil, fmt.Errorf("invalid maximum gas price %q", cfg.MaxGasPriceWei)
+		}
+		bounded, err := gas.NewBounded(strategy, multiplier, maxGasPrice)
+		if err != nil {
+			return nil, err
+		}
+		rollout.GasStrategy = canaryGasStrategy{bounded}
+		changes = append(changes, fmt.Sprintf("gas=%s*%g", name, multiplier))
+	}
+	if cfg.CanaryBatchSize > 0 {
+		changes = append(changes, fmt.Sprintf("batch=%d", cfg.CanaryBatchSize))
+	}
+
+	labels := make(map[string]string, len(cfg.DeploymentLabels)+1)
+	for k, v := range cfg.DeploymentLabels {
+		labels[k] = v
+	}
+	labels["pipeline"] = pipeline
+	controller, err := canary.New(canary.Options{
+		Name:       strings.Join(changes, ","),
+		Percent:    cfg.CanaryPercent,
+		Window:     cfg.CanaryWindow,
+		MinSamples: cfg.CanaryMinSamples,
+		Thresholds: canary.Thresholds{
+			MaxCostIncrease:        cfg.CanaryMaxCostIncrease,
+			MaxLatencyIncrease:     cfg.CanaryMaxLatencyIncrease,
+			MaxFailureRateIncrease: cfg.CanaryMaxFailureIncrease,
+		},
+	}, labels)
+	if err != nil {
+		return nil, err
+	}
+	rollout.Controller = controller
+	log.Info().
+		Str("pipeline", pipeline).
+		Str("canary", controller.Status().Name).
+		Int("percent", cfg.CanaryPercent).
+		Msg("Canary rollout started")
+	return rollout, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/service"
+	"drand-oracle-updater/sender"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// decommissionReport is the audit report of a decommissioning
+type decommissionReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	ChainID    int64     `json:"chain_id"`
+	// Executed is false for a plan, where no transaction is sent
+	Executed bool `json:"executed"`
+	// PausedInstance is the running updater whose submissions were paused
+	PausedInstance string                   `json:"paused_instance,omitempty"`
+	Nonces         *sender.NonceState       `json:"nonces,omitempty"`
+	Pipelines      []decommissionedPipeline `json:"pipelines"`
+	Sweep          *service.Sweep           `json:"sweep,omitempty"`
+	Error          string                   `json:"error,omitempty"`
+}
+
+// decommissionedPipeline is the state a pipeline was left in
+type decommissionedPipeline struct {
+	Name        string                   `json:"name"`
+	OracleRound uint64                   `json:"oracle_round"`
+	Signer      service.SignerRevocation `json:"signer"`
+}
+
+// runDecommission winds a deployment down: pauses the running updater, waits
+// for the sender's pending transactions, revokes the oracle's signer and
+// sweeps the sender's balance to the treasury
+func runDecommission(args []string) {
+	fs := flag.NewFlagSet("decommission", flag.ExitOnError)
+	treasury := fs.String("treasury", "", "address receiving the sender's balance, DECOMMISSION_TREASURY_ADDRESS or the top-up treasury by default")
+	adminURL := fs.String("admin-url", "", "base URL of the running updater, whose submissions are paused through the admin API first")
+	keepSigner := fs.Bool("keep-signer", false, "leave the oracle's signer in place")
+	keepWei := fs.String("keep-wei", "0", "amount left on the sender, e.g. for the L1 data fee of rollups")
+	timeout := fs.Duration("timeout", 10*time.Minute, "how long to wait for the sender's pending transactions")
+	execute := fs.Bool("execute", false, "send the transactions, without it only the plan is reported")
+	out := fs.String("out", "", "file the report is written to, stdout by default")
+	_ = fs.Parse(args)
+
+	cfg, _ := loadConfig()
+	treasuryAddress, err := decommissionTreasury(cfg, *treasury)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid treasury")
+	}
+	keep, ok := new(big.Int).SetString(*keepWei, 10)
+	if !ok || keep.Sign() < 0 {
+		log.Fatal().Str("keep_wei", *keepWei).Msg("Invalid amount to keep")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report := decommissionReport{StartedAt: time.Now().UTC(), ChainID: cfg.ChainID, Executed: *execute}
+	finish := func(err error) {
+		report.FinishedAt = time.Now().UTC()
+		if err != nil {
+			report.Error = err.Error()
+		}
+		if writeErr := writeDecommissionReport(*out, report); writeErr != nil {
+			log.Error().Err(writeErr).Msg("Failed to write the report")
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("Decommissioning stopped")
+		}
+	}
+
+	pipelines, updaters, _ := newUpdaters(cfg, nil, nil, nil, nil)
+	if *adminURL != "" {
+		if *execute {
+			for _, pipeline := range pipelines {
+				if err := pauseInstance(ctx, *adminURL, cfg.AdminToken, pipeline.Name); err != nil {
+					finish(fmt.Errorf("error pausing pipeline %s of the running updater: %w", pipeline.Name, err))
+				}
+			}
+			report.PausedInstance = *adminURL
+		}
+	} else {
+		log.Warn().Msg("No --admin-url, make sure no running updater submits for the sender meanwhile")
+	}
+
+	// The sender, with its nonce manager, is shared by the pipelines
+	waitCtx, cancel := context.WithTimeout(ctx, *timeout)
+	nonces, err := updaters[0].WaitPendingTransactions(waitCtx)
+	cancel()
+	if err != nil {
+		finish(fmt.Errorf("error waiting for pending transactions: %w", err))
+	}
+	report.Nonces = &nonces
+
+	for i, pipeline := range pipelines {
+		decommissioned := decommissionedPipeline{
+			Name:   pipeline.Name,
+			Signer: service.SignerRevocation{Skipped: "kept by --keep-signer"},
+		}
+		decommissioned.OracleRound, err = updaters[i].LatestRound(ctx)
+		if err != nil {
+			finish(err)
+		}
+		if !*keepSigner {
+			decommissioned.Signer, err = updaters[i].RevokeSigner(ctx, *execute)
+		}
+		report.Pipelines = append(report.Pipelines, decommissioned)
+		if err != nil {
+			finish(fmt.Errorf("error revoking the signer of pipeline %s: %w", pipeline.Name, err))
+		}
+	}
+
+	sweep, err := updaters[0].SweepSender(ctx, treasuryAddress, keep, *execute)
+	report.Sweep = &sweep
+	if err != nil {
+		finish(fmt.Errorf("error sweeping the sender: %w", err))
+	}
+
+	if *execute {
+		for i, decommissioned := range report.Pipelines {
+			updaters[i].RecordDecommission(decommissioned.OracleRound, map[string]string{
+				"treasury":       sweep.To,
+				"swept_wei":      sweep.AmountWei,
+				"sweep_tx_hash":  sweep.TxHash,
+				"signer_revoked": fmt.Sprintf("%t", decommissioned.Signer.Revoked),
+				"signer_tx_hash": decommissioned.Signer.TxHash,
+			})
+		}
+	}
+	finish(nil)
+	log.Info().Bool("executed", *execute).Msg("Decommissioning done")
+}
+
+// decommissionTreasury resolves the address the sender's balance is swept to
+func decommissionTreasury(cfg config.Config, flagValue string) (common.Address, error) {
+	value := flagValue
+	if value == "" {
+		value = cfg.DecommissionTreasury
+	}
+	if value == "" && cfg.TopUpTreasuryPrivateKey != "" {
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.TopUpTreasuryPrivateKey, "0x"))
+		if err != nil {
+			return common.Address{}, fmt.Errorf("error parsing treasury private key: %w", err)
+		}
+		return crypto.PubkeyToAddress(key.PublicKey), nil
+	}
+	if !common.IsHexAddress(value) {
+		return common.Address{}, fmt.Errorf("invalid treasury address %q, set --treasury or DECOMMISSION_TREASURY_ADDRESS", value)
+	}
+	return common.HexToAddress(value), nil
+}
+
+// pauseInstance pauses the submissions of a pipeline of a running updater
+// through its admin API
+func pauseInstance(ctx context.Context, baseURL, adminToken, pipeline string) error {
+	url := strings.TrimSuffix(baseURL, "/")
+	if pipeline != config.DefaultPipeline {
+		url += "/pipelines/" + pipeline
+	}
+	body, _ := json.Marshal(map[string]string{"reason": "decommission"})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/admin/submissions/pause", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("admin API returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	log.Info().Str("pipeline", pipeline).Str("instance", baseURL).Msg("Submissions of the running updater paused")
+	return nil
+}
+
+// writeDecommissionReport writes the report as indented JSON to path, or to
+// stdout when empty
+func writeDecommissionReport(path string, report decommissionReport) error {
+	var w io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
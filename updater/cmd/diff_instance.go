@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runDiffInstance prints the configuration and build differences between two running instances
+func runDiffInstance(args []string) {
+	fs := flag.NewFlagSet("diff-instance", flag.ExitOnError)
+	all := fs.Bool("all", false, "also print keys that are identical")
+	timeout := fs.Duration("timeout", 10*time.Second, "HTTP request timeout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: updater diff-instance [flags] <base-url-a> <base-url-b>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	a, b := strings.TrimSuffix(fs.Arg(0), "/"), strings.TrimSuffix(fs.Arg(1), "/")
+	httpClient := &http.Client{Timeout: *timeout}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "KEY\t%s\t%s\n", a, b)
+	differences := 0
+	for _, path := range []string{"/version", "/config"} {
+		valuesA, err := fetchFlatJSON(httpClient, a+path)
+		if err != nil {
+			log.Fatal().Err(err).Str("url", a+path).Msg("Failed to fetch instance")
+		}
+		valuesB, err := fetchFlatJSON(httpClient, b+path)
+		if err != nil {
+			log.Fatal().Err(err).Str("url", b+path).Msg("Failed to fetch instance")
+		}
+
+		for _, key := range unionKeys(valuesA, valuesB) {
+			valueA, okA := valuesA[key]
+			valueB, okB := valuesB[key]
+			if !okA {
+				valueA = "<unset>"
+			}
+			if !okB {
+				valueB = "<unset>"
+			}
+			if valueA == valueB && okA == okB {
+				if *all {
+					fmt.Fprintf(w, "  %s.%s\t%s\t%s\n", path, key, valueA, valueB)
+				}
+				continue
+			}
+			differences++
+			fmt.Fprintf(w, "! %s.%s\t%s\t%s\n", path, key, valueA, valueB)
+		}
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\n%d difference(s)\n", differences)
+	if differences > 0 {
+		os.Exit(1)
+	}
+}
+
+// fetchFlatJSON fetches a JSON object and returns its top-level values as strings
+func fetchFlatJSON(httpClient *http.Client, url string) (map[string]string, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var raw map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[key] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+func unionKeys(a, b map[string]string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		seen[key] = true
+	}
+	for key := range b {
+		seen[key] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/drandpool"
+)
+
+// newDrandPool returns the pool of a pipeline's drand relays, tracking the
+// health of every relay of DRAND_URLS
+func newDrandPool(cfg config.Config, chainHash []byte, pipeline string) (*drandpool.Pool, error) {
+	labels := make(map[string]string, len(cfg.DeploymentLabels)+1)
+	for k, v := range cfg.DeploymentLabels {
+		labels[k] = v
+	}
+	labels["pipeline"] = pipeline
+	return drandpool.New(cfg.DrandURLs, chainHash, drandpool.Options{
+		Timeout:         cfg.DrandRelayTimeout,
+		MaxLatency:      cfg.DrandRelayMaxLatency,
+		MaxErrorRate:    cfg.DrandRelayMaxErrorRate,
+		PruneAfter:      cfg.DrandRelayPruneAfter,
+		ProbeInterval:   cfg.DrandRelayProbeInterval,
+		LatencyWeight:   cfg.DrandRelayLatencyWeight,
+		StalenessWeight: cfg.DrandRelayStaleWeight,
+		CrossChecks:     cfg.DrandRelayCrossChecks,
+		MetricLabels:    labels,
+	})
+}
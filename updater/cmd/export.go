@@ -0,0 +1,85 @@
+package main
+
+import (
+	"drand-oracle-updater/internal/accounting"
+	"drand-oracle-updater/internal/store"
+	"flag"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runExport writes the sender transaction history to a CSV or JSON file for accounting
+func runExport(args []string) {
+	defaultStateDir := os.Getenv("STATE_DIR")
+	if defaultStateDir == "" {
+		defaultStateDir = "data"
+	}
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	stateDir := fs.String("state-dir", defaultStateDir, "state directory of the updater")
+	from := fs.String("from", "", "start date, inclusive (YYYY-MM-DD or RFC3339)")
+	to := fs.String("to", "", "end date, exclusive (YYYY-MM-DD or RFC3339)")
+	format := fs.String("format", string(accounting.FormatCSV), "output format: csv or json")
+	fields := fs.String("fields", "", "comma separated list of fields to export")
+	usdPrice := fs.String("usd-price", "", "price of the native token in USD, used for fee_usd")
+	out := fs.String("out", "", "output file, defaults to stdout")
+	_ = fs.Parse(args)
+
+	fromTime, err := accounting.ParseDate(*from)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --from date")
+	}
+	toTime, err := accounting.ParseDate(*to)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --to date")
+	}
+	exportFields, err := accounting.ParseFields(*fields)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --fields")
+	}
+	var price *big.Float
+	if *usdPrice != "" {
+		var ok bool
+		price, ok = new(big.Float).SetString(*usdPrice)
+		if !ok {
+			log.Fatal().Str("usd_price", *usdPrice).Msg("Invalid --usd-price")
+		}
+	}
+
+	stateStore, err := store.Open(*stateDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error opening state store")
+	}
+	txs, err := stateStore.Transactions(fromTime, toTime)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error reading transactions")
+	}
+
+	annotations, err := stateStore.Annotations()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error reading annotations")
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal().Err(err).Msg("error creating output file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	err = accounting.Export(w, txs, accounting.Options{
+		Format:      accounting.Format(*format),
+		Fields:      exportFields,
+		USDPrice:    price,
+		Annotations: annotations,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("error exporting transactions")
+	}
+}
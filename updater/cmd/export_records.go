@@ -0,0 +1,61 @@
+package main
+
+import (
+	"drand-oracle-updater/internal/records"
+	"drand-oracle-updater/internal/store"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runExportRecords writes the rounds index and the transaction history of a
+// state store in a record format, the same files ARCHIVE_DIR receives
+func runExportRecords(args []string) {
+	defaultStateDir := os.Getenv("STATE_DIR")
+	if defaultStateDir == "" {
+		defaultStateDir = "data"
+	}
+
+	fs := flag.NewFlagSet("export-records", flag.ExitOnError)
+	stateDir := fs.String("state-dir", defaultStateDir, "state directory of the updater")
+	format := fs.String("format", string(records.FormatJSONL), "record format: jsonl, protobuf or cbor")
+	out := fs.String("out", "records", "output directory, existing files are appended to")
+	_ = fs.Parse(args)
+
+	recordFormat, err := records.ParseFormat(*format)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --format")
+	}
+	stateStore, err := store.Open(*stateDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error opening state store")
+	}
+	writer, err := records.NewWriter(*out, recordFormat)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating output directory")
+	}
+
+	rounds, err := stateStore.Rounds()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error reading rounds")
+	}
+	for _, round := range rounds {
+		if err := writer.Append("rounds", round); err != nil {
+			log.Fatal().Err(err).Msg("error writing rounds")
+		}
+	}
+	log.Info().Str("path", writer.Path("rounds")).Int("records", len(rounds)).Msg("Exported rounds")
+
+	txs, err := stateStore.Transactions(time.Time{}, time.Time{})
+	if err != nil {
+		log.Fatal().Err(err).Msg("error reading transactions")
+	}
+	for _, tx := range txs {
+		if err := writer.Append("transactions", tx); err != nil {
+			log.Fatal().Err(err).Msg("error writing transactions")
+		}
+	}
+	log.Info().Str("path", writer.Path("transactions")).Int("records", len(txs)).Msg("Exported transactions")
+}
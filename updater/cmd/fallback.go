@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/config"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// fallbackOracle is a Drand Oracle contract on another chain read when the drand relays fail
+type fallbackOracle struct {
+	binding *binding.Binding
+	address common.Address
+}
+
+// newFallbackOracles connects to the configured fallback oracles and indexes
+// them by the hex chain hash of the drand network they serve
+func newFallbackOracles(cfg config.Config) (map[string]fallbackOracle, error) {
+	if len(cfg.FallbackOracleAddresses) == 0 {
+		return nil, nil
+	}
+	if cfg.FallbackRPC == "" {
+		return nil, errors.New("FALLBACK_RPC is required with FALLBACK_ORACLE_ADDRESSES")
+	}
+
+	log.Info().Str("rpc_url", cfg.FallbackRPC).Msg("Initializing fallback RPC client...")
+	rpcClient, err := ethclient.Dial(cfg.FallbackRPC)
+	if err != nil {
+		return nil, fmt.Errorf("error creating fallback rpc client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	oracles := make(map[string]fallbackOracle)
+	for _, address := range cfg.FallbackOracleAddresses {
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid fallback oracle address %q", address)
+		}
+		oracleAddress := common.HexToAddress(address)
+		oracleBinding, err := binding.NewBinding(oracleAddress, rpcClient)
+		if err != nil {
+			return nil, fmt.Errorf("error creating fallback oracle binding: %w", err)
+		}
+		chainHash, err := oracleBinding.CHAINHASH(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return nil, fmt.Errorf("error getting chain hash of fallback oracle %s: %w", oracleAddress.Hex(), err)
+		}
+		key := hex.EncodeToString(chainHash[:])
+		if _, ok := oracles[key]; ok {
+			return nil, fmt.Errorf("several fallback oracles serve chain %s", key)
+		}
+		oracles[key] = fallbackOracle{binding: oracleBinding, address: oracleAddress}
+		log.Info().Str("address", oracleAddress.Hex()).Str("chain_hash", key).Msg("Fallback oracle initialized")
+	}
+	return oracles, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/gas"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// newGasStrategy builds the gas price strategy from the configuration. Strategies
+// other than rpc fall back to the RPC suggested gas price when they fail.
+func newGasStrategy(cfg config.Config, rpcClient *ethclient.Client) (*gas.Bounded, error) {
+	name := cfg.GasStrategy
+	if name == "" {
+		name = gas.DefaultStrategy(cfg.ChainID)
+	}
+
+	strategy, err := buildGasStrategy(cfg, rpcClient, name)
+	if err != nil {
+		return nil, err
+	}
+
+	maxGasPrice, ok := new(big.Int).SetString(cfg.MaxGasPriceWei, 10)
+	if !ok || maxGasPrice.Sign() < 0 {
+		return nil, fmt.Errorf("invalid maximum gas price %q", cfg.MaxGasPriceWei)
+	}
+	bounded, err := gas.NewBounded(strategy, cfg.GasPriceMultiplier, maxGasPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("strategy", name).
+		Float64("multiplier", cfg.GasPriceMultiplier).
+		Str("max_gas_price_wei", maxGasPrice.String()).
+		Msg("Gas strategy initialized")
+	return bounded, nil
+}
+
+// buildGasStrategy returns the named strategy, falling back to the RPC
+// suggested gas price unless it is the rpc strategy
+func buildGasStrategy(cfg config.Config, rpcClient *ethclient.Client, name string) (gas.Strategy, error) {
+	rpcStrategy := gas.NewRPCStrategy(rpcClient)
+	switch name {
+	case gas.StrategyRPC:
+		return rpcStrategy, nil
+	case gas.StrategyPercentile:
+		percentile, err := gas.NewPercentileStrategy(rpcClient, cfg.GasPercentileBlocks, cfg.GasPercentile)
+		if err != nil {
+			return nil, err
+		}
+		return gas.NewFallback(percentile, rpcStrategy), nil
+	case gas.StrategyAPI:
+		api, err := gas.NewAPIStrategy(cfg.GasAPIURL, cfg.GasAPIKey, cfg.GasAPIField)
+		if err != nil {
+			return nil, err
+		}
+		return gas.NewFallback(api, rpcStrategy), nil
+	default:
+		return nil, fmt.Errorf("unknown gas strategy %q", name)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/rpcpool"
+	"drand-oracle-updater/internal/service"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// newHibernation returns the hibernation of the updaters, nil when
+// HIBERNATE_AFTER is not set. The idle connections of meter are closed on
+// hibernation.
+func newHibernation(cfg config.Config, meter *rpcpool.Meter) (*service.Hibernation, error) {
+	if cfg.HibernateAfter <= 0 {
+		return nil, nil
+	}
+	hibernation := &service.Hibernation{
+		After:        cfg.HibernateAfter,
+		PollInterval: cfg.HibernatePollInterval,
+	}
+	if cfg.HibernateWakeAddress != "" {
+		if !common.IsHexAddress(cfg.HibernateWakeAddress) {
+			return nil, fmt.Errorf("invalid hibernation wake address %q", cfg.HibernateWakeAddress)
+		}
+		hibernation.WakeAddress = common.HexToAddress(cfg.HibernateWakeAddress)
+	}
+	if cfg.HibernateWakeEvent != "" {
+		hibernation.WakeTopic = crypto.Keccak256Hash([]byte(cfg.HibernateWakeEvent))
+	}
+	if meter != nil {
+		hibernation.Connections = meter
+	}
+	log.Info().
+		Dur("after", hibernation.After).
+		Dur("poll_interval", hibernation.PollInterval).
+		Str("wake_event", cfg.HibernateWakeEvent).
+		Msg("Updaters hibernate while no request event is emitted")
+	return hibernation, nil
+}
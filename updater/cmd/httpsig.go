@@ -0,0 +1,27 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/httpsig"
+	"fmt"
+)
+
+// newWebhookSigner returns the signer of the outbound webhook payloads, nil
+// when WEBHOOK_SIGNING_KEYS is empty
+func newWebhookSigner(cfg config.Config) (*httpsig.Signer, error) {
+	keys, err := httpsig.ParseSigningKeys(cfg.WebhookSigningKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_SIGNING_KEYS: %w", err)
+	}
+	return httpsig.NewSigner(keys), nil
+}
+
+// newIngestVerifier returns the verifier of the pushed beacons, nil when
+// INGEST_VERIFY_KEYS is empty
+func newIngestVerifier(cfg config.Config) (*httpsig.Verifier, error) {
+	keys, err := httpsig.ParseVerifyingKeys(cfg.IngestVerifyKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid INGEST_VERIFY_KEYS: %w", err)
+	}
+	return httpsig.NewVerifier(keys, cfg.SignatureTolerance), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/leader"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Leader election backends
+const (
+	leaderElectionRedis      = "redis"
+	leaderElectionKubernetes = "kubernetes"
+)
+
+// newElector returns the leader elector of the configured backend, nil
+// without LEADER_ELECTION
+func newElector(cfg config.Config) (*leader.Elector, error) {
+	if cfg.LeaderElection == "" {
+		return nil, nil
+	}
+	identity := cfg.LeaderIdentity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error getting hostname for the leader identity: %w", err)
+		}
+		identity = hostname
+	}
+
+	var lock leader.Lock
+	var err error
+	switch cfg.LeaderElection {
+	case leaderElectionRedis:
+		if cfg.LeaderRedisURL == "" {
+			return nil, fmt.Errorf("LEADER_REDIS_URL is required with LEADER_ELECTION=%s", leaderElectionRedis)
+		}
+		lock, err = leader.NewRedisLock(cfg.LeaderRedisURL, cfg.LeaderLockName, identity, cfg.LeaderLeaseDuration)
+	case leaderElectionKubernetes:
+		lock, err = leader.NewKubernetesLease(cfg.LeaderNamespace, cfg.LeaderLockName, identity, cfg.LeaderLeaseDuration)
+	default:
+		return nil, fmt.Errorf("invalid leader election %q, expected %s or %s", cfg.LeaderElection, leaderElectionRedis, leaderElectionKubernetes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Info().
+		Str("lock", lock.Name()).
+		Str("name", cfg.LeaderLockName).
+		Str("identity", identity).
+		Dur("lease_duration", cfg.LeaderLeaseDuration).
+		Msg("Leader election enabled, only the leader submits transactions")
+	return leader.NewElector(lock, identity, cfg.LeaderLeaseDuration, cfg.DeploymentLabels), nil
+}
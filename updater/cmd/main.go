@@ -2,165 +2,673 @@ package main
 
 import (
 	"context"
-	"drand-oracle-updater/binding"
 	"drand-oracle-updater/config"
+	"drand-oracle-updater/gas"
+	"drand-oracle-updater/internal/accounts"
+	"drand-oracle-updater/internal/api"
+	"drand-oracle-updater/internal/audit"
+	"drand-oracle-updater/internal/finality"
+	"drand-oracle-updater/internal/limits"
+	"drand-oracle-updater/internal/probe"
+	"drand-oracle-updater/internal/region"
+	"drand-oracle-updater/internal/rpcpool"
 	"drand-oracle-updater/internal/service"
+	"drand-oracle-updater/internal/store"
 	"drand-oracle-updater/sender"
-	"drand-oracle-updater/signer"
-	"encoding/hex"
 	"fmt"
+	"math/big"
+	"net"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
-	"github.com/ethereum/go-ethereum/crypto"
-
-	"github.com/drand/drand/client"
-	drandHTTPClient "github.com/drand/drand/client/http"
-	drandLog "github.com/drand/drand/log"
-
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/kelseyhightower/envconfig"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 )
 
+// serverShutdownTimeout bounds the shutdown of the HTTP servers
+const serverShutdownTimeout = 5 * time.Second
+
 func main() {
-	var cfg config.Config
-	if err := envconfig.Process("", &cfg); err != nil {
-		log.Fatal().Err(err).Msg("Failed to process environment variables")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "export-records":
+			runExportRecords(os.Args[2:])
+			return
+		case "upload-archive":
+			runUploadArchive(os.Args[2:])
+			return
+		case "diff-instance":
+			runDiffInstance(os.Args[2:])
+			return
+		case "reindex":
+			runReindex(os.Args[2:])
+			return
+		case "simulate-gas":
+			runSimulateGas(os.Args[2:])
+			return
+		case "run":
+			run()
+			return
+		case "status":
+			runStatus(os.Args[2:])
+			return
+		case "submit":
+			runSubmit(os.Args[2:])
+			return
+		case "backfill":
+			runBackfill(os.Args[2:])
+			return
+		case "repl":
+			runRepl(os.Args[2:])
+			return
+		case "verify-config":
+			runVerifyConfig(os.Args[2:])
+			return
+		case "record-fixtures":
+			runRecordFixtures(os.Args[2:])
+			return
+		case "scaffold-consumer":
+			runScaffoldConsumer(os.Args[2:])
+			return
+		case "relay":
+			runRelay(os.Args[2:])
+			return
+		case "decommission":
+			runDecommission(os.Args[2:])
+			return
+		case "verify-audit":
+			runVerifyAudit(os.Args[2:])
+			return
+		case "bench-pipeline":
+			runBenchPipeline(os.Args[2:])
+			return
+		case "help", "-h", "--help":
+			printUsage()
+			return
+		case "--help-config":
+			printConfigSchema()
+			return
+		}
 	}
+	run()
+}
 
-	chainHash, err := hex.DecodeString(cfg.ChainHash)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to decode chain hash")
+// printUsage lists the subcommands
+func printUsage() {
+	fmt.Println(`Usage: updater [command] [flags]
+
+Commands:
+  run            Run the updater, the default without a command
+  status         Print the latest drand round against the oracle's latest round
+  submit         Force-submit a round
+  backfill       Submit a range of rounds
+  repl           Step rounds through the submission pipeline interactively
+  verify-config  Validate the environment and check connectivity
+  export         Export the accounting records
+  export-records Export the rounds and transactions as JSON lines, protobuf or CBOR
+  upload-archive Upload the rounds index to the archive bucket
+  diff-instance  Compare the configuration of two running instances
+  reindex        Rebuild the local rounds index
+  simulate-gas   Replay history under alternative gas strategies
+  record-fixtures
+                 Record live drand beacons and oracle transactions as JSON fixtures
+  scaffold-consumer
+                 Generate a Go consumer and a Solidity example contract for an oracle
+  relay          Set drand rounds on a non-EVM chain, such as Solana
+  decommission   Wind a deployment down and sweep the sender's balance to the treasury
+  verify-audit   Check the hash chain of an audit log file
+  bench-pipeline Measure the catch-up throughput of pipeline configurations
+
+Run "updater <command> -h" for the flags of a command, and "updater --help-config"
+for the configuration variables.`)
+}
+
+// printConfigSchema lists the configuration variables with their types and defaults
+func printConfigSchema() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIABLE\tTYPE\tDEFAULT\t")
+	for _, variable := range config.Schema() {
+		def := variable.Default
+		switch {
+		case variable.Required:
+			def = "required"
+		case def == "":
+			def = "-"
+		}
+		var notes string
+		if variable.Secret {
+			notes = "secret"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", variable.Name, variable.Type, def, notes)
 	}
+	_ = w.Flush()
+}
 
-	// Initialize drand client
-	log.Info().
-		Str("drand_urls", strings.Join(cfg.DrandURLs, ",")).
-		Str("chain_hash", hex.EncodeToString(chainHash)).
-		Msg("Initializing drand client...")
-	drandClient, err := client.New(
-		client.From(drandHTTPClient.ForURLs(cfg.DrandURLs, chainHash)...),
-		client.WithChainHash(chainHash),
-		client.WithLogger(drandLog.NewLogger(os.Stdout, drandLog.LogError)), // Only log errors
-	)
+// loadConfig processes the configuration file, if any, and the environment
+// variables, and attaches the deployment labels to every log line
+func loadConfig() (config.Config, *config.Loader) {
+	loader := config.NewLoader(os.Getenv(config.ConfigFileEnv))
+	cfg, err := loader.Load()
 	if err != nil {
-		log.Fatal().Err(err).Msg("error creating client")
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	for _, unknown := range loader.Unknown() {
+		log.Warn().Str("variable", unknown.Name).Str("suggestion", unknown.Suggestion).Msg("Unknown configuration variable ignored, set STRICT_CONFIG=true to refuse it")
 	}
 
-	// Initialize RPC client
-	log.Info().Str("rpc_url", cfg.RPC).Msg("Initializing RPC client...")
-	rpcClient, err := ethclient.Dial(cfg.RPC)
-	if err != nil {
-		log.Fatal().Err(err).Msg("error creating rpc client")
+	if len(cfg.DeploymentLabels) > 0 {
+		logContext := log.Logger.With()
+		for k, v := range cfg.DeploymentLabels {
+			logContext = logContext.Str(k, v)
+		}
+		log.Logger = logContext.Logger()
 	}
+	return cfg, loader
+}
 
-	// Initialize contract binding
-	contractAddress := common.HexToAddress(cfg.DrandOracleAddress)
-	log.Info().Str("address", contractAddress.Hex()).Msg("Initializing DrandOracle contract binding...")
-	binding, err := binding.NewBinding(contractAddress, rpcClient)
-	if err != nil {
-		log.Fatal().Err(err).Msg("error creating binding")
+// lintConfig logs the dangerous combinations of the configuration, and
+// refuses to start on the errors
+func lintConfig(cfg config.Config) {
+	failed := false
+	for _, finding := range config.Lint(cfg) {
+		event := log.Warn()
+		if finding.Severity == config.LintError {
+			event, failed = log.Error(), true
+		}
+		event.Str("rule", finding.Rule).Msg(finding.Message)
+	}
+	if failed {
+		log.Fatal().Msg("Dangerous configuration, fix it or silence the rule with CONFIG_LINT_IGNORE")
 	}
+}
 
-	// Initialize signer
-	log.Info().Int64("chain_id", cfg.ChainID).Msg("Initializing signer...")
-	signerPrivateKey, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.SignerPrivateKey, "0x"))
+func run() {
+	cfg, loader := loadConfig()
+	lintConfig(cfg)
+	stopTracing := setupTracing(cfg)
+	defer stopTracing()
+	elector, err := newElector(cfg)
 	if err != nil {
-		log.Fatal().Err(err).Msg("error parsing private key")
+		log.Fatal().Err(err).Msg("error creating leader elector")
 	}
-	signer := signer.NewSigner(cfg.ChainID, contractAddress, signerPrivateKey)
-	log.Info().Str("address", signer.Address().Hex()).Msg("Signer initialized")
-
-	// Initialize sender
-	log.Info().Int64("chain_id", cfg.ChainID).Msg("Initializing sender...")
-	senderPrivateKey, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.SenderPrivateKey, "0x"))
+	var election service.LeaderElection
+	switch {
+	case elector != nil && cfg.ColdStandby:
+		log.Fatal().Msg("COLD_STANDBY and LEADER_ELECTION are mutually exclusive")
+	case elector != nil:
+		election = elector
+	case cfg.ColdStandby:
+		log.Warn().Msg("Cold standby, rounds are left to the primary instance")
+		election = service.ColdStandby{}
+	}
+	var health *probe.Health
+	ownership, err := newOwnership(cfg, &health)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating region ownership")
+	}
+	if ownership != nil && election != nil {
+		log.Fatal().Msg("REGION is mutually exclusive with LEADER_ELECTION and COLD_STANDBY")
+	}
+	governor := limits.New(limits.Options{
+		MemoryLimit:           cfg.MemoryLimitBytes,
+		MaxGoroutines:         cfg.MaxGoroutines,
+		Threshold:             cfg.DegradeThreshold,
+		MaxConcurrentCatchUps: cfg.MaxConcurrentCatchUps,
+		MetricLabels:          cfg.DeploymentLabels,
+	})
+	if governor != nil {
+		log.Info().
+			Int64("memory_limit_bytes", governor.MemoryLimit()).
+			Int("max_goroutines", cfg.MaxGoroutines).
+			Int("max_concurrent_catchups", cfg.MaxConcurrentCatchUps).
+			Msg("Resource limits initialized")
+	}
+	auditLog, err := newAuditLog(cfg)
 	if err != nil {
-		log.Fatal().Err(err).Msg("error parsing private key")
+		log.Fatal().Err(err).Msg("Invalid audit log")
+	}
+	pipelines, updaters, gasStrategy := newUpdaters(cfg, election, ownership, governor, auditLog)
+	ingestVerifier, err := newIngestVerifier(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating the beacon ingestion verifier")
+	}
+	apiServers := make(map[string]*api.Server, len(pipelines))
+	targets := make(map[string]probe.Target, len(pipelines))
+	for i, pipeline := range pipelines {
+		apiServers[pipeline.Name] = api.NewServer(updaters[i], cfg, ingestVerifier)
+		targets[pipeline.Name] = updaters[i]
 	}
-	sender := sender.NewSender(cfg.ChainID, senderPrivateKey)
-	log.Info().Str("address", sender.Address().Hex()).Msg("Sender initialized")
+	health = probe.NewHealth(targets)
 
-	// Initialize updater service
-	log.Info().Msg("Initializing updater service...")
-	updater, err := service.NewUpdater(drandClient, rpcClient, cfg.SetRandomnessGasLimit, cfg.ChainID, contractAddress, binding, cfg.GenesisRound, cfg.MaxRetries, signer, sender)
+	// Stop gracefully on SIGINT and SIGTERM
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	apiTLSConfig, err := newAPITLSConfig(cfg)
 	if err != nil {
-		log.Fatal().Err(err).Msg("error creating updater")
+		log.Fatal().Err(err).Msg("Invalid API TLS configuration")
+	}
+	apiServer := &http.Server{
+		Addr:      fmt.Sprintf(":%d", cfg.HttpPort),
+		Handler:   withRegionStatus(api.PipelinesHandler(apiServers), ownership),
+		TLSConfig: apiTLSConfig,
+	}
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.MetricsPort),
+		Handler: promhttp.Handler(),
 	}
 
 	// Start all services
 	log.Info().Msg("Starting services...")
 	errGroup, ctx := errgroup.WithContext(context.Background())
-	errGroup.Go(func() error {
-		if err := updater.Start(ctx); err != nil {
-			log.Error().Err(err).Msg("error running updater")
-			return err
-		}
-		return nil
-	})
+	for i, updater := range updaters {
+		pipeline := pipelines[i].Name
+		errGroup.Go(func() error {
+			if err := updater.Start(ctx); err != nil {
+				log.Error().Err(err).Str("pipeline", pipeline).Msg("error running updater")
+				return err
+			}
+			return nil
+		})
+	}
 
-	// Start health check server
+	// Sample the resource usage, degrading background work near the limits
+	governorCtx, stopGovernor := context.WithCancel(ctx)
+	defer stopGovernor()
 	errGroup.Go(func() error {
-		log.Info().Int("port", cfg.HttpPort).Msg("Starting health check server...")
+		return governor.Run(governorCtx)
+	})
 
-		healthMux := http.NewServeMux()
-		healthMux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			_, err := w.Write([]byte("OK"))
-			if err != nil {
-				log.Error().Err(err).Msg("error writing health check response")
+	// Campaign for leadership until the updaters are stopped, releasing the
+	// lease for a standby to take over right away
+	electionCtx, stopElection := context.WithCancel(ctx)
+	defer stopElection()
+	if elector != nil {
+		errGroup.Go(func() error {
+			if err := elector.Run(electionCtx); err != nil && electionCtx.Err() == nil {
+				return err
 			}
+			return nil
 		})
-
-		healthServer := &http.Server{
-			Addr:    fmt.Sprintf(":%d", cfg.HttpPort),
-			Handler: healthMux,
-		}
-
-		go func() {
-			<-ctx.Done()
-			err := healthServer.Shutdown(context.Background())
-			if err != nil {
-				log.Error().Err(err).Msg("error shutting down health check server")
+	}
+	if ownership != nil {
+		errGroup.Go(func() error {
+			if err := ownership.Run(electionCtx); err != nil && electionCtx.Err() == nil {
+				return err
 			}
-		}()
+			return nil
+		})
+	}
 
-		if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error().Err(err).Msg("error running health check server")
+	// Start health check and API server
+	errGroup.Go(func() error {
+		log.Info().Int("port", cfg.HttpPort).Bool("tls", apiTLSConfig != nil).Msg("Starting health check and API server...")
+		serve := apiServer.ListenAndServe
+		if apiTLSConfig != nil {
+			serve = func() error { return apiServer.ListenAndServeTLS(cfg.HttpTLSCert, cfg.HttpTLSKey) }
+		}
+		if err := serve(); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("error running API server")
 			return err
 		}
 		return nil
 	})
 
+	// Start the gRPC health server, for probes that cannot do HTTP
+	var grpcServer *grpc.Server
+	if cfg.GRPCHealthPort != 0 {
+		grpcServer = probe.NewGRPCServer(probe.NewGRPCHealth(health, cfg.ProbeInterval))
+		errGroup.Go(func() error {
+			log.Info().Int("port", cfg.GRPCHealthPort).Msg("Starting gRPC health server...")
+			listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCHealthPort))
+			if err != nil {
+				log.Error().Err(err).Msg("error running gRPC health server")
+				return err
+			}
+			if err := grpcServer.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+				log.Error().Err(err).Msg("error running gRPC health server")
+				return err
+			}
+			return nil
+		})
+	}
+
+	// Touch the probe files until shutdown
+	probeCtx, stopProbes := context.WithCancel(ctx)
+	defer stopProbes()
+	if cfg.LivenessFile != "" || cfg.ReadinessFile != "" {
+		files := probe.NewFiles(health, cfg.LivenessFile, cfg.ReadinessFile, cfg.ProbeInterval)
+		errGroup.Go(func() error {
+			_ = files.Run(probeCtx)
+			return nil
+		})
+	}
+
 	// Start metrics server
 	errGroup.Go(func() error {
 		log.Info().Int("port", cfg.MetricsPort).Msg("Starting metrics server...")
-
-		metricsServer := &http.Server{
-			Addr:    fmt.Sprintf(":%d", cfg.MetricsPort),
-			Handler: promhttp.Handler(),
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("error running metrics server")
+			return err
 		}
+		return nil
+	})
 
-		go func() {
-			<-ctx.Done()
-			err := metricsServer.Shutdown(context.Background())
-			if err != nil {
-				log.Error().Err(err).Msg("error shutting down metrics server")
+	// Reload the configuration file until shutdown
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	if loader.Path() != "" {
+		errGroup.Go(func() error {
+			if err := watchConfig(watchCtx, loader, cfg, updaters, gasStrategy); err != nil {
+				log.Error().Err(err).Msg("Failed to watch configuration file, reload with a restart")
 			}
-		}()
+			return nil
+		})
+	}
 
-		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error().Err(err).Msg("error running metrics server")
-			return err
+	// Shut down on signal, or when any service fails
+	errGroup.Go(func() error {
+		defer stopWatch()
+		select {
+		case <-signalCtx.Done():
+			// A second signal terminates the process immediately
+			stopSignals()
+			log.Info().Dur("timeout", cfg.ShutdownTimeout).Msg("Shutdown signal received, stopping updaters...")
+			stopCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+			defer cancel()
+			var stopGroup sync.WaitGroup
+			for i, updater := range updaters {
+				stopGroup.Add(1)
+				go func() {
+					defer stopGroup.Done()
+					if err := updater.Stop(stopCtx); err != nil {
+						log.Error().Err(err).Str("pipeline", pipelines[i].Name).Msg("error stopping updater")
+					}
+				}()
+			}
+			stopGroup.Wait()
+		case <-ctx.Done():
+		}
+		stopElection()
+		stopGovernor()
+
+		// The servers are shut down last so that probes and metrics stay
+		// available while draining
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		if err := apiServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("error shutting down API server")
+		}
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("error shutting down metrics server")
+		}
+		if grpcServer != nil {
+			grpcServer.Stop()
 		}
+		stopProbes()
 		return nil
 	})
 
-	if err := errGroup.Wait(); err != nil {
+	err = errGroup.Wait()
+	// The updaters stopped, the entries buffered for the audit bucket are
+	// flushed even after a failure
+	closeAuditLog(auditLog)
+	// log.Fatal exits without waiting for the alerts sent in the background,
+	// such as the round_failed alert of the failure
+	flushAlerts(updaters)
+	if err != nil {
 		log.Fatal().Err(err).Msg("service error")
 	}
+	log.Info().Msg("Updater stopped")
+}
+
+// newUpdaters builds the updater of every pipeline, along with the RPC clients,
+// the sender and the options they share, submitting only while election, if
+// not nil, elects this replica, within the resource limits of governor if not
+// nil, recording their decisions to auditLog if not nil. The shared gas
+// strategy is returned so that its bounds can be reloaded.
+func newUpdaters(cfg config.Config, election service.LeaderElection, ownership *region.Ownership, governor *limits.Governor, auditLog *audit.Log) ([]config.Pipeline, []*service.Updater, *gas.Bounded) {
+	pipelines, err := cfg.AllPipelines()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid pipelines")
+	}
+
+	// Initialize RPC client
+	log.Info().Str("rpc_url", cfg.RPC).Int("fallback_urls", len(cfg.RPCFallbackURLs)).Msg("Initializing RPC client...")
+	rpcClient, rpcMeter, err := rpcpool.Dial(append([]string{cfg.RPC}, cfg.RPCFallbackURLs...), rpcpool.Options{
+		Timeout:      cfg.RPCTimeout,
+		RoundRobin:   cfg.RPCRoundRobin,
+		MetricLabels: cfg.DeploymentLabels,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating rpc client")
+	}
+
+	// Initialize the events client, used to subscribe to the oracle events
+	var eventsClient *ethclient.Client
+	if cfg.RPCWS != "" {
+		log.Info().Str("rpc_ws_url", cfg.RPCWS).Msg("Initializing events client...")
+		eventsClient, err = ethclient.Dial(cfg.RPCWS)
+		if err != nil {
+			log.Fatal().Err(err).Msg("error creating events client")
+		}
+	}
+
+	// Initialize sender
+	log.Info().Int64("chain_id", cfg.ChainID).Msg("Initializing sender...")
+	senderKey, senderPrivateKey, err := newSenderKey(context.Background(), cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading sender key")
+	}
+	txSender := sender.NewSender(cfg.ChainID, senderKey)
+	log.Info().Str("address", txSender.Address().Hex()).Msg("Sender initialized")
+	userOps, err := newUserOpSender(cfg, senderPrivateKey, rpcClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating user operation sender")
+	}
+
+	submissionFee, ok := new(big.Int).SetString(cfg.SubmissionFeeWei, 10)
+	if !ok || submissionFee.Sign() < 0 {
+		log.Fatal().Str("submission_fee_wei", cfg.SubmissionFeeWei).Msg("Invalid submission fee")
+	}
+	minSenderBalance, ok := new(big.Int).SetString(cfg.MinSenderBalanceWei, 10)
+	if !ok || minSenderBalance.Sign() < 0 {
+		log.Fatal().Str("min_sender_balance_wei", cfg.MinSenderBalanceWei).Msg("Invalid minimum sender balance")
+	}
+	lossLimit, ok := new(big.Int).SetString(cfg.LossLimitWei, 10)
+	if !ok || lossLimit.Sign() < 0 {
+		log.Fatal().Str("loss_limit_wei", cfg.LossLimitWei).Msg("Invalid loss limit")
+	}
+	catchUpCostCeiling, ok := new(big.Int).SetString(cfg.CatchUpCostCeilingWei, 10)
+	if !ok || catchUpCostCeiling.Sign() < 0 {
+		log.Fatal().Str("catchup_cost_ceiling_wei", cfg.CatchUpCostCeilingWei).Msg("Invalid catch-up cost ceiling")
+	}
+
+	// Initialize gas strategy
+	gasStrategy, err := newGasStrategy(cfg, rpcClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating gas strategy")
+	}
+
+	fallbackOracles, err := newFallbackOracles(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating fallback oracles")
+	}
+
+	pauseProposer, err := newPauseProposer(cfg, rpcClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating pause proposer")
+	}
+
+	topUps, err := newTopUpManager(cfg, rpcClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating top-up manager")
+	}
+
+	selfTest, err := newSelfTest(cfg, rpcClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating self-test")
+	}
+
+	schedulerPolicy, err := service.ParsePolicy(cfg.SchedulerPolicy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid scheduler policy")
+	}
+	catchUpPolicy, err := service.ParseCatchUpPolicy(cfg.CatchUpPolicy, cfg.CatchUpEvery, cfg.CatchUpMaxAge)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid catch-up policy")
+	}
+	calldataForm, err := service.ParseCalldataForm(cfg.CalldataForm)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid calldata form")
+	}
+	finalityPolicy, err := finality.ParsePolicy(cfg.Finality, cfg.FinalityDepth, cfg.ChainID, cfg.FinalityChains)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid finality policy")
+	}
+	log.Info().
+		Str("mode", string(finalityPolicy.Mode)).
+		Uint64("depth", finalityPolicy.Depth).
+		Msg("Finality policy initialized")
+	if catchUpPolicy.Skips() {
+		log.Warn().
+			Str("policy", string(catchUpPolicy.Mode)).
+			Uint64("every", catchUpPolicy.Every).
+			Dur("max_age", catchUpPolicy.MaxAge).
+			Msg("Catch-up policy skips rounds, the oracle will have gaps")
+	}
+
+	keyRotation, err := newKeyRotation(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading next keys")
+	}
+
+	hibernation, err := newHibernation(cfg, rpcMeter)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid hibernation")
+	}
+	// The health of pooled endpoints is tracked by the pool
+	var rpcEndpoints service.RPCEndpoints
+	if rpcMeter != nil && rpcMeter.Pool() != nil {
+		rpcEndpoints = rpcMeter.Pool()
+	}
+
+	releases, err := newReleases(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid release publisher")
+	}
+	consumers, err := newConsumerNotifier(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid consumer webhooks")
+	}
+
+	if cfg.DryRun {
+		log.Warn().Msg("Dry run mode: transactions are simulated and never broadcast")
+	}
+	if cfg.CatchUpBatchSize > 1 && (cfg.DryRun || userOps != nil) {
+		log.Warn().Int("batch_size", cfg.CatchUpBatchSize).Msg("Catch-up batching is disabled for dry runs and user operations, rounds are submitted one by one")
+	}
+
+	// Poll the balances and nonces of the managed accounts in one batch,
+	// rather than once per pipeline
+	accountPoller := accounts.NewPoller(rpcClient.Client(), accounts.Options{
+		Interval:     cfg.AccountPollInterval,
+		MetricLabels: cfg.DeploymentLabels,
+	})
+	accountPoller.Watch("sender", txSender.Address())
+	if keyRotation.NextSenderKey != nil {
+		accountPoller.Watch("next_sender", keyRotation.NextSenderKey.Address())
+	}
+	if userOps != nil {
+		accountPoller.Watch("smart_account", userOps.Address())
+	}
+	if topUps != nil {
+		if treasury, ok := topUps.TreasuryAddress(); ok {
+			accountPoller.Watch("treasury", treasury)
+		}
+	}
+
+	// Initialize one updater per pipeline. They share the sender, so they also
+	// share its nonce manager.
+	options := service.Options{
+		SubmissionFee:    submissionFee,
+		MinSenderBalance: minSenderBalance,
+		MaxRoundLag:      cfg.MaxRoundLag,
+		Retention: store.Retention{
+			MaxAge:          cfg.StateRetention,
+			MaxRounds:       cfg.StateRetentionRounds,
+			RoundsMaxAge:    cfg.StateRoundsRetention,
+			RoundsMaxRounds: cfg.StateRoundsMaxRounds,
+		},
+		CompactionInterval:     cfg.StateCompactionInterval,
+		ArchiveVerifyRate:      cfg.ArchiveVerifyRate,
+		ArchiveVerifyInterval:  cfg.ArchiveVerifyInterval,
+		DryRun:                 cfg.DryRun,
+		GasStrategy:            gasStrategy,
+		SchedulerPolicy:        schedulerPolicy,
+		QueueSize:              cfg.SchedulerQueueSize,
+		LossLimit:              lossLimit,
+		LossWindow:             cfg.LossWindow,
+		LossCooldown:           cfg.LossCooldown,
+		AlertAfterRetries:      cfg.AlertAfterRetries,
+		CatchUpCostCeiling:     catchUpCostCeiling,
+		CatchUpRequireApproval: cfg.CatchUpRequireApproval,
+		CatchUpPolicy:          catchUpPolicy,
+		BatchSize:              cfg.CatchUpBatchSize,
+		CatchUpFetchWorkers:    cfg.CatchUpFetchWorkers,
+		CatchUpSignWorkers:     cfg.CatchUpSignWorkers,
+		InFlightRounds:         cfg.SubmissionPipelineDepth,
+		DeploymentLabels:       cfg.DeploymentLabels,
+		Nonces:                 sender.NewNonceManager(rpcClient, txSender),
+		EventsClient:           eventsClient,
+		EventsPollInterval:     cfg.EventsPollInterval,
+		MinSetDelay:            cfg.MinSetDelay,
+		ChainClockMaxWait:      cfg.ChainClockMaxWait,
+		ConfirmCommitted:       cfg.FinalityConfirm,
+		CalldataForm:           calldataForm,
+		Audit:                  auditLog,
+		Consumers:              consumers,
+		UserOperations:         userOps,
+		Leader:                 election,
+		Ownership:              ownership,
+		PauseProposer:          pauseProposer,
+		TopUps:                 topUps,
+		FundWait:               cfg.TopUpStartupWait,
+		Accounts:               accountPoller,
+		Limits:                 governor,
+		Finality:               finalityPolicy,
+		KeyRotation:            keyRotation,
+		Hibernation:            hibernation,
+		Releases:               releases,
+		RPCEndpoints:           rpcEndpoints,
+		Retry:                  retryPolicy(cfg),
+	}
+	updaters := make([]*service.Updater, len(pipelines))
+	for i, pipeline := range pipelines {
+		// The test oracle serves the default pipeline's drand network
+		pipelineOptions := options
+		if pipeline.Name == config.DefaultPipeline {
+			pipelineOptions.SelfTest = selfTest
+		}
+		pipelineOptions.Canary, err = newCanary(cfg, rpcClient, pipeline.Name)
+		if err != nil {
+			log.Fatal().Err(err).Str("pipeline", pipeline.Name).Msg("error creating canary rollout")
+		}
+		updaters[i], err = newPipelineUpdater(cfg, pipeline, rpcClient, txSender, fallbackOracles, pipelineOptions)
+		if err != nil {
+			log.Fatal().Err(err).Str("pipeline", pipeline.Name).Msg("error creating updater")
+		}
+	}
+	return pipelines, updaters, gasStrategy
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/notify"
+	"drand-oracle-updater/internal/retry"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// newConsumerNotifier returns the notifier posting the rounds landing on the
+// oracle to the consumer webhooks, nil when CONSUMER_WEBHOOK_URLS is empty.
+// Payloads are signed with WEBHOOK_SIGNING_KEYS.
+func newConsumerNotifier(cfg config.Config) (*notify.Notifier, error) {
+	if len(cfg.ConsumerWebhookURLs) == 0 {
+		return nil, nil
+	}
+	signer, err := newWebhookSigner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := notify.New(cfg.ConsumerWebhookURLs, notify.Options{
+		Timeout: cfg.ConsumerWebhookTimeout,
+		Retry: retry.Policy{
+			MaxAttempts:    cfg.ConsumerWebhookAttempts,
+			InitialBackoff: time.Second,
+			MaxBackoff:     30 * time.Second,
+			Jitter:         retry.DefaultPolicy.Jitter,
+		},
+		QueueSize:    cfg.ConsumerWebhookQueueSize,
+		Signer:       signer,
+		MetricLabels: cfg.DeploymentLabels,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int("webhooks", len(cfg.ConsumerWebhookURLs)).Msg("Posting new rounds to consumer webhooks")
+	return notifier, nil
+}
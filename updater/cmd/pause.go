@@ -0,0 +1,39 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/safe"
+	"drand-oracle-updater/internal/service"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// newPauseProposer returns the proposer of oracle pauses to the owner Safe,
+// nil when PAUSE_SAFE_ADDRESS is not set
+func newPauseProposer(cfg config.Config, rpcClient *ethclient.Client) (service.PauseProposer, error) {
+	if cfg.PauseSafeAddress == "" {
+		return nil, nil
+	}
+	if !common.IsHexAddress(cfg.PauseSafeAddress) {
+		return nil, fmt.Errorf("invalid pause safe address %q", cfg.PauseSafeAddress)
+	}
+	if cfg.PauseSafeTxServiceURL == "" || cfg.PauseProposerPrivateKey == "" {
+		return nil, errors.New("PAUSE_SAFE_TX_SERVICE_URL and PAUSE_PROPOSER_PRIVATE_KEY are required with PAUSE_SAFE_ADDRESS")
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.PauseProposerPrivateKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing pause proposer private key: %w", err)
+	}
+	proposer := safe.NewProposer(cfg.PauseSafeTxServiceURL, common.HexToAddress(cfg.PauseSafeAddress), cfg.ChainID, key, rpcClient)
+	log.Info().
+		Str("safe", proposer.Safe().Hex()).
+		Str("proposer", proposer.Address().Hex()).
+		Msg("Oracle pauses are proposed to the Safe on failed security checks")
+	return proposer, nil
+}
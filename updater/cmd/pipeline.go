@@ -0,0 +1,162 @@
+package main
+
+import (
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/fallback"
+	"drand-oracle-updater/internal/pacer"
+	"drand-oracle-updater/internal/records"
+	"drand-oracle-updater/internal/service"
+	"drand-oracle-updater/internal/store"
+	"drand-oracle-updater/sender"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/drand/drand/client"
+	drandLog "github.com/drand/drand/log"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// pipelineStateDir returns the state directory of a pipeline. The default
+// pipeline keeps the top-level directory so existing state is picked up.
+func pipelineStateDir(cfg config.Config, pipeline config.Pipeline) string {
+	if pipeline.Name == config.DefaultPipeline {
+		return cfg.StateDir
+	}
+	return filepath.Join(cfg.StateDir, "pipelines", pipeline.Name)
+}
+
+// pipelineArchiveDir returns the archive directory of a pipeline, laid out as
+// the state directories
+func pipelineArchiveDir(cfg config.Config, pipeline config.Pipeline) string {
+	if pipeline.Name == config.DefaultPipeline {
+		return cfg.ArchiveDir
+	}
+	return filepath.Join(cfg.ArchiveDir, "pipelines", pipeline.Name)
+}
+
+// newPipelineUpdater builds the updater of a pipeline: its drand client,
+// contract binding, signer, alerting and state store. The RPC client, the
+// sender, the fallback oracles and the shared options are common to all pipelines.
+func newPipelineUpdater(
+	cfg config.Config,
+	pipeline config.Pipeline,
+	rpcClient *ethclient.Client,
+	sender *sender.Sender,
+	fallbackOracles map[string]fallbackOracle,
+	options service.Options,
+) (*service.Updater, error) {
+	logger := log.With().Str("pipeline", pipeline.Name).Logger()
+
+	chainHash, err := hex.DecodeString(pipeline.ChainHash)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding chain hash: %w", err)
+	}
+
+	// Initialize drand client
+	logger.Info().
+		Str("drand_urls", strings.Join(cfg.DrandURLs, ",")).
+		Str("chain_hash", hex.EncodeToString(chainHash)).
+		Msg("Initializing drand client...")
+	relays, err := newDrandPool(cfg, chainHash, pipeline.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error creating drand relay pool: %w", err)
+	}
+	options.DrandRelays = relays
+	drandClient, err := client.New(
+		client.From(relays),
+		client.WithChainHash(chainHash),
+		client.WithLogger(drandLog.NewLogger(os.Stdout, drandLog.LogError)), // Only log errors
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %w", err)
+	}
+	if cfg.DrandAdaptivePolling {
+		drandClient = pacer.NewClient(drandClient, pacer.Options{
+			Lead:     cfg.DrandPollLead,
+			Interval: cfg.DrandPollInterval,
+			Jitter:   cfg.DrandPollJitter,
+		})
+	}
+	if oracle, ok := fallbackOracles[hex.EncodeToString(chainHash)]; ok {
+		logger.Info().Str("address", oracle.address.Hex()).Msg("Falling back to the fallback oracle when the drand relays fail")
+		drandClient = fallback.NewClient(drandClient, oracle.binding, oracle.address, fallback.Options{
+			StallTimeout:    cfg.FallbackStallTimeout,
+			RequireVerified: cfg.FallbackRequireVerified,
+		})
+	}
+
+	// Initialize contract binding
+	contractAddress := common.HexToAddress(pipeline.OracleAddress)
+	logger.Info().Str("address", contractAddress.Hex()).Msg("Initializing DrandOracle contract binding...")
+	var backend bind.ContractBackend = rpcClient
+	callCache, err := newCallCache(cfg, rpcClient, pipeline.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error creating call cache: %w", err)
+	}
+	if callCache != nil {
+		backend = callCache
+		options.CallCache = callCache
+	}
+	binding, err := binding.NewBinding(contractAddress, backend)
+	if err != nil {
+		return nil, fmt.Errorf("error creating binding: %w", err)
+	}
+
+	// Initialize signer, whose EIP-712 domain is bound to the oracle contract
+	logger.Info().Int64("chain_id", cfg.ChainID).Msg("Initializing signer...")
+	signer, err := newSetRandomnessSigner(cfg, contractAddress)
+	if err != nil {
+		return nil, fmt.Errorf("error creating signer: %w", err)
+	}
+	logger.Info().Str("address", signer.Address().Hex()).Msg("Signer initialized")
+
+	// Initialize alerting
+	options.Alerts, err = newAlertDispatcher(cfg, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error creating alert dispatcher: %w", err)
+	}
+
+	// Initialize state store
+	stateDir := pipelineStateDir(cfg, pipeline)
+	logger.Info().Str("dir", stateDir).Msg("Initializing state store...")
+	stateStore, err := store.Open(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening state store: %w", err)
+	}
+	var sinks store.Sinks
+	if cfg.ArchiveDir != "" {
+		format, err := records.ParseFormat(cfg.ArchiveFormat)
+		if err != nil {
+			return nil, err
+		}
+		archive, err := records.NewWriter(pipelineArchiveDir(cfg, pipeline), format)
+		if err != nil {
+			return nil, fmt.Errorf("error opening archive: %w", err)
+		}
+		logger.Info().Str("dir", pipelineArchiveDir(cfg, pipeline)).Str("format", string(format)).Msg("Archiving rounds and transactions")
+		sinks = append(sinks, archive)
+	}
+	roundObjects, err := newRoundObjects(cfg, hex.EncodeToString(chainHash), filepath.Join(stateDir, roundObjectsPending))
+	if err != nil {
+		return nil, err
+	}
+	if roundObjects != nil {
+		logger.Info().Str("bucket", cfg.ArchiveS3Bucket).Str("round_url", roundObjects.URL(pipeline.GenesisRound)).Msg("Uploading every round to the archive bucket")
+		sinks = append(sinks, roundObjects)
+		options.RoundObjects = roundObjects
+	}
+	if len(sinks) > 0 {
+		stateStore.SetSink(sinks)
+	}
+
+	options.Pipeline = pipeline.Name
+	logger.Info().Msg("Initializing updater service...")
+	return service.NewUpdater(drandClient, rpcClient, cfg.SetRandomnessGasLimit, cfg.ChainID, contractAddress, binding, pipeline.GenesisRound, cfg.MaxRetries, signer, sender, stateStore, options)
+}
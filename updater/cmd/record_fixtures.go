@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/fixtures"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// runRecordFixtures records a window of live drand beacons and oracle
+// interactions as a JSON fixture
+func runRecordFixtures(args []string) {
+	fs := flag.NewFlagSet("record-fixtures", flag.ExitOnError)
+	rpcURL := fs.String("rpc", os.Getenv("RPC"), "RPC URL")
+	oracleAddress := fs.String("oracle-address", os.Getenv("DRAND_ORACLE_ADDRESS"), "Drand Oracle contract address")
+	drandURLs := fs.String("drand-urls", os.Getenv("DRAND_URLS"), "comma separated drand relay URLs")
+	rounds := fs.Uint64("rounds", 20, "number of latest drand rounds to record")
+	blocks := fs.Uint64("blocks", 500, "number of latest blocks to record")
+	out := fs.String("out", "-", "output file, - for stdout")
+	timeout := fs.Duration("timeout", 5*time.Minute, "timeout of the whole recording")
+	_ = fs.Parse(args)
+
+	if *rpcURL == "" {
+		log.Fatal().Msg("--rpc or RPC is required")
+	}
+	if !common.IsHexAddress(*oracleAddress) {
+		log.Fatal().Str("oracle_address", *oracleAddress).Msg("--oracle-address or DRAND_ORACLE_ADDRESS must be a valid address")
+	}
+	if *drandURLs == "" {
+		log.Fatal().Msg("--drand-urls or DRAND_URLS is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	rpcClient, err := ethclient.Dial(*rpcURL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating rpc client")
+	}
+	contractAddress := common.HexToAddress(*oracleAddress)
+	oracle, err := binding.NewBinding(contractAddress, rpcClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating binding")
+	}
+	chainHash, err := oracle.CHAINHASH(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to get chain hash from Drand Oracle contract")
+	}
+	drandClient, err := newDrandClient(strings.Split(*drandURLs, ","), chainHash[:])
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating drand client")
+	}
+
+	fixture, err := fixtures.NewRecorder(drandClient, rpcClient, oracle, contractAddress).Record(ctx, fixtures.Options{
+		Rounds: *rounds,
+		Blocks: *blocks,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to record fixtures")
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal().Err(err).Msg("error creating output file")
+		}
+		defer f.Close()
+		w = f
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(fixture); err != nil {
+		log.Fatal().Err(err).Msg("Failed to write fixtures")
+	}
+	log.Info().
+		Int("beacons", len(fixture.Drand.Beacons)).
+		Int("transactions", len(fixture.Chain.Transactions)).
+		Int("blocks", len(fixture.Chain.Blocks)).
+		Msg("Fixtures recorded")
+}
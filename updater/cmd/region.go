@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/probe"
+	"drand-oracle-updater/internal/region"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// newOwnership returns the round ownership of the configured region, nil
+// without REGION. The region reports itself ready to its peers when every
+// pipeline is ready, once health is set.
+func newOwnership(cfg config.Config, health **probe.Health) (*region.Ownership, error) {
+	if cfg.Region == "" {
+		return nil, nil
+	}
+	peers, err := region.ParsePeers(cfg.RegionPeers)
+	if err != nil {
+		return nil, err
+	}
+	ownership, err := region.New(region.Options{
+		Region:         cfg.Region,
+		Peers:          peers,
+		Token:          cfg.RegionToken,
+		Grace:          cfg.RegionGrace,
+		GossipInterval: cfg.RegionGossipInterval,
+	}, func(ctx context.Context) region.Status {
+		return region.Status{Ready: *health != nil && (*health).Ready(ctx)}
+	}, cfg.DeploymentLabels)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().
+		Str("region", cfg.Region).
+		Int("peers", len(peers)).
+		Dur("grace", cfg.RegionGrace).
+		Msg("Active regions, submitting owned rounds and covering the others after the grace period")
+	return ownership, nil
+}
+
+// withRegionStatus serves the region status to the peers alongside the API
+func withRegionStatus(handler http.Handler, ownership *region.Ownership) http.Handler {
+	if ownership == nil {
+		return handler
+	}
+	mux := http.NewServeMux()
+	mux.Handle(region.StatusPath, ownership.Handler())
+	mux.Handle("/", handler)
+	return mux
+}
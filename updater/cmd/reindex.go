@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/indexer"
+	"drand-oracle-updater/internal/store"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// runReindex wipes and rebuilds the local rounds index from the contract's events
+func runReindex(args []string) {
+	defaultStateDir := os.Getenv("STATE_DIR")
+	if defaultStateDir == "" {
+		defaultStateDir = "data"
+	}
+
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	stateDir := fs.String("state-dir", defaultStateDir, "state directory of the updater")
+	rpcURL := fs.String("rpc", os.Getenv("RPC"), "RPC URL")
+	oracleAddress := fs.String("oracle-address", os.Getenv("DRAND_ORACLE_ADDRESS"), "Drand Oracle contract address")
+	fromBlock := fs.Uint64("from-block", 0, "first block to index, 0 looks up the contract deployment block")
+	toBlock := fs.Uint64("to-block", 0, "last block to index, 0 indexes up to the latest block")
+	chunkSize := fs.Uint64("chunk-size", 2000, "number of blocks per log query")
+	rate := fs.Float64("rate", 10, "maximum RPC requests per second, 0 disables the limit")
+	resume := fs.Bool("resume", false, "resume an interrupted reindex instead of starting over")
+	_ = fs.Parse(args)
+
+	if *rpcURL == "" {
+		log.Fatal().Msg("--rpc or RPC is required")
+	}
+	if !common.IsHexAddress(*oracleAddress) {
+		log.Fatal().Str("oracle_address", *oracleAddress).Msg("--oracle-address or DRAND_ORACLE_ADDRESS must be a valid address")
+	}
+
+	rpcClient, err := ethclient.Dial(*rpcURL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating rpc client")
+	}
+	contractAddress := common.HexToAddress(*oracleAddress)
+	binding, err := binding.NewBinding(contractAddress, rpcClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating binding")
+	}
+	stateStore, err := store.Open(*stateDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error opening state store")
+	}
+
+	// Stop between chunks on interrupt, so that the reindex can be resumed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	progress, err := indexer.NewIndexer(rpcClient, binding, contractAddress, stateStore).Reindex(ctx, indexer.Options{
+		FromBlock:         *fromBlock,
+		ToBlock:           *toBlock,
+		ChunkSize:         *chunkSize,
+		RequestsPerSecond: *rate,
+		Resume:            *resume,
+	})
+	if err != nil {
+		event := log.Fatal().Err(err)
+		if progress != nil {
+			event = event.Uint64("next_block", progress.NextBlock)
+		}
+		event.Msg("Reindex stopped, run again with --resume to continue")
+	}
+	log.Info().
+		Uint64("from_block", progress.FromBlock).
+		Uint64("to_block", progress.ToBlock).
+		Uint64("rounds", progress.Rounds).
+		Uint64("last_round", progress.LastRound).
+		Dur("duration", progress.FinishedAt.Sub(progress.StartedAt)).
+		Msg("Reindex complete")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/chains"
+	"drand-oracle-updater/internal/chains/solana"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/drand/drand/client"
+	drandHTTPClient "github.com/drand/drand/client/http"
+	drandLog "github.com/drand/drand/log"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// runRelay sets the rounds of a drand network on a non-EVM chain, configured
+// from the environment
+func runRelay(args []string) {
+	fs := flag.NewFlagSet("relay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: updater relay")
+		fmt.Fprintln(fs.Output(), "Sets drand rounds on the non-EVM chain selected by RELAY_CHAIN, e.g. solana.")
+	}
+	_ = fs.Parse(args)
+
+	var cfg config.RelayConfig
+	if err := envconfig.Process("", &cfg); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load relay configuration")
+	}
+	submitter, err := newChainSubmitter(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Str("chain", cfg.RelayChain).Msg("error creating chain submitter")
+	}
+
+	chainHash, err := hex.DecodeString(cfg.ChainHash)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error decoding chain hash")
+	}
+	drandClient, err := client.New(
+		client.From(drandHTTPClient.ForURLs(cfg.DrandURLs, chainHash)...),
+		client.WithChainHash(chainHash),
+		client.WithLogger(drandLog.NewLogger(os.Stdout, drandLog.LogError)), // Only log errors
+	)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating drand client")
+	}
+	relay := chains.NewRelay(drandClient, submitter, chains.RelayOptions{
+		GenesisRound: cfg.GenesisRound,
+		MaxRetries:   cfg.MaxRetries,
+	}, cfg.DeploymentLabels)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.MetricsPort),
+		Handler: promhttp.Handler(),
+	}
+	errGroup, ctx := errgroup.WithContext(ctx)
+	errGroup.Go(func() error {
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+	errGroup.Go(func() error {
+		err := relay.Run(ctx)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		_ = metricsServer.Shutdown(shutdownCtx)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	})
+	if err := errGroup.Wait(); err != nil {
+		log.Fatal().Err(err).Msg("Relay stopped")
+	}
+	log.Info().Msg("Relay stopped")
+}
+
+// newChainSubmitter returns the submitter of the configured chain family
+func newChainSubmitter(cfg config.RelayConfig) (chains.ChainSubmitter, error) {
+	switch strings.ToLower(cfg.RelayChain) {
+	case chains.FamilySolana:
+		submitter, err := solana.NewSubmitter(solana.Options{
+			RPC:            cfg.Solana.RPC,
+			ProgramID:      cfg.Solana.ProgramID,
+			OracleAccount:  cfg.Solana.OracleAccount,
+			Commitment:     cfg.Solana.Commitment,
+			ConfirmTimeout: cfg.Solana.ConfirmTimeout,
+		}, cfg.Solana.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().
+			Str("program_id", cfg.Solana.ProgramID).
+			Str("oracle_account", cfg.Solana.OracleAccount).
+			Str("authority", submitter.Authority()).
+			Msg("Relaying to Solana")
+		return submitter, nil
+	case chains.FamilyEVM:
+		return nil, errors.New("EVM chains are served by the updater itself, run it without the relay command")
+	default:
+		return nil, fmt.Errorf("unsupported chain %q, expected %s", cfg.RelayChain, chains.FamilySolana)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/release"
+	"drand-oracle-updater/internal/service"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// newReleases returns the publisher of the daily releases, nil when
+// RELEASE_IPFS_API_URL is not set
+func newReleases(cfg config.Config) (*service.Releases, error) {
+	if cfg.ReleaseIPFSAPIURL == "" {
+		return nil, nil
+	}
+	if cfg.ReleasePrivateKey == "" {
+		return nil, fmt.Errorf("RELEASE_PRIVATE_KEY is required to publish releases")
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.ReleasePrivateKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing release private key: %w", err)
+	}
+	if cfg.ReleaseAnchor && cfg.DryRun {
+		log.Warn().Msg("Dry run mode: releases are published but not anchored on-chain")
+	}
+	log.Info().
+		Str("publisher", crypto.PubkeyToAddress(key.PublicKey).Hex()).
+		Bool("anchor", cfg.ReleaseAnchor).
+		Msg("Publishing daily releases to IPFS")
+	return &service.Releases{
+		Key:    key,
+		Pinner: release.NewIPFS(cfg.ReleaseIPFSAPIURL, cfg.ReleaseIPFSToken),
+		Anchor: cfg.ReleaseAnchor,
+	}, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/gas"
+	"drand-oracle-updater/internal/retry"
+	"drand-oracle-updater/internal/service"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// reloadDebounce groups the file events of a single save, as editors often
+// write, rename and chmod in a row
+const reloadDebounce = 500 * time.Millisecond
+
+// watchConfig reloads the configuration file on SIGHUP and whenever it changes,
+// and applies the reloadable settings until ctx is done
+func watchConfig(ctx context.Context, loader *config.Loader, current config.Config, updaters []*service.Updater, gasStrategy *gas.Bounded) error {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// The directory is watched, as saving often replaces the file
+	path := filepath.Clean(loader.Path())
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+	log.Info().Str("path", path).Msg("Watching configuration file, reloading on change or SIGHUP")
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hangup:
+			log.Info().Msg("SIGHUP received, reloading configuration")
+			current = reloadConfig(loader, current, updaters, gasStrategy)
+		case event := <-watcher.Events:
+			if filepath.Clean(event.Name) == path && !event.Has(fsnotify.Chmod) {
+				debounce = time.After(reloadDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			log.Info().Str("path", path).Msg("Configuration file changed, reloading")
+			current = reloadConfig(loader, current, updaters, gasStrategy)
+		case err := <-watcher.Errors:
+			log.Warn().Err(err).Msg("Configuration file watcher error")
+		}
+	}
+}
+
+// reloadConfig loads the configuration again and applies its reloadable
+// settings. It returns the configuration in effect: the current one with the
+// reloadable settings of the new one, or the current one when the new one is
+// invalid. Settings needing a restart keep their running values, so they are
+// reported on every reload until the process restarts.
+func reloadConfig(loader *config.Loader, current config.Config, updaters []*service.Updater, gasStrategy *gas.Bounded) config.Config {
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to reload configuration, keeping the current one")
+		return current
+	}
+	applied := config.MergeKeys(current, cfg, config.ReloadableKeys)
+	if err := applySettings(applied, updaters, gasStrategy); err != nil {
+		log.Error().Err(err).Msg("Invalid reloaded settings, keeping the current ones")
+		return current
+	}
+
+	var restartKeys []string
+	for _, key := range config.ChangedKeys(current, cfg) {
+		if !slices.Contains(config.ReloadableKeys, key) {
+			restartKeys = append(restartKeys, key)
+		}
+	}
+	if len(restartKeys) > 0 {
+		log.Warn().Strs("keys", restartKeys).Msg("Changed settings need a restart to apply")
+	}
+	return applied
+}
+
+// applySettings applies the reloadable settings of a configuration to the
+// running updaters and gas strategy
+func applySettings(cfg config.Config, updaters []*service.Updater, gasStrategy *gas.Bounded) error {
+	minSenderBalance, ok := new(big.Int).SetString(cfg.MinSenderBalanceWei, 10)
+	if !ok || minSenderBalance.Sign() < 0 {
+		return fmt.Errorf("invalid minimum sender balance %q", cfg.MinSenderBalanceWei)
+	}
+	maxGasPrice, ok := new(big.Int).SetString(cfg.MaxGasPriceWei, 10)
+	if !ok || maxGasPrice.Sign() < 0 {
+		return fmt.Errorf("invalid maximum gas price %q", cfg.MaxGasPriceWei)
+	}
+	if cfg.MaxRetries <= 0 {
+		return fmt.Errorf("maximum retries must be positive, got %d", cfg.MaxRetries)
+	}
+	if err := gasStrategy.SetBounds(cfg.GasPriceMultiplier, maxGasPrice); err != nil {
+		return err
+	}
+
+	for _, updater := range updaters {
+		updater.ApplySettings(service.Settings{
+			MaxRetries:          cfg.MaxRetries,
+			AlertAfterRetries:   cfg.AlertAfterRetries,
+			MinSenderBalance:    minSenderBalance,
+			MaxRoundLag:         cfg.MaxRoundLag,
+			AlertRepeatInterval: cfg.AlertRepeatInterval,
+			Retry:               retryPolicy(cfg),
+		})
+	}
+	return nil
+}
+
+// retryPolicy returns the retry policy of drand fetches, RPC reads and
+// broadcasts
+func retryPolicy(cfg config.Config) retry.Policy {
+	return retry.Policy{
+		MaxAttempts:      cfg.RetryMaxAttempts,
+		InitialBackoff:   cfg.RetryInitialBackoff,
+		MaxBackoff:       cfg.RetryMaxBackoff,
+		Jitter:           retry.DefaultPolicy.Jitter,
+		BreakerThreshold: cfg.RetryBreakerThreshold,
+		BreakerCooldown:  cfg.RetryBreakerCooldown,
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/service"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const replHelp = `Commands:
+  fetch [round]     Fetch a round from the drand network, the latest without a round
+  verify            Verify the round against the drand chain public key
+  payload           Sign the round for the oracle and print the setRandomness payload
+  simulate          Simulate the setRandomness transaction against the chain state
+  send              Submit the round after confirmation
+  show              Print everything known about the round
+  pipeline [name]   Print or switch the pipeline
+  help              Print this help
+  quit              Leave the REPL`
+
+// repl is the state of an interactive session
+type repl struct {
+	pipelines []config.Pipeline
+	updaters  []*service.Updater
+	pipeline  int
+	timeout   time.Duration
+
+	in    *bufio.Scanner
+	out   io.Writer
+	round *service.Inspection
+}
+
+// runRepl steps rounds through the submission pipeline interactively, for
+// debugging new contract versions
+func runRepl(args []string) {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	pipeline := fs.String("pipeline", "default", "pipeline to start with")
+	timeout := fs.Duration("timeout", time.Minute, "timeout of each command")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: updater repl [flags]")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), "\n"+replHelp)
+	}
+	_ = fs.Parse(args)
+
+	cfg, _ := loadConfig()
+	pipelines, updaters, _ := newUpdaters(cfg, nil, nil, nil, nil)
+	r := &repl{
+		pipelines: pipelines,
+		updaters:  updaters,
+		pipeline:  -1,
+		timeout:   *timeout,
+		in:        bufio.NewScanner(os.Stdin),
+		out:       os.Stdout,
+	}
+	if err := r.switchPipeline(*pipeline); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start the REPL")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	r.loop(ctx)
+}
+
+// loop reads and runs commands until quit, end of input or cancellation
+func (r *repl) loop(ctx context.Context) {
+	for {
+		fmt.Fprintf(r.out, "%s> ", r.pipelines[r.pipeline].Name)
+		if !r.in.Scan() || ctx.Err() != nil {
+			fmt.Fprintln(r.out)
+			return
+		}
+		fields := strings.Fields(r.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return
+		}
+		cmdCtx, cancel := context.WithTimeout(ctx, r.timeout)
+		err := r.run(cmdCtx, fields[0], fields[1:])
+		cancel()
+		if err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+	}
+}
+
+// run runs one command
+func (r *repl) run(ctx context.Context, command string, args []string) error {
+	updater := r.updaters[r.pipeline]
+	switch command {
+	case "help":
+		fmt.Fprintln(r.out, replHelp)
+		return nil
+	case "pipeline":
+		if len(args) == 0 {
+			fmt.Fprintln(r.out, r.pipelines[r.pipeline].Name)
+			return nil
+		}
+		return r.switchPipeline(args[0])
+	case "fetch":
+		var round uint64
+		if len(args) > 0 {
+			var err error
+			if round, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+				return fmt.Errorf("invalid round %q", args[0])
+			}
+		}
+		inspection, err := updater.InspectRound(ctx, round)
+		if err != nil {
+			return err
+		}
+		r.round = inspection
+		fmt.Fprintf(r.out, "round %d at %s\n  randomness %s\n  signature  %s\n",
+			inspection.Round, time.Unix(int64(inspection.Timestamp), 0).UTC().Format(time.RFC3339), inspection.Randomness, inspection.Signature)
+		return nil
+	}
+
+	if r.round == nil {
+		return errors.New("no round, fetch one first")
+	}
+	switch command {
+	case "show":
+		return r.show()
+	case "verify":
+		if err := updater.VerifyInspection(ctx, r.round); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "round %d is valid\n", r.round.Round)
+		return nil
+	case "payload":
+		if err := updater.PrepareInspection(ctx, r.round); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "eip712 signature %s\ngas price        %s wei\ncalldata         %s\n",
+			r.round.EIP712Signature, r.round.GasPrice, r.round.Calldata)
+		return nil
+	case "simulate":
+		if err := updater.SimulateInspection(ctx, r.round); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "oracle round %d\n", r.round.OracleRound)
+		if r.round.SimulationError != "" {
+			fmt.Fprintf(r.out, "reverted: %s\n", r.round.SimulationError)
+		} else {
+			fmt.Fprintf(r.out, "ok, gas %d, estimated cost %s wei\n", r.round.GasEstimate, r.round.EstimatedCost)
+		}
+		return nil
+	case "send":
+		fmt.Fprintf(r.out, "Submit round %d to pipeline %s with the updater's sender? [y/N] ", r.round.Round, r.pipelines[r.pipeline].Name)
+		if !r.in.Scan() || strings.ToLower(strings.TrimSpace(r.in.Text())) != "y" {
+			fmt.Fprintln(r.out, "not sent")
+			return nil
+		}
+		if err := updater.SendInspection(ctx, r.round); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "round %d submitted\n", r.round.Round)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, try help", command)
+	}
+}
+
+// show prints the round as indented JSON
+func (r *repl) show() error {
+	out, err := json.MarshalIndent(r.round, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, string(out))
+	return nil
+}
+
+// switchPipeline selects a pipeline by name, dropping the current round
+func (r *repl) switchPipeline(name string) error {
+	for i := range r.pipelines {
+		if r.pipelines[i].Name == name {
+			if i != r.pipeline {
+				r.pipeline = i
+				r.round = nil
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown pipeline %q", name)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/service"
+	"drand-oracle-updater/sender"
+	"drand-oracle-updater/signer"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// newKeyRotation loads the keys staged for a rotation, which is triggered
+// through the admin API
+func newKeyRotation(cfg config.Config) (service.KeyRotation, error) {
+	scheme, err := signer.ParseScheme(cfg.SignatureScheme)
+	if err != nil {
+		return service.KeyRotation{}, err
+	}
+	rotation := service.KeyRotation{
+		Scheme:       scheme,
+		DrainTimeout: cfg.KeyRotationDrainTimeout,
+	}
+	if cfg.SenderNextPrivateKey != "" {
+		privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.SenderNextPrivateKey, "0x"))
+		if err != nil {
+			return service.KeyRotation{}, fmt.Errorf("error parsing next sender private key: %w", err)
+		}
+		rotation.NextSenderKey = sender.NewLocalKey(privateKey)
+		log.Info().Str("address", rotation.NextSenderKey.Address().Hex()).Msg("Next sender key staged for rotation")
+	}
+	if cfg.SignerNextPrivateKey != "" {
+		rotation.NextSignerKey, err = crypto.HexToECDSA(strings.TrimPrefix(cfg.SignerNextPrivateKey, "0x"))
+		if err != nil {
+			return service.KeyRotation{}, fmt.Errorf("error parsing next signer private key: %w", err)
+		}
+		log.Info().Str("address", crypto.PubkeyToAddress(rotation.NextSignerKey.PublicKey).Hex()).Msg("Next signer key staged for rotation")
+	}
+	return rotation, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// consumerTemplates are the files of a generated consumer, named after the
+// generated file with a .tmpl suffix
+//
+//go:embed templates/consumer/*.tmpl
+var consumerTemplates embed.FS
+
+// consumerABIEntries are the parts of the oracle interface used by consumers
+var consumerABIEntries = []string{"latestRound", "getRandomnessFromRound", "getRandomnessFromTimestamp", "RandomnessUpdated"}
+
+// defaultGoEthereumVersion is required by the generated Go consumer when the
+// updater's own version cannot be read from its build info
+const defaultGoEthereumVersion = "v1.14.11"
+
+// consumerScaffold is the data of the consumer templates
+type consumerScaffold struct {
+	Module            string
+	ChainID           int64
+	OracleAddress     string
+	ChainHash         string
+	ABI               string
+	GoEthereumVersion string
+}
+
+// runScaffoldConsumer generates a Go consumer and a Solidity example contract
+// wired to the oracle of a chain
+func runScaffoldConsumer(args []string) {
+	fs := flag.NewFlagSet("scaffold-consumer", flag.ExitOnError)
+	chainID := fs.Int64("chain-id", envInt64("CHAIN_ID"), "chain ID of the oracle")
+	oracleAddress := fs.String("oracle-address", os.Getenv("DRAND_ORACLE_ADDRESS"), "Drand Oracle contract address")
+	rpcURL := fs.String("rpc", "", "optional RPC URL, to check the chain ID and read the oracle's chain hash; not written to the generated files")
+	module := fs.String("module", "example.com/drand-consumer", "Go module path of the generated consumer")
+	out := fs.String("out", "drand-consumer", "output directory")
+	force := fs.Bool("force", false, "overwrite existing files")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of the RPC checks")
+	_ = fs.Parse(args)
+
+	if *chainID <= 0 {
+		log.Fatal().Msg("--chain-id or CHAIN_ID is required")
+	}
+	if !common.IsHexAddress(*oracleAddress) {
+		log.Fatal().Str("oracle_address", *oracleAddress).Msg("--oracle-address or DRAND_ORACLE_ADDRESS must be a valid address")
+	}
+	oracleABI, err := consumerABI()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error extracting the oracle interface")
+	}
+	scaffold := consumerScaffold{
+		Module:            *module,
+		ChainID:           *chainID,
+		OracleAddress:     common.HexToAddress(*oracleAddress).Hex(),
+		ABI:               oracleABI,
+		GoEthereumVersion: goEthereumVersion(),
+	}
+
+	if *rpcURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		scaffold.ChainHash, err = checkConsumerChain(ctx, *rpcURL, *chainID, common.HexToAddress(*oracleAddress))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to check the oracle")
+		}
+	}
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatal().Err(err).Msg("error creating output directory")
+	}
+	templates, err := consumerTemplates.ReadDir("templates/consumer")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error listing templates")
+	}
+	for _, entry := range templates {
+		path := filepath.Join(*out, strings.TrimSuffix(entry.Name(), ".tmpl"))
+		if err := writeTemplate("templates/consumer/"+entry.Name(), path, scaffold, *force); err != nil {
+			log.Fatal().Err(err).Str("path", path).Msg("error generating consumer")
+		}
+		log.Info().Str("path", path).Msg("Generated")
+	}
+}
+
+// consumerABI returns the JSON ABI of the oracle entries used by consumers
+func consumerABI() (string, error) {
+	var entries []map[string]any
+	if err := json.Unmarshal([]byte(binding.BindingMetaData.ABI), &entries); err != nil {
+		return "", err
+	}
+	var kept []map[string]any
+	for _, entry := range entries {
+		if name, _ := entry["name"].(string); slices.Contains(consumerABIEntries, name) {
+			kept = append(kept, entry)
+		}
+	}
+	data, err := json.Marshal(kept)
+	return string(data), err
+}
+
+// checkConsumerChain checks that the RPC serves the chain and returns the
+// drand chain hash of the oracle
+func checkConsumerChain(ctx context.Context, rpcURL string, chainID int64, oracleAddress common.Address) (string, error) {
+	rpcClient, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return "", err
+	}
+	defer rpcClient.Close()
+	id, err := rpcClient.ChainID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if id.Int64() != chainID {
+		return "", fmt.Errorf("the RPC serves chain %s, not chain %d", id, chainID)
+	}
+	oracle, err := binding.NewBinding(oracleAddress, rpcClient)
+	if err != nil {
+		return "", err
+	}
+	chainHash, err := oracle.CHAINHASH(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(chainHash[:]), nil
+}
+
+func writeTemplate(name string, path string, data consumerScaffold, force bool) error {
+	tmpl, err := template.ParseFS(consumerTemplates, name)
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return errors.New("file exists, use --force to overwrite")
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return tmpl.Execute(f, data)
+}
+
+// goEthereumVersion returns the go-ethereum version the updater is built with
+func goEthereumVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return defaultGoEthereumVersion
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/ethereum/go-ethereum" {
+			return dep.Version
+		}
+	}
+	return defaultGoEthereumVersion
+}
+
+// envInt64 returns an integer environment variable, 0 when unset or invalid
+func envInt64(name string) int64 {
+	value, _ := strconv.ParseInt(os.Getenv(name), 10, 64)
+	return value
+}
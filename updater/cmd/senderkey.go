@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/sender"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Sender key backends
+const (
+	senderKeyLocal   = "local"
+	senderKeyLedger  = "ledger"
+	senderKeyDerived = "derived"
+)
+
+// testnetChains are the chains derived sender keys are allowed on, along with
+// SENDER_KEY_TESTNET_CHAINS
+var testnetChains = map[int64]bool{
+	11155111: true, // Sepolia
+	17000:    true, // Holesky
+	560048:   true, // Hoodi
+	84532:    true, // Base Sepolia
+	11155420: true, // OP Sepolia
+	421614:   true, // Arbitrum Sepolia
+	80002:    true, // Polygon Amoy
+	10200:    true, // Gnosis Chiado
+	59141:    true, // Linea Sepolia
+	534351:   true, // Scroll Sepolia
+	43113:    true, // Avalanche Fuji
+	97:       true, // BNB Smart Chain testnet
+	31337:    true, // Anvil and Hardhat
+	1337:     true, // Geth dev mode and Ganache
+}
+
+// senderKeyDomain separates the sender keys derived from SENDER_KEY_SEED from
+// any other use of the seed
+const senderKeyDomain = "drand-oracle-updater/sender"
+
+// newSenderKey returns the key signing the sender's transactions, and the
+// private key itself when it is held in memory
+func newSenderKey(ctx context.Context, cfg config.Config) (sender.KeyProvider, *ecdsa.PrivateKey, error) {
+	switch cfg.SenderKeyBackend {
+	case senderKeyLocal:
+		if cfg.SenderPrivateKey == "" {
+			return nil, nil, errors.New("SENDER_PRIVATE_KEY is required with SENDER_KEY_BACKEND=local")
+		}
+		privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.SenderPrivateKey, "0x"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing private key: %w", err)
+		}
+		return sender.NewLocalKey(privateKey), privateKey, nil
+	case senderKeyLedger:
+		if cfg.SenderPrivateKey != "" {
+			return nil, nil, errors.New("SENDER_PRIVATE_KEY must not be set with SENDER_KEY_BACKEND=ledger")
+		}
+		if cfg.SenderMode != senderModeEOA {
+			return nil, nil, fmt.Errorf("SENDER_MODE=%s requires a local sender key", cfg.SenderMode)
+		}
+		ctx, cancel := context.WithTimeout(ctx, cfg.LedgerConnectTimeout)
+		defer cancel()
+		ledger, err := sender.NewLedger(ctx, cfg.LedgerDerivationPath, cfg.LedgerConfirmTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ledger, nil, nil
+	case senderKeyDerived:
+		if cfg.SenderPrivateKey != "" {
+			return nil, nil, errors.New("SENDER_PRIVATE_KEY must not be set with SENDER_KEY_BACKEND=derived")
+		}
+		if cfg.SenderKeySeed == "" || cfg.SenderKeySecret == "" {
+			return nil, nil, errors.New("SENDER_KEY_SEED and SENDER_KEY_SECRET are required with SENDER_KEY_BACKEND=derived")
+		}
+		if !testnetChains[cfg.ChainID] && !slices.Contains(cfg.SenderKeyTestnets, cfg.ChainID) {
+			return nil, nil, fmt.Errorf("SENDER_KEY_BACKEND=derived is for testnets only, chain %d is not a known testnet nor listed in SENDER_KEY_TESTNET_CHAINS", cfg.ChainID)
+		}
+		privateKey, err := deriveSenderKey(cfg.SenderKeySecret, cfg.SenderKeySeed, cfg.ChainID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sender.NewLocalKey(privateKey), privateKey, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid sender key backend %q, expected %s, %s or %s", cfg.SenderKeyBackend, senderKeyLocal, senderKeyLedger, senderKeyDerived)
+	}
+}
+
+// deriveSenderKey derives the sender key of a chain from a seed, such as the
+// name of a review app, keyed with a secret shared by the deployments, so that
+// an ephemeral deployment gets a fresh sender without a key being generated
+// and stored for it, and keeps it across restarts. Without the secret, the
+// seed alone does not reveal the key.
+func deriveSenderKey(secret, seed string, chainID int64) (*ecdsa.PrivateKey, error) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s/%d/%s", senderKeyDomain, chainID, seed)
+	privateKey, err := crypto.ToECDSA(mac.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("error deriving sender key: %w", err)
+	}
+	return privateKey, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/signer"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// newSetRandomnessSigner builds the SetRandomness signer from the configuration. A
+// single local key is used as is, anything more is wrapped in a threshold signer.
+func newSetRandomnessSigner(cfg config.Config, contractAddress common.Address) (signer.SetRandomnessSigner, error) {
+	scheme, err := signer.ParseScheme(cfg.SignatureScheme)
+	if err != nil {
+		return nil, err
+	}
+	var signers []signer.SetRandomnessSigner
+	for _, key := range append([]string{cfg.SignerPrivateKey}, cfg.ExtraSignerKeys...) {
+		privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(key, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing signer private key: %w", err)
+		}
+		signers = append(signers, signer.NewSigner(cfg.ChainID, contractAddress, privateKey, scheme))
+	}
+
+	if len(cfg.RemoteSignerAddresses) != len(cfg.RemoteSignerURLs) {
+		return nil, errors.New("REMOTE_SIGNER_ADDRESSES and REMOTE_SIGNER_URLS must have the same length")
+	}
+	for i, address := range cfg.RemoteSignerAddresses {
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid remote signer address %q", address)
+		}
+		signers = append(signers, signer.NewRemoteSigner(cfg.ChainID, contractAddress, common.HexToAddress(address), cfg.RemoteSignerURLs[i], scheme))
+	}
+
+	if len(signers) == 1 && cfg.SignerThreshold == 1 {
+		return signers[0], nil
+	}
+
+	thresholdSigner, err := signer.NewThresholdSigner(cfg.SignerThreshold, signers...)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range thresholdSigner.Addresses() {
+		log.Info().Str("address", address.Hex()).Msg("Threshold signer member")
+	}
+	log.Info().
+		Int("threshold", thresholdSigner.Threshold()).
+		Int("signers", len(signers)).
+		Msg("Threshold signer initialized")
+	return thresholdSigner, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/internal/accounting"
+	"drand-oracle-updater/internal/gassim"
+	"drand-oracle-updater/internal/store"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// scenarioFlags collects the repeated --scenario flags
+type scenarioFlags []gassim.Scenario
+
+func (s *scenarioFlags) String() string {
+	names := make([]string, len(*s))
+	for i, scenario := range *s {
+		names[i] = scenario.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func (s *scenarioFlags) Set(value string) error {
+	scenario, err := gassim.ParseScenario(value)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, scenario)
+	return nil
+}
+
+// runSimulateGas replays the recorded transaction history under alternative gas
+// strategies and reports their hypothetical cost and latency. It only reads
+// from the state store and the RPC.
+func runSimulateGas(args []string) {
+	defaultStateDir := os.Getenv("STATE_DIR")
+	if defaultStateDir == "" {
+		defaultStateDir = "data"
+	}
+
+	var scenarios scenarioFlags
+	fs := flag.NewFlagSet("simulate-gas", flag.ExitOnError)
+	stateDir := fs.String("state-dir", defaultStateDir, "state directory of the updater")
+	rpcURL := fs.String("rpc", os.Getenv("RPC"), "RPC URL, must serve eth_feeHistory for the replayed blocks")
+	from := fs.String("from", "", "start date, inclusive (YYYY-MM-DD or RFC3339)")
+	to := fs.String("to", "", "end date, exclusive (YYYY-MM-DD or RFC3339)")
+	fs.Var(&scenarios, "scenario", "scenario to replay, repeatable: name:percentile=25,blocks=20,multiplier=1.1,max=<wei>,bump-after=3,bump=12.5,max-bumps=3")
+	horizon := fs.Uint64("horizon", 50, "blocks a transaction is followed for before it counts as stuck")
+	inclusionPercentile := fs.Float64("inclusion-percentile", 10, "priority fee percentile of a block a transaction must pay to be included")
+	rate := fs.Float64("rate", 10, "maximum RPC requests per second, 0 disables the limit")
+	format := fs.String("format", "table", "output format: table or json")
+	_ = fs.Parse(args)
+
+	if *rpcURL == "" {
+		log.Fatal().Msg("--rpc or RPC is required")
+	}
+	if *format != "table" && *format != "json" {
+		log.Fatal().Str("format", *format).Msg("--format must be table or json")
+	}
+	if len(scenarios) == 0 {
+		scenarios = gassim.DefaultScenarios
+	}
+	fromTime, err := accounting.ParseDate(*from)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --from date")
+	}
+	toTime, err := accounting.ParseDate(*to)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --to date")
+	}
+
+	stateStore, err := store.Open(*stateDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error opening state store")
+	}
+	txs, err := stateStore.Transactions(fromTime, toTime)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error reading transactions")
+	}
+	rpcClient, err := ethclient.Dial(*rpcURL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating rpc client")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := gassim.Simulate(ctx, rpcClient, txs, scenarios, gassim.Options{
+		Horizon:             *horizon,
+		InclusionPercentile: *inclusionPercentile,
+		RequestsPerSecond:   *rate,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("error simulating gas strategies")
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal().Err(err).Msg("error writing report")
+		}
+		return
+	}
+	printSimulationReport(report)
+}
+
+func printSimulationReport(report *gassim.Report) {
+	fmt.Printf("Replayed %d transactions in blocks %d-%d, actual cost %s wei",
+		report.Transactions, report.FromBlock, report.ToBlock, report.ActualCost)
+	if report.BlockTime > 0 {
+		fmt.Printf(", block time %s", report.BlockTime.Round(10*time.Millisecond))
+	}
+	fmt.Print("\n\n")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCENARIO\tINCLUDED\tSTUCK\tCOST (WEI)\tACTUAL (WEI)\tCHANGE\tMEAN\tP50\tP95\tMAX\tBUMPS")
+	for _, r := range report.Results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%+.1f%%\t%s\t%s\t%s\t%s\t%d\n",
+			r.Scenario.Name,
+			r.Included,
+			r.Stuck,
+			r.Cost,
+			r.ActualCost,
+			r.CostChange,
+			latency(r.MeanLatency, report.BlockTime),
+			latency(float64(r.P50Latency), report.BlockTime),
+			latency(float64(r.P95Latency), report.BlockTime),
+			latency(float64(r.MaxLatency), report.BlockTime),
+			r.Bumps,
+		)
+	}
+	_ = w.Flush()
+}
+
+// latency formats a latency in blocks, with its duration when the block time is known
+func latency(blocks float64, blockTime time.Duration) string {
+	if blockTime == 0 {
+		return fmt.Sprintf("%.1fb", blocks)
+	}
+	d := time.Duration(blocks * float64(blockTime))
+	return fmt.Sprintf("%.1fb/%s", blocks, d.Round(time.Second))
+}
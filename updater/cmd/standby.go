@@ -0,0 +1,52 @@
+package main
+
+import (
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/service"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// newSelfTest returns the self-test of the default pipeline against the test
+// oracle, nil without SELF_TEST_ORACLE_ADDRESS
+func newSelfTest(cfg config.Config, rpcClient *ethclient.Client) (*service.SelfTest, error) {
+	if cfg.SelfTestOracleAddress == "" {
+		return nil, nil
+	}
+	if !common.IsHexAddress(cfg.SelfTestOracleAddress) {
+		return nil, fmt.Errorf("invalid self-test oracle address %q", cfg.SelfTestOracleAddress)
+	}
+	if cfg.SelfTestInterval <= 0 {
+		return nil, errors.New("SELF_TEST_INTERVAL must be positive")
+	}
+	if cfg.SenderMode != senderModeEOA {
+		return nil, fmt.Errorf("the self-test sends transactions from the sender, it does not support SENDER_MODE=%s", cfg.SenderMode)
+	}
+	address := common.HexToAddress(cfg.SelfTestOracleAddress)
+	if address == common.HexToAddress(cfg.DrandOracleAddress) {
+		return nil, errors.New("the self-test oracle must not be the production oracle")
+	}
+	testBinding, err := binding.NewBinding(address, rpcClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating self-test oracle binding: %w", err)
+	}
+	signer, err := newSetRandomnessSigner(cfg, address)
+	if err != nil {
+		return nil, fmt.Errorf("error creating self-test signer: %w", err)
+	}
+	log.Info().
+		Str("oracle", address.Hex()).
+		Dur("interval", cfg.SelfTestInterval).
+		Msg("Self-test initialized")
+	return &service.SelfTest{
+		Oracle:   address,
+		Binding:  testBinding,
+		Signer:   signer,
+		Interval: cfg.SelfTestInterval,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/drand/drand/client"
+	drandHTTPClient "github.com/drand/drand/client/http"
+	drandLog "github.com/drand/drand/log"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// roundStatus compares the latest drand round with the oracle's latest round
+type roundStatus struct {
+	ChainHash     string `json:"chain_hash"`
+	OracleAddress string `json:"oracle_address"`
+	DrandRound    uint64 `json:"drand_round"`
+	OracleRound   uint64 `json:"oracle_round"`
+	Lag           uint64 `json:"lag"`
+}
+
+// newDrandClient returns a drand client of the relays for a chain, logging
+// errors only, to stderr so that the commands' output stays parseable
+func newDrandClient(urls []string, chainHash []byte) (client.Client, error) {
+	return client.New(
+		client.From(drandHTTPClient.ForURLs(urls, chainHash)...),
+		client.WithChainHash(chainHash),
+		client.WithLogger(drandLog.NewLogger(os.Stderr, drandLog.LogError)),
+	)
+}
+
+// runStatus prints the latest drand round against the oracle's latest round
+func runStatus(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	rpcURL := fs.String("rpc", os.Getenv("RPC"), "RPC URL")
+	oracleAddress := fs.String("oracle-address", os.Getenv("DRAND_ORACLE_ADDRESS"), "Drand Oracle contract address")
+	drandURLs := fs.String("drand-urls", os.Getenv("DRAND_URLS"), "comma separated drand relay URLs")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of the whole command")
+	_ = fs.Parse(args)
+
+	if *rpcURL == "" {
+		log.Fatal().Msg("--rpc or RPC is required")
+	}
+	if !common.IsHexAddress(*oracleAddress) {
+		log.Fatal().Str("oracle_address", *oracleAddress).Msg("--oracle-address or DRAND_ORACLE_ADDRESS must be a valid address")
+	}
+	if *drandURLs == "" {
+		log.Fatal().Msg("--drand-urls or DRAND_URLS is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	rpcClient, err := ethclient.Dial(*rpcURL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating rpc client")
+	}
+	contractAddress := common.HexToAddress(*oracleAddress)
+	oracle, err := binding.NewBinding(contractAddress, rpcClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating binding")
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	chainHash, err := oracle.CHAINHASH(opts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to get chain hash from Drand Oracle contract")
+	}
+	oracleRound, err := oracle.LatestRound(opts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to get latest round from Drand Oracle contract")
+	}
+
+	// The oracle's chain hash selects the drand network
+	drandClient, err := newDrandClient(strings.Split(*drandURLs, ","), chainHash[:])
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating drand client")
+	}
+	latest, err := drandClient.Get(ctx, 0)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to get latest round from Drand network")
+	}
+
+	status := roundStatus{
+		ChainHash:     fmt.Sprintf("%x", chainHash),
+		OracleAddress: contractAddress.Hex(),
+		DrandRound:    latest.Round(),
+		OracleRound:   oracleRound,
+	}
+	if status.DrandRound > status.OracleRound {
+		status.Lag = status.DrandRound - status.OracleRound
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(status); err != nil {
+			log.Fatal().Err(err).Msg("Failed to write status")
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "chain hash\t%s\n", status.ChainHash)
+	fmt.Fprintf(w, "oracle\t%s\n", status.OracleAddress)
+	fmt.Fprintf(w, "drand round\t%d\n", status.DrandRound)
+	fmt.Fprintf(w, "oracle round\t%d\n", status.OracleRound)
+	fmt.Fprintf(w, "lag\t%d\n", status.Lag)
+	_ = w.Flush()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/internal/service"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runSubmit force-submits a single round
+func runSubmit(args []string) {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	round := fs.Uint64("round", 0, "round to submit, it must follow the oracle's latest round")
+	pipeline := fs.String("pipeline", "default", "pipeline to submit the round to")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: updater submit --round N [flags]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *round == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	submitRounds(*pipeline, *round, *round)
+}
+
+// runBackfill submits a range of rounds
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	from := fs.Uint64("from", 0, "first round to submit, rounds already set on the oracle are skipped")
+	to := fs.Uint64("to", 0, "last round to submit, 0 submits up to the latest drand round")
+	pipeline := fs.String("pipeline", "default", "pipeline to submit the rounds to")
+	_ = fs.Parse(args)
+	if *to != 0 && *from > *to {
+		log.Fatal().Uint64("from", *from).Uint64("to", *to).Msg("--from must not be after --to")
+	}
+	submitRounds(*pipeline, *from, *to)
+}
+
+// submitRounds submits rounds through the updater of a pipeline, configured
+// from the environment like the updater itself
+func submitRounds(pipeline string, from, to uint64) {
+	cfg, _ := loadConfig()
+	auditLog, err := newAuditLog(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid audit log")
+	}
+	defer closeAuditLog(auditLog)
+	pipelines, updaters, _ := newUpdaters(cfg, nil, nil, nil, auditLog)
+	var updater *service.Updater
+	for i := range pipelines {
+		if pipelines[i].Name == pipeline {
+			updater = updaters[i]
+		}
+	}
+	if updater == nil {
+		log.Fatal().Str("pipeline", pipeline).Msg("Unknown pipeline")
+	}
+	log.Warn().Msg("Submitting with the updater's sender, make sure no running updater submits for it meanwhile")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	submitted, err := updater.SubmitRounds(ctx, from, to)
+	if err != nil {
+		closeAuditLog(auditLog)
+		log.Fatal().Err(err).Int("submitted", submitted).Msg("Submission stopped")
+	}
+	log.Info().Int("submitted", submitted).Msg("Submission done")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"drand-oracle-updater/config"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// newAPITLSConfig returns the TLS configuration of the API server, nil when
+// HTTP_TLS_CERT is not set. With ADMIN_CLIENT_CA, client certificates are
+// requested and verified against it, authorizing the admin routes.
+func newAPITLSConfig(cfg config.Config) (*tls.Config, error) {
+	if cfg.HttpTLSCert == "" && cfg.HttpTLSKey == "" {
+		if cfg.AdminClientCA != "" {
+			return nil, errors.New("ADMIN_CLIENT_CA requires HTTP_TLS_CERT and HTTP_TLS_KEY")
+		}
+		return nil, nil
+	}
+	if cfg.HttpTLSCert == "" || cfg.HttpTLSKey == "" {
+		return nil, errors.New("HTTP_TLS_CERT and HTTP_TLS_KEY must be set together")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.AdminClientCA != "" {
+		pem, err := os.ReadFile(cfg.AdminClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("error reading admin client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in admin client CA %s", cfg.AdminClientCA)
+		}
+		// Clients without a certificate still reach the other routes
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		log.Info().Str("ca", cfg.AdminClientCA).Msg("Admin routes accept client certificates")
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/topup"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// newTopUpManager returns the manager topping up the sender from the treasury,
+// nil when TOPUP_FLOOR_WEI is 0
+func newTopUpManager(cfg config.Config, rpcClient *ethclient.Client) (*topup.Manager, error) {
+	floor, ok := new(big.Int).SetString(cfg.TopUpFloorWei, 10)
+	if !ok || floor.Sign() < 0 {
+		return nil, fmt.Errorf("invalid top-up floor %q", cfg.TopUpFloorWei)
+	}
+	if floor.Sign() == 0 {
+		return nil, nil
+	}
+	target, ok := new(big.Int).SetString(cfg.TopUpTargetWei, 10)
+	if !ok || target.Sign() < 0 {
+		return nil, fmt.Errorf("invalid top-up target %q", cfg.TopUpTargetWei)
+	}
+	maxAmount, ok := new(big.Int).SetString(cfg.TopUpMaxAmountWei, 10)
+	if !ok || maxAmount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid maximum top-up amount %q", cfg.TopUpMaxAmountWei)
+	}
+
+	var treasury topup.Treasury
+	switch {
+	case cfg.TopUpTreasuryPrivateKey != "" && cfg.TopUpFaucetURL != "":
+		return nil, errors.New("TOPUP_TREASURY_PRIVATE_KEY and TOPUP_FAUCET_URL are mutually exclusive")
+	case cfg.TopUpTreasuryPrivateKey != "":
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(cfg.TopUpTreasuryPrivateKey, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing treasury private key: %w", err)
+		}
+		treasury = topup.NewWallet(cfg.ChainID, key, rpcClient)
+	case cfg.TopUpFaucetURL != "":
+		treasury = topup.NewFaucet(cfg.TopUpFaucetURL, cfg.TopUpFaucetToken, cfg.ChainID)
+	default:
+		return nil, errors.New("TOPUP_TREASURY_PRIVATE_KEY or TOPUP_FAUCET_URL is required with TOPUP_FLOOR_WEI")
+	}
+
+	manager, err := topup.NewManager(treasury, topup.Config{
+		Floor:     floor,
+		Target:    target,
+		MaxAmount: maxAmount,
+		Cooldown:  cfg.TopUpCooldown,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info().
+		Str("treasury", manager.Treasury()).
+		Str("floor_wei", floor.String()).
+		Str("max_amount_wei", maxAmount.String()).
+		Dur("cooldown", cfg.TopUpCooldown).
+		Msg("Sender is topped up from the treasury when its balance runs low")
+	return manager, nil
+}
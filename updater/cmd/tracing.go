@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/tracing"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// traceExportTimeout bounds a single export of spans to the collector
+const traceExportTimeout = 10 * time.Second
+
+// tracingOptions builds the trace exporter options from the configuration
+func tracingOptions(cfg config.Config) (tracing.Options, error) {
+	if cfg.TraceSampleRatio < 0 || cfg.TraceSampleRatio > 1 {
+		return tracing.Options{}, fmt.Errorf("invalid trace sample ratio %v, must be between 0 and 1", cfg.TraceSampleRatio)
+	}
+	headers, err := tracing.ParseHeaders(cfg.OTLPHeaders)
+	if err != nil {
+		return tracing.Options{}, err
+	}
+	return tracing.Options{
+		Endpoint:    cfg.OTLPEndpoint,
+		Headers:     headers,
+		ServiceName: cfg.OTelServiceName,
+		SampleRatio: cfg.TraceSampleRatio,
+		Timeout:     traceExportTimeout,
+		Attributes:  cfg.DeploymentLabels,
+	}, nil
+}
+
+// setupTracing installs the trace exporter when an OTLP endpoint is
+// configured, and returns the function flushing the pending spans at exit
+func setupTracing(cfg config.Config) func() {
+	opts, err := tracingOptions(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid tracing configuration")
+	}
+	shutdown, err := tracing.Setup(context.Background(), opts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error setting up tracing")
+	}
+	if opts.Endpoint != "" {
+		log.Info().
+			Str("service_name", opts.ServiceName).
+			Float64("sample_ratio", opts.SampleRatio).
+			Msg("Tracing initialized")
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), traceExportTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("error flushing traces")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"drand-oracle-updater/internal/store"
+	"flag"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runUploadArchive uploads the rounds already in the rounds index of every
+// pipeline to the archive bucket, such as the rounds indexed before the bucket
+// was configured
+func runUploadArchive(args []string) {
+	fs := flag.NewFlagSet("upload-archive", flag.ExitOnError)
+	fromRound := fs.Uint64("from-round", 0, "first round to upload")
+	workers := fs.Int("workers", 8, "number of concurrent uploads")
+	_ = fs.Parse(args)
+
+	cfg, _ := loadConfig()
+	if cfg.ArchiveS3Endpoint == "" {
+		log.Fatal().Msg("ARCHIVE_S3_ENDPOINT is required")
+	}
+	pipelines, err := cfg.AllPipelines()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid pipelines")
+	}
+
+	// Stop on interrupt, a later run with --from-round continuing
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	failed := false
+	for _, pipeline := range pipelines {
+		logger := log.With().Str("pipeline", pipeline.Name).Logger()
+		objects, err := newRoundObjects(cfg, pipeline.ChainHash, "")
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error creating archive bucket client")
+		}
+		stateStore, err := store.Open(pipelineStateDir(cfg, pipeline))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error opening state store")
+		}
+
+		var uploaded, failures atomic.Uint64
+		rounds := make(chan store.Round)
+		var wg sync.WaitGroup
+		for range max(*workers, 1) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for round := range rounds {
+					if err := objects.Put(ctx, round); err != nil {
+						failures.Add(1)
+						logger.Error().Err(err).Uint64("round", round.Round).Msg("Failed to upload round")
+						continue
+					}
+					if uploaded.Add(1)%10000 == 0 {
+						logger.Info().Uint64("uploaded", uploaded.Load()).Uint64("round", round.Round).Msg("Upload progress")
+					}
+				}
+			}()
+		}
+		err = stateStore.ScanRounds(func(record int, round store.Round, err error) error {
+			if err != nil {
+				logger.Warn().Err(err).Int("record", record).Msg("Skipping unreadable record")
+				return nil
+			}
+			if round.Round < *fromRound {
+				return nil
+			}
+			select {
+			case rounds <- round:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(rounds)
+		wg.Wait()
+		if err != nil {
+			logger.Fatal().Err(err).Uint64("uploaded", uploaded.Load()).Msg("Upload stopped")
+		}
+		logger.Info().Uint64("uploaded", uploaded.Load()).Uint64("failed", failures.Load()).Str("bucket", cfg.ArchiveS3Bucket).Msg("Uploaded rounds index")
+		failed = failed || failures.Load() > 0
+	}
+	if failed {
+		log.Fatal().Msg("Some rounds failed to upload, run again to retry")
+	}
+}
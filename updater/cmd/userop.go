@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/sender"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// Sender modes
+const (
+	senderModeEOA     = "eoa"
+	senderModeERC4337 = "erc4337"
+)
+
+// newUserOpSender returns the ERC-4337 user operation sender of the smart
+// account owned by the sender key, nil unless SENDER_MODE is erc4337
+func newUserOpSender(cfg config.Config, ownerKey *ecdsa.PrivateKey, rpcClient *ethclient.Client) (*sender.UserOpSender, error) {
+	switch cfg.SenderMode {
+	case senderModeEOA:
+		return nil, nil
+	case senderModeERC4337:
+	default:
+		return nil, fmt.Errorf("invalid sender mode %q, expected %s or %s", cfg.SenderMode, senderModeEOA, senderModeERC4337)
+	}
+	if cfg.ERC4337BundlerURL == "" || cfg.ERC4337AccountAddress == "" {
+		return nil, errors.New("ERC4337_BUNDLER_URL and ERC4337_ACCOUNT_ADDRESS are required with SENDER_MODE=erc4337")
+	}
+	if !common.IsHexAddress(cfg.ERC4337AccountAddress) {
+		return nil, fmt.Errorf("invalid account address %q", cfg.ERC4337AccountAddress)
+	}
+	if !common.IsHexAddress(cfg.ERC4337EntryPoint) {
+		return nil, fmt.Errorf("invalid entry point address %q", cfg.ERC4337EntryPoint)
+	}
+	var paymasterAndData []byte
+	if cfg.ERC4337PaymasterAndData != "" {
+		var err error
+		paymasterAndData, err = hexutil.Decode(cfg.ERC4337PaymasterAndData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid paymaster data: %w", err)
+		}
+	}
+	bundler, err := rpc.Dial(cfg.ERC4337BundlerURL)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bundler client: %w", err)
+	}
+	userOps := sender.NewUserOpSender(
+		cfg.ChainID,
+		ownerKey,
+		common.HexToAddress(cfg.ERC4337AccountAddress),
+		common.HexToAddress(cfg.ERC4337EntryPoint),
+		paymasterAndData,
+		bundler,
+		rpcClient,
+	)
+	log.Info().
+		Str("account", userOps.Address().Hex()).
+		Str("entry_point", userOps.EntryPoint().Hex()).
+		Bool("paymaster", len(paymasterAndData) > 0).
+		Msg("Rounds are set through ERC-4337 user operations")
+	return userOps, nil
+}
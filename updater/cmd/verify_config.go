@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/finality"
+	"drand-oracle-updater/internal/notify"
+	"drand-oracle-updater/internal/records"
+	"drand-oracle-updater/internal/region"
+	"drand-oracle-updater/internal/service"
+	"drand-oracle-updater/signer"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// configCheck is the outcome of one verify-config check
+type configCheck struct {
+	name string
+	err  error
+	// warning reports err without failing the verification
+	warning bool
+}
+
+// runVerifyConfig validates the environment and checks connectivity to the
+// drand relays, the RPC and the oracle contracts without submitting anything
+func runVerifyConfig(args []string) {
+	fs := flag.NewFlagSet("verify-config", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of the connectivity checks")
+	_ = fs.Parse(args)
+
+	loader := config.NewLoader(os.Getenv(config.ConfigFileEnv))
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAIL  environment: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	// Unknown variables fail the verification even without STRICT_CONFIG
+	var unknownErr error
+	if unknown := loader.Unknown(); len(unknown) > 0 {
+		unknownErr = fmt.Errorf("unknown variables %v", unknown)
+	}
+	checks := append([]configCheck{{name: "variables", err: unknownErr}}, lintChecks(cfg)...)
+	checks = append(checks, verifyConfig(ctx, cfg)...)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	failed := 0
+	for _, check := range checks {
+		if check.err != nil && check.warning {
+			fmt.Fprintf(w, "WARN\t%s\t%v\n", check.name, check.err)
+		} else if check.err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL\t%s\t%v\n", check.name, check.err)
+		} else {
+			fmt.Fprintf(w, "ok\t%s\t\n", check.name)
+		}
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\n%d check(s), %d failed\n", len(checks), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// lintChecks reports the dangerous combinations of the configuration, the
+// warnings not failing the verification
+func lintChecks(cfg config.Config) []configCheck {
+	var checks []configCheck
+	for _, finding := range config.Lint(cfg) {
+		checks = append(checks, configCheck{
+			name:    "lint " + finding.Rule,
+			err:     errors.New(finding.Message),
+			warning: finding.Severity == config.LintWarning,
+		})
+	}
+	return checks
+}
+
+// verifyConfig runs the static checks of the configuration, then the
+// connectivity checks of every pipeline
+func verifyConfig(ctx context.Context, cfg config.Config) []configCheck {
+	var checks []configCheck
+	check := func(name string, err error) bool {
+		checks = append(checks, configCheck{name: name, err: err})
+		return err == nil
+	}
+
+	_, _, err := newSenderKey(ctx, cfg)
+	check("sender key", err)
+	_, err = newKeyRotation(cfg)
+	check("next keys", err)
+	for _, amount := range []struct{ name, value string }{
+		{"SUBMISSION_FEE_WEI", cfg.SubmissionFeeWei},
+		{"MIN_SENDER_BALANCE_WEI", cfg.MinSenderBalanceWei},
+		{"LOSS_LIMIT_WEI", cfg.LossLimitWei},
+		{"CATCHUP_COST_CEILING_WEI", cfg.CatchUpCostCeilingWei},
+		{"MAX_GAS_PRICE_WEI", cfg.MaxGasPriceWei},
+	} {
+		check(amount.name, parseWei(amount.value))
+	}
+	_, err = service.ParseCatchUpPolicy(cfg.CatchUpPolicy, cfg.CatchUpEvery, cfg.CatchUpMaxAge)
+	check("catch-up policy", err)
+	calldataForm, err := service.ParseCalldataForm(cfg.CalldataForm)
+	check("calldata form", err)
+	check("audit log", verifyAuditLog(cfg))
+	_, err = signer.ParseScheme(cfg.SignatureScheme)
+	check("signature scheme", err)
+	_, err = records.ParseFormat(cfg.ArchiveFormat)
+	check("archive format", err)
+	check("archive bucket", verifyRoundObjects(cfg))
+	_, err = newWebhookSigner(cfg)
+	check("webhook signing keys", err)
+	check("consumer webhooks", notify.ValidateURLs(cfg.ConsumerWebhookURLs))
+	_, err = newIngestVerifier(cfg)
+	check("ingest verify keys", err)
+	_, err = tracingOptions(cfg)
+	check("tracing", err)
+	_, err = newTopUpManager(cfg, nil)
+	check("top-up", err)
+	check("resource limits", verifyLimits(cfg))
+	_, err = finality.ParsePolicy(cfg.Finality, cfg.FinalityDepth, cfg.ChainID, cfg.FinalityChains)
+	check("finality", err)
+	pipelines, err := cfg.AllPipelines()
+	if !check("pipelines", err) {
+		return checks
+	}
+
+	rpcClient, err := ethclient.Dial(cfg.RPC)
+	if !check("rpc", err) {
+		return checks
+	}
+	chainID, err := rpcClient.ChainID(ctx)
+	if err == nil && chainID.Int64() != cfg.ChainID {
+		err = fmt.Errorf("rpc chain id is %s, CHAIN_ID is %d", chainID, cfg.ChainID)
+	}
+	check("rpc chain id", err)
+	if cfg.ColdStandby && cfg.LeaderElection != "" {
+		check("cold standby", errors.New("COLD_STANDBY and LEADER_ELECTION are mutually exclusive"))
+	}
+	if cfg.Region != "" {
+		err = nil
+		if cfg.ColdStandby || cfg.LeaderElection != "" {
+			err = errors.New("REGION is mutually exclusive with LEADER_ELECTION and COLD_STANDBY")
+		} else if cfg.RegionToken == "" {
+			err = errors.New("REGION_TOKEN is required with REGION")
+		} else {
+			_, err = region.ParsePeers(cfg.RegionPeers)
+		}
+		check("region", err)
+	}
+	selfTest, err := newSelfTest(cfg, rpcClient)
+	if err == nil && selfTest != nil {
+		var testChainHash [32]byte
+		testChainHash, err = selfTest.Binding.CHAINHASH(&bind.CallOpts{Context: ctx})
+		if chainHash, _ := hex.DecodeString(cfg.ChainHash); err == nil && !bytes.Equal(testChainHash[:], chainHash) {
+			err = fmt.Errorf("self-test oracle serves chain %x", testChainHash)
+		}
+	}
+	check("self-test oracle", err)
+
+	for _, pipeline := range pipelines {
+		prefix := "pipeline " + pipeline.Name + ": "
+		chainHash, err := hex.DecodeString(pipeline.ChainHash)
+		if !check(prefix+"chain hash", err) {
+			continue
+		}
+
+		drandClient, err := newDrandClient(cfg.DrandURLs, chainHash)
+		if err == nil {
+			_, err = drandClient.Get(ctx, 0)
+		}
+		check(prefix+"drand relays", err)
+
+		contractAddress := common.HexToAddress(pipeline.OracleAddress)
+		oracle, err := binding.NewBinding(contractAddress, rpcClient)
+		if !check(prefix+"oracle binding", err) {
+			continue
+		}
+		opts := &bind.CallOpts{Context: ctx}
+		oracleChainHash, err := oracle.CHAINHASH(opts)
+		if err == nil && !bytes.Equal(oracleChainHash[:], chainHash) {
+			err = fmt.Errorf("oracle serves chain %x", oracleChainHash)
+		}
+		check(prefix+"oracle chain hash", err)
+
+		if calldataForm == service.CalldataCompact {
+			capabilities, err := oracle.Capabilities(opts)
+			if err == nil && !service.SupportsCompactCalldata(capabilities) {
+				err = fmt.Errorf("oracle capabilities %#x lack the compact calldata form", capabilities)
+			}
+			check(prefix+"calldata form", err)
+		}
+
+		signer, err := newSetRandomnessSigner(cfg, contractAddress)
+		if !check(prefix+"signer", err) {
+			continue
+		}
+		oracleSigner, err := oracle.Signer(opts)
+		if err == nil && oracleSigner != signer.Address() {
+			err = fmt.Errorf("oracle signer is %s, configured signer is %s", oracleSigner.Hex(), signer.Address().Hex())
+		}
+		check(prefix+"oracle signer", err)
+	}
+	return checks
+}
+
+// parseWei checks that a wei amount is a non-negative integer
+func parseWei(value string) error {
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok || amount.Sign() < 0 {
+		return errors.New("not a non-negative integer")
+	}
+	return nil
+}
+
+// verifyLimits checks the resource limits, which limits.New does not reject
+func verifyLimits(cfg config.Config) error {
+	switch {
+	case cfg.MemoryLimitBytes < 0:
+		return errors.New("MEMORY_LIMIT_BYTES must not be negative")
+	case cfg.MaxGoroutines < 0:
+		return errors.New("MAX_GOROUTINES must not be negative")
+	case cfg.MaxConcurrentCatchUps < 0:
+		return errors.New("MAX_CONCURRENT_CATCHUPS must not be negative")
+	case cfg.CatchUpFetchWorkers < 0 || cfg.CatchUpSignWorkers < 0:
+		return errors.New("CATCHUP_FETCH_WORKERS and CATCHUP_SIGN_WORKERS must not be negative")
+	case cfg.SubmissionPipelineDepth < 0:
+		return errors.New("SUBMISSION_PIPELINE_DEPTH must not be negative")
+	case cfg.DegradeThreshold <= 0 || cfg.DegradeThreshold > 1:
+		return fmt.Errorf("DEGRADE_THRESHOLD %v must be above 0 and at most 1", cfg.DegradeThreshold)
+	}
+	return nil
+}
@@ -1,16 +1,204 @@
 package config
 
+import "time"
+
 type Config struct {
-	DrandURLs             []string `envconfig:"DRAND_URLS" required:"true"`
-	ChainHash             string   `envconfig:"CHAIN_HASH" required:"true"`
-	DrandOracleAddress    string   `envconfig:"DRAND_ORACLE_ADDRESS" required:"true"`
-	RPC                   string   `envconfig:"RPC" required:"true"`
-	ChainID               int64    `envconfig:"CHAIN_ID" required:"true"`
-	SetRandomnessGasLimit uint64   `envconfig:"SET_RANDOMNESS_GAS_LIMIT" required:"true"`
-	SignerPrivateKey      string   `envconfig:"SIGNER_PRIVATE_KEY" required:"true"`
-	SenderPrivateKey      string   `envconfig:"SENDER_PRIVATE_KEY" required:"true"`
-	GenesisRound          uint64   `envconfig:"GENESIS_ROUND" required:"true"`
-	MetricsPort           int      `envconfig:"METRICS_PORT" default:"4014"`
-	HttpPort              int      `envconfig:"HTTP_PORT" default:"8080"`
-	MaxRetries            int      `envconfig:"MAX_RETRIES" default:"10"`
+	DrandURLs                []string      `envconfig:"DRAND_URLS" required:"true" redact:"url"`
+	ChainHash                string        `envconfig:"CHAIN_HASH" required:"true"`
+	DrandOracleAddress       string        `envconfig:"DRAND_ORACLE_ADDRESS" required:"true"`
+	DrandAdaptivePolling     bool          `envconfig:"DRAND_ADAPTIVE_POLLING" default:"true"`
+	DrandPollLead            time.Duration `envconfig:"DRAND_POLL_LEAD" default:"100ms"`
+	DrandPollInterval        time.Duration `envconfig:"DRAND_POLL_INTERVAL" default:"500ms"`
+	DrandPollJitter          time.Duration `envconfig:"DRAND_POLL_JITTER" default:"200ms"`
+	DrandRelayTimeout        time.Duration `envconfig:"DRAND_RELAY_TIMEOUT" default:"5s"`
+	DrandRelayMaxLatency     time.Duration `envconfig:"DRAND_RELAY_MAX_LATENCY" default:"2s"`
+	DrandRelayMaxErrorRate   float64       `envconfig:"DRAND_RELAY_MAX_ERROR_RATE" default:"0.2"`
+	DrandRelayPruneAfter     int           `envconfig:"DRAND_RELAY_PRUNE_AFTER" default:"3"`
+	DrandRelayProbeInterval  time.Duration `envconfig:"DRAND_RELAY_PROBE_INTERVAL" default:"1m"`
+	DrandRelayLatencyWeight  float64       `envconfig:"DRAND_RELAY_LATENCY_WEIGHT" default:"1"`
+	DrandRelayStaleWeight    float64       `envconfig:"DRAND_RELAY_STALENESS_WEIGHT" default:"1"`
+	DrandRelayCrossChecks    int           `envconfig:"DRAND_RELAY_CROSS_CHECKS" default:"1"`
+	RPC                      string        `envconfig:"RPC" required:"true" redact:"url"`
+	RPCFallbackURLs          []string      `envconfig:"RPC_FALLBACK_URLS" redact:"url"`
+	RPCRoundRobin            bool          `envconfig:"RPC_ROUND_ROBIN" default:"false"`
+	RPCTimeout               time.Duration `envconfig:"RPC_TIMEOUT" default:"30s"`
+	RPCCacheTTL              time.Duration `envconfig:"RPC_CACHE_TTL"`
+	RPCWS                    string        `envconfig:"RPC_WS" redact:"url"`
+	EventsPollInterval       time.Duration `envconfig:"EVENTS_POLL_INTERVAL" default:"12s"`
+	HibernateAfter           time.Duration `envconfig:"HIBERNATE_AFTER"`
+	HibernatePollInterval    time.Duration `envconfig:"HIBERNATE_POLL_INTERVAL" default:"1m"`
+	HibernateWakeAddress     string        `envconfig:"HIBERNATE_WAKE_ADDRESS"`
+	HibernateWakeEvent       string        `envconfig:"HIBERNATE_WAKE_EVENT"`
+	ChainID                  int64         `envconfig:"CHAIN_ID" required:"true"`
+	SetRandomnessGasLimit    uint64        `envconfig:"SET_RANDOMNESS_GAS_LIMIT" required:"true"`
+	SignerPrivateKey         string        `envconfig:"SIGNER_PRIVATE_KEY" required:"true" redact:"secret"`
+	SenderPrivateKey         string        `envconfig:"SENDER_PRIVATE_KEY" redact:"secret"`
+	SenderKeyBackend         string        `envconfig:"SENDER_KEY_BACKEND" default:"local"`
+	LedgerDerivationPath     string        `envconfig:"LEDGER_DERIVATION_PATH" default:"m/44'/60'/0'/0/0"`
+	LedgerConnectTimeout     time.Duration `envconfig:"LEDGER_CONNECT_TIMEOUT" default:"1m"`
+	LedgerConfirmTimeout     time.Duration `envconfig:"LEDGER_CONFIRM_TIMEOUT" default:"2m"`
+	SenderKeySeed            string        `envconfig:"SENDER_KEY_SEED"`
+	SenderKeySecret          string        `envconfig:"SENDER_KEY_SECRET" redact:"secret"`
+	SenderKeyTestnets        []int64       `envconfig:"SENDER_KEY_TESTNET_CHAINS"`
+	SenderMode               string        `envconfig:"SENDER_MODE" default:"eoa"`
+	ERC4337BundlerURL        string        `envconfig:"ERC4337_BUNDLER_URL" redact:"url"`
+	ERC4337EntryPoint        string        `envconfig:"ERC4337_ENTRY_POINT" default:"0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789"`
+	ERC4337AccountAddress    string        `envconfig:"ERC4337_ACCOUNT_ADDRESS"`
+	ERC4337PaymasterAndData  string        `envconfig:"ERC4337_PAYMASTER_AND_DATA"`
+	ExtraSignerKeys          []string      `envconfig:"EXTRA_SIGNER_PRIVATE_KEYS" redact:"secret"`
+	RemoteSignerAddresses    []string      `envconfig:"REMOTE_SIGNER_ADDRESSES"`
+	RemoteSignerURLs         []string      `envconfig:"REMOTE_SIGNER_URLS" redact:"url"`
+	SignerThreshold          int           `envconfig:"SIGNER_THRESHOLD" default:"1"`
+	SignatureScheme          string        `envconfig:"SIGNATURE_SCHEME" default:"eip712"`
+	SenderNextPrivateKey     string        `envconfig:"SENDER_NEXT_PRIVATE_KEY" redact:"secret"`
+	SignerNextPrivateKey     string        `envconfig:"SIGNER_NEXT_PRIVATE_KEY" redact:"secret"`
+	KeyRotationDrainTimeout  time.Duration `envconfig:"KEY_ROTATION_DRAIN_TIMEOUT" default:"5m"`
+	GenesisRound             uint64        `envconfig:"GENESIS_ROUND" required:"true"`
+	MetricsPort              int           `envconfig:"METRICS_PORT" default:"4014"`
+	HttpPort                 int           `envconfig:"HTTP_PORT" default:"8080"`
+	GRPCHealthPort           int           `envconfig:"GRPC_HEALTH_PORT" default:"0"`
+	LivenessFile             string        `envconfig:"LIVENESS_FILE"`
+	ReadinessFile            string        `envconfig:"READINESS_FILE"`
+	ProbeInterval            time.Duration `envconfig:"PROBE_INTERVAL" default:"10s"`
+	MaxRetries               int           `envconfig:"MAX_RETRIES" default:"10"`
+	ShutdownTimeout          time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"2m"`
+	DeploymentLabels         Labels        `envconfig:"DEPLOYMENT_LABELS"`
+	ExtraPipelines           Pipelines     `envconfig:"EXTRA_PIPELINES"`
+	StateDir                 string        `envconfig:"STATE_DIR" default:"data"`
+	SubmissionFeeWei         string        `envconfig:"SUBMISSION_FEE_WEI" default:"0"`
+	MinSenderBalanceWei      string        `envconfig:"MIN_SENDER_BALANCE_WEI" default:"0"`
+	AccountPollInterval      time.Duration `envconfig:"ACCOUNT_POLL_INTERVAL" default:"1m"`
+	MaxRoundLag              uint64        `envconfig:"MAX_ROUND_LAG" default:"10"`
+	StateRetention           time.Duration `envconfig:"STATE_RETENTION" default:"0"`
+	StateRetentionRounds     uint64        `envconfig:"STATE_RETENTION_ROUNDS" default:"0"`
+	StateRoundsRetention     time.Duration `envconfig:"STATE_ROUNDS_RETENTION" default:"2160h"`
+	StateRoundsMaxRounds     uint64        `envconfig:"STATE_ROUNDS_RETENTION_ROUNDS" default:"0"`
+	StateCompactionInterval  time.Duration `envconfig:"STATE_COMPACTION_INTERVAL" default:"24h"`
+	ArchiveDir               string        `envconfig:"ARCHIVE_DIR"`
+	ArchiveFormat            string        `envconfig:"ARCHIVE_FORMAT" default:"jsonl"`
+	ArchiveVerifyRate        float64       `envconfig:"ARCHIVE_VERIFY_RATE" default:"0"`
+	ArchiveVerifyInterval    time.Duration `envconfig:"ARCHIVE_VERIFY_INTERVAL" default:"24h"`
+	ArchiveS3Endpoint        string        `envconfig:"ARCHIVE_S3_ENDPOINT" redact:"url"`
+	ArchiveS3Bucket          string        `envconfig:"ARCHIVE_S3_BUCKET"`
+	ArchiveS3Prefix          string        `envconfig:"ARCHIVE_S3_PREFIX"`
+	ArchiveS3Region          string        `envconfig:"ARCHIVE_S3_REGION" default:"us-east-1"`
+	ArchiveS3AccessKeyID     string        `envconfig:"ARCHIVE_S3_ACCESS_KEY_ID" redact:"secret"`
+	ArchiveS3SecretAccessKey string        `envconfig:"ARCHIVE_S3_SECRET_ACCESS_KEY" redact:"secret"`
+	ArchiveS3PublicURL       string        `envconfig:"ARCHIVE_S3_PUBLIC_URL"`
+	ArchiveEraRounds         uint64        `envconfig:"ARCHIVE_ERA_ROUNDS" default:"100000"`
+	ReleaseIPFSAPIURL        string        `envconfig:"RELEASE_IPFS_API_URL" redact:"url"`
+	ReleaseIPFSToken         string        `envconfig:"RELEASE_IPFS_TOKEN" redact:"secret"`
+	ReleasePrivateKey        string        `envconfig:"RELEASE_PRIVATE_KEY" redact:"secret"`
+	ReleaseAnchor            bool          `envconfig:"RELEASE_ANCHOR" default:"false"`
+	AdminToken               string        `envconfig:"ADMIN_TOKEN" redact:"secret"`
+	AdminClientCA            string        `envconfig:"ADMIN_CLIENT_CA"`
+	HttpTLSCert              string        `envconfig:"HTTP_TLS_CERT"`
+	HttpTLSKey               string        `envconfig:"HTTP_TLS_KEY"`
+	DryRun                   bool          `envconfig:"DRY_RUN" default:"false"`
+	IngestTokens             []string      `envconfig:"INGEST_TOKENS" redact:"secret"`
+	IngestVerifyKeys         []string      `envconfig:"INGEST_VERIFY_KEYS" redact:"secret"`
+	WebhookSigningKeys       []string      `envconfig:"WEBHOOK_SIGNING_KEYS" redact:"secret"`
+	ConsumerWebhookURLs      []string      `envconfig:"CONSUMER_WEBHOOK_URLS" redact:"url"`
+	ConsumerWebhookAttempts  int           `envconfig:"CONSUMER_WEBHOOK_MAX_ATTEMPTS" default:"5"`
+	ConsumerWebhookTimeout   time.Duration `envconfig:"CONSUMER_WEBHOOK_TIMEOUT" default:"10s"`
+	ConsumerWebhookQueueSize int           `envconfig:"CONSUMER_WEBHOOK_QUEUE_SIZE" default:"100"`
+	SignatureTolerance       time.Duration `envconfig:"SIGNATURE_TOLERANCE" default:"5m"`
+	GasStrategy              string        `envconfig:"GAS_STRATEGY"`
+	GasPriceMultiplier       float64       `envconfig:"GAS_PRICE_MULTIPLIER" default:"1"`
+	MaxGasPriceWei           string        `envconfig:"MAX_GAS_PRICE_WEI" default:"0"`
+	GasPercentile            float64       `envconfig:"GAS_PERCENTILE" default:"50"`
+	GasPercentileBlocks      uint64        `envconfig:"GAS_PERCENTILE_BLOCKS" default:"20"`
+	GasAPIURL                string        `envconfig:"GAS_API_URL" redact:"url"`
+	GasAPIKey                string        `envconfig:"GAS_API_KEY" redact:"secret"`
+	GasAPIField              string        `envconfig:"GAS_API_FIELD" default:"result.ProposeGasPrice"`
+	CanaryPercent            int           `envconfig:"CANARY_PERCENT" default:"0"`
+	CanaryGasStrategy        string        `envconfig:"CANARY_GAS_STRATEGY"`
+	CanaryGasPriceMultiplier float64       `envconfig:"CANARY_GAS_PRICE_MULTIPLIER" default:"0"`
+	CanaryBatchSize          int           `envconfig:"CANARY_BATCH_SIZE" default:"0"`
+	CanaryWindow             int           `envconfig:"CANARY_WINDOW" default:"100"`
+	CanaryMinSamples         int           `envconfig:"CANARY_MIN_SAMPLES" default:"20"`
+	CanaryMaxCostIncrease    float64       `envconfig:"CANARY_MAX_COST_INCREASE" default:"0.1"`
+	CanaryMaxLatencyIncrease float64       `envconfig:"CANARY_MAX_LATENCY_INCREASE" default:"0.5"`
+	CanaryMaxFailureIncrease float64       `envconfig:"CANARY_MAX_FAILURE_RATE_INCREASE" default:"0.05"`
+	SchedulerPolicy          string        `envconfig:"SCHEDULER_POLICY" default:"fifo"`
+	SchedulerQueueSize       int           `envconfig:"SCHEDULER_QUEUE_SIZE" default:"64"`
+	LossLimitWei             string        `envconfig:"LOSS_LIMIT_WEI" default:"0"`
+	LossWindow               time.Duration `envconfig:"LOSS_WINDOW" default:"30m"`
+	LossCooldown             time.Duration `envconfig:"LOSS_COOLDOWN" default:"0"`
+	AlertSlackWebhookURL     string        `envconfig:"ALERT_SLACK_WEBHOOK_URL" redact:"secret"`
+	AlertPagerDutyRoutingKey string        `envconfig:"ALERT_PAGERDUTY_ROUTING_KEY" redact:"secret"`
+	AlertWebhookURL          string        `envconfig:"ALERT_WEBHOOK_URL" redact:"url"`
+	AlertWebhookToken        string        `envconfig:"ALERT_WEBHOOK_TOKEN" redact:"secret"`
+	AlertConditions          []string      `envconfig:"ALERT_CONDITIONS"`
+	AlertAfterRetries        int           `envconfig:"ALERT_AFTER_RETRIES" default:"0"`
+	AlertRepeatInterval      time.Duration `envconfig:"ALERT_REPEAT_INTERVAL" default:"1h"`
+	CatchUpCostCeilingWei    string        `envconfig:"CATCHUP_COST_CEILING_WEI" default:"0"`
+	CatchUpRequireApproval   bool          `envconfig:"CATCHUP_REQUIRE_APPROVAL" default:"false"`
+	CatchUpPolicy            string        `envconfig:"CATCHUP_POLICY" default:"all"`
+	CatchUpEvery             uint64        `envconfig:"CATCHUP_EVERY" default:"0"`
+	CatchUpMaxAge            time.Duration `envconfig:"CATCHUP_MAX_AGE" default:"0"`
+	CatchUpBatchSize         int           `envconfig:"CATCHUP_BATCH_SIZE" default:"1"`
+	CatchUpFetchWorkers      int           `envconfig:"CATCHUP_FETCH_WORKERS" default:"1"`
+	CatchUpSignWorkers       int           `envconfig:"CATCHUP_SIGN_WORKERS" default:"0"`
+	SubmissionPipelineDepth  int           `envconfig:"SUBMISSION_PIPELINE_DEPTH" default:"1"`
+	CalldataForm             string        `envconfig:"CALLDATA_FORM" default:"auto"`
+	AuditLogFile             string        `envconfig:"AUDIT_LOG_FILE"`
+	AuditS3Endpoint          string        `envconfig:"AUDIT_S3_ENDPOINT" redact:"url"`
+	AuditS3Bucket            string        `envconfig:"AUDIT_S3_BUCKET"`
+	AuditS3Prefix            string        `envconfig:"AUDIT_S3_PREFIX"`
+	AuditS3Region            string        `envconfig:"AUDIT_S3_REGION" default:"us-east-1"`
+	AuditS3AccessKeyID       string        `envconfig:"AUDIT_S3_ACCESS_KEY_ID" redact:"secret"`
+	AuditS3SecretAccessKey   string        `envconfig:"AUDIT_S3_SECRET_ACCESS_KEY" redact:"secret"`
+	AuditS3FlushInterval     time.Duration `envconfig:"AUDIT_S3_FLUSH_INTERVAL" default:"1m"`
+	FallbackRPC              string        `envconfig:"FALLBACK_RPC" redact:"url"`
+	FallbackOracleAddresses  []string      `envconfig:"FALLBACK_ORACLE_ADDRESSES"`
+	FallbackStallTimeout     time.Duration `envconfig:"FALLBACK_STALL_TIMEOUT" default:"0"`
+	FallbackRequireVerified  bool          `envconfig:"FALLBACK_REQUIRE_VERIFIED" default:"true"`
+	MinSetDelay              time.Duration `envconfig:"MIN_SET_DELAY" default:"0"`
+	ChainClockMaxWait        time.Duration `envconfig:"CHAIN_CLOCK_MAX_WAIT" default:"1m"`
+	RetryMaxAttempts         int           `envconfig:"RETRY_MAX_ATTEMPTS" default:"5"`
+	RetryInitialBackoff      time.Duration `envconfig:"RETRY_INITIAL_BACKOFF" default:"500ms"`
+	RetryMaxBackoff          time.Duration `envconfig:"RETRY_MAX_BACKOFF" default:"30s"`
+	RetryBreakerThreshold    int           `envconfig:"RETRY_BREAKER_THRESHOLD" default:"10"`
+	RetryBreakerCooldown     time.Duration `envconfig:"RETRY_BREAKER_COOLDOWN" default:"1m"`
+	StrictConfig             bool          `envconfig:"STRICT_CONFIG" default:"false"`
+	ConfigLintIgnore         []string      `envconfig:"CONFIG_LINT_IGNORE"`
+	LeaderElection           string        `envconfig:"LEADER_ELECTION"`
+	LeaderRedisURL           string        `envconfig:"LEADER_REDIS_URL" redact:"url"`
+	LeaderNamespace          string        `envconfig:"LEADER_NAMESPACE"`
+	LeaderLockName           string        `envconfig:"LEADER_LOCK_NAME" default:"drand-oracle-updater"`
+	LeaderLeaseDuration      time.Duration `envconfig:"LEADER_LEASE_DURATION" default:"15s"`
+	LeaderIdentity           string        `envconfig:"LEADER_IDENTITY"`
+	Region                   string        `envconfig:"REGION"`
+	RegionPeers              []string      `envconfig:"REGION_PEERS"`
+	RegionToken              string        `envconfig:"REGION_TOKEN" redact:"secret"`
+	RegionGrace              time.Duration `envconfig:"REGION_GRACE" default:"10s"`
+	RegionGossipInterval     time.Duration `envconfig:"REGION_GOSSIP_INTERVAL" default:"5s"`
+	ColdStandby              bool          `envconfig:"COLD_STANDBY" default:"false"`
+	SelfTestOracleAddress    string        `envconfig:"SELF_TEST_ORACLE_ADDRESS"`
+	SelfTestInterval         time.Duration `envconfig:"SELF_TEST_INTERVAL" default:"6h"`
+	PauseSafeAddress         string        `envconfig:"PAUSE_SAFE_ADDRESS"`
+	PauseSafeTxServiceURL    string        `envconfig:"PAUSE_SAFE_TX_SERVICE_URL" redact:"url"`
+	PauseProposerPrivateKey  string        `envconfig:"PAUSE_PROPOSER_PRIVATE_KEY" redact:"secret"`
+	TopUpFloorWei            string        `envconfig:"TOPUP_FLOOR_WEI" default:"0"`
+	TopUpTargetWei           string        `envconfig:"TOPUP_TARGET_WEI" default:"0"`
+	TopUpMaxAmountWei        string        `envconfig:"TOPUP_MAX_AMOUNT_WEI" default:"0"`
+	TopUpCooldown            time.Duration `envconfig:"TOPUP_COOLDOWN" default:"1h"`
+	TopUpTreasuryPrivateKey  string        `envconfig:"TOPUP_TREASURY_PRIVATE_KEY" redact:"secret"`
+	TopUpFaucetURL           string        `envconfig:"TOPUP_FAUCET_URL" redact:"url"`
+	TopUpFaucetToken         string        `envconfig:"TOPUP_FAUCET_TOKEN" redact:"secret"`
+	TopUpStartupWait         time.Duration `envconfig:"TOPUP_STARTUP_WAIT"`
+	DecommissionTreasury     string        `envconfig:"DECOMMISSION_TREASURY_ADDRESS"`
+	Finality                 string        `envconfig:"FINALITY" default:"auto"`
+	FinalityDepth            uint64        `envconfig:"FINALITY_DEPTH" default:"0"`
+	FinalityChains           ChainFinality `envconfig:"FINALITY_CHAINS"`
+	FinalityConfirm          bool          `envconfig:"FINALITY_CONFIRM" default:"false"`
+	MemoryLimitBytes         int64         `envconfig:"MEMORY_LIMIT_BYTES" default:"0"`
+	MaxGoroutines            int           `envconfig:"MAX_GOROUTINES" default:"0"`
+	MaxConcurrentCatchUps    int           `envconfig:"MAX_CONCURRENT_CATCHUPS" default:"0"`
+	DegradeThreshold         float64       `envconfig:"DEGRADE_THRESHOLD" default:"0.85"`
+	OTLPEndpoint             string        `envconfig:"OTEL_EXPORTER_OTLP_ENDPOINT" redact:"url"`
+	OTLPHeaders              string        `envconfig:"OTEL_EXPORTER_OTLP_HEADERS" redact:"secret"`
+	OTelServiceName          string        `envconfig:"OTEL_SERVICE_NAME" default:"drand-oracle-updater"`
+	TraceSampleRatio         float64       `envconfig:"TRACE_SAMPLE_RATIO" default:"1"`
 }
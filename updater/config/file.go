@@ -0,0 +1,201 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kelseyhightower/envconfig"
+)
+
+// ConfigFileEnv names the environment variable pointing to the configuration file
+const ConfigFileEnv = "CONFIG_FILE"
+
+// ReloadableKeys are the settings applied on reload, other changes need a restart
+var ReloadableKeys = []string{
+	"MAX_RETRIES",
+	"ALERT_AFTER_RETRIES",
+	"MIN_SENDER_BALANCE_WEI",
+	"MAX_ROUND_LAG",
+	"GAS_PRICE_MULTIPLIER",
+	"MAX_GAS_PRICE_WEI",
+	"ALERT_REPEAT_INTERVAL",
+	"RETRY_MAX_ATTEMPTS",
+	"RETRY_INITIAL_BACKOFF",
+	"RETRY_MAX_BACKOFF",
+	"RETRY_BREAKER_THRESHOLD",
+	"RETRY_BREAKER_COOLDOWN",
+}
+
+// Loader loads the configuration from a TOML file merged with the environment.
+// The file uses the environment variable names as keys, and the environment
+// overrides the file:
+//
+//	RPC = "https://rpc.example.org"
+//	DRAND_URLS = ["https://api.drand.sh", "https://drand.cloudflare.com"]
+//	MAX_GAS_PRICE_WEI = "50000000000"
+//
+//	[DEPLOYMENT_LABELS]
+//	env = "prod"
+type Loader struct {
+	path string
+	// environment holds the variables set in the environment at startup, which
+	// the file must not override
+	environment map[string]bool
+	// fileKeys are the variables last set from the file
+	fileKeys []string
+	// unknown are the unknown variables of the last load
+	unknown []UnknownVariable
+}
+
+// NewLoader returns a loader reading the file at path, or the environment only
+// when path is empty
+func NewLoader(path string) *Loader {
+	environment := make(map[string]bool)
+	for _, pair := range os.Environ() {
+		key, _, _ := strings.Cut(pair, "=")
+		environment[key] = true
+	}
+	return &Loader{path: path, environment: environment}
+}
+
+// Path returns the configuration file path, empty without a file
+func (l *Loader) Path() string {
+	return l.path
+}
+
+// Load reads the file again and processes the configuration. The file values
+// are exported to the process environment, so that subcommands defaulting
+// their flags from the environment see them too. With STRICT_CONFIG, unknown
+// variables of the file and the environment are an error.
+func (l *Loader) Load() (Config, error) {
+	var cfg Config
+	var unknownFile []UnknownVariable
+	if l.path != "" {
+		values, err := readFile(l.path)
+		if err != nil {
+			return cfg, err
+		}
+		for _, key := range l.fileKeys {
+			if _, ok := values[key]; !ok {
+				os.Unsetenv(key)
+			}
+		}
+		unknownFile = unknownFileKeys(values)
+		l.fileKeys = l.fileKeys[:0]
+		for key, value := range values {
+			if l.environment[key] {
+				continue
+			}
+			if err := os.Setenv(key, value); err != nil {
+				return cfg, err
+			}
+			l.fileKeys = append(l.fileKeys, key)
+		}
+	}
+	if err := envconfig.Process("", &cfg); err != nil {
+		return cfg, err
+	}
+
+	// Unknown file keys are exported too, so only add the environment's others
+	l.unknown = unknownFile
+	for _, u := range UnknownVariables(os.Environ()) {
+		if !slices.ContainsFunc(unknownFile, func(f UnknownVariable) bool { return f.Name == u.Name }) {
+			l.unknown = append(l.unknown, u)
+		}
+	}
+	if cfg.StrictConfig && len(l.unknown) > 0 {
+		return cfg, unknownError(l.unknown)
+	}
+	return cfg, nil
+}
+
+// Unknown returns the unknown variables found by the last load, which are
+// ignored unless STRICT_CONFIG is set
+func (l *Loader) Unknown() []UnknownVariable {
+	return l.unknown
+}
+
+// readFile decodes a TOML configuration file into environment variable values
+func readFile(path string) (map[string]string, error) {
+	var raw map[string]any
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		formatted, err := formatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("config file key %s: %w", key, err)
+		}
+		values[strings.ToUpper(key)] = formatted
+	}
+	return values, nil
+}
+
+// formatValue formats a TOML value the way envconfig expects it: arrays as
+// comma separated lists and tables as comma separated key=value pairs
+func formatValue(value any) (string, error) {
+	switch v := value.(type) {
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			formatted, err := formatValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = formatted
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			formatted, err := formatValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			pairs[i] = k + "=" + formatted
+		}
+		return strings.Join(pairs, ","), nil
+	case string, bool, int64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// ChangedKeys returns the settings that differ between two configurations,
+// sorted. Secrets are compared redacted, so their changes are not reported.
+func ChangedKeys(a, b Config) []string {
+	valuesA, valuesB := a.Redacted(), b.Redacted()
+	var changed []string
+	for key, value := range valuesA {
+		if valuesB[key] != value {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// MergeKeys returns base with the settings named by keys taken from updated
+func MergeKeys(base, updated Config, keys []string) Config {
+	merged := reflect.ValueOf(&base).Elem()
+	values := reflect.ValueOf(updated)
+	t := merged.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get("envconfig"); name != "" && slices.Contains(keys, name) {
+			merged.Field(i).Set(values.Field(i))
+		}
+	}
+	return base
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMergeKeys(t *testing.T) {
+	current := Config{RPC: "https://rpc.example.org", MaxRetries: 10, RetryMaxBackoff: 30 * time.Second}
+	updated := Config{RPC: "https://other.example.org", MaxRetries: 3, RetryMaxBackoff: time.Minute}
+
+	merged := MergeKeys(current, updated, ReloadableKeys)
+	if merged.MaxRetries != 3 || merged.RetryMaxBackoff != time.Minute {
+		t.Errorf("reloadable settings = %d, %s, want 3, 1m0s", merged.MaxRetries, merged.RetryMaxBackoff)
+	}
+	if merged.RPC != current.RPC {
+		t.Errorf("RPC = %s, want the current %s", merged.RPC, current.RPC)
+	}
+	// The restart-only change is still reported by the next reload
+	if changed := ChangedKeys(merged, updated); !slices.Equal(changed, []string{"RPC"}) {
+		t.Errorf("changed keys = %v, want [RPC]", changed)
+	}
+}
@@ -0,0 +1,8 @@
+package config
+
+import "drand-oracle-updater/internal/finality"
+
+// ChainFinality are the finality policies of chains overriding the built-in
+// defaults, decoded from a comma separated list of chain_id=mode[:depth]
+// entries
+type ChainFinality = finality.ChainPolicies
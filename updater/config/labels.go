@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// labelNamePattern restricts label names to what Prometheus accepts, so the
+// same labels can be used for logs, metrics and alerts
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels are key=value pairs, decoded from a comma separated list such as
+// "env=prod,region=eu-west-1"
+type Labels map[string]string
+
+// Decode implements envconfig.Decoder
+func (l *Labels) Decode(value string) error {
+	labels := Labels{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !labelNamePattern.MatchString(key) {
+			return fmt.Errorf("invalid label %q, expected key=value with a key matching %s", pair, labelNamePattern)
+		}
+		if strings.HasPrefix(key, "__") {
+			return fmt.Errorf("invalid label %q, keys starting with __ are reserved", pair)
+		}
+		labels[key] = strings.TrimSpace(val)
+	}
+	*l = labels
+	return nil
+}
+
+// String formats the labels back to their configuration format, sorted by key
+func (l Labels) String() string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + l[k]
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"drand-oracle-updater/internal/finality"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// LintSeverity is how dangerous a configuration combination is
+type LintSeverity string
+
+const (
+	// LintError refuses to start
+	LintError LintSeverity = "error"
+	// LintWarning is logged only
+	LintWarning LintSeverity = "warning"
+)
+
+// LintRule is a combination of settings that are valid on their own but
+// dangerous together
+type LintRule struct {
+	Name     string
+	Severity LintSeverity
+	// Matches reports whether the configuration has the combination
+	Matches func(c Config) bool
+	// Message explains the consequence and the fix
+	Message string
+}
+
+// LintFinding is a rule matched by a configuration
+type LintFinding struct {
+	Rule     string
+	Severity LintSeverity
+	Message  string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s", f.Rule, f.Message)
+}
+
+// LintRules are the known-bad combinations. A rule can be silenced with
+// CONFIG_LINT_IGNORE when the deployment knows better, e.g. a contract
+// accepting gaps in its rounds.
+var LintRules = []LintRule{
+	{
+		Name:     "catchup-gaps",
+		Severity: LintError,
+		Matches: func(c Config) bool {
+			return (c.CatchUpPolicy != "" && c.CatchUpPolicy != "all") || c.CatchUpMaxAge > 0
+		},
+		Message: "CATCHUP_POLICY or CATCHUP_MAX_AGE skips rounds, which the Drand Oracle contract rejects as it only accepts the round following its latest round; every catch-up transaction would revert",
+	},
+	{
+		Name:     "dry-run-leader",
+		Severity: LintError,
+		Matches: func(c Config) bool {
+			return c.DryRun && c.LeaderElection != ""
+		},
+		Message: "DRY_RUN with LEADER_ELECTION lets a dry run instance hold the lock and submit nothing while the other replicas stand by",
+	},
+	{
+		Name:     "finality-latest-reorg",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			if !strings.EqualFold(c.Finality, string(finality.ModeLatest)) {
+				return false
+			}
+			policy, err := finality.ParsePolicy(string(finality.ModeAuto), 0, c.ChainID, c.FinalityChains)
+			return err == nil && policy.Mode != finality.ModeLatest
+		},
+		Message: "FINALITY=latest considers every block committed on a chain that reorgs, so rounds may be reported committed and later reorged out; use auto, safe or finalized",
+	},
+	{
+		Name:     "batch-ignored",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			return (c.CatchUpBatchSize > 1 || c.CanaryBatchSize > 1) && (c.DryRun || c.SenderMode == "erc4337")
+		},
+		Message: "CATCHUP_BATCH_SIZE and CANARY_BATCH_SIZE are ignored by dry runs and user operations, which submit rounds one by one",
+	},
+	{
+		Name:     "pipeline-ignored",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			return c.SubmissionPipelineDepth > 1 && (c.DryRun || c.SenderMode == "erc4337")
+		},
+		Message: "SUBMISSION_PIPELINE_DEPTH is ignored by dry runs and user operations, which wait for every round",
+	},
+	{
+		Name:     "standby-without-self-test",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			return c.ColdStandby && c.SelfTestOracleAddress == ""
+		},
+		Message: "COLD_STANDBY without SELF_TEST_ORACLE_ADDRESS never proves the instance can take over",
+	},
+	{
+		Name:     "standby-dry-run",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			return c.ColdStandby && c.SelfTestOracleAddress != "" && c.DryRun
+		},
+		Message: "DRY_RUN fails every self-test of the cold standby at the submit stage, as nothing is broadcast",
+	},
+	{
+		Name:     "set-delay-without-clock-wait",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			return c.MinSetDelay > 0 && c.ChainClockMaxWait == 0
+		},
+		Message: "MIN_SET_DELAY with CHAIN_CLOCK_MAX_WAIT=0 broadcasts rounds the contract rejects while the chain clock lags behind",
+	},
+	{
+		Name:     "signer-is-sender",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			return c.SenderPrivateKey != "" && strings.TrimPrefix(c.SenderPrivateKey, "0x") == strings.TrimPrefix(c.SignerPrivateKey, "0x")
+		},
+		Message: "SENDER_PRIVATE_KEY is SIGNER_PRIVATE_KEY, so the key authorizing rounds is also the hot wallet paying for them; use separate keys",
+	},
+	{
+		Name:     "startup-wait-without-topup",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			return c.TopUpStartupWait > 0 && (strings.TrimLeft(c.TopUpFloorWei, "0") == "" || c.DryRun)
+		},
+		Message: "TOPUP_STARTUP_WAIT is ignored without a TOPUP_FLOOR_WEI or with DRY_RUN, so the sender is not funded at startup",
+	},
+	{
+		Name:     "retention-without-archive",
+		Severity: LintWarning,
+		Matches: func(c Config) bool {
+			return (c.StateRetention > 0 || c.StateRetentionRounds > 0) && c.ArchiveDir == ""
+		},
+		Message: "STATE_RETENTION or STATE_RETENTION_ROUNDS without ARCHIVE_DIR deletes the only copy of the pruned transactions, which drop out of the accounting export and GET /v1/costs",
+	},
+}
+
+// Lint returns the rules the configuration matches, except the ignored ones.
+// Ignored names that are not rules are reported, so that a typo does not
+// silently keep a rule enabled.
+func Lint(c Config) []LintFinding {
+	var findings []LintFinding
+	for _, name := range c.ConfigLintIgnore {
+		if !slices.ContainsFunc(LintRules, func(r LintRule) bool { return r.Name == name }) {
+			findings = append(findings, LintFinding{
+				Rule:     "unknown-rule",
+				Severity: LintWarning,
+				Message:  fmt.Sprintf("CONFIG_LINT_IGNORE names unknown rule %q", name),
+			})
+		}
+	}
+	for _, rule := range LintRules {
+		if slices.Contains(c.ConfigLintIgnore, rule.Name) || !rule.Matches(c) {
+			continue
+		}
+		findings = append(findings, LintFinding{Rule: rule.Name, Severity: rule.Severity, Message: rule.Message})
+	}
+	return findings
+}
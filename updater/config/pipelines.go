@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultPipeline is the name of the pipeline configured by CHAIN_HASH,
+// DRAND_ORACLE_ADDRESS and GENESIS_ROUND
+const DefaultPipeline = "default"
+
+// pipelineNamePattern keeps pipeline names usable in URL paths, directory names and metric labels
+var pipelineNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Pipeline feeds the rounds of a drand network to a Drand Oracle contract
+type Pipeline struct {
+	Name          string
+	ChainHash     string
+	OracleAddress string
+	GenesisRound  uint64
+}
+
+// Pipelines are additional pipelines, decoded from a comma separated list of
+// name=chain_hash:oracle_address:genesis_round entries
+type Pipelines []Pipeline
+
+// Decode implements envconfig.Decoder
+func (p *Pipelines) Decode(value string) error {
+	var pipelines Pipelines
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		parts := strings.Split(spec, ":")
+		if !ok || len(parts) != 3 {
+			return fmt.Errorf("invalid pipeline %q, expected name=chain_hash:oracle_address:genesis_round", entry)
+		}
+		genesisRound, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid genesis round in pipeline %q: %w", entry, err)
+		}
+		pipelines = append(pipelines, Pipeline{
+			Name:          strings.TrimSpace(name),
+			ChainHash:     parts[0],
+			OracleAddress: parts[1],
+			GenesisRound:  genesisRound,
+		})
+	}
+	*p = pipelines
+	return nil
+}
+
+// String formats the pipelines back to their configuration format
+func (p Pipelines) String() string {
+	entries := make([]string, len(p))
+	for i, pipeline := range p {
+		entries[i] = fmt.Sprintf("%s=%s:%s:%d", pipeline.Name, pipeline.ChainHash, pipeline.OracleAddress, pipeline.GenesisRound)
+	}
+	return strings.Join(entries, ",")
+}
+
+// AllPipelines returns the default pipeline followed by the extra pipelines,
+// checking that names and oracle addresses are unique
+func (c Config) AllPipelines() ([]Pipeline, error) {
+	pipelines := append([]Pipeline{{
+		Name:          DefaultPipeline,
+		ChainHash:     c.ChainHash,
+		OracleAddress: c.DrandOracleAddress,
+		GenesisRound:  c.GenesisRound,
+	}}, c.ExtraPipelines...)
+
+	names := make(map[string]bool)
+	oracles := make(map[common.Address]string)
+	for _, p := range pipelines {
+		if !pipelineNamePattern.MatchString(p.Name) {
+			return nil, fmt.Errorf("invalid pipeline name %q, expected %s", p.Name, pipelineNamePattern)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("duplicate pipeline name %q", p.Name)
+		}
+		names[p.Name] = true
+		if !common.IsHexAddress(p.OracleAddress) {
+			return nil, fmt.Errorf("invalid oracle address %q in pipeline %s", p.OracleAddress, p.Name)
+		}
+		address := common.HexToAddress(p.OracleAddress)
+		if other, ok := oracles[address]; ok {
+			return nil, fmt.Errorf("pipelines %s and %s write to the same oracle %s", other, p.Name, address.Hex())
+		}
+		oracles[address] = p.Name
+	}
+	return pipelines, nil
+}
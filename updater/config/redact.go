@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+// Redacted returns the configuration keyed by environment variable name, with
+// secrets and credential-bearing URLs redacted so it is safe to expose
+func (c Config) Redacted() map[string]string {
+	out := make(map[string]string)
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("envconfig")
+		if name == "" {
+			continue
+		}
+		out[name] = redactValue(field.Tag.Get("redact"), v.Field(i).Interface())
+	}
+	return out
+}
+
+func redactValue(mode string, value any) string {
+	switch mode {
+	case "secret":
+		if reflect.ValueOf(value).IsZero() {
+			return ""
+		}
+		return redacted
+	case "url":
+		if urls, ok := value.([]string); ok {
+			redactedURLs := make([]string, len(urls))
+			for i, u := range urls {
+				redactedURLs[i] = redactURL(u)
+			}
+			return strings.Join(redactedURLs, ",")
+		}
+		return redactURL(fmt.Sprint(value))
+	}
+	if values, ok := value.([]string); ok {
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// redactURL keeps the scheme and host of a URL, which is enough to tell
+// endpoints apart, and hides user info, path and query which often carry API keys
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redacted
+	}
+	if u.User == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" {
+		return u.String()
+	}
+	return fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, redacted)
+}
@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// RelayConfig configures the relay subcommand, which sets the rounds of a
+// drand network on a non-EVM chain. Each chain family has its own section.
+type RelayConfig struct {
+	RelayChain       string       `envconfig:"RELAY_CHAIN" required:"true"`
+	DrandURLs        []string     `envconfig:"DRAND_URLS" required:"true" redact:"url"`
+	ChainHash        string       `envconfig:"CHAIN_HASH" required:"true"`
+	GenesisRound     uint64       `envconfig:"GENESIS_ROUND" required:"true"`
+	MaxRetries       int          `envconfig:"MAX_RETRIES" default:"10"`
+	MetricsPort      int          `envconfig:"METRICS_PORT" default:"4014"`
+	DeploymentLabels Labels       `envconfig:"DEPLOYMENT_LABELS"`
+	Solana           SolanaConfig `envconfig:"SOLANA"`
+}
+
+// SolanaConfig is the Solana section of the relay configuration, read from
+// the SOLANA_ variables
+type SolanaConfig struct {
+	RPC            string        `envconfig:"RPC" redact:"url"`
+	ProgramID      string        `envconfig:"PROGRAM_ID"`
+	OracleAccount  string        `envconfig:"ORACLE_ACCOUNT"`
+	PrivateKey     string        `envconfig:"PRIVATE_KEY" redact:"secret"`
+	Commitment     string        `envconfig:"COMMITMENT" default:"confirmed"`
+	ConfirmTimeout time.Duration `envconfig:"CONFIRM_TIMEOUT" default:"90s"`
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ignoredKeys are common variables sharing a prefix with the configuration
+var ignoredKeys = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+
+// maxSuggestionDistance bounds the edit distance of a suggested variable
+const maxSuggestionDistance = 3
+
+// Variable describes a configuration variable
+type Variable struct {
+	Name     string
+	Type     string
+	Default  string
+	Required bool
+	Secret   bool
+}
+
+// Schema returns the configuration variables sorted by name
+func Schema() []Variable {
+	var variables []Variable
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("envconfig")
+		if name == "" {
+			continue
+		}
+		variables = append(variables, Variable{
+			Name:     name,
+			Type:     typeName(field.Type),
+			Default:  field.Tag.Get("default"),
+			Required: field.Tag.Get("required") == "true",
+			Secret:   field.Tag.Get("redact") == "secret",
+		})
+	}
+	variables = append(variables, Variable{Name: ConfigFileEnv, Type: "path"})
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return variables
+}
+
+// typeName is the type of a variable as written in the environment
+func typeName(t reflect.Type) string {
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return "duration"
+	case reflect.TypeOf(Labels{}):
+		return "key=value list"
+	case reflect.TypeOf(Pipelines{}):
+		return "pipeline list"
+	case reflect.TypeOf(ChainFinality{}):
+		return "chain_id=mode list"
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		return typeName(t.Elem()) + " list"
+	case reflect.Int, reflect.Int64:
+		return "int"
+	case reflect.Uint64:
+		return "uint"
+	case reflect.Float64:
+		return "float"
+	}
+	return t.Kind().String()
+}
+
+// UnknownVariable is a variable looking like a configuration variable that
+// the configuration does not have, typically a typo
+type UnknownVariable struct {
+	Name string
+	// Suggestion is the closest configuration variable, empty if none is close
+	Suggestion string
+}
+
+func (u UnknownVariable) String() string {
+	if u.Suggestion == "" {
+		return u.Name
+	}
+	return fmt.Sprintf("%s (did you mean %s?)", u.Name, u.Suggestion)
+}
+
+// UnknownVariables returns the variables of environ, in os.Environ form, that
+// start like a configuration variable, such as SENDER_ or GAS_, but are not
+// one. Kubernetes service links, such as ORACLE_SERVICE_HOST, are ignored.
+func UnknownVariables(environ []string) []UnknownVariable {
+	known := knownKeys()
+	prefixes := make(map[string]bool)
+	for _, key := range known {
+		prefix, _, _ := strings.Cut(key, "_")
+		prefixes[prefix] = true
+	}
+	set := make(map[string]bool, len(environ))
+	for _, pair := range environ {
+		key, _, _ := strings.Cut(pair, "=")
+		set[key] = true
+	}
+
+	var unknown []UnknownVariable
+	for key := range set {
+		prefix, _, _ := strings.Cut(key, "_")
+		if !prefixes[prefix] || slices.Contains(known, key) || slices.Contains(ignoredKeys, key) || serviceLink(key, set) {
+			continue
+		}
+		unknown = append(unknown, UnknownVariable{Name: key, Suggestion: suggest(key, known)})
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Name < unknown[j].Name })
+	return unknown
+}
+
+// unknownFileKeys returns the keys of a configuration file that are not
+// configuration variables, which unlike the environment holds nothing else
+func unknownFileKeys(values map[string]string) []UnknownVariable {
+	known := knownKeys()
+	var unknown []UnknownVariable
+	for key := range values {
+		if !slices.Contains(known, key) {
+			unknown = append(unknown, UnknownVariable{Name: key, Suggestion: suggest(key, known)})
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Name < unknown[j].Name })
+	return unknown
+}
+
+func knownKeys() []string {
+	var keys []string
+	for _, variable := range Schema() {
+		keys = append(keys, variable.Name)
+	}
+	return keys
+}
+
+// serviceLink reports whether a variable is set by Kubernetes for a service,
+// which comes with its <SERVICE>_SERVICE_HOST variable
+func serviceLink(key string, set map[string]bool) bool {
+	for _, marker := range []string{"_SERVICE_", "_PORT"} {
+		if i := strings.Index(key, marker); i > 0 && set[key[:i]+"_SERVICE_HOST"] {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns the known key closest to key, empty if none is close enough
+func suggest(key string, known []string) string {
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, candidate := range known {
+		if d := distance(key, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance of two strings
+func distance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// unknownError reports unknown variables in strict mode
+func unknownError(unknown []UnknownVariable) error {
+	names := make([]string, len(unknown))
+	for i, u := range unknown {
+		names[i] = u.String()
+	}
+	return fmt.Errorf("unknown configuration variables %s, remove them or disable STRICT_CONFIG", strings.Join(names, ", "))
+}
@@ -0,0 +1,228 @@
+// Package drandoracle reads drand randomness from a Drand Oracle contract,
+// verifying every round against the drand network's public key, so that Go
+// services can consume the oracle without trusting the updater that set it.
+//
+//	info, _ := drandClient.Info(ctx)
+//	oracle, err := drandoracle.NewClient(ctx, oracleAddress, ethClient, info, drandoracle.Options{})
+//	round, err := oracle.WaitForRound(ctx, 4200001)
+//	fmt.Printf("%x\n", round.Randomness)
+package drandoracle
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/binding"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/crypto"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// DefaultPollInterval is the default interval between reads of the latest
+// round while waiting for a round
+const DefaultPollInterval = 2 * time.Second
+
+var (
+	// ErrRoundNotFound is returned for a round the oracle does not store
+	ErrRoundNotFound = errors.New("round not found on the oracle")
+	// ErrInvalidRandomness is returned when a round's randomness is not the
+	// hash of its signature
+	ErrInvalidRandomness = errors.New("randomness does not match the signature")
+	// ErrInvalidSignature is returned when a round's signature does not verify
+	// against the drand network's public key
+	ErrInvalidSignature = errors.New("signature is invalid for the drand network")
+	// ErrUnverified is returned by RequireVerified clients for rounds whose
+	// signature cannot be verified
+	ErrUnverified = errors.New("round cannot be verified")
+)
+
+// Round is a round stored in the oracle
+type Round struct {
+	Round      uint64
+	Timestamp  uint64
+	Randomness [32]byte
+	Signature  []byte
+	// Verified reports whether the signature was verified against the drand
+	// network's public key. Chained schemes sign over the previous round's
+	// signature, so their rounds whose previous round is not stored on the
+	// oracle cannot be verified. The randomness is always checked against
+	// the signature.
+	Verified bool
+}
+
+// Time returns the drand time of the round
+func (r *Round) Time() time.Time {
+	return time.Unix(int64(r.Timestamp), 0)
+}
+
+// Options configures a client
+type Options struct {
+	// PollInterval is the interval between reads of the latest round while
+	// waiting for a round, DefaultPollInterval when 0
+	PollInterval time.Duration
+	// RequireVerified rejects the rounds that cannot be verified with
+	// ErrUnverified rather than returning them with Verified unset
+	RequireVerified bool
+}
+
+// Client reads and verifies the rounds of a Drand Oracle contract
+type Client struct {
+	oracle  *binding.Binding
+	address common.Address
+	info    *chain.Info
+	scheme  *crypto.Scheme
+	options Options
+}
+
+// NewClient returns a client of the oracle at address, serving the drand
+// network described by info, as returned by a drand client's Info. It fails
+// when the oracle serves another network.
+func NewClient(ctx context.Context, address common.Address, backend bind.ContractBackend, info *chain.Info, options Options) (*Client, error) {
+	if info == nil {
+		return nil, errors.New("drand chain info is required to verify rounds")
+	}
+	scheme, err := crypto.SchemeFromName(info.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	oracle, err := binding.NewBinding(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	chainHash, err := oracle.CHAINHASH(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("error getting chain hash of oracle %s: %w", address.Hex(), err)
+	}
+	if !bytes.Equal(chainHash[:], info.Hash()) {
+		return nil, fmt.Errorf("oracle %s serves chain %x, expected %s", address.Hex(), chainHash, info.HashString())
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	return &Client{oracle: oracle, address: address, info: info, scheme: scheme, options: options}, nil
+}
+
+// Address returns the address of the oracle
+func (c *Client) Address() common.Address {
+	return c.address
+}
+
+// LatestRound returns the latest round set on the oracle, 0 before the first
+func (c *Client) LatestRound(ctx context.Context) (uint64, error) {
+	return c.oracle.LatestRound(&bind.CallOpts{Context: ctx})
+}
+
+// RandomnessAt returns a verified round
+func (c *Client) RandomnessAt(ctx context.Context, round uint64) (*Round, error) {
+	return c.randomnessAt(&bind.CallOpts{Context: ctx}, round)
+}
+
+func (c *Client) randomnessAt(opts *bind.CallOpts, round uint64) (*Round, error) {
+	random, err := c.oracle.GetRandomnessFromRound(opts, round)
+	if err != nil {
+		return nil, fmt.Errorf("error getting round %d: %w", round, err)
+	}
+	if random.Round == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrRoundNotFound, round)
+	}
+	r := &Round{
+		Round:      random.Round,
+		Timestamp:  random.Timestamp,
+		Randomness: random.Randomness,
+		Signature:  random.Signature,
+	}
+	if err := c.verify(opts, r); err != nil {
+		return nil, fmt.Errorf("round %d: %w", round, err)
+	}
+	return r, nil
+}
+
+// verify checks the randomness against the signature, and the signature
+// against the drand network's public key. The previous signature of chained
+// schemes is read from the oracle at the same block.
+func (c *Client) verify(opts *bind.CallOpts, r *Round) error {
+	if !bytes.Equal(crypto.RandomnessFromSignature(r.Signature), r.Randomness[:]) {
+		return ErrInvalidRandomness
+	}
+
+	beacon := &chain.Beacon{Round: r.Round, Signature: r.Signature}
+	if c.scheme.Name == crypto.DefaultSchemeID && r.Round > 1 {
+		previous, err := c.oracle.GetRandomnessFromRound(opts, r.Round-1)
+		if err != nil || previous.Round == 0 {
+			// The previous round is pruned or was never set
+			if c.options.RequireVerified {
+				return ErrUnverified
+			}
+			return nil
+		}
+		beacon.PreviousSig = previous.Signature
+	}
+	if err := c.scheme.VerifyBeacon(beacon, c.info.PublicKey); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	r.Verified = true
+	return nil
+}
+
+// WaitForRound returns a verified round once it is set on the oracle, polling
+// the latest round until then. A round the oracle skipped returns
+// ErrRoundNotFound once a later round is set.
+func (c *Client) WaitForRound(ctx context.Context, round uint64) (*Round, error) {
+	ticker := time.NewTicker(c.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		latest, err := c.LatestRound(ctx)
+		if err == nil && latest >= round {
+			return c.RandomnessAt(ctx, round)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// SubscribeRounds sends the verified rounds set on the oracle from now on to
+// sink. It requires a backend supporting subscriptions, such as a WebSocket
+// client. The subscription fails with the error of a round failing
+// verification, which means the oracle's signer or the drand network is
+// compromised.
+func (c *Client) SubscribeRounds(ctx context.Context, sink chan<- *Round) (event.Subscription, error) {
+	events := make(chan *binding.BindingRandomnessUpdated)
+	sub, err := c.oracle.WatchRandomnessUpdated(&bind.WatchOpts{Context: ctx}, events)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case e := <-events:
+				// Verify at the event's block, where the previous round is
+				// stored even if pruned since
+				opts := &bind.CallOpts{Context: ctx, BlockHash: e.Raw.BlockHash}
+				r, err := c.randomnessAt(opts, e.Round)
+				if err != nil {
+					return err
+				}
+				select {
+				case sink <- r:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
@@ -0,0 +1,126 @@
+package gas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// weiPerGwei converts the gwei prices returned by gas APIs to wei
+var weiPerGwei = big.NewFloat(1_000_000_000)
+
+// APIStrategy reads the gas price from an external gas API such as Etherscan's
+// gas tracker or Blocknative. The price, in gwei, is looked up in the JSON
+// response with a dot separated path where numeric segments index arrays, e.g.
+// "result.ProposeGasPrice" or "blockPrices.0.estimatedPrices.0.price".
+type APIStrategy struct {
+	url        string
+	apiKey     string
+	field      []string
+	httpClient *http.Client
+}
+
+// NewAPIStrategy creates an external gas API strategy. A non empty apiKey is
+// sent as the Authorization header.
+func NewAPIStrategy(url, apiKey, field string) (*APIStrategy, error) {
+	if url == "" {
+		return nil, fmt.Errorf("gas API URL is not set")
+	}
+	if field == "" {
+		return nil, fmt.Errorf("gas API field is not set")
+	}
+	return &APIStrategy{
+		url:        url,
+		apiKey:     apiKey,
+		field:      strings.Split(field, "."),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (s *APIStrategy) Name() string {
+	return StrategyAPI
+}
+
+func (s *APIStrategy) GasPrice(ctx context.Context) (*big.Int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", s.apiKey)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("gas API returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var body any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error decoding gas API response: %w", err)
+	}
+	value, err := lookup(body, s.field)
+	if err != nil {
+		return nil, err
+	}
+	gwei, err := parseGwei(value)
+	if err != nil {
+		return nil, err
+	}
+	wei, _ := new(big.Float).Mul(gwei, weiPerGwei).Int(nil)
+	return wei, nil
+}
+
+// lookup walks a decoded JSON value along path
+func lookup(value any, path []string) (any, error) {
+	for i, segment := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[segment]
+			if !ok {
+				return nil, fmt.Errorf("gas API response has no field %q", strings.Join(path[:i+1], "."))
+			}
+			value = next
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("gas API response has no element %q", strings.Join(path[:i+1], "."))
+			}
+			value = v[index]
+		default:
+			return nil, fmt.Errorf("gas API response field %q is not an object or array", strings.Join(path[:i], "."))
+		}
+	}
+	return value, nil
+}
+
+// parseGwei accepts prices encoded as JSON numbers or decimal strings
+func parseGwei(value any) (*big.Float, error) {
+	var gwei *big.Float
+	switch v := value.(type) {
+	case float64:
+		gwei = big.NewFloat(v)
+	case string:
+		f, ok := new(big.Float).SetString(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid gas price %q", v)
+		}
+		gwei = f
+	default:
+		return nil, fmt.Errorf("unexpected gas price type %T", value)
+	}
+	if gwei.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid gas price %s gwei", gwei.String())
+	}
+	return gwei, nil
+}
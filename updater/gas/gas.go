@@ -0,0 +1,124 @@
+package gas
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Strategy names
+const (
+	StrategyRPC        = "rpc"
+	StrategyPercentile = "percentile"
+	StrategyAPI        = "api"
+)
+
+// Strategy prices SetRandomness transactions
+type Strategy interface {
+	// Name returns the strategy name used in logs and metrics
+	Name() string
+	// GasPrice returns the gas price in wei to use for the next transaction
+	GasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// chainDefaults are the default strategies of chains where the RPC suggested
+// gas price is known to be a poor fit. Other chains default to StrategyRPC.
+var chainDefaults = map[int64]string{
+	1:        StrategyPercentile, // Ethereum
+	11155111: StrategyPercentile, // Sepolia
+	17000:    StrategyPercentile, // Holesky
+}
+
+// DefaultStrategy returns the default strategy name for a chain
+func DefaultStrategy(chainID int64) string {
+	if name, ok := chainDefaults[chainID]; ok {
+		return name
+	}
+	return StrategyRPC
+}
+
+// Bounded scales the gas price of a strategy by a multiplier and caps it at a
+// maximum. A zero or nil maximum disables the cap.
+type Bounded struct {
+	Strategy
+
+	mu         sync.RWMutex
+	multiplier *big.Float
+	max        *big.Int
+}
+
+// NewBounded wraps a strategy with a multiplier and an optional maximum
+func NewBounded(strategy Strategy, multiplier float64, max *big.Int) (*Bounded, error) {
+	if multiplier <= 0 {
+		return nil, fmt.Errorf("gas price multiplier must be positive, got %v", multiplier)
+	}
+	return &Bounded{
+		Strategy:   strategy,
+		multiplier: big.NewFloat(multiplier),
+		max:        max,
+	}, nil
+}
+
+// SetBounds replaces the multiplier and the maximum of a running strategy
+func (b *Bounded) SetBounds(multiplier float64, max *big.Int) error {
+	if multiplier <= 0 {
+		return fmt.Errorf("gas price multiplier must be positive, got %v", multiplier)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.multiplier = big.NewFloat(multiplier)
+	b.max = max
+	return nil
+}
+
+func (b *Bounded) GasPrice(ctx context.Context) (*big.Int, error) {
+	gasPrice, err := b.Strategy.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	multiplier, max := b.multiplier, b.max
+	b.mu.RUnlock()
+
+	gasPrice, _ = new(big.Float).Mul(new(big.Float).SetInt(gasPrice), multiplier).Int(nil)
+	if max != nil && max.Sign() > 0 && gasPrice.Cmp(max) > 0 {
+		log.Warn().
+			Str("strategy", b.Name()).
+			Str("gas_price", gasPrice.String()).
+			Str("max_gas_price", max.String()).
+			Msg("Gas price capped at the configured maximum")
+		gasPrice = new(big.Int).Set(max)
+	}
+	return gasPrice, nil
+}
+
+// Fallback uses a secondary strategy when the primary one fails
+type Fallback struct {
+	primary   Strategy
+	secondary Strategy
+}
+
+// NewFallback returns a strategy trying primary first and secondary on error
+func NewFallback(primary, secondary Strategy) *Fallback {
+	return &Fallback{primary: primary, secondary: secondary}
+}
+
+func (f *Fallback) Name() string {
+	return f.primary.Name()
+}
+
+func (f *Fallback) GasPrice(ctx context.Context) (*big.Int, error) {
+	gasPrice, err := f.primary.GasPrice(ctx)
+	if err == nil {
+		return gasPrice, nil
+	}
+	log.Warn().
+		Err(err).
+		Str("strategy", f.primary.Name()).
+		Str("fallback", f.secondary.Name()).
+		Msg("Gas price strategy failed, using fallback")
+	return f.secondary.GasPrice(ctx)
+}
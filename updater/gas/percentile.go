@@ -0,0 +1,62 @@
+package gas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// PercentileStrategy prices transactions from the priority fees paid in recent
+// blocks (eth_feeHistory). The gas price is the base fee of the next block plus
+// the median, across blocks, of the configured priority fee percentile.
+type PercentileStrategy struct {
+	rpcClient  *ethclient.Client
+	blocks     uint64
+	percentile float64
+}
+
+func NewPercentileStrategy(rpcClient *ethclient.Client, blocks uint64, percentile float64) (*PercentileStrategy, error) {
+	if blocks == 0 {
+		return nil, errors.New("percentile gas strategy needs at least one block")
+	}
+	if percentile < 0 || percentile > 100 {
+		return nil, fmt.Errorf("gas percentile must be within [0, 100], got %v", percentile)
+	}
+	return &PercentileStrategy{
+		rpcClient:  rpcClient,
+		blocks:     blocks,
+		percentile: percentile,
+	}, nil
+}
+
+func (s *PercentileStrategy) Name() string {
+	return StrategyPercentile
+}
+
+func (s *PercentileStrategy) GasPrice(ctx context.Context) (*big.Int, error) {
+	history, err := s.rpcClient.FeeHistory(ctx, s.blocks, nil, []float64{s.percentile})
+	if err != nil {
+		return nil, err
+	}
+	if len(history.BaseFee) == 0 {
+		return nil, errors.New("fee history has no base fee, the chain may not support EIP-1559")
+	}
+	// The last base fee is the one of the next block
+	baseFee := history.BaseFee[len(history.BaseFee)-1]
+
+	var tips []*big.Int
+	for _, reward := range history.Reward {
+		if len(reward) > 0 && reward[0] != nil {
+			tips = append(tips, reward[0])
+		}
+	}
+	if len(tips) == 0 {
+		return new(big.Int).Set(baseFee), nil
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+	return new(big.Int).Add(baseFee, tips[len(tips)/2]), nil
+}
@@ -0,0 +1,25 @@
+package gas
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// RPCStrategy uses the gas price suggested by the RPC node (eth_gasPrice)
+type RPCStrategy struct {
+	rpcClient *ethclient.Client
+}
+
+func NewRPCStrategy(rpcClient *ethclient.Client) *RPCStrategy {
+	return &RPCStrategy{rpcClient: rpcClient}
+}
+
+func (s *RPCStrategy) Name() string {
+	return StrategyRPC
+}
+
+func (s *RPCStrategy) GasPrice(ctx context.Context) (*big.Int, error) {
+	return s.rpcClient.SuggestGasPrice(ctx)
+}
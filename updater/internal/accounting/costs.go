@@ -0,0 +1,146 @@
+package accounting
+
+import (
+	"drand-oracle-updater/internal/store"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Costs is the aggregate cost of a set of transactions
+type Costs struct {
+	Transactions int `json:"transactions"`
+	// Failed is the number of reverted transactions, which cost gas too
+	Failed int `json:"failed"`
+	// Rounds is the number of rounds set by successful transactions
+	Rounds  int    `json:"rounds"`
+	GasUsed uint64 `json:"gas_used"`
+	// FeeWei is the gas fee of every transaction, FailedFeeWei the part of it
+	// spent on failed transactions
+	FeeWei       string `json:"fee_wei"`
+	FailedFeeWei string `json:"failed_fee_wei"`
+	// ValueWei is the submission fee paid as msg.value
+	ValueWei     string `json:"value_wei"`
+	TotalCostWei string `json:"total_cost_wei"`
+	// CostPerRoundWei is the total cost divided by the rounds set
+	CostPerRoundWei string `json:"cost_per_round_wei"`
+}
+
+// DailyCosts is the cost of the transactions of a UTC day
+type DailyCosts struct {
+	Date string `json:"date"`
+	Costs
+}
+
+// CostReport is the cost of the transactions of a period, with its daily
+// breakdown when requested
+type CostReport struct {
+	From  *time.Time   `json:"from,omitempty"`
+	To    *time.Time   `json:"to,omitempty"`
+	Total Costs        `json:"total"`
+	Days  []DailyCosts `json:"days,omitempty"`
+	// Annotations are the operational notes covering any of the transactions
+	Annotations []store.Annotation `json:"annotations,omitempty"`
+}
+
+// costs accumulates transactions
+type costs struct {
+	transactions, failed, rounds int
+	gasUsed                      uint64
+	fee, failedFee, value        *big.Int
+}
+
+func newCosts() *costs {
+	return &costs{fee: new(big.Int), failedFee: new(big.Int), value: new(big.Int)}
+}
+
+func (c *costs) add(tx store.Transaction) {
+	fee, ok := new(big.Int).SetString(tx.Fee, 10)
+	if !ok {
+		fee = new(big.Int)
+	}
+	c.transactions++
+	c.gasUsed += tx.GasUsed
+	c.fee.Add(c.fee, fee)
+	c.value.Add(c.value, valueWei(tx))
+	if tx.Status == types.ReceiptStatusSuccessful {
+		// A batch transaction sets BatchSize rounds
+		c.rounds += max(tx.BatchSize, 1)
+	} else {
+		c.failed++
+		c.failedFee.Add(c.failedFee, fee)
+	}
+}
+
+func (c *costs) summary() Costs {
+	total := new(big.Int).Add(c.fee, c.value)
+	perRound := new(big.Int)
+	if c.rounds > 0 {
+		perRound.Div(total, big.NewInt(int64(c.rounds)))
+	}
+	return Costs{
+		Transactions:    c.transactions,
+		Failed:          c.failed,
+		Rounds:          c.rounds,
+		GasUsed:         c.gasUsed,
+		FeeWei:          c.fee.String(),
+		FailedFeeWei:    c.failedFee.String(),
+		ValueWei:        c.value.String(),
+		TotalCostWei:    total.String(),
+		CostPerRoundWei: perRound.String(),
+	}
+}
+
+// Summarize aggregates the cost of transactions recorded in [from, to), a zero
+// bound leaving that side open, broken down by UTC day when daily is set. The
+// annotations covering the transactions are attached to the report.
+func Summarize(txs []store.Transaction, from, to time.Time, daily bool, annotations []store.Annotation) CostReport {
+	var report CostReport
+	if !from.IsZero() {
+		report.From = &from
+	}
+	if !to.IsZero() {
+		report.To = &to
+	}
+
+	total := newCosts()
+	var days []string
+	byDay := make(map[string]*costs)
+	attached := make(map[string]bool)
+	for _, tx := range txs {
+		total.add(tx)
+		for _, a := range store.Covering(annotations, tx.Round, tx.Timestamp) {
+			if !attached[a.ID] {
+				attached[a.ID] = true
+				report.Annotations = append(report.Annotations, a)
+			}
+		}
+		if !daily {
+			continue
+		}
+		day := tx.Timestamp.UTC().Format(time.DateOnly)
+		if byDay[day] == nil {
+			byDay[day] = newCosts()
+			days = append(days, day)
+		}
+		byDay[day].add(tx)
+	}
+	report.Total = total.summary()
+	// Transactions are recorded in order, so are the days
+	for _, day := range days {
+		report.Days = append(report.Days, DailyCosts{Date: day, Costs: byDay[day].summary()})
+	}
+	return report
+}
+
+// ParseDate parses a date as either YYYY-MM-DD or RFC3339. An empty string yields the zero time.
+func ParseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,68 @@
+package accounting
+
+import (
+	"drand-oracle-updater/internal/store"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// day is the UTC midnight of a test day
+func day(d int) time.Time {
+	return time.Date(2024, 11, d, 0, 0, 0, 0, time.UTC)
+}
+
+// testTransactions are the transactions of two days: a round, a reverted
+// round and a batch of 3 rounds, all paying a submission fee
+var testTransactions = []store.Transaction{
+	{Timestamp: day(1).Add(time.Hour), Round: 1, GasUsed: 100, Fee: "1000", Value: "10", Status: types.ReceiptStatusSuccessful},
+	{Timestamp: day(1).Add(2 * time.Hour), Round: 2, GasUsed: 50, Fee: "500", Value: "10", Status: types.ReceiptStatusFailed},
+	{Timestamp: day(2).Add(time.Hour), Round: 2, GasUsed: 200, Fee: "2000", Value: "30", Status: types.ReceiptStatusSuccessful, BatchSize: 3},
+}
+
+func TestSummarize(t *testing.T) {
+	report := Summarize(testTransactions, day(1), day(3), true, nil)
+
+	want := Costs{
+		Transactions: 3,
+		Failed:       1,
+		Rounds:       4,
+		GasUsed:      350,
+		FeeWei:       "3500",
+		FailedFeeWei: "500",
+		// The reverted transaction's submission fee was refunded
+		ValueWei:        "40",
+		TotalCostWei:    "3540",
+		CostPerRoundWei: "885",
+	}
+	if report.Total != want {
+		t.Errorf("total = %+v, want %+v", report.Total, want)
+	}
+	if report.From == nil || !report.From.Equal(day(1)) || report.To == nil || !report.To.Equal(day(3)) {
+		t.Errorf("period = %v to %v, want %v to %v", report.From, report.To, day(1), day(3))
+	}
+
+	wantDays := []DailyCosts{
+		{Date: "2024-11-01", Costs: Costs{Transactions: 2, Failed: 1, Rounds: 1, GasUsed: 150, FeeWei: "1500", FailedFeeWei: "500", ValueWei: "10", TotalCostWei: "1510", CostPerRoundWei: "1510"}},
+		{Date: "2024-11-02", Costs: Costs{Transactions: 1, Rounds: 3, GasUsed: 200, FeeWei: "2000", FailedFeeWei: "0", ValueWei: "30", TotalCostWei: "2030", CostPerRoundWei: "676"}},
+	}
+	if len(report.Days) != len(wantDays) {
+		t.Fatalf("%d days, want %d", len(report.Days), len(wantDays))
+	}
+	for i, want := range wantDays {
+		if report.Days[i] != want {
+			t.Errorf("day %d = %+v, want %+v", i, report.Days[i], want)
+		}
+	}
+}
+
+func TestSummarizeNoRounds(t *testing.T) {
+	report := Summarize(testTransactions[1:2], time.Time{}, time.Time{}, false, nil)
+	if report.Total.CostPerRoundWei != "0" || report.Total.TotalCostWei != "500" {
+		t.Errorf("total = %+v, want a cost of 500 and no cost per round", report.Total)
+	}
+	if report.From != nil || report.To != nil || report.Days != nil {
+		t.Errorf("report = %+v, want an open period without days", report)
+	}
+}
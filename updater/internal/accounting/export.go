@@ -0,0 +1,188 @@
+package accounting
+
+import (
+	"drand-oracle-updater/internal/store"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// weiPerEther is used to convert wei amounts to the chain's native unit
+var weiPerEther = new(big.Float).SetInt(big.NewInt(1_000_000_000_000_000_000))
+
+// fieldValues maps every exportable field name to its value extractor
+var fieldValues = map[string]func(tx store.Transaction, opts Options) string{
+	"timestamp":     func(tx store.Transaction, _ Options) string { return tx.Timestamp.UTC().Format(time.RFC3339) },
+	"date":          func(tx store.Transaction, _ Options) string { return tx.Timestamp.UTC().Format(time.DateOnly) },
+	"chain_id":      func(tx store.Transaction, _ Options) string { return strconv.FormatInt(tx.ChainID, 10) },
+	"round":         func(tx store.Transaction, _ Options) string { return strconv.FormatUint(tx.Round, 10) },
+	"tx_hash":       func(tx store.Transaction, _ Options) string { return tx.TxHash },
+	"source":        func(tx store.Transaction, _ Options) string { return tx.Source },
+	"from":          func(tx store.Transaction, _ Options) string { return tx.From },
+	"nonce":         func(tx store.Transaction, _ Options) string { return strconv.FormatUint(tx.Nonce, 10) },
+	"block_number":  func(tx store.Transaction, _ Options) string { return strconv.FormatUint(tx.BlockNumber, 10) },
+	"status":        func(tx store.Transaction, _ Options) string { return strconv.FormatUint(tx.Status, 10) },
+	"gas_limit":     func(tx store.Transaction, _ Options) string { return strconv.FormatUint(tx.GasLimit, 10) },
+	"gas_used":      func(tx store.Transaction, _ Options) string { return strconv.FormatUint(tx.GasUsed, 10) },
+	"gas_price_wei": func(tx store.Transaction, _ Options) string { return tx.EffectiveGasPrice },
+	"fee_wei":       func(tx store.Transaction, _ Options) string { return tx.Fee },
+	"value_wei":     func(tx store.Transaction, _ Options) string { return valueWei(tx).String() },
+	"total_cost_wei": func(tx store.Transaction, _ Options) string {
+		total, ok := totalCostWei(tx)
+		if !ok {
+			return ""
+		}
+		return total.String()
+	},
+	"fee_native": func(tx store.Transaction, _ Options) string {
+		fee, ok := feeNative(tx)
+		if !ok {
+			return ""
+		}
+		return fee.Text('f', 18)
+	},
+	"fee_usd": func(tx store.Transaction, opts Options) string {
+		fee, ok := feeNative(tx)
+		if !ok || opts.USDPrice == nil {
+			return ""
+		}
+		return new(big.Float).Mul(fee, opts.USDPrice).Text('f', 6)
+	},
+	"annotations": func(tx store.Transaction, opts Options) string {
+		var notes []string
+		for _, a := range store.Covering(opts.Annotations, tx.Round, tx.Timestamp) {
+			notes = append(notes, a.Note)
+		}
+		return strings.Join(notes, "; ")
+	},
+}
+
+// DefaultFields are the fields exported when none are configured
+var DefaultFields = []string{"timestamp", "tx_hash", "round", "gas_used", "fee_wei", "fee_usd", "annotations"}
+
+// Options configures an accounting export
+type Options struct {
+	Format Format
+	Fields []string
+	// USDPrice is the price of one unit of the native token in USD. When nil
+	// the fee_usd field is left empty.
+	USDPrice *big.Float
+	// Annotations fill the annotations field with the notes covering each
+	// transaction's round or timestamp
+	Annotations []store.Annotation
+}
+
+// ParseFields parses a comma separated list of field names
+func ParseFields(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultFields, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if _, ok := fieldValues[field]; !ok {
+			return nil, fmt.Errorf("unknown export field %q", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Export writes the transactions to w in the configured format
+func Export(w io.Writer, txs []store.Transaction, opts Options) error {
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	for _, field := range fields {
+		if _, ok := fieldValues[field]; !ok {
+			return fmt.Errorf("unknown export field %q", field)
+		}
+	}
+
+	switch opts.Format {
+	case FormatCSV, "":
+		return exportCSV(w, txs, fields, opts)
+	case FormatJSON:
+		return exportJSON(w, txs, fields, opts)
+	default:
+		return fmt.Errorf("unsupported export format %q", opts.Format)
+	}
+}
+
+func exportCSV(w io.Writer, txs []store.Transaction, fields []string, opts Options) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(fields); err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		row := make([]string, len(fields))
+		for i, field := range fields {
+			row[i] = fieldValues[field](tx, opts)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportJSON(w io.Writer, txs []store.Transaction, fields []string, opts Options) error {
+	rows := make([]map[string]string, 0, len(txs))
+	for _, tx := range txs {
+		row := make(map[string]string, len(fields))
+		for _, field := range fields {
+			row[field] = fieldValues[field](tx, opts)
+		}
+		rows = append(rows, row)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// feeNative returns the total cost of a transaction, gas fee plus submission fee, in the native unit
+func feeNative(tx store.Transaction) (*big.Float, bool) {
+	total, ok := totalCostWei(tx)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(total), weiPerEther), true
+}
+
+// totalCostWei returns the gas fee plus the submission fee paid as msg.value
+func totalCostWei(tx store.Transaction) (*big.Int, bool) {
+	fee, ok := new(big.Int).SetString(tx.Fee, 10)
+	if !ok {
+		return nil, false
+	}
+	return fee.Add(fee, valueWei(tx)), true
+}
+
+// valueWei returns the submission fee paid as msg.value, 0 for a reverted
+// transaction, whose value was refunded
+func valueWei(tx store.Transaction) *big.Int {
+	if tx.Status != types.ReceiptStatusSuccessful {
+		return new(big.Int)
+	}
+	value, ok := new(big.Int).SetString(tx.Value, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return value
+}
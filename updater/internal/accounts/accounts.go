@@ -0,0 +1,323 @@
+// Package accounts polls the balances and nonces of every account the updater
+// manages, such as the sender shared by the pipelines, the staged next sender
+// key, the top-up treasury and the smart account, with a single JSON-RPC batch
+// per interval. Pipelines subscribe to the results rather than each polling
+// its own accounts.
+package accounts
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultInterval is the delay between polls when none is configured
+const DefaultInterval = time.Minute
+
+const (
+	labelAccount = "account"
+	labelAddress = "address"
+	labelBlock   = "block"
+	labelResult  = "result"
+)
+
+// BatchCaller sends JSON-RPC batches, as the rpc.Client of an ethclient does
+type BatchCaller interface {
+	BatchCallContext(ctx context.Context, b []rpc.BatchElem) error
+}
+
+// Options configures a poller
+type Options struct {
+	// Interval is the delay between polls, DefaultInterval when 0. Subscribers
+	// may stretch it, see Subscribe.
+	Interval time.Duration
+	// MetricLabels are attached to every metric of the poller
+	MetricLabels map[string]string
+}
+
+// Account is the latest known state of a managed account
+type Account struct {
+	// Name is the role of the account, such as sender or treasury
+	Name    string
+	Address common.Address
+	// Balance is nil until the account was polled successfully
+	Balance *big.Int
+	// PendingNonce is the nonce at the pending block, counting the
+	// transactions of the node's mempool
+	PendingNonce uint64
+	// LatestNonce is the nonce at the latest block, the number of mined
+	// transactions
+	LatestNonce uint64
+	// UpdatedAt is the time of the latest successful poll of the account
+	UpdatedAt time.Time
+	// Err is the error of the latest poll, nil when it succeeded
+	Err error
+}
+
+// Snapshot is the state of the managed accounts after a poll
+type Snapshot struct {
+	At       time.Time
+	Accounts []Account
+}
+
+// Account returns the state of the account with the given address, false
+// when it is not managed
+func (s Snapshot) Account(address common.Address) (Account, bool) {
+	for _, account := range s.Accounts {
+		if account.Address == address {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// Subscription receives the snapshots of a poller
+type Subscription struct {
+	poller *Poller
+	c      chan Snapshot
+	// interval returns the delay between polls the subscriber needs, nil for
+	// the poller's interval
+	interval func() time.Duration
+}
+
+// C returns the channel of the snapshots. A snapshot not read before the next
+// poll is replaced by the newer one.
+func (s *Subscription) C() <-chan Snapshot {
+	return s.c
+}
+
+// Close stops the subscription, and the polling once no subscriber is left
+func (s *Subscription) Close() {
+	s.poller.unsubscribe(s)
+}
+
+// Poller polls the managed accounts. It polls while it has subscribers, at
+// the shortest of their intervals.
+type Poller struct {
+	client  BatchCaller
+	options Options
+
+	balance *prometheus.GaugeVec
+	nonce   *prometheus.GaugeVec
+	polls   *prometheus.CounterVec
+
+	mu          sync.Mutex
+	accounts    []*Account
+	subscribers map[*Subscription]struct{}
+	// stop stops the polling loop, nil while there is no subscriber
+	stop func()
+	// watched is signaled when an account is added, so that it is polled
+	// right away
+	watched chan struct{}
+}
+
+// NewPoller returns a poller sending its batches through client
+func NewPoller(client BatchCaller, options Options) *Poller {
+	if options.Interval <= 0 {
+		options.Interval = DefaultInterval
+	}
+	factory := promauto.With(prometheus.WrapRegistererWith(options.MetricLabels, prometheus.DefaultRegisterer))
+	return &Poller{
+		client:  client,
+		options: options,
+		balance: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "drand_account_balance_wei",
+			Help: "Balance of a managed account at the latest block, in wei",
+		}, []string{labelAccount, labelAddress}),
+		nonce: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "drand_account_nonce",
+			Help: "Nonce of a managed account at the pending or latest block",
+		}, []string{labelAccount, labelAddress, labelBlock}),
+		polls: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "drand_account_polls_total",
+			Help: "Total number of batched account polls by result",
+		}, []string{labelResult}),
+		subscribers: make(map[*Subscription]struct{}),
+		watched:     make(chan struct{}, 1),
+	}
+}
+
+// Interval returns the configured delay between polls
+func (p *Poller) Interval() time.Duration {
+	return p.options.Interval
+}
+
+// Watch adds an account to the polled accounts, under name. Accounts already
+// watched keep their name.
+func (p *Poller) Watch(name string, address common.Address) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, account := range p.accounts {
+		if account.Address == address {
+			return
+		}
+	}
+	p.accounts = append(p.accounts, &Account{Name: name, Address: address})
+	log.Debug().Str("account", name).Str("address", address.Hex()).Msg("Watching account balance and nonces")
+	select {
+	case p.watched <- struct{}{}:
+	default:
+	}
+}
+
+// Subscribe returns a subscription to the snapshots, starting the polling for
+// the first subscriber. interval, if not nil, returns the delay between polls
+// the subscriber needs, e.g. stretched while hibernating: the poller waits
+// for the shortest delay of its subscribers.
+func (p *Poller) Subscribe(interval func() time.Duration) *Subscription {
+	s := &Subscription{poller: p, c: make(chan Snapshot, 1), interval: interval}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscribers[s] = struct{}{}
+	if p.stop == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.stop = cancel
+		go p.run(ctx)
+	}
+	return s
+}
+
+func (p *Poller) unsubscribe(s *Subscription) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.subscribers[s]; !ok {
+		return
+	}
+	delete(p.subscribers, s)
+	if len(p.subscribers) == 0 && p.stop != nil {
+		p.stop()
+		p.stop = nil
+	}
+}
+
+// run polls until ctx is cancelled
+func (p *Poller) run(ctx context.Context) {
+	// The accounts watched before the first poll are polled by it
+	select {
+	case <-p.watched:
+	default:
+	}
+	for {
+		if err := p.Poll(ctx); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("Failed to poll account balances and nonces")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.watched:
+		case <-time.After(p.interval()):
+		}
+	}
+}
+
+// interval returns the shortest delay between polls of the subscribers
+func (p *Poller) interval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var shortest time.Duration
+	for s := range p.subscribers {
+		interval := p.options.Interval
+		if s.interval != nil {
+			interval = s.interval()
+		}
+		if shortest == 0 || interval < shortest {
+			shortest = interval
+		}
+	}
+	if shortest <= 0 {
+		return p.options.Interval
+	}
+	return shortest
+}
+
+// Poll gets the balance, pending nonce and latest nonce of every watched
+// account in a single batch, and sends the snapshot to the subscribers. An
+// account whose calls failed keeps its previous state, along with the error.
+func (p *Poller) Poll(ctx context.Context) error {
+	p.mu.Lock()
+	watched := len(p.accounts)
+	addresses := make([]common.Address, watched)
+	for i, account := range p.accounts {
+		addresses[i] = account.Address
+	}
+	p.mu.Unlock()
+	if watched == 0 {
+		return nil
+	}
+
+	type polled struct {
+		balance hexutil.Big
+		pending hexutil.Uint64
+		latest  hexutil.Uint64
+	}
+	results := make([]polled, len(addresses))
+	batch := make([]rpc.BatchElem, 0, 3*len(addresses))
+	for i, address := range addresses {
+		batch = append(batch,
+			rpc.BatchElem{Method: "eth_getBalance", Args: []any{address, "latest"}, Result: &results[i].balance},
+			rpc.BatchElem{Method: "eth_getTransactionCount", Args: []any{address, "pending"}, Result: &results[i].pending},
+			rpc.BatchElem{Method: "eth_getTransactionCount", Args: []any{address, "latest"}, Result: &results[i].latest},
+		)
+	}
+	batchErr := p.client.BatchCallContext(ctx, batch)
+
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	// Accounts are only appended, so the first ones are the polled ones
+	for i, account := range p.accounts[:len(addresses)] {
+		err := batchErr
+		if err == nil {
+			err = errors.Join(batch[3*i].Error, batch[3*i+1].Error, batch[3*i+2].Error)
+		}
+		account.Err = err
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		account.Balance = results[i].balance.ToInt()
+		account.PendingNonce = uint64(results[i].pending)
+		account.LatestNonce = uint64(results[i].latest)
+		account.UpdatedAt = now
+
+		balance, _ := new(big.Float).SetInt(account.Balance).Float64()
+		p.balance.WithLabelValues(account.Name, account.Address.Hex()).Set(balance)
+		p.nonce.WithLabelValues(account.Name, account.Address.Hex(), "pending").Set(float64(account.PendingNonce))
+		p.nonce.WithLabelValues(account.Name, account.Address.Hex(), "latest").Set(float64(account.LatestNonce))
+	}
+	if batchErr != nil {
+		errs = []error{batchErr}
+	}
+	result := "success"
+	if len(errs) > 0 {
+		result = "failure"
+	}
+	p.polls.WithLabelValues(result).Inc()
+
+	snapshot := Snapshot{At: now, Accounts: make([]Account, len(p.accounts))}
+	for i, account := range p.accounts {
+		snapshot.Accounts[i] = *account
+	}
+	for s := range p.subscribers {
+		// The unread snapshot is replaced by the newer one
+		select {
+		case <-s.c:
+		default:
+		}
+		select {
+		case s.c <- snapshot:
+		default:
+		}
+	}
+	return errors.Join(errs...)
+}
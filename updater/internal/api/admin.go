@@ -0,0 +1,165 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/subtle"
+	"drand-oracle-updater/internal/service"
+	"drand-oracle-updater/sender"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// maxRotateBodySize bounds the body of a key rotation request
+const maxRotateBodySize = 4 * 1024
+
+// rotateRequest is the optional body of a key rotation request. Without a
+// private key, the key staged in the configuration is rotated to.
+type rotateRequest struct {
+	PrivateKey string `json:"private_key"`
+}
+
+// requireAdmin guards admin routes with the configured bearer token, or a
+// client certificate issued by the admin client CA. Admin routes are disabled
+// when neither is configured.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.config.AdminToken == "" && s.config.AdminClientCA == "" {
+			writeError(w, http.StatusForbidden, "admin API is disabled")
+			return
+		}
+		// The TLS handshake only verifies client certificates against the
+		// admin client CA
+		var client string
+		if s.config.AdminClientCA != "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			client = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		} else {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if s.config.AdminToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+		}
+		log.Info().Str("method", r.Method).Str("path", r.URL.Path).Str("remote", r.RemoteAddr).Str("client", client).Msg("Admin request")
+		next(w, r)
+	}
+}
+
+func (s *Server) handleVacuum(w http.ResponseWriter, r *http.Request) {
+	results, err := s.updater.Vacuum(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to vacuum state store")
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleResetCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.updater.ResetCircuitBreaker())
+}
+
+func (s *Server) handleApproveCatchUp(w http.ResponseWriter, r *http.Request) {
+	estimate, err := s.updater.ApproveCatchUp()
+	if errors.Is(err, service.ErrNoPendingCatchUp) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, estimate)
+}
+
+// wakeResponse is the response of an admin wake-up
+type wakeResponse struct {
+	WasHibernating bool `json:"was_hibernating"`
+}
+
+func (s *Server) handleWake(w http.ResponseWriter, r *http.Request) {
+	wasHibernating, err := s.updater.Wake()
+	if errors.Is(err, service.ErrHibernationDisabled) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, wakeResponse{WasHibernating: wasHibernating})
+}
+
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	round, err := strconv.ParseUint(r.PathValue("round"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid round")
+		return
+	}
+	preview, err := s.updater.PreviewRound(r.Context(), round)
+	if err != nil {
+		log.Error().Err(err).Uint64("round", round).Msg("Failed to preview round submission")
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, preview)
+}
+
+func (s *Server) handleRotateSender(w http.ResponseWriter, r *http.Request) {
+	privateKey, ok := readRotateKey(w, r)
+	if !ok {
+		return
+	}
+	var next sender.KeyProvider
+	if privateKey != nil {
+		next = sender.NewLocalKey(privateKey)
+	}
+	rotation, err := s.updater.RotateSender(r.Context(), next)
+	if err != nil {
+		writeRotateError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rotation)
+}
+
+func (s *Server) handleRotateSigner(w http.ResponseWriter, r *http.Request) {
+	privateKey, ok := readRotateKey(w, r)
+	if !ok {
+		return
+	}
+	rotation, err := s.updater.RotateSigner(r.Context(), privateKey)
+	if err != nil {
+		writeRotateError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rotation)
+}
+
+// readRotateKey parses the private key of a rotation request, nil when the
+// body is empty or has none
+func readRotateKey(w http.ResponseWriter, r *http.Request) (*ecdsa.PrivateKey, bool) {
+	var req rotateRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRotateBodySize)).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return nil, false
+	}
+	if req.PrivateKey == "" {
+		return nil, true
+	}
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(req.PrivateKey, "0x"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid private key")
+		return nil, false
+	}
+	return privateKey, true
+}
+
+func writeRotateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNoNextKey):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, sender.ErrDrainTimeout):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeError(w, http.StatusConflict, err.Error())
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"drand-oracle-updater/internal/accounting"
+	"drand-oracle-updater/internal/service"
+	"drand-oracle-updater/internal/store"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxAnnotationBodySize bounds the body of an annotation request
+const maxAnnotationBodySize = 16 * 1024
+
+func (s *Server) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := accounting.ParseDate(query.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from, expected YYYY-MM-DD or RFC3339")
+		return
+	}
+	to, err := accounting.ParseDate(query.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to, expected YYYY-MM-DD or RFC3339")
+		return
+	}
+	var round uint64
+	if value := query.Get("round"); value != "" {
+		round, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid round")
+			return
+		}
+	}
+	annotations, err := s.updater.Annotations(from, to, round)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read annotations")
+		writeError(w, http.StatusInternalServerError, "failed to read annotations")
+		return
+	}
+	if annotations == nil {
+		annotations = []store.Annotation{}
+	}
+	writeJSON(w, http.StatusOK, annotations)
+}
+
+func (s *Server) handleAddAnnotation(w http.ResponseWriter, r *http.Request) {
+	var annotation store.Annotation
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAnnotationBodySize)).Decode(&annotation); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	annotation, err := s.updater.AddAnnotation(annotation)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Info().Str("id", annotation.ID).Str("note", annotation.Note).Msg("Annotation added")
+	writeJSON(w, http.StatusCreated, annotation)
+}
+
+func (s *Server) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := s.updater.DeleteAnnotation(id)
+	if errors.Is(err, service.ErrAnnotationNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to delete annotation")
+		writeError(w, http.StatusInternalServerError, "failed to delete annotation")
+		return
+	}
+	log.Info().Str("id", id).Msg("Annotation deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"drand-oracle-updater/internal/service"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// maxControlBodySize bounds the body of a control request
+const maxControlBodySize = 4 * 1024
+
+// pauseRequest is the optional body of a pause request
+type pauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// debugWindowRequest is the body of a debug window, which ends after
+// Minutes or Rounds, whichever comes first
+type debugWindowRequest struct {
+	Minutes float64 `json:"minutes"`
+	Rounds  uint64  `json:"rounds"`
+}
+
+// logLevelRequest is the body of a log level change, and its response
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func (s *Server) handlePauseSubmissions(w http.ResponseWriter, r *http.Request) {
+	var req pauseRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxControlBodySize)).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.updater.PauseSubmissions(req.Reason))
+}
+
+func (s *Server) handleResumeSubmissions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.updater.ResumeSubmissions())
+}
+
+func (s *Server) handleForceSubmitRound(w http.ResponseWriter, r *http.Request) {
+	round, err := strconv.ParseUint(r.PathValue("round"), 10, 64)
+	if err != nil || round == 0 {
+		writeError(w, http.StatusBadRequest, "invalid round")
+		return
+	}
+	err = s.updater.ForceSubmitRound(r.Context(), round)
+	switch {
+	case errors.Is(err, service.ErrRoundAlreadySet):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrStopping), errors.Is(err, service.ErrNotRunning):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	case err != nil:
+		log.Error().Err(err).Uint64("round", round).Msg("Failed to force round submission")
+		writeError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]uint64{"round": round})
+	}
+}
+
+func (s *Server) handleBackfill(w http.ResponseWriter, r *http.Request) {
+	backfill, err := s.updater.Backfill()
+	switch {
+	case errors.Is(err, service.ErrCatchingUp):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeJSON(w, http.StatusAccepted, backfill)
+	}
+}
+
+func (s *Server) handleResync(w http.ResponseWriter, r *http.Request) {
+	resync, err := s.updater.Resync(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to resync state")
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, resync)
+}
+
+func (s *Server) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, logLevelRequest{Level: zerolog.GlobalLevel().String()})
+}
+
+// handleSetLogLevel changes the log level of the whole process, every
+// pipeline included
+func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req logLevelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxControlBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	level, err := zerolog.ParseLevel(req.Level)
+	if err != nil || req.Level == "" {
+		writeError(w, http.StatusBadRequest, "invalid log level")
+		return
+	}
+	previous := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(level)
+	log.Warn().Str("level", level.String()).Str("previous", previous.String()).Msg("Log level changed")
+	writeJSON(w, http.StatusOK, logLevelRequest{Level: level.String()})
+}
+
+func (s *Server) handleGetDebugWindow(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.updater.DebugWindow())
+}
+
+// handleStartDebugWindow lowers the log level of the whole process to debug
+// for a number of minutes or of this pipeline's rounds
+func (s *Server) handleStartDebugWindow(w http.ResponseWriter, r *http.Request) {
+	var req debugWindowRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxControlBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Minutes < 0 || req.Minutes > service.MaxDebugWindow.Minutes() {
+		writeError(w, http.StatusBadRequest, service.ErrInvalidDebugWindow.Error())
+		return
+	}
+	window, err := s.updater.StartDebugWindow(time.Duration(req.Minutes*float64(time.Minute)), req.Rounds)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, window)
+}
+
+func (s *Server) handleEndDebugWindow(w http.ResponseWriter, r *http.Request) {
+	window, err := s.updater.EndDebugWindow()
+	if errors.Is(err, service.ErrNoDebugWindow) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, window)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"drand-oracle-updater/internal/service"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rs/zerolog/log"
+)
+
+const maxBeaconBodySize = 64 * 1024
+
+// beaconRequest follows the drand HTTP API beacon format
+type beaconRequest struct {
+	Round             uint64 `json:"round"`
+	Randomness        string `json:"randomness"`
+	Signature         string `json:"signature"`
+	PreviousSignature string `json:"previous_signature"`
+}
+
+// requireIngestAuth guards the ingestion endpoint with one of the configured
+// partner tokens, and a signature of the body by one of the configured keys.
+// Ingestion is disabled when neither is configured.
+func (s *Server) requireIngestAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.config.IngestTokens) == 0 && s.ingestVerifier == nil {
+			writeError(w, http.StatusForbidden, "beacon ingestion is disabled")
+			return
+		}
+		if len(s.config.IngestTokens) > 0 {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || !validToken(token, s.config.IngestTokens) {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+		}
+		if s.ingestVerifier != nil {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBeaconBodySize))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid beacon")
+				return
+			}
+			if _, err := s.ingestVerifier.Verify(r.Header, body); err != nil {
+				log.Warn().Err(err).Str("remote", r.RemoteAddr).Msg("Rejected unsigned or invalid pushed beacon")
+				writeError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		next(w, r)
+	}
+}
+
+func validToken(token string, tokens []string) bool {
+	valid := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (s *Server) handleIngestBeacon(w http.ResponseWriter, r *http.Request) {
+	var req beaconRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBeaconBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid beacon")
+		return
+	}
+
+	beacon, err := decodeBeacon(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = s.updater.IngestBeacon(r.Context(), beacon)
+	if errors.Is(err, service.ErrInvalidBeacon) {
+		log.Warn().Err(err).Uint64("round", req.Round).Str("remote", r.RemoteAddr).Msg("Rejected pushed beacon")
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func decodeBeacon(req beaconRequest) (service.Beacon, error) {
+	beacon := service.Beacon{Round: req.Round}
+	if req.Round == 0 {
+		return beacon, errors.New("missing round")
+	}
+
+	var err error
+	if beacon.Signature, err = decodeHex(req.Signature); err != nil || len(beacon.Signature) == 0 {
+		return beacon, errors.New("invalid signature")
+	}
+	if beacon.Randomness, err = decodeHex(req.Randomness); err != nil {
+		return beacon, errors.New("invalid randomness")
+	}
+	if beacon.PreviousSignature, err = decodeHex(req.PreviousSignature); err != nil {
+		return beacon, errors.New("invalid previous signature")
+	}
+	return beacon, nil
+}
+
+// decodeHex decodes a hex string with or without 0x prefix, as drand omits it
+func decodeHex(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "0x") {
+		s = "0x" + s
+	}
+	return hexutil.Decode(s)
+}
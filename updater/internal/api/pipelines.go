@@ -0,0 +1,41 @@
+package api
+
+import (
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/service"
+	"net/http"
+	"sort"
+)
+
+// PipelinesHandler serves the default pipeline at the root, as when it runs
+// alone, and every other pipeline under /pipelines/{name}/. GET /pipelines
+// lists the status of all pipelines.
+func PipelinesHandler(servers map[string]*Server) http.Handler {
+	defaultServer := servers[config.DefaultPipeline]
+	if len(servers) == 1 && defaultServer != nil {
+		return defaultServer.Handler()
+	}
+
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /pipelines", func(w http.ResponseWriter, r *http.Request) {
+		statuses := make([]service.Status, len(names))
+		for i, name := range names {
+			statuses[i] = servers[name].updater.Status()
+		}
+		writeJSON(w, http.StatusOK, statuses)
+	})
+	for _, name := range names {
+		prefix := "/pipelines/" + name
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, servers[name].Handler()))
+	}
+	if defaultServer != nil {
+		mux.Handle("/", defaultServer.Handler())
+	}
+	return mux
+}
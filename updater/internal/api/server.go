@@ -0,0 +1,330 @@
+package api
+
+import (
+	"bytes"
+	"drand-oracle-updater/config"
+	"drand-oracle-updater/internal/accounting"
+	"drand-oracle-updater/internal/httpsig"
+	"drand-oracle-updater/internal/service"
+	"drand-oracle-updater/internal/version"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Server serves the health check and the JSON API for the updater
+type Server struct {
+	updater *service.Updater
+	config  config.Config
+	// ingestVerifier verifies the signatures of pushed beacons, unless nil
+	ingestVerifier *httpsig.Verifier
+	mux            *http.ServeMux
+}
+
+func NewServer(updater *service.Updater, cfg config.Config, ingestVerifier *httpsig.Verifier) *Server {
+	s := &Server{
+		updater:        updater,
+		config:         cfg,
+		ingestVerifier: ingestVerifier,
+		mux:            http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/health", s.handleHealth)
+	s.mux.HandleFunc("GET /live", s.handleLive)
+	s.mux.HandleFunc("GET /ready", s.handleReady)
+	s.mux.HandleFunc("GET /config", s.handleConfig)
+	s.mux.HandleFunc("GET /version", s.handleVersion)
+	s.mux.HandleFunc("GET /v1/status", s.handleStatus)
+	s.mux.HandleFunc("GET /v1/rounds/latest", s.handleLatestRound)
+	s.mux.HandleFunc("GET /v1/rounds/at", s.handleRoundAt)
+	s.mux.HandleFunc("GET /v1/rounds/{round}", s.handleRound)
+	s.mux.HandleFunc("GET /v1/rounds/{round}/inclusion", s.handleRoundInclusion)
+	s.mux.HandleFunc("GET /v1/catch-up", s.handleCatchUp)
+	s.mux.HandleFunc("GET /v1/costs", s.handleCosts)
+	s.mux.HandleFunc("GET /v1/annotations", s.handleAnnotations)
+	s.mux.HandleFunc("GET /v1/releases", s.handleReleases)
+	s.mux.HandleFunc("GET /v1/drand/relays", s.handleDrandRelays)
+	s.mux.HandleFunc("GET /archive/{round}", s.handleArchive)
+	s.mux.HandleFunc("GET /timeline", s.handleTimeline)
+
+	s.mux.HandleFunc("POST /ingest/beacon", s.requireIngestAuth(s.handleIngestBeacon))
+
+	s.mux.HandleFunc("POST /admin/vacuum", s.requireAdmin(s.handleVacuum))
+	s.mux.HandleFunc("GET /admin/export", s.requireAdmin(s.handleExport))
+	s.mux.HandleFunc("POST /admin/circuit-breaker/reset", s.requireAdmin(s.handleResetCircuitBreaker))
+	s.mux.HandleFunc("POST /admin/catch-up/approve", s.requireAdmin(s.handleApproveCatchUp))
+	s.mux.HandleFunc("POST /admin/annotations", s.requireAdmin(s.handleAddAnnotation))
+	s.mux.HandleFunc("DELETE /admin/annotations/{id}", s.requireAdmin(s.handleDeleteAnnotation))
+	s.mux.HandleFunc("POST /admin/keys/sender/rotate", s.requireAdmin(s.handleRotateSender))
+	s.mux.HandleFunc("POST /admin/keys/signer/rotate", s.requireAdmin(s.handleRotateSigner))
+	s.mux.HandleFunc("POST /admin/wake", s.requireAdmin(s.handleWake))
+	s.mux.HandleFunc("POST /admin/submissions/pause", s.requireAdmin(s.handlePauseSubmissions))
+	s.mux.HandleFunc("POST /admin/submissions/resume", s.requireAdmin(s.handleResumeSubmissions))
+	s.mux.HandleFunc("POST /admin/rounds/{round}/submit", s.requireAdmin(s.handleForceSubmitRound))
+	s.mux.HandleFunc("POST /admin/backfill", s.requireAdmin(s.handleBackfill))
+	s.mux.HandleFunc("POST /admin/resync", s.requireAdmin(s.handleResync))
+	s.mux.HandleFunc("GET /admin/log-level", s.requireAdmin(s.handleGetLogLevel))
+	s.mux.HandleFunc("PUT /admin/log-level", s.requireAdmin(s.handleSetLogLevel))
+	s.mux.HandleFunc("GET /admin/debug-window", s.requireAdmin(s.handleGetDebugWindow))
+	s.mux.HandleFunc("POST /admin/debug-window", s.requireAdmin(s.handleStartDebugWindow))
+	s.mux.HandleFunc("DELETE /admin/debug-window", s.requireAdmin(s.handleEndDebugWindow))
+	s.mux.HandleFunc("POST /preview/{round}", s.requireAdmin(s.handlePreview))
+
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write([]byte("OK"))
+	if err != nil {
+		log.Error().Err(err).Msg("error writing health check response")
+	}
+}
+
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	ok, checks := s.updater.Liveness()
+	writeChecks(w, ok, checks)
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ok, checks := s.updater.Readiness(r.Context())
+	writeChecks(w, ok, checks)
+}
+
+func writeChecks(w http.ResponseWriter, ok bool, checks []service.CheckResult) {
+	status, code := "ok", http.StatusOK
+	if !ok {
+		status, code = "fail", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.config.Redacted())
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, version.Get())
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.updater.Status())
+}
+
+func (s *Server) handleCatchUp(w http.ResponseWriter, r *http.Request) {
+	estimate := s.updater.CatchUpEstimate()
+	if estimate == nil {
+		writeError(w, http.StatusNotFound, "no catch-up has been estimated")
+		return
+	}
+	writeJSON(w, http.StatusOK, estimate)
+}
+
+func (s *Server) handleCosts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := accounting.ParseDate(query.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from, expected YYYY-MM-DD or RFC3339")
+		return
+	}
+	to, err := accounting.ParseDate(query.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to, expected YYYY-MM-DD or RFC3339")
+		return
+	}
+	daily := query.Get("group") == "day"
+	if group := query.Get("group"); group != "" && !daily {
+		writeError(w, http.StatusBadRequest, "invalid group, expected day")
+		return
+	}
+	report, err := s.updater.Costs(from, to, daily)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read recorded transactions")
+		writeError(w, http.StatusInternalServerError, "failed to read recorded transactions")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := accounting.ParseDate(query.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from, expected YYYY-MM-DD or RFC3339")
+		return
+	}
+	to, err := accounting.ParseDate(query.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to, expected YYYY-MM-DD or RFC3339")
+		return
+	}
+	format := accounting.Format(query.Get("format"))
+	contentType := "text/csv"
+	switch format {
+	case "", accounting.FormatCSV:
+		format = accounting.FormatCSV
+	case accounting.FormatJSON:
+		contentType = "application/json"
+	default:
+		writeError(w, http.StatusBadRequest, "invalid format, expected csv or json")
+		return
+	}
+	fields, err := accounting.ParseFields(query.Get("fields"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var price *big.Float
+	if usdPrice := query.Get("usd_price"); usdPrice != "" {
+		var ok bool
+		if price, ok = new(big.Float).SetString(usdPrice); !ok {
+			writeError(w, http.StatusBadRequest, "invalid usd_price")
+			return
+		}
+	}
+
+	// The export is buffered so that a failure is answered with an error
+	// rather than a truncated file
+	var body bytes.Buffer
+	err = s.updater.Export(&body, from, to, accounting.Options{Format: format, Fields: fields, USDPrice: price})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to export recorded transactions")
+		writeError(w, http.StatusInternalServerError, "failed to export recorded transactions")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body.Bytes())
+}
+
+func (s *Server) handleReleases(w http.ResponseWriter, r *http.Request) {
+	releases, err := s.updater.Releases()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read releases")
+		writeError(w, http.StatusInternalServerError, "failed to read releases")
+		return
+	}
+	writeJSON(w, http.StatusOK, releases)
+}
+
+func (s *Server) handleDrandRelays(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.updater.DrandRelays())
+}
+
+func (s *Server) handleLatestRound(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("finality") {
+	case "", "latest":
+		s.writeRound(w, r, s.updater.GetLatestOracleRound())
+	case "committed":
+		s.writeRound(w, r, s.updater.CommittedOracleRound())
+	default:
+		writeError(w, http.StatusBadRequest, "invalid finality, expected latest or committed")
+	}
+}
+
+func (s *Server) handleRound(w http.ResponseWriter, r *http.Request) {
+	round, err := strconv.ParseUint(r.PathValue("round"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid round")
+		return
+	}
+	s.writeRound(w, r, round)
+}
+
+// handleArchive redirects to the object of a round in the archive bucket,
+// which consumers then fetch from the bucket directly
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	round, err := strconv.ParseUint(r.PathValue("round"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid round")
+		return
+	}
+	url, err := s.updater.ArchiveURL(round)
+	switch {
+	case errors.Is(err, service.ErrArchiveDisabled):
+		writeError(w, http.StatusNotFound, "round archive bucket is not configured")
+		return
+	case errors.Is(err, service.ErrRoundNotFound):
+		writeError(w, http.StatusNotFound, "round is not set on the oracle")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func (s *Server) handleRoundAt(w http.ResponseWriter, r *http.Request) {
+	timestamp, err := strconv.ParseUint(r.URL.Query().Get("timestamp"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid timestamp")
+		return
+	}
+	result, err := s.updater.RoundAt(timestamp)
+	if errors.Is(err, service.ErrRoundNotFound) {
+		writeError(w, http.StatusNotFound, "no indexed round was set at this timestamp")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Uint64("timestamp", timestamp).Msg("Failed to read rounds index")
+		writeError(w, http.StatusInternalServerError, "failed to read rounds index")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleRoundInclusion(w http.ResponseWriter, r *http.Request) {
+	round, err := strconv.ParseUint(r.PathValue("round"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid round")
+		return
+	}
+	result, err := s.updater.RoundInclusion(round)
+	if errors.Is(err, service.ErrRoundNotFound) {
+		writeError(w, http.StatusNotFound, "round is not indexed")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Uint64("round", round).Msg("Failed to read rounds index")
+		writeError(w, http.StatusInternalServerError, "failed to read rounds index")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) writeRound(w http.ResponseWriter, r *http.Request, round uint64) {
+	result, err := s.updater.OracleRound(r.Context(), round)
+	if errors.Is(err, service.ErrRoundNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Uint64("round", round).Msg("Failed to read round from Drand Oracle contract")
+		writeError(w, http.StatusBadGateway, "failed to read round from oracle contract")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("error writing JSON response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
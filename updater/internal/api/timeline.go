@@ -0,0 +1,33 @@
+package api
+
+import (
+	"drand-oracle-updater/internal/accounting"
+	"drand-oracle-updater/internal/service"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+func (s *Server) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := accounting.ParseDate(query.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from, expected YYYY-MM-DD or RFC3339")
+		return
+	}
+	to, err := accounting.ParseDate(query.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to, expected YYYY-MM-DD or RFC3339")
+		return
+	}
+	timeline, err := s.updater.Timeline(from, to, service.ParseTimelineKinds(query.Get("kind")))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read timeline")
+		writeError(w, http.StatusInternalServerError, "failed to read timeline")
+		return
+	}
+	if timeline == nil {
+		timeline = []service.TimelineEvent{}
+	}
+	writeJSON(w, http.StatusOK, timeline)
+}
@@ -0,0 +1,191 @@
+// Package audit keeps a tamper-evident record of the updater's submission
+// decisions, separate from its operational logs. Every entry is a JSON line
+// chained to the previous one by its hash, so that a removed, reordered or
+// edited entry breaks the chain.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Decisions on a round
+const (
+	// DecisionSubmitted is a round set by a mined transaction of the updater
+	DecisionSubmitted = "submitted"
+	// DecisionSimulated is a round simulated in a dry run
+	DecisionSimulated = "simulated"
+	// DecisionSkipped is a round the updater chose not to submit
+	DecisionSkipped = "skipped"
+	// DecisionReverted is a round whose transaction was mined but reverted
+	DecisionReverted = "reverted"
+	// DecisionAbandoned is a round given up after all its attempts failed
+	DecisionAbandoned = "abandoned"
+)
+
+// Entry is a decision on a round, or on a range of rounds
+type Entry struct {
+	// Seq numbers the entries from 1, without gaps
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	Pipeline string    `json:"pipeline"`
+	ChainID  int64     `json:"chain_id"`
+	Oracle   string    `json:"oracle"`
+	Round    uint64    `json:"round"`
+	// LastRound is the last round of a range, such as a batch
+	LastRound      uint64 `json:"last_round,omitempty"`
+	DrandSignature string `json:"drand_signature,omitempty"`
+	Decision       string `json:"decision"`
+	Reason         string `json:"reason,omitempty"`
+	TxHash         string `json:"tx_hash,omitempty"`
+	Signer         string `json:"signer"`
+	Sender         string `json:"sender,omitempty"`
+	// PrevHash is the hash of the previous entry, empty for the first one
+	PrevHash string `json:"prev_hash"`
+	// Hash is the SHA-256 hash of the entry encoded without it
+	Hash string `json:"hash"`
+}
+
+// hash returns the hash of the entry encoded with an empty hash
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Sink stores the encoded entries, one JSON line each, in order
+type Sink interface {
+	Append(ctx context.Context, line []byte) error
+	Close() error
+}
+
+// head is the end of the chain, persisted to resume it after a restart
+type head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Log chains the entries and appends them to a sink. It is safe for
+// concurrent use by the pipelines.
+type Log struct {
+	sink     Sink
+	headPath string
+
+	mu   sync.Mutex
+	head head
+}
+
+// New returns a log appending to sink, resuming the chain whose head is
+// persisted at headPath
+func New(sink Sink, headPath string) (*Log, error) {
+	l := &Log{sink: sink, headPath: headPath}
+	data, err := os.ReadFile(headPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("error reading audit log head: %w", err)
+	default:
+		if err := json.Unmarshal(data, &l.head); err != nil {
+			return nil, fmt.Errorf("error decoding audit log head %s: %w", headPath, err)
+		}
+	}
+	return l, nil
+}
+
+// Record chains an entry to the previous one and appends it. The chain only
+// advances once the sink accepted the entry.
+func (l *Log) Record(ctx context.Context, entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Seq = l.head.Seq + 1
+	entry.PrevHash = l.head.Hash
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	hash, err := entry.hash()
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := l.sink.Append(ctx, append(line, '\n')); err != nil {
+		return err
+	}
+	l.head = head{Seq: entry.Seq, Hash: entry.Hash}
+	return l.saveHead()
+}
+
+// saveHead persists the head of the chain, replacing the previous one
+// atomically
+func (l *Log) saveHead() error {
+	data, err := json.Marshal(l.head)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.headPath), 0o755); err != nil {
+		return err
+	}
+	tmp := l.headPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.headPath)
+}
+
+// Close closes the sink, flushing the entries it buffers
+func (l *Log) Close() error {
+	return l.sink.Close()
+}
+
+// Verify checks the chain of the entries read from r, one JSON line each,
+// and returns the number of entries. prevHash is the hash of the entry
+// preceding the first one, empty when r starts the chain.
+func Verify(r io.Reader, prevHash string) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var count int
+	var seq uint64
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return count, fmt.Errorf("line %d: %w", count+1, err)
+		}
+		if count > 0 && entry.Seq != seq+1 {
+			return count, fmt.Errorf("entry %d follows entry %d", entry.Seq, seq)
+		}
+		if entry.PrevHash != prevHash {
+			return count, fmt.Errorf("entry %d does not chain to the previous entry", entry.Seq)
+		}
+		hash, err := entry.hash()
+		if err != nil {
+			return count, err
+		}
+		if hash != entry.Hash {
+			return count, fmt.Errorf("entry %d was modified, its hash is %s", entry.Seq, hash)
+		}
+		seq, prevHash = entry.Seq, entry.Hash
+		count++
+	}
+	return count, scanner.Err()
+}
@@ -0,0 +1,206 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memorySink keeps the appended lines in memory
+type memorySink struct {
+	lines [][]byte
+	err   error
+}
+
+func (s *memorySink) Append(_ context.Context, line []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.lines = append(s.lines, line)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+// recordRounds records a submitted entry for each round
+func recordRounds(t *testing.T, l *Log, rounds ...uint64) {
+	t.Helper()
+	for _, round := range rounds {
+		if err := l.Record(context.Background(), Entry{Round: round, Decision: DecisionSubmitted, Signer: "0x01"}); err != nil {
+			t.Fatalf("recording round %d: %v", round, err)
+		}
+	}
+}
+
+// newTestLog returns a log of 4 entries in memory
+func newTestLog(t *testing.T) (*Log, *memorySink) {
+	t.Helper()
+	sink := &memorySink{}
+	l, err := New(sink, filepath.Join(t.TempDir(), "head.json"))
+	if err != nil {
+		t.Fatalf("new log: %v", err)
+	}
+	recordRounds(t, l, 1, 2, 3, 4)
+	return l, sink
+}
+
+// editEntry returns the line with its entry changed by edit, the hash being
+// left as is unless edit recomputes it
+func editEntry(t *testing.T, line []byte, edit func(e *Entry)) []byte {
+	t.Helper()
+	var entry Entry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatalf("decoding entry: %v", err)
+	}
+	edit(&entry)
+	edited, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatalf("encoding entry: %v", err)
+	}
+	return append(edited, '\n')
+}
+
+func TestVerify(t *testing.T) {
+	_, sink := newTestLog(t)
+	count, err := Verify(bytes.NewReader(bytes.Join(sink.lines, nil)), "")
+	if err != nil || count != 4 {
+		t.Fatalf("Verify = %d, %v, want 4 entries", count, err)
+	}
+
+	// A file starting mid-chain verifies from the hash of the entry before it
+	var first Entry
+	if err := json.Unmarshal(sink.lines[1], &first); err != nil {
+		t.Fatalf("decoding entry: %v", err)
+	}
+	if count, err := Verify(bytes.NewReader(bytes.Join(sink.lines[2:], nil)), first.Hash); err != nil || count != 2 {
+		t.Errorf("Verify from entry 3 = %d, %v, want 2 entries", count, err)
+	}
+	if _, err := Verify(bytes.NewReader(bytes.Join(sink.lines[2:], nil)), ""); err == nil {
+		t.Error("chain starting at entry 3 verified without the previous hash")
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, lines [][]byte) [][]byte
+		// wantErr is a substring of the error
+		wantErr string
+	}{
+		{
+			name: "edited entry",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				lines[1] = editEntry(t, lines[1], func(e *Entry) { e.Decision = DecisionSkipped })
+				return lines
+			},
+			wantErr: "entry 2 was modified",
+		},
+		{
+			name: "rehashed entry",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				// The edited entry's hash is fixed, breaking the link of the next one
+				lines[1] = editEntry(t, lines[1], func(e *Entry) {
+					e.TxHash = "0xdead"
+					e.Hash, _ = e.hash()
+				})
+				return lines
+			},
+			wantErr: "entry 3 does not chain",
+		},
+		{
+			name: "removed entry",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			wantErr: "entry 3 follows entry 1",
+		},
+		{
+			name: "reordered entries",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			wantErr: "entry 3 follows entry 1",
+		},
+		{
+			name: "renumbered entries",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				// The removal of entry 2 is hidden by renumbering the entries after it
+				lines = append(lines[:1], lines[2:]...)
+				for i := 1; i < len(lines); i++ {
+					lines[i] = editEntry(t, lines[i], func(e *Entry) {
+						e.Seq--
+						e.Hash, _ = e.hash()
+					})
+				}
+				return lines
+			},
+			wantErr: "entry 2 does not chain",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, sink := newTestLog(t)
+			lines := tt.tamper(t, sink.lines)
+			_, err := Verify(bytes.NewReader(bytes.Join(lines, nil)), "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResumeChain(t *testing.T) {
+	dir := t.TempDir()
+	path, headPath := filepath.Join(dir, "audit.jsonl"), filepath.Join(dir, "head.json")
+	open := func() *Log {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatalf("file sink: %v", err)
+		}
+		l, err := New(sink, headPath)
+		if err != nil {
+			t.Fatalf("new log: %v", err)
+		}
+		return l
+	}
+
+	l := open()
+	recordRounds(t, l, 1, 2)
+	if err := l.Close(); err != nil {
+		t.Fatalf("closing: %v", err)
+	}
+	// A restarted log continues the chain of the file
+	l = open()
+	recordRounds(t, l, 3)
+	if err := l.Close(); err != nil {
+		t.Fatalf("closing: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log: %v", err)
+	}
+	if count, err := Verify(bytes.NewReader(data), ""); err != nil || count != 3 {
+		t.Errorf("Verify = %d, %v, want 3 entries", count, err)
+	}
+}
+
+func TestRecordSinkFailure(t *testing.T) {
+	l, sink := newTestLog(t)
+	sink.err = errors.New("sink down")
+	if err := l.Record(context.Background(), Entry{Round: 5, Decision: DecisionSubmitted}); err == nil {
+		t.Fatal("entry recorded despite the sink failure")
+	}
+	// The rejected entry does not advance the chain
+	sink.err = nil
+	recordRounds(t, l, 5)
+	if count, err := Verify(bytes.NewReader(bytes.Join(sink.lines, nil)), ""); err != nil || count != 5 {
+		t.Errorf("Verify = %d, %v, want 5 entries", count, err)
+	}
+}
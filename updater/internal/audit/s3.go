@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/internal/objectstore"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// uploadTimeout bounds an object upload
+	uploadTimeout = 1 * time.Minute
+
+	// DefaultFlushInterval is the interval between uploads of the buffered
+	// entries
+	DefaultFlushInterval = 1 * time.Minute
+)
+
+// S3Config locates the bucket of an S3-compatible object storage
+type S3Config struct {
+	// Endpoint is the base URL of the storage, e.g.
+	// https://s3.eu-west-1.amazonaws.com, addressed path-style
+	Endpoint        string
+	Bucket          string
+	Prefix          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// FlushInterval is the interval between uploads of the buffered entries
+	FlushInterval time.Duration
+}
+
+// S3Sink buffers the entries and uploads them every flush interval as a new
+// object, objects being immutable. Entries buffered when the process dies are
+// lost, the chain then shows the gap.
+type S3Sink struct {
+	config S3Config
+	client *objectstore.Client
+
+	mu     sync.Mutex
+	buffer bytes.Buffer
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewS3Sink returns a sink uploading to the bucket, and starts flushing
+func NewS3Sink(config S3Config) (*S3Sink, error) {
+	client, err := objectstore.New(objectstore.Config{
+		Endpoint:        config.Endpoint,
+		Bucket:          config.Bucket,
+		Region:          config.Region,
+		AccessKeyID:     config.AccessKeyID,
+		SecretAccessKey: config.SecretAccessKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit bucket: %w", err)
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultFlushInterval
+	}
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	s := &S3Sink{
+		config: config,
+		client: client,
+		done:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.flushPeriodically()
+	return s, nil
+}
+
+// Append buffers a line until the next flush
+func (s *S3Sink) Append(_ context.Context, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffer.Write(line)
+	return nil
+}
+
+// flushPeriodically uploads the buffered entries every flush interval, until
+// the sink is closed
+func (s *S3Sink) flushPeriodically() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		if err := s.Flush(context.Background()); err != nil {
+			log.Error().Err(err).Str("bucket", s.config.Bucket).Msg("Failed to upload audit entries, retrying next flush")
+		}
+	}
+}
+
+// Flush uploads the buffered entries as a new object. Entries are kept
+// buffered when the upload fails.
+func (s *S3Sink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buffer.Len() == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	key := fmt.Sprintf("%s/%s.jsonl", now.Format("2006-01-02"), now.Format("20060102T150405.000000000Z"))
+	if s.config.Prefix != "" {
+		key = s.config.Prefix + "/" + key
+	}
+	if err := s.client.Put(ctx, key, "application/x-ndjson", s.buffer.Bytes()); err != nil {
+		return err
+	}
+	s.buffer.Reset()
+	return nil
+}
+
+// Close stops the periodic flushes and uploads the buffered entries
+func (s *S3Sink) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+	defer cancel()
+	return s.Flush(ctx)
+}
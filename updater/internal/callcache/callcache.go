@@ -0,0 +1,179 @@
+// Package callcache caches and coalesces the read calls of a contract binding,
+// so that the same view call made by several goroutines, or again shortly
+// after, is only sent to the RPC once. Results are cached for a TTL, or until
+// invalidated for methods whose results never change, and invalidated early
+// when an event shows that they changed.
+package callcache
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+)
+
+// Results of a cached call, the result label of the cache metric
+const (
+	resultHit       = "hit"
+	resultCoalesced = "coalesced"
+	resultMiss      = "miss"
+)
+
+// Options configures a cache
+type Options struct {
+	// TTL is how long the results of the Mutable methods are cached
+	TTL time.Duration
+	// Mutable are the methods whose results are cached for TTL
+	Mutable []string
+	// Static are the methods whose results never change, cached until
+	// invalidated
+	Static []string
+	// MetricLabels are attached to the metrics of the cache
+	MetricLabels map[string]string
+}
+
+// entry is a cached call result
+type entry struct {
+	method  string
+	result  []byte
+	expires time.Time // zero for static methods
+}
+
+// Backend is a bind.ContractBackend caching the calls to the configured
+// methods at the latest block. Other calls, and transactions, go to the
+// wrapped backend.
+type Backend struct {
+	bind.ContractBackend
+	options Options
+
+	// methods maps the selectors of the cached methods to their names
+	methods map[[4]byte]string
+	static  map[string]bool
+	group   singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]entry
+	// generations are bumped by invalidations, so that a call in flight
+	// while its method is invalidated does not cache its result
+	generations map[string]uint64
+
+	requests *prometheus.CounterVec
+}
+
+// New returns a backend caching the calls to the methods of contractABI
+// selected by options
+func New(backend bind.ContractBackend, contractABI *abi.ABI, options Options) (*Backend, error) {
+	b := &Backend{
+		ContractBackend: backend,
+		options:         options,
+		methods:         make(map[[4]byte]string),
+		static:          make(map[string]bool),
+		entries:         make(map[string]entry),
+		generations:     make(map[string]uint64),
+	}
+	for _, name := range append(append([]string{}, options.Mutable...), options.Static...) {
+		method, ok := contractABI.Methods[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown method %q", name)
+		}
+		b.methods[[4]byte(method.ID)] = name
+	}
+	for _, name := range options.Static {
+		b.static[name] = true
+	}
+
+	factory := promauto.With(prometheus.WrapRegistererWith(options.MetricLabels, prometheus.DefaultRegisterer))
+	b.requests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_rpc_cache_requests_total",
+		Help: "Total number of cached contract calls by method and result, hit, coalesced with a call in flight, or miss",
+	}, []string{"method", "result"})
+	return b, nil
+}
+
+// CallContract serves a call to a cached method at the latest block from the
+// cache, or joins the same call in flight, and sends it otherwise
+func (b *Backend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber != nil || call.To == nil || len(call.Data) < 4 {
+		return b.ContractBackend.CallContract(ctx, call, blockNumber)
+	}
+	method, ok := b.methods[[4]byte(call.Data[:4])]
+	if !ok {
+		return b.ContractBackend.CallContract(ctx, call, blockNumber)
+	}
+	key := call.To.Hex() + call.From.Hex() + hex.EncodeToString(call.Data)
+
+	b.mu.Lock()
+	cached, ok := b.entries[key]
+	if ok && !cached.expires.IsZero() && time.Now().After(cached.expires) {
+		delete(b.entries, key)
+		ok = false
+	}
+	generation := b.generations[method]
+	b.mu.Unlock()
+	if ok {
+		b.requests.WithLabelValues(method, resultHit).Inc()
+		return cached.result, nil
+	}
+
+	// The function runs in the goroutine of the first caller, the others
+	// share its result
+	sent := false
+	result, err, _ := b.group.Do(key, func() (any, error) {
+		sent = true
+		result, err := b.ContractBackend.CallContract(ctx, call, nil)
+		if err != nil {
+			return nil, err
+		}
+		b.mu.Lock()
+		if b.generations[method] == generation {
+			cached := entry{method: method, result: result}
+			if !b.static[method] {
+				cached.expires = time.Now().Add(b.options.TTL)
+			}
+			b.entries[key] = cached
+		}
+		b.mu.Unlock()
+		return result, nil
+	})
+	if sent {
+		b.requests.WithLabelValues(method, resultMiss).Inc()
+	} else {
+		b.requests.WithLabelValues(method, resultCoalesced).Inc()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.([]byte), nil
+}
+
+// Invalidate drops the cached results of the given methods, of every method
+// when none is given
+func (b *Backend) Invalidate(methods ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(methods) == 0 {
+		for _, name := range b.methods {
+			methods = append(methods, name)
+		}
+	}
+	for _, method := range methods {
+		b.generations[method]++
+	}
+	for key, cached := range b.entries {
+		for _, method := range methods {
+			if cached.method == method {
+				delete(b.entries, key)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package canary rolls out a new submission strategy progressively: a share of
+// the rounds, chosen by hashing the round, is submitted with the canary
+// strategy and the others with the current one. The outcomes of both groups
+// are compared over a sliding window, and the canary is rolled back for good
+// when it costs more, is slower to be included or fails more often than the
+// control group beyond the configured thresholds.
+package canary
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Group is the group a round is assigned to
+type Group string
+
+const (
+	Control Group = "control"
+	Canary  Group = "canary"
+)
+
+// Outcome is the outcome of a mined transaction
+type Outcome struct {
+	// Cost is the fee paid per round in wei
+	Cost *big.Int
+	// Latency is the time from the broadcast to the inclusion
+	Latency time.Duration
+	// Failed reports whether the transaction reverted
+	Failed bool
+}
+
+// Thresholds are the regressions of the canary against the control group that
+// roll it back
+type Thresholds struct {
+	// MaxCostIncrease is the relative increase of the mean cost per round,
+	// e.g. 0.1 for 10%
+	MaxCostIncrease float64
+	// MaxLatencyIncrease is the relative increase of the mean inclusion latency
+	MaxLatencyIncrease float64
+	// MaxFailureRateIncrease is the absolute increase of the failure rate,
+	// e.g. 0.05 for 5 percentage points
+	MaxFailureRateIncrease float64
+}
+
+// Options configures a rollout
+type Options struct {
+	// Name describes the canary strategy in logs and the status
+	Name string
+	// Percent is the share of rounds assigned to the canary, 0 to 100
+	Percent int
+	// Window is the number of latest outcomes per group compared
+	Window int
+	// MinSamples is the number of outcomes each group needs before comparing
+	MinSamples int
+	Thresholds Thresholds
+}
+
+// Stats summarizes the outcomes of a group within the window
+type Stats struct {
+	Samples     int     `json:"samples"`
+	MeanCostWei string  `json:"mean_cost_wei"`
+	MeanLatency string  `json:"mean_latency"`
+	FailureRate float64 `json:"failure_rate"`
+
+	meanCost    *big.Float
+	meanLatency time.Duration
+}
+
+// Status is the state of the rollout
+type Status struct {
+	Name    string `json:"name"`
+	Percent int    `json:"percent"`
+	// RolledBack reports whether the canary was rolled back, every round then
+	// being assigned to the control group
+	RolledBack   bool       `json:"rolled_back"`
+	RolledBackAt *time.Time `json:"rolled_back_at,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+	Control      Stats      `json:"control"`
+	Canary       Stats      `json:"canary"`
+}
+
+// Controller assigns rounds to groups and rolls the canary back on regression
+type Controller struct {
+	options Options
+
+	mu           sync.Mutex
+	outcomes     map[Group][]Outcome
+	rolledBack   bool
+	rolledBackAt time.Time
+	reason       string
+
+	outcomesTotal *prometheus.CounterVec
+	rolledBackSet prometheus.Gauge
+}
+
+// New returns the controller of a rollout. metricLabels are attached to its
+// metrics.
+func New(options Options, metricLabels map[string]string) (*Controller, error) {
+	if options.Percent < 0 || options.Percent > 100 {
+		return nil, fmt.Errorf("canary percent must be between 0 and 100, got %d", options.Percent)
+	}
+	if options.MinSamples <= 0 || options.Window < options.MinSamples {
+		return nil, fmt.Errorf("canary window %d must be at least the minimum samples %d, which must be positive", options.Window, options.MinSamples)
+	}
+	factory := promauto.With(prometheus.WrapRegistererWith(metricLabels, prometheus.DefaultRegisterer))
+	return &Controller{
+		options:  options,
+		outcomes: make(map[Group][]Outcome),
+		outcomesTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "drand_canary_outcomes_total",
+			Help: "Total number of mined transactions by canary group and result",
+		}, []string{"canary_group", "result"}),
+		rolledBackSet: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "drand_canary_rolled_back",
+			Help: "Whether the canary strategy was rolled back (1) or is still rolled out (0)",
+		}),
+	}, nil
+}
+
+// Group returns the group of a round, always Control once rolled back
+func (c *Controller) Group(round uint64) Group {
+	c.mu.Lock()
+	rolledBack := c.rolledBack
+	c.mu.Unlock()
+	if rolledBack || c.options.Percent == 0 {
+		return Control
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], round)
+	hash := sha256.Sum256(buf[:])
+	if binary.BigEndian.Uint64(hash[:8])%100 < uint64(c.options.Percent) {
+		return Canary
+	}
+	return Control
+}
+
+// Observe records the outcome of a transaction of a group, and returns the
+// regression when it rolls the canary back, empty otherwise
+func (c *Controller) Observe(group Group, outcome Outcome) string {
+	result := "success"
+	if outcome.Failed {
+		result = "failure"
+	}
+	c.outcomesTotal.WithLabelValues(string(group), result).Inc()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rolledBack {
+		return ""
+	}
+	outcomes := append(c.outcomes[group], outcome)
+	if len(outcomes) > c.options.Window {
+		outcomes = outcomes[len(outcomes)-c.options.Window:]
+	}
+	c.outcomes[group] = outcomes
+
+	reason := c.regression()
+	if reason != "" {
+		c.rolledBack = true
+		c.rolledBackAt = time.Now()
+		c.reason = reason
+		c.rolledBackSet.Set(1)
+	}
+	return reason
+}
+
+// regression compares the groups once both have enough samples, and returns
+// the first threshold the canary exceeds
+func (c *Controller) regression() string {
+	control, canary := c.stats(Control), c.stats(Canary)
+	if control.Samples < c.options.MinSamples || canary.Samples < c.options.MinSamples {
+		return ""
+	}
+	thresholds := c.options.Thresholds
+	if control.meanCost.Sign() > 0 {
+		increase, _ := new(big.Float).Quo(new(big.Float).Sub(canary.meanCost, control.meanCost), control.meanCost).Float64()
+		if increase > thresholds.MaxCostIncrease {
+			return fmt.Sprintf("mean cost per round %s wei is %.0f%% above the control group's %s wei", canary.MeanCostWei, increase*100, control.MeanCostWei)
+		}
+	}
+	if control.meanLatency > 0 {
+		increase := float64(canary.meanLatency-control.meanLatency) / float64(control.meanLatency)
+		if increase > thresholds.MaxLatencyIncrease {
+			return fmt.Sprintf("mean inclusion latency %s is %.0f%% above the control group's %s", canary.MeanLatency, increase*100, control.MeanLatency)
+		}
+	}
+	if increase := canary.FailureRate - control.FailureRate; increase > thresholds.MaxFailureRateIncrease {
+		return fmt.Sprintf("failure rate %.1f%% is %.1f points above the control group's %.1f%%", canary.FailureRate*100, increase*100, control.FailureRate*100)
+	}
+	return ""
+}
+
+// stats summarizes the outcomes of a group
+func (c *Controller) stats(group Group) Stats {
+	outcomes := c.outcomes[group]
+	stats := Stats{Samples: len(outcomes), meanCost: new(big.Float)}
+	if len(outcomes) == 0 {
+		stats.MeanCostWei, stats.MeanLatency = "0", "0s"
+		return stats
+	}
+	totalCost := new(big.Int)
+	var totalLatency time.Duration
+	failures := 0
+	for _, outcome := range outcomes {
+		if outcome.Cost != nil {
+			totalCost.Add(totalCost, outcome.Cost)
+		}
+		totalLatency += outcome.Latency
+		if outcome.Failed {
+			failures++
+		}
+	}
+	n := len(outcomes)
+	stats.meanCost.Quo(new(big.Float).SetInt(totalCost), big.NewFloat(float64(n)))
+	meanCost, _ := stats.meanCost.Int(nil)
+	stats.MeanCostWei = meanCost.String()
+	stats.meanLatency = totalLatency / time.Duration(n)
+	stats.MeanLatency = stats.meanLatency.Round(time.Millisecond).String()
+	stats.FailureRate = float64(failures) / float64(n)
+	return stats
+}
+
+// Status returns the state of the rollout
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := Status{
+		Name:       c.options.Name,
+		Percent:    c.options.Percent,
+		RolledBack: c.rolledBack,
+		Reason:     c.reason,
+		Control:    c.stats(Control),
+		Canary:     c.stats(Canary),
+	}
+	if c.rolledBack {
+		rolledBackAt := c.rolledBackAt
+		status.RolledBackAt = &rolledBackAt
+	}
+	return status
+}
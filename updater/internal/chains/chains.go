@@ -0,0 +1,50 @@
+// Package chains abstracts the chain drand rounds are set on. A ChainSubmitter
+// reads the latest round set on a chain and sets the next one, and a Relay
+// feeds it the rounds of a drand network in order. The EVM updater submits
+// rounds through its own ChainSubmitter, with gas pricing, nonces and
+// finality; other chain families, such as Solana, run on a Relay.
+package chains
+
+import (
+	"context"
+	"time"
+
+	"github.com/drand/drand/chain"
+)
+
+// Chain families
+const (
+	FamilyEVM    = "evm"
+	FamilySolana = "solana"
+)
+
+// Beacon is a drand round to set on a chain, verified by the drand client
+type Beacon struct {
+	Round      uint64
+	Timestamp  uint64
+	Randomness [32]byte
+	Signature  []byte
+}
+
+// NewBeacon returns the beacon of a drand round, its timestamp computed from
+// the network's genesis time and period
+func NewBeacon(info *chain.Info, round uint64, randomness []byte, signature []byte) Beacon {
+	return Beacon{
+		Round:      round,
+		Timestamp:  uint64(info.GenesisTime) + (round-1)*uint64(info.Period/time.Second),
+		Randomness: [32]byte(randomness),
+		Signature:  signature,
+	}
+}
+
+// ChainSubmitter sets drand rounds on a chain. Chains only accept the round
+// following their latest round, so rounds are submitted one at a time, in
+// order.
+type ChainSubmitter interface {
+	// Family returns the chain family, e.g. FamilyEVM
+	Family() string
+	// LatestRound returns the latest round set on the chain, 0 before the first
+	LatestRound(ctx context.Context) (uint64, error)
+	// Submit sets a round and returns once the chain committed it
+	Submit(ctx context.Context, beacon Beacon) error
+}
@@ -0,0 +1,204 @@
+package chains
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+// retryInterval is the delay before reading the chain or drand again after a
+// failed read
+const retryInterval = 5 * time.Second
+
+// RelayOptions configures a relay
+type RelayOptions struct {
+	// GenesisRound is the first round set on an empty chain
+	GenesisRound uint64
+	// MaxRetries is the number of attempts to submit a round before the relay
+	// stops, 1 when 0
+	MaxRetries int
+}
+
+// Relay sets the rounds of a drand network on a chain in order: it catches up
+// from the chain's latest round, then submits every round drand publishes,
+// fetching the rounds the drand watch skipped
+type Relay struct {
+	drand     client.Client
+	submitter ChainSubmitter
+	options   RelayOptions
+
+	info        *chain.Info
+	latestRound uint64
+
+	chainRound  prometheus.Gauge
+	submissions *prometheus.CounterVec
+}
+
+// NewRelay returns a relay from a drand client to a chain. metricLabels are
+// attached to its metrics.
+func NewRelay(drand client.Client, submitter ChainSubmitter, options RelayOptions, metricLabels map[string]string) *Relay {
+	if options.MaxRetries <= 0 {
+		options.MaxRetries = 1
+	}
+	labels := prometheus.Labels{"family": submitter.Family()}
+	for k, v := range metricLabels {
+		labels[k] = v
+	}
+	factory := promauto.With(prometheus.WrapRegistererWith(labels, prometheus.DefaultRegisterer))
+	return &Relay{
+		drand:     drand,
+		submitter: submitter,
+		options:   options,
+		chainRound: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "drand_chain_round",
+			Help: "Latest drand round set on the chain by the relay",
+		}),
+		submissions: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "drand_chain_submissions_total",
+			Help: "Total number of round submissions to the chain by result",
+		}, []string{"result"}),
+	}
+}
+
+// Run relays rounds until ctx is cancelled or a round fails after every retry
+func (r *Relay) Run(ctx context.Context) error {
+	var err error
+	r.info, err = untilOK(ctx, "Failed to get drand info", func(ctx context.Context) (*chain.Info, error) {
+		return r.drand.Info(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	r.latestRound, err = untilOK(ctx, "Failed to get latest round from chain", r.submitter.LatestRound)
+	if err != nil {
+		return err
+	}
+	r.chainRound.Set(float64(r.latestRound))
+	log.Info().
+		Str("family", r.submitter.Family()).
+		Uint64("chain_round", r.latestRound).
+		Uint64("drand_round", r.drand.RoundAt(time.Now())).
+		Msg("Relaying drand rounds")
+
+	if err := r.relayUpTo(ctx, r.drand.RoundAt(time.Now()), nil); err != nil {
+		return err
+	}
+	for {
+		for result := range r.drand.Watch(ctx) {
+			if err := r.relayUpTo(ctx, result.Round(), result); err != nil {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warn().Dur("retry_in", retryInterval).Msg("Drand watch stopped, watching again")
+		if err := sleep(ctx, retryInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// relayUpTo submits the rounds following the chain's latest round up to
+// round. latest is the drand result of round, if already fetched.
+func (r *Relay) relayUpTo(ctx context.Context, round uint64, latest client.Result) error {
+	for {
+		next := r.options.GenesisRound
+		if r.latestRound != 0 {
+			next = r.latestRound + 1
+		}
+		if next > round {
+			return nil
+		}
+		result := latest
+		if result == nil || result.Round() != next {
+			var err error
+			result, err = untilOK(ctx, "Failed to get round from Drand network", func(ctx context.Context) (client.Result, error) {
+				return r.drand.Get(ctx, next)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		beacon := NewBeacon(r.info, result.Round(), result.Randomness(), result.Signature())
+		if err := r.submit(ctx, beacon); err != nil {
+			return err
+		}
+	}
+}
+
+// submit sets a round on the chain, retrying with an exponential backoff. The
+// chain's latest round is read again before every retry, so that a round
+// committed after a failed confirmation is not submitted twice.
+func (r *Relay) submit(ctx context.Context, beacon Beacon) error {
+	var err error
+	for attempt := 0; attempt < r.options.MaxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(math.Pow(2, float64(attempt-1))) * time.Second
+			log.Warn().
+				Err(err).
+				Uint64("round", beacon.Round).
+				Int("attempt", attempt).
+				Dur("backoff", backoff).
+				Msg("Retrying round submission after backoff")
+			if err := sleep(ctx, backoff); err != nil {
+				return err
+			}
+			latest, latestErr := r.submitter.LatestRound(ctx)
+			if latestErr == nil && latest >= beacon.Round {
+				r.setLatestRound(latest)
+				return nil
+			}
+		}
+		err = r.submitter.Submit(ctx, beacon)
+		if err == nil {
+			r.submissions.WithLabelValues("success").Inc()
+			r.setLatestRound(beacon.Round)
+			log.Info().Str("family", r.submitter.Family()).Uint64("round", beacon.Round).Msg("Round set on chain")
+			return nil
+		}
+		r.submissions.WithLabelValues("failure").Inc()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("error submitting round %d after %d attempts: %w", beacon.Round, r.options.MaxRetries, err)
+}
+
+func (r *Relay) setLatestRound(round uint64) {
+	r.latestRound = round
+	r.chainRound.Set(float64(round))
+}
+
+// untilOK calls fn until it succeeds or ctx is cancelled, logging failures
+func untilOK[T any](ctx context.Context, msg string, fn func(ctx context.Context) (T, error)) (T, error) {
+	for {
+		value, err := fn(ctx)
+		if err == nil {
+			return value, nil
+		}
+		log.Error().Err(err).Dur("retry_in", retryInterval).Msg(msg)
+		if err := sleep(ctx, retryInterval); err != nil {
+			return value, err
+		}
+	}
+}
+
+// sleep waits for d, returning ctx's error when it is cancelled first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
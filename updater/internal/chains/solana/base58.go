@@ -0,0 +1,54 @@
+package solana
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// base58Alphabet is the Bitcoin alphabet Solana encodes addresses with
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var bigRadix = big.NewInt(58)
+
+// encodeBase58 encodes bytes, each leading zero byte as a leading 1
+func encodeBase58(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	var out []byte
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, bigRadix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// decodeBase58 decodes a base58 string
+func decodeBase58(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errors.New("empty base58 string")
+	}
+	n := new(big.Int)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, errors.New("invalid base58 character")
+		}
+		n.Mul(n, bigRadix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
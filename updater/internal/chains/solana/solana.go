@@ -0,0 +1,357 @@
+// Package solana sets drand rounds on a Solana program. The program is expected
+// to follow the Anchor conventions: a set_randomness instruction taking the
+// round, timestamp, randomness and signature, Borsh encoded, with the oracle
+// state account (writable) and the authority (signer) as accounts, and an
+// oracle state account starting with the latest round, after the account
+// discriminator. The authority pays the transaction fees.
+package solana
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"drand-oracle-updater/internal/chains"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults of the options left to zero
+const (
+	DefaultCommitment          = "confirmed"
+	DefaultConfirmTimeout      = 90 * time.Second
+	defaultConfirmPollInterval = 500 * time.Millisecond
+)
+
+// setRandomnessDiscriminator is the Anchor discriminator of the set_randomness
+// instruction
+var setRandomnessDiscriminator = anchorDiscriminator("global:set_randomness")
+
+// Options configures the submitter
+type Options struct {
+	// RPC is the JSON-RPC endpoint of a Solana node
+	RPC string
+	// ProgramID is the base58 address of the oracle program
+	ProgramID string
+	// OracleAccount is the base58 address of the oracle state account
+	OracleAccount string
+	// Commitment is the commitment the submitter waits for and reads the
+	// latest round at: processed, confirmed or finalized
+	Commitment string
+	// ConfirmTimeout bounds the wait for a transaction's confirmation. It should
+	// stay above the ~60 seconds a blockhash is valid for.
+	ConfirmTimeout time.Duration
+}
+
+// Submitter sets drand rounds on a Solana program
+type Submitter struct {
+	options       Options
+	key           ed25519.PrivateKey
+	authority     [32]byte
+	program       [32]byte
+	oracleAccount [32]byte
+	client        *http.Client
+	requestID     atomic.Uint64
+}
+
+var _ chains.ChainSubmitter = (*Submitter)(nil)
+
+// NewSubmitter returns a submitter signing with privateKey, a 64-byte keypair
+// as a JSON array of bytes, the solana-keygen format, or base58 encoded
+func NewSubmitter(options Options, privateKey string) (*Submitter, error) {
+	if options.RPC == "" {
+		return nil, errors.New("solana rpc is required")
+	}
+	if options.Commitment == "" {
+		options.Commitment = DefaultCommitment
+	}
+	switch options.Commitment {
+	case "processed", "confirmed", "finalized":
+	default:
+		return nil, fmt.Errorf("invalid solana commitment %q, expected processed, confirmed or finalized", options.Commitment)
+	}
+	if options.ConfirmTimeout <= 0 {
+		options.ConfirmTimeout = DefaultConfirmTimeout
+	}
+	key, err := parseKeypair(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	program, err := parsePublicKey(options.ProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid solana program id: %w", err)
+	}
+	oracleAccount, err := parsePublicKey(options.OracleAccount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid solana oracle account: %w", err)
+	}
+	return &Submitter{
+		options:       options,
+		key:           key,
+		authority:     [32]byte(key.Public().(ed25519.PublicKey)),
+		program:       program,
+		oracleAccount: oracleAccount,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Family implements chains.ChainSubmitter
+func (s *Submitter) Family() string {
+	return chains.FamilySolana
+}
+
+// Authority returns the base58 address of the authority signing the rounds
+func (s *Submitter) Authority() string {
+	return encodeBase58(s.authority[:])
+}
+
+// LatestRound reads the latest round from the oracle state account
+func (s *Submitter) LatestRound(ctx context.Context) (uint64, error) {
+	var result struct {
+		Value *struct {
+			Data  []string `json:"data"`
+			Owner string   `json:"owner"`
+		} `json:"value"`
+	}
+	err := s.call(ctx, "getAccountInfo", []any{
+		encodeBase58(s.oracleAccount[:]),
+		map[string]string{"encoding": "base64", "commitment": s.options.Commitment},
+	}, &result)
+	if err != nil {
+		return 0, err
+	}
+	if result.Value == nil || len(result.Value.Data) == 0 {
+		return 0, fmt.Errorf("oracle account %s not found", encodeBase58(s.oracleAccount[:]))
+	}
+	if result.Value.Owner != encodeBase58(s.program[:]) {
+		return 0, fmt.Errorf("oracle account is owned by %s, not the oracle program", result.Value.Owner)
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Value.Data[0])
+	if err != nil {
+		return 0, err
+	}
+	// Account discriminator, then the latest round
+	if len(data) < 16 {
+		return 0, fmt.Errorf("oracle account data too short: %d bytes", len(data))
+	}
+	return binary.LittleEndian.Uint64(data[8:16]), nil
+}
+
+// Submit sends the set_randomness transaction of a round and waits for its
+// confirmation at the configured commitment
+func (s *Submitter) Submit(ctx context.Context, beacon chains.Beacon) error {
+	var blockhash struct {
+		Value struct {
+			Blockhash string `json:"blockhash"`
+		} `json:"value"`
+	}
+	err := s.call(ctx, "getLatestBlockhash", []any{map[string]string{"commitment": s.options.Commitment}}, &blockhash)
+	if err != nil {
+		return fmt.Errorf("error getting latest blockhash: %w", err)
+	}
+	recent, err := parsePublicKey(blockhash.Value.Blockhash)
+	if err != nil {
+		return fmt.Errorf("invalid blockhash: %w", err)
+	}
+
+	tx := s.setRandomnessTransaction(beacon, recent)
+	var signature string
+	err = s.call(ctx, "sendTransaction", []any{
+		base64.StdEncoding.EncodeToString(tx),
+		map[string]string{"encoding": "base64", "preflightCommitment": s.options.Commitment},
+	}, &signature)
+	if err != nil {
+		return fmt.Errorf("error sending set_randomness transaction: %w", err)
+	}
+	log.Info().Uint64("round", beacon.Round).Str("signature", signature).Msg("Sent set_randomness transaction")
+	return s.waitConfirmed(ctx, signature)
+}
+
+// setRandomnessTransaction returns a signed legacy transaction calling
+// set_randomness
+func (s *Submitter) setRandomnessTransaction(beacon chains.Beacon, recentBlockhash [32]byte) []byte {
+	var data bytes.Buffer
+	data.Write(setRandomnessDiscriminator[:])
+	_ = binary.Write(&data, binary.LittleEndian, beacon.Round)
+	_ = binary.Write(&data, binary.LittleEndian, beacon.Timestamp)
+	data.Write(beacon.Randomness[:])
+	_ = binary.Write(&data, binary.LittleEndian, uint32(len(beacon.Signature)))
+	data.Write(beacon.Signature)
+
+	// Accounts are ordered writable signers, writable non-signers, then
+	// read-only non-signers
+	var message bytes.Buffer
+	message.Write([]byte{1, 0, 1})
+	writeCompactU16(&message, 3)
+	message.Write(s.authority[:])
+	message.Write(s.oracleAccount[:])
+	message.Write(s.program[:])
+	message.Write(recentBlockhash[:])
+	writeCompactU16(&message, 1)
+	message.WriteByte(2)
+	writeCompactU16(&message, 2)
+	message.Write([]byte{1, 0})
+	writeCompactU16(&message, data.Len())
+	message.Write(data.Bytes())
+
+	var tx bytes.Buffer
+	writeCompactU16(&tx, 1)
+	tx.Write(ed25519.Sign(s.key, message.Bytes()))
+	tx.Write(message.Bytes())
+	return tx.Bytes()
+}
+
+// waitConfirmed polls the status of a transaction until it reaches the
+// configured commitment, fails, or the confirmation timeout expires
+func (s *Submitter) waitConfirmed(ctx context.Context, signature string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.options.ConfirmTimeout)
+	defer cancel()
+	ticker := time.NewTicker(defaultConfirmPollInterval)
+	defer ticker.Stop()
+	for {
+		var statuses struct {
+			Value []*struct {
+				ConfirmationStatus string          `json:"confirmationStatus"`
+				Err                json.RawMessage `json:"err"`
+			} `json:"value"`
+		}
+		err := s.call(ctx, "getSignatureStatuses", []any{[]string{signature}}, &statuses)
+		if err == nil && len(statuses.Value) == 1 && statuses.Value[0] != nil {
+			status := statuses.Value[0]
+			if len(status.Err) > 0 && string(status.Err) != "null" {
+				return fmt.Errorf("set_randomness transaction %s failed: %s", signature, status.Err)
+			}
+			if commitmentReached(status.ConfirmationStatus, s.options.Commitment) {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("set_randomness transaction %s not confirmed: %w", signature, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// commitmentReached reports whether a confirmation status is at least the
+// required commitment
+func commitmentReached(status string, required string) bool {
+	levels := map[string]int{"processed": 1, "confirmed": 2, "finalized": 3}
+	return levels[status] >= levels[required] && levels[status] > 0
+}
+
+// rpcError is a Solana JSON-RPC error
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("solana rpc error %d: %s", e.Code, e.Message)
+}
+
+// call sends a JSON-RPC request and decodes its result
+func (s *Submitter) call(ctx context.Context, method string, params []any, result any) error {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      s.requestID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.options.RPC, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("solana rpc %s: unexpected status %s", method, resp.Status)
+	}
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// anchorDiscriminator returns the first 8 bytes of the SHA-256 of an Anchor
+// namespaced name
+func anchorDiscriminator(name string) [8]byte {
+	hash := sha256.Sum256([]byte(name))
+	return [8]byte(hash[:8])
+}
+
+// writeCompactU16 writes the variable length length prefix of Solana arrays
+func writeCompactU16(buf *bytes.Buffer, n int) {
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			buf.WriteByte(b)
+			return
+		}
+		buf.WriteByte(b | 0x80)
+	}
+}
+
+// parseKeypair parses a 64-byte ed25519 keypair
+func parseKeypair(value string) (ed25519.PrivateKey, error) {
+	value = strings.TrimSpace(value)
+	var key []byte
+	if strings.HasPrefix(value, "[") {
+		var numbers []int
+		if err := json.Unmarshal([]byte(value), &numbers); err != nil {
+			return nil, fmt.Errorf("invalid solana keypair: %w", err)
+		}
+		for _, n := range numbers {
+			if n < 0 || n > 255 {
+				return nil, errors.New("invalid solana keypair: byte out of range")
+			}
+			key = append(key, byte(n))
+		}
+	} else {
+		var err error
+		key, err = decodeBase58(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid solana keypair: %w", err)
+		}
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid solana keypair: %d bytes, expected %d", len(key), ed25519.PrivateKeySize)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// parsePublicKey parses a base58 encoded 32-byte address or hash
+func parsePublicKey(value string) ([32]byte, error) {
+	decoded, err := decodeBase58(value)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if len(decoded) != 32 {
+		return [32]byte{}, fmt.Errorf("%q is %d bytes, expected 32", value, len(decoded))
+	}
+	return [32]byte(decoded), nil
+}
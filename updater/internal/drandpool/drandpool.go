@@ -0,0 +1,629 @@
+// Package drandpool spreads the requests of a drand client over several HTTP
+// relays, tracking the latency, error rate and staleness of each one. Slow or
+// erroring relays are demoted behind the healthy ones, and relays failing
+// repeatedly are pruned, only probed in the background until they answer
+// again, so that one flaky relay does not stall every request on its timeout.
+// Among the healthy relays, the closest one, by round-trip time and staleness,
+// serves the requests while the others cross-check its rounds.
+package drandpool
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/client"
+	drandHTTPClient "github.com/drand/drand/client/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults of the options left to zero
+const (
+	DefaultTimeout       = 5 * time.Second
+	DefaultMaxLatency    = 2 * time.Second
+	DefaultMaxErrorRate  = 0.2
+	DefaultPruneAfter    = 3
+	DefaultProbeInterval = 1 * time.Minute
+)
+
+// Results of a cross-check
+const (
+	crossCheckAgree    = "agree"
+	crossCheckDisagree = "disagree"
+	crossCheckFailure  = "failure"
+)
+
+const (
+	// averageWeight is the weight of the latest request in the moving
+	// averages of the latency and the error rate
+	averageWeight = 0.2
+
+	labelRelay = "relay"
+)
+
+// State is the health state of a relay
+type State string
+
+const (
+	// StateHealthy relays are tried first, best score first
+	StateHealthy State = "healthy"
+	// StateDemoted relays are slow or erroring, tried after the healthy ones
+	StateDemoted State = "demoted"
+	// StatePruned relays failed repeatedly, only tried when every other relay
+	// failed, and probed in the background
+	StatePruned State = "pruned"
+)
+
+// stateValues are the values of the state metric
+var stateValues = map[State]float64{
+	StatePruned:  0,
+	StateDemoted: 1,
+	StateHealthy: 2,
+}
+
+// Options configures a pool
+type Options struct {
+	// Timeout bounds a request to a single relay
+	Timeout time.Duration
+	// MaxLatency demotes a relay whose average latency exceeds it
+	MaxLatency time.Duration
+	// MaxErrorRate demotes a relay whose average error rate exceeds it
+	MaxErrorRate float64
+	// PruneAfter prunes a relay after that many consecutive failures
+	PruneAfter int
+	// ProbeInterval is the interval between probes of the latest round on
+	// every relay, measuring their round-trip time and staleness
+	ProbeInterval time.Duration
+	// LatencyWeight and StalenessWeight weigh a relay's average round-trip
+	// time and its staleness, both in seconds, in its score. Among relays in
+	// the same state, the lowest score is tried first. Both default to 1 when
+	// neither is set.
+	LatencyWeight   float64
+	StalenessWeight float64
+	// CrossChecks is the number of other relays every due round served is
+	// checked against in the background, 0 disables cross-checks
+	CrossChecks int
+	// MetricLabels are attached to every metric of the pool
+	MetricLabels map[string]string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.MaxLatency <= 0 {
+		o.MaxLatency = DefaultMaxLatency
+	}
+	if o.MaxErrorRate <= 0 {
+		o.MaxErrorRate = DefaultMaxErrorRate
+	}
+	if o.PruneAfter <= 0 {
+		o.PruneAfter = DefaultPruneAfter
+	}
+	if o.ProbeInterval <= 0 {
+		o.ProbeInterval = DefaultProbeInterval
+	}
+	if o.LatencyWeight <= 0 && o.StalenessWeight <= 0 {
+		o.LatencyWeight = 1
+		o.StalenessWeight = 1
+	}
+	return o
+}
+
+// RelayStatus is the health of a relay
+type RelayStatus struct {
+	Relay     string        `json:"relay"`
+	State     State         `json:"state"`
+	Latency   time.Duration `json:"latency"`
+	ErrorRate float64       `json:"error_rate"`
+	// Staleness is the number of rounds the relay's latest round was behind
+	// at its latest probe
+	Staleness uint64  `json:"staleness"`
+	Score     float64 `json:"score"`
+	// Failures is the number of consecutive failures
+	Failures  int  `json:"failures"`
+	Preferred bool `json:"preferred"`
+	// LastError is the error of the latest failed request, kept after the
+	// relay recovered
+	LastError string `json:"last_error,omitempty"`
+}
+
+// relay is a drand relay and its health
+type relay struct {
+	client client.Client
+	// name identifies the relay in logs and metrics without its path
+	name string
+
+	mu        sync.Mutex
+	latency   time.Duration
+	errorRate float64
+	staleness uint64
+	failures  int
+	pruned    bool
+	lastError string
+}
+
+// Pool is a drand client sending each request to its relays in order of
+// health until one answers. Results are not verified, the pool is meant to
+// be wrapped by a verifying client.
+type Pool struct {
+	relays  []*relay
+	info    *chain.Info
+	options Options
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// preferred is the relay tried first by the latest request
+	preferred      *relay
+	preferredMutex sync.Mutex
+
+	state       *prometheus.GaugeVec
+	latency     *prometheus.GaugeVec
+	errorRate   *prometheus.GaugeVec
+	requests    *prometheus.CounterVec
+	prunesTotal *prometheus.CounterVec
+	recoveries  *prometheus.CounterVec
+	staleness   *prometheus.GaugeVec
+	score       *prometheus.GaugeVec
+	preferredUp *prometheus.GaugeVec
+	selected    *prometheus.CounterVec
+	crossChecks *prometheus.CounterVec
+}
+
+// New returns a pool over the relays at urls serving the drand network of
+// chainHash, taking the chain info from the first relay answering, and starts
+// probing the relays
+func New(urls []string, chainHash []byte, options Options) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no drand relay")
+	}
+	if _, ok := options.MetricLabels[labelRelay]; ok {
+		return nil, fmt.Errorf("deployment label %q is reserved for metric labels", labelRelay)
+	}
+	p := &Pool{options: options.withDefaults(), done: make(chan struct{})}
+
+	var errs []error
+	for _, raw := range urls {
+		c, err := drandHTTPClient.New(raw, chainHash, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", relayName(raw), err))
+			continue
+		}
+		p.info, err = c.Info(context.Background())
+		_ = c.Close()
+		if err == nil {
+			break
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", relayName(raw), err))
+	}
+	if p.info == nil {
+		return nil, fmt.Errorf("no drand relay serves the chain info: %w", errors.Join(errs...))
+	}
+	for _, raw := range urls {
+		c, err := drandHTTPClient.NewWithInfo(raw, p.info, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid drand relay %q: %w", relayName(raw), err)
+		}
+		p.relays = append(p.relays, &relay{client: c, name: relayName(raw)})
+	}
+
+	factory := promauto.With(prometheus.WrapRegistererWith(options.MetricLabels, prometheus.DefaultRegisterer))
+	p.state = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_relay_state",
+		Help: "Health state of a drand relay: 2 healthy, 1 demoted, 0 pruned",
+	}, []string{labelRelay})
+	p.latency = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_relay_latency_seconds",
+		Help: "Moving average of the latency of a drand relay",
+	}, []string{labelRelay})
+	p.errorRate = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_relay_error_rate",
+		Help: "Moving average of the error rate of a drand relay",
+	}, []string{labelRelay})
+	p.requests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_relay_requests_total",
+		Help: "Total number of requests to a drand relay by result",
+	}, []string{labelRelay, "result"})
+	p.prunesTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_relay_prunes_total",
+		Help: "Total number of times a drand relay was pruned",
+	}, []string{labelRelay})
+	p.recoveries = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_relay_recoveries_total",
+		Help: "Total number of times a pruned drand relay recovered",
+	}, []string{labelRelay})
+	p.staleness = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_relay_staleness_rounds",
+		Help: "Number of rounds the latest round of a drand relay was behind at its latest probe",
+	}, []string{labelRelay})
+	p.score = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_relay_score",
+		Help: "Selection score of a drand relay, lower is preferred among relays in the same state",
+	}, []string{labelRelay})
+	p.preferredUp = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_relay_preferred",
+		Help: "Whether a drand relay is tried first (1) or not (0)",
+	}, []string{labelRelay})
+	p.selected = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_relay_selected_total",
+		Help: "Total number of requests served by a drand relay",
+	}, []string{labelRelay})
+	p.crossChecks = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_relay_cross_checks_total",
+		Help: "Total number of cross-checks of served rounds against a drand relay by result",
+	}, []string{labelRelay, "result"})
+	for _, r := range p.relays {
+		p.state.WithLabelValues(r.name).Set(stateValues[StateHealthy])
+		p.latency.WithLabelValues(r.name).Set(0)
+		p.errorRate.WithLabelValues(r.name).Set(0)
+		p.staleness.WithLabelValues(r.name).Set(0)
+		p.score.WithLabelValues(r.name).Set(0)
+		p.preferredUp.WithLabelValues(r.name).Set(0)
+	}
+
+	go p.probe()
+	return p, nil
+}
+
+// relayName is the scheme and host of a relay URL
+func relayName(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Get returns a round, the latest when round is 0, from the relays in order
+// of health until one answers. A round not due yet is only asked to the first
+// relay, as the others would not have it either, and its failure is not held
+// against the relay. A due round is cross-checked in the background.
+func (p *Pool) Get(ctx context.Context, round uint64) (client.Result, error) {
+	due := round == 0 || round <= p.RoundAt(time.Now())
+	var errs []error
+	relays := p.order()
+	p.prefer(relays[0])
+	for i, r := range relays {
+		result, err := p.attempt(ctx, r, round, due)
+		if err == nil {
+			p.selected.WithLabelValues(r.name).Inc()
+			if due && p.options.CrossChecks > 0 {
+				go p.crossCheck(result, r, relays[i+1:])
+			}
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", r.name, err))
+		if !due {
+			break
+		}
+	}
+	return nil, fmt.Errorf("all drand relays failed: %w", errors.Join(errs...))
+}
+
+// attempt gets a round from a relay, tracking its health when the round is due
+func (p *Pool) attempt(ctx context.Context, r *relay, round uint64, due bool) (client.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.options.Timeout)
+	defer cancel()
+	start := time.Now()
+	result, err := r.client.Get(ctx, round)
+	if !due || (err != nil && ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded)) {
+		return result, err
+	}
+	// A relay timing out is charged the whole timeout
+	p.observe(r, time.Since(start), err)
+	if err == nil && round == 0 {
+		p.observeStaleness(r, result.Round())
+	}
+	return result, err
+}
+
+// order returns the relays to try: the healthy ones by score, then the
+// demoted ones by score, then the pruned ones as a last resort
+func (p *Pool) order() []*relay {
+	type ranked struct {
+		relay *relay
+		state State
+		score float64
+	}
+	ranks := make([]ranked, len(p.relays))
+	for i, r := range p.relays {
+		r.mu.Lock()
+		ranks[i] = ranked{relay: r, state: p.stateLocked(r), score: p.scoreLocked(r)}
+		r.mu.Unlock()
+	}
+	slices.SortStableFunc(ranks, func(a, b ranked) int {
+		if a.state != b.state {
+			return int(stateValues[b.state] - stateValues[a.state])
+		}
+		switch {
+		case a.score < b.score:
+			return -1
+		case a.score > b.score:
+			return 1
+		}
+		return 0
+	})
+	relays := make([]*relay, len(ranks))
+	for i, rank := range ranks {
+		relays[i] = rank.relay
+	}
+	return relays
+}
+
+// scoreLocked returns the score of a relay, whose lock is held: its weighted
+// average round-trip time and staleness, in seconds
+func (p *Pool) scoreLocked(r *relay) float64 {
+	staleness := time.Duration(r.staleness) * p.info.Period
+	return p.options.LatencyWeight*r.latency.Seconds() + p.options.StalenessWeight*staleness.Seconds()
+}
+
+// prefer records the relay tried first, logging when it changes
+func (p *Pool) prefer(r *relay) {
+	p.preferredMutex.Lock()
+	previous := p.preferred
+	p.preferred = r
+	p.preferredMutex.Unlock()
+	if previous == r {
+		return
+	}
+	p.preferredUp.WithLabelValues(r.name).Set(1)
+	event := log.Info().Str("relay", r.name)
+	if previous != nil {
+		p.preferredUp.WithLabelValues(previous.name).Set(0)
+		previous.mu.Lock()
+		event = event.Str("previous", previous.name).Float64("previous_score", p.scoreLocked(previous))
+		previous.mu.Unlock()
+	}
+	r.mu.Lock()
+	event = event.
+		Str("state", string(p.stateLocked(r))).
+		Float64("score", p.scoreLocked(r)).
+		Dur("latency", r.latency).
+		Uint64("staleness", r.staleness)
+	r.mu.Unlock()
+	event.Msg("Preferred drand relay changed")
+}
+
+// observeStaleness records how many rounds the latest round of a relay is
+// behind the current round
+func (p *Pool) observeStaleness(r *relay, latest uint64) {
+	var staleness uint64
+	if current := p.RoundAt(time.Now()); current > latest {
+		staleness = current - latest
+	}
+	r.mu.Lock()
+	r.staleness = staleness
+	score := p.scoreLocked(r)
+	r.mu.Unlock()
+	p.staleness.WithLabelValues(r.name).Set(float64(staleness))
+	p.score.WithLabelValues(r.name).Set(score)
+}
+
+// crossCheck asks the next relays not pruned for a round served by another
+// relay, and compares their signatures. Relays serving a different signature
+// are reported, the verifying client wrapping the pool rejects the invalid one.
+func (p *Pool) crossCheck(result client.Result, served *relay, others []*relay) {
+	checked := 0
+	for _, r := range others {
+		if checked == p.options.CrossChecks {
+			return
+		}
+		r.mu.Lock()
+		pruned := r.pruned
+		r.mu.Unlock()
+		if pruned {
+			continue
+		}
+		checked++
+		other, err := p.attempt(context.Background(), r, result.Round(), true)
+		switch {
+		case err != nil:
+			p.crossChecks.WithLabelValues(r.name, crossCheckFailure).Inc()
+		case !bytes.Equal(other.Signature(), result.Signature()):
+			p.crossChecks.WithLabelValues(r.name, crossCheckDisagree).Inc()
+			log.Warn().
+				Uint64("round", result.Round()).
+				Str("relay", served.name).
+				Str("signature", hex.EncodeToString(result.Signature())).
+				Str("other_relay", r.name).
+				Str("other_signature", hex.EncodeToString(other.Signature())).
+				Msg("Drand relays disagree on a round")
+		default:
+			p.crossChecks.WithLabelValues(r.name, crossCheckAgree).Inc()
+		}
+	}
+}
+
+// stateLocked returns the state of a relay, whose lock is held
+func (p *Pool) stateLocked(r *relay) State {
+	switch {
+	case r.pruned:
+		return StatePruned
+	case r.latency > p.options.MaxLatency || r.errorRate > p.options.MaxErrorRate:
+		return StateDemoted
+	default:
+		return StateHealthy
+	}
+}
+
+// observe updates the health of a relay after a request
+func (p *Pool) observe(r *relay, latency time.Duration, err error) {
+	r.mu.Lock()
+	previous := p.stateLocked(r)
+	r.latency += time.Duration(averageWeight * float64(latency-r.latency))
+	failed := 0.0
+	if err != nil {
+		failed = 1
+		r.failures++
+		r.lastError = err.Error()
+	} else {
+		r.failures = 0
+	}
+	r.errorRate += averageWeight * (failed - r.errorRate)
+	pruned := err != nil && !r.pruned && r.failures >= p.options.PruneAfter
+	recovered := err == nil && r.pruned
+	if pruned {
+		r.pruned = true
+	}
+	if recovered {
+		r.pruned = false
+	}
+	state := p.stateLocked(r)
+	status := RelayStatus{Relay: r.name, State: state, Latency: r.latency, ErrorRate: r.errorRate, Score: p.scoreLocked(r), Failures: r.failures}
+	r.mu.Unlock()
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	p.requests.WithLabelValues(r.name, result).Inc()
+	p.latency.WithLabelValues(r.name).Set(status.Latency.Seconds())
+	p.errorRate.WithLabelValues(r.name).Set(status.ErrorRate)
+	p.score.WithLabelValues(r.name).Set(status.Score)
+	p.state.WithLabelValues(r.name).Set(stateValues[state])
+
+	switch {
+	case pruned:
+		p.prunesTotal.WithLabelValues(r.name).Inc()
+		log.Warn().Err(err).
+			Str("relay", r.name).
+			Int("failures", status.Failures).
+			Dur("probe_interval", p.options.ProbeInterval).
+			Msg("Drand relay pruned after repeated failures")
+	case recovered:
+		p.recoveries.WithLabelValues(r.name).Inc()
+		log.Info().Str("relay", r.name).Dur("latency", latency).Msg("Pruned drand relay recovered")
+	case state != previous:
+		log.Info().
+			Str("relay", r.name).
+			Str("state", string(state)).
+			Dur("latency", status.Latency).
+			Float64("error_rate", status.ErrorRate).
+			Msg("Drand relay health changed")
+	}
+}
+
+// probe gets the latest round from every relay every probe interval, until
+// the pool is closed, measuring their round-trip time and staleness and
+// bringing the pruned relays back once they answer
+func (p *Pool) probe() {
+	ticker := time.NewTicker(p.options.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		var wg sync.WaitGroup
+		for _, r := range p.relays {
+			wg.Add(1)
+			go func(r *relay) {
+				defer wg.Done()
+				_, _ = p.attempt(context.Background(), r, 0, true)
+			}(r)
+		}
+		wg.Wait()
+	}
+}
+
+// Status returns the health of every relay, in configuration order
+func (p *Pool) Status() []RelayStatus {
+	p.preferredMutex.Lock()
+	preferred := p.preferred
+	p.preferredMutex.Unlock()
+	statuses := make([]RelayStatus, len(p.relays))
+	for i, r := range p.relays {
+		r.mu.Lock()
+		statuses[i] = RelayStatus{
+			Relay:     r.name,
+			State:     p.stateLocked(r),
+			Latency:   r.latency,
+			ErrorRate: r.errorRate,
+			Staleness: r.staleness,
+			Score:     p.scoreLocked(r),
+			Failures:  r.failures,
+			Preferred: r == preferred,
+			LastError: r.lastError,
+		}
+		r.mu.Unlock()
+	}
+	return statuses
+}
+
+// Watch emits the rounds watched on the preferred relay, switching to the
+// next one when its watch ends
+func (p *Pool) Watch(ctx context.Context) <-chan client.Result {
+	out := make(chan client.Result)
+	go func() {
+		defer close(out)
+		for ctx.Err() == nil {
+			r := p.order()[0]
+			p.prefer(r)
+			start := time.Now()
+			for result := range r.client.Watch(ctx) {
+				p.selected.WithLabelValues(r.name).Inc()
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			p.observe(r, time.Since(start), errors.New("watch ended"))
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.done:
+				return
+			case <-time.After(p.info.Period):
+			}
+		}
+	}()
+	return out
+}
+
+// Info returns the chain info of the drand network
+func (p *Pool) Info(ctx context.Context) (*chain.Info, error) {
+	return p.info, nil
+}
+
+// RoundAt returns the latest round published at t
+func (p *Pool) RoundAt(t time.Time) uint64 {
+	return chain.CurrentRound(t.Unix(), p.info.Period, p.info.GenesisTime)
+}
+
+// String lists the relays of the pool
+func (p *Pool) String() string {
+	names := make([]string, len(p.relays))
+	for i, r := range p.relays {
+		names[i] = r.name
+	}
+	return fmt.Sprintf("drandpool(%v)", names)
+}
+
+// Close stops the probes and closes every relay client
+func (p *Pool) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		for _, r := range p.relays {
+			_ = r.client.Close()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,312 @@
+// Package fallback provides a drand client that falls back to reading rounds
+// from a Drand Oracle contract on another chain when every drand relay fails.
+package fallback
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/binding"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/client"
+	"github.com/drand/drand/crypto"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Sources flag the rounds read from the fallback oracle
+const (
+	// SourceOracle is a round read from the fallback oracle whose signature was verified
+	SourceOracle = "fallback_oracle"
+	// SourceOracleUnverified is a round read from the fallback oracle whose
+	// signature could not be verified, because the previous signature of a
+	// chained scheme is missing from the oracle
+	SourceOracleUnverified = "fallback_oracle_unverified"
+)
+
+// maxWatchBacklog bounds the rounds emitted at once by Watch when the fallback oracle is far ahead
+const maxWatchBacklog = 100
+
+// ErrRoundNotFound is returned when the fallback oracle does not have a round
+var ErrRoundNotFound = errors.New("round not found on the fallback oracle")
+
+// Result is a round read from the fallback oracle
+type Result struct {
+	round      uint64
+	randomness []byte
+	signature  []byte
+	verified   bool
+}
+
+func (r *Result) Round() uint64      { return r.round }
+func (r *Result) Randomness() []byte { return r.randomness }
+func (r *Result) Signature() []byte  { return r.signature }
+
+// Source returns SourceOracle or SourceOracleUnverified
+func (r *Result) Source() string {
+	if r.verified {
+		return SourceOracle
+	}
+	return SourceOracleUnverified
+}
+
+// Options configures the fallback
+type Options struct {
+	// StallTimeout is how long Watch waits for a round from the relays before
+	// polling the fallback oracle, 0 uses three drand periods
+	StallTimeout time.Duration
+	// RequireVerified rejects rounds whose signature cannot be verified
+	RequireVerified bool
+}
+
+// Client is a drand client reading from the wrapped relays client first and
+// from a Drand Oracle contract on another chain when the relays fail
+type Client struct {
+	client.Client
+	oracle        *binding.Binding
+	oracleAddress common.Address
+	options       Options
+
+	mu   sync.Mutex
+	info *chain.Info
+	// checked is set once the fallback oracle's chain hash has been checked
+	checked bool
+}
+
+func NewClient(relays client.Client, oracle *binding.Binding, oracleAddress common.Address, options Options) *Client {
+	return &Client{
+		Client:        relays,
+		oracle:        oracle,
+		oracleAddress: oracleAddress,
+		options:       options,
+	}
+}
+
+// Info returns the chain info from the relays, or the last one seen when they fail
+func (c *Client) Info(ctx context.Context) (*chain.Info, error) {
+	info, err := c.Client.Info(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.info = info
+		return info, nil
+	}
+	if c.info != nil {
+		return c.info, nil
+	}
+	return nil, err
+}
+
+// Get returns a round from the relays, or from the fallback oracle when they fail
+func (c *Client) Get(ctx context.Context, round uint64) (client.Result, error) {
+	result, err := c.Client.Get(ctx, round)
+	if err == nil {
+		return result, nil
+	}
+	log.Warn().Err(err).Uint64("round", round).Msg("Drand relays failed, reading round from the fallback oracle")
+	fallbackResult, fallbackErr := c.get(ctx, round)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%w, fallback oracle: %w", err, fallbackErr)
+	}
+	return fallbackResult, nil
+}
+
+// Watch emits the relays' rounds, and polls the fallback oracle while the
+// relays have not produced a round for the stall timeout
+func (c *Client) Watch(ctx context.Context) <-chan client.Result {
+	out := make(chan client.Result)
+	relays := c.Client.Watch(ctx)
+
+	go func() {
+		defer close(out)
+
+		info, err := c.Info(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get drand info, fallback oracle disabled")
+			for result := range relays {
+				out <- result
+			}
+			return
+		}
+		stallTimeout := c.options.StallTimeout
+		if stallTimeout == 0 {
+			stallTimeout = 3 * info.Period
+		}
+
+		var last uint64
+		emit := func(result client.Result) bool {
+			if result.Round() <= last {
+				return true
+			}
+			last = result.Round()
+			select {
+			case out <- result:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		stall := time.NewTimer(stallTimeout)
+		defer stall.Stop()
+		var poll <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case result, ok := <-relays:
+				if !ok {
+					// The relays gave up, keep serving from the fallback oracle
+					relays = nil
+					poll = time.After(0)
+					continue
+				}
+				if poll != nil && relays != nil {
+					log.Info().Uint64("round", result.Round()).Msg("Drand relays recovered, stopped polling the fallback oracle")
+					poll = nil
+				}
+				if !emit(result) {
+					return
+				}
+				stall.Reset(stallTimeout)
+			case <-stall.C:
+				log.Warn().Dur("stall_timeout", stallTimeout).Msg("No round from the drand relays, polling the fallback oracle")
+				poll = time.After(0)
+			case <-poll:
+				for _, result := range c.poll(ctx, last) {
+					if !emit(result) {
+						return
+					}
+				}
+				poll = time.After(info.Period)
+			}
+		}
+	}()
+	return out
+}
+
+// poll returns the rounds after last available on the fallback oracle
+func (c *Client) poll(ctx context.Context, last uint64) []client.Result {
+	latest, err := c.oracle.LatestRound(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get latest round from the fallback oracle")
+		return nil
+	}
+	from := last + 1
+	if last == 0 || latest-last > maxWatchBacklog {
+		from = latest - min(latest, maxWatchBacklog) + 1
+	}
+
+	var results []client.Result
+	for round := from; round <= latest; round++ {
+		result, err := c.get(ctx, round)
+		if err != nil {
+			log.Error().Err(err).Uint64("round", round).Msg("Failed to read round from the fallback oracle")
+			break
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// get reads and verifies a round from the fallback oracle, round 0 being its latest round
+func (c *Client) get(ctx context.Context, round uint64) (*Result, error) {
+	info, err := c.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkChainHash(ctx, info); err != nil {
+		return nil, err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	if round == 0 {
+		round, err = c.oracle.LatestRound(opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	random, err := c.oracle.GetRandomnessFromRound(opts, round)
+	if err != nil {
+		return nil, err
+	}
+	if random.Round == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrRoundNotFound, round)
+	}
+
+	result := &Result{
+		round:      random.Round,
+		randomness: random.Randomness[:],
+		signature:  random.Signature,
+	}
+	if !bytes.Equal(crypto.RandomnessFromSignature(result.signature), result.randomness) {
+		return nil, fmt.Errorf("round %d from the fallback oracle: randomness does not match the signature", round)
+	}
+	if err := c.verify(ctx, info, result); err != nil {
+		return nil, fmt.Errorf("round %d from the fallback oracle: %w", round, err)
+	}
+	if !result.verified && c.options.RequireVerified {
+		return nil, fmt.Errorf("round %d from the fallback oracle cannot be verified", round)
+	}
+	log.Warn().
+		Uint64("round", result.round).
+		Str("oracle", c.oracleAddress.Hex()).
+		Str("source", result.Source()).
+		Msg("Read round from the fallback oracle")
+	return result, nil
+}
+
+// verify checks the round's signature against the network's public key. Chained
+// schemes sign over the previous signature, which is read from the previous
+// round on the fallback oracle; without it the round is left unverified.
+func (c *Client) verify(ctx context.Context, info *chain.Info, result *Result) error {
+	scheme, err := crypto.SchemeFromName(info.Scheme)
+	if err != nil {
+		return err
+	}
+
+	beacon := &chain.Beacon{Round: result.round, Signature: result.signature}
+	if scheme.Name == crypto.DefaultSchemeID {
+		if result.round <= 1 {
+			return nil
+		}
+		previous, err := c.oracle.GetRandomnessFromRound(&bind.CallOpts{Context: ctx}, result.round-1)
+		if err != nil || previous.Round == 0 {
+			return nil
+		}
+		beacon.PreviousSig = previous.Signature
+	}
+
+	if err := scheme.VerifyBeacon(beacon, info.PublicKey); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	result.verified = true
+	return nil
+}
+
+// checkChainHash makes sure the fallback oracle serves the same drand network
+func (c *Client) checkChainHash(ctx context.Context, info *chain.Info) error {
+	c.mu.Lock()
+	checked := c.checked
+	c.mu.Unlock()
+	if checked {
+		return nil
+	}
+
+	chainHash, err := c.oracle.CHAINHASH(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(chainHash[:], info.Hash()) {
+		return fmt.Errorf("fallback oracle %s serves chain %x, expected %s", c.oracleAddress.Hex(), chainHash, info.HashString())
+	}
+	c.mu.Lock()
+	c.checked = true
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,234 @@
+// Package finality resolves the latest block a chain considers committed,
+// from the safe or finalized block tags where the RPC supports them and a
+// confirmation depth otherwise
+package finality
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// Mode is how the committed block is resolved
+type Mode string
+
+const (
+	// ModeAuto uses the default of the chain
+	ModeAuto Mode = "auto"
+	// ModeLatest considers the latest block committed
+	ModeLatest Mode = "latest"
+	// ModeSafe uses the safe block tag, blocks unlikely to be reorged, such
+	// as justified blocks or L2 blocks derived from L1 data
+	ModeSafe Mode = "safe"
+	// ModeFinalized uses the finalized block tag, blocks that cannot be reorged
+	ModeFinalized Mode = "finalized"
+	// ModeDepth considers committed the blocks at least Depth blocks deep
+	ModeDepth Mode = "depth"
+)
+
+// Policy resolves the committed block of a chain
+type Policy struct {
+	Mode Mode `json:"mode"`
+	// Depth is the confirmation depth of ModeDepth, also used when the RPC
+	// does not support the block tag of ModeSafe or ModeFinalized
+	Depth uint64 `json:"depth"`
+}
+
+// chainPolicies are the defaults of the chains with finality gadgets, other
+// chains default to the latest block
+var chainPolicies = map[int64]Policy{
+	// Ethereum mainnet and testnets finalize after two epochs
+	1:        {Mode: ModeFinalized, Depth: 64},
+	11155111: {Mode: ModeFinalized, Depth: 64},
+	17000:    {Mode: ModeFinalized, Depth: 64},
+	560048:   {Mode: ModeFinalized, Depth: 64},
+	// Gnosis Chain finalizes with its beacon chain
+	100: {Mode: ModeFinalized, Depth: 20},
+	// Polygon PoS finalizes with milestones
+	137: {Mode: ModeFinalized, Depth: 128},
+	// OP Stack and Arbitrum blocks are safe once their data is posted to L1
+	10:       {Mode: ModeSafe, Depth: 10},
+	8453:     {Mode: ModeSafe, Depth: 10},
+	11155420: {Mode: ModeSafe, Depth: 10},
+	84532:    {Mode: ModeSafe, Depth: 10},
+	42161:    {Mode: ModeSafe, Depth: 10},
+	421614:   {Mode: ModeSafe, Depth: 10},
+}
+
+// ChainPolicies are the policies of chains overriding or extending the
+// built-in defaults, decoded from a comma separated list of
+// chain_id=mode[:depth] entries
+type ChainPolicies map[int64]Policy
+
+// Decode implements envconfig.Decoder
+func (c *ChainPolicies) Decode(value string) error {
+	policies := make(ChainPolicies)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		chain, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid chain finality %q, expected chain_id=mode[:depth]", entry)
+		}
+		chainID, err := strconv.ParseInt(strings.TrimSpace(chain), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid chain ID in chain finality %q: %w", entry, err)
+		}
+		mode, depthValue, _ := strings.Cut(spec, ":")
+		var depth uint64
+		if depthValue != "" {
+			depth, err = strconv.ParseUint(depthValue, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid depth in chain finality %q: %w", entry, err)
+			}
+		}
+		if Mode(strings.ToLower(mode)) == ModeAuto {
+			return fmt.Errorf("invalid chain finality %q, a chain cannot default to auto", entry)
+		}
+		policy, err := ParsePolicy(mode, depth, chainID, nil)
+		if err != nil {
+			return fmt.Errorf("invalid chain finality %q: %w", entry, err)
+		}
+		policies[chainID] = policy
+	}
+	*c = policies
+	return nil
+}
+
+// String formats the policies back to their configuration format
+func (c ChainPolicies) String() string {
+	chainIDs := make([]int64, 0, len(c))
+	for chainID := range c {
+		chainIDs = append(chainIDs, chainID)
+	}
+	slices.Sort(chainIDs)
+	entries := make([]string, len(chainIDs))
+	for i, chainID := range chainIDs {
+		entries[i] = fmt.Sprintf("%d=%s:%d", chainID, c[chainID].Mode, c[chainID].Depth)
+	}
+	return strings.Join(entries, ",")
+}
+
+// ParsePolicy parses a finality mode. ModeAuto and an empty mode use the
+// policy of the chain in chains, or its built-in default, and depth, unless 0,
+// overrides the default depth.
+func ParsePolicy(mode string, depth uint64, chainID int64, chains ChainPolicies) (Policy, error) {
+	policy := Policy{Mode: Mode(strings.ToLower(mode)), Depth: depth}
+	switch policy.Mode {
+	case "", ModeAuto:
+		var ok bool
+		if policy, ok = chains[chainID]; !ok {
+			policy = chainPolicies[chainID]
+		}
+		if policy.Mode == "" {
+			policy.Mode = ModeLatest
+		}
+		if depth != 0 {
+			policy.Depth = depth
+		}
+	case ModeLatest, ModeSafe, ModeFinalized:
+	case ModeDepth:
+		if depth == 0 {
+			return Policy{}, fmt.Errorf("finality mode %q requires a depth", mode)
+		}
+	default:
+		return Policy{}, fmt.Errorf("unknown finality mode %q, expected auto, latest, safe, finalized or depth", mode)
+	}
+	return policy, nil
+}
+
+// Tracker resolves the committed block of a chain under a policy
+type Tracker struct {
+	client *ethclient.Client
+	policy Policy
+
+	mu sync.Mutex
+	// fallback is set while the RPC fails to resolve the block tag
+	fallback bool
+}
+
+// NewTracker returns a tracker of the committed block through client
+func NewTracker(client *ethclient.Client, policy Policy) *Tracker {
+	return &Tracker{client: client, policy: policy}
+}
+
+// Policy returns the policy of the tracker
+func (t *Tracker) Policy() Policy {
+	return t.policy
+}
+
+// CommittedBlock returns the number of the latest committed block. When the
+// RPC does not resolve the safe or finalized tag, such as RPCs predating the
+// tags, it falls back to the confirmation depth.
+func (t *Tracker) CommittedBlock(ctx context.Context) (uint64, error) {
+	var tag rpc.BlockNumber
+	switch t.policy.Mode {
+	case ModeSafe:
+		tag = rpc.SafeBlockNumber
+	case ModeFinalized:
+		tag = rpc.FinalizedBlockNumber
+	case ModeDepth:
+		return t.byDepth(ctx, t.policy.Depth)
+	default:
+		return t.byDepth(ctx, 0)
+	}
+
+	header, err := t.client.HeaderByNumber(ctx, big.NewInt(tag.Int64()))
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		t.setFallback(true, err)
+		return t.byDepth(ctx, t.policy.Depth)
+	}
+	t.setFallback(false, nil)
+	return header.Number.Uint64(), nil
+}
+
+// byDepth returns the latest block number minus depth
+func (t *Tracker) byDepth(ctx context.Context, depth uint64) (uint64, error) {
+	latest, err := t.client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if latest < depth {
+		return 0, nil
+	}
+	return latest - depth, nil
+}
+
+// setFallback logs the transitions between the block tag and the fallback depth
+func (t *Tracker) setFallback(fallback bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if fallback == t.fallback {
+		return
+	}
+	t.fallback = fallback
+	if fallback {
+		log.Warn().Err(err).
+			Str("tag", string(t.policy.Mode)).
+			Uint64("depth", t.policy.Depth).
+			Msg("RPC does not resolve the block tag, falling back to the confirmation depth")
+	} else {
+		log.Info().Str("tag", string(t.policy.Mode)).Msg("RPC resolves the block tag again")
+	}
+}
+
+// Fallback reports whether the committed block is currently resolved by
+// depth because the RPC failed to resolve the block tag
+func (t *Tracker) Fallback() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.fallback
+}
@@ -0,0 +1,327 @@
+// Package fixtures records windows of live drand beacons and Drand Oracle
+// interactions as JSON, so that tests and replays run against realistic data.
+//
+// Fixtures only hold public drand and chain data. The RPC and relay URLs,
+// which often carry API keys, are never recorded.
+package fixtures
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/client"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Version is the format version of the recorded fixtures
+const Version = 1
+
+// maxFeeHistoryBlocks bounds the blocks of a single eth_feeHistory query
+const maxFeeHistoryBlocks = 1024
+
+// Fixture is a recorded window of drand beacons and oracle interactions
+type Fixture struct {
+	Version    int       `json:"version"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Drand      Drand     `json:"drand"`
+	Chain      Chain     `json:"chain"`
+}
+
+// Drand is the drand network info and a window of its beacons
+type Drand struct {
+	ChainHash   string   `json:"chain_hash"`
+	PublicKey   string   `json:"public_key"`
+	Period      uint64   `json:"period_seconds"`
+	GenesisTime int64    `json:"genesis_time"`
+	GenesisSeed string   `json:"genesis_seed"`
+	Scheme      string   `json:"scheme"`
+	Beacons     []Beacon `json:"beacons"`
+}
+
+// Beacon is a drand round
+type Beacon struct {
+	Round             uint64 `json:"round"`
+	Timestamp         int64  `json:"timestamp"`
+	Randomness        string `json:"randomness"`
+	Signature         string `json:"signature"`
+	PreviousSignature string `json:"previous_signature,omitempty"`
+}
+
+// Chain is the oracle state and a window of blocks of the destination chain
+type Chain struct {
+	ChainID       int64  `json:"chain_id"`
+	OracleAddress string `json:"oracle_address"`
+	ChainHash     string `json:"chain_hash"`
+	Signer        string `json:"signer"`
+	EarliestRound uint64 `json:"earliest_round"`
+	LatestRound   uint64 `json:"latest_round"`
+	FromBlock     uint64 `json:"from_block"`
+	ToBlock       uint64 `json:"to_block"`
+	// Rounds are the rounds of the drand window stored in the oracle
+	Rounds []OracleRound `json:"rounds"`
+	// Transactions are the transactions of the block window that set rounds
+	Transactions []Transaction `json:"transactions"`
+	// Blocks are the fees of every block of the window
+	Blocks []Block `json:"blocks"`
+}
+
+// OracleRound is a round as stored in the oracle
+type OracleRound struct {
+	Round      uint64 `json:"round"`
+	Timestamp  uint64 `json:"timestamp"`
+	Randomness string `json:"randomness"`
+	Signature  string `json:"signature"`
+}
+
+// Transaction is a mined setRandomness transaction with its receipt
+type Transaction struct {
+	Hash              string `json:"hash"`
+	Round             uint64 `json:"round"`
+	From              string `json:"from"`
+	Nonce             uint64 `json:"nonce"`
+	Type              uint8  `json:"type"`
+	Gas               uint64 `json:"gas"`
+	GasPrice          string `json:"gas_price"`
+	GasFeeCap         string `json:"gas_fee_cap"`
+	GasTipCap         string `json:"gas_tip_cap"`
+	Value             string `json:"value"`
+	Input             string `json:"input"`
+	BlockNumber       uint64 `json:"block_number"`
+	LogIndex          uint   `json:"log_index"`
+	Status            uint64 `json:"status"`
+	GasUsed           uint64 `json:"gas_used"`
+	EffectiveGasPrice string `json:"effective_gas_price"`
+}
+
+// Block is the fee market state of a block
+type Block struct {
+	Number       uint64   `json:"number"`
+	BaseFee      string   `json:"base_fee"`
+	GasUsedRatio float64  `json:"gas_used_ratio"`
+	Rewards      []string `json:"rewards"`
+}
+
+// RewardPercentiles are the priority fee percentiles recorded for every block
+var RewardPercentiles = []float64{25, 50, 75}
+
+// Options selects the recorded window
+type Options struct {
+	// Rounds is the number of latest drand rounds to record
+	Rounds uint64
+	// Blocks is the number of latest blocks to record
+	Blocks uint64
+}
+
+// Recorder records fixtures from live networks
+type Recorder struct {
+	drandClient   client.Client
+	rpcClient     *ethclient.Client
+	oracle        *binding.Binding
+	oracleAddress common.Address
+}
+
+func NewRecorder(drandClient client.Client, rpcClient *ethclient.Client, oracle *binding.Binding, oracleAddress common.Address) *Recorder {
+	return &Recorder{
+		drandClient:   drandClient,
+		rpcClient:     rpcClient,
+		oracle:        oracle,
+		oracleAddress: oracleAddress,
+	}
+}
+
+// Record captures the latest drand rounds and blocks of the options
+func (r *Recorder) Record(ctx context.Context, options Options) (*Fixture, error) {
+	if options.Rounds == 0 || options.Blocks == 0 {
+		return nil, errors.New("at least one round and one block must be recorded")
+	}
+	fixture := &Fixture{
+		Version:    Version,
+		RecordedAt: time.Now().UTC(),
+	}
+	if err := r.recordDrand(ctx, &fixture.Drand, options.Rounds); err != nil {
+		return nil, err
+	}
+	if err := r.recordChain(ctx, &fixture.Chain, fixture.Drand.Beacons, options.Blocks); err != nil {
+		return nil, err
+	}
+	return fixture, nil
+}
+
+func (r *Recorder) recordDrand(ctx context.Context, fixture *Drand, rounds uint64) error {
+	info, err := r.drandClient.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting drand info: %w", err)
+	}
+	fixture.ChainHash = info.HashString()
+	fixture.PublicKey = info.PublicKey.String()
+	fixture.Period = uint64(info.Period.Seconds())
+	fixture.GenesisTime = info.GenesisTime
+	fixture.GenesisSeed = hex.EncodeToString(info.GenesisSeed)
+	fixture.Scheme = info.Scheme
+
+	latest, err := r.drandClient.Get(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("error getting latest drand round: %w", err)
+	}
+	from := latest.Round() - min(latest.Round()-1, rounds-1)
+	for round := from; round <= latest.Round(); round++ {
+		result, err := r.drandClient.Get(ctx, round)
+		if err != nil {
+			return fmt.Errorf("error getting drand round %d: %w", round, err)
+		}
+		beacon := Beacon{
+			Round:      result.Round(),
+			Timestamp:  chain.TimeOfRound(info.Period, info.GenesisTime, result.Round()),
+			Randomness: hex.EncodeToString(result.Randomness()),
+			Signature:  hex.EncodeToString(result.Signature()),
+		}
+		if chained, ok := result.(interface{ PreviousSignature() []byte }); ok {
+			beacon.PreviousSignature = hex.EncodeToString(chained.PreviousSignature())
+		}
+		fixture.Beacons = append(fixture.Beacons, beacon)
+	}
+	return nil
+}
+
+func (r *Recorder) recordChain(ctx context.Context, fixture *Chain, beacons []Beacon, blocks uint64) error {
+	opts := &bind.CallOpts{Context: ctx}
+	chainID, err := r.rpcClient.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting chain id: %w", err)
+	}
+	chainHash, err := r.oracle.CHAINHASH(opts)
+	if err != nil {
+		return fmt.Errorf("error getting oracle chain hash: %w", err)
+	}
+	signer, err := r.oracle.Signer(opts)
+	if err != nil {
+		return fmt.Errorf("error getting oracle signer: %w", err)
+	}
+	fixture.ChainID = chainID.Int64()
+	fixture.OracleAddress = r.oracleAddress.Hex()
+	fixture.ChainHash = hex.EncodeToString(chainHash[:])
+	fixture.Signer = signer.Hex()
+	if fixture.EarliestRound, err = r.oracle.EarliestRound(opts); err != nil {
+		return fmt.Errorf("error getting oracle earliest round: %w", err)
+	}
+	if fixture.LatestRound, err = r.oracle.LatestRound(opts); err != nil {
+		return fmt.Errorf("error getting oracle latest round: %w", err)
+	}
+
+	for _, beacon := range beacons {
+		if fixture.LatestRound == 0 || beacon.Round < fixture.EarliestRound || beacon.Round > fixture.LatestRound {
+			continue
+		}
+		random, err := r.oracle.GetRandomnessFromRound(opts, beacon.Round)
+		if err != nil {
+			return fmt.Errorf("error getting oracle round %d: %w", beacon.Round, err)
+		}
+		fixture.Rounds = append(fixture.Rounds, OracleRound{
+			Round:      random.Round,
+			Timestamp:  random.Timestamp,
+			Randomness: hex.EncodeToString(random.Randomness[:]),
+			Signature:  hex.EncodeToString(random.Signature),
+		})
+	}
+
+	latestBlock, err := r.rpcClient.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting block number: %w", err)
+	}
+	fixture.ToBlock = latestBlock
+	fixture.FromBlock = latestBlock - min(latestBlock, blocks-1)
+	if err := r.recordTransactions(ctx, fixture, chainID); err != nil {
+		return err
+	}
+	return r.recordBlocks(ctx, fixture)
+}
+
+// recordTransactions records the transactions of the RandomnessUpdated events of the block window
+func (r *Recorder) recordTransactions(ctx context.Context, fixture *Chain, chainID *big.Int) error {
+	it, err := r.oracle.FilterRandomnessUpdated(&bind.FilterOpts{Start: fixture.FromBlock, End: &fixture.ToBlock, Context: ctx})
+	if err != nil {
+		return fmt.Errorf("error filtering RandomnessUpdated events: %w", err)
+	}
+	defer it.Close()
+
+	txSigner := types.LatestSignerForChainID(chainID)
+	for it.Next() {
+		event := it.Event
+		tx, _, err := r.rpcClient.TransactionByHash(ctx, event.Raw.TxHash)
+		if err != nil {
+			return fmt.Errorf("error getting transaction %s: %w", event.Raw.TxHash.Hex(), err)
+		}
+		receipt, err := r.rpcClient.TransactionReceipt(ctx, event.Raw.TxHash)
+		if err != nil {
+			return fmt.Errorf("error getting receipt %s: %w", event.Raw.TxHash.Hex(), err)
+		}
+		from, err := types.Sender(txSigner, tx)
+		if err != nil {
+			return fmt.Errorf("error recovering sender of %s: %w", event.Raw.TxHash.Hex(), err)
+		}
+		// Pre-London receipts of some nodes omit the effective gas price
+		effectiveGasPrice := receipt.EffectiveGasPrice
+		if effectiveGasPrice == nil {
+			effectiveGasPrice = tx.GasPrice()
+		}
+		fixture.Transactions = append(fixture.Transactions, Transaction{
+			Hash:              tx.Hash().Hex(),
+			Round:             event.Round,
+			From:              from.Hex(),
+			Nonce:             tx.Nonce(),
+			Type:              tx.Type(),
+			Gas:               tx.Gas(),
+			GasPrice:          tx.GasPrice().String(),
+			GasFeeCap:         tx.GasFeeCap().String(),
+			GasTipCap:         tx.GasTipCap().String(),
+			Value:             tx.Value().String(),
+			Input:             hex.EncodeToString(tx.Data()),
+			BlockNumber:       event.Raw.BlockNumber,
+			LogIndex:          event.Raw.Index,
+			Status:            receipt.Status,
+			GasUsed:           receipt.GasUsed,
+			EffectiveGasPrice: effectiveGasPrice.String(),
+		})
+	}
+	return it.Error()
+}
+
+// recordBlocks records the fee history of the block window
+func (r *Recorder) recordBlocks(ctx context.Context, fixture *Chain) error {
+	for first := fixture.FromBlock; first <= fixture.ToBlock; {
+		count := min(fixture.ToBlock-first+1, maxFeeHistoryBlocks)
+		last := first + count - 1
+		history, err := r.rpcClient.FeeHistory(ctx, count, new(big.Int).SetUint64(last), RewardPercentiles)
+		if err != nil {
+			return fmt.Errorf("error getting fee history up to block %d: %w", last, err)
+		}
+		if len(history.GasUsedRatio) == 0 {
+			return fmt.Errorf("empty fee history up to block %d", last)
+		}
+		for i := range history.GasUsedRatio {
+			block := Block{
+				Number:       history.OldestBlock.Uint64() + uint64(i),
+				BaseFee:      history.BaseFee[i].String(),
+				GasUsedRatio: history.GasUsedRatio[i],
+			}
+			if i < len(history.Reward) {
+				for _, reward := range history.Reward[i] {
+					block.Rewards = append(block.Rewards, reward.String())
+				}
+			}
+			fixture.Blocks = append(fixture.Blocks, block)
+		}
+		// Nodes may return fewer blocks than requested
+		first = history.OldestBlock.Uint64() + uint64(len(history.GasUsedRatio))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package gassim
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// blockFees are the fees of a block
+type blockFees struct {
+	baseFee *big.Int
+	// rewards are the priority fees at feeCache.percentiles
+	rewards []*big.Int
+}
+
+// feeCache holds the fee history of the blocks around the replayed transactions,
+// fetched once for every percentile used by the scenarios
+type feeCache struct {
+	client      Client
+	percentiles []float64
+	// inclusionIndex is the index of the inclusion percentile in percentiles
+	inclusionIndex int
+	blocks         map[uint64]blockFees
+
+	limiter *time.Ticker
+}
+
+func newFeeCache(client Client, scenarios []Scenario, opts Options) *feeCache {
+	seen := map[float64]bool{opts.InclusionPercentile: true}
+	percentiles := []float64{opts.InclusionPercentile}
+	for _, s := range scenarios {
+		if !seen[s.Percentile] {
+			seen[s.Percentile] = true
+			percentiles = append(percentiles, s.Percentile)
+		}
+	}
+	// eth_feeHistory requires increasing percentiles
+	sort.Float64s(percentiles)
+
+	c := &feeCache{
+		client:      client,
+		percentiles: percentiles,
+		blocks:      make(map[uint64]blockFees),
+	}
+	c.inclusionIndex = c.index(opts.InclusionPercentile)
+	if opts.RequestsPerSecond > 0 {
+		c.limiter = time.NewTicker(time.Duration(float64(time.Second) / opts.RequestsPerSecond))
+	}
+	return c
+}
+
+func (c *feeCache) close() {
+	if c.limiter != nil {
+		c.limiter.Stop()
+	}
+}
+
+// index returns the index of a percentile in the fetched percentiles
+func (c *feeCache) index(percentile float64) int {
+	return sort.SearchFloat64s(c.percentiles, percentile)
+}
+
+// load fetches the fees of the blocks in [from, to] that are not cached yet
+func (c *feeCache) load(ctx context.Context, from, to uint64) error {
+	for from <= to {
+		if _, ok := c.blocks[from]; ok {
+			from++
+			continue
+		}
+		last := min(from+maxFeeHistoryBlocks-1, to)
+		c.wait()
+		history, err := c.client.FeeHistory(ctx, last-from+1, new(big.Int).SetUint64(last), c.percentiles)
+		if err != nil {
+			return fmt.Errorf("fee history of blocks %d-%d: %w", from, last, err)
+		}
+		if len(history.BaseFee) == 0 {
+			return fmt.Errorf("fee history of blocks %d-%d has no base fee, the chain may not support EIP-1559", from, last)
+		}
+		// The last base fee is the prediction for the block after the range and is skipped
+		oldest := history.OldestBlock.Uint64()
+		for i, baseFee := range history.BaseFee[:len(history.BaseFee)-1] {
+			fees := blockFees{baseFee: baseFee, rewards: make([]*big.Int, len(c.percentiles))}
+			if i < len(history.Reward) {
+				copy(fees.rewards, history.Reward[i])
+			}
+			c.blocks[oldest+uint64(i)] = fees
+		}
+		from = last + 1
+	}
+	return nil
+}
+
+func (c *feeCache) blockNumber(ctx context.Context) (uint64, error) {
+	c.wait()
+	return c.client.BlockNumber(ctx)
+}
+
+// blockTime returns the average block time between two blocks, 0 if they are the same
+func (c *feeCache) blockTime(ctx context.Context, from, to uint64) (time.Duration, error) {
+	if from == to {
+		return 0, nil
+	}
+	c.wait()
+	first, err := c.client.HeaderByNumber(ctx, new(big.Int).SetUint64(from))
+	if err != nil {
+		return 0, err
+	}
+	c.wait()
+	last, err := c.client.HeaderByNumber(ctx, new(big.Int).SetUint64(to))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(last.Time-first.Time) * time.Second / time.Duration(to-from), nil
+}
+
+// wait blocks until the rate limiter allows the next RPC request
+func (c *feeCache) wait() {
+	if c.limiter != nil {
+		<-c.limiter.C
+	}
+}
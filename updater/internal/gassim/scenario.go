@@ -0,0 +1,134 @@
+package gassim
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scenario is an alternative gas strategy replayed against the transaction
+// history. Prices are computed as by the percentile strategy, scaled by the
+// multiplier and capped at the maximum, then bumped while not included.
+type Scenario struct {
+	Name string `json:"name"`
+	// Percentile is the priority fee percentile of recent blocks
+	Percentile float64 `json:"percentile"`
+	// Blocks is the number of recent blocks the priority fee is taken from
+	Blocks uint64 `json:"blocks"`
+	// Multiplier scales the computed gas price
+	Multiplier float64 `json:"multiplier"`
+	// Max caps the gas price, nil or 0 disables the cap
+	Max *big.Int `json:"max_wei,omitempty"`
+	// BumpAfter is the number of blocks without inclusion after which the gas
+	// price is bumped, 0 never bumps
+	BumpAfter uint64 `json:"bump_after_blocks"`
+	// BumpPercent is the gas price increase of every bump
+	BumpPercent float64 `json:"bump_percent"`
+	// MaxBumps is the maximum number of bumps per transaction
+	MaxBumps int `json:"max_bumps"`
+}
+
+// DefaultScenarios are replayed when no scenario is given
+var DefaultScenarios = []Scenario{
+	{Name: "p25", Percentile: 25, Blocks: 20, Multiplier: 1, BumpPercent: 10, MaxBumps: 3},
+	{Name: "p50", Percentile: 50, Blocks: 20, Multiplier: 1, BumpPercent: 10, MaxBumps: 3},
+	{Name: "p75", Percentile: 75, Blocks: 20, Multiplier: 1, BumpPercent: 10, MaxBumps: 3},
+}
+
+// ParseScenario parses a scenario of the form
+// name:percentile=25,blocks=20,multiplier=1.1,max=50000000000,bump-after=3,bump=12.5,max-bumps=3.
+// Omitted settings default to the 50th percentile of 20 blocks, no multiplier,
+// no cap and no bumps.
+func ParseScenario(spec string) (Scenario, error) {
+	s := Scenario{Percentile: 50, Blocks: 20, Multiplier: 1, BumpPercent: 10, MaxBumps: 3}
+	name, settings, _ := strings.Cut(spec, ":")
+	s.Name = strings.TrimSpace(name)
+	if s.Name == "" {
+		return s, fmt.Errorf("scenario %q has no name", spec)
+	}
+
+	for _, setting := range strings.Split(settings, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return s, fmt.Errorf("scenario %s: expected key=value, got %q", s.Name, setting)
+		}
+		var err error
+		switch key {
+		case "percentile":
+			s.Percentile, err = strconv.ParseFloat(value, 64)
+			if err == nil && (s.Percentile < 0 || s.Percentile > 100) {
+				err = fmt.Errorf("must be within [0, 100]")
+			}
+		case "blocks":
+			s.Blocks, err = strconv.ParseUint(value, 10, 64)
+			if err == nil && s.Blocks == 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "multiplier":
+			s.Multiplier, err = strconv.ParseFloat(value, 64)
+			if err == nil && s.Multiplier <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "max":
+			max, ok := new(big.Int).SetString(value, 10)
+			if !ok {
+				err = fmt.Errorf("invalid amount")
+			}
+			s.Max = max
+		case "bump-after":
+			s.BumpAfter, err = strconv.ParseUint(value, 10, 64)
+		case "bump":
+			s.BumpPercent, err = strconv.ParseFloat(value, 64)
+			if err == nil && s.BumpPercent <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "max-bumps":
+			s.MaxBumps, err = strconv.Atoi(value)
+		default:
+			return s, fmt.Errorf("scenario %s: unknown setting %q", s.Name, key)
+		}
+		if err != nil {
+			return s, fmt.Errorf("scenario %s: invalid %s %q: %w", s.Name, key, value, err)
+		}
+	}
+	return s, nil
+}
+
+// price returns the scenario's initial gas price for a transaction submitted
+// after block, from the fees of the preceding blocks
+func (s Scenario) price(fees *feeCache, block uint64, percentileIndex int) *big.Int {
+	var tips []*big.Int
+	for b := block - min(s.Blocks, block) + 1; b <= block; b++ {
+		if fee, ok := fees.blocks[b]; ok && fee.rewards[percentileIndex] != nil {
+			tips = append(tips, fee.rewards[percentileIndex])
+		}
+	}
+	price := new(big.Int).Set(fees.blocks[block+1].baseFee)
+	if len(tips) > 0 {
+		price.Add(price, median(tips))
+	}
+	return s.bound(scale(price, s.Multiplier))
+}
+
+// bump raises a gas price by the scenario's bump percentage
+func (s Scenario) bump(price *big.Int) *big.Int {
+	return s.bound(scale(price, 1+s.BumpPercent/100))
+}
+
+// bound caps a gas price at the scenario's maximum
+func (s Scenario) bound(price *big.Int) *big.Int {
+	if s.Max != nil && s.Max.Sign() > 0 && price.Cmp(s.Max) > 0 {
+		return new(big.Int).Set(s.Max)
+	}
+	return price
+}
+
+func scale(v *big.Int, factor float64) *big.Int {
+	scaled, _ := new(big.Float).Mul(new(big.Float).SetInt(v), big.NewFloat(factor)).Int(nil)
+	return scaled
+}
@@ -0,0 +1,250 @@
+// Package gassim replays the recorded transaction history under alternative gas
+// strategies, reporting the hypothetical cost and inclusion latency of each.
+//
+// The simulation is an approximation: every transaction is assumed to have been
+// submitted right after the block preceding its inclusion block, and a
+// transaction is assumed to be included in the first block whose base fee it
+// covers while paying at least the inclusion percentile of that block's
+// priority fees. Fees are charged as legacy transactions, at the full gas price.
+package gassim
+
+import (
+	"context"
+	"drand-oracle-updater/internal/store"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// maxFeeHistoryBlocks is the largest block range requested per eth_feeHistory call
+const maxFeeHistoryBlocks = 1024
+
+// Client is the subset of the RPC client used by the simulation
+type Client interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// Options configures a simulation
+type Options struct {
+	// Horizon is the number of blocks a transaction is followed for before it
+	// is counted as stuck
+	Horizon uint64
+	// InclusionPercentile is the priority fee percentile of a block a
+	// transaction must pay to be included in it
+	InclusionPercentile float64
+	// RequestsPerSecond limits the RPC request rate, 0 disables the limit
+	RequestsPerSecond float64
+}
+
+// Report is the outcome of a simulation
+type Report struct {
+	FromBlock    uint64        `json:"from_block"`
+	ToBlock      uint64        `json:"to_block"`
+	BlockTime    time.Duration `json:"block_time"`
+	Transactions int           `json:"transactions"`
+	// ActualCost is the gas fee actually paid for the simulated transactions
+	ActualCost string   `json:"actual_cost_wei"`
+	Results    []Result `json:"results"`
+}
+
+// Result is the outcome of a scenario
+type Result struct {
+	Scenario Scenario `json:"scenario"`
+	Included int      `json:"included"`
+	// Stuck is the number of transactions not included within the horizon
+	Stuck int `json:"stuck"`
+	// Cost is the hypothetical gas fee of the included transactions, and
+	// ActualCost the fee actually paid for the same transactions
+	Cost       string `json:"cost_wei"`
+	ActualCost string `json:"actual_cost_wei"`
+	// CostChange is the relative cost change against the actual cost, in percent
+	CostChange float64 `json:"cost_change_percent"`
+	// Latencies are in blocks from submission to inclusion, the actual latency being 1
+	MeanLatency float64 `json:"mean_latency_blocks"`
+	P50Latency  uint64  `json:"p50_latency_blocks"`
+	P95Latency  uint64  `json:"p95_latency_blocks"`
+	MaxLatency  uint64  `json:"max_latency_blocks"`
+	Bumps       int     `json:"bumps"`
+}
+
+// Simulate replays transactions under every scenario
+func Simulate(ctx context.Context, client Client, txs []store.Transaction, scenarios []Scenario, opts Options) (*Report, error) {
+	if len(scenarios) == 0 {
+		return nil, errors.New("no scenario to simulate")
+	}
+	if opts.Horizon == 0 {
+		return nil, errors.New("horizon must be positive")
+	}
+	var mined []store.Transaction
+	for _, tx := range txs {
+		if tx.BlockNumber > 1 {
+			mined = append(mined, tx)
+		}
+	}
+	if len(mined) == 0 {
+		return nil, errors.New("no mined transaction to replay")
+	}
+	sort.Slice(mined, func(i, j int) bool { return mined[i].BlockNumber < mined[j].BlockNumber })
+
+	fees := newFeeCache(client, scenarios, opts)
+	defer fees.close()
+
+	latest, err := fees.blockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var lookback uint64
+	for _, s := range scenarios {
+		lookback = max(lookback, s.Blocks)
+	}
+
+	report := &Report{
+		FromBlock:    mined[0].BlockNumber,
+		ToBlock:      mined[len(mined)-1].BlockNumber,
+		Transactions: len(mined),
+	}
+	report.BlockTime, err = fees.blockTime(ctx, report.FromBlock, report.ToBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*scenarioResult, len(scenarios))
+	for i := range results {
+		results[i] = newScenarioResult()
+	}
+	actualCost := new(big.Int)
+	for n, tx := range mined {
+		submitted := tx.BlockNumber - 1
+		from := submitted - min(lookback, submitted) + 1
+		to := min(submitted+opts.Horizon, latest)
+		if err := fees.load(ctx, from, to); err != nil {
+			return nil, err
+		}
+		if _, ok := fees.blocks[tx.BlockNumber]; !ok {
+			return nil, fmt.Errorf("no fee history for block %d, the RPC may have pruned it", tx.BlockNumber)
+		}
+
+		actual := parseWei(tx.Fee)
+		actualCost.Add(actualCost, actual)
+		for i, s := range scenarios {
+			results[i].add(replay(fees, s, fees.index(s.Percentile), submitted, to), tx.GasUsed, actual)
+		}
+		if (n+1)%100 == 0 {
+			log.Info().Int("transactions", n+1).Int("total", len(mined)).Msg("Simulation progress")
+		}
+	}
+
+	report.ActualCost = actualCost.String()
+	for i, s := range scenarios {
+		report.Results = append(report.Results, results[i].result(s))
+	}
+	return report, nil
+}
+
+// outcome is the replay of a transaction under a scenario
+type outcome struct {
+	included bool
+	latency  uint64
+	price    *big.Int
+	bumps    int
+}
+
+// replay follows a transaction submitted after block until it is included or the last block
+func replay(fees *feeCache, s Scenario, percentileIndex int, submitted, last uint64) outcome {
+	price := s.price(fees, submitted, percentileIndex)
+	o := outcome{price: price}
+	for b := submitted + 1; b <= last; b++ {
+		fee, ok := fees.blocks[b]
+		if !ok {
+			break
+		}
+		tip := new(big.Int).Sub(price, fee.baseFee)
+		minTip := fee.rewards[fees.inclusionIndex]
+		if tip.Sign() >= 0 && (minTip == nil || tip.Cmp(minTip) >= 0) {
+			o.included = true
+			o.latency = b - submitted
+			o.price = price
+			return o
+		}
+		waited := b - submitted
+		if s.BumpAfter > 0 && o.bumps < s.MaxBumps && waited%s.BumpAfter == 0 {
+			price = s.bump(price)
+			o.bumps++
+		}
+	}
+	o.price = price
+	return o
+}
+
+type scenarioResult struct {
+	cost       *big.Int
+	actualCost *big.Int
+	latencies  []uint64
+	stuck      int
+	bumps      int
+}
+
+func newScenarioResult() *scenarioResult {
+	return &scenarioResult{cost: new(big.Int), actualCost: new(big.Int)}
+}
+
+func (r *scenarioResult) add(o outcome, gasUsed uint64, actual *big.Int) {
+	r.bumps += o.bumps
+	if !o.included {
+		r.stuck++
+		return
+	}
+	r.cost.Add(r.cost, new(big.Int).Mul(o.price, new(big.Int).SetUint64(gasUsed)))
+	r.actualCost.Add(r.actualCost, actual)
+	r.latencies = append(r.latencies, o.latency)
+}
+
+func (r *scenarioResult) result(s Scenario) Result {
+	result := Result{
+		Scenario:   s,
+		Included:   len(r.latencies),
+		Stuck:      r.stuck,
+		Cost:       r.cost.String(),
+		ActualCost: r.actualCost.String(),
+		Bumps:      r.bumps,
+	}
+	if r.actualCost.Sign() > 0 {
+		diff := new(big.Float).SetInt(new(big.Int).Sub(r.cost, r.actualCost))
+		change, _ := diff.Quo(diff, new(big.Float).SetInt(r.actualCost)).Float64()
+		result.CostChange = change * 100
+	}
+	if len(r.latencies) > 0 {
+		sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+		var total uint64
+		for _, l := range r.latencies {
+			total += l
+		}
+		result.MeanLatency = float64(total) / float64(len(r.latencies))
+		result.P50Latency = r.latencies[len(r.latencies)/2]
+		result.P95Latency = r.latencies[len(r.latencies)*95/100]
+		result.MaxLatency = r.latencies[len(r.latencies)-1]
+	}
+	return result
+}
+
+func parseWei(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return v
+}
+
+func median(values []*big.Int) *big.Int {
+	sorted := append([]*big.Int(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	return sorted[len(sorted)/2]
+}
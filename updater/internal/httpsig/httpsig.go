@@ -0,0 +1,256 @@
+// Package httpsig signs and verifies HTTP request bodies, so that receivers of
+// the updater's webhooks can authenticate them and the updater can
+// authenticate pushed beacons.
+//
+// A signed request carries the unix time it was signed at and one signature
+// per signing key:
+//
+//	X-Signature-Timestamp: 1700000000
+//	X-Signature: key1=<hex>, key2=<hex>
+//
+// Signatures cover "<timestamp>.<body>". HMAC keys sign with HMAC-SHA256,
+// ECDSA keys sign the keccak256 hash of the message with a secp256k1 key, as a
+// 65 byte signature verified by recovering the signer address. Signing with
+// several keys lets receivers rotate keys: the new key is added to the signer,
+// then to the receivers, and the old one is removed from the signer last.
+package httpsig
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Headers of a signed request
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Signature-Timestamp"
+)
+
+// Algorithms
+const (
+	AlgorithmHMAC  = "hmac"
+	AlgorithmECDSA = "ecdsa"
+)
+
+// DefaultTolerance is the default maximum age of a verified signature
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// ErrUnsigned is returned when a request carries no signature
+	ErrUnsigned = errors.New("request is not signed")
+	// ErrExpired is returned when a signature is older than the tolerance, or in the future
+	ErrExpired = errors.New("signature timestamp is outside the tolerance")
+	// ErrInvalidSignature is returned when no signature matches a known key
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Key is a signing or verifying key
+type Key struct {
+	ID        string
+	Algorithm string
+
+	secret     []byte
+	privateKey *ecdsa.PrivateKey
+	address    common.Address
+}
+
+// ParseSigningKeys parses id:hmac:<secret> and id:ecdsa:<private key hex> entries
+func ParseSigningKeys(entries []string) ([]Key, error) {
+	return parseKeys(entries, true)
+}
+
+// ParseVerifyingKeys parses id:hmac:<secret> and id:ecdsa:<address> entries
+func ParseVerifyingKeys(entries []string) ([]Key, error) {
+	return parseKeys(entries, false)
+}
+
+func parseKeys(entries []string, signing bool) ([]Key, error) {
+	var keys []Key
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid key %q, expected id:algorithm:key", redact(entry))
+		}
+		key := Key{ID: parts[0], Algorithm: parts[1]}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		seen[key.ID] = true
+
+		switch key.Algorithm {
+		case AlgorithmHMAC:
+			key.secret = []byte(parts[2])
+		case AlgorithmECDSA:
+			if signing {
+				privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(parts[2], "0x"))
+				if err != nil {
+					return nil, fmt.Errorf("invalid private key of key %q: %w", key.ID, err)
+				}
+				key.privateKey = privateKey
+				key.address = crypto.PubkeyToAddress(privateKey.PublicKey)
+			} else {
+				if !common.IsHexAddress(parts[2]) {
+					return nil, fmt.Errorf("invalid address of key %q", key.ID)
+				}
+				key.address = common.HexToAddress(parts[2])
+			}
+		default:
+			return nil, fmt.Errorf("unknown algorithm %q of key %q, expected %s or %s", key.Algorithm, key.ID, AlgorithmHMAC, AlgorithmECDSA)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// redact hides the key material of an invalid entry in errors
+func redact(entry string) string {
+	parts := strings.SplitN(entry, ":", 3)
+	if len(parts) == 3 {
+		parts[2] = "***"
+	}
+	return strings.Join(parts, ":")
+}
+
+// Address returns the address of an ECDSA key
+func (k Key) Address() common.Address {
+	return k.address
+}
+
+// Signer signs request bodies with every configured key
+type Signer struct {
+	keys []Key
+}
+
+// NewSigner returns a signer of the keys, nil without keys
+func NewSigner(keys []Key) *Signer {
+	if len(keys) == 0 {
+		return nil
+	}
+	return &Signer{keys: keys}
+}
+
+// Sign sets the signature headers of a request with the given body. A nil
+// signer leaves the request unsigned.
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	if s == nil {
+		return nil
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	message := signedMessage(timestamp, body)
+	signatures := make([]string, 0, len(s.keys))
+	for _, key := range s.keys {
+		var signature []byte
+		switch key.Algorithm {
+		case AlgorithmHMAC:
+			signature = hmacSignature(key.secret, message)
+		case AlgorithmECDSA:
+			var err error
+			signature, err = crypto.Sign(crypto.Keccak256(message), key.privateKey)
+			if err != nil {
+				return fmt.Errorf("error signing with key %q: %w", key.ID, err)
+			}
+		}
+		signatures = append(signatures, key.ID+"="+hex.EncodeToString(signature))
+	}
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, strings.Join(signatures, ", "))
+	return nil
+}
+
+// Verifier verifies that a request body was signed by one of the configured
+// keys, recently enough
+type Verifier struct {
+	keys      map[string]Key
+	tolerance time.Duration
+}
+
+// NewVerifier returns a verifier of the keys, nil without keys. tolerance is
+// the maximum age of a signature, DefaultTolerance when 0.
+func NewVerifier(keys []Key, tolerance time.Duration) *Verifier {
+	if len(keys) == 0 {
+		return nil
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	byID := make(map[string]Key, len(keys))
+	for _, key := range keys {
+		byID[key.ID] = key
+	}
+	return &Verifier{keys: byID, tolerance: tolerance}
+}
+
+// Verify checks the signature headers against the body. Signatures of unknown
+// keys are ignored, so that senders can sign with keys being rotated in.
+func (v *Verifier) Verify(header http.Header, body []byte) (keyID string, err error) {
+	timestamp, signatures := header.Get(HeaderTimestamp), header.Get(HeaderSignature)
+	if timestamp == "" || signatures == "" {
+		return "", ErrUnsigned
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature timestamp %q", timestamp)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > v.tolerance || age < -v.tolerance {
+		return "", ErrExpired
+	}
+
+	message := signedMessage(timestamp, body)
+	for _, entry := range strings.Split(signatures, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		key, known := v.keys[id]
+		if !ok || !known {
+			continue
+		}
+		signature, err := hex.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		if key.verify(message, signature) {
+			return id, nil
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+func (k Key) verify(message []byte, signature []byte) bool {
+	switch k.Algorithm {
+	case AlgorithmHMAC:
+		return hmac.Equal(signature, hmacSignature(k.secret, message))
+	case AlgorithmECDSA:
+		if len(signature) != crypto.SignatureLength {
+			return false
+		}
+		sig := make([]byte, len(signature))
+		copy(sig, signature)
+		// Accept V as 0/1 or 27/28
+		if sig[crypto.RecoveryIDOffset] >= 27 {
+			sig[crypto.RecoveryIDOffset] -= 27
+		}
+		pubKey, err := crypto.SigToPub(crypto.Keccak256(message), sig)
+		return err == nil && crypto.PubkeyToAddress(*pubKey) == k.address
+	}
+	return false
+}
+
+func signedMessage(timestamp string, body []byte) []byte {
+	return append([]byte(timestamp+"."), body...)
+}
+
+func hmacSignature(secret []byte, message []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
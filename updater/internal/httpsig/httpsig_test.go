@@ -0,0 +1,196 @@
+package httpsig
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testECDSAKey is the private key of the ECDSA test key
+const testECDSAKey = "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+
+var testBody = []byte(`{"round":1}`)
+
+// parseTestKeys returns the signing and verifying keys of entries, as
+// id:algorithm:secret or id:ecdsa for the ECDSA test key
+func parseTestKeys(t *testing.T, entries ...string) ([]Key, []Key) {
+	t.Helper()
+	privateKey, err := crypto.HexToECDSA(testECDSAKey)
+	if err != nil {
+		t.Fatalf("test key: %v", err)
+	}
+	var signing, verifying []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry, ":"+AlgorithmECDSA); ok {
+			signing = append(signing, id+":ecdsa:"+testECDSAKey)
+			verifying = append(verifying, id+":ecdsa:"+crypto.PubkeyToAddress(privateKey.PublicKey).Hex())
+			continue
+		}
+		signing = append(signing, entry)
+		verifying = append(verifying, entry)
+	}
+	signingKeys, err := ParseSigningKeys(signing)
+	if err != nil {
+		t.Fatalf("signing keys: %v", err)
+	}
+	verifyingKeys, err := ParseVerifyingKeys(verifying)
+	if err != nil {
+		t.Fatalf("verifying keys: %v", err)
+	}
+	return signingKeys, verifyingKeys
+}
+
+// signedHeader signs the test body with keys and returns the request headers
+func signedHeader(t *testing.T, keys []Key) http.Header {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "http://localhost", nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if err := NewSigner(keys).Sign(req, testBody); err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	return req.Header
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		signing []string
+		// verifying are the keys of the verifier, the signing keys when nil
+		verifying []string
+		wantKey   string
+	}{
+		{"hmac", []string{"k1:hmac:secret"}, nil, "k1"},
+		{"ecdsa", []string{"k1:ecdsa"}, nil, "k1"},
+		{"rotated in", []string{"old:hmac:old-secret", "new:hmac:new-secret"}, []string{"new:hmac:new-secret"}, "new"},
+		{"rotated out", []string{"new:hmac:new-secret"}, []string{"old:hmac:old-secret", "new:hmac:new-secret"}, "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signing, verifying := parseTestKeys(t, tt.signing...)
+			if tt.verifying != nil {
+				_, verifying = parseTestKeys(t, tt.verifying...)
+			}
+			keyID, err := NewVerifier(verifying, 0).Verify(signedHeader(t, signing), testBody)
+			if err != nil || keyID != tt.wantKey {
+				t.Errorf("Verify = %q, %v, want key %q", keyID, err, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// tamper changes the headers and body of a request signed with the
+		// HMAC and ECDSA keys
+		tamper  func(header http.Header) []byte
+		wantErr error
+	}{
+		{
+			name: "unsigned",
+			tamper: func(header http.Header) []byte {
+				header.Del(HeaderSignature)
+				return testBody
+			},
+			wantErr: ErrUnsigned,
+		},
+		{
+			name: "modified body",
+			tamper: func(header http.Header) []byte {
+				return []byte(`{"round":2}`)
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "forged signatures",
+			tamper: func(header http.Header) []byte {
+				header.Set(HeaderSignature, "k1="+strings.Repeat("00", 32)+", k2="+strings.Repeat("00", 65))
+				return testBody
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "unknown key",
+			tamper: func(header http.Header) []byte {
+				header.Set(HeaderSignature, strings.NewReplacer("k1=", "k3=", "k2=", "k4=").Replace(header.Get(HeaderSignature)))
+				return testBody
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "shifted timestamp",
+			tamper: func(header http.Header) []byte {
+				// The signatures cover the timestamp
+				unix, _ := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+				header.Set(HeaderTimestamp, strconv.FormatInt(unix+1, 10))
+				return testBody
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "expired",
+			tamper: func(header http.Header) []byte {
+				header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(-DefaultTolerance-time.Minute).Unix(), 10))
+				return testBody
+			},
+			wantErr: ErrExpired,
+		},
+		{
+			name: "future",
+			tamper: func(header http.Header) []byte {
+				header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(DefaultTolerance+time.Minute).Unix(), 10))
+				return testBody
+			},
+			wantErr: ErrExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signing, verifying := parseTestKeys(t, "k1:hmac:secret", "k2:ecdsa")
+			header := signedHeader(t, signing)
+			body := tt.tamper(header)
+			if _, err := NewVerifier(verifying, 0).Verify(header, body); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRecoveryID(t *testing.T) {
+	signing, verifying := parseTestKeys(t, "k1:ecdsa")
+	header := signedHeader(t, signing)
+	// Signers of the Ethereum ecosystem often encode V as 27 or 28
+	id, encoded, _ := strings.Cut(header.Get(HeaderSignature), "=")
+	signature, err := hex.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("decoding signature: %v", err)
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	header.Set(HeaderSignature, id+"="+hex.EncodeToString(signature))
+	if _, err := NewVerifier(verifying, 0).Verify(header, testBody); err != nil {
+		t.Errorf("Verify with V of %d: %v", signature[crypto.RecoveryIDOffset], err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	for _, entry := range []string{"k1", "k1:hmac:", "k1:rsa:secret", "k1:ecdsa:not-a-key"} {
+		if _, err := ParseSigningKeys([]string{entry}); err == nil {
+			t.Errorf("signing key %q parsed", entry)
+		}
+	}
+	if _, err := ParseVerifyingKeys([]string{"k1:hmac:a", "k1:hmac:b"}); err == nil {
+		t.Error("duplicate key ids parsed")
+	}
+	// Key material is not leaked by errors
+	if _, err := ParseSigningKeys([]string{":hmac:topsecret"}); err == nil || strings.Contains(err.Error(), "topsecret") {
+		t.Errorf("error = %v, want the key redacted", err)
+	}
+}
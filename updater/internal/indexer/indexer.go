@@ -0,0 +1,289 @@
+package indexer
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/store"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// checkpointName is the name of the reindex checkpoint in the state store
+const checkpointName = "reindex"
+
+// minChunkSize is the smallest log query range chunks are split down to
+const minChunkSize = 1
+
+// ErrNoCheckpoint is returned when resuming without a reindex in progress
+var ErrNoCheckpoint = errors.New("no reindex in progress to resume")
+
+// Options configures a reindex
+type Options struct {
+	// FromBlock is the first block to index, 0 looks up the contract deployment block
+	FromBlock uint64
+	// ToBlock is the last block to index, 0 indexes up to the latest block
+	ToBlock uint64
+	// ChunkSize is the number of blocks per log query. Chunks are halved when
+	// the RPC rejects a query, e.g. for returning too many results.
+	ChunkSize uint64
+	// RequestsPerSecond limits the RPC request rate, 0 disables the limit
+	RequestsPerSecond float64
+	// Resume continues an interrupted reindex instead of starting over
+	Resume bool
+}
+
+// Progress is the state of a reindex, persisted after every chunk so an
+// interrupted reindex can be resumed
+type Progress struct {
+	FromBlock  uint64    `json:"from_block"`
+	ToBlock    uint64    `json:"to_block"`
+	NextBlock  uint64    `json:"next_block"`
+	LastRound  uint64    `json:"last_round"`
+	Rounds     uint64    `json:"rounds"`
+	StartedAt  time.Time `json:"started_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// Indexer rebuilds the local rounds index from the Drand Oracle contract's RandomnessUpdated events
+type Indexer struct {
+	rpcClient     *ethclient.Client
+	binding       *binding.Binding
+	oracleAddress common.Address
+	store         *store.Store
+
+	limiter <-chan time.Time
+	headers map[uint64]time.Time
+}
+
+func NewIndexer(rpcClient *ethclient.Client, binding *binding.Binding, oracleAddress common.Address, store *store.Store) *Indexer {
+	return &Indexer{
+		rpcClient:     rpcClient,
+		binding:       binding,
+		oracleAddress: oracleAddress,
+		store:         store,
+	}
+}
+
+// Reindex wipes the rounds index and rebuilds it from the contract's events,
+// or continues an interrupted reindex when opts.Resume is set
+func (i *Indexer) Reindex(ctx context.Context, opts Options) (*Progress, error) {
+	if opts.ChunkSize == 0 {
+		return nil, errors.New("chunk size must be positive")
+	}
+	if opts.RequestsPerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.RequestsPerSecond))
+		defer ticker.Stop()
+		i.limiter = ticker.C
+	}
+
+	progress, err := i.start(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := opts.ChunkSize
+	for progress.NextBlock <= progress.ToBlock {
+		from := progress.NextBlock
+		to := min(from+chunkSize-1, progress.ToBlock)
+
+		rounds, err := i.fetchRounds(ctx, from, to)
+		if err != nil {
+			if ctx.Err() != nil {
+				return progress, ctx.Err()
+			}
+			if chunkSize <= minChunkSize {
+				return progress, err
+			}
+			chunkSize = max(chunkSize/2, minChunkSize)
+			log.Warn().Err(err).Uint64("from_block", from).Uint64("chunk_size", chunkSize).Msg("Log query failed, retrying with a smaller chunk")
+			continue
+		}
+
+		for _, round := range rounds {
+			// Events indexed before an interruption are skipped on resume
+			if round.Round <= progress.LastRound {
+				continue
+			}
+			if err := i.store.AppendRound(round); err != nil {
+				return progress, err
+			}
+			progress.LastRound = round.Round
+			progress.Rounds++
+		}
+
+		progress.NextBlock = to + 1
+		progress.UpdatedAt = time.Now().UTC()
+		if err := i.store.SaveCheckpoint(checkpointName, progress); err != nil {
+			return progress, err
+		}
+		i.report(progress)
+
+		// Grow back towards the configured chunk size after a successful query
+		chunkSize = min(chunkSize*2, opts.ChunkSize)
+	}
+
+	progress.FinishedAt = time.Now().UTC()
+	if err := i.store.DeleteCheckpoint(checkpointName); err != nil {
+		return progress, err
+	}
+	return progress, nil
+}
+
+// start loads the checkpoint when resuming, or wipes the index and creates a new one
+func (i *Indexer) start(ctx context.Context, opts Options) (*Progress, error) {
+	var progress Progress
+	if opts.Resume {
+		ok, err := i.store.LoadCheckpoint(checkpointName, &progress)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNoCheckpoint
+		}
+		// Rounds are appended one by one while the checkpoint is saved after
+		// every chunk, so the index may be ahead of the checkpoint: the rounds
+		// it holds are not appended again
+		if count, highest := i.store.IndexedRounds(); highest > progress.LastRound {
+			progress.LastRound = highest
+			progress.Rounds = uint64(count)
+		}
+		log.Info().
+			Uint64("next_block", progress.NextBlock).
+			Uint64("to_block", progress.ToBlock).
+			Uint64("rounds", progress.Rounds).
+			Msg("Resuming reindex")
+		return &progress, nil
+	}
+
+	toBlock := opts.ToBlock
+	if toBlock == 0 {
+		i.wait()
+		latest, err := i.rpcClient.BlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		toBlock = latest
+	}
+	fromBlock := opts.FromBlock
+	if fromBlock == 0 {
+		fromBlock = i.deploymentBlock(ctx, toBlock)
+	}
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("from block %d is after to block %d", fromBlock, toBlock)
+	}
+
+	if err := i.store.ResetRounds(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	progress = Progress{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		NextBlock: fromBlock,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	if err := i.store.SaveCheckpoint(checkpointName, &progress); err != nil {
+		return nil, err
+	}
+	log.Info().Uint64("from_block", fromBlock).Uint64("to_block", toBlock).Msg("Starting reindex")
+	return &progress, nil
+}
+
+// fetchRounds returns the RandomnessUpdated events emitted in [from, to]
+func (i *Indexer) fetchRounds(ctx context.Context, from, to uint64) ([]store.Round, error) {
+	i.wait()
+	it, err := i.binding.FilterRandomnessUpdated(&bind.FilterOpts{Start: from, End: &to, Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	i.headers = make(map[uint64]time.Time)
+	var rounds []store.Round
+	for it.Next() {
+		event := it.Event
+		timestamp, err := i.blockTime(ctx, event.Raw.BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, store.Round{
+			Timestamp:   timestamp,
+			Round:       event.Round,
+			Randomness:  hex.EncodeToString(event.Randomness[:]),
+			Signature:   hex.EncodeToString(event.Signature),
+			BlockNumber: event.Raw.BlockNumber,
+			TxHash:      event.Raw.TxHash.Hex(),
+			LogIndex:    event.Raw.Index,
+		})
+	}
+	return rounds, it.Error()
+}
+
+// blockTime returns the timestamp of a block, cached for the current chunk
+func (i *Indexer) blockTime(ctx context.Context, number uint64) (time.Time, error) {
+	if t, ok := i.headers[number]; ok {
+		return t, nil
+	}
+	i.wait()
+	header, err := i.rpcClient.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return time.Time{}, err
+	}
+	t := time.Unix(int64(header.Time), 0).UTC()
+	i.headers[number] = t
+	return t, nil
+}
+
+// deploymentBlock binary searches the first block the contract has code at. It
+// needs an archive node and falls back to block 0 when history is unavailable.
+func (i *Indexer) deploymentBlock(ctx context.Context, latest uint64) uint64 {
+	low, high := uint64(0), latest
+	for low < high {
+		mid := low + (high-low)/2
+		i.wait()
+		code, err := i.rpcClient.CodeAt(ctx, i.oracleAddress, new(big.Int).SetUint64(mid))
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to look up the contract deployment block, indexing from block 0")
+			return 0
+		}
+		if len(code) > 0 {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	log.Info().Uint64("block", low).Msg("Found contract deployment block")
+	return low
+}
+
+// wait blocks until the rate limiter allows the next RPC request
+func (i *Indexer) wait() {
+	if i.limiter != nil {
+		<-i.limiter
+	}
+}
+
+func (i *Indexer) report(p *Progress) {
+	total := p.ToBlock - p.FromBlock + 1
+	done := p.NextBlock - p.FromBlock
+	event := log.Info().
+		Uint64("block", p.NextBlock-1).
+		Uint64("to_block", p.ToBlock).
+		Uint64("rounds", p.Rounds).
+		Str("progress", fmt.Sprintf("%.1f%%", float64(done)*100/float64(total)))
+	if elapsed := p.UpdatedAt.Sub(p.StartedAt); done > 0 && elapsed > 0 {
+		remaining := time.Duration(float64(elapsed) / float64(done) * float64(total-done))
+		event = event.Dur("eta", remaining.Round(time.Second))
+	}
+	event.Msg("Reindex progress")
+}
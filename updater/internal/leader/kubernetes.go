@@ -0,0 +1,245 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts in every pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// microTimeFormat is the format of the Lease times
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// lease is the subset of a coordination.k8s.io/v1 Lease used for the election
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// KubernetesLease is a lease held in a Kubernetes Lease object, through the
+// API server of the cluster the updater runs in. The pod's service account
+// needs get, create and update on leases in the namespace.
+type KubernetesLease struct {
+	apiServer  string
+	token      string
+	namespace  string
+	name       string
+	identity   string
+	duration   time.Duration
+	httpClient *http.Client
+}
+
+// NewKubernetesLease returns a lock on the Lease name in namespace, the pod's
+// namespace when empty, using the in-cluster service account credentials
+func NewKubernetesLease(namespace string, name string, identity string, duration time.Duration) (*KubernetesLease, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST is not set")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account CA")
+	}
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("error reading pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	return &KubernetesLease{
+		apiServer: "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: namespace,
+		name:      name,
+		identity:  identity,
+		duration:  duration,
+		httpClient: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}},
+	}, nil
+}
+
+func (k *KubernetesLease) Name() string {
+	return "kubernetes"
+}
+
+func (k *KubernetesLease) Acquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	current, err := k.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	if current == nil {
+		created := k.newLease(now)
+		status, err := k.write(ctx, http.MethodPost, k.collectionURL(), created)
+		if status == http.StatusConflict {
+			// Created by another replica in the meantime
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	spec := current.Spec
+	if spec.HolderIdentity != "" && spec.HolderIdentity != k.identity && !expired(spec, now) {
+		return false, nil
+	}
+	next := *current
+	next.Spec.HolderIdentity = k.identity
+	next.Spec.LeaseDurationSeconds = k.durationSeconds()
+	next.Spec.RenewTime = now.UTC().Format(microTimeFormat)
+	if spec.HolderIdentity != k.identity {
+		next.Spec.AcquireTime = next.Spec.RenewTime
+		next.Spec.LeaseTransitions++
+	}
+	// The resource version makes the update fail if another replica wrote
+	// the lease since it was read
+	status, err := k.write(ctx, http.MethodPut, k.leaseURL(), &next)
+	if status == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (k *KubernetesLease) Release(ctx context.Context) error {
+	current, err := k.get(ctx)
+	if err != nil || current == nil || current.Spec.HolderIdentity != k.identity {
+		return err
+	}
+	next := *current
+	next.Spec.HolderIdentity = ""
+	next.Spec.LeaseDurationSeconds = 1
+	next.Spec.RenewTime = time.Now().UTC().Format(microTimeFormat)
+	_, err = k.write(ctx, http.MethodPut, k.leaseURL(), &next)
+	return err
+}
+
+func (k *KubernetesLease) newLease(now time.Time) *lease {
+	at := now.UTC().Format(microTimeFormat)
+	return &lease{
+		APIVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata:   leaseMetadata{Name: k.name, Namespace: k.namespace},
+		Spec: leaseSpec{
+			HolderIdentity:       k.identity,
+			LeaseDurationSeconds: k.durationSeconds(),
+			AcquireTime:          at,
+			RenewTime:            at,
+		},
+	}
+}
+
+func (k *KubernetesLease) durationSeconds() int {
+	return int(math.Ceil(k.duration.Seconds()))
+}
+
+// expired reports whether a lease was not renewed within its duration
+func expired(spec leaseSpec, now time.Time) bool {
+	renewed, err := time.Parse(microTimeFormat, spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(spec.LeaseDurationSeconds) * time.Second))
+}
+
+func (k *KubernetesLease) collectionURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", k.apiServer, k.namespace)
+}
+
+func (k *KubernetesLease) leaseURL() string {
+	return k.collectionURL() + "/" + k.name
+}
+
+// get reads the lease, nil when it does not exist
+func (k *KubernetesLease) get(ctx context.Context) (*lease, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.leaseURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := k.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+	var l lease
+	if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// write creates or updates the lease and returns the response status
+func (k *KubernetesLease) write(ctx context.Context, method string, url string, l *lease) (int, error) {
+	body, err := json.Marshal(l)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := k.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, apiError(resp)
+	}
+	return resp.StatusCode, nil
+}
+
+func (k *KubernetesLease) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+k.token)
+	req.Header.Set("Accept", "application/json")
+	return k.httpClient.Do(req)
+}
+
+func apiError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("kubernetes API returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+}
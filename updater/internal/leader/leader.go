@@ -0,0 +1,153 @@
+// Package leader elects one leader among updater replicas through a lease held
+// in Redis or in a Kubernetes Lease, so that only the leader submits
+// transactions while the standby replicas stay synced.
+package leader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+// releaseTimeout bounds the release of the lease on shutdown
+const releaseTimeout = 5 * time.Second
+
+// Lock is a lease held by at most one replica at a time
+type Lock interface {
+	// Name describes the lock backend
+	Name() string
+	// Acquire takes the lease, or renews it when already held, for the lease
+	// duration and reports whether this replica holds it
+	Acquire(ctx context.Context) (bool, error)
+	// Release gives the lease up when held, so that a standby takes over
+	// without waiting for the lease to expire
+	Release(ctx context.Context) error
+}
+
+// Elector keeps trying to acquire the lease and tracks whether this replica is
+// the leader. A leader that fails to renew the lease steps down before the
+// lease expires, so that two replicas never both consider themselves leader.
+type Elector struct {
+	lock          Lock
+	identity      string
+	leaseDuration time.Duration
+	renewInterval time.Duration
+	isLeader      prometheus.Gauge
+
+	mu     sync.Mutex
+	leader bool
+	// elected is closed while this replica is the leader
+	elected chan struct{}
+	// renewedAt is when the last successful acquisition started
+	renewedAt time.Time
+}
+
+// NewElector returns an elector renewing the lease every third of the lease
+// duration. metricLabels are attached to its metric.
+func NewElector(lock Lock, identity string, leaseDuration time.Duration, metricLabels map[string]string) *Elector {
+	factory := promauto.With(prometheus.WrapRegistererWith(metricLabels, prometheus.DefaultRegisterer))
+	return &Elector{
+		lock:          lock,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		renewInterval: leaseDuration / 3,
+		isLeader: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "drand_leader",
+			Help: "Whether this replica is the leader submitting transactions",
+		}),
+		elected: make(chan struct{}),
+	}
+}
+
+// Identity returns the identity of this replica in the lease
+func (e *Elector) Identity() string {
+	return e.identity
+}
+
+// Run campaigns for the lease until ctx is cancelled, then releases it
+func (e *Elector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			e.setLeader(false)
+			releaseCtx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+			defer cancel()
+			if err := e.lock.Release(releaseCtx); err != nil {
+				log.Warn().Err(err).Str("lock", e.lock.Name()).Msg("Failed to release leader lease")
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign acquires or renews the lease once
+func (e *Elector) campaign(ctx context.Context) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, e.renewInterval)
+	defer cancel()
+	held, err := e.lock.Acquire(ctx)
+	if err != nil {
+		e.mu.Lock()
+		// Keep leading while the lease is certainly still ours, stepping down
+		// one renewal ahead of its expiry
+		valid := e.leader && time.Since(e.renewedAt) < e.leaseDuration-e.renewInterval
+		e.mu.Unlock()
+		log.Warn().Err(err).Str("lock", e.lock.Name()).Bool("leading", valid).Msg("Failed to acquire leader lease")
+		if !valid {
+			e.setLeader(false)
+		}
+		return
+	}
+	if held {
+		e.mu.Lock()
+		e.renewedAt = start
+		e.mu.Unlock()
+	}
+	e.setLeader(held)
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leader == leader {
+		return
+	}
+	e.leader = leader
+	if leader {
+		close(e.elected)
+		e.isLeader.Set(1)
+		log.Info().Str("identity", e.identity).Str("lock", e.lock.Name()).Msg("Elected leader, submitting transactions")
+	} else {
+		e.elected = make(chan struct{})
+		e.isLeader.Set(0)
+		log.Warn().Str("identity", e.identity).Str("lock", e.lock.Name()).Msg("Not the leader anymore, standing by")
+	}
+}
+
+// IsLeader reports whether this replica is the leader
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// Wait blocks until this replica is the leader
+func (e *Elector) Wait(ctx context.Context) error {
+	e.mu.Lock()
+	elected := e.elected
+	e.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-elected:
+		return nil
+	}
+}
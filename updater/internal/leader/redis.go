@@ -0,0 +1,206 @@
+package leader
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// renewScript extends the lease when held by this replica
+const renewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+
+// releaseScript deletes the lease when held by this replica
+const releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// RedisLock is a lease held as a Redis key set to the holder's identity with
+// an expiry. It speaks the Redis protocol directly, over a new connection per
+// operation, which is plenty at one operation per renewal interval.
+type RedisLock struct {
+	address  string
+	tls      bool
+	username string
+	password string
+	db       int
+	key      string
+	identity string
+	ttl      time.Duration
+}
+
+// NewRedisLock returns a lock on key at a redis:// or rediss:// URL, such as
+// redis://:password@redis:6379/0
+func NewRedisLock(rawURL string, key string, identity string, ttl time.Duration) (*RedisLock, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid redis url scheme %q, expected redis or rediss", u.Scheme)
+	}
+	l := &RedisLock{
+		address:  u.Host,
+		tls:      u.Scheme == "rediss",
+		key:      key,
+		identity: identity,
+		ttl:      ttl,
+	}
+	if u.Port() == "" {
+		l.address = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		l.username = u.User.Username()
+		l.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		l.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return l, nil
+}
+
+func (l *RedisLock) Name() string {
+	return "redis"
+}
+
+func (l *RedisLock) Acquire(ctx context.Context) (bool, error) {
+	ttl := strconv.FormatInt(l.ttl.Milliseconds(), 10)
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	renewed, err := conn.do("EVAL", renewScript, "1", l.key, l.identity, ttl)
+	if err != nil {
+		return false, err
+	}
+	if renewed == int64(1) {
+		return true, nil
+	}
+	set, err := conn.do("SET", l.key, l.identity, "NX", "PX", ttl)
+	if err != nil {
+		return false, err
+	}
+	// SET NX replies OK when set, and nil when the key is held by another replica
+	return set == "OK", nil
+}
+
+func (l *RedisLock) Release(ctx context.Context) error {
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.do("EVAL", releaseScript, "1", l.key, l.identity)
+	return err
+}
+
+// redisConn is a connection speaking RESP
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (l *RedisLock) dial(ctx context.Context) (*redisConn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", l.address)
+	if err != nil {
+		return nil, err
+	}
+	if l.tls {
+		host, _, _ := net.SplitHostPort(l.address)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c := &redisConn{Conn: conn, reader: bufio.NewReader(conn)}
+
+	if l.password != "" {
+		args := []string{"AUTH", l.password}
+		if l.username != "" {
+			args = []string{"AUTH", l.username, l.password}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if l.db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(l.db)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do sends a command and returns its reply: a string, an int64, nil or a
+// slice of replies
+func (c *redisConn) do(args ...string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return c.reply()
+}
+
+func (c *redisConn) reply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		replies := make([]any, n)
+		for i := range replies {
+			if replies[i], err = c.reply(); err != nil {
+				return nil, err
+			}
+		}
+		return replies, nil
+	}
+	return nil, fmt.Errorf("unexpected redis reply %q", line)
+}
@@ -0,0 +1,303 @@
+// Package limits keeps the updater within its memory and goroutine budgets,
+// degrading background work as the limits are approached rather than being
+// OOM-killed in the middle of a backfill.
+package limits
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultThreshold is the share of a limit above which the updater degrades
+	DefaultThreshold = 0.85
+
+	// recoveryMargin is how far below the threshold usage must drop for the
+	// updater to recover, so that it does not flap around the threshold
+	recoveryMargin = 0.1
+
+	// sampleInterval is the interval between usage samples
+	sampleInterval = 5 * time.Second
+)
+
+// Usage samples of the Go runtime
+const (
+	metricTotalMemory    = "/memory/classes/total:bytes"
+	metricReleasedMemory = "/memory/classes/heap/released:bytes"
+)
+
+// Options configures the governor
+type Options struct {
+	// MemoryLimit is the soft memory limit of the process in bytes, set with
+	// debug.SetMemoryLimit. 0 keeps the limit of GOMEMLIMIT, if any.
+	MemoryLimit int64
+	// MaxGoroutines is the number of goroutines the process should stay
+	// under, 0 disables the goroutine limit
+	MaxGoroutines int
+	// Threshold is the share of a limit above which the updater degrades,
+	// 0 uses DefaultThreshold
+	Threshold float64
+	// MaxConcurrentCatchUps is the number of pipelines fetching catch-up rounds
+	// at once, 0 for no limit. Degraded, a single pipeline catches up at a time.
+	MaxConcurrentCatchUps int
+	// MetricLabels are attached to every metric of the governor
+	MetricLabels map[string]string
+}
+
+// Status is the resource usage and degradation state of the process
+type Status struct {
+	Degraded bool `json:"degraded"`
+	// Reasons are the limits approached while degraded
+	Reasons       []string `json:"reasons,omitempty"`
+	MemoryBytes   uint64   `json:"memory_bytes"`
+	MemoryLimit   int64    `json:"memory_limit_bytes,omitempty"`
+	Goroutines    int      `json:"goroutines"`
+	MaxGoroutines int      `json:"max_goroutines,omitempty"`
+	// Since is the time the degradation state last changed
+	Since time.Time `json:"since"`
+}
+
+// Governor samples the resource usage of the process and degrades background
+// work while a limit is approached. A nil governor never degrades.
+type Governor struct {
+	options     Options
+	memoryLimit int64
+
+	mu     sync.Mutex
+	status Status
+	// recovered is closed and replaced when the process recovers
+	recovered chan struct{}
+	// catchUps holds a token per pipeline fetching catch-up rounds
+	catchUps   int
+	catchUpsCh chan struct{}
+
+	degraded    prometheus.Gauge
+	memory      prometheus.Gauge
+	goroutines  prometheus.Gauge
+	transitions *prometheus.CounterVec
+}
+
+// New applies the memory limit and returns the governor. It returns nil
+// when no limit is configured.
+func New(options Options) *Governor {
+	if options.MemoryLimit > 0 {
+		debug.SetMemoryLimit(options.MemoryLimit)
+	}
+	// A negative input only reads the limit, which GOMEMLIMIT may have set
+	memoryLimit := debug.SetMemoryLimit(-1)
+	if memoryLimit == math.MaxInt64 {
+		memoryLimit = 0
+	}
+	if memoryLimit == 0 && options.MaxGoroutines <= 0 && options.MaxConcurrentCatchUps <= 0 {
+		return nil
+	}
+	if options.Threshold <= 0 || options.Threshold > 1 {
+		options.Threshold = DefaultThreshold
+	}
+
+	g := &Governor{
+		options:     options,
+		memoryLimit: memoryLimit,
+		status:      Status{Since: time.Now().UTC()},
+		recovered:   make(chan struct{}),
+		catchUpsCh:  make(chan struct{}),
+	}
+	factory := promauto.With(prometheus.WrapRegistererWith(options.MetricLabels, prometheus.DefaultRegisterer))
+	g.degraded = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "drand_updater_degraded",
+		Help: "Whether the updater degraded its background work as a resource limit is approached",
+	})
+	g.memory = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "drand_updater_memory_bytes",
+		Help: "Memory mapped by the Go runtime and not returned to the operating system",
+	})
+	g.goroutines = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "drand_updater_goroutines",
+		Help: "Number of goroutines of the updater process",
+	})
+	g.transitions = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_updater_degradations_total",
+		Help: "Total number of times the updater degraded, by limit approached: memory or goroutines",
+	}, []string{"limit"})
+	return g
+}
+
+// MemoryLimit returns the soft memory limit in bytes, 0 when unlimited
+func (g *Governor) MemoryLimit() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.memoryLimit
+}
+
+// Run samples the resource usage until ctx is done
+func (g *Governor) Run(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for {
+		g.sample()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample reads the resource usage and updates the degradation state
+func (g *Governor) sample() {
+	samples := []metrics.Sample{{Name: metricTotalMemory}, {Name: metricReleasedMemory}}
+	metrics.Read(samples)
+	var memory uint64
+	if samples[0].Value.Kind() == metrics.KindUint64 && samples[1].Value.Kind() == metrics.KindUint64 {
+		memory = samples[0].Value.Uint64() - samples[1].Value.Uint64()
+	}
+	goroutines := runtime.NumGoroutine()
+	g.memory.Set(float64(memory))
+	g.goroutines.Set(float64(goroutines))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	threshold := g.options.Threshold
+	if g.status.Degraded {
+		threshold -= recoveryMargin
+	}
+	var reasons []string
+	if g.memoryLimit > 0 && float64(memory) >= threshold*float64(g.memoryLimit) {
+		reasons = append(reasons, "memory")
+	}
+	if g.options.MaxGoroutines > 0 && float64(goroutines) >= threshold*float64(g.options.MaxGoroutines) {
+		reasons = append(reasons, "goroutines")
+	}
+	g.status.MemoryBytes = memory
+	g.status.MemoryLimit = g.memoryLimit
+	g.status.Goroutines = goroutines
+	g.status.MaxGoroutines = g.options.MaxGoroutines
+
+	degraded := len(reasons) > 0
+	g.status.Reasons = reasons
+	if degraded == g.status.Degraded {
+		return
+	}
+	g.status.Degraded = degraded
+	g.status.Since = time.Now().UTC()
+	if degraded {
+		g.degraded.Set(1)
+		for _, reason := range reasons {
+			g.transitions.WithLabelValues(reason).Inc()
+		}
+		log.Warn().
+			Strs("limits", reasons).
+			Uint64("memory_bytes", memory).
+			Int("goroutines", goroutines).
+			Msg("Resource limits approached, degrading background work")
+		// Return the memory freed by the degraded work to the operating
+		// system rather than waiting for the scavenger
+		go debug.FreeOSMemory()
+	} else {
+		g.degraded.Set(0)
+		log.Info().
+			Uint64("memory_bytes", memory).
+			Int("goroutines", goroutines).
+			Msg("Resource usage back below the limits, resuming background work")
+		close(g.recovered)
+		g.recovered = make(chan struct{})
+	}
+	g.wakeCatchUps()
+}
+
+// Degraded reports whether background work should be degraded
+func (g *Governor) Degraded() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status.Degraded
+}
+
+// Status returns the resource usage and degradation state, nil for a nil
+// governor
+func (g *Governor) Status() *Status {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status := g.status
+	status.Reasons = append([]string(nil), g.status.Reasons...)
+	return &status
+}
+
+// WaitRecovered blocks while the updater is degraded
+func (g *Governor) WaitRecovered(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	for {
+		g.mu.Lock()
+		degraded, recovered := g.status.Degraded, g.recovered
+		g.mu.Unlock()
+		if !degraded {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-recovered:
+		}
+	}
+}
+
+// AcquireCatchUp blocks until the pipeline may fetch a catch-up round, and
+// returns the function releasing its slot
+func (g *Governor) AcquireCatchUp(ctx context.Context) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	for {
+		g.mu.Lock()
+		limit := g.options.MaxConcurrentCatchUps
+		if g.status.Degraded {
+			limit = 1
+		}
+		if limit <= 0 || g.catchUps < limit {
+			g.catchUps++
+			g.mu.Unlock()
+			return g.releaseCatchUp, nil
+		}
+		wake := g.catchUpsCh
+		g.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+func (g *Governor) releaseCatchUp() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.catchUps--
+	g.wakeCatchUps()
+}
+
+// wakeCatchUps wakes up the pipelines waiting for a catch-up slot, it must be
+// called with the lock held
+func (g *Governor) wakeCatchUps() {
+	close(g.catchUpsCh)
+	g.catchUpsCh = make(chan struct{})
+}
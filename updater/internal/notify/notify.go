@@ -0,0 +1,267 @@
+// Package notify posts the rounds landing on the oracle to the webhooks of
+// downstream consumers, so that they learn of a new round without watching
+// the chain themselves. Each webhook has its own queue and delivery goroutine,
+// so that a slow or failing webhook does not delay the others.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/internal/httpsig"
+	"drand-oracle-updater/internal/retry"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+const labelWebhook = "webhook"
+
+// Delivery results
+const (
+	resultDelivered = "delivered"
+	resultFailed    = "failed"
+	resultDropped   = "dropped"
+)
+
+// Round is the payload posted for a round set on the oracle
+type Round struct {
+	Pipeline    string    `json:"pipeline,omitempty"`
+	ChainID     int64     `json:"chain_id"`
+	Oracle      string    `json:"oracle"`
+	Round       uint64    `json:"round"`
+	Randomness  string    `json:"randomness"`
+	Signature   string    `json:"signature"`
+	TxHash      string    `json:"tx_hash"`
+	BlockNumber uint64    `json:"block_number"`
+	BlockTime   time.Time `json:"block_time"`
+}
+
+// Options configures the deliveries
+type Options struct {
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration
+	// Retry is the retry policy of a delivery, its circuit breaker is not used
+	Retry retry.Policy
+	// QueueSize is the number of rounds waiting to be delivered to a webhook
+	// above which new rounds are dropped for it
+	QueueSize int
+	// Signer signs the payloads, nil sends them unsigned
+	Signer *httpsig.Signer
+	// MetricLabels are attached to every metric
+	MetricLabels map[string]string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 100
+	}
+	o.Retry.BreakerThreshold = 0
+	return o
+}
+
+// delivery is a round waiting to be delivered
+type delivery struct {
+	round    Round
+	queuedAt time.Time
+}
+
+// webhook is a consumer's webhook and its queue of rounds
+type webhook struct {
+	url string
+	// name identifies the webhook in logs and metrics without its path and
+	// query, which may carry credentials
+	name    string
+	queue   chan delivery
+	retrier *retry.Retrier
+}
+
+// Notifier delivers rounds to the webhooks. It is safe for concurrent use by
+// the pipelines.
+type Notifier struct {
+	webhooks   []*webhook
+	options    Options
+	httpClient *http.Client
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	deliveries *prometheus.CounterVec
+	attempts   *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	queued     *prometheus.GaugeVec
+}
+
+// New returns a notifier delivering to the webhooks at urls, and starts
+// delivering
+func New(urls []string, options Options) (*Notifier, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no webhook")
+	}
+	if _, ok := options.MetricLabels[labelWebhook]; ok {
+		return nil, fmt.Errorf("deployment label %q is reserved for metric labels", labelWebhook)
+	}
+	options = options.withDefaults()
+	n := &Notifier{
+		options:    options,
+		httpClient: &http.Client{Timeout: options.Timeout},
+	}
+	if err := ValidateURLs(urls); err != nil {
+		return nil, err
+	}
+	for _, raw := range urls {
+		n.webhooks = append(n.webhooks, &webhook{
+			url:     raw,
+			name:    webhookName(raw),
+			queue:   make(chan delivery, options.QueueSize),
+			retrier: retry.New("consumer_webhook", options.Retry, nil),
+		})
+	}
+
+	factory := promauto.With(prometheus.WrapRegistererWith(options.MetricLabels, prometheus.DefaultRegisterer))
+	n.deliveries = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_webhook_deliveries_total",
+		Help: "Total number of rounds posted to a consumer webhook by result: delivered, failed after every attempt or dropped from a full queue",
+	}, []string{labelWebhook, "result"})
+	n.attempts = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_webhook_attempts_total",
+		Help: "Total number of delivery attempts to a consumer webhook by result",
+	}, []string{labelWebhook, "result"})
+	n.latency = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "drand_webhook_delivery_latency_seconds",
+		Help:    "Time from a round being queued to its delivery to a consumer webhook, retries included",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{labelWebhook})
+	n.queued = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_webhook_queue_length",
+		Help: "Number of rounds waiting to be delivered to a consumer webhook",
+	}, []string{labelWebhook})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	for _, w := range n.webhooks {
+		n.queued.WithLabelValues(w.name).Set(0)
+		n.wg.Add(1)
+		go n.deliver(ctx, w)
+	}
+	return n, nil
+}
+
+// ValidateURLs checks that urls are HTTP or HTTPS webhook URLs
+func ValidateURLs(urls []string) error {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q", webhookName(raw))
+		}
+	}
+	return nil
+}
+
+// webhookName is the scheme and host of a webhook URL
+func webhookName(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Notify queues a round for every webhook, without waiting for its delivery.
+// A round is dropped for a webhook whose queue is full.
+func (n *Notifier) Notify(round Round) {
+	queuedAt := time.Now()
+	for _, w := range n.webhooks {
+		select {
+		case w.queue <- delivery{round: round, queuedAt: queuedAt}:
+			n.queued.WithLabelValues(w.name).Set(float64(len(w.queue)))
+		default:
+			n.deliveries.WithLabelValues(w.name, resultDropped).Inc()
+			log.Warn().Str("webhook", w.name).Uint64("round", round.Round).Msg("Consumer webhook queue full, dropping round")
+		}
+	}
+}
+
+// deliver posts the queued rounds of a webhook in order until ctx is done
+func (n *Notifier) deliver(ctx context.Context, w *webhook) {
+	defer n.wg.Done()
+	for {
+		var d delivery
+		select {
+		case <-ctx.Done():
+			return
+		case d = <-w.queue:
+		}
+		n.queued.WithLabelValues(w.name).Set(float64(len(w.queue)))
+
+		round := d.round
+		err := w.retrier.Do(ctx, func(ctx context.Context) error {
+			err := n.post(ctx, w, round)
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			n.attempts.WithLabelValues(w.name, result).Inc()
+			return err
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			n.deliveries.WithLabelValues(w.name, resultFailed).Inc()
+			log.Error().Err(err).Str("webhook", w.name).Uint64("round", round.Round).Msg("Failed to deliver round to consumer webhook")
+			continue
+		}
+		n.deliveries.WithLabelValues(w.name, resultDelivered).Inc()
+		n.latency.WithLabelValues(w.name).Observe(time.Since(d.queuedAt).Seconds())
+	}
+}
+
+// post sends a round to a webhook. Client errors other than rate limiting
+// are not retried.
+func (n *Notifier) post(ctx context.Context, w *webhook, round Round) error {
+	data, err := json.Marshal(round)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := n.options.Signer.Sign(req, data); err != nil {
+		return retry.Permanent(err)
+	}
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s returned %s: %s", w.name, resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusRequestTimeout {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// Close stops the deliveries, dropping the queued rounds
+func (n *Notifier) Close() {
+	n.cancel()
+	n.wg.Wait()
+}
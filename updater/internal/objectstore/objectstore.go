@@ -0,0 +1,137 @@
+// Package objectstore uploads objects to an S3-compatible object storage,
+// signing requests with AWS Signature Version 4
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// uploadTimeout bounds an object upload
+const uploadTimeout = 1 * time.Minute
+
+// Config locates the bucket of an S3-compatible object storage
+type Config struct {
+	// Endpoint is the base URL of the storage, e.g.
+	// https://s3.eu-west-1.amazonaws.com, addressed path-style
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Client uploads objects to a bucket
+type Client struct {
+	config Config
+	client *http.Client
+}
+
+// New returns a client of the bucket
+func New(config Config) (*Client, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("the S3 endpoint and bucket are required")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	return &Client{
+		config: config,
+		client: &http.Client{Timeout: uploadTimeout},
+	}, nil
+}
+
+// Bucket returns the name of the bucket
+func (c *Client) Bucket() string {
+	return c.config.Bucket
+}
+
+// URL returns the path-style URL of an object
+func (c *Client) URL(key string) string {
+	return c.config.Endpoint + "/" + c.config.Bucket + "/" + key
+}
+
+// Put uploads an object, replacing the object of the same key
+func (c *Client) Put(ctx context.Context, key, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.URL(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256Hex(body)
+	amzDate := time.Now().UTC().Format("20060102T150405Z")
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.config.AccessKeyID != "" {
+		req.Header.Set("Authorization", c.authorization(req, payloadHash, amzDate))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 put returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// authorization returns the Signature Version 4 authorization header of a
+// request without query string
+func (c *Client) authorization(req *http.Request, payloadHash, amzDate string) string {
+	date := amzDate[:8]
+	scope := date + "/" + c.config.Region + "/s3/aws4_request"
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+c.config.SecretAccessKey), date)
+	key = hmacSHA256(key, c.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.config.AccessKeyID, scope, signedHeaders, signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
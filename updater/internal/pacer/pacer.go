@@ -0,0 +1,161 @@
+// Package pacer provides a drand client whose Watch polls the relays on the
+// round schedule of the drand network: it sleeps until just before the next
+// round is due, computed from the chain info, then polls for that round only
+// until it is published, instead of polling every relay at fixed intervals.
+package pacer
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/client"
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults of the options left to zero
+const (
+	DefaultLead     = 100 * time.Millisecond
+	DefaultInterval = 500 * time.Millisecond
+)
+
+// infoRetryInterval is the interval between attempts to get the chain info
+const infoRetryInterval = 5 * time.Second
+
+// Options configures the polling
+type Options struct {
+	// Lead is how long before a round is due the first poll is sent
+	Lead time.Duration
+	// Interval is the delay between polls until the round is published
+	Interval time.Duration
+	// Jitter bounds the random delay added to every poll, so that updaters
+	// sharing relays do not poll in lockstep, 0 disabling it
+	Jitter time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Lead <= 0 {
+		o.Lead = DefaultLead
+	}
+	if o.Interval <= 0 {
+		o.Interval = DefaultInterval
+	}
+	if o.Jitter < 0 {
+		o.Jitter = 0
+	}
+	return o
+}
+
+// Client is a drand client polling the wrapped client for each round when it
+// is due
+type Client struct {
+	client.Client
+	options Options
+}
+
+func NewClient(c client.Client, options Options) *Client {
+	return &Client{Client: c, options: options.withDefaults()}
+}
+
+// Watch emits the latest round, then every round as it is published. Rounds
+// are awaited in order, so that none is skipped when the relays fail.
+func (c *Client) Watch(ctx context.Context) <-chan client.Result {
+	out := make(chan client.Result)
+	go func() {
+		defer close(out)
+
+		var info *chain.Info
+		for {
+			var err error
+			info, err = c.Info(ctx)
+			if err == nil {
+				break
+			}
+			log.Error().Err(err).Dur("retry_in", infoRetryInterval).Msg("Failed to get drand info to schedule polling")
+			if !sleep(ctx, infoRetryInterval) {
+				return
+			}
+		}
+
+		round := c.RoundAt(time.Now())
+		for {
+			result, err := c.await(ctx, info, round)
+			if err != nil {
+				return
+			}
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				return
+			}
+			round = result.Round() + 1
+		}
+	}()
+	return out
+}
+
+// await sleeps until shortly before the round is due, then polls for it until
+// it is published. A round overdue by a period is polled once per period only,
+// as the relays are failing rather than late.
+func (c *Client) await(ctx context.Context, info *chain.Info, round uint64) (client.Result, error) {
+	due := time.Unix(chain.TimeOfRound(info.Period, info.GenesisTime, round), 0)
+	if !sleep(ctx, time.Until(due.Add(-c.options.Lead))+c.jitter()) {
+		return nil, ctx.Err()
+	}
+
+	overdue := false
+	for polls := 1; ; polls++ {
+		result, err := c.Get(ctx, round)
+		if err == nil && result.Round() == round {
+			log.Debug().
+				Uint64("round", round).
+				Int("polls", polls).
+				Dur("delay", time.Since(due)).
+				Msg("Drand round published")
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		interval := c.options.Interval
+		if time.Since(due) > info.Period {
+			if !overdue {
+				log.Warn().
+					Err(err).
+					Uint64("round", round).
+					Int("polls", polls).
+					Msg("Drand round not published one period after it was due, polling once per period")
+				overdue = true
+			}
+			interval = info.Period
+		}
+		if !sleep(ctx, interval+c.jitter()) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// jitter returns a random delay up to the configured jitter
+func (c *Client) jitter() time.Duration {
+	if c.options.Jitter == 0 {
+		return 0
+	}
+	return rand.N(c.options.Jitter + 1)
+}
+
+// sleep waits for d, returning false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
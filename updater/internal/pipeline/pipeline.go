@@ -0,0 +1,93 @@
+// Package pipeline runs items through a sequence of stages, each processing up
+// to its number of workers items concurrently, and emits the items in the
+// order they came in. Ordering is kept at every stage, so that a slow item
+// holds back the items behind it rather than being overtaken, as rounds must
+// reach the oracle in order.
+package pipeline
+
+import (
+	"context"
+)
+
+// Stage is a step of a pipeline
+type Stage[T any] struct {
+	// Name identifies the stage in the results of failed items
+	Name string
+	// Workers is the number of items processed concurrently, at least 1
+	Workers int
+	// Process processes an item, returning the item passed to the next stage
+	Process func(ctx context.Context, item T) (T, error)
+}
+
+// Result is an item out of a pipeline
+type Result[T any] struct {
+	Item T
+	// Err is the error of the stage that failed the item, whose following
+	// stages were skipped
+	Err error
+	// Stage is the name of the stage that failed the item, empty on success
+	Stage string
+}
+
+// Run starts the stages and returns the results of the items read from in, in
+// order. The caller closes in and reads the results until the returned channel
+// is closed. Once ctx is cancelled the items left are failed with its error
+// rather than processed.
+func Run[T any](ctx context.Context, in <-chan T, stages ...Stage[T]) <-chan Result[T] {
+	source := make(chan Result[T])
+	go func() {
+		defer close(source)
+		for item := range in {
+			source <- Result[T]{Item: item}
+		}
+	}()
+	results := source
+	for _, stage := range stages {
+		results = stage.run(ctx, results)
+	}
+	return results
+}
+
+// run processes the items of in with the stage's workers, emitting them in
+// the order of in
+func (s Stage[T]) run(ctx context.Context, in <-chan Result[T]) chan Result[T] {
+	workers := max(s.Workers, 1)
+	// slots bounds the items processed concurrently, and pending holds the
+	// items in flight in order, each with the channel of its result
+	slots := make(chan struct{}, workers)
+	pending := make(chan chan Result[T], workers)
+	go func() {
+		defer close(pending)
+		for result := range in {
+			done := make(chan Result[T], 1)
+			pending <- done
+			if result.Err != nil {
+				done <- result
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				done <- Result[T]{Item: result.Item, Err: err, Stage: s.Name}
+				continue
+			}
+			slots <- struct{}{}
+			go func(item T) {
+				defer func() { <-slots }()
+				processed, err := s.Process(ctx, item)
+				if err != nil {
+					done <- Result[T]{Item: processed, Err: err, Stage: s.Name}
+					return
+				}
+				done <- Result[T]{Item: processed}
+			}(result.Item)
+		}
+	}()
+
+	out := make(chan Result[T])
+	go func() {
+		defer close(out)
+		for done := range pending {
+			out <- <-done
+		}
+	}()
+	return out
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// collect runs items 0 to n-1 through the stages and returns the results
+func collect(ctx context.Context, n int, stages ...Stage[int]) []Result[int] {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 0; i < n; i++ {
+			in <- i
+		}
+	}()
+	var results []Result[int]
+	for result := range Run(ctx, in, stages...) {
+		results = append(results, result)
+	}
+	return results
+}
+
+// jittered returns a stage adding add to items after a random delay, so that
+// the workers finish out of order
+func jittered(name string, workers int, add int) Stage[int] {
+	return Stage[int]{
+		Name:    name,
+		Workers: workers,
+		Process: func(ctx context.Context, item int) (int, error) {
+			time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+			return item + add, nil
+		},
+	}
+}
+
+func TestRunOrder(t *testing.T) {
+	const n = 100
+	results := collect(context.Background(), n, jittered("first", 8, 1000), jittered("second", 3, 1000000))
+	if len(results) != n {
+		t.Fatalf("got %d results, want %d", len(results), n)
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("item %d failed: %v", i, result.Err)
+		}
+		// Every stage processed the item, emitted in order
+		if want := i + 1001000; result.Item != want {
+			t.Errorf("result %d = %d, want %d", i, result.Item, want)
+		}
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	errOdd := errors.New("odd item")
+	failing := Stage[int]{
+		Name:    "failing",
+		Workers: 4,
+		Process: func(ctx context.Context, item int) (int, error) {
+			if item%2 == 1 {
+				return item, errOdd
+			}
+			return item, nil
+		},
+	}
+	results := collect(context.Background(), 10, failing, jittered("next", 2, 100))
+	for i, result := range results {
+		if i%2 == 0 {
+			if result.Err != nil || result.Item != i+100 {
+				t.Errorf("result %d = %d, %v, want %d", i, result.Item, result.Err, i+100)
+			}
+			continue
+		}
+		// The stages following the failed stage are skipped
+		if !errors.Is(result.Err, errOdd) || result.Stage != "failing" || result.Item != i {
+			t.Errorf("result %d = %d, %v in stage %q, want %d failed in stage failing", i, result.Item, result.Err, result.Stage, i)
+		}
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	processed := false
+	stage := Stage[int]{
+		Name: "stage",
+		Process: func(ctx context.Context, item int) (int, error) {
+			processed = true
+			return item, nil
+		},
+	}
+	for _, result := range collect(ctx, 5, stage) {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("item %d not failed with the cancelled context: %v", result.Item, result.Err)
+		}
+	}
+	if processed {
+		t.Error("item processed after the context was cancelled")
+	}
+}
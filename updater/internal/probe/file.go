@@ -0,0 +1,86 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Files touches a liveness file while every pipeline is live, and a readiness
+// file while every pipeline is ready, for exec probes checking the age of the
+// files. The readiness file is removed as soon as a pipeline is not ready.
+type Files struct {
+	health        *Health
+	livenessPath  string
+	readinessPath string
+	interval      time.Duration
+}
+
+// NewFiles returns the file probes of health, an empty path disabling its
+// probe, checked every interval
+func NewFiles(health *Health, livenessPath string, readinessPath string, interval time.Duration) *Files {
+	return &Files{
+		health:        health,
+		livenessPath:  livenessPath,
+		readinessPath: readinessPath,
+		interval:      interval,
+	}
+}
+
+// Run updates the files until ctx is cancelled, then removes the readiness file
+func (f *Files) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		f.update(ctx)
+		select {
+		case <-ctx.Done():
+			if f.readinessPath != "" {
+				remove(f.readinessPath)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *Files) update(ctx context.Context) {
+	// A liveness file that is not touched anymore gets stale, restarting the
+	// process once the probe's threshold is reached
+	if f.livenessPath != "" && f.health.Live() {
+		touch(f.livenessPath)
+	}
+	if f.readinessPath != "" {
+		if f.health.Ready(ctx) {
+			touch(f.readinessPath)
+		} else {
+			remove(f.readinessPath)
+		}
+	}
+}
+
+// touch creates a file or updates its modification time
+func touch(path string) {
+	now := time.Now()
+	err := os.Chtimes(path, now, now)
+	if errors.Is(err, fs.ErrNotExist) {
+		var file *os.File
+		file, err = os.Create(path)
+		if err == nil {
+			err = file.Close()
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to touch probe file")
+	}
+}
+
+func remove(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error().Err(err).Str("path", path).Msg("Failed to remove probe file")
+	}
+}
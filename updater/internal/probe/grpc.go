@@ -0,0 +1,92 @@
+package probe
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// LivenessService is the gRPC health service name checking liveness instead
+// of readiness
+const LivenessService = "liveness"
+
+// GRPCHealth implements the grpc.health.v1 health service. The empty service
+// name checks the readiness of every pipeline, a pipeline name the readiness
+// of that pipeline, and LivenessService the liveness of every pipeline.
+type GRPCHealth struct {
+	healthpb.UnimplementedHealthServer
+	health *Health
+	// watchInterval is the interval between checks of watched services
+	watchInterval time.Duration
+}
+
+// NewGRPCHealth returns the gRPC health service of health, re-checking watched
+// services every watchInterval
+func NewGRPCHealth(health *Health, watchInterval time.Duration) *GRPCHealth {
+	return &GRPCHealth{health: health, watchInterval: watchInterval}
+}
+
+// NewGRPCServer returns a gRPC server serving only the health service
+func NewGRPCServer(health *GRPCHealth) *grpc.Server {
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health)
+	return server
+}
+
+func (g *GRPCHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	serving, found := g.check(ctx, req.GetService())
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: serving}, nil
+}
+
+func (g *GRPCHealth) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(g.watchInterval)
+	defer ticker.Stop()
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		serving, found := g.check(ctx, req.GetService())
+		if !found {
+			serving = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		// Only changes are sent, as per the health checking protocol
+		if serving != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: serving}); err != nil {
+				return err
+			}
+			last = serving
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// check returns the serving status of a service, and whether it exists
+func (g *GRPCHealth) check(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	var ok bool
+	switch service {
+	case "":
+		ok = g.health.Ready(ctx)
+	case LivenessService:
+		ok = g.health.Live()
+	default:
+		var found bool
+		ok, found = g.health.PipelineReady(ctx, service)
+		if !found {
+			return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+		}
+	}
+	if !ok {
+		return healthpb.HealthCheckResponse_NOT_SERVING, true
+	}
+	return healthpb.HealthCheckResponse_SERVING, true
+}
@@ -0,0 +1,65 @@
+// Package probe exposes the health of the updaters to orchestrators whose
+// probes cannot do HTTP: as a gRPC health service and as files touched while
+// healthy. Both reflect the same liveness and readiness checks as the /live
+// and /ready HTTP endpoints.
+package probe
+
+import (
+	"context"
+	"sort"
+
+	"drand-oracle-updater/internal/service"
+)
+
+// Target is a component whose health is probed, such as an updater
+type Target interface {
+	Liveness() (bool, []service.CheckResult)
+	Readiness(ctx context.Context) (bool, []service.CheckResult)
+}
+
+// Health aggregates the health of the updater of every pipeline
+type Health struct {
+	targets map[string]Target
+	names   []string
+}
+
+// NewHealth returns the health of the targets, keyed by pipeline name
+func NewHealth(targets map[string]Target) *Health {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &Health{targets: targets, names: names}
+}
+
+// Live reports whether every target is live
+func (h *Health) Live() bool {
+	for _, name := range h.names {
+		if ok, _ := h.targets[name].Liveness(); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready reports whether every target is ready
+func (h *Health) Ready(ctx context.Context) bool {
+	for _, name := range h.names {
+		if ok, _ := h.targets[name].Readiness(ctx); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// PipelineReady reports whether the target of a pipeline is ready, and
+// whether the pipeline exists
+func (h *Health) PipelineReady(ctx context.Context, name string) (ready bool, found bool) {
+	target, found := h.targets[name]
+	if !found {
+		return false, false
+	}
+	ready, _ = target.Readiness(ctx)
+	return ready, true
+}
@@ -0,0 +1,74 @@
+package records
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// CBOR major types
+const (
+	cborUnsigned byte = 0
+	cborNegative byte = 1
+	cborText     byte = 3
+	cborMap      byte = 5
+	cborTag      byte = 6
+)
+
+// cborTagDateTime tags an RFC 3339 date/time string
+const cborTagDateTime = 0
+
+// encodeCBOR encodes fields as a CBOR map keyed by field name, times as
+// tagged RFC 3339 strings
+func encodeCBOR(fields []field) []byte {
+	count := 0
+	for _, f := range fields {
+		if !isZero(f.value) {
+			count++
+		}
+	}
+	b := cborHead(nil, cborMap, uint64(count))
+	for _, f := range fields {
+		if isZero(f.value) {
+			continue
+		}
+		b = cborString(b, f.name)
+		switch v := f.value.(type) {
+		case uint64:
+			b = cborHead(b, cborUnsigned, v)
+		case int64:
+			if v < 0 {
+				b = cborHead(b, cborNegative, uint64(-1-v))
+			} else {
+				b = cborHead(b, cborUnsigned, uint64(v))
+			}
+		case string:
+			b = cborString(b, v)
+		case time.Time:
+			b = cborHead(b, cborTag, cborTagDateTime)
+			b = cborString(b, v.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	return b
+}
+
+func cborString(b []byte, s string) []byte {
+	return append(cborHead(b, cborText, uint64(len(s))), s...)
+}
+
+// cborHead appends the initial byte of a data item of a major type with its
+// argument, in the shortest form
+func cborHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		return append(b, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), n)
+	}
+}
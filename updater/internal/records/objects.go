@@ -0,0 +1,251 @@
+package records
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/internal/objectstore"
+	"drand-oracle-updater/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultEraRounds is the number of rounds of an era when none is configured
+	DefaultEraRounds = 100000
+
+	// objectQueueSize bounds the rounds waiting in memory to be uploaded,
+	// rounds indexed while the queue is full are spilled to the pending list
+	objectQueueSize = 1024
+
+	// objectAttempts is the number of uploads of a round before it is moved
+	// to the pending list
+	objectAttempts = 3
+
+	// pendingRetryInterval is the interval between uploads of the pending list
+	pendingRetryInterval = 1 * time.Minute
+)
+
+// RoundObjectsOptions lays out the rounds in the bucket
+type RoundObjectsOptions struct {
+	// Prefix is prepended to the keys
+	Prefix string
+	// ChainHash is the drand chain hash of the rounds, hex encoded
+	ChainHash string
+	// EraRounds is the number of rounds of an era, DefaultEraRounds when 0
+	EraRounds uint64
+	// PublicURL is the base URL consumers fetch the objects from, such as a
+	// CDN or a public bucket website, the path-style URL of the bucket when
+	// empty
+	PublicURL string
+	// PendingPath is the file persisting the rounds not uploaded yet, when the
+	// queue is full or their uploads failed, so that they are uploaded later,
+	// also after a restart. Such rounds are only logged when empty.
+	PendingPath string
+}
+
+// RoundObjects uploads every indexed round to an object storage bucket as
+// its own JSON object, keyed by chain hash, era and round, e.g.
+// <chain hash>/12/1234567.json, so that consumers fetch any round by key
+// rather than scanning a bundle. It is a store.Sink, uploading in the
+// background so that the store is never held by the bucket.
+type RoundObjects struct {
+	client  *objectstore.Client
+	options RoundObjectsOptions
+	queue   chan store.Round
+
+	// pending guards the pending list
+	pending sync.Mutex
+}
+
+// NewRoundObjects returns a sink uploading the rounds to the bucket of
+// client, and starts uploading
+func NewRoundObjects(client *objectstore.Client, options RoundObjectsOptions) *RoundObjects {
+	if options.EraRounds == 0 {
+		options.EraRounds = DefaultEraRounds
+	}
+	options.Prefix = strings.Trim(options.Prefix, "/")
+	options.PublicURL = strings.TrimSuffix(options.PublicURL, "/")
+	o := &RoundObjects{
+		client:  client,
+		options: options,
+		queue:   make(chan store.Round, objectQueueSize),
+	}
+	go o.upload()
+	return o
+}
+
+// Key returns the key of the object of a round
+func (o *RoundObjects) Key(round uint64) string {
+	key := fmt.Sprintf("%s/%d/%d.json", o.options.ChainHash, round/o.options.EraRounds, round)
+	if o.options.Prefix != "" {
+		key = o.options.Prefix + "/" + key
+	}
+	return key
+}
+
+// URL returns the URL consumers fetch the object of a round from
+func (o *RoundObjects) URL(round uint64) string {
+	if o.options.PublicURL != "" {
+		return o.options.PublicURL + "/" + o.Key(round)
+	}
+	return o.client.URL(o.Key(round))
+}
+
+// Write queues a round for upload, spilling it to the pending list when the
+// queue is full. Records other than rounds are ignored.
+func (o *RoundObjects) Write(collection string, record any) {
+	round, ok := record.(store.Round)
+	if !ok {
+		return
+	}
+	select {
+	case o.queue <- round:
+	default:
+		o.spill(round, "Round upload queue is full")
+	}
+}
+
+// Put uploads the object of a round
+func (o *RoundObjects) Put(ctx context.Context, round store.Round) error {
+	data, err := json.Marshal(round)
+	if err != nil {
+		return err
+	}
+	return o.client.Put(ctx, o.Key(round.Round), "application/json", data)
+}
+
+// upload uploads the queued rounds, and the pending list every
+// pendingRetryInterval
+func (o *RoundObjects) upload() {
+	o.uploadPending()
+	ticker := time.NewTicker(pendingRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case round := <-o.queue:
+			if err := o.put(round); err != nil {
+				o.spill(round, "Failed to upload round")
+			}
+		case <-ticker.C:
+			o.uploadPending()
+		}
+	}
+}
+
+// put uploads a round, retrying failed uploads a few times
+func (o *RoundObjects) put(round store.Round) error {
+	var err error
+	for attempt := 1; attempt <= objectAttempts; attempt++ {
+		if err = o.Put(context.Background(), round); err == nil {
+			return nil
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	return err
+}
+
+// spill appends a round to the pending list, logging why
+func (o *RoundObjects) spill(round store.Round, reason string) {
+	logger := log.With().Uint64("round", round.Round).Str("bucket", o.client.Bucket()).Logger()
+	if o.options.PendingPath == "" {
+		logger.Error().Msg(reason + ", round missing from the bucket")
+		return
+	}
+	if err := o.keepPending(round); err != nil {
+		logger.Error().Err(err).Msg(reason + ", and failed to keep it pending: round missing from the bucket")
+		return
+	}
+	logger.Debug().Msg(reason + ", uploading it later")
+}
+
+// keepPending appends a round to the pending list
+func (o *RoundObjects) keepPending(round store.Round) error {
+	data, err := json.Marshal(round)
+	if err != nil {
+		return err
+	}
+	o.pending.Lock()
+	defer o.pending.Unlock()
+	return appendLine(o.options.PendingPath, data)
+}
+
+// uploadPending uploads the rounds of the pending list. The list is moved
+// aside while it is uploaded, so that rounds spilled meanwhile are kept, and
+// rounds failing again go back to the list. Uploads being idempotent, a list
+// left aside by a crash is uploaded again.
+func (o *RoundObjects) uploadPending() {
+	if o.options.PendingPath == "" {
+		return
+	}
+	uploading := o.options.PendingPath + ".uploading"
+	o.pending.Lock()
+	if _, err := os.Stat(uploading); errors.Is(err, os.ErrNotExist) {
+		err = os.Rename(o.options.PendingPath, uploading)
+		if err != nil {
+			o.pending.Unlock()
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Error().Err(err).Str("path", o.options.PendingPath).Msg("Failed to read the pending round uploads")
+			}
+			return
+		}
+	}
+	o.pending.Unlock()
+
+	data, err := os.ReadFile(uploading)
+	if err != nil {
+		log.Error().Err(err).Str("path", uploading).Msg("Failed to read the pending round uploads")
+		return
+	}
+	uploaded, failed := 0, 0
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var round store.Round
+		if err := json.Unmarshal(line, &round); err != nil {
+			log.Error().Err(err).Str("path", uploading).Msg("Dropping unreadable pending round upload")
+			continue
+		}
+		// Once an upload failed, the bucket is likely down and the other
+		// rounds are kept for the next attempt
+		if failed == 0 {
+			if err = o.put(round); err == nil {
+				uploaded++
+				continue
+			}
+		}
+		failed++
+		if err := o.keepPending(round); err != nil {
+			log.Error().Err(err).Uint64("round", round.Round).Msg("Failed to keep round pending, round missing from the bucket")
+		}
+	}
+	if err := os.Remove(uploading); err != nil {
+		log.Error().Err(err).Str("path", uploading).Msg("Failed to remove the uploaded pending rounds")
+	}
+	switch {
+	case failed > 0:
+		log.Warn().Err(err).Int("uploaded", uploaded).Int("pending", failed).Str("bucket", o.client.Bucket()).Msg("Failed to upload pending rounds, retrying later")
+	case uploaded > 0:
+		log.Info().Int("uploaded", uploaded).Str("bucket", o.client.Bucket()).Msg("Uploaded pending rounds")
+	}
+}
+
+// appendLine appends a line to a file, synced to disk
+func appendLine(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,47 @@
+package records
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encodeProtobuf encodes fields as a protobuf message, times as
+// google.protobuf.Timestamp messages
+func encodeProtobuf(fields []field) []byte {
+	var b []byte
+	for _, f := range fields {
+		if isZero(f.value) {
+			continue
+		}
+		switch v := f.value.(type) {
+		case uint64:
+			b = protowire.AppendTag(b, f.number, protowire.VarintType)
+			b = protowire.AppendVarint(b, v)
+		case int64:
+			b = protowire.AppendTag(b, f.number, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v))
+		case string:
+			b = protowire.AppendTag(b, f.number, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		case time.Time:
+			b = protowire.AppendTag(b, f.number, protowire.BytesType)
+			b = protowire.AppendBytes(b, encodeTimestamp(v))
+		}
+	}
+	return b
+}
+
+// encodeTimestamp encodes a google.protobuf.Timestamp message
+func encodeTimestamp(t time.Time) []byte {
+	var b []byte
+	if seconds := t.Unix(); seconds != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(seconds))
+	}
+	if nanos := t.Nanosecond(); nanos != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(nanos))
+	}
+	return b
+}
@@ -0,0 +1,147 @@
+// Package records serializes the audit and archive records of the state
+// store, the sender's transactions and the oracle's rounds, for downstream
+// data pipelines. The schemas of every format are published in the schemas
+// directory of the repository.
+package records
+
+import (
+	"drand-oracle-updater/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Format is a record serialization format
+type Format string
+
+const (
+	// FormatJSONL writes one JSON object per line, as in the state store
+	FormatJSONL Format = "jsonl"
+	// FormatProtobuf writes protobuf messages, each prefixed with its length
+	// as a varint
+	FormatProtobuf Format = "protobuf"
+	// FormatCBOR writes a CBOR sequence (RFC 8742) of maps
+	FormatCBOR Format = "cbor"
+)
+
+// ErrUnsupported is returned for records that are neither rounds nor transactions
+var ErrUnsupported = errors.New("unsupported record")
+
+// ParseFormat parses a record format name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatJSONL, FormatProtobuf, FormatCBOR:
+		return f, nil
+	case "":
+		return FormatJSONL, nil
+	default:
+		return "", fmt.Errorf("unknown record format %q", s)
+	}
+}
+
+// Extension returns the file extension of the format
+func (f Format) Extension() string {
+	switch f {
+	case FormatProtobuf:
+		return ".binpb"
+	case FormatCBOR:
+		return ".cbor"
+	default:
+		return ".jsonl"
+	}
+}
+
+// field is a record field, numbered as in the protobuf schema and named as in
+// the JSON and CBOR schemas. Values are uint64, int64, string or time.Time.
+type field struct {
+	number protowire.Number
+	name   string
+	value  any
+}
+
+// Encode serializes a store.Round or store.Transaction, framed so that
+// records can be appended to one another
+func Encode(format Format, record any) ([]byte, error) {
+	fields, err := recordFields(record)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatProtobuf:
+		message := encodeProtobuf(fields)
+		return protowire.AppendBytes(nil, message), nil
+	case FormatCBOR:
+		return encodeCBOR(fields), nil
+	default:
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+}
+
+func recordFields(record any) ([]field, error) {
+	switch r := record.(type) {
+	case store.Round:
+		return roundFields(r), nil
+	case store.Transaction:
+		return transactionFields(r), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupported, record)
+	}
+}
+
+// roundFields are the fields of the Round message of schemas/records.proto
+func roundFields(r store.Round) []field {
+	return []field{
+		{1, "timestamp", r.Timestamp},
+		{2, "round", r.Round},
+		{3, "randomness", r.Randomness},
+		{4, "signature", r.Signature},
+		{5, "block_number", r.BlockNumber},
+		{6, "tx_hash", r.TxHash},
+		{7, "log_index", uint64(r.LogIndex)},
+	}
+}
+
+// transactionFields are the fields of the Transaction message of schemas/records.proto
+func transactionFields(t store.Transaction) []field {
+	return []field{
+		{1, "timestamp", t.Timestamp},
+		{2, "chain_id", t.ChainID},
+		{3, "round", t.Round},
+		{4, "source", t.Source},
+		{5, "tx_hash", t.TxHash},
+		{6, "from", t.From},
+		{7, "nonce", t.Nonce},
+		{8, "block_number", t.BlockNumber},
+		{9, "gas_limit", t.GasLimit},
+		{10, "gas_used", t.GasUsed},
+		{11, "effective_gas_price_wei", t.EffectiveGasPrice},
+		{12, "fee_wei", t.Fee},
+		{13, "value_wei", t.Value},
+		{14, "status", t.Status},
+		{15, "user_op_hash", t.UserOpHash},
+		{16, "batch_size", uint64(t.BatchSize)},
+	}
+}
+
+// isZero reports whether a field holds its zero value, which protobuf and
+// CBOR records omit
+func isZero(value any) bool {
+	switch v := value.(type) {
+	case uint64:
+		return v == 0
+	case int64:
+		return v == 0
+	case string:
+		return v == ""
+	case time.Time:
+		return v.IsZero()
+	}
+	return false
+}
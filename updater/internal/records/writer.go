@@ -0,0 +1,59 @@
+package records
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Writer appends the rounds and transactions written to the state store to
+// per-collection files in a directory, in one format. It is a store.Sink.
+type Writer struct {
+	dir    string
+	format Format
+	mu     sync.Mutex
+}
+
+// NewWriter creates the directory if needed and returns a writer to it
+func NewWriter(dir string, format Format) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Writer{dir: dir, format: format}, nil
+}
+
+// Path returns the file a collection is written to
+func (w *Writer) Path(collection string) string {
+	return filepath.Join(w.dir, collection+w.format.Extension())
+}
+
+// Write appends a record of a collection, logging failures. Records other
+// than rounds and transactions are ignored.
+func (w *Writer) Write(collection string, record any) {
+	if err := w.Append(collection, record); err != nil && !errors.Is(err, ErrUnsupported) {
+		log.Error().Err(err).Str("collection", collection).Str("path", w.Path(collection)).Msg("Failed to archive record")
+	}
+}
+
+// Append appends a record of a collection
+func (w *Writer) Append(collection string, record any) error {
+	data, err := Encode(w.format, record)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f, err := os.OpenFile(w.Path(collection), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
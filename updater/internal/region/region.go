@@ -0,0 +1,261 @@
+// Package region partitions the ownership of rounds among updaters running
+// active in several regions, without a shared lock. Every round is owned by
+// one region, chosen by hashing the round, and each other region covers it
+// after a grace period, in a fixed order, when the owner did not set it.
+// Regions poll each other's status over a small authenticated API, so that
+// the owner and the regions ahead in the order are passed over while down.
+package region
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+// StatusPath is the path regions serve their status on
+const StatusPath = "/region/status"
+
+// gossipTimeout bounds a status request to a peer
+const gossipTimeout = 5 * time.Second
+
+// Status is the status a region serves to the others
+type Status struct {
+	Region string `json:"region"`
+	// Ready reports whether every pipeline of the region is ready to submit
+	Ready bool      `json:"ready"`
+	Time  time.Time `json:"time"`
+}
+
+// Peer is another region
+type Peer struct {
+	Name string
+	URL  string
+}
+
+// ParsePeers parses name=url entries
+func ParsePeers(entries []string) ([]Peer, error) {
+	peers := make([]Peer, 0, len(entries))
+	for _, entry := range entries {
+		name, url, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("invalid region peer %q, expected name=url", entry)
+		}
+		peers = append(peers, Peer{Name: name, URL: strings.TrimSuffix(url, "/")})
+	}
+	return peers, nil
+}
+
+// Options configures the ownership of this region
+type Options struct {
+	// Region is the name of this region
+	Region string
+	Peers  []Peer
+	// Token authenticates the status requests between regions
+	Token string
+	// Grace is how long each region waits for the one ahead of it in a
+	// round's order before covering the round
+	Grace time.Duration
+	// GossipInterval is the interval between status requests to each peer.
+	// A peer not answering ready for three intervals is considered down.
+	GossipInterval time.Duration
+}
+
+// peerState is the latest status seen from a peer
+type peerState struct {
+	lastReady time.Time
+}
+
+// Ownership tells which region owns a round and how long this region waits
+// before covering a round it does not own
+type Ownership struct {
+	options Options
+	// regions are the names of every region, sorted so that all regions
+	// agree on the owners
+	regions []string
+	status  func(ctx context.Context) Status
+	client  *http.Client
+	peerUp  *prometheus.GaugeVec
+
+	mu    sync.Mutex
+	peers map[string]*peerState
+}
+
+// New returns the ownership of the region. status reports the status of this
+// region to its peers. metricLabels are attached to its metric.
+func New(options Options, status func(ctx context.Context) Status, metricLabels map[string]string) (*Ownership, error) {
+	if options.Region == "" {
+		return nil, errors.New("region name is required")
+	}
+	if options.Token == "" {
+		return nil, errors.New("region token is required")
+	}
+	if options.Grace <= 0 || options.GossipInterval <= 0 {
+		return nil, errors.New("region grace and gossip interval must be positive")
+	}
+	regions := []string{options.Region}
+	peers := make(map[string]*peerState, len(options.Peers))
+	for _, peer := range options.Peers {
+		if slices.Contains(regions, peer.Name) {
+			return nil, fmt.Errorf("duplicate region %q", peer.Name)
+		}
+		regions = append(regions, peer.Name)
+		peers[peer.Name] = &peerState{}
+	}
+	slices.Sort(regions)
+
+	factory := promauto.With(prometheus.WrapRegistererWith(metricLabels, prometheus.DefaultRegisterer))
+	return &Ownership{
+		options: options,
+		regions: regions,
+		status:  status,
+		client:  &http.Client{Timeout: gossipTimeout},
+		peerUp: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "drand_region_peer_up",
+			Help: "Whether a peer region answered ready to the latest status requests (1) or not (0)",
+		}, []string{"peer"}),
+		peers: peers,
+	}, nil
+}
+
+// Region returns the name of this region
+func (o *Ownership) Region() string {
+	return o.options.Region
+}
+
+// Owner returns the region owning a round
+func (o *Ownership) Owner(round uint64) string {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], round)
+	hash := sha256.Sum256(buf[:])
+	return o.regions[binary.BigEndian.Uint64(hash[:8])%uint64(len(o.regions))]
+}
+
+// CoverDelay returns how long this region waits for the others to set a
+// round before submitting it, 0 when it owns the round. The regions following
+// the owner in sorted order cover it one grace period after another, skipping
+// the regions that are down.
+func (o *Ownership) CoverDelay(round uint64) time.Duration {
+	owner := slices.Index(o.regions, o.Owner(round))
+	ahead := 0
+	for i := range o.regions {
+		name := o.regions[(owner+i)%len(o.regions)]
+		if name == o.options.Region {
+			break
+		}
+		if o.up(name) {
+			ahead++
+		}
+	}
+	return time.Duration(ahead) * o.options.Grace
+}
+
+// up reports whether a peer answered ready recently
+func (o *Ownership) up(name string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	peer, ok := o.peers[name]
+	return ok && time.Since(peer.lastReady) < 3*o.options.GossipInterval
+}
+
+// Run polls the status of every peer until ctx is cancelled
+func (o *Ownership) Run(ctx context.Context) error {
+	ticker := time.NewTicker(o.options.GossipInterval)
+	defer ticker.Stop()
+	for {
+		for _, peer := range o.options.Peers {
+			o.poll(ctx, peer)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll requests the status of a peer and records whether it is ready
+func (o *Ownership) poll(ctx context.Context, peer Peer) {
+	status, err := o.fetch(ctx, peer)
+	ready := err == nil && status.Ready
+	switch {
+	case err != nil:
+		log.Debug().Err(err).Str("peer", peer.Name).Msg("Failed to get region status")
+	case status.Region != peer.Name:
+		log.Warn().Str("peer", peer.Name).Str("region", status.Region).Msg("Region peer answered with another region name")
+		ready = false
+	}
+
+	o.mu.Lock()
+	state := o.peers[peer.Name]
+	wasUp := time.Since(state.lastReady) < 3*o.options.GossipInterval
+	if ready {
+		state.lastReady = time.Now()
+	}
+	isUp := time.Since(state.lastReady) < 3*o.options.GossipInterval
+	o.mu.Unlock()
+
+	if isUp {
+		o.peerUp.WithLabelValues(peer.Name).Set(1)
+	} else {
+		o.peerUp.WithLabelValues(peer.Name).Set(0)
+	}
+	if wasUp && !isUp {
+		log.Warn().Str("peer", peer.Name).Msg("Region peer down, covering its rounds without waiting for it")
+	} else if !wasUp && isUp {
+		log.Info().Str("peer", peer.Name).Msg("Region peer up")
+	}
+}
+
+func (o *Ownership) fetch(ctx context.Context, peer Peer) (Status, error) {
+	var status Status
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer.URL+StatusPath, nil)
+	if err != nil {
+		return status, err
+	}
+	req.Header.Set("Authorization", "Bearer "+o.options.Token)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	return status, err
+}
+
+// Handler serves the status of this region to the peers presenting the token
+func (o *Ownership) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(o.options.Token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		status := o.status(r.Context())
+		status.Region = o.options.Region
+		status.Time = time.Now().UTC()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			log.Error().Err(err).Msg("error writing region status")
+		}
+	})
+}
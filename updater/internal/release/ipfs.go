@@ -0,0 +1,83 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pinTimeout bounds a pin request
+const pinTimeout = 2 * time.Minute
+
+// Pinner adds a file to IPFS and pins it, returning its CID
+type Pinner interface {
+	Pin(ctx context.Context, name string, data []byte) (string, error)
+}
+
+// IPFS pins files through the RPC API of an IPFS node, such as Kubo, or of a
+// pinning service exposing it
+type IPFS struct {
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+// NewIPFS returns a pinner using the RPC API at apiURL, e.g.
+// http://localhost:5001. token, if not empty, is sent as a bearer token.
+func NewIPFS(apiURL, token string) *IPFS {
+	return &IPFS{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		token:  token,
+		client: &http.Client{Timeout: pinTimeout},
+	}
+}
+
+// Pin adds and pins a file with CIDv1, and returns its CID
+func (p *IPFS) Pin(ctx context.Context, name string, data []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL+"/api/v0/add?pin=true&cid-version=1", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("IPFS add returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var added struct {
+		Hash string `json:"Hash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+		return "", fmt.Errorf("error decoding IPFS add response: %w", err)
+	}
+	if added.Hash == "" {
+		return "", fmt.Errorf("IPFS add returned no CID")
+	}
+	return added.Hash, nil
+}
@@ -0,0 +1,105 @@
+// Package release bundles a day of verified drand beacons set on an oracle,
+// with the transactions that set them, into a signed file pinned to IPFS. The
+// bundles form an archive of the oracle's history that consumers can fetch by
+// CID from any IPFS gateway and authenticate without trusting the gateway.
+//
+// A release file is the JSON of a Release. Its signature is a 65 byte
+// secp256k1 signature of the keccak256 hash of the JSON of its bundle, as
+// serialized in the file, verified by recovering the publisher address.
+package release
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Version is the version of the release format
+const Version = 1
+
+// DateLayout is the layout of the day of a bundle
+const DateLayout = "2006-01-02"
+
+// ErrInvalidSignature is returned when a release is not signed by its publisher
+var ErrInvalidSignature = errors.New("invalid release signature")
+
+// Beacon is a drand beacon set on the oracle, and where it was set
+type Beacon struct {
+	Round      uint64 `json:"round"`
+	Randomness string `json:"randomness"`
+	Signature  string `json:"signature"`
+	// BlockTime is the timestamp of the block the round was set in
+	BlockTime   time.Time `json:"block_time"`
+	BlockNumber uint64    `json:"block_number"`
+	TxHash      string    `json:"tx_hash"`
+	LogIndex    uint      `json:"log_index"`
+}
+
+// Bundle is a day of beacons set on an oracle, in round order
+type Bundle struct {
+	Version   int    `json:"version"`
+	Date      string `json:"date"`
+	ChainHash string `json:"chain_hash"`
+	ChainID   int64  `json:"chain_id"`
+	Oracle    string `json:"oracle"`
+	// Scheme and PublicKey verify the beacons against the drand network
+	Scheme    string   `json:"scheme"`
+	PublicKey string   `json:"public_key"`
+	Beacons   []Beacon `json:"beacons"`
+}
+
+// Release is a signed bundle
+type Release struct {
+	Bundle json.RawMessage `json:"bundle"`
+	// Publisher is the address of the key that signed the bundle
+	Publisher string `json:"publisher"`
+	Signature string `json:"signature"`
+}
+
+// Sign serializes and signs a bundle
+func Sign(bundle Bundle, key *ecdsa.PrivateKey) (Release, error) {
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return Release{}, err
+	}
+	signature, err := crypto.Sign(crypto.Keccak256(data), key)
+	if err != nil {
+		return Release{}, fmt.Errorf("error signing bundle: %w", err)
+	}
+	return Release{
+		Bundle:    data,
+		Publisher: crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		Signature: "0x" + hex.EncodeToString(signature),
+	}, nil
+}
+
+// Verify checks that a release is signed by its publisher and returns its
+// bundle
+func (r Release) Verify() (Bundle, error) {
+	signature, err := hex.DecodeString(trimHexPrefix(r.Signature))
+	if err != nil || len(signature) != crypto.SignatureLength {
+		return Bundle{}, ErrInvalidSignature
+	}
+	pub, err := crypto.SigToPub(crypto.Keccak256(r.Bundle), signature)
+	if err != nil || crypto.PubkeyToAddress(*pub) != common.HexToAddress(r.Publisher) {
+		return Bundle{}, ErrInvalidSignature
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(r.Bundle, &bundle); err != nil {
+		return Bundle{}, fmt.Errorf("error decoding bundle: %w", err)
+	}
+	return bundle, nil
+}
+
+func trimHexPrefix(s string) string {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:]
+	}
+	return s
+}
@@ -0,0 +1,271 @@
+// Package retry retries transient failures with jittered exponential backoff,
+// and trips a circuit breaker to stop calling a dependency that keeps failing.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrCircuitOpen is returned without calling the operation while its circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Policy configures the retries of an operation and its circuit breaker
+type Policy struct {
+	// MaxAttempts is the number of calls before giving up, including the first
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubled on every
+	// retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of the backoff randomly added or removed, so that
+	// callers failing together do not retry together
+	Jitter float64
+	// BreakerThreshold is the number of consecutive failures opening the
+	// circuit breaker, 0 disables it
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open before letting a
+	// single call probe whether the dependency recovered
+	BreakerCooldown time.Duration
+}
+
+// DefaultPolicy is used for the fields of a policy left to zero
+var DefaultPolicy = Policy{
+	MaxAttempts:      5,
+	InitialBackoff:   500 * time.Millisecond,
+	MaxBackoff:       30 * time.Second,
+	Jitter:           0.2,
+	BreakerThreshold: 10,
+	BreakerCooldown:  time.Minute,
+}
+
+// Backoff returns the delay after the given failed attempt, starting at 1
+func (p Policy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	backoff = min(backoff, float64(p.MaxBackoff))
+	backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(backoff)
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultPolicy.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultPolicy.InitialBackoff
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = max(p.InitialBackoff, DefaultPolicy.MaxBackoff)
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+	if p.BreakerCooldown <= 0 {
+		p.BreakerCooldown = DefaultPolicy.BreakerCooldown
+	}
+	return p
+}
+
+// Observer is notified of the retries and circuit breaker changes, typically
+// to update metrics
+type Observer interface {
+	// IncRetry is called before every retry of an operation
+	IncRetry(operation string)
+	// IncRetryExhausted is called when an operation failed on every attempt
+	IncRetryExhausted(operation string)
+	// SetRetryCircuitOpen is called when the circuit breaker of an operation opens or closes
+	SetRetryCircuitOpen(operation string, open bool)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as not worth retrying. It does not count as a
+// failure for the circuit breaker, as the dependency did answer.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retrier retries an operation, such as drand fetches or RPC reads, and
+// breaks its circuit after repeated failures
+type Retrier struct {
+	operation string
+	policy    Policy
+	observer  Observer
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// New returns a retrier of an operation, observer may be nil
+func New(operation string, policy Policy, observer Observer) *Retrier {
+	return &Retrier{
+		operation: operation,
+		policy:    policy.withDefaults(),
+		observer:  observer,
+	}
+}
+
+// SetPolicy replaces the policy, applied from the next call on. An open
+// circuit breaker stays open, for the new cooldown.
+func (r *Retrier) SetPolicy(policy Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy = policy.withDefaults()
+}
+
+// Policy returns the policy in effect
+func (r *Retrier) Policy() Policy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.policy
+}
+
+// Do calls fn until it succeeds, returns a permanent error, the attempts are
+// exhausted, ctx is done or the circuit breaker opens. The call keeps the
+// policy it started with.
+func (r *Retrier) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	policy := r.Policy()
+	for attempt := 1; ; attempt++ {
+		if !r.allow(time.Now()) {
+			return fmt.Errorf("%s: %w", r.operation, ErrCircuitOpen)
+		}
+		err := fn(ctx)
+		var permanent *permanentError
+		switch {
+		case err == nil:
+			r.succeeded()
+			return nil
+		case errors.As(err, &permanent):
+			r.succeeded()
+			return permanent.err
+		case ctx.Err() != nil:
+			// Cancelled rather than failed
+			r.release()
+			return err
+		}
+
+		r.failed(time.Now())
+		if attempt >= policy.MaxAttempts {
+			if r.observer != nil {
+				r.observer.IncRetryExhausted(r.operation)
+			}
+			return err
+		}
+		backoff := policy.Backoff(attempt)
+		log.Debug().
+			Err(err).
+			Str("operation", r.operation).
+			Int("attempt", attempt).
+			Dur("backoff", backoff).
+			Msg("Retrying after backoff")
+		if r.observer != nil {
+			r.observer.IncRetry(r.operation)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Value is Do for operations returning a value
+func Value[T any](ctx context.Context, r *Retrier, fn func(ctx context.Context) (T, error)) (T, error) {
+	var value T
+	err := r.Do(ctx, func(ctx context.Context) error {
+		var err error
+		value, err = fn(ctx)
+		return err
+	})
+	return value, err
+}
+
+// Open reports whether the circuit breaker is open
+func (r *Retrier) Open() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.openedAt.IsZero()
+}
+
+// allow reports whether the operation can be called, letting a single probe
+// through once the cooldown of an open breaker has elapsed
+func (r *Retrier) allow(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.openedAt.IsZero() {
+		return true
+	}
+	if r.probing || now.Sub(r.openedAt) < r.policy.BreakerCooldown {
+		return false
+	}
+	r.probing = true
+	return true
+}
+
+func (r *Retrier) succeeded() {
+	r.mu.Lock()
+	wasOpen := !r.openedAt.IsZero()
+	r.failures = 0
+	r.openedAt = time.Time{}
+	r.probing = false
+	r.mu.Unlock()
+
+	if wasOpen {
+		log.Info().Str("operation", r.operation).Msg("Circuit breaker closed")
+		if r.observer != nil {
+			r.observer.SetRetryCircuitOpen(r.operation, false)
+		}
+	}
+}
+
+func (r *Retrier) failed(now time.Time) {
+	r.mu.Lock()
+	r.failures++
+	if r.probing {
+		// The probe failed, stay open for another cooldown
+		r.openedAt = now
+		r.probing = false
+		r.mu.Unlock()
+		return
+	}
+	opened := r.policy.BreakerThreshold > 0 && r.openedAt.IsZero() && r.failures >= r.policy.BreakerThreshold
+	if opened {
+		r.openedAt = now
+	}
+	failures, cooldown := r.failures, r.policy.BreakerCooldown
+	r.mu.Unlock()
+
+	if opened {
+		log.Error().
+			Str("operation", r.operation).
+			Int("failures", failures).
+			Dur("cooldown", cooldown).
+			Msg("Circuit breaker opened after consecutive failures")
+		if r.observer != nil {
+			r.observer.SetRetryCircuitOpen(r.operation, true)
+		}
+	}
+}
+
+// release lets another probe through when a probe was cancelled
+func (r *Retrier) release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probing = false
+}
@@ -0,0 +1,155 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient failure")
+
+// failing returns an operation failing every call, counting the calls
+func failing(calls *int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		*calls++
+		return errTransient
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	r := New("test", Policy{MaxAttempts: 2, InitialBackoff: time.Millisecond}, nil)
+	calls := 0
+	if err := r.Do(context.Background(), failing(&calls)); !errors.Is(err, errTransient) || calls != 2 {
+		t.Fatalf("Do = %v after %d calls, want the failure after 2", err, calls)
+	}
+
+	r.SetPolicy(Policy{MaxAttempts: 4, InitialBackoff: time.Millisecond})
+	calls = 0
+	if err := r.Do(context.Background(), failing(&calls)); !errors.Is(err, errTransient) || calls != 4 {
+		t.Errorf("Do = %v after %d calls, want the failure after 4", err, calls)
+	}
+	// The fields left to zero take the defaults, as with New
+	if policy := r.Policy(); policy.MaxBackoff != DefaultPolicy.MaxBackoff || policy.BreakerCooldown != DefaultPolicy.BreakerCooldown {
+		t.Errorf("policy = %+v, want the default backoff and cooldown", policy)
+	}
+}
+
+// breakerObserver records the circuit breaker changes
+type breakerObserver struct {
+	open []bool
+}
+
+func (o *breakerObserver) IncRetry(string)          {}
+func (o *breakerObserver) IncRetryExhausted(string) {}
+func (o *breakerObserver) SetRetryCircuitOpen(_ string, open bool) {
+	o.open = append(o.open, open)
+}
+
+func TestBreakerOpens(t *testing.T) {
+	observer := &breakerObserver{}
+	r := New("test", Policy{MaxAttempts: 2, InitialBackoff: time.Millisecond, BreakerThreshold: 3, BreakerCooldown: time.Hour}, observer)
+	calls := 0
+	// The failures count across calls
+	if err := r.Do(context.Background(), failing(&calls)); !errors.Is(err, errTransient) || r.Open() {
+		t.Fatalf("Do = %v with the breaker open %v, want the failure with the breaker closed", err, r.Open())
+	}
+	if err := r.Do(context.Background(), failing(&calls)); !errors.Is(err, ErrCircuitOpen) || calls != 3 {
+		t.Fatalf("Do = %v after %d calls, want the breaker open after 3", err, calls)
+	}
+	if !r.Open() || !slices.Equal(observer.open, []bool{true}) {
+		t.Errorf("breaker open %v, observed %v, want open", r.Open(), observer.open)
+	}
+	// While open, the operation is not called
+	if err := r.Do(context.Background(), failing(&calls)); !errors.Is(err, ErrCircuitOpen) || calls != 3 {
+		t.Errorf("Do = %v after %d calls, want the breaker open without calling", err, calls)
+	}
+}
+
+func TestBreakerPermanentErrors(t *testing.T) {
+	r := New("test", Policy{MaxAttempts: 1, BreakerThreshold: 2}, nil)
+	permanent := func(ctx context.Context) error { return Permanent(errTransient) }
+	for i := 0; i < 3; i++ {
+		if err := r.Do(context.Background(), permanent); !errors.Is(err, errTransient) {
+			t.Fatalf("Do = %v, want the permanent error", err)
+		}
+	}
+	// The dependency answered, it is not failing
+	if r.Open() {
+		t.Error("breaker opened by permanent errors")
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	const cooldown = time.Minute
+	start := time.Now()
+	tests := []struct {
+		name        string
+		probeFailed bool
+	}{
+		{"probe succeeds", false},
+		{"probe fails", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			observer := &breakerObserver{}
+			r := New("test", Policy{BreakerThreshold: 2, BreakerCooldown: cooldown}, observer)
+			r.failed(start)
+			r.failed(start)
+			if r.allow(start.Add(cooldown / 2)) {
+				t.Fatal("call allowed before the cooldown")
+			}
+
+			// Half-open: a single probe is let through after the cooldown
+			probe := start.Add(cooldown)
+			if !r.allow(probe) {
+				t.Fatal("probe not allowed after the cooldown")
+			}
+			if r.allow(probe) {
+				t.Fatal("second call allowed while probing")
+			}
+
+			if !tt.probeFailed {
+				r.succeeded()
+				if r.Open() || !r.allow(probe) || !slices.Equal(observer.open, []bool{true, false}) {
+					t.Errorf("breaker open %v, observed %v, want closed", r.Open(), observer.open)
+				}
+				return
+			}
+			// A failed probe opens the breaker for another cooldown
+			r.failed(probe)
+			if !r.Open() || r.allow(probe.Add(cooldown/2)) {
+				t.Error("call allowed after a failed probe")
+			}
+			if !r.allow(probe.Add(cooldown)) {
+				t.Error("probe not allowed after another cooldown")
+			}
+			if !slices.Equal(observer.open, []bool{true}) {
+				t.Errorf("observed %v, want the breaker opened once", observer.open)
+			}
+		})
+	}
+}
+
+func TestBreakerCancelledProbe(t *testing.T) {
+	r := New("test", Policy{BreakerThreshold: 1, BreakerCooldown: time.Millisecond}, nil)
+	r.failed(time.Now())
+	time.Sleep(2 * time.Millisecond)
+
+	// A probe cancelled before the dependency answered lets another one through
+	ctx, cancel := context.WithCancel(context.Background())
+	err := r.Do(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Do = %v, want the cancellation", err)
+	}
+	if err := r.Do(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("Do = %v after a cancelled probe, want the probe to close the breaker", err)
+	}
+	if r.Open() {
+		t.Error("breaker open after a successful probe")
+	}
+}
@@ -0,0 +1,68 @@
+package rpcpool
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const labelMethod = "method"
+
+// Meter is an http.RoundTripper counting the JSON-RPC calls sent through it by
+// method, so that the RPC usage of the updater, and what hibernation saves, is
+// measured where RPC providers bill it
+type Meter struct {
+	transport http.RoundTripper
+	calls     *prometheus.CounterVec
+}
+
+// NewMeter returns a meter sending requests through transport. metricLabels
+// are attached to its metrics.
+func NewMeter(transport http.RoundTripper, metricLabels map[string]string) *Meter {
+	factory := promauto.With(prometheus.WrapRegistererWith(metricLabels, prometheus.DefaultRegisterer))
+	return &Meter{
+		transport: transport,
+		calls: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "drand_rpc_calls_total",
+			Help: "Total number of JSON-RPC calls sent by method, each call of a batch counted",
+		}, []string{labelMethod}),
+	}
+}
+
+// Pool returns the pool the meter sends requests through, nil for a single
+// endpoint
+func (m *Meter) Pool() *Pool {
+	pool, _ := m.transport.(*Pool)
+	return pool
+}
+
+// RoundTrip counts the calls of a request and sends it
+func (m *Meter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, method := range methods(body) {
+			m.calls.WithLabelValues(method).Inc()
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	return m.transport.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the transport, which
+// are opened again by the next call
+func (m *Meter) CloseIdleConnections() {
+	if m == nil {
+		return
+	}
+	if closer, ok := m.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
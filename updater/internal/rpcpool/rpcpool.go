@@ -0,0 +1,353 @@
+// Package rpcpool spreads the JSON-RPC calls of an ethclient over several
+// endpoints, failing over to the next healthy endpoint on errors and timeouts.
+package rpcpool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// baseCooldown is how long an endpoint is skipped after its first failure,
+	// doubled on every consecutive failure up to maxCooldown
+	baseCooldown = 5 * time.Second
+	maxCooldown  = 5 * time.Minute
+
+	// scoreWeight is the weight of the latest call in the success score
+	scoreWeight = 0.1
+
+	labelEndpoint = "endpoint"
+)
+
+// stickyMethods are sent to the first healthy endpoint even with round-robin,
+// as their results depend on the node's mempool
+var stickyMethods = map[string]bool{
+	"eth_sendRawTransaction":  true,
+	"eth_getTransactionCount": true,
+}
+
+// Options configures a pool
+type Options struct {
+	// Timeout bounds a single attempt on an endpoint, 0 leaves attempts bounded
+	// by the caller's context only
+	Timeout time.Duration
+	// RoundRobin spreads read calls over the healthy endpoints instead of
+	// sending every call to the first healthy one
+	RoundRobin bool
+	// MetricLabels are attached to every metric of the pool
+	MetricLabels map[string]string
+}
+
+// endpoint is an RPC URL and its health
+type endpoint struct {
+	url *url.URL
+	// name identifies the endpoint in logs and metrics without its path and
+	// query, which often carry API keys
+	name string
+
+	mu        sync.Mutex
+	score     float64
+	failures  int
+	downUntil time.Time
+	lastError string
+}
+
+func (e *endpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.downUntil)
+}
+
+// EndpointStatus is the health of an endpoint
+type EndpointStatus struct {
+	Endpoint string  `json:"endpoint"`
+	Healthy  bool    `json:"healthy"`
+	Score    float64 `json:"score"`
+	// Failures is the number of consecutive failures
+	Failures int `json:"failures"`
+	// DownUntil is the end of the cooldown of an unhealthy endpoint
+	DownUntil *time.Time `json:"down_until,omitempty"`
+	// LastError is the error of the latest failed call, kept after the
+	// endpoint recovered
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Pool is an http.RoundTripper sending each JSON-RPC request to one of its
+// endpoints, retrying on the next one when an endpoint fails
+type Pool struct {
+	endpoints []*endpoint
+	options   Options
+	transport http.RoundTripper
+	next      atomic.Uint64
+
+	up            *prometheus.GaugeVec
+	score         *prometheus.GaugeVec
+	failuresTotal *prometheus.CounterVec
+}
+
+// New returns a pool over the given endpoints, in order of preference
+func New(urls []string, options Options) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no rpc endpoint")
+	}
+	if _, ok := options.MetricLabels[labelEndpoint]; ok {
+		return nil, fmt.Errorf("deployment label %q is reserved for metric labels", labelEndpoint)
+	}
+
+	p := &Pool{
+		options:   options,
+		transport: http.DefaultTransport,
+	}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid rpc endpoint %q, only http(s) endpoints can be pooled", raw)
+		}
+		p.endpoints = append(p.endpoints, &endpoint{
+			url:   u,
+			name:  u.Scheme + "://" + u.Host,
+			score: 1,
+		})
+	}
+
+	factory := promauto.With(prometheus.WrapRegistererWith(options.MetricLabels, prometheus.DefaultRegisterer))
+	p.up = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_rpc_endpoint_up",
+		Help: "Whether the last call to an RPC endpoint succeeded",
+	}, []string{labelEndpoint})
+	p.score = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_rpc_endpoint_score",
+		Help: "Moving average of the success rate of an RPC endpoint",
+	}, []string{labelEndpoint})
+	p.failuresTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_rpc_endpoint_failures_total",
+		Help: "Total number of failed calls to an RPC endpoint",
+	}, []string{labelEndpoint})
+	for _, e := range p.endpoints {
+		p.up.WithLabelValues(e.name).Set(1)
+		p.score.WithLabelValues(e.name).Set(1)
+	}
+	return p, nil
+}
+
+// Dial returns an ethclient sending its calls through a pool over the given
+// endpoints, and the meter counting its calls. A single HTTP endpoint is
+// dialed directly through the meter. A single WebSocket or IPC endpoint is
+// dialed as is, without a meter.
+func Dial(urls []string, options Options) (*ethclient.Client, *Meter, error) {
+	if len(urls) == 1 {
+		u, err := url.Parse(urls[0])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			client, err := ethclient.Dial(urls[0])
+			return client, nil, err
+		}
+		meter := NewMeter(http.DefaultTransport, options.MetricLabels)
+		rpcClient, err := rpc.DialOptions(context.Background(), urls[0], rpc.WithHTTPClient(&http.Client{Transport: meter}))
+		if err != nil {
+			return nil, nil, err
+		}
+		return ethclient.NewClient(rpcClient), meter, nil
+	}
+	pool, err := New(urls, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	meter := NewMeter(pool, options.MetricLabels)
+	// The URL is only a placeholder, every request is rewritten to an endpoint
+	rpcClient, err := rpc.DialOptions(context.Background(), pool.endpoints[0].url.String(), rpc.WithHTTPClient(&http.Client{Transport: meter}))
+	if err != nil {
+		return nil, nil, err
+	}
+	return ethclient.NewClient(rpcClient), meter, nil
+}
+
+// CloseIdleConnections closes the idle connections to every endpoint
+func (p *Pool) CloseIdleConnections() {
+	if closer, ok := p.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Status returns the health of every endpoint, in order of preference
+func (p *Pool) Status() []EndpointStatus {
+	now := time.Now()
+	statuses := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		e.mu.Lock()
+		statuses[i] = EndpointStatus{
+			Endpoint:  e.name,
+			Healthy:   !now.Before(e.downUntil),
+			Score:     e.score,
+			Failures:  e.failures,
+			LastError: e.lastError,
+		}
+		if !statuses[i].Healthy {
+			downUntil := e.downUntil
+			statuses[i].DownUntil = &downUntil
+		}
+		e.mu.Unlock()
+	}
+	return statuses
+}
+
+// RoundTrip sends a JSON-RPC request to the endpoints in order until one
+// answers. The response body is read before returning, so that the attempt
+// timeout also covers it.
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, e := range p.order(body) {
+		resp, err := p.attempt(req, body, e)
+		if err == nil {
+			p.succeeded(e)
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, req.Context().Err()
+		}
+		p.failed(e, err)
+		errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+	}
+	return nil, fmt.Errorf("all rpc endpoints failed: %w", errors.Join(errs...))
+}
+
+// attempt sends a request to one endpoint. Server errors and rate limiting
+// count as failures, other responses, including JSON-RPC errors, are returned.
+func (p *Pool) attempt(req *http.Request, body []byte, e *endpoint) (*http.Response, error) {
+	ctx := req.Context()
+	if p.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.options.Timeout)
+		defer cancel()
+	}
+
+	out := req.Clone(ctx)
+	out.URL = e.url
+	out.Host = ""
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("http status %s", resp.Status)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// order returns the endpoints to try for a request: the healthy ones first,
+// rotated for read calls with round-robin, then the cooling down ones as a
+// last resort
+func (p *Pool) order(body []byte) []*endpoint {
+	now := time.Now()
+	var healthy, down []*endpoint
+	for _, e := range p.endpoints {
+		if e.healthy(now) {
+			healthy = append(healthy, e)
+		} else {
+			down = append(down, e)
+		}
+	}
+	if p.options.RoundRobin && len(healthy) > 1 && !sticky(body) {
+		offset := int(p.next.Add(1) % uint64(len(healthy)))
+		healthy = slices.Concat(healthy[offset:], healthy[:offset])
+	}
+	return append(healthy, down...)
+}
+
+// sticky reports whether a request, or any request of a batch, calls a sticky method
+func sticky(body []byte) bool {
+	for _, method := range methods(body) {
+		if stickyMethods[method] {
+			return true
+		}
+	}
+	return false
+}
+
+// methods returns the methods called by a request, or by every request of a
+// batch, nil when the body is not JSON-RPC
+func methods(body []byte) []string {
+	type call struct {
+		Method string `json:"method"`
+	}
+	var calls []call
+	if err := json.Unmarshal(body, &calls); err != nil {
+		var single call
+		if err := json.Unmarshal(body, &single); err != nil {
+			return nil
+		}
+		calls = []call{single}
+	}
+	names := make([]string, len(calls))
+	for i, c := range calls {
+		names[i] = c.Method
+	}
+	return names
+}
+
+func (p *Pool) succeeded(e *endpoint) {
+	e.mu.Lock()
+	recovered := e.failures > 0
+	e.failures = 0
+	e.score += scoreWeight * (1 - e.score)
+	score := e.score
+	e.mu.Unlock()
+
+	if recovered {
+		log.Info().Str("endpoint", e.name).Msg("RPC endpoint recovered")
+	}
+	p.up.WithLabelValues(e.name).Set(1)
+	p.score.WithLabelValues(e.name).Set(score)
+}
+
+func (p *Pool) failed(e *endpoint, err error) {
+	e.mu.Lock()
+	e.failures++
+	e.score -= scoreWeight * e.score
+	cooldown := min(baseCooldown<<min(e.failures-1, 16), maxCooldown)
+	e.downUntil = time.Now().Add(cooldown)
+	// Errors quote the URL, whose path and query often carry API keys
+	e.lastError = strings.ReplaceAll(err.Error(), e.url.String(), e.name)
+	score := e.score
+	e.mu.Unlock()
+
+	log.Warn().Err(err).Str("endpoint", e.name).Dur("cooldown", cooldown).Msg("RPC endpoint failed, failing over")
+	p.failuresTotal.WithLabelValues(e.name).Inc()
+	p.up.WithLabelValues(e.name).Set(0)
+	p.score.WithLabelValues(e.name).Set(score)
+}
@@ -0,0 +1,193 @@
+// Package safe proposes transactions to a Safe multisig through the Safe
+// Transaction Service, for its owners to confirm and execute.
+package safe
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"drand-oracle-updater/signer"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const requestTimeout = 30 * time.Second
+
+// nonceSelector is the selector of the Safe's nonce() function
+var nonceSelector = crypto.Keccak256([]byte("nonce()"))[:4]
+
+// Proposer proposes Safe transactions signed by an owner or delegate of the Safe
+type Proposer struct {
+	serviceURL string
+	safe       common.Address
+	chainID    int64
+	key        *ecdsa.PrivateKey
+	rpcClient  *ethclient.Client
+	httpClient *http.Client
+}
+
+// NewProposer returns a proposer to the Safe through the transaction service
+// at serviceURL, e.g. https://safe-transaction-mainnet.safe.global
+func NewProposer(serviceURL string, safe common.Address, chainID int64, key *ecdsa.PrivateKey, rpcClient *ethclient.Client) *Proposer {
+	return &Proposer{
+		serviceURL: strings.TrimRight(serviceURL, "/"),
+		safe:       safe,
+		chainID:    chainID,
+		key:        key,
+		rpcClient:  rpcClient,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Safe returns the address of the Safe
+func (p *Proposer) Safe() common.Address {
+	return p.safe
+}
+
+// Address returns the address of the proposer
+func (p *Proposer) Address() common.Address {
+	return crypto.PubkeyToAddress(p.key.PublicKey)
+}
+
+// proposal is the body of a multisig transaction proposal
+type proposal struct {
+	To                      string `json:"to"`
+	Value                   string `json:"value"`
+	Data                    string `json:"data"`
+	Operation               int    `json:"operation"`
+	SafeTxGas               string `json:"safeTxGas"`
+	BaseGas                 string `json:"baseGas"`
+	GasPrice                string `json:"gasPrice"`
+	GasToken                string `json:"gasToken"`
+	RefundReceiver          string `json:"refundReceiver"`
+	Nonce                   string `json:"nonce"`
+	ContractTransactionHash string `json:"contractTransactionHash"`
+	Sender                  string `json:"sender"`
+	Signature               string `json:"signature"`
+	Origin                  string `json:"origin,omitempty"`
+}
+
+// Propose proposes a call from the Safe with the Safe's next nonce, and
+// returns the Safe transaction hash. origin describes the proposal to the
+// Safe owners.
+func (p *Proposer) Propose(ctx context.Context, to common.Address, data []byte, origin string) (common.Hash, error) {
+	nonce, err := p.nonce(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash, err := signer.TypedDataHash(p.typedData(to, data, nonce))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	signature, err := crypto.Sign(hash, p.key)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// The Safe expects V as 27/28 for ECDSA signatures
+	signature[crypto.RecoveryIDOffset] += 27
+
+	body, err := json.Marshal(proposal{
+		To:                      to.Hex(),
+		Value:                   "0",
+		Data:                    hexutil.Encode(data),
+		Operation:               0,
+		SafeTxGas:               "0",
+		BaseGas:                 "0",
+		GasPrice:                "0",
+		GasToken:                common.Address{}.Hex(),
+		RefundReceiver:          common.Address{}.Hex(),
+		Nonce:                   nonce.String(),
+		ContractTransactionHash: hexutil.Encode(hash),
+		Sender:                  p.Address().Hex(),
+		Signature:               hexutil.Encode(signature),
+		Origin:                  origin,
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	url := fmt.Sprintf("%s/api/v1/safes/%s/multisig-transactions/", p.serviceURL, p.safe.Hex())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return common.Hash{}, fmt.Errorf("safe transaction service returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return common.BytesToHash(hash), nil
+}
+
+// nonce reads the Safe's current nonce, the nonce of its next transaction
+func (p *Proposer) nonce(ctx context.Context) (*big.Int, error) {
+	result, err := p.rpcClient.CallContract(ctx, ethereum.CallMsg{To: &p.safe, Data: nonceSelector}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading safe nonce: %w", err)
+	}
+	if len(result) != 32 {
+		return nil, fmt.Errorf("invalid safe nonce %x, is %s a Safe?", result, p.safe.Hex())
+	}
+	return new(big.Int).SetBytes(result), nil
+}
+
+// typedData returns the EIP-712 typed data of a Safe transaction calling to
+// without value nor gas refund
+func (p *Proposer) typedData(to common.Address, data []byte, nonce *big.Int) *apitypes.TypedData {
+	zero := math.NewHexOrDecimal256(0)
+	return &apitypes.TypedData{
+		Types: apitypes.Types{
+			"SafeTx": []apitypes.Type{
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "data", Type: "bytes"},
+				{Name: "operation", Type: "uint8"},
+				{Name: "safeTxGas", Type: "uint256"},
+				{Name: "baseGas", Type: "uint256"},
+				{Name: "gasPrice", Type: "uint256"},
+				{Name: "gasToken", Type: "address"},
+				{Name: "refundReceiver", Type: "address"},
+				{Name: "nonce", Type: "uint256"},
+			},
+			// EIP712Domain(uint256 chainId,address verifyingContract)
+			"EIP712Domain": []apitypes.Type{
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+		},
+		PrimaryType: "SafeTx",
+		Domain: apitypes.TypedDataDomain{
+			ChainId:           math.NewHexOrDecimal256(p.chainID),
+			VerifyingContract: p.safe.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"to":             to.Hex(),
+			"value":          zero,
+			"data":           data,
+			"operation":      zero,
+			"safeTxGas":      zero,
+			"baseGas":        zero,
+			"gasPrice":       zero,
+			"gasToken":       common.Address{}.Hex(),
+			"refundReceiver": common.Address{}.Hex(),
+			"nonce":          (*math.HexOrDecimal256)(nonce),
+		},
+	}
+}
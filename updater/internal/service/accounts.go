@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/accounts"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// accountSender is the name the sender is polled under
+const accountSender = "sender"
+
+// monitorAccounts checks the sender balance and nonces from the snapshots of
+// the account poller shared by the pipelines, in place of monitorBalance and
+// monitorNonces polling them for this pipeline alone
+func (u *Updater) monitorAccounts(ctx context.Context) error {
+	poller := u.options.Accounts
+	subscription := poller.Subscribe(u.accountPollInterval)
+	defer subscription.Close()
+
+	var previousGaps []uint64
+	for {
+		var snapshot accounts.Snapshot
+		select {
+		case <-ctx.Done():
+			return nil
+		case snapshot = <-subscription.C():
+		}
+
+		address := u.sender.Address()
+		account, ok := snapshot.Account(address)
+		if !ok {
+			// The sender rotated to a key that was not staged, which is
+			// polled from now on
+			poller.Watch(accountSender, address)
+			continue
+		}
+		if account.Err != nil {
+			log.Error().Err(account.Err).Msg("Failed to get updater balance and nonces")
+			continue
+		}
+		if account.Balance == nil {
+			// Watched after the poll started
+			continue
+		}
+		u.balanceChecked(ctx, account.Balance)
+		if !u.options.DryRun {
+			previousGaps = u.nonceStateChecked(ctx, u.nonces.Reconcile(account.PendingNonce, account.LatestNonce), previousGaps)
+		}
+	}
+}
+
+// accountPollInterval returns the delay between account polls this pipeline
+// needs, stretched while it hibernates as monitorBalance's
+func (u *Updater) accountPollInterval() time.Duration {
+	u.hibernation.mu.Lock()
+	hibernating := u.hibernation.hibernating
+	u.hibernation.mu.Unlock()
+	interval := u.options.Accounts.Interval()
+	if hibernating {
+		interval = max(interval, u.hibernationPollInterval())
+	}
+	return interval
+}
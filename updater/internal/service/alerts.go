@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/alerting"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// raisedAlerts tracks the conditions alerted on and not resolved since, so that
+// the timeline records when a condition is raised rather than every repeat
+type raisedAlerts struct {
+	mu         sync.Mutex
+	conditions map[string]bool
+}
+
+// sendAlert sends an alert and records it in the timeline when its condition
+// was not already raised
+func (u *Updater) sendAlert(alert alerting.Alert) {
+	u.raisedAlerts.mu.Lock()
+	raised := u.raisedAlerts.conditions[alert.Condition]
+	if u.raisedAlerts.conditions == nil {
+		u.raisedAlerts.conditions = make(map[string]bool)
+	}
+	u.raisedAlerts.conditions[alert.Condition] = true
+	u.raisedAlerts.mu.Unlock()
+	if !raised {
+		details := map[string]string{"condition": alert.Condition, "severity": string(alert.Severity)}
+		for k, v := range alert.Details {
+			details[k] = v
+		}
+		u.recordEvent(kindAlert, 0, alert.Summary, details)
+	}
+	u.options.Alerts.Send(alert)
+}
+
+// FlushAlerts waits for the alerts being sent, see alerting.Dispatcher.Flush
+func (u *Updater) FlushAlerts(ctx context.Context) {
+	u.options.Alerts.Flush(ctx)
+}
+
+// resolveAlert resolves a condition, recording it in the timeline when it was
+// raised
+func (u *Updater) resolveAlert(condition string) {
+	u.raisedAlerts.mu.Lock()
+	raised := u.raisedAlerts.conditions[condition]
+	delete(u.raisedAlerts.conditions, condition)
+	u.raisedAlerts.mu.Unlock()
+	if raised {
+		u.recordEvent(kindAlertResolved, 0, "Alert condition "+condition+" resolved", map[string]string{"condition": condition})
+	}
+	u.options.Alerts.Resolve(condition)
+}
+
+// alertRoundFailed alerts that a round could not be set on the oracle
+func (u *Updater) alertRoundFailed(round uint64, attempts int, err error) {
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionRoundFailed,
+		Severity:  alerting.SeverityCritical,
+		Summary:   fmt.Sprintf("Round %d failed to land after %d attempts", round, attempts),
+		Details: map[string]string{
+			"round":    fmt.Sprintf("%d", round),
+			"attempts": fmt.Sprintf("%d", attempts),
+			"error":    err.Error(),
+		},
+	})
+}
+
+// checkLowBalance alerts when the sender balance is below the minimum sender balance
+func (u *Updater) checkLowBalance(balance *big.Int) {
+	minSenderBalance := u.Settings().MinSenderBalance
+	if minSenderBalance == nil || balance.Cmp(minSenderBalance) >= 0 {
+		u.resolveAlert(alerting.ConditionLowBalance)
+		return
+	}
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionLowBalance,
+		Severity:  alerting.SeverityWarning,
+		Summary:   "Updater sender balance is below the minimum",
+		Details: map[string]string{
+			"sender_address":  u.sender.Address().Hex(),
+			"balance_wei":     balance.String(),
+			"min_balance_wei": minSenderBalance.String(),
+		},
+	})
+}
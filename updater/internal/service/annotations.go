@@ -0,0 +1,94 @@
+package service
+
+import (
+	"crypto/rand"
+	"drand-oracle-updater/internal/store"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrAnnotationNotFound is returned when deleting an unknown annotation
+var ErrAnnotationNotFound = errors.New("annotation not found")
+
+// AddAnnotation validates and records an annotation, assigning its ID and
+// creation time
+func (u *Updater) AddAnnotation(annotation store.Annotation) (store.Annotation, error) {
+	annotation.Note = strings.TrimSpace(annotation.Note)
+	if annotation.Note == "" {
+		return store.Annotation{}, errors.New("annotation note is empty")
+	}
+	if annotation.FromRound == 0 && annotation.From == nil {
+		return store.Annotation{}, errors.New("annotation needs from_round or from")
+	}
+	if annotation.ToRound != 0 && annotation.ToRound < annotation.FromRound {
+		return store.Annotation{}, errors.New("annotation to_round is before from_round")
+	}
+	if annotation.To != nil && (annotation.From == nil || !annotation.To.After(*annotation.From)) {
+		return store.Annotation{}, errors.New("annotation to is not after from")
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return store.Annotation{}, err
+	}
+	annotation.ID = hex.EncodeToString(id)
+	annotation.CreatedAt = time.Now().UTC()
+	annotation.Deleted = false
+	if err := u.store.AppendAnnotation(annotation); err != nil {
+		return store.Annotation{}, err
+	}
+	return annotation, nil
+}
+
+// DeleteAnnotation deletes an annotation
+func (u *Updater) DeleteAnnotation(id string) error {
+	annotations, err := u.store.Annotations()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(annotations, func(a store.Annotation) bool { return a.ID == id }) {
+		return ErrAnnotationNotFound
+	}
+	return u.store.DeleteAnnotation(id)
+}
+
+// Annotations returns the annotations whose period overlaps [from, to), a zero
+// bound leaving that side open, along with the round annotations when round
+// is zero or covered
+func (u *Updater) Annotations(from, to time.Time, round uint64) ([]store.Annotation, error) {
+	annotations, err := u.store.Annotations()
+	if err != nil {
+		return nil, err
+	}
+	if from.IsZero() && to.IsZero() && round == 0 {
+		return annotations, nil
+	}
+	var matching []store.Annotation
+	for _, a := range annotations {
+		if (round != 0 && a.CoversRound(round)) || ((!from.IsZero() || !to.IsZero()) && a.Overlaps(from, to)) {
+			matching = append(matching, a)
+		}
+	}
+	return matching, nil
+}
+
+// activeAnnotations returns the annotations covering the current time or the
+// latest oracle round, for the status
+func (u *Updater) activeAnnotations() []store.Annotation {
+	annotations, err := u.store.Annotations()
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+	latest := u.GetLatestOracleRound()
+	var active []store.Annotation
+	for _, a := range annotations {
+		if a.CoversTime(now) || a.CoversRound(latest) {
+			active = append(active, a)
+		}
+	}
+	return active
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/alerting"
+	"drand-oracle-updater/internal/store"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/crypto"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rs/zerolog/log"
+)
+
+// archiveCheckpoint is the name of the state store checkpoint tracking the
+// archive verification
+const archiveCheckpoint = "archive-verification"
+
+// archiveCheckpointEvery is the number of verified rounds between checkpoints
+const archiveCheckpointEvery = 100
+
+// archiveRetryInterval is the delay before a failed archive verification pass resumes
+const archiveRetryInterval = time.Minute
+
+// errArchiveDegraded interrupts an archive verification pass, releasing the
+// rounds index it holds in memory, while the process approaches its limits
+var errArchiveDegraded = errors.New("archive verification paused, resource limits approached")
+
+// Archive checks, failing when an indexed round cannot be relied on
+const (
+	// archiveCheckDecode fails when a record of the rounds index is unreadable
+	archiveCheckDecode = "decode"
+	// archiveCheckDuplicate fails when a round is indexed twice with different values
+	archiveCheckDuplicate = "duplicate"
+	// archiveCheckRandomness fails when an indexed round's randomness is not
+	// the hash of its signature
+	archiveCheckRandomness = "randomness"
+	// archiveCheckSignature fails when an indexed round's signature does not
+	// verify against the drand network's public key
+	archiveCheckSignature = "signature"
+	// archiveCheckOnChain fails when an indexed round differs from the oracle's
+	archiveCheckOnChain = "onchain"
+)
+
+// ArchiveVerification is the progress of the archive verification, persisted
+// as a checkpoint so that a restart resumes the pass in progress
+type ArchiveVerification struct {
+	// Pass counts the passes over the rounds index, from 1
+	Pass          int       `json:"pass"`
+	PassStartedAt time.Time `json:"pass_started_at"`
+	// NextRound is the lowest round not verified yet in the current pass
+	NextRound uint64 `json:"next_round"`
+	// Rounds is the number of indexed rounds of the current pass
+	Rounds    int `json:"rounds"`
+	Verified  int `json:"verified"`
+	Corrupted int `json:"corrupted"`
+	// Unavailable counts the rounds that could not be read from the oracle,
+	// which are only checked against the drand network
+	Unavailable    int       `json:"unavailable"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	LastPassEnded  time.Time `json:"last_pass_ended,omitempty"`
+	LastCorruption string    `json:"last_corruption,omitempty"`
+}
+
+// ArchiveVerification returns the progress of the archive verification, nil
+// when disabled
+func (u *Updater) ArchiveVerification() *ArchiveVerification {
+	if u.options.ArchiveVerifyRate <= 0 {
+		return nil
+	}
+	u.archiveMutex.RLock()
+	defer u.archiveMutex.RUnlock()
+	progress := u.archiveProgress
+	return &progress
+}
+
+// verifyArchive continuously re-verifies the rounds index against the drand
+// network and the oracle, at most ArchiveVerifyRate rounds per second, so that
+// bit-rot or a corrupted store is noticed before the rounds are relied on
+func (u *Updater) verifyArchive(ctx context.Context) error {
+	if u.options.ArchiveVerifyRate <= 0 {
+		return nil
+	}
+	limiter := time.NewTicker(time.Duration(float64(time.Second) / u.options.ArchiveVerifyRate))
+	defer limiter.Stop()
+
+	var progress ArchiveVerification
+	ok, err := u.store.LoadCheckpoint(archiveCheckpoint, &progress)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load the archive verification checkpoint, starting over")
+		progress = ArchiveVerification{}
+	}
+	if ok && progress.Pass > 0 {
+		log.Info().
+			Int("pass", progress.Pass).
+			Uint64("next_round", progress.NextRound).
+			Msg("Resuming archive verification")
+	}
+
+	for {
+		if progress.Pass == 0 || progress.LastPassEnded.After(progress.PassStartedAt) {
+			// After a finished pass, the next one starts after the interval
+			if wait := time.Until(progress.LastPassEnded.Add(u.options.ArchiveVerifyInterval)); progress.Pass > 0 && wait > 0 {
+				u.setArchiveProgress(progress)
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(wait):
+				}
+			}
+			progress = ArchiveVerification{
+				Pass:          progress.Pass + 1,
+				PassStartedAt: time.Now().UTC(),
+				LastPassEnded: progress.LastPassEnded,
+			}
+		}
+		if err := u.verifyArchivePass(ctx, limiter.C, &progress); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, errArchiveDegraded) {
+				log.Warn().Int("pass", progress.Pass).Uint64("next_round", progress.NextRound).Msg("Pausing archive verification until resource usage recovers")
+				if err := u.options.Limits.WaitRecovered(ctx); err != nil {
+					return nil
+				}
+				continue
+			}
+			log.Error().Err(err).Int("pass", progress.Pass).Msg("Archive verification failed, retrying")
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(archiveRetryInterval):
+			}
+		}
+	}
+}
+
+// verifyArchivePass verifies the indexed rounds from the checkpointed round on
+func (u *Updater) verifyArchivePass(ctx context.Context, limiter <-chan time.Time, progress *ArchiveVerification) error {
+	rounds, err := u.loadArchive(progress.NextRound == 0)
+	if err != nil {
+		return err
+	}
+	progress.Rounds = len(rounds)
+	u.setArchiveProgress(*progress)
+
+	start := sort.Search(len(rounds), func(i int) bool { return rounds[i].Round >= progress.NextRound })
+	for i := start; i < len(rounds); i++ {
+		select {
+		case <-ctx.Done():
+			return u.saveArchiveProgress(progress)
+		case <-limiter:
+		}
+		if u.options.Limits.Degraded() {
+			if err := u.saveArchiveProgress(progress); err != nil {
+				return err
+			}
+			return errArchiveDegraded
+		}
+
+		var previous *store.Round
+		if i > 0 && rounds[i-1].Round+1 == rounds[i].Round {
+			previous = &rounds[i-1]
+		}
+		check, detail, available := u.verifyArchivedRound(ctx, rounds[i], previous)
+		if check != "" {
+			progress.Corrupted++
+			progress.LastCorruption = fmt.Sprintf("round %d: %s", rounds[i].Round, check)
+			u.reportArchiveCorruption(check, rounds[i], detail)
+		} else {
+			u.metrics.IncArchiveVerified("valid")
+		}
+		if !available {
+			progress.Unavailable++
+		}
+		progress.Verified++
+		progress.NextRound = rounds[i].Round + 1
+
+		if progress.Verified%archiveCheckpointEvery == 0 {
+			if err := u.saveArchiveProgress(progress); err != nil {
+				return err
+			}
+		}
+	}
+
+	progress.NextRound = 0
+	progress.LastPassEnded = time.Now().UTC()
+	log.Info().
+		Int("pass", progress.Pass).
+		Int("rounds", progress.Rounds).
+		Int("corrupted", progress.Corrupted).
+		Int("unavailable", progress.Unavailable).
+		Dur("duration", progress.LastPassEnded.Sub(progress.PassStartedAt)).
+		Msg("Archive verification pass completed")
+	return u.saveArchiveProgress(progress)
+}
+
+// loadArchive reads the rounds index sorted by round. Unreadable records and
+// rounds indexed twice with different values are reported once per pass,
+// when it starts.
+func (u *Updater) loadArchive(report bool) ([]store.Round, error) {
+	byRound := make(map[uint64]store.Round)
+	err := u.store.ScanRounds(func(record int, round store.Round, err error) error {
+		if err != nil {
+			if report {
+				u.reportArchiveCorruption(archiveCheckDecode, store.Round{}, fmt.Sprintf("record %d: %s", record, err))
+			}
+			return nil
+		}
+		if indexed, ok := byRound[round.Round]; ok {
+			if report && (indexed.Randomness != round.Randomness || indexed.Signature != round.Signature) {
+				u.reportArchiveCorruption(archiveCheckDuplicate, round, fmt.Sprintf("record %d differs from an earlier record", record))
+			}
+			return nil
+		}
+		byRound[round.Round] = round
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rounds := make([]store.Round, 0, len(byRound))
+	for _, round := range byRound {
+		rounds = append(rounds, round)
+	}
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i].Round < rounds[j].Round })
+	return rounds, nil
+}
+
+// verifyArchivedRound checks an indexed round against the drand network's
+// public key and the oracle. It returns the failed check, empty when the
+// round is valid, and whether the oracle still serves the round.
+func (u *Updater) verifyArchivedRound(ctx context.Context, round store.Round, previous *store.Round) (string, string, bool) {
+	randomness, err := hex.DecodeString(round.Randomness)
+	if err != nil {
+		return archiveCheckDecode, err.Error(), true
+	}
+	signature, err := hex.DecodeString(round.Signature)
+	if err != nil {
+		return archiveCheckDecode, err.Error(), true
+	}
+	if !bytes.Equal(crypto.RandomnessFromSignature(signature), randomness) {
+		return archiveCheckRandomness, "randomness does not match the signature", true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyRoundTimeout)
+	defer cancel()
+	onChain, err := u.binding.GetRandomnessFromRound(&bind.CallOpts{Context: ctx}, round.Round)
+	if err != nil {
+		// The oracle is unreachable, the round is checked again next pass
+		log.Warn().Err(err).Uint64("round", round.Round).Msg("Failed to get oracle round, checking the archive against drand only")
+	}
+	available := err == nil && onChain.Round != 0
+
+	scheme, err := crypto.SchemeFromName(u.drandInfo.Scheme)
+	if err != nil {
+		return "", "", available
+	}
+	beacon := &chain.Beacon{Round: round.Round, Signature: signature}
+	if scheme.Name == crypto.DefaultSchemeID && round.Round > 1 {
+		// Chained schemes sign over the previous signature, taken from the
+		// archive, or the oracle when not archived
+		switch {
+		case previous != nil:
+			beacon.PreviousSig, _ = hex.DecodeString(previous.Signature)
+		default:
+			prev, err := u.binding.GetRandomnessFromRound(&bind.CallOpts{Context: ctx}, round.Round-1)
+			if err != nil || prev.Round == 0 {
+				beacon = nil
+			} else {
+				beacon.PreviousSig = prev.Signature
+			}
+		}
+	}
+	if beacon != nil {
+		if err := scheme.VerifyBeacon(beacon, u.drandInfo.PublicKey); err != nil {
+			return archiveCheckSignature, err.Error(), available
+		}
+	}
+
+	if available && (!bytes.Equal(onChain.Randomness[:], randomness) || !bytes.Equal(onChain.Signature, signature)) {
+		return archiveCheckOnChain, "oracle randomness " + hex.EncodeToString(onChain.Randomness[:]), available
+	}
+	return "", "", available
+}
+
+// reportArchiveCorruption logs, counts and alerts an indexed round failing a check
+func (u *Updater) reportArchiveCorruption(check string, round store.Round, detail string) {
+	log.Error().
+		Str("check", check).
+		Uint64("round", round.Round).
+		Str("tx_hash", round.TxHash).
+		Str("detail", detail).
+		Msg("Archived round failed verification")
+	u.metrics.IncArchiveVerified(check)
+
+	summary := fmt.Sprintf("Archived round %d failed the %s check", round.Round, check)
+	if round.Round == 0 {
+		summary = fmt.Sprintf("Rounds index failed the %s check", check)
+	}
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionArchiveCorruption,
+		Severity:  alerting.SeverityWarning,
+		Summary:   summary,
+		Details: map[string]string{
+			"check":  check,
+			"round":  fmt.Sprintf("%d", round.Round),
+			"detail": detail,
+			"store":  u.store.Dir(),
+		},
+	})
+}
+
+func (u *Updater) saveArchiveProgress(progress *ArchiveVerification) error {
+	progress.UpdatedAt = time.Now().UTC()
+	u.setArchiveProgress(*progress)
+	return u.store.SaveCheckpoint(archiveCheckpoint, progress)
+}
+
+func (u *Updater) setArchiveProgress(progress ArchiveVerification) {
+	u.archiveMutex.Lock()
+	u.archiveProgress = progress
+	u.archiveMutex.Unlock()
+	u.metrics.SetArchiveProgress(progress.Pass, progress.Verified, progress.Rounds)
+}
+
+// ErrArchiveDisabled is returned when the rounds are not uploaded to an
+// archive bucket
+var ErrArchiveDisabled = errors.New("round archive bucket is not configured")
+
+// ArchiveURL returns the URL of the object of a round in the archive bucket.
+// Rounds not set on the oracle yet are not found, rounds set before the
+// bucket was configured may be missing from it.
+func (u *Updater) ArchiveURL(round uint64) (string, error) {
+	if u.options.RoundObjects == nil {
+		return "", ErrArchiveDisabled
+	}
+	if round == 0 || round > u.GetLatestOracleRound() {
+		return "", ErrRoundNotFound
+	}
+	return u.options.RoundObjects.URL(round), nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/audit"
+	"encoding/hex"
+
+	"github.com/rs/zerolog/log"
+)
+
+// auditDecision records a decision on a round, or on the range of rounds
+// ending at lastRound, to the audit log. A failure to record is logged and
+// counted but never fails the round.
+func (u *Updater) auditDecision(decision string, round, lastRound uint64, drandSignature []byte, reason, txHash string) {
+	if u.options.Audit == nil {
+		return
+	}
+	entry := audit.Entry{
+		Pipeline: u.options.Pipeline,
+		ChainID:  u.chainID,
+		Oracle:   u.oracleAddress.Hex(),
+		Round:    round,
+		Decision: decision,
+		Reason:   reason,
+		TxHash:   txHash,
+		Signer:   u.signer.Current().Address().Hex(),
+		Sender:   u.sender.Address().Hex(),
+	}
+	if lastRound > round {
+		entry.LastRound = lastRound
+	}
+	if len(drandSignature) > 0 {
+		entry.DrandSignature = hex.EncodeToString(drandSignature)
+	}
+	if err := u.options.Audit.Record(context.Background(), entry); err != nil {
+		u.metrics.IncAuditEntry(decision, "failure")
+		log.Error().Err(err).Uint64("round", round).Str("decision", decision).Msg("Failed to record audit entry")
+		return
+	}
+	u.metrics.IncAuditEntry(decision, "success")
+}
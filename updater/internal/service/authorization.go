@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/alerting"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rs/zerolog/log"
+)
+
+// authorizationPollInterval is the interval between authorization checks
+// while submissions are held back
+const authorizationPollInterval = 15 * time.Second
+
+// Authorization checks holding submissions back
+const (
+	// checkPaused fails while the oracle is paused
+	checkPaused = "paused"
+	// checkSigner fails while the oracle's signer is not the configured signer
+	checkSigner = "signer"
+)
+
+// AuthorizationStatus is whether the oracle accepts the updater's rounds, as of
+// the latest check before a submission
+type AuthorizationStatus struct {
+	Authorized   bool       `json:"authorized"`
+	Paused       bool       `json:"paused"`
+	OracleSigner string     `json:"oracle_signer,omitempty"`
+	CheckedAt    *time.Time `json:"checked_at,omitempty"`
+	// HeldSince is when submissions were held back, while not authorized
+	HeldSince *time.Time `json:"held_since,omitempty"`
+}
+
+// authorizationState is the latest authorization check
+type authorizationState struct {
+	mu     sync.Mutex
+	status AuthorizationStatus
+}
+
+// Authorization returns the latest authorization check, nil before the first
+// submission
+func (u *Updater) Authorization() *AuthorizationStatus {
+	u.authorization.mu.Lock()
+	defer u.authorization.mu.Unlock()
+	if u.authorization.status.CheckedAt == nil {
+		return nil
+	}
+	status := u.authorization.status
+	return &status
+}
+
+// checkAuthorization reads the oracle's paused state and signer, and returns
+// the failed check, empty when the updater is authorized
+func (u *Updater) checkAuthorization(ctx context.Context) (string, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	paused, err := u.binding.Paused(opts)
+	if err != nil {
+		return "", fmt.Errorf("error getting paused state from Drand Oracle contract: %w", err)
+	}
+	oracleSigner, err := u.binding.Signer(opts)
+	if err != nil {
+		return "", fmt.Errorf("error getting signer from Drand Oracle contract: %w", err)
+	}
+	signerAuthorized := oracleSigner == u.signer.Address()
+	u.metrics.SetAuthorization(paused, signerAuthorized)
+
+	var failed string
+	switch {
+	case paused:
+		failed = checkPaused
+	case !signerAuthorized:
+		failed = checkSigner
+	}
+
+	now := time.Now()
+	u.authorization.mu.Lock()
+	status := &u.authorization.status
+	wasAuthorized := status.CheckedAt == nil || status.Authorized
+	checked, wasPaused, previousSigner := status.CheckedAt != nil, status.Paused, status.OracleSigner
+	status.Authorized = failed == ""
+	status.Paused = paused
+	status.OracleSigner = oracleSigner.Hex()
+	status.CheckedAt = &now
+	switch {
+	case wasAuthorized && !status.Authorized:
+		status.HeldSince = &now
+	case status.Authorized:
+		status.HeldSince = nil
+	}
+	u.authorization.mu.Unlock()
+
+	if checked {
+		u.recordAuthorizationChanges(ctx, wasPaused, paused, previousSigner, oracleSigner.Hex())
+	}
+	if wasAuthorized && failed != "" {
+		u.alertNotAuthorized(failed, oracleSigner.Hex())
+	}
+	if !wasAuthorized && failed == "" {
+		log.Info().Msg("Oracle accepts the updater's rounds again, resuming submissions")
+		u.resolveAlert(alerting.ConditionNotAuthorized)
+	}
+	return failed, nil
+}
+
+// recordAuthorizationChanges records the pauses and signer changes of the
+// oracle seen since the previous check in the timeline
+func (u *Updater) recordAuthorizationChanges(ctx context.Context, wasPaused, paused bool, previousSigner, oracleSigner string) {
+	switch {
+	case !wasPaused && paused:
+		u.recordOracleChange(ctx, kindOraclePaused, "Paused", "Oracle paused", map[string]string{})
+	case wasPaused && !paused:
+		u.recordOracleChange(ctx, kindOracleUnpaused, "Unpaused", "Oracle unpaused", map[string]string{})
+	}
+	if previousSigner != oracleSigner {
+		u.recordOracleChange(ctx, kindSignerUpdated, "SignerUpdated", "Oracle signer changed to "+oracleSigner, map[string]string{
+			"previous_signer": previousSigner,
+			"signer":          oracleSigner,
+		})
+	}
+}
+
+// alertNotAuthorized logs and alerts that submissions are held back
+func (u *Updater) alertNotAuthorized(check string, oracleSigner string) {
+	summary := "Oracle is paused, holding submissions back until it is unpaused"
+	if check == checkSigner {
+		summary = "Oracle signer changed, holding submissions back until the configured signer is authorized again"
+	}
+	log.Error().
+		Str("check", check).
+		Str("oracle_signer", oracleSigner).
+		Str("signer", u.signer.Address().Hex()).
+		Msg(summary)
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionNotAuthorized,
+		Severity:  alerting.SeverityCritical,
+		Summary:   summary,
+		Details: map[string]string{
+			"check":         check,
+			"oracle_signer": oracleSigner,
+			"signer":        u.signer.Address().Hex(),
+		},
+	})
+}
+
+// waitForAuthorization blocks while the oracle is paused or its signer is not
+// the configured signer, as every transaction would revert. A failed check
+// does not hold the submission back, the gas estimation catches an unauthorized
+// updater anyway.
+func (u *Updater) waitForAuthorization(ctx context.Context) error {
+	failed, err := u.checkAuthorization(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warn().Err(err).Msg("Failed to check the updater is authorized, submitting anyway")
+		return nil
+	}
+	if failed == "" {
+		return nil
+	}
+	u.metrics.IncSubmissionHeld(failed)
+
+	ticker := time.NewTicker(authorizationPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			failed, err = u.checkAuthorization(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				log.Warn().Err(err).Msg("Failed to check the updater is authorized")
+				continue
+			}
+			if failed == "" {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/audit"
+	"drand-oracle-updater/internal/canary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// batchSize returns the maximum number of rounds submitted per transaction
+// from a round, the canary's for its rounds. Dry runs and user operations
+// submit rounds one by one.
+func (u *Updater) batchSize(round uint64) int {
+	size := u.options.BatchSize
+	if u.canaryGroup(round) == canary.Canary && u.options.Canary.BatchSize > 0 {
+		size = u.options.Canary.BatchSize
+	}
+	if size <= 1 || u.options.DryRun || u.options.UserOperations != nil {
+		return 1
+	}
+	return size
+}
+
+// collectBatch returns the rounds submitted along with a popped round: the
+// round itself and the queued catch-up rounds directly following it
+func (u *Updater) collectBatch(rd *roundData) []*roundData {
+	return append([]*roundData{rd}, u.scheduler.PopFollowing(rd.round, u.batchSize(rd.round)-1)...)
+}
+
+// submitRounds submits a single round, or consecutive rounds in one batch
+func (u *Updater) submitRounds(ctx context.Context, batch []*roundData) error {
+	if len(batch) == 1 {
+		rd := batch[0]
+		return u.processRoundData(ctx, rd)
+	}
+	return u.processBatch(ctx, batch)
+}
+
+// processBatch submits consecutive rounds in one setRandomnessBatch transaction
+func (u *Updater) processBatch(ctx context.Context, batch []*roundData) error {
+	// Rounds set since the batch was collected, by a previous attempt or
+	// another updater, are dropped
+	latestOracleRound := u.GetLatestOracleRound()
+	for len(batch) > 0 && batch[0].round <= latestOracleRound && batch[0].round != u.genesisRound {
+		batch = batch[1:]
+	}
+	switch {
+	case len(batch) == 0:
+		return nil
+	case len(batch) == 1:
+		return u.submitRounds(ctx, batch)
+	}
+	first, last := batch[0].round, batch[len(batch)-1].round
+	if first != u.genesisRound && first != latestOracleRound+1 {
+		log.Info().
+			Uint64("latestOracleRound", latestOracleRound).
+			Uint64("from_round", first).
+			Msg("Skipping irrelevant batch")
+		u.auditDecision(audit.DecisionSkipped, first, last, nil, fmt.Sprintf("oracle at round %d", latestOracleRound), "")
+		return nil
+	}
+
+	log.Info().
+		Uint64("from_round", first).
+		Uint64("to_round", last).
+		Int("rounds", len(batch)).
+		Msg("Processing batch")
+
+	randoms := make([]binding.IDrandOracleRandom, len(batch))
+	signatures := make([][]byte, len(batch))
+	signCtx, span := tracer.Start(ctx, "sign")
+	for i, rd := range batch {
+		randoms[i] = binding.IDrandOracleRandom{
+			Round:      rd.round,
+			Timestamp:  u.roundTimestamp(rd.round),
+			Randomness: [32]byte(rd.randomness),
+			Signature:  rd.signature,
+		}
+		signature, err := u.signer.SignSetRandomness(signCtx, rd.round, randoms[i].Timestamp, randoms[i].Randomness, rd.signature)
+		if err != nil {
+			log.Error().Err(err).Uint64("round", rd.round).Msg("Failed to sign set randomness")
+			endSpan(span, err)
+			return err
+		}
+		signatures[i] = signature
+	}
+	span.End()
+
+	// The last round of the batch is the most recent one
+	err := traceStage(ctx, "await_chain_clock", func(ctx context.Context) error {
+		return u.awaitChainClock(ctx, last, randoms[len(randoms)-1].Timestamp)
+	})
+	if err != nil {
+		return err
+	}
+
+	gasStrategy := u.gasStrategy(first)
+	gasPrice, err := gasStrategy.GasPrice(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("strategy", gasStrategy.Name()).Msg("Failed to get gas price")
+		return err
+	}
+	u.metrics.SetGasPrice(gasStrategy.Name(), gasPrice)
+
+	var value *big.Int
+	if u.options.SubmissionFee != nil {
+		value = new(big.Int).Mul(u.options.SubmissionFee, big.NewInt(int64(len(batch))))
+	}
+	broadcastCtx, broadcastSpan := tracer.Start(ctx, "broadcast")
+	nonce, err := u.nonces.Next(broadcastCtx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sender nonce")
+		endSpan(broadcastSpan, err)
+		return err
+	}
+	tx, err := u.binding.SetRandomnessBatch(
+		&bind.TransactOpts{
+			From:     u.sender.Address(),
+			Nonce:    new(big.Int).SetUint64(nonce),
+			Signer:   u.sender.SignerFn(),
+			GasLimit: u.setRandomnessGasLimit * uint64(len(batch)),
+			GasPrice: gasPrice,
+			Value:    value,
+			NoSend:   true,
+		},
+		randoms,
+		signatures,
+	)
+	if err != nil {
+		u.nonces.Release(nonce)
+		endSpan(broadcastSpan, err)
+		return err
+	}
+	broadcastSpan.SetAttributes(attrTxHash.String(tx.Hash().Hex()), attrNonce.Int64(int64(nonce)))
+	broadcastAt := time.Now()
+	err = u.broadcast(broadcastCtx, tx)
+	endSpan(broadcastSpan, err)
+	if err != nil {
+		log.Error().Err(err).Str("hash", tx.Hash().Hex()).Msg("Failed to broadcast set randomness batch transaction")
+		u.nonces.Release(nonce)
+		return err
+	}
+	u.nonces.Sent(tx)
+	u.metrics.ObserveCalldataBytes(calldataBatch, len(tx.Data()))
+	receipt, err := u.waitMined(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to wait for transaction to be mined")
+		return err
+	}
+	u.nonces.Confirm(nonce)
+	u.recordTransaction(first, len(batch), batch[0].source, tx, receipt)
+	u.trackConfirmation(first, last, receipt)
+	u.indexRound(receipt)
+	u.metrics.ObserveGasUsage(0, receipt.GasUsed)
+	u.metrics.ObserveFeePaid(transactionFee(tx, receipt))
+
+	u.observeCanary(first, len(batch), transactionFee(tx, receipt), time.Since(broadcastAt), receipt.Status != types.ReceiptStatusSuccessful)
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		failure := u.classifyRevert(ctx, u.failedTxMsg(tx), receipt.BlockNumber, first, receipt.GasUsed)
+		u.metrics.IncSetRandomnessFailure(failure.reason)
+		u.recordLoss(first, transactionFee(tx, receipt))
+		log.Error().
+			Uint64("from_round", first).
+			Uint64("to_round", last).
+			Str("hash", tx.Hash().Hex()).
+			Str("reason", failure.reason).
+			Str("revert", failure.decoded).
+			Uint64("gas_used", receipt.GasUsed).
+			Msg("Set randomness batch transaction failed")
+		u.auditDecision(audit.DecisionReverted, first, last, nil, failure.reason, tx.Hash().Hex())
+		return fmt.Errorf("set randomness batch transaction failed: %s", failure.reason)
+	}
+	log.Info().
+		Uint64("from_round", first).
+		Uint64("to_round", last).
+		Str("hash", tx.Hash().Hex()).
+		Msg("Set randomness batch transaction successful")
+	u.auditDecision(audit.DecisionSubmitted, first, last, nil, "", tx.Hash().Hex())
+	u.verifyReceipt(receipt.Logs, tx.Hash().Hex(), randoms...)
+	for _, random := range randoms {
+		u.roundSet(random.Round, random.Timestamp)
+	}
+	return nil
+}
+
+// roundTimestamp returns the drand timestamp of a round
+func (u *Updater) roundTimestamp(round uint64) uint64 {
+	return uint64(u.drandInfo.GenesisTime) + uint64(round-1)*uint64(u.drandInfo.Period.Seconds())
+}
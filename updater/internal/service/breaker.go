@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/alerting"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const breakerPollInterval = 5 * time.Second
+
+// BreakerStatus is the state of the financial circuit breaker
+type BreakerStatus struct {
+	Enabled  bool       `json:"enabled"`
+	Open     bool       `json:"open"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	// Losses is the amount spent on failed transactions within the window
+	Losses string `json:"losses_wei"`
+	Limit  string `json:"limit_wei"`
+	Window string `json:"window"`
+}
+
+type loss struct {
+	at  time.Time
+	wei *big.Int
+}
+
+// lossBreaker trips after more than limit wei has been spent on failed
+// transactions within window. Once open it stays open for cooldown, or until
+// reset when cooldown is 0.
+type lossBreaker struct {
+	limit    *big.Int
+	window   time.Duration
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	losses   []loss
+	openedAt time.Time
+}
+
+func newLossBreaker(limit *big.Int, window, cooldown time.Duration) *lossBreaker {
+	return &lossBreaker{limit: limit, window: window, cooldown: cooldown}
+}
+
+func (b *lossBreaker) enabled() bool {
+	return b.limit != nil && b.limit.Sign() > 0 && b.window > 0
+}
+
+// record adds the cost of a failed transaction and reports whether it tripped the breaker
+func (b *lossBreaker) record(now time.Time, wei *big.Int) bool {
+	if !b.enabled() {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.losses = append(b.losses, loss{at: now, wei: new(big.Int).Set(wei)})
+	if !b.openedAt.IsZero() || b.total(now).Cmp(b.limit) <= 0 {
+		return false
+	}
+	b.openedAt = now
+	return true
+}
+
+// isOpen reports whether submissions must be paused, closing the breaker once the cooldown has elapsed
+func (b *lossBreaker) isOpen(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return false
+	}
+	if b.cooldown > 0 && now.Sub(b.openedAt) >= b.cooldown {
+		log.Info().Dur("cooldown", b.cooldown).Msg("Financial circuit breaker closed after cooldown")
+		b.openedAt = time.Time{}
+		b.losses = nil
+		return false
+	}
+	return true
+}
+
+// reset closes the breaker and forgets the recorded losses
+func (b *lossBreaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openedAt = time.Time{}
+	b.losses = nil
+}
+
+func (b *lossBreaker) status(now time.Time) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Enabled: b.enabled(),
+		Open:    !b.openedAt.IsZero(),
+		Losses:  b.total(now).String(),
+		Window:  b.window.String(),
+	}
+	if b.limit != nil {
+		status.Limit = b.limit.String()
+	}
+	if status.Open {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// total drops losses older than the window and sums the others, it must be
+// called with the lock held
+func (b *lossBreaker) total(now time.Time) *big.Int {
+	kept := b.losses[:0]
+	total := new(big.Int)
+	for _, l := range b.losses {
+		if now.Sub(l.at) > b.window {
+			continue
+		}
+		kept = append(kept, l)
+		total.Add(total, l.wei)
+	}
+	b.losses = kept
+	return total
+}
+
+// recordLoss feeds the gas fee of a failed transaction to the circuit breaker
+func (u *Updater) recordLoss(round uint64, fee *big.Int) {
+	if !u.breaker.record(time.Now(), fee) {
+		return
+	}
+	status := u.breaker.status(time.Now())
+	log.Error().
+		Int64("chain_id", u.chainID).
+		Uint64("round", round).
+		Str("losses_wei", status.Losses).
+		Str("limit_wei", status.Limit).
+		Str("window", status.Window).
+		Msg("Financial circuit breaker tripped, pausing submissions")
+	u.metrics.SetCircuitBreakerOpen(true)
+	u.recordEvent(kindCircuitBreakerTripped, round, "Financial circuit breaker tripped", map[string]string{
+		"losses_wei": status.Losses,
+		"limit_wei":  status.Limit,
+		"window":     status.Window,
+	})
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionCircuitBreaker,
+		Severity:  alerting.SeverityCritical,
+		Summary:   "Financial circuit breaker tripped, submissions are paused",
+		Details: map[string]string{
+			"round":      fmt.Sprintf("%d", round),
+			"losses_wei": status.Losses,
+			"limit_wei":  status.Limit,
+			"window":     status.Window,
+		},
+	})
+}
+
+// waitForBreaker blocks while the financial circuit breaker is open
+func (u *Updater) waitForBreaker(ctx context.Context) error {
+	if !u.breaker.isOpen(time.Now()) {
+		return nil
+	}
+	ticker := time.NewTicker(breakerPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if !u.breaker.isOpen(time.Now()) {
+				u.metrics.SetCircuitBreakerOpen(false)
+				u.recordEvent(kindCircuitBreakerClosed, 0, "Financial circuit breaker closed after its cooldown", nil)
+				log.Info().Msg("Financial circuit breaker closed, resuming submissions")
+				return nil
+			}
+		}
+	}
+}
+
+// CircuitBreaker returns the state of the financial circuit breaker
+func (u *Updater) CircuitBreaker() BreakerStatus {
+	return u.breaker.status(time.Now())
+}
+
+// ResetCircuitBreaker closes the financial circuit breaker
+func (u *Updater) ResetCircuitBreaker() BreakerStatus {
+	u.breaker.reset()
+	u.metrics.SetCircuitBreakerOpen(false)
+	u.recordEvent(kindCircuitBreakerClosed, 0, "Financial circuit breaker reset", map[string]string{"reset": "admin"})
+	u.resolveAlert(alerting.ConditionCircuitBreaker)
+	log.Warn().Msg("Financial circuit breaker reset")
+	return u.breaker.status(time.Now())
+}
+
+func (u *Updater) checkCircuitBreaker() CheckResult {
+	result := CheckResult{Name: "circuit_breaker"}
+	status := u.breaker.status(time.Now())
+	result.Value = status.Losses
+	if status.Open {
+		result.Error = "financial circuit breaker is open"
+		return result
+	}
+	result.OK = true
+	return result
+}
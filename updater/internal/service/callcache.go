@@ -0,0 +1,23 @@
+package service
+
+// Oracle view methods whose cached results the updater invalidates
+const (
+	methodLatestRound   = "latestRound"
+	methodEarliestRound = "earliestRound"
+	methodSigner        = "signer"
+)
+
+// CallCache caches the oracle's view calls made through the binding. The
+// updater invalidates the cached results it learns have changed.
+type CallCache interface {
+	// Invalidate drops the cached results of the given methods, of every
+	// method when none is given
+	Invalidate(methods ...string)
+}
+
+// invalidateCalls drops cached view call results, if calls are cached
+func (u *Updater) invalidateCalls(methods ...string) {
+	if u.options.CallCache != nil {
+		u.options.CallCache.Invalidate(methods...)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rs/zerolog/log"
+)
+
+// CalldataForm is the form of the setRandomness calldata
+type CalldataForm string
+
+const (
+	// CalldataAuto submits the compact form when the oracle supports it
+	CalldataAuto CalldataForm = "auto"
+	// CalldataFull submits every field of the round with setRandomness
+	CalldataFull CalldataForm = "full"
+	// CalldataCompact submits only the timestamp and the drand signature with
+	// setRandomnessCompact, the oracle deriving the round and the randomness
+	CalldataCompact CalldataForm = "compact"
+
+	// calldataBatch labels the calldata of setRandomnessBatch transactions
+	calldataBatch CalldataForm = "batch"
+)
+
+// Capability flags returned by the oracle's capabilities()
+const (
+	capabilityBatch   = 0
+	capabilityCompact = 1
+)
+
+// ParseCalldataForm parses a calldata form name
+func ParseCalldataForm(s string) (CalldataForm, error) {
+	switch f := CalldataForm(s); f {
+	case CalldataAuto, CalldataFull, CalldataCompact:
+		return f, nil
+	case "":
+		return CalldataAuto, nil
+	default:
+		return "", fmt.Errorf("unknown calldata form %q", s)
+	}
+}
+
+// SupportsCompactCalldata reports whether the oracle's capability flags
+// include the compact calldata form
+func SupportsCompactCalldata(capabilities *big.Int) bool {
+	return capabilities.Bit(capabilityCompact) == 1
+}
+
+// negotiateCalldata reads the oracle's capability flags and enables the
+// compact calldata form when the oracle supports it. Oracles predating
+// capabilities() only accept the full form.
+func (u *Updater) negotiateCalldata(ctx context.Context) error {
+	form := u.options.CalldataForm
+	if form == CalldataFull {
+		log.Info().Str("form", string(CalldataFull)).Msg("Calldata form set")
+		return nil
+	}
+	capabilities, err := u.binding.Capabilities(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		if form == CalldataCompact {
+			return fmt.Errorf("error reading the oracle capabilities for the compact calldata form: %w", err)
+		}
+		log.Info().Err(err).Msg("The oracle does not report its capabilities, submitting the full calldata form")
+		return nil
+	}
+	u.compactCalldata = SupportsCompactCalldata(capabilities)
+	if form == CalldataCompact && !u.compactCalldata {
+		return fmt.Errorf("the oracle does not support the compact calldata form, capabilities %#x", capabilities)
+	}
+	negotiated := CalldataFull
+	if u.compactCalldata {
+		negotiated = CalldataCompact
+	}
+	log.Info().
+		Str("capabilities", fmt.Sprintf("%#x", capabilities)).
+		Bool("batch", capabilities.Bit(capabilityBatch) == 1).
+		Str("form", string(negotiated)).
+		Msg("Calldata form negotiated with the oracle")
+	return nil
+}
+
+// calldataForm returns the form of the calldata setting a round. The compact
+// form only sets the round following the oracle's latest round, so the full
+// form is kept for the oracle's first round and for rounds past the next one.
+func (u *Updater) calldataForm(round uint64) CalldataForm {
+	if !u.compactCalldata {
+		return CalldataFull
+	}
+	u.latestOracleRoundMutex.RLock()
+	latestOracleRound := u.latestOracleRound
+	u.latestOracleRoundMutex.RUnlock()
+	if latestOracleRound == 0 || round != latestOracleRound+1 {
+		return CalldataFull
+	}
+	return CalldataCompact
+}
+
+// setRandomnessCalldata packs the calldata setting a round, in the form
+// negotiated with the oracle, and returns it with its form
+func (u *Updater) setRandomnessCalldata(random binding.IDrandOracleRandom, signature []byte) ([]byte, CalldataForm, error) {
+	contractABI, err := binding.BindingMetaData.GetAbi()
+	if err != nil {
+		return nil, "", err
+	}
+	form := u.calldataForm(random.Round)
+	var calldata []byte
+	if form == CalldataCompact {
+		calldata, err = contractABI.Pack("setRandomnessCompact", random.Timestamp, random.Signature, signature)
+	} else {
+		calldata, err = contractABI.Pack("setRandomness", random, signature)
+	}
+	return calldata, form, err
+}
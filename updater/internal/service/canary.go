@@ -0,0 +1,66 @@
+package service
+
+import (
+	"drand-oracle-updater/alerting"
+	"drand-oracle-updater/gas"
+	"drand-oracle-updater/internal/canary"
+	"math/big"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Canary rolls out an alternative gas strategy or batch size to a share of
+// the rounds, and rolls it back when it regresses against the other rounds
+type Canary struct {
+	Controller *canary.Controller
+	// GasStrategy prices the canary rounds, nil uses the updater's strategy
+	GasStrategy gas.Strategy
+	// BatchSize replaces the batch size for the canary rounds, 0 keeps it
+	BatchSize int
+}
+
+// canaryGroup returns the rollout group of a round, the control group without
+// a canary
+func (u *Updater) canaryGroup(round uint64) canary.Group {
+	if u.options.Canary == nil {
+		return canary.Control
+	}
+	return u.options.Canary.Controller.Group(round)
+}
+
+// gasStrategy returns the gas strategy pricing a round
+func (u *Updater) gasStrategy(round uint64) gas.Strategy {
+	if u.canaryGroup(round) == canary.Canary && u.options.Canary.GasStrategy != nil {
+		return u.options.Canary.GasStrategy
+	}
+	return u.options.GasStrategy
+}
+
+// observeCanary records the outcome of a mined transaction setting rounds from
+// first in the rollout, and rolls the canary back on regression
+func (u *Updater) observeCanary(first uint64, rounds int, fee *big.Int, latency time.Duration, failed bool) {
+	if u.options.Canary == nil {
+		return
+	}
+	group := u.canaryGroup(first)
+	cost := new(big.Int).Div(fee, big.NewInt(int64(max(rounds, 1))))
+	regression := u.options.Canary.Controller.Observe(group, canary.Outcome{Cost: cost, Latency: latency, Failed: failed})
+	if regression == "" {
+		return
+	}
+	status := u.options.Canary.Controller.Status()
+	log.Error().
+		Str("canary", status.Name).
+		Str("regression", regression).
+		Msg("Canary strategy regressed against the control group, rolled back")
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionCanaryRolledBack,
+		Severity:  alerting.SeverityWarning,
+		Summary:   "Canary strategy " + status.Name + " rolled back: " + regression,
+		Details: map[string]string{
+			"canary":     status.Name,
+			"regression": regression,
+		},
+	})
+}
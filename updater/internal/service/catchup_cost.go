@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// gasHistorySize is the number of recent successful transactions the gas per round is learned from
+const gasHistorySize = 100
+
+// ErrNoPendingCatchUp is returned when approving a catch-up that is not waiting for approval
+var ErrNoPendingCatchUp = errors.New("no catch-up is waiting for approval")
+
+// CatchUpEstimate is the simulated cost of a catch-up, computed before it starts
+type CatchUpEstimate struct {
+	FromRound   uint64 `json:"from_round"`
+	ToRound     uint64 `json:"to_round"`
+	Rounds      uint64 `json:"rounds"`
+	GasPerRound uint64 `json:"gas_per_round"`
+	// GasSource is "history" when the gas per round was learned from past
+	// transactions and "gas_limit" when the gas limit was used instead
+	GasSource        string    `json:"gas_source"`
+	GasPrice         string    `json:"gas_price_wei"`
+	SubmissionFee    string    `json:"submission_fee_wei"`
+	CostPerRound     string    `json:"cost_per_round_wei"`
+	TotalCost        string    `json:"total_cost_wei"`
+	Ceiling          string    `json:"ceiling_wei,omitempty"`
+	RequiresApproval bool      `json:"requires_approval"`
+	Approved         bool      `json:"approved"`
+	EstimatedAt      time.Time `json:"estimated_at"`
+}
+
+// catchUpGate holds the latest catch-up estimate and its approval
+type catchUpGate struct {
+	mu       sync.Mutex
+	estimate *CatchUpEstimate
+	approved chan struct{}
+}
+
+// estimateCatchUp simulates the cost of submitting rounds from through to, as
+// selected by the catch-up policy
+func (u *Updater) estimateCatchUp(ctx context.Context, from, to uint64) (*CatchUpEstimate, error) {
+	gasPrice, err := u.options.GasStrategy.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gasPerRound, gasSource := u.learnedGasPerRound()
+
+	submissionFee := new(big.Int)
+	if u.options.SubmissionFee != nil {
+		submissionFee.Set(u.options.SubmissionFee)
+	}
+	costPerRound := new(big.Int).Mul(new(big.Int).SetUint64(gasPerRound), gasPrice)
+	costPerRound.Add(costPerRound, submissionFee)
+	rounds := u.options.CatchUpPolicy.count(from, to)
+	totalCost := new(big.Int).Mul(costPerRound, new(big.Int).SetUint64(rounds))
+
+	estimate := &CatchUpEstimate{
+		FromRound:     from,
+		ToRound:       to,
+		Rounds:        rounds,
+		GasPerRound:   gasPerRound,
+		GasSource:     gasSource,
+		GasPrice:      gasPrice.String(),
+		SubmissionFee: submissionFee.String(),
+		CostPerRound:  costPerRound.String(),
+		TotalCost:     totalCost.String(),
+		EstimatedAt:   time.Now().UTC(),
+	}
+	if ceiling := u.options.CatchUpCostCeiling; ceiling != nil && ceiling.Sign() > 0 {
+		estimate.Ceiling = ceiling.String()
+		estimate.RequiresApproval = totalCost.Cmp(ceiling) > 0
+	}
+	if u.options.CatchUpRequireApproval {
+		estimate.RequiresApproval = true
+	}
+	return estimate, nil
+}
+
+// learnedGasPerRound averages the gas used per round by recent successful
+// transactions, falling back to the gas limit when there is no history
+func (u *Updater) learnedGasPerRound() (uint64, string) {
+	txs, err := u.store.Transactions(time.Time{}, time.Time{})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read transaction history, estimating with the gas limit")
+		return u.setRandomnessGasLimit, "gas_limit"
+	}
+
+	var total, count, seen uint64
+	for i := len(txs) - 1; i >= 0 && seen < gasHistorySize; i-- {
+		if txs[i].Status != types.ReceiptStatusSuccessful || txs[i].ChainID != u.chainID {
+			continue
+		}
+		// A batch transaction sets BatchSize rounds
+		seen++
+		total += txs[i].GasUsed
+		count += uint64(max(txs[i].BatchSize, 1))
+	}
+	if count == 0 {
+		return u.setRandomnessGasLimit, "gas_limit"
+	}
+	return total / count, "history"
+}
+
+// gateCatchUp estimates the cost of a catch-up and, when approval is required,
+// blocks until it is approved through the admin API
+func (u *Updater) gateCatchUp(ctx context.Context, from, to uint64) error {
+	estimate, err := u.estimateCatchUp(ctx, from, to)
+	if err != nil {
+		if u.catchUpGated() {
+			log.Error().Err(err).Msg("Failed to estimate catch-up cost")
+			return err
+		}
+		// The estimate is informational only, so do not block the catch-up on it
+		log.Warn().Err(err).Msg("Failed to estimate catch-up cost")
+		return nil
+	}
+
+	approved := make(chan struct{})
+	u.catchUpGate.mu.Lock()
+	u.catchUpGate.estimate = estimate
+	u.catchUpGate.approved = approved
+	u.catchUpGate.mu.Unlock()
+
+	log.Info().
+		Uint64("from_round", estimate.FromRound).
+		Uint64("to_round", estimate.ToRound).
+		Uint64("rounds", estimate.Rounds).
+		Uint64("gas_per_round", estimate.GasPerRound).
+		Str("gas_source", estimate.GasSource).
+		Str("gas_price_wei", estimate.GasPrice).
+		Str("total_cost_wei", estimate.TotalCost).
+		Bool("requires_approval", estimate.RequiresApproval).
+		Msg("Estimated catch-up cost")
+	u.metrics.SetCatchUpEstimatedCost(estimate.TotalCost)
+
+	if !estimate.RequiresApproval {
+		return nil
+	}
+
+	log.Warn().
+		Str("total_cost_wei", estimate.TotalCost).
+		Str("ceiling_wei", estimate.Ceiling).
+		Msg("Catch-up is waiting for approval through POST /admin/catch-up/approve")
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-approved:
+		log.Info().Msg("Catch-up approved, proceeding")
+		return nil
+	}
+}
+
+// catchUpGated reports whether catch-ups may need approval before proceeding
+func (u *Updater) catchUpGated() bool {
+	ceiling := u.options.CatchUpCostCeiling
+	return u.options.CatchUpRequireApproval || (ceiling != nil && ceiling.Sign() > 0)
+}
+
+// CatchUpEstimate returns the estimate of the last catch-up, nil if none was estimated
+func (u *Updater) CatchUpEstimate() *CatchUpEstimate {
+	u.catchUpGate.mu.Lock()
+	defer u.catchUpGate.mu.Unlock()
+	if u.catchUpGate.estimate == nil {
+		return nil
+	}
+	estimate := *u.catchUpGate.estimate
+	return &estimate
+}
+
+// ApproveCatchUp approves a catch-up waiting for approval
+func (u *Updater) ApproveCatchUp() (*CatchUpEstimate, error) {
+	u.catchUpGate.mu.Lock()
+	defer u.catchUpGate.mu.Unlock()
+
+	estimate := u.catchUpGate.estimate
+	if estimate == nil || !estimate.RequiresApproval || estimate.Approved {
+		return nil, ErrNoPendingCatchUp
+	}
+	estimate.Approved = true
+	close(u.catchUpGate.approved)
+
+	approved := *estimate
+	return &approved, nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/audit"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CatchUpMode selects the rounds of the backlog submitted while catching up
+type CatchUpMode string
+
+const (
+	// CatchUpAll submits every round of the backlog
+	CatchUpAll CatchUpMode = "all"
+	// CatchUpLatestOnly submits the latest drand round only
+	CatchUpLatestOnly CatchUpMode = "latest-only"
+	// CatchUpEveryNth submits the rounds that are multiples of N, and the latest round
+	CatchUpEveryNth CatchUpMode = "every-nth"
+)
+
+// CatchUpPolicy selects the rounds submitted while catching up. Any policy
+// but submitting every round, without a maximum age, leaves gaps in the
+// oracle's rounds, which the oracle contract must accept.
+type CatchUpPolicy struct {
+	Mode CatchUpMode
+	// Every is N of CatchUpEveryNth
+	Every uint64
+	// MaxAge skips the rounds of the backlog produced longer ago, 0 keeps them all
+	MaxAge time.Duration
+}
+
+// ParseCatchUpPolicy parses a catch-up mode name with its parameters
+func ParseCatchUpPolicy(mode string, every uint64, maxAge time.Duration) (CatchUpPolicy, error) {
+	policy := CatchUpPolicy{Mode: CatchUpMode(mode), Every: every, MaxAge: maxAge}
+	switch policy.Mode {
+	case CatchUpAll, CatchUpLatestOnly:
+	case "":
+		policy.Mode = CatchUpAll
+	case CatchUpEveryNth:
+		if every < 2 {
+			return CatchUpPolicy{}, fmt.Errorf("catch-up mode %q requires an interval of at least 2, got %d", mode, every)
+		}
+	default:
+		return CatchUpPolicy{}, fmt.Errorf("unknown catch-up mode %q", mode)
+	}
+	if maxAge < 0 {
+		return CatchUpPolicy{}, fmt.Errorf("invalid catch-up maximum age %s", maxAge)
+	}
+	return policy, nil
+}
+
+// Skips reports whether the policy may leave rounds of the backlog unsubmitted
+func (p CatchUpPolicy) Skips() bool {
+	return (p.Mode != "" && p.Mode != CatchUpAll) || p.MaxAge > 0
+}
+
+// first returns the first round to submit from round from, given the latest
+// drand round. Rounds past latest are returned as is.
+func (p CatchUpPolicy) first(from, latest uint64) uint64 {
+	if from > latest {
+		return from
+	}
+	switch p.Mode {
+	case CatchUpLatestOnly:
+		return latest
+	case CatchUpEveryNth:
+		next := (from + p.Every - 1) / p.Every * p.Every
+		if next > latest {
+			return latest
+		}
+		return next
+	default:
+		return from
+	}
+}
+
+// catchUpNext returns the first round to submit from round from, recording
+// the rounds the catch-up policy skips to the audit log
+func (u *Updater) catchUpNext(from, latest uint64) uint64 {
+	next := u.options.CatchUpPolicy.first(from, latest)
+	if next > from {
+		u.auditDecision(audit.DecisionSkipped, from, next-1, nil, fmt.Sprintf("catch-up mode %s", u.options.CatchUpPolicy.Mode), "")
+	}
+	return next
+}
+
+// count returns the number of rounds submitted from round from through latest
+func (p CatchUpPolicy) count(from, latest uint64) uint64 {
+	if from > latest {
+		return 0
+	}
+	switch p.Mode {
+	case CatchUpLatestOnly:
+		return 1
+	case CatchUpEveryNth:
+		// The multiples of N, and the latest round when it is not one
+		n := latest/p.Every - (from-1)/p.Every
+		if latest%p.Every != 0 {
+			n++
+		}
+		return n
+	default:
+		return latest - from + 1
+	}
+}
+
+// catchUpStart returns the first round of the backlog within the maximum age
+func (u *Updater) catchUpStart(from uint64) uint64 {
+	maxAge := u.options.CatchUpPolicy.MaxAge
+	if maxAge <= 0 {
+		return from
+	}
+	cutoff := time.Now().Add(-maxAge).Unix() - u.drandInfo.GenesisTime
+	if cutoff <= 0 {
+		return from
+	}
+	// The first round produced at or after the cutoff
+	period := int64(u.drandInfo.Period.Seconds())
+	oldest := uint64((cutoff+period-1)/period) + 1
+	if oldest > from {
+		log.Info().
+			Uint64("from_round", from).
+			Uint64("oldest_round", oldest).
+			Dur("max_age", maxAge).
+			Msg("Skipping catch-up rounds older than the maximum age")
+		u.auditDecision(audit.DecisionSkipped, from, oldest-1, nil, fmt.Sprintf("older than the catch-up maximum age of %s", maxAge), "")
+		return oldest
+	}
+	return from
+}
+
+// checkCatchUpPolicy refuses a policy skipping rounds when the oracle only
+// accepts the round following its latest round, by simulating the submission
+// of the round after next. It can only tell while the oracle is behind by two
+// rounds or more, which is when rounds would be skipped.
+func (u *Updater) checkCatchUpPolicy(ctx context.Context) error {
+	if !u.options.CatchUpPolicy.Skips() {
+		return nil
+	}
+	u.latestDrandRoundMutex.RLock()
+	latestDrandRound := u.latestDrandRound
+	u.latestDrandRoundMutex.RUnlock()
+	latestOracleRound := u.GetLatestOracleRound()
+	if latestOracleRound == 0 || latestDrandRound < latestOracleRound+2 {
+		return nil
+	}
+
+	round := latestOracleRound + 2
+	result, err := u.drandClient.Get(ctx, round)
+	if err != nil {
+		return err
+	}
+	random := binding.IDrandOracleRandom{
+		Round:      round,
+		Timestamp:  uint64(u.drandInfo.GenesisTime) + (round-1)*uint64(u.drandInfo.Period.Seconds()),
+		Randomness: [32]byte(result.Randomness()),
+		Signature:  result.Signature(),
+	}
+	signature, err := u.signer.SignSetRandomness(ctx, random.Round, random.Timestamp, random.Randomness, random.Signature)
+	if err != nil {
+		return err
+	}
+	gasPrice, err := u.options.GasStrategy.GasPrice(ctx)
+	if err != nil {
+		return err
+	}
+	msg, err := u.setRandomnessMsg(random, signature, gasPrice)
+	if err != nil {
+		return err
+	}
+	_, err = u.rpcClient.CallContract(ctx, msg, nil)
+	if isInvalidRound(err) {
+		return fmt.Errorf("catch-up mode %q skips rounds, but the oracle only accepts the round following its latest round", u.options.CatchUpPolicy.Mode)
+	}
+	if err != nil {
+		// Reverting for another reason, such as a set delay, says nothing
+		// about gaps
+		log.Warn().Err(err).Msg("Failed to check that the oracle accepts skipped rounds")
+	}
+	return nil
+}
+
+// isInvalidRound reports whether a call reverted with the oracle's InvalidRound error
+func isInvalidRound(err error) bool {
+	name, ok := decodeRevert(err)
+	return ok && name == "InvalidRound"
+}
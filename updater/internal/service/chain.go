@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/audit"
+	"drand-oracle-updater/internal/chains"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// evmSubmitter sets rounds on the oracle contract of an EVM chain, with the
+// updater's gas pricing, nonces and finality. A round is broadcast and then
+// confirmed once its transaction is mined, as two steps, so that the catch-up
+// pipeline can broadcast rounds while others are in flight.
+type evmSubmitter struct {
+	*Updater
+}
+
+var _ chains.ChainSubmitter = evmSubmitter{}
+
+// Family implements chains.ChainSubmitter
+func (s evmSubmitter) Family() string {
+	return chains.FamilyEVM
+}
+
+// LatestRound reads the latest round from the oracle, implementing
+// chains.ChainSubmitter
+func (s evmSubmitter) LatestRound(ctx context.Context) (uint64, error) {
+	latestRound, err := s.binding.LatestRound(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return 0, fmt.Errorf("error getting latest round from Drand Oracle contract: %w", err)
+	}
+	s.latestOracleRoundMutex.Lock()
+	s.latestOracleRound = max(s.latestOracleRound, latestRound)
+	s.latestOracleRoundMutex.Unlock()
+	return latestRound, nil
+}
+
+// Submit sets a round once the rounds being submitted are, implementing
+// chains.ChainSubmitter
+func (s evmSubmitter) Submit(ctx context.Context, beacon chains.Beacon) error {
+	release, err := s.acquireSubmissions(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return s.submit(ctx, &roundData{round: beacon.Round, randomness: beacon.Randomness[:], signature: beacon.Signature})
+}
+
+// submit broadcasts a round and waits for its transaction to be mined
+func (s evmSubmitter) submit(ctx context.Context, rd *roundData) error {
+	pending, err := s.broadcastRound(ctx, rd)
+	if err != nil || pending == nil {
+		return err
+	}
+	return s.confirmRound(ctx, pending)
+}
+
+// LatestRound reads the latest round from the oracle
+func (u *Updater) LatestRound(ctx context.Context) (uint64, error) {
+	return u.chain.LatestRound(ctx)
+}
+
+// pendingRound is a round whose transaction was broadcast and not mined yet
+type pendingRound struct {
+	rd             *roundData
+	roundTimestamp uint64
+	tx             *types.Transaction
+	nonce          uint64
+	gasEstimate    uint64
+	broadcastAt    time.Time
+}
+
+// broadcastRound signs a round and broadcasts its transaction. It returns nil
+// without error for a round that needs no transaction: an irrelevant round, a
+// dry run or a user operation, which is submitted and mined.
+func (s evmSubmitter) broadcastRound(ctx context.Context, rd *roundData) (*pendingRound, error) {
+	round, randomness, signature, source := rd.round, rd.randomness, rd.signature, rd.source
+	// Only processRounds submits rounds, so the lock is held for reads and
+	// writes only rather than across the whole submission. Rounds broadcast
+	// by the submission pipeline count as set.
+	latestOracleRound := s.submittedRound()
+	// A catch-up policy skipping rounds submits rounds past the next one
+	skipping := s.options.CatchUpPolicy.Skips() && round > latestOracleRound
+	if round != s.genesisRound && latestOracleRound+1 != round && !skipping {
+		log.Info().
+			Uint64("latestOracleRound", latestOracleRound).
+			Uint64("round", round).
+			Msg("Skipping irrelevant round")
+		s.auditDecision(audit.DecisionSkipped, round, 0, signature, fmt.Sprintf("oracle at round %d", latestOracleRound), "")
+		return nil, nil
+	}
+
+	roundTimestamp := s.roundTimestamp(round)
+
+	log.Info().
+		Uint64("round", round).
+		Str("source", source).
+		Time("timestamp", time.Unix(int64(roundTimestamp), 0)).
+		Str("randomness", hex.EncodeToString(randomness)).
+		Str("signature", hex.EncodeToString(signature)).
+		Msg("Processing round")
+
+	eip712Signature, err := s.signRound(ctx, rd, roundTimestamp)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to sign set randomness")
+		return nil, err
+	}
+
+	gasStrategy := s.gasStrategy(round)
+	gasPrice, err := gasStrategy.GasPrice(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("strategy", gasStrategy.Name()).Msg("Failed to get gas price")
+		return nil, err
+	}
+	s.metrics.SetGasPrice(gasStrategy.Name(), gasPrice)
+
+	random := binding.IDrandOracleRandom{
+		Round:      round,
+		Timestamp:  roundTimestamp,
+		Randomness: [32]byte(randomness),
+		Signature:  signature,
+	}
+
+	// Everything up to the broadcast is prepared while the contract does not
+	// accept the round yet
+	earliest := s.earliestSetTime(roundTimestamp)
+	if !earliest.IsZero() {
+		err := traceStage(ctx, "await_set_window", func(ctx context.Context) error {
+			return s.awaitSetWindow(ctx, random, eip712Signature, gasPrice, earliest)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = traceStage(ctx, "await_chain_clock", func(ctx context.Context) error {
+		return s.awaitChainClock(ctx, round, roundTimestamp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.options.DryRun {
+		err := traceStage(ctx, "simulate", func(ctx context.Context) error {
+			return s.simulateSetRandomness(ctx, random, eip712Signature, gasPrice)
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.auditDecision(audit.DecisionSimulated, round, 0, signature, "dry run", "")
+		// Advance the local view only, so the pipeline keeps moving as it would for real
+		s.latestOracleRoundMutex.Lock()
+		s.latestOracleRound = round
+		s.latestOracleRoundMutex.Unlock()
+		return nil, nil
+	}
+
+	if s.options.UserOperations != nil {
+		return nil, traceStage(ctx, "user_operation", func(ctx context.Context) error {
+			return s.submitUserOperation(ctx, round, roundTimestamp, source, random, eip712Signature, gasPrice)
+		})
+	}
+
+	// The estimate is only used to track estimated against actual gas usage. It
+	// is skipped with a set delay, as it would delay the broadcast, and revert
+	// until a block past the delay is mined.
+	var gasEstimate uint64
+	if earliest.IsZero() {
+		gasEstimate, err = s.estimateSetRandomnessGas(ctx, random, eip712Signature, gasPrice)
+		if err != nil {
+			log.Warn().Err(err).Uint64("round", round).Msg("Failed to estimate setRandomness gas")
+		}
+	}
+
+	broadcastCtx, broadcastSpan := tracer.Start(ctx, "broadcast")
+	nonce, err := s.nonces.Next(broadcastCtx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get sender nonce")
+		endSpan(broadcastSpan, err)
+		return nil, err
+	}
+	opts := &bind.TransactOpts{
+		From:     s.sender.Address(),
+		Nonce:    new(big.Int).SetUint64(nonce),
+		Signer:   s.sender.SignerFn(),
+		GasLimit: s.setRandomnessGasLimit,
+		GasPrice: gasPrice,
+		Value:    s.options.SubmissionFee,
+		NoSend:   true,
+	}
+	form := s.calldataForm(round)
+	var tx *types.Transaction
+	if form == CalldataCompact {
+		tx, err = s.binding.SetRandomnessCompact(opts, random.Timestamp, random.Signature, eip712Signature)
+	} else {
+		tx, err = s.binding.SetRandomness(opts, random, eip712Signature)
+	}
+	if err != nil {
+		s.nonces.Release(nonce)
+		endSpan(broadcastSpan, err)
+		return nil, err
+	}
+	broadcastSpan.SetAttributes(attrTxHash.String(tx.Hash().Hex()), attrNonce.Int64(int64(nonce)))
+	broadcastAt := time.Now()
+	err = s.broadcast(broadcastCtx, tx)
+	endSpan(broadcastSpan, err)
+	if err != nil {
+		log.Error().Err(err).Str("hash", tx.Hash().Hex()).Msg("Failed to broadcast set randomness transaction")
+		s.nonces.Release(nonce)
+		return nil, err
+	}
+	s.nonces.Sent(tx)
+	s.metrics.ObserveCalldataBytes(form, len(tx.Data()))
+	return &pendingRound{
+		rd:             rd,
+		roundTimestamp: roundTimestamp,
+		tx:             tx,
+		nonce:          nonce,
+		gasEstimate:    gasEstimate,
+		broadcastAt:    broadcastAt,
+	}, nil
+}
+
+// confirmRound waits for the transaction of a broadcast round to be mined
+func (s evmSubmitter) confirmRound(ctx context.Context, pending *pendingRound) error {
+	round, signature, tx := pending.rd.round, pending.rd.signature, pending.tx
+	receipt, err := s.waitMined(ctx, tx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to wait for transaction to be mined")
+		return err
+	}
+	s.nonces.Confirm(pending.nonce)
+	s.recordTransaction(round, 1, pending.rd.source, tx, receipt)
+	s.trackConfirmation(round, round, receipt)
+	s.indexRound(receipt)
+	s.metrics.ObserveGasUsage(pending.gasEstimate, receipt.GasUsed)
+	s.metrics.ObserveFeePaid(transactionFee(tx, receipt))
+
+	s.observeCanary(round, 1, transactionFee(tx, receipt), time.Since(pending.broadcastAt), receipt.Status != types.ReceiptStatusSuccessful)
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		failure := s.classifyRevert(ctx, s.failedTxMsg(tx), receipt.BlockNumber, round, receipt.GasUsed)
+		s.metrics.IncSetRandomnessFailure(failure.reason)
+		s.recordLoss(round, transactionFee(tx, receipt))
+		log.Error().
+			Uint64("round", round).
+			Str("hash", tx.Hash().Hex()).
+			Str("reason", failure.reason).
+			Str("revert", failure.decoded).
+			Uint64("gas_used", receipt.GasUsed).
+			Msg("Set randomness transaction failed")
+		s.auditDecision(audit.DecisionReverted, round, 0, signature, failure.reason, tx.Hash().Hex())
+		err = fmt.Errorf("set randomness transaction failed: %s", failure.reason)
+		return err
+	} else {
+		log.Info().Uint64("round", round).Str("hash", tx.Hash().Hex()).Msg("Set randomness transaction successful")
+		s.auditDecision(audit.DecisionSubmitted, round, 0, signature, "", tx.Hash().Hex())
+		s.verifyReceipt(receipt.Logs, tx.Hash().Hex(), binding.IDrandOracleRandom{
+			Round:      round,
+			Timestamp:  pending.roundTimestamp,
+			Randomness: [32]byte(pending.rd.randomness),
+			Signature:  signature,
+		})
+		s.roundSet(round, pending.roundTimestamp)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/retry"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// chainClockPollInterval is the interval between block header reads while
+// waiting for the chain clock to catch up with a round
+const chainClockPollInterval = time.Second
+
+// requiredBlockTime returns the block timestamp from which the contract
+// accepts a round: its drand timestamp, plus the contract's set delay
+func (u *Updater) requiredBlockTime(roundTimestamp uint64) uint64 {
+	return roundTimestamp + uint64(u.options.MinSetDelay/time.Second)
+}
+
+// awaitChainClock delays the submission of a round until the latest block
+// timestamp of the chain reaches the round's, as nodes or sequencers with a
+// skewed clock otherwise see the round in the future. The round is submitted
+// anyway after ChainClockMaxWait, or when the chain clock cannot be read.
+func (u *Updater) awaitChainClock(ctx context.Context, round uint64, roundTimestamp uint64) error {
+	if u.options.ChainClockMaxWait <= 0 {
+		return nil
+	}
+	required := u.requiredBlockTime(roundTimestamp)
+	deadline := time.Now().Add(u.options.ChainClockMaxWait)
+	waited := false
+	for {
+		header, err := retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) (*types.Header, error) {
+			return u.rpcClient.HeaderByNumber(ctx, nil)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warn().Err(err).Uint64("round", round).Msg("Failed to read the chain clock, submitting the round")
+			return nil
+		}
+		blockTime := time.Unix(int64(header.Time), 0)
+		skew := time.Since(blockTime)
+		u.metrics.SetChainClockSkew(skew)
+
+		if header.Time >= required {
+			if waited {
+				u.metrics.IncChainClockDelay("caught_up")
+				log.Info().
+					Uint64("round", round).
+					Uint64("block", header.Number.Uint64()).
+					Dur("skew", skew).
+					Msg("Chain clock caught up with the round")
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			u.metrics.IncChainClockDelay("timeout")
+			log.Warn().
+				Uint64("round", round).
+				Uint64("round_timestamp", roundTimestamp).
+				Uint64("block_timestamp", header.Time).
+				Dur("skew", skew).
+				Dur("max_wait", u.options.ChainClockMaxWait).
+				Msg("Chain clock still behind the round, submitting anyway")
+			return nil
+		}
+		if !waited {
+			waited = true
+			log.Warn().
+				Uint64("round", round).
+				Uint64("round_timestamp", roundTimestamp).
+				Uint64("block_timestamp", header.Time).
+				Dur("skew", skew).
+				Msg("Chain clock behind the round, delaying submission")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(chainClockPollInterval):
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/store"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Vacuum compacts the local state store according to the retention policy and
+// refreshes the store size metrics
+func (u *Updater) Vacuum(ctx context.Context) ([]store.CompactionResult, error) {
+	u.vacuumMutex.Lock()
+	defer u.vacuumMutex.Unlock()
+
+	results, err := u.store.Compact(u.options.Retention, u.GetLatestOracleRound(), time.Now())
+	for _, result := range results {
+		u.metrics.AddStateStoreRemoved(result.Collection, result.Removed)
+		if result.Removed > 0 {
+			log.Info().
+				Str("collection", result.Collection).
+				Int("removed", result.Removed).
+				Int("kept", result.Kept).
+				Int64("bytes_before", result.BytesBefore).
+				Int64("bytes_after", result.BytesAfter).
+				Msg("Compacted state store collection")
+		}
+	}
+	if err != nil {
+		return results, err
+	}
+
+	u.updateStoreSizes()
+	return results, nil
+}
+
+// compactStore periodically compacts the local state store so that disk usage stays bounded
+func (u *Updater) compactStore(ctx context.Context) error {
+	if u.options.CompactionInterval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(u.options.CompactionInterval)
+	defer ticker.Stop()
+
+	u.updateStoreSizes()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := u.Vacuum(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to compact state store")
+			}
+		}
+	}
+}
+
+func (u *Updater) updateStoreSizes() {
+	sizes, err := u.store.Sizes()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get state store sizes")
+		return
+	}
+	for collection, size := range sizes {
+		u.metrics.SetStateStoreSize(collection, size)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/alerting"
+	"drand-oracle-updater/binding"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/crypto"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Security checks whose failure means the oracle's randomness cannot be trusted
+const (
+	// checkChainInfo fails when the oracle is bound to another drand network
+	// than the one served by the relays
+	checkChainInfo = "chain_info"
+	// checkRandomness fails when an oracle round's randomness is not the hash
+	// of its signature
+	checkRandomness = "randomness"
+	// checkSignature fails when an oracle round's signature does not verify
+	// against the drand network's public key
+	checkSignature = "signature"
+)
+
+// verifyRoundTimeout bounds the verification of a round set on the oracle
+const verifyRoundTimeout = 30 * time.Second
+
+// proposeTimeout bounds a pause proposal
+const proposeTimeout = time.Minute
+
+// PauseProposer proposes a call from the oracle's owner, such as a Safe
+// transaction, for the owner's signers to confirm
+type PauseProposer interface {
+	Propose(ctx context.Context, to common.Address, data []byte, origin string) (common.Hash, error)
+}
+
+// verifyOracleRound checks a round set on the oracle, by this updater or any
+// other, against the drand network. A round failing the checks was signed by
+// a compromised oracle signer, or the drand network itself is compromised.
+func (u *Updater) verifyOracleRound(event *binding.BindingRandomnessUpdated) {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyRoundTimeout)
+	defer cancel()
+
+	details := map[string]string{
+		"round":      fmt.Sprintf("%d", event.Round),
+		"randomness": hex.EncodeToString(event.Randomness[:]),
+		"signature":  hex.EncodeToString(event.Signature),
+		"tx_hash":    event.Raw.TxHash.Hex(),
+	}
+	if !bytes.Equal(crypto.RandomnessFromSignature(event.Signature), event.Randomness[:]) {
+		u.reportCompromise(ctx, checkRandomness, fmt.Sprintf("Oracle round %d randomness does not match its signature", event.Round), details)
+		return
+	}
+
+	scheme, err := crypto.SchemeFromName(u.drandInfo.Scheme)
+	if err != nil {
+		log.Warn().Err(err).Uint64("round", event.Round).Msg("Cannot verify oracle round")
+		return
+	}
+	beacon := &chain.Beacon{Round: event.Round, Signature: event.Signature}
+	if scheme.Name == crypto.DefaultSchemeID && event.Round > 1 {
+		// Chained schemes sign over the previous signature, rounds whose
+		// previous round is not on the oracle are left unverified
+		previous, err := u.binding.GetRandomnessFromRound(&bind.CallOpts{Context: ctx}, event.Round-1)
+		if err != nil {
+			log.Warn().Err(err).Uint64("round", event.Round).Msg("Failed to get previous oracle round, round left unverified")
+			return
+		}
+		if previous.Round == 0 {
+			return
+		}
+		beacon.PreviousSig = previous.Signature
+	}
+	if err := scheme.VerifyBeacon(beacon, u.drandInfo.PublicKey); err != nil {
+		details["error"] = err.Error()
+		u.reportCompromise(ctx, checkSignature, fmt.Sprintf("Oracle round %d signature is invalid for the drand network", event.Round), details)
+	}
+}
+
+// reportCompromise logs, counts and alerts a failed security check, and
+// proposes pausing the oracle when a pause proposer is configured
+func (u *Updater) reportCompromise(ctx context.Context, check string, summary string, details map[string]string) {
+	event := log.Error().Str("check", check)
+	for k, v := range details {
+		event = event.Str(k, v)
+	}
+	event.Msg(summary)
+	u.metrics.IncCompromiseDetected(check)
+
+	alertDetails := map[string]string{"check": check}
+	for k, v := range details {
+		alertDetails[k] = v
+	}
+	if u.options.PauseProposer != nil {
+		if proposal := u.proposePause(ctx, check, summary); proposal != "" {
+			alertDetails["pause_proposal"] = proposal
+		}
+	}
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionCompromise,
+		Severity:  alerting.SeverityCritical,
+		Summary:   summary,
+		Details:   alertDetails,
+	})
+}
+
+// proposePause proposes pausing the oracle, once per process, and returns the
+// proposal hash. Pausing is left to the owner's signers, so an unwarranted
+// proposal is only rejected.
+func (u *Updater) proposePause(ctx context.Context, check string, summary string) string {
+	if !u.pauseProposed.CompareAndSwap(false, true) {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, proposeTimeout)
+	defer cancel()
+
+	paused, err := u.binding.Paused(&bind.CallOpts{Context: ctx})
+	if err == nil && paused {
+		log.Info().Msg("Oracle already paused, not proposing a pause")
+		return ""
+	}
+	hash, err := u.proposePauseCall(ctx, check, summary)
+	if err != nil {
+		// Allow the next failed check to propose again
+		u.pauseProposed.Store(false)
+		u.metrics.IncPauseProposal("failure")
+		log.Error().Err(err).Str("check", check).Msg("Failed to propose pausing the oracle")
+		return ""
+	}
+	u.metrics.IncPauseProposal("success")
+	log.Warn().Str("check", check).Str("proposal", hash.Hex()).Msg("Proposed pausing the oracle")
+	return hash.Hex()
+}
+
+func (u *Updater) proposePauseCall(ctx context.Context, check string, summary string) (common.Hash, error) {
+	oracleABI, err := binding.BindingMetaData.GetAbi()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	data, err := oracleABI.Pack("pause")
+	if err != nil {
+		return common.Hash{}, err
+	}
+	origin := fmt.Sprintf("drand-oracle-updater: %s check failed: %s", check, summary)
+	return u.options.PauseProposer.Propose(ctx, u.oracleAddress, data, origin)
+}
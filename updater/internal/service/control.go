@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/retry"
+	"drand-oracle-updater/sender"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/drand/drand/client"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrRoundAlreadySet is returned when forcing the submission of a round
+	// the oracle already has
+	ErrRoundAlreadySet = errors.New("round already set on the oracle")
+
+	// ErrCatchingUp is returned when a backfill is triggered while the
+	// updater is catching up
+	ErrCatchingUp = errors.New("the updater is already catching up")
+
+	// ErrNotRunning is returned for operations on an updater not started yet
+	ErrNotRunning = errors.New("updater is not running")
+)
+
+// SubmissionsPause is the state of the operator pause of submissions
+type SubmissionsPause struct {
+	Paused bool       `json:"paused"`
+	Reason string     `json:"reason,omitempty"`
+	Since  *time.Time `json:"since,omitempty"`
+}
+
+// pauseState is the operator pause of submissions
+type pauseState struct {
+	mu     sync.Mutex
+	paused bool
+	reason string
+	since  time.Time
+	// resumed is closed when submissions are resumed
+	resumed chan struct{}
+}
+
+// Resync is the state read again from the chain and the drand network
+type Resync struct {
+	OracleRound   uint64             `json:"oracle_round"`
+	EarliestRound uint64             `json:"earliest_round"`
+	DrandRound    uint64             `json:"drand_round"`
+	SenderBalance string             `json:"sender_balance_wei"`
+	Nonces        *sender.NonceState `json:"nonces,omitempty"`
+	ResyncedAt    time.Time          `json:"resynced_at"`
+}
+
+// Backfill describes a triggered backfill
+type Backfill struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// PauseSubmissions holds the following rounds back until ResumeSubmissions.
+// The round being submitted is still mined, rounds keep being queued.
+func (u *Updater) PauseSubmissions(reason string) SubmissionsPause {
+	u.pause.mu.Lock()
+	if !u.pause.paused {
+		u.pause.paused = true
+		u.pause.reason = reason
+		u.pause.since = time.Now().UTC()
+		u.pause.resumed = make(chan struct{})
+		u.pause.mu.Unlock()
+		u.metrics.SetSubmissionsPaused(true)
+		u.recordEvent(kindSubmissionsPaused, u.GetLatestOracleRound(), "Submissions paused by an operator", map[string]string{"reason": reason})
+		log.Warn().Str("reason", reason).Msg("Submissions paused by an operator")
+	} else {
+		u.pause.mu.Unlock()
+	}
+	return u.SubmissionsPause()
+}
+
+// ResumeSubmissions resumes submissions paused by PauseSubmissions
+func (u *Updater) ResumeSubmissions() SubmissionsPause {
+	u.pause.mu.Lock()
+	if u.pause.paused {
+		pausedFor := time.Since(u.pause.since)
+		u.pause.paused = false
+		u.pause.reason = ""
+		close(u.pause.resumed)
+		u.pause.mu.Unlock()
+		u.metrics.SetSubmissionsPaused(false)
+		u.recordEvent(kindSubmissionsResumed, u.GetLatestOracleRound(), "Submissions resumed by an operator", map[string]string{
+			"paused_for": pausedFor.Round(time.Second).String(),
+		})
+		log.Info().Dur("paused_for", pausedFor).Msg("Submissions resumed by an operator")
+	} else {
+		u.pause.mu.Unlock()
+	}
+	return u.SubmissionsPause()
+}
+
+// SubmissionsPause returns the state of the operator pause of submissions
+func (u *Updater) SubmissionsPause() SubmissionsPause {
+	u.pause.mu.Lock()
+	defer u.pause.mu.Unlock()
+	pause := SubmissionsPause{Paused: u.pause.paused, Reason: u.pause.reason}
+	if u.pause.paused {
+		since := u.pause.since
+		pause.Since = &since
+	}
+	return pause
+}
+
+// waitForResume blocks while submissions are paused by an operator
+func (u *Updater) waitForResume(ctx context.Context) error {
+	u.pause.mu.Lock()
+	paused, resumed := u.pause.paused, u.pause.resumed
+	u.pause.mu.Unlock()
+	if !paused {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resumed:
+		return nil
+	}
+}
+
+// ForceSubmitRound fetches a round from the drand network and queues it in
+// the critical lane, ahead of every other round. A round ahead of the
+// oracle's next round waits in the queue for the rounds before it.
+func (u *Updater) ForceSubmitRound(ctx context.Context, round uint64) error {
+	if u.stopping.Load() {
+		return ErrStopping
+	}
+	if !u.running.Load() {
+		return ErrNotRunning
+	}
+	if round <= u.GetLatestOracleRound() {
+		return ErrRoundAlreadySet
+	}
+	result, err := retry.Value(ctx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+		return u.drandClient.Get(ctx, round)
+	})
+	if err != nil {
+		return fmt.Errorf("error getting round %d from the drand network: %w", round, err)
+	}
+	log.Info().Uint64("round", round).Msg("Round submission forced by an operator")
+	span := u.traceReceivedRound(ctx, round, LaneCritical)
+	err = u.scheduler.Push(ctx, &roundData{
+		round:      result.Round(),
+		randomness: result.Randomness(),
+		signature:  result.Signature(),
+		source:     u.observeSource(result),
+		span:       span,
+	}, LaneCritical)
+	if err != nil {
+		endSpan(span, err)
+	}
+	return err
+}
+
+// Backfill catches up on the rounds the oracle is missing up to the latest
+// drand round in the background, as on startup
+func (u *Updater) Backfill() (Backfill, error) {
+	u.stopMutex.Lock()
+	ctx := u.intakeCtx
+	u.stopMutex.Unlock()
+	if u.stopping.Load() {
+		return Backfill{}, ErrStopping
+	}
+	if ctx == nil || !u.running.Load() {
+		return Backfill{}, ErrNotRunning
+	}
+	if u.catchingUp.Load() {
+		return Backfill{}, ErrCatchingUp
+	}
+
+	u.latestDrandRoundMutex.RLock()
+	backfill := Backfill{From: u.GetLatestOracleRound() + 1, To: u.latestDrandRound}
+	u.latestDrandRoundMutex.RUnlock()
+	log.Info().Uint64("from", backfill.From).Uint64("to", backfill.To).Msg("Backfill triggered by an operator")
+	go func() {
+		if err := u.catchUp(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Backfill failed")
+		}
+	}()
+	return backfill, nil
+}
+
+// Resync reads the oracle rounds, the latest drand round, the sender balance
+// and nonces again, replacing the updater's view of them, and drops the cached
+// view calls
+func (u *Updater) Resync(ctx context.Context) (Resync, error) {
+	u.invalidateCalls()
+	resync := Resync{}
+	var err error
+	resync.EarliestRound, err = retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) (uint64, error) {
+		return u.binding.EarliestRound(&bind.CallOpts{Context: ctx})
+	})
+	if err != nil {
+		return Resync{}, fmt.Errorf("error getting earliest round from Drand Oracle contract: %w", err)
+	}
+	resync.OracleRound, err = retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) (uint64, error) {
+		return u.binding.LatestRound(&bind.CallOpts{Context: ctx})
+	})
+	if err != nil {
+		return Resync{}, fmt.Errorf("error getting latest round from Drand Oracle contract: %w", err)
+	}
+	latestDrandRound, err := retry.Value(ctx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+		return u.drandClient.Get(ctx, 0)
+	})
+	if err != nil {
+		return Resync{}, fmt.Errorf("error getting latest round from the drand network: %w", err)
+	}
+	resync.DrandRound = latestDrandRound.Round()
+	balance, err := retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) (*big.Int, error) {
+		return u.rpcClient.BalanceAt(ctx, u.sender.Address(), nil)
+	})
+	if err != nil {
+		return Resync{}, fmt.Errorf("error getting sender balance: %w", err)
+	}
+	resync.SenderBalance = balance.String()
+
+	u.latestOracleRoundMutex.Lock()
+	previousOracleRound := u.latestOracleRound
+	u.latestOracleRound = resync.OracleRound
+	u.latestOracleRoundMutex.Unlock()
+	u.metrics.SetOracleRound(float64(resync.OracleRound))
+	u.latestDrandRoundMutex.Lock()
+	u.latestDrandRound = max(u.latestDrandRound, resync.DrandRound)
+	u.lastDrandRoundAt = time.Now()
+	u.latestDrandRoundMutex.Unlock()
+	u.updateRoundLag()
+	u.senderBalanceMutex.Lock()
+	u.senderBalance = balance
+	u.senderBalanceMutex.Unlock()
+	u.metrics.SetUpdaterBalance(balance.String())
+
+	if !u.options.DryRun && u.options.UserOperations == nil {
+		state, err := u.nonces.State(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to reconcile sender nonces")
+		} else {
+			u.metrics.SetNonceGaps(len(state.Gaps))
+			resync.Nonces = &state
+		}
+	}
+	resync.ResyncedAt = time.Now().UTC()
+
+	log.Info().
+		Uint64("oracle_round", resync.OracleRound).
+		Uint64("previous_oracle_round", previousOracleRound).
+		Uint64("drand_round", resync.DrandRound).
+		Str("sender_balance", resync.SenderBalance).
+		Msg("State resynced by an operator")
+	return resync, nil
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"drand-oracle-updater/internal/accounting"
+	"io"
+	"time"
+)
+
+// Costs returns the cost of the transactions recorded in [from, to), a zero
+// bound leaving that side open, broken down by UTC day when daily is set
+func (u *Updater) Costs(from, to time.Time, daily bool) (accounting.CostReport, error) {
+	txs, err := u.store.Transactions(from, to)
+	if err != nil {
+		return accounting.CostReport{}, err
+	}
+	annotations, err := u.store.Annotations()
+	if err != nil {
+		return accounting.CostReport{}, err
+	}
+	return accounting.Summarize(txs, from, to, daily, annotations), nil
+}
+
+// Export writes the transactions recorded in [from, to) to w, as the export
+// command does, with the annotations covering them
+func (u *Updater) Export(w io.Writer, from, to time.Time, opts accounting.Options) error {
+	txs, err := u.store.Transactions(from, to)
+	if err != nil {
+		return err
+	}
+	opts.Annotations, err = u.store.Annotations()
+	if err != nil {
+		return err
+	}
+	return accounting.Export(w, txs, opts)
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxDebugWindow bounds the duration of a debug logging window
+const MaxDebugWindow = 24 * time.Hour
+
+var (
+	// ErrInvalidDebugWindow is returned for a debug window without a
+	// duration nor a number of rounds, or longer than MaxDebugWindow
+	ErrInvalidDebugWindow = fmt.Errorf("a debug window lasts up to %s or a number of rounds", MaxDebugWindow)
+	// ErrNoDebugWindow is returned when ending a debug window while none is open
+	ErrNoDebugWindow = errors.New("no debug window is open")
+)
+
+// DebugWindow is a window of debug logging, ending on its deadline or after
+// its number of rounds, whichever comes first
+type DebugWindow struct {
+	Active    bool       `json:"active"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// Until is the deadline of the window, nil when only rounds end it
+	Until *time.Time `json:"until,omitempty"`
+	// Rounds is the number of rounds the window lasts, 0 when only its
+	// deadline ends it. The rounds processed by Pipeline count.
+	Rounds          uint64 `json:"rounds,omitempty"`
+	RemainingRounds uint64 `json:"remaining_rounds,omitempty"`
+	Pipeline        string `json:"pipeline,omitempty"`
+	// PreviousLevel is the log level restored when the window ends
+	PreviousLevel string `json:"previous_level,omitempty"`
+}
+
+// debugWindow is the open debug window. The log level is process wide, so is
+// the window, opened and counted by one pipeline.
+var debugWindow struct {
+	sync.Mutex
+	window   DebugWindow
+	owner    *Updater
+	previous zerolog.Level
+	timer    *time.Timer
+	// seq tells the deadline of the open window from those of the windows it
+	// replaced
+	seq uint64
+}
+
+// StartDebugWindow lowers the log level to debug for duration or the next
+// rounds processed by the updater, whichever comes first, then restores it.
+// A window already open is replaced, keeping the level it restores.
+func (u *Updater) StartDebugWindow(duration time.Duration, rounds uint64) (DebugWindow, error) {
+	if duration < 0 || duration > MaxDebugWindow || (duration == 0 && rounds == 0) {
+		return DebugWindow{}, ErrInvalidDebugWindow
+	}
+
+	debugWindow.Lock()
+	defer debugWindow.Unlock()
+	if !debugWindow.window.Active {
+		debugWindow.previous = zerolog.GlobalLevel()
+	}
+	if debugWindow.timer != nil {
+		debugWindow.timer.Stop()
+		debugWindow.timer = nil
+	}
+	debugWindow.seq++
+	now := time.Now().UTC()
+	window := DebugWindow{
+		Active:          true,
+		StartedAt:       &now,
+		Rounds:          rounds,
+		RemainingRounds: rounds,
+		Pipeline:        u.options.Pipeline,
+		PreviousLevel:   debugWindow.previous.String(),
+	}
+	if duration > 0 {
+		until := now.Add(duration)
+		window.Until = &until
+		seq := debugWindow.seq
+		debugWindow.timer = time.AfterFunc(duration, func() {
+			debugWindow.Lock()
+			defer debugWindow.Unlock()
+			if debugWindow.seq == seq && debugWindow.window.Active {
+				endDebugWindowLocked("deadline reached")
+			}
+		})
+	}
+	debugWindow.window = window
+	debugWindow.owner = u
+	// A trace level already logs more than debug
+	zerolog.SetGlobalLevel(min(debugWindow.previous, zerolog.DebugLevel))
+
+	log.Warn().
+		Dur("duration", duration).
+		Uint64("rounds", rounds).
+		Str("previous", window.PreviousLevel).
+		Msg("Debug logging window started")
+	u.recordEvent(kindDebugWindowStarted, u.GetLatestOracleRound(), "Debug logging window started", debugWindowDetails(window))
+	return window, nil
+}
+
+// DebugWindow returns the open debug window, inactive when none is open
+func (u *Updater) DebugWindow() DebugWindow {
+	debugWindow.Lock()
+	defer debugWindow.Unlock()
+	return debugWindow.window
+}
+
+// EndDebugWindow ends the open debug window early and restores the log level
+func (u *Updater) EndDebugWindow() (DebugWindow, error) {
+	debugWindow.Lock()
+	defer debugWindow.Unlock()
+	if !debugWindow.window.Active {
+		return DebugWindow{}, ErrNoDebugWindow
+	}
+	window := debugWindow.window
+	endDebugWindowLocked("ended by an operator")
+	window.Active = false
+	return window, nil
+}
+
+// debugRoundsProcessed counts rounds processed by the updater against the
+// open debug window, ending it after its last round
+func (u *Updater) debugRoundsProcessed(rounds int) {
+	debugWindow.Lock()
+	defer debugWindow.Unlock()
+	window := &debugWindow.window
+	if !window.Active || debugWindow.owner != u || window.Rounds == 0 {
+		return
+	}
+	window.RemainingRounds -= min(window.RemainingRounds, uint64(rounds))
+	if window.RemainingRounds == 0 {
+		endDebugWindowLocked("rounds processed")
+	}
+}
+
+// endDebugWindowLocked closes the open debug window. The previous log level
+// is restored unless the level was changed during the window.
+func endDebugWindowLocked(reason string) {
+	if debugWindow.timer != nil {
+		debugWindow.timer.Stop()
+		debugWindow.timer = nil
+	}
+	window := debugWindow.window
+	owner := debugWindow.owner
+	debugWindow.window = DebugWindow{}
+	debugWindow.owner = nil
+
+	restored := zerolog.GlobalLevel() == min(debugWindow.previous, zerolog.DebugLevel)
+	if restored {
+		zerolog.SetGlobalLevel(debugWindow.previous)
+	}
+	log.Warn().
+		Str("reason", reason).
+		Bool("restored", restored).
+		Str("level", zerolog.GlobalLevel().String()).
+		Msg("Debug logging window ended")
+	details := debugWindowDetails(window)
+	details["reason"] = reason
+	owner.recordEvent(kindDebugWindowEnded, owner.GetLatestOracleRound(), "Debug logging window ended", details)
+}
+
+func debugWindowDetails(window DebugWindow) map[string]string {
+	details := map[string]string{"previous_level": window.PreviousLevel}
+	if window.Until != nil {
+		details["until"] = window.Until.Format(time.RFC3339)
+	}
+	if window.Rounds > 0 {
+		details["rounds"] = fmt.Sprintf("%d", window.Rounds)
+	}
+	return details
+}
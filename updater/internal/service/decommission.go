@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/sender"
+	"drand-oracle-updater/signer"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// pendingCheckInterval is the interval between checks of the sender's
+// pending transactions while waiting for them to be mined
+const pendingCheckInterval = 2 * time.Second
+
+// SignerRevocation is the outcome of revoking the updater's signer on the oracle
+type SignerRevocation struct {
+	Oracle string `json:"oracle"`
+	Signer string `json:"signer"`
+	// Revoked is set once the oracle's signer was handed over to BurnAddress,
+	// an address whose key was discarded
+	Revoked     bool   `json:"revoked"`
+	BurnAddress string `json:"burn_address,omitempty"`
+	TxHash      string `json:"tx_hash,omitempty"`
+	// Skipped is why the signer was not revoked
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Sweep is the outcome of sweeping the sender's balance to a treasury
+type Sweep struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	BalanceWei string `json:"balance_wei"`
+	AmountWei  string `json:"amount_wei"`
+	FeeWei     string `json:"fee_wei"`
+	TxHash     string `json:"tx_hash,omitempty"`
+	// Skipped is why the balance was not swept
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// WaitPendingTransactions waits for the sender's pending transactions to be
+// mined, and returns the sender's nonce state once none is pending
+func (u *Updater) WaitPendingTransactions(ctx context.Context) (sender.NonceState, error) {
+	for {
+		state, err := u.nonces.State(ctx)
+		if err != nil {
+			return sender.NonceState{}, fmt.Errorf("error getting sender nonces: %w", err)
+		}
+		if state.Pending <= state.Latest {
+			return state, nil
+		}
+		log.Info().
+			Uint64("pending", state.Pending).
+			Uint64("latest", state.Latest).
+			Msg("Waiting for the sender's pending transactions to be mined")
+		select {
+		case <-ctx.Done():
+			return sender.NonceState{}, ctx.Err()
+		case <-time.After(pendingCheckInterval):
+		}
+	}
+}
+
+// RevokeSigner hands the oracle over to an address whose key is discarded, so
+// that nobody can set rounds with the updater's signer anymore. It is skipped
+// when the oracle's signer is not the updater's, or the updater's signer is
+// not a local key. Without execute, it only reports what it would do.
+func (u *Updater) RevokeSigner(ctx context.Context, execute bool) (SignerRevocation, error) {
+	current := u.signer.Current()
+	revocation := SignerRevocation{Oracle: u.oracleAddress.Hex(), Signer: current.Address().Hex()}
+
+	u.invalidateCalls(methodSigner)
+	oracleSigner, err := u.binding.Signer(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return revocation, fmt.Errorf("error getting signer from Drand Oracle contract: %w", err)
+	}
+	if oracleSigner != current.Address() {
+		revocation.Skipped = fmt.Sprintf("the oracle's signer is %s", oracleSigner.Hex())
+		return revocation, nil
+	}
+	local, ok := current.(*signer.Signer)
+	if !ok {
+		revocation.Skipped = "a remote or threshold signer cannot sign setSigner, call setSigner on the oracle"
+		return revocation, nil
+	}
+	paused, err := u.binding.Paused(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return revocation, fmt.Errorf("error getting pause state from Drand Oracle contract: %w", err)
+	}
+	if paused {
+		revocation.Skipped = "the oracle is paused, setSigner is refused while paused"
+		return revocation, nil
+	}
+	if !execute {
+		revocation.Skipped = "not executed"
+		return revocation, nil
+	}
+
+	burnKey, err := crypto.GenerateKey()
+	if err != nil {
+		return revocation, err
+	}
+	burnAddress := crypto.PubkeyToAddress(burnKey.PublicKey)
+	tx, err := u.setSigner(ctx, local, burnAddress)
+	if err != nil {
+		return revocation, err
+	}
+	revocation.Revoked = true
+	revocation.BurnAddress = burnAddress.Hex()
+	revocation.TxHash = tx.Hash().Hex()
+	log.Warn().
+		Str("oracle", revocation.Oracle).
+		Str("signer", revocation.Signer).
+		Str("burn_address", revocation.BurnAddress).
+		Msg("Oracle signer revoked")
+	return revocation, nil
+}
+
+// SweepSender sends the sender's balance, less the transfer fee and keep, to
+// treasury. Without execute, it only reports what it would do.
+func (u *Updater) SweepSender(ctx context.Context, treasury common.Address, keep *big.Int, execute bool) (Sweep, error) {
+	from := u.sender.Address()
+	sweep := Sweep{From: from.Hex(), To: treasury.Hex(), BalanceWei: "0", AmountWei: "0", FeeWei: "0"}
+	if u.options.UserOperations != nil {
+		sweep.Skipped = "the sender is a smart account, sweep it through the account"
+		return sweep, nil
+	}
+	balance, err := u.rpcClient.BalanceAt(ctx, from, nil)
+	if err != nil {
+		return sweep, fmt.Errorf("error getting sender balance: %w", err)
+	}
+	sweep.BalanceWei = balance.String()
+
+	gas, err := u.rpcClient.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &treasury, Value: big.NewInt(1)})
+	if err != nil {
+		return sweep, fmt.Errorf("error estimating gas: %w", err)
+	}
+	gasPrice, err := u.options.GasStrategy.GasPrice(ctx)
+	if err != nil {
+		return sweep, fmt.Errorf("error getting gas price: %w", err)
+	}
+	fee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gas))
+	sweep.FeeWei = fee.String()
+	amount := new(big.Int).Sub(balance, fee)
+	if keep != nil {
+		amount.Sub(amount, keep)
+	}
+	if amount.Sign() <= 0 {
+		sweep.Skipped = "the balance does not cover the transfer fee and the kept amount"
+		return sweep, nil
+	}
+	sweep.AmountWei = amount.String()
+	if !execute {
+		sweep.Skipped = "not executed"
+		return sweep, nil
+	}
+
+	nonce, err := u.nonces.Next(ctx)
+	if err != nil {
+		return sweep, fmt.Errorf("error getting sender nonce: %w", err)
+	}
+	tx, err := u.sender.SignerFn()(from, types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &treasury,
+		Value:    amount,
+		Gas:      gas,
+		GasPrice: gasPrice,
+	}))
+	if err != nil {
+		u.nonces.Release(nonce)
+		return sweep, fmt.Errorf("error signing sweep transaction: %w", err)
+	}
+	if err := u.broadcast(ctx, tx); err != nil {
+		u.nonces.Release(nonce)
+		return sweep, fmt.Errorf("error broadcasting sweep transaction: %w", err)
+	}
+	u.nonces.Sent(tx)
+	sweep.TxHash = tx.Hash().Hex()
+	log.Info().Str("hash", sweep.TxHash).Str("amount_wei", sweep.AmountWei).Str("treasury", sweep.To).Msg("Sweep transaction sent")
+	receipt, err := u.waitMined(ctx, tx)
+	if err != nil {
+		return sweep, fmt.Errorf("error waiting for sweep transaction: %w", err)
+	}
+	u.nonces.Confirm(nonce)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return sweep, errors.New("sweep transaction failed")
+	}
+	sweep.FeeWei = transactionFee(tx, receipt).String()
+	return sweep, nil
+}
+
+// RecordDecommission records the decommissioning of the pipeline at its
+// latest oracle round on the timeline
+func (u *Updater) RecordDecommission(round uint64, details map[string]string) {
+	u.recordEvent(kindDecommissioned, round, "Deployment decommissioned", details)
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"drand-oracle-updater/internal/drandpool"
+	"drand-oracle-updater/internal/rpcpool"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DependencyState is the health state of an external dependency
+type DependencyState string
+
+const (
+	// DependencyHealthy dependencies answer as expected
+	DependencyHealthy DependencyState = "healthy"
+	// DependencyDegraded dependencies answer, slowly or after recent failures
+	DependencyDegraded DependencyState = "degraded"
+	// DependencyDown dependencies failed their latest use or probe
+	DependencyDown DependencyState = "down"
+	// DependencyUnknown dependencies were not used nor probed yet
+	DependencyUnknown DependencyState = "unknown"
+)
+
+// Kinds of dependencies
+const (
+	dependencyDrandRelay  = "drand_relay"
+	dependencyRPCEndpoint = "rpc_endpoint"
+	dependencyStateStore  = "state_store"
+	dependencyKey         = "key_provider"
+)
+
+// Groups of redundant dependencies
+const (
+	groupDrandRelays  = "drand_relays"
+	groupRPCEndpoints = "rpc_endpoints"
+)
+
+// RPCEndpoints reports the health of the RPC endpoints
+type RPCEndpoints interface {
+	Status() []rpcpool.EndpointStatus
+}
+
+// Dependency is an external dependency of the updater and its health
+type Dependency struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Group gathers redundant dependencies, of which one must not be down
+	Group string `json:"group,omitempty"`
+	// Required dependencies must not be down for the updater to be ready
+	Required  bool            `json:"required"`
+	State     DependencyState `json:"state"`
+	LastError string          `json:"last_error,omitempty"`
+	// CheckedAt is the time of the latest use or probe, when known
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+// DependencyMatrix is the health of every external dependency. The updater
+// is ready when no required dependency is down and every group has a
+// dependency up.
+type DependencyMatrix struct {
+	Ready bool `json:"ready"`
+	// Reasons explain why the updater is not ready
+	Reasons      []string     `json:"reasons,omitempty"`
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// dependencyProbes are the outcomes of the latest probes of the dependencies
+// that do not track their own health, by name
+type dependencyProbes struct {
+	mu      sync.Mutex
+	results map[string]probeResult
+}
+
+type probeResult struct {
+	at  time.Time
+	err error
+}
+
+func (p *dependencyProbes) observe(name string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.results == nil {
+		p.results = make(map[string]probeResult)
+	}
+	p.results[name] = probeResult{at: time.Now(), err: err}
+}
+
+func (p *dependencyProbes) dependency(name, kind string) Dependency {
+	p.mu.Lock()
+	result, ok := p.results[name]
+	p.mu.Unlock()
+	if !ok {
+		return Dependency{Name: name, Kind: kind, Required: true, State: DependencyUnknown}
+	}
+	return usedDependency(name, kind, result.at, result.err)
+}
+
+// usedDependency returns a required dependency whose health is the outcome
+// of its latest use or probe at, a zero time if it was never used
+func usedDependency(name, kind string, at time.Time, err error) Dependency {
+	dependency := Dependency{Name: name, Kind: kind, Required: true, State: DependencyUnknown}
+	if at.IsZero() {
+		return dependency
+	}
+	dependency.CheckedAt = &at
+	dependency.State = DependencyHealthy
+	if err != nil {
+		dependency.State = DependencyDown
+		dependency.LastError = err.Error()
+	}
+	return dependency
+}
+
+// Dependencies returns the health of the drand relays, the RPC endpoints, the
+// state store and the key providers, as last observed. It does not call them.
+func (u *Updater) Dependencies() DependencyMatrix {
+	var dependencies []Dependency
+	dependencies = append(dependencies, u.relayDependencies()...)
+	dependencies = append(dependencies, u.rpcDependencies()...)
+	dependencies = append(dependencies, u.probes.dependency("state_store", dependencyStateStore))
+	at, err := u.sender.LastSign()
+	dependencies = append(dependencies, usedDependency("sender_key", dependencyKey, at, err))
+	at, err = u.signer.LastSign()
+	dependencies = append(dependencies, usedDependency("signer", dependencyKey, at, err))
+	return newDependencyMatrix(dependencies)
+}
+
+// relayDependencies returns the drand relays, required when there is only one
+func (u *Updater) relayDependencies() []Dependency {
+	if u.options.DrandRelays == nil {
+		return []Dependency{u.probes.dependency("drand", dependencyDrandRelay)}
+	}
+	relays := u.options.DrandRelays.Status()
+	dependencies := make([]Dependency, len(relays))
+	for i, relay := range relays {
+		state := DependencyHealthy
+		switch relay.State {
+		case drandpool.StateDemoted:
+			state = DependencyDegraded
+		case drandpool.StatePruned:
+			state = DependencyDown
+		}
+		dependencies[i] = Dependency{
+			Name:      relay.Relay,
+			Kind:      dependencyDrandRelay,
+			Group:     groupDrandRelays,
+			Required:  len(relays) == 1,
+			State:     state,
+			LastError: relay.LastError,
+		}
+	}
+	return dependencies
+}
+
+// rpcDependencies returns the RPC endpoints, required when there is only one
+func (u *Updater) rpcDependencies() []Dependency {
+	if u.options.RPCEndpoints == nil {
+		return []Dependency{u.probes.dependency("rpc", dependencyRPCEndpoint)}
+	}
+	endpoints := u.options.RPCEndpoints.Status()
+	dependencies := make([]Dependency, len(endpoints))
+	for i, endpoint := range endpoints {
+		state := DependencyHealthy
+		switch {
+		case !endpoint.Healthy:
+			state = DependencyDown
+		case endpoint.Failures > 0 || endpoint.Score < 0.5:
+			state = DependencyDegraded
+		}
+		dependencies[i] = Dependency{
+			Name:      endpoint.Endpoint,
+			Kind:      dependencyRPCEndpoint,
+			Group:     groupRPCEndpoints,
+			Required:  len(endpoints) == 1,
+			State:     state,
+			LastError: endpoint.LastError,
+		}
+	}
+	return dependencies
+}
+
+// newDependencyMatrix computes the readiness of dependencies
+func newDependencyMatrix(dependencies []Dependency) DependencyMatrix {
+	matrix := DependencyMatrix{Ready: true, Dependencies: dependencies}
+	var groups []string
+	up := make(map[string]bool)
+	for _, dependency := range dependencies {
+		if dependency.Group != "" {
+			if _, ok := up[dependency.Group]; !ok {
+				groups = append(groups, dependency.Group)
+			}
+			up[dependency.Group] = up[dependency.Group] || dependency.State != DependencyDown
+		}
+		if dependency.Required && dependency.State == DependencyDown {
+			matrix.Ready = false
+			matrix.Reasons = append(matrix.Reasons, fmt.Sprintf("%s %s is down: %s", dependency.Kind, dependency.Name, dependency.LastError))
+		}
+	}
+	for _, group := range groups {
+		if !up[group] {
+			matrix.Ready = false
+			matrix.Reasons = append(matrix.Reasons, fmt.Sprintf("all %s are down", group))
+		}
+	}
+	return matrix
+}
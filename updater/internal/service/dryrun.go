@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rs/zerolog/log"
+)
+
+// simulateSetRandomness builds the setRandomness transaction the updater would
+// send and simulates it with eth_call and eth_estimateGas without broadcasting.
+//
+// The contract only accepts the round following its latest round, so only that
+// round can be simulated against the actual chain state. Later rounds, which the
+// dry run pipeline reaches because nothing is written on-chain, are priced with
+// the last gas estimate instead.
+func (u *Updater) simulateSetRandomness(
+	ctx context.Context,
+	random binding.IDrandOracleRandom,
+	signature []byte,
+	gasPrice *big.Int,
+) error {
+	msg, err := u.setRandomnessMsg(random, signature, gasPrice)
+	if err != nil {
+		return err
+	}
+
+	onChainRound, err := u.binding.LatestRound(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get latest round from Drand Oracle contract")
+		return err
+	}
+
+	logger := log.Info().
+		Bool("dry_run", true).
+		Uint64("round", random.Round).
+		Str("to", u.oracleAddress.Hex()).
+		Str("from", u.sender.Address().Hex()).
+		Str("calldata", "0x"+hex.EncodeToString(msg.Data)).
+		Str("gas_price", gasPrice.String())
+
+	if onChainRound != 0 && random.Round != onChainRound+1 {
+		if u.dryRunGasEstimate == 0 {
+			logger.Uint64("on_chain_round", onChainRound).
+				Msg("Dry run: round is not next on-chain, skipping simulation")
+			return nil
+		}
+		logger.
+			Uint64("on_chain_round", onChainRound).
+			Uint64("estimated_gas", u.dryRunGasEstimate).
+			Str("estimated_cost", estimatedCost(u.dryRunGasEstimate, gasPrice, u.options.SubmissionFee).String()).
+			Msg("Dry run: round is not next on-chain, priced with the last gas estimate")
+		return nil
+	}
+
+	if _, err := u.rpcClient.CallContract(ctx, msg, nil); err != nil {
+		log.Error().Err(err).Uint64("round", random.Round).Msg("Dry run: setRandomness simulation reverted")
+		return err
+	}
+
+	gasEstimate, err := u.estimateSetRandomnessGas(ctx, random, signature, gasPrice)
+	if err != nil {
+		log.Error().Err(err).Uint64("round", random.Round).Msg("Dry run: failed to estimate gas")
+		return err
+	}
+	u.dryRunGasEstimate = gasEstimate
+
+	if gasEstimate > u.setRandomnessGasLimit {
+		log.Warn().
+			Uint64("estimated_gas", gasEstimate).
+			Uint64("gas_limit", u.setRandomnessGasLimit).
+			Msg("Dry run: estimated gas exceeds the configured gas limit")
+	}
+
+	logger.
+		Uint64("estimated_gas", gasEstimate).
+		Str("estimated_cost", estimatedCost(gasEstimate, gasPrice, u.options.SubmissionFee).String()).
+		Msg("Dry run: setRandomness simulation succeeded, not broadcasting")
+	return nil
+}
+
+// estimatedCost returns gas * gasPrice + value in wei
+func estimatedCost(gas uint64, gasPrice *big.Int, value *big.Int) *big.Int {
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gas), gasPrice)
+	if value != nil {
+		cost.Add(cost, value)
+	}
+	return cost
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/retry"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+// feeABI is the view function oracles charging a per-submission fee advertise it with
+const feeABI = `[{"type":"function","name":"fee","inputs":[],"outputs":[{"name":"","type":"uint256"}],"stateMutability":"view"}]`
+
+// ErrSubmissionFeeTooLow is returned when the configured submission fee is below the contract's advertised fee
+var ErrSubmissionFeeTooLow = errors.New("configured submission fee is below the contract's advertised fee")
+
+// advertisedFee reads the per-submission fee from the oracle contract. It
+// returns nil if the contract does not advertise a fee, i.e. the call reverts
+// or returns no data. Transport failures are retried, then returned.
+func (u *Updater) advertisedFee(ctx context.Context) (*big.Int, error) {
+	parsed, err := abi.JSON(strings.NewReader(feeABI))
+	if err != nil {
+		return nil, err
+	}
+	data, err := parsed.Pack("fee")
+	if err != nil {
+		return nil, err
+	}
+	oracle := u.oracleAddress
+	out, err := retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) ([]byte, error) {
+		out, err := u.rpcClient.CallContract(ctx, ethereum.CallMsg{To: &oracle, Data: data}, nil)
+		if err != nil && isExecutionRevert(err) {
+			return nil, retry.Permanent(err)
+		}
+		return out, err
+	})
+	if err != nil && !isExecutionRevert(err) {
+		return nil, fmt.Errorf("error reading the advertised submission fee: %w", err)
+	}
+	if err != nil || len(out) == 0 {
+		log.Debug().Err(err).Msg("Drand Oracle contract does not advertise a submission fee")
+		return nil, nil
+	}
+	values, err := parsed.Unpack("fee", out)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the advertised submission fee: %w", err)
+	}
+	return abi.ConvertType(values[0], new(big.Int)).(*big.Int), nil
+}
+
+// isExecutionRevert reports whether a call failed because its execution
+// reverted, as opposed to a transport failure
+func isExecutionRevert(err error) bool {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		return true
+	}
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && strings.Contains(strings.ToLower(rpcErr.Error()), "revert")
+}
+
+// validateSubmissionFee checks the configured msg.value against the contract's
+// advertised fee so that a misconfiguration does not cause systematic reverts
+func (u *Updater) validateSubmissionFee(ctx context.Context) error {
+	fee, err := u.advertisedFee(ctx)
+	if err != nil {
+		return err
+	}
+	// An unset submission fee sends no value
+	submissionFee := new(big.Int)
+	if u.options.SubmissionFee != nil {
+		submissionFee.Set(u.options.SubmissionFee)
+	}
+
+	if fee == nil {
+		if submissionFee.Sign() > 0 {
+			log.Warn().
+				Str("submission_fee", submissionFee.String()).
+				Msg("Submission fee is configured but the Drand Oracle contract does not advertise one")
+		}
+		return nil
+	}
+
+	log.Info().
+		Str("advertised_fee", fee.String()).
+		Str("submission_fee", submissionFee.String()).
+		Msg("Drand Oracle contract charges a submission fee")
+
+	switch submissionFee.Cmp(fee) {
+	case -1:
+		return ErrSubmissionFeeTooLow
+	case 1:
+		log.Warn().
+			Str("advertised_fee", fee.String()).
+			Str("submission_fee", submissionFee.String()).
+			Msg("Configured submission fee is above the contract's advertised fee")
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/chaintest"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// feeOracle returns the runtime code of a contract returning fee, at most
+// 255 wei, as a uint256 on every call
+func feeOracle(fee byte) []byte {
+	return []byte{
+		0x60, fee, 0x60, 0x00, 0x52, // MSTORE(0, fee)
+		0x60, 0x20, 0x60, 0x00, 0xf3, // RETURN(0, 32)
+	}
+}
+
+func TestValidateSubmissionFee(t *testing.T) {
+	tests := []struct {
+		name string
+		// advertised is the fee of the oracle, nil for an oracle advertising none
+		advertised    []byte
+		submissionFee *big.Int
+		wantErr       error
+	}{
+		{"no fee unset", nil, nil, nil},
+		{"no fee advertised", nil, big.NewInt(10), nil},
+		{"unset below advertised", feeOracle(100), nil, ErrSubmissionFeeTooLow},
+		{"below advertised", feeOracle(100), big.NewInt(99), ErrSubmissionFeeTooLow},
+		{"matching", feeOracle(100), big.NewInt(100), nil},
+		{"above advertised", feeOracle(100), big.NewInt(101), nil},
+		{"unset with zero fee", feeOracle(0), nil, nil},
+	}
+	b := chaintest.New(t, chaintest.EVM, nil)
+	u := newTestUpdater(t, b, newTestDrand(t, 1), Options{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u.oracleAddress = deployContract(t, b, stubOracle)
+			if tt.advertised != nil {
+				u.oracleAddress = deployContract(t, b, tt.advertised)
+			}
+			u.options.SubmissionFee = tt.submissionFee
+			if err := u.validateSubmissionFee(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateSubmissionFee() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
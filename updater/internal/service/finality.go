@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/finality"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Results of following a submission until its block is committed, the result
+// label of the confirmation metric
+const (
+	confirmationCommitted  = "committed"
+	confirmationReincluded = "reincluded"
+	confirmationReorged    = "reorged"
+)
+
+// pendingConfirmation is a mined submission whose block is not committed yet
+type pendingConfirmation struct {
+	firstRound  uint64
+	lastRound   uint64
+	txHash      common.Hash
+	blockNumber uint64
+	blockHash   common.Hash
+}
+
+// Finality is the oracle state at the latest block the chain considers
+// committed, which a reorg cannot roll back
+type Finality struct {
+	Mode  finality.Mode `json:"mode"`
+	Depth uint64        `json:"depth,omitempty"`
+	// Fallback is set while the RPC does not resolve the block tag and the
+	// committed block is resolved by depth
+	Fallback       bool      `json:"fallback,omitempty"`
+	CommittedBlock uint64    `json:"committed_block"`
+	CommittedRound uint64    `json:"committed_round"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Finality returns the oracle state at the committed block, nil when the
+// latest block is considered committed
+func (u *Updater) Finality() *Finality {
+	if !u.tracksFinality() {
+		return nil
+	}
+	u.committedMutex.RLock()
+	defer u.committedMutex.RUnlock()
+	committed := u.committed
+	return &committed
+}
+
+// committedBlock reports whether a block is committed, every block being
+// committed when the latest block is considered committed
+func (u *Updater) committedBlock(block uint64) bool {
+	if !u.tracksFinality() {
+		return true
+	}
+	u.committedMutex.RLock()
+	defer u.committedMutex.RUnlock()
+	return block <= u.committed.CommittedBlock
+}
+
+func (u *Updater) tracksFinality() bool {
+	mode := u.finality.Policy().Mode
+	return mode != "" && mode != finality.ModeLatest
+}
+
+// trackCommitted follows the oracle round at the committed block, every
+// events poll interval or less often while hibernating
+func (u *Updater) trackCommitted(ctx context.Context) error {
+	if !u.tracksFinality() {
+		return nil
+	}
+	for {
+		if err := u.updateCommitted(ctx); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("Failed to get the committed oracle round")
+		} else if err == nil {
+			u.confirmSubmissions(ctx)
+		}
+		if err := u.idleWait(ctx, u.options.EventsPollInterval); err != nil {
+			return nil
+		}
+	}
+}
+
+// updateCommitted reads the oracle round at the committed block
+func (u *Updater) updateCommitted(ctx context.Context) error {
+	block, err := u.finality.CommittedBlock(ctx)
+	if err != nil {
+		return err
+	}
+	u.committedMutex.RLock()
+	previous := u.committed
+	u.committedMutex.RUnlock()
+	if block == previous.CommittedBlock && !previous.UpdatedAt.IsZero() {
+		return nil
+	}
+	round, err := u.binding.LatestRound(&bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(block)})
+	if err != nil {
+		return err
+	}
+
+	policy := u.finality.Policy()
+	committed := Finality{
+		Mode:           policy.Mode,
+		Depth:          policy.Depth,
+		Fallback:       u.finality.Fallback(),
+		CommittedBlock: block,
+		CommittedRound: round,
+		UpdatedAt:      time.Now().UTC(),
+	}
+	u.committedMutex.Lock()
+	u.committed = committed
+	u.committedMutex.Unlock()
+	u.metrics.SetCommitted(block, round)
+	if round != previous.CommittedRound {
+		log.Debug().Uint64("block", block).Uint64("round", round).Msg("Committed oracle round advanced")
+	}
+	return nil
+}
+
+// CommittedOracleRound returns the latest oracle round at the committed block,
+// the latest oracle round when the latest block is considered committed
+func (u *Updater) CommittedOracleRound() uint64 {
+	if !u.tracksFinality() {
+		return u.GetLatestOracleRound()
+	}
+	u.committedMutex.RLock()
+	defer u.committedMutex.RUnlock()
+	return u.committed.CommittedRound
+}
+
+// committedRound reports whether a round is set at the committed block
+func (u *Updater) committedRound(round uint64) bool {
+	return round <= u.CommittedOracleRound()
+}
+
+// trackConfirmation follows a successful submission of rounds first to last
+// until its block is committed
+func (u *Updater) trackConfirmation(first, last uint64, receipt *types.Receipt) {
+	if !u.options.ConfirmCommitted || !u.tracksFinality() || receipt.Status != types.ReceiptStatusSuccessful {
+		return
+	}
+	u.confirmationsMutex.Lock()
+	defer u.confirmationsMutex.Unlock()
+	u.confirmations = append(u.confirmations, pendingConfirmation{
+		firstRound:  first,
+		lastRound:   last,
+		txHash:      receipt.TxHash,
+		blockNumber: receipt.BlockNumber.Uint64(),
+		blockHash:   receipt.BlockHash,
+	})
+}
+
+// confirmSubmissions checks the submissions mined in committed blocks against
+// the chain. A submission moved to another block by a reorg is followed until
+// that block is committed, and one reorged out is caught up again.
+func (u *Updater) confirmSubmissions(ctx context.Context) {
+	u.committedMutex.RLock()
+	committedBlock := u.committed.CommittedBlock
+	u.committedMutex.RUnlock()
+
+	u.confirmationsMutex.Lock()
+	pending := u.confirmations
+	u.confirmationsMutex.Unlock()
+
+	var kept []pendingConfirmation
+	reorged := false
+	for _, confirmation := range pending {
+		if confirmation.blockNumber > committedBlock {
+			kept = append(kept, confirmation)
+			continue
+		}
+		receipt, err := u.rpcClient.TransactionReceipt(ctx, confirmation.txHash)
+		switch {
+		case err != nil && !errors.Is(err, ethereum.NotFound):
+			log.Warn().Err(err).Str("hash", confirmation.txHash.Hex()).Msg("Failed to get submission receipt, checking it again")
+			kept = append(kept, confirmation)
+		case err != nil || receipt.Status != types.ReceiptStatusSuccessful:
+			u.submissionReorged(confirmation)
+			reorged = true
+		case receipt.BlockHash != confirmation.blockHash:
+			u.metrics.IncConfirmation(confirmationReincluded)
+			log.Warn().
+				Str("hash", confirmation.txHash.Hex()).
+				Uint64("block", confirmation.blockNumber).
+				Uint64("new_block", receipt.BlockNumber.Uint64()).
+				Msg("Submission moved to another block by a reorg")
+			confirmation.blockNumber = receipt.BlockNumber.Uint64()
+			confirmation.blockHash = receipt.BlockHash
+			kept = append(kept, confirmation)
+		default:
+			u.metrics.IncConfirmation(confirmationCommitted)
+			log.Debug().
+				Str("hash", confirmation.txHash.Hex()).
+				Uint64("block", confirmation.blockNumber).
+				Msg("Submission committed")
+		}
+	}
+
+	// Submissions mined while checking were appended after the pending ones
+	u.confirmationsMutex.Lock()
+	u.confirmations = append(kept, u.confirmations[len(pending):]...)
+	u.confirmationsMutex.Unlock()
+
+	if reorged {
+		u.rewindOracleRound(ctx)
+	}
+}
+
+// submissionReorged records a submission reorged out of the chain
+func (u *Updater) submissionReorged(confirmation pendingConfirmation) {
+	u.metrics.IncConfirmation(confirmationReorged)
+	log.Error().
+		Uint64("from_round", confirmation.firstRound).
+		Uint64("to_round", confirmation.lastRound).
+		Str("hash", confirmation.txHash.Hex()).
+		Uint64("block", confirmation.blockNumber).
+		Msg("Submission reorged out of the chain, catching up again")
+	u.recordEvent(kindSubmissionReorged, confirmation.firstRound, fmt.Sprintf("Submission of round %d reorged out", confirmation.firstRound), map[string]string{
+		"to_round":     fmt.Sprintf("%d", confirmation.lastRound),
+		"tx_hash":      confirmation.txHash.Hex(),
+		"block_number": fmt.Sprintf("%d", confirmation.blockNumber),
+	})
+}
+
+// rewindOracleRound reads the latest oracle round again after a reorg, which
+// may have lowered it, and catches up from there
+func (u *Updater) rewindOracleRound(ctx context.Context) {
+	u.invalidateCalls(methodLatestRound, methodEarliestRound)
+	latestRound, err := u.binding.LatestRound(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get latest round from Drand Oracle contract after a reorg")
+		return
+	}
+	u.latestOracleRoundMutex.Lock()
+	previous := u.latestOracleRound
+	u.latestOracleRound = latestRound
+	u.latestOracleRoundMutex.Unlock()
+	u.metrics.SetOracleRound(float64(latestRound))
+	u.updateRoundLag()
+	if latestRound >= previous {
+		return
+	}
+	log.Warn().Uint64("round", latestRound).Uint64("previous_round", previous).Msg("Oracle round rewound by a reorg")
+	go func() {
+		if err := u.catchUp(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to catch up after a reorg")
+		}
+	}()
+}
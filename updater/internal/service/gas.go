@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// setRandomnessMsg builds the call message of the setRandomness transaction the
+// updater would send for a round
+func (u *Updater) setRandomnessMsg(
+	random binding.IDrandOracleRandom,
+	signature []byte,
+	gasPrice *big.Int,
+) (ethereum.CallMsg, error) {
+	calldata, _, err := u.setRandomnessCalldata(random, signature)
+	if err != nil {
+		return ethereum.CallMsg{}, err
+	}
+	return ethereum.CallMsg{
+		From:     u.sender.Address(),
+		To:       &u.oracleAddress,
+		Gas:      u.setRandomnessGasLimit,
+		GasPrice: gasPrice,
+		Value:    u.options.SubmissionFee,
+		Data:     calldata,
+	}, nil
+}
+
+// estimateSetRandomnessGas estimates the gas used by the setRandomness transaction of a round
+func (u *Updater) estimateSetRandomnessGas(
+	ctx context.Context,
+	random binding.IDrandOracleRandom,
+	signature []byte,
+	gasPrice *big.Int,
+) (uint64, error) {
+	msg, err := u.setRandomnessMsg(random, signature, gasPrice)
+	if err != nil {
+		return 0, err
+	}
+	msg.Gas = 0
+	return u.rpcClient.EstimateGas(ctx, msg)
+}
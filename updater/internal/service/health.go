@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const healthCheckTimeout = 5 * time.Second
+
+// CheckResult is the outcome of a single health check
+type CheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Value string `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Liveness reports whether the updater process is functioning. A failing
+// liveness check means the process should be restarted.
+func (u *Updater) Liveness() (bool, []CheckResult) {
+	checks := []CheckResult{u.checkRunning()}
+	return allOK(checks), checks
+}
+
+// Readiness reports whether the updater is able to keep the oracle up to date
+func (u *Updater) Readiness(ctx context.Context) (bool, []CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	checks := []CheckResult{
+		u.checkRunning(),
+		u.checkNotStopping(),
+		u.checkDrandInfo(ctx),
+		u.checkRPC(ctx),
+		u.checkSenderBalance(),
+		u.checkRoundLag(),
+		u.checkCircuitBreaker(),
+	}
+	// The dependency matrix is computed once the dependencies without a
+	// health of their own were probed
+	u.probeStateStore()
+	checks = append(checks, u.checkDependencies())
+	return allOK(checks), checks
+}
+
+func (u *Updater) checkRunning() CheckResult {
+	result := CheckResult{Name: "updater_running", OK: u.running.Load()}
+	if !result.OK {
+		result.Error = "updater is not running"
+	}
+	return result
+}
+
+func (u *Updater) checkDrandInfo(ctx context.Context) CheckResult {
+	result := CheckResult{Name: "drand_info"}
+	info, err := u.drandClient.Info(ctx)
+	u.probes.observe("drand", err)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OK = true
+	result.Value = info.HashString()
+	return result
+}
+
+func (u *Updater) checkRPC(ctx context.Context) CheckResult {
+	result := CheckResult{Name: "rpc_block_number"}
+	blockNumber, err := u.rpcClient.BlockNumber(ctx)
+	u.probes.observe("rpc", err)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OK = true
+	result.Value = fmt.Sprintf("%d", blockNumber)
+	return result
+}
+
+func (u *Updater) checkSenderBalance() CheckResult {
+	result := CheckResult{Name: "sender_balance"}
+
+	u.senderBalanceMutex.RLock()
+	balance := u.senderBalance
+	u.senderBalanceMutex.RUnlock()
+
+	if balance == nil {
+		result.Error = "sender balance is not known yet"
+		return result
+	}
+	result.Value = balance.String()
+	minSenderBalance := u.Settings().MinSenderBalance
+	if minSenderBalance != nil && balance.Cmp(minSenderBalance) < 0 {
+		result.Error = fmt.Sprintf("sender balance is below %s wei", minSenderBalance)
+		return result
+	}
+	result.OK = true
+	return result
+}
+
+func (u *Updater) checkRoundLag() CheckResult {
+	result := CheckResult{Name: "round_lag"}
+
+	u.latestDrandRoundMutex.RLock()
+	drandRound := u.latestDrandRound
+	u.latestDrandRoundMutex.RUnlock()
+	oracleRound := u.GetLatestOracleRound()
+
+	var lag uint64
+	if drandRound > oracleRound {
+		lag = drandRound - oracleRound
+	}
+	result.Value = fmt.Sprintf("%d", lag)
+	maxRoundLag := u.Settings().MaxRoundLag
+	// Rounds are left to lag while hibernating
+	if lag > maxRoundLag && !u.hibernating() {
+		result.Error = fmt.Sprintf("round lag is above %d", maxRoundLag)
+		return result
+	}
+	result.OK = true
+	return result
+}
+
+// probeStateStore checks that the state store can be listed
+func (u *Updater) probeStateStore() {
+	_, err := u.store.Sizes()
+	u.probes.observe("state_store", err)
+}
+
+// checkDependencies reports the readiness computed from the dependency matrix
+func (u *Updater) checkDependencies() CheckResult {
+	matrix := u.Dependencies()
+	result := CheckResult{Name: "dependencies", OK: matrix.Ready}
+	down := 0
+	for _, dependency := range matrix.Dependencies {
+		if dependency.State == DependencyDown {
+			down++
+		}
+	}
+	result.Value = fmt.Sprintf("%d/%d down", down, len(matrix.Dependencies))
+	if !matrix.Ready {
+		result.Error = strings.Join(matrix.Reasons, "; ")
+	}
+	return result
+}
+
+func allOK(checks []CheckResult) bool {
+	for _, check := range checks {
+		if !check.OK {
+			return false
+		}
+	}
+	return true
+}
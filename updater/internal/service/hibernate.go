@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Reasons of a wake-up from hibernation, the reason label of the wake-up metric
+const (
+	wakeRequest = "request"
+	wakeAdmin   = "admin"
+)
+
+// defaultHibernationPollInterval is the hibernation poll interval when none
+// is configured
+const defaultHibernationPollInterval = 1 * time.Minute
+
+// ErrHibernationDisabled is returned by Wake when hibernation is not configured
+var ErrHibernationDisabled = errors.New("hibernation is disabled")
+
+// IdleConnections closes the idle connections of an RPC client, the next call
+// opening them again
+type IdleConnections interface {
+	CloseIdleConnections()
+}
+
+// Hibernation configures the hibernation of a rarely used deployment. Once
+// no request event was seen for After, the updater stops submitting new
+// rounds, slows its background loops down to PollInterval and closes its idle
+// RPC connections. A request event or an admin wake-up wakes it up, and the
+// rounds produced in the meantime are caught up according to the catch-up
+// policy.
+type Hibernation struct {
+	// After is how long without a request event before hibernating
+	After time.Duration
+
+	// PollInterval is the interval between queries of the request events, and
+	// the minimum interval of the background loops while hibernating. 0 uses
+	// defaultHibernationPollInterval.
+	PollInterval time.Duration
+
+	// WakeAddress is the contract emitting the request events, the oracle
+	// when zero
+	WakeAddress common.Address
+
+	// WakeTopic is the topic of the request events, the zero hash only wakes
+	// the updater through the admin API
+	WakeTopic common.Hash
+
+	// Connections closes the idle RPC connections on hibernation, nil keeps
+	// them open
+	Connections IdleConnections
+}
+
+// HibernationStatus is the hibernation state of the updater
+type HibernationStatus struct {
+	Hibernating bool       `json:"hibernating"`
+	Since       *time.Time `json:"since,omitempty"`
+	// LastActivity is the latest request event or wake-up
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// hibernationState tracks whether the updater hibernates
+type hibernationState struct {
+	mu           sync.Mutex
+	hibernating  bool
+	since        time.Time
+	lastActivity time.Time
+	// awake is closed on wake-up, cutting the hibernation waits short
+	awake chan struct{}
+	// wake is signalled by an admin wake-up
+	wake chan struct{}
+}
+
+// Hibernation returns the hibernation state, nil without hibernation
+func (u *Updater) Hibernation() *HibernationStatus {
+	if u.options.Hibernation == nil {
+		return nil
+	}
+	u.hibernation.mu.Lock()
+	defer u.hibernation.mu.Unlock()
+	status := &HibernationStatus{
+		Hibernating:  u.hibernation.hibernating,
+		LastActivity: u.hibernation.lastActivity,
+	}
+	if u.hibernation.hibernating {
+		since := u.hibernation.since
+		status.Since = &since
+	}
+	return status
+}
+
+// hibernating reports whether the updater hibernates
+func (u *Updater) hibernating() bool {
+	u.hibernation.mu.Lock()
+	defer u.hibernation.mu.Unlock()
+	return u.hibernation.hibernating
+}
+
+// Wake wakes the updater up from hibernation, and reports whether it was
+// hibernating. An awake updater postpones its next hibernation.
+func (u *Updater) Wake() (bool, error) {
+	if u.options.Hibernation == nil {
+		return false, ErrHibernationDisabled
+	}
+	hibernating := u.hibernating()
+	select {
+	case u.hibernation.wake <- struct{}{}:
+	default:
+	}
+	return hibernating, nil
+}
+
+// idleWait waits interval, or the hibernation poll interval if longer while
+// hibernating. A wake-up cuts the wait short.
+func (u *Updater) idleWait(ctx context.Context, interval time.Duration) error {
+	u.hibernation.mu.Lock()
+	hibernating, awake := u.hibernation.hibernating, u.hibernation.awake
+	u.hibernation.mu.Unlock()
+	if hibernating {
+		interval = max(interval, u.hibernationPollInterval())
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-awake:
+		return nil
+	case <-time.After(interval):
+		return nil
+	}
+}
+
+func (u *Updater) hibernationPollInterval() time.Duration {
+	if u.options.Hibernation.PollInterval > 0 {
+		return u.options.Hibernation.PollInterval
+	}
+	return defaultHibernationPollInterval
+}
+
+// runHibernation polls the request events every hibernation poll interval,
+// hibernating once none was seen for the hibernation delay and waking up on
+// the next one or on an admin wake-up
+func (u *Updater) runHibernation(ctx context.Context) error {
+	if u.options.Hibernation == nil {
+		return nil
+	}
+	u.hibernation.mu.Lock()
+	u.hibernation.lastActivity = time.Now()
+	u.hibernation.mu.Unlock()
+	u.metrics.SetHibernating(false)
+
+	var next uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-u.hibernation.wake:
+			if err := u.wake(ctx, wakeAdmin); err != nil {
+				return err
+			}
+		case <-time.After(u.hibernationPollInterval()):
+			requested, err := u.pollRequests(ctx, &next)
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to poll request events")
+				continue
+			}
+			if requested {
+				if err := u.wake(ctx, wakeRequest); err != nil {
+					return err
+				}
+				continue
+			}
+			u.hibernation.mu.Lock()
+			idle := !u.hibernation.hibernating && time.Since(u.hibernation.lastActivity) >= u.options.Hibernation.After
+			u.hibernation.mu.Unlock()
+			if idle {
+				u.hibernate()
+			}
+		}
+	}
+}
+
+// pollRequests reports whether a request event was emitted from block next
+// on, and moves next past the latest block. The first poll starts at the
+// latest block.
+func (u *Updater) pollRequests(ctx context.Context, next *uint64) (bool, error) {
+	topic := u.options.Hibernation.WakeTopic
+	if topic == (common.Hash{}) {
+		return false, nil
+	}
+	latest, err := u.rpcClient.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+	if *next == 0 || *next+chainEventLookback < latest {
+		*next = latest - min(latest, chainEventLookback)
+	}
+	if *next > latest {
+		return false, nil
+	}
+	address := u.options.Hibernation.WakeAddress
+	if address == (common.Address{}) {
+		address = u.oracleAddress
+	}
+	logs, err := u.rpcClient.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(*next),
+		ToBlock:   new(big.Int).SetUint64(latest),
+		Addresses: []common.Address{address},
+		Topics:    [][]common.Hash{{topic}},
+	})
+	if err != nil {
+		return false, err
+	}
+	*next = latest + 1
+	return len(logs) > 0, nil
+}
+
+// hibernate stops submitting new rounds, slows the background loops down and
+// closes the idle RPC connections
+func (u *Updater) hibernate() {
+	u.hibernation.mu.Lock()
+	u.hibernation.hibernating = true
+	u.hibernation.since = time.Now()
+	u.hibernation.awake = make(chan struct{})
+	idleFor := time.Since(u.hibernation.lastActivity)
+	u.hibernation.mu.Unlock()
+
+	pollInterval := u.hibernationPollInterval()
+	u.watcher.SetPollInterval(max(u.options.EventsPollInterval, pollInterval))
+	if u.options.Hibernation.Connections != nil {
+		u.options.Hibernation.Connections.CloseIdleConnections()
+	}
+	u.metrics.SetHibernating(true)
+	log.Info().
+		Dur("idle_for", idleFor).
+		Dur("poll_interval", pollInterval).
+		Msg("No request for a while, hibernating")
+	u.recordEvent(kindHibernated, u.GetLatestOracleRound(), "Updater hibernating", map[string]string{
+		"idle_for": idleFor.Round(time.Second).String(),
+	})
+}
+
+// wake postpones the next hibernation and, when hibernating, restores the
+// background loops and catches up on the rounds produced in the meantime
+func (u *Updater) wake(ctx context.Context, reason string) error {
+	u.hibernation.mu.Lock()
+	u.hibernation.lastActivity = time.Now()
+	hibernating := u.hibernation.hibernating
+	since := u.hibernation.since
+	if hibernating {
+		u.hibernation.hibernating = false
+		close(u.hibernation.awake)
+	}
+	u.hibernation.mu.Unlock()
+	if !hibernating {
+		return nil
+	}
+
+	u.watcher.SetPollInterval(u.options.EventsPollInterval)
+	u.metrics.SetHibernating(false)
+	u.metrics.IncHibernationWakeup(reason)
+	log.Info().
+		Str("reason", reason).
+		Dur("hibernated_for", time.Since(since)).
+		Msg("Waking up from hibernation")
+	u.recordEvent(kindWoken, u.GetLatestOracleRound(), fmt.Sprintf("Updater woken up by %s", reason), map[string]string{
+		"reason":         reason,
+		"hibernated_for": time.Since(since).Round(time.Second).String(),
+	})
+
+	if err := u.catchUp(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Error().Err(err).Msg("Failed to catch up after waking up")
+	}
+	return nil
+}
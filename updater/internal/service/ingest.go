@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/crypto"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidBeacon is returned when a pushed beacon fails verification
+var ErrInvalidBeacon = errors.New("invalid beacon")
+
+// Beacon is a drand beacon pushed to the updater by a relay partner
+type Beacon struct {
+	Round             uint64
+	Randomness        []byte
+	Signature         []byte
+	PreviousSignature []byte
+}
+
+// verifyBeacon verifies a beacon against the drand chain public key, the same
+// way the drand client verifies pulled beacons, and returns its randomness
+func (u *Updater) verifyBeacon(b Beacon) ([]byte, error) {
+	scheme, err := crypto.SchemeFromName(u.drandInfo.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	err = scheme.VerifyBeacon(&chain.Beacon{
+		PreviousSig: b.PreviousSignature, // only used by chained schemes
+		Round:       b.Round,
+		Signature:   b.Signature,
+	}, u.drandInfo.PublicKey.Clone())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBeacon, err)
+	}
+
+	randomness := crypto.RandomnessFromSignature(b.Signature)
+	if len(b.Randomness) > 0 && !bytes.Equal(randomness, b.Randomness) {
+		return nil, fmt.Errorf("%w: randomness does not match signature", ErrInvalidBeacon)
+	}
+	return randomness, nil
+}
+
+// IngestBeacon verifies a pushed beacon and feeds it into the submission pipeline
+func (u *Updater) IngestBeacon(ctx context.Context, b Beacon) error {
+	if u.stopping.Load() {
+		return ErrStopping
+	}
+	randomness, err := u.verifyBeacon(b)
+	if err != nil {
+		u.metrics.IncIngestedBeacon("rejected")
+		return err
+	}
+	u.metrics.IncIngestedBeacon("accepted")
+
+	u.latestDrandRoundMutex.Lock()
+	if b.Round > u.latestDrandRound {
+		u.latestDrandRound = b.Round
+		u.lastDrandRoundAt = time.Now()
+		u.metrics.SetDrandRound(float64(b.Round))
+	}
+	u.latestDrandRoundMutex.Unlock()
+	u.updateRoundLag()
+
+	if b.Round <= u.GetLatestOracleRound() {
+		log.Debug().Uint64("round", b.Round).Msg("Ignoring ingested beacon for a round already on-chain")
+		return nil
+	}
+
+	log.Info().Uint64("round", b.Round).Msg("Ingested pushed beacon")
+	span := u.traceReceivedRound(ctx, b.Round, LaneRequested)
+	err = u.scheduler.Push(ctx, &roundData{
+		round:      b.Round,
+		randomness: randomness,
+		signature:  b.Signature,
+		span:       span,
+	}, LaneRequested)
+	if err != nil {
+		endSpan(span, err)
+	}
+	return err
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/retry"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/drand/drand/client"
+	"github.com/drand/drand/crypto"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrNotPrepared is returned when a round is simulated before being prepared
+var ErrNotPrepared = errors.New("round is not prepared")
+
+// Inspection is a round stepped through the submission pipeline by hand, each
+// step filling in its part
+type Inspection struct {
+	Round      uint64 `json:"round"`
+	Timestamp  uint64 `json:"timestamp"`
+	Randomness string `json:"randomness"`
+	Signature  string `json:"signature"`
+	Source     string `json:"source,omitempty"`
+
+	Verified *bool `json:"verified,omitempty"`
+
+	EIP712Signature string `json:"eip712_signature,omitempty"`
+	Calldata        string `json:"calldata,omitempty"`
+	GasPrice        string `json:"gas_price,omitempty"`
+
+	OracleRound     uint64 `json:"oracle_round,omitempty"`
+	GasEstimate     uint64 `json:"gas_estimate,omitempty"`
+	EstimatedCost   string `json:"estimated_cost,omitempty"`
+	SimulationError string `json:"simulation_error,omitempty"`
+
+	randomness      []byte
+	signature       []byte
+	eip712Signature []byte
+	gasPrice        *big.Int
+}
+
+// InspectRound fetches a round from the drand network, 0 fetching the latest
+func (u *Updater) InspectRound(ctx context.Context, round uint64) (*Inspection, error) {
+	result, err := retry.Value(ctx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+		return u.drandClient.Get(ctx, round)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting round %d from Drand network: %w", round, err)
+	}
+	in := &Inspection{
+		Round:      result.Round(),
+		Timestamp:  u.roundTimestamp(result.Round()),
+		Randomness: hex.EncodeToString(result.Randomness()),
+		Signature:  hex.EncodeToString(result.Signature()),
+		randomness: result.Randomness(),
+		signature:  result.Signature(),
+	}
+	if r, ok := result.(interface{ Source() string }); ok {
+		in.Source = r.Source()
+	}
+	return in, nil
+}
+
+// VerifyInspection verifies the round against the drand chain public key
+func (u *Updater) VerifyInspection(ctx context.Context, in *Inspection) error {
+	err := u.verifyFetchedRound(ctx, in.Round, in.randomness, in.signature)
+	verified := err == nil
+	in.Verified = &verified
+	return err
+}
+
+// verifyFetchedRound verifies a round fetched from the drand network against
+// the drand chain public key. Chained schemes sign over the previous
+// signature, fetched from the drand network.
+func (u *Updater) verifyFetchedRound(ctx context.Context, round uint64, randomness []byte, signature []byte) error {
+	beacon := Beacon{Round: round, Randomness: randomness, Signature: signature}
+	if u.drandInfo.Scheme == crypto.DefaultSchemeID && round > 1 {
+		previous, err := retry.Value(ctx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+			return u.drandClient.Get(ctx, round-1)
+		})
+		if err != nil {
+			return fmt.Errorf("error getting previous round %d from Drand network: %w", round-1, err)
+		}
+		beacon.PreviousSignature = previous.Signature()
+	}
+	_, err := u.verifyBeacon(beacon)
+	return err
+}
+
+// PrepareInspection signs the round for the oracle and builds the
+// setRandomness calldata at the current gas price
+func (u *Updater) PrepareInspection(ctx context.Context, in *Inspection) error {
+	random := in.random()
+	eip712Signature, err := u.signer.SignSetRandomness(ctx, in.Round, in.Timestamp, random.Randomness, in.signature)
+	if err != nil {
+		return fmt.Errorf("error signing set randomness: %w", err)
+	}
+	gasPrice, err := u.options.GasStrategy.GasPrice(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting gas price from the %s strategy: %w", u.options.GasStrategy.Name(), err)
+	}
+	msg, err := u.setRandomnessMsg(random, eip712Signature, gasPrice)
+	if err != nil {
+		return err
+	}
+	in.eip712Signature = eip712Signature
+	in.gasPrice = gasPrice
+	in.EIP712Signature = hex.EncodeToString(eip712Signature)
+	in.Calldata = hex.EncodeToString(msg.Data)
+	in.GasPrice = gasPrice.String()
+	return nil
+}
+
+// SimulateInspection simulates the prepared setRandomness transaction with
+// eth_call and eth_estimateGas. A reverted simulation is recorded on the
+// inspection rather than returned, only the RPC failing returns an error.
+func (u *Updater) SimulateInspection(ctx context.Context, in *Inspection) error {
+	if in.eip712Signature == nil {
+		return ErrNotPrepared
+	}
+	oracleRound, err := u.binding.LatestRound(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("error getting latest round from Drand Oracle contract: %w", err)
+	}
+	in.OracleRound = oracleRound
+	in.GasEstimate, in.EstimatedCost, in.SimulationError = 0, "", ""
+
+	random := in.random()
+	msg, err := u.setRandomnessMsg(random, in.eip712Signature, in.gasPrice)
+	if err != nil {
+		return err
+	}
+	if _, err := u.rpcClient.CallContract(ctx, msg, nil); err != nil {
+		in.SimulationError = err.Error()
+		return nil
+	}
+	gasEstimate, err := u.estimateSetRandomnessGas(ctx, random, in.eip712Signature, in.gasPrice)
+	if err != nil {
+		in.SimulationError = err.Error()
+		return nil
+	}
+	in.GasEstimate = gasEstimate
+	in.EstimatedCost = estimatedCost(gasEstimate, in.gasPrice, u.options.SubmissionFee).String()
+	return nil
+}
+
+// SendInspection submits the round like the submit command, signed again at
+// the gas price of the moment
+func (u *Updater) SendInspection(ctx context.Context, in *Inspection) error {
+	_, err := u.SubmitRounds(ctx, in.Round, in.Round)
+	return err
+}
+
+// Preview is the setRandomness transaction the updater would send for a round
+// at the moment, simulated against the current chain state
+type Preview struct {
+	*Inspection
+	// Submittable reports whether the round follows the oracle's latest round
+	Submittable bool               `json:"submittable"`
+	Transaction PreviewTransaction `json:"transaction"`
+}
+
+// PreviewTransaction is an unsigned setRandomness transaction
+type PreviewTransaction struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	ChainID  int64  `json:"chain_id"`
+	Nonce    uint64 `json:"nonce"`
+	GasLimit uint64 `json:"gas_limit"`
+	GasPrice string `json:"gas_price"`
+	Value    string `json:"value"`
+	// MaxCost is the gas limit at the gas price plus the value
+	MaxCost string `json:"max_cost"`
+	Data    string `json:"data"`
+	// SigningHash is the hash the sender signs, to compare with a hardware
+	// wallet's or a co-signer's display
+	SigningHash string `json:"signing_hash"`
+}
+
+// PreviewRound fetches, verifies and signs a round, 0 being the latest, and
+// builds the transaction the updater would send for it, at the sender's
+// pending nonce, without broadcasting it. The transaction is simulated, a
+// revert being recorded in the preview's simulation error.
+func (u *Updater) PreviewRound(ctx context.Context, round uint64) (*Preview, error) {
+	in, err := u.InspectRound(ctx, round)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.VerifyInspection(ctx, in); err != nil {
+		return nil, fmt.Errorf("round %d: %w", in.Round, err)
+	}
+	if err := u.PrepareInspection(ctx, in); err != nil {
+		return nil, err
+	}
+	if err := u.SimulateInspection(ctx, in); err != nil {
+		return nil, err
+	}
+	nonce, err := u.rpcClient.PendingNonceAt(ctx, u.sender.Address())
+	if err != nil {
+		return nil, fmt.Errorf("error getting sender nonce: %w", err)
+	}
+
+	msg, err := u.setRandomnessMsg(in.random(), in.eip712Signature, in.gasPrice)
+	if err != nil {
+		return nil, err
+	}
+	value := new(big.Int)
+	if msg.Value != nil {
+		value.Set(msg.Value)
+	}
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       msg.To,
+		Gas:      msg.Gas,
+		GasPrice: msg.GasPrice,
+		Value:    value,
+		Data:     msg.Data,
+	})
+	signingHash := types.LatestSignerForChainID(big.NewInt(u.chainID)).Hash(tx)
+	return &Preview{
+		Inspection:  in,
+		Submittable: in.Round == in.OracleRound+1 || (in.OracleRound == 0 && in.Round == u.genesisRound),
+		Transaction: PreviewTransaction{
+			From:        u.sender.Address().Hex(),
+			To:          u.oracleAddress.Hex(),
+			ChainID:     u.chainID,
+			Nonce:       nonce,
+			GasLimit:    msg.Gas,
+			GasPrice:    msg.GasPrice.String(),
+			Value:       value.String(),
+			MaxCost:     estimatedCost(msg.Gas, msg.GasPrice, value).String(),
+			Data:        hex.EncodeToString(msg.Data),
+			SigningHash: signingHash.Hex(),
+		},
+	}, nil
+}
+
+func (in *Inspection) random() binding.IDrandOracleRandom {
+	return binding.IDrandOracleRandom{
+		Round:      in.Round,
+		Timestamp:  in.Timestamp,
+		Randomness: [32]byte(in.randomness),
+		Signature:  in.signature,
+	}
+}
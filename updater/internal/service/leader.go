@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// leaderPollInterval is the interval at which a standby replica holding a
+// round checks whether it was elected or the leader set the round
+const leaderPollInterval = time.Second
+
+// LeaderElection tells whether this replica is the leader among redundant
+// updater replicas, the only one submitting transactions
+type LeaderElection interface {
+	IsLeader() bool
+}
+
+// RoundOwnership partitions rounds among updaters running active in several
+// regions: each round is owned by one region and covered by the others after
+// a delay when its owner did not set it
+type RoundOwnership interface {
+	// Region returns the name of this region
+	Region() string
+	// Owner returns the region owning a round
+	Owner(round uint64) string
+	// CoverDelay returns how long to wait for the other regions to set a
+	// round before submitting it, 0 when this region owns it
+	CoverDelay(round uint64) time.Duration
+}
+
+// isLeader reports whether this replica submits transactions, always true
+// without leader election. Leadership changes are recorded in the timeline.
+func (u *Updater) isLeader() bool {
+	if u.options.Leader == nil {
+		return true
+	}
+	leader := u.options.Leader.IsLeader()
+	if u.leading.Swap(leader) != leader {
+		if leader {
+			u.recordEvent(kindLeaderElected, u.GetLatestOracleRound(), "Replica elected leader", nil)
+		} else {
+			u.recordEvent(kindLeaderLost, u.GetLatestOracleRound(), "Replica lost leadership, standing by", nil)
+		}
+	}
+	return leader
+}
+
+// awaitLeadership holds a round on a standby replica until the replica is
+// elected, reporting true, or the leader sets the round, reporting false. The
+// standby keeps following drand and the oracle meanwhile, so that it submits
+// the next round as soon as it takes over.
+func (u *Updater) awaitLeadership(ctx context.Context, round uint64) (bool, error) {
+	if u.isLeader() {
+		return true, nil
+	}
+	log.Debug().Uint64("round", round).Msg("Standing by, round left to the leader")
+	ticker := time.NewTicker(leaderPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+		}
+		if u.isLeader() {
+			log.Info().Uint64("round", round).Msg("Took over as leader, submitting round")
+			return true, nil
+		}
+		if round <= u.GetLatestOracleRound() {
+			return false, nil
+		}
+	}
+}
+
+// awaitOwnership holds a round owned by another region for its cover delay,
+// reporting true when the round is still not set by then and this region
+// covers it, or false when another region set it
+func (u *Updater) awaitOwnership(ctx context.Context, round uint64) (bool, error) {
+	if u.options.Ownership == nil {
+		return true, nil
+	}
+	delay := u.options.Ownership.CoverDelay(round)
+	if delay == 0 {
+		return true, nil
+	}
+	owner := u.options.Ownership.Owner(round)
+	log.Debug().Uint64("round", round).Str("owner", owner).Dur("cover_delay", delay).Msg("Round owned by another region, waiting before covering it")
+	deadline := time.Now().Add(delay)
+	ticker := time.NewTicker(leaderPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+		}
+		if round <= u.GetLatestOracleRound() {
+			return false, nil
+		}
+		if !time.Now().Before(deadline) {
+			log.Warn().Uint64("round", round).Str("owner", owner).Dur("cover_delay", delay).Msg("Round not set by its owner region, covering it")
+			u.metrics.IncRoundCovered(owner)
+			return true, nil
+		}
+	}
+}
+
+// role is the role of this replica in the status, empty without leader
+// election or regions
+func (u *Updater) role() string {
+	switch {
+	case u.options.Leader == nil && u.options.Ownership != nil:
+		return "active"
+	case u.options.Leader == nil:
+		return ""
+	case u.options.Leader.IsLeader():
+		return "leader"
+	default:
+		return "standby"
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/drand/drand/chain"
 	"github.com/ethereum/go-ethereum/common"
@@ -17,6 +18,20 @@ const (
 	labelChainID        = "chain_id"
 	labelOracleAddress  = "oracle_address"
 	labelUpdaterAddress = "updater_address"
+	labelCollection     = "collection"
+	labelResult         = "result"
+	labelStrategy       = "strategy"
+	labelLane           = "lane"
+	labelPipeline       = "pipeline"
+	labelSource         = "source"
+	labelOperation      = "operation"
+	labelCheck          = "check"
+	labelStage          = "stage"
+	labelOwnerRegion    = "owner_region"
+	labelReason         = "reason"
+	labelKey            = "key"
+	labelForm           = "form"
+	labelDecision       = "decision"
 
 	// Drand info metric labels
 	labelPublicKey   = "public_key"
@@ -27,13 +42,82 @@ const (
 	labelGenesisSeed = "genesis_seed"
 )
 
+// metricLabels are the label names used by the updater metrics, which const labels must not reuse
+var metricLabels = []string{
+	labelChainHash, labelChainID, labelOracleAddress, labelUpdaterAddress, labelCollection, labelResult,
+	labelStrategy, labelLane, labelPipeline, labelSource, labelOperation, labelCheck, labelStage, labelOwnerRegion, labelReason, labelKey, labelForm, labelDecision, labelPublicKey, labelID, labelPeriod, labelScheme, labelGenesisTime, labelGenesisSeed,
+}
+
+// validateConstLabels checks that const labels do not collide with metric labels
+func validateConstLabels(constLabels map[string]string) error {
+	for _, name := range metricLabels {
+		if _, ok := constLabels[name]; ok {
+			return fmt.Errorf("deployment label %q is reserved for metric labels", name)
+		}
+	}
+	return nil
+}
+
 // Metrics holds all Prometheus metrics for the updater
 type Metrics struct {
 	drandRoundTotal           *prometheus.GaugeVec
 	oracleRoundTotal          *prometheus.GaugeVec
+	committedRound            *prometheus.GaugeVec
+	chainClockSkew            *prometheus.GaugeVec
+	chainClockDelaysTotal     *prometheus.CounterVec
+	selfTestsTotal            *prometheus.CounterVec
+	selfTestLastSuccess       *prometheus.GaugeVec
+	standbyReady              *prometheus.GaugeVec
+	oraclePaused              *prometheus.GaugeVec
+	signerAuthorized          *prometheus.GaugeVec
+	submissionsHeldTotal      *prometheus.CounterVec
+	roundsCoveredTotal        *prometheus.CounterVec
+	committedBlock            *prometheus.GaugeVec
+	confirmationsTotal        *prometheus.CounterVec
 	setRandomnessSuccessTotal *prometheus.CounterVec
 	setRandomnessFailureTotal *prometheus.CounterVec
 	updaterBalance            *prometheus.GaugeVec
+	stateStoreSize            *prometheus.GaugeVec
+	stateStoreRemovedTotal    *prometheus.CounterVec
+	ingestedBeaconsTotal      *prometheus.CounterVec
+	gasPrice                  *prometheus.GaugeVec
+	gasEstimated              *prometheus.HistogramVec
+	gasUsed                   *prometheus.HistogramVec
+	gasUsedToEstimatedRatio   *prometheus.HistogramVec
+	queueLength               *prometheus.GaugeVec
+	pipelineInFlight          *prometheus.GaugeVec
+	pipelineRequeuedTotal     *prometheus.CounterVec
+	receiptMismatchesTotal    *prometheus.CounterVec
+	circuitBreakerOpen        *prometheus.GaugeVec
+	roundLag                  *prometheus.GaugeVec
+	submissionLatency         *prometheus.HistogramVec
+	feePaid                   *prometheus.HistogramVec
+	calldataBytes             *prometheus.HistogramVec
+	auditEntriesTotal         *prometheus.CounterVec
+	catchUpEstimatedCost      *prometheus.GaugeVec
+	nonceGaps                 *prometheus.GaugeVec
+	nonceGapsFilledTotal      *prometheus.CounterVec
+	fallbackRoundsTotal       *prometheus.CounterVec
+	retriesTotal              *prometheus.CounterVec
+	feesTotal                 *prometheus.CounterVec
+	gasUsedTotal              *prometheus.CounterVec
+	valueTotal                *prometheus.CounterVec
+	retriesExhaustedTotal     *prometheus.CounterVec
+	retryCircuitOpen          *prometheus.GaugeVec
+	retryCircuitOpenedTotal   *prometheus.CounterVec
+	compromiseDetectedTotal   *prometheus.CounterVec
+	pauseProposalsTotal       *prometheus.CounterVec
+	archiveVerifiedTotal      *prometheus.CounterVec
+	archivePass               *prometheus.GaugeVec
+	archiveProgress           *prometheus.GaugeVec
+	topUpsTotal               *prometheus.CounterVec
+	topUpWeiTotal             *prometheus.CounterVec
+	keyRotationsTotal         *prometheus.CounterVec
+	keyRotatedAt              *prometheus.GaugeVec
+	hibernating               *prometheus.GaugeVec
+	hibernationWakeupsTotal   *prometheus.CounterVec
+	submissionsPaused         *prometheus.GaugeVec
+	releasesPublishedTotal    *prometheus.CounterVec
 
 	// New info metric
 	drandInfo *prometheus.GaugeVec
@@ -45,8 +129,11 @@ type Metrics struct {
 	updaterAddress common.Address
 }
 
-// NewMetrics creates and registers all Prometheus metrics
-func NewMetrics(chainID int64, oracleAddress common.Address, updaterAddress common.Address, drandInfo *chain.Info) *Metrics {
+// NewMetrics creates and registers all Prometheus metrics. The const labels are
+// attached to every metric.
+func NewMetrics(chainID int64, oracleAddress common.Address, updaterAddress common.Address, drandInfo *chain.Info, constLabels map[string]string) *Metrics {
+	factory := promauto.With(prometheus.WrapRegistererWith(constLabels, prometheus.DefaultRegisterer))
+
 	m := &Metrics{
 		chainHash:      drandInfo.HashString(),
 		chainID:        chainID,
@@ -54,28 +141,118 @@ func NewMetrics(chainID int64, oracleAddress common.Address, updaterAddress comm
 		updaterAddress: updaterAddress,
 	}
 
-	m.drandRoundTotal = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	m.drandRoundTotal = factory.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "drand_round_number_network",
 		Help: "Current round number from the Drand network",
 	}, []string{labelChainHash})
 
-	m.oracleRoundTotal = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	m.oracleRoundTotal = factory.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "drand_round_number_oracle",
 		Help: "Current round number processed by the Oracle",
 	}, []string{labelChainID, labelOracleAddress})
 
-	m.setRandomnessSuccessTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	m.chainClockSkew = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_chain_clock_skew_seconds",
+		Help: "Local time minus the timestamp of the latest block, observed before submitting a round",
+	}, []string{labelChainID})
+
+	m.chainClockDelaysTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_chain_clock_delays_total",
+		Help: "Total number of submissions delayed by a chain clock behind the round, by result: caught_up or timeout",
+	}, []string{labelChainID, labelResult})
+
+	m.selfTestsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_self_tests_total",
+		Help: "Total number of end-to-end self-tests against the test oracle, by result and failed stage",
+	}, []string{labelChainID, labelOracleAddress, labelStage, labelResult})
+
+	m.selfTestLastSuccess = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_self_test_last_success_timestamp_seconds",
+		Help: "Unix time of the latest successful self-test",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.standbyReady = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_standby_ready",
+		Help: "Whether the latest self-test succeeded, proving the instance ready to take over (1) or not (0)",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.oraclePaused = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_oracle_paused",
+		Help: "Whether the Oracle contract is paused (1) or not (0), as of the latest authorization check",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.signerAuthorized = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_signer_authorized",
+		Help: "Whether the configured signer is the Oracle contract's signer (1) or not (0), as of the latest authorization check",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.submissionsHeldTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_submissions_held_total",
+		Help: "Total number of submissions held back because the updater is not authorized, by failed check: paused or signer",
+	}, []string{labelChainID, labelOracleAddress, labelCheck})
+
+	m.roundsCoveredTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_region_rounds_covered_total",
+		Help: "Total number of rounds submitted on behalf of the region owning them, by owner region",
+	}, []string{labelChainID, labelOracleAddress, labelOwnerRegion})
+
+	m.committedRound = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_round_number_oracle_committed",
+		Help: "Latest round set on the Oracle in a block the chain considers committed",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.confirmationsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_submission_confirmations_total",
+		Help: "Total number of mined submissions followed until their block was committed, by result",
+	}, []string{labelChainID, labelOracleAddress, labelResult})
+
+	m.committedBlock = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_committed_block_number",
+		Help: "Latest block the chain considers committed, from the safe or finalized tag or the confirmation depth",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.setRandomnessSuccessTotal = factory.NewCounterVec(prometheus.CounterOpts{
 		Name: "drand_set_randomness_success_total",
 		Help: "Total number of successful SetRandomness transactions",
 	}, []string{labelChainID, labelOracleAddress})
 
-	m.setRandomnessFailureTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	m.setRandomnessFailureTotal = factory.NewCounterVec(prometheus.CounterOpts{
 		Name: "drand_set_randomness_failure_total",
-		Help: "Total number of failed SetRandomness transactions",
+		Help: "Total number of failed SetRandomness transactions by reason",
+	}, []string{labelChainID, labelOracleAddress, labelReason})
+
+	m.keyRotationsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_key_rotations_total",
+		Help: "Total number of key rotations by key, sender or signer, and result",
+	}, []string{labelChainID, labelOracleAddress, labelKey, labelResult})
+
+	m.keyRotatedAt = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_key_rotated_timestamp_seconds",
+		Help: "Unix time of the latest successful rotation of a key, sender or signer",
+	}, []string{labelChainID, labelOracleAddress, labelKey})
+
+	m.hibernating = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_hibernating",
+		Help: "Whether the updater hibernates (1) or is awake (0)",
 	}, []string{labelChainID, labelOracleAddress})
 
+	m.hibernationWakeupsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_hibernation_wakeups_total",
+		Help: "Total number of wake-ups from hibernation by reason, request or admin",
+	}, []string{labelChainID, labelOracleAddress, labelReason})
+
+	m.submissionsPaused = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_submissions_paused",
+		Help: "Whether submissions are paused by an operator (1) or not (0)",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.releasesPublishedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_releases_published_total",
+		Help: "Total number of daily releases published to IPFS by result",
+	}, []string{labelChainID, labelOracleAddress, labelResult})
+
 	// Add info metric
-	m.drandInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	m.drandInfo = factory.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "drand_network_info",
 		Help: "Static information about the Drand network configuration",
 	}, []string{
@@ -98,11 +275,194 @@ func NewMetrics(chainID int64, oracleAddress common.Address, updaterAddress comm
 	).Set(1)
 
 	// Add the balance metric
-	m.updaterBalance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	m.updaterBalance = factory.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "drand_updater_balance_wei",
 		Help: "Current balance of the updater address in wei",
 	}, []string{labelChainID, labelOracleAddress, labelUpdaterAddress})
 
+	m.stateStoreSize = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_state_store_size_bytes",
+		Help: "On-disk size of the local state store per collection",
+	}, []string{labelCollection})
+
+	m.stateStoreRemovedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_state_store_removed_records_total",
+		Help: "Total number of records removed from the local state store by compaction",
+	}, []string{labelCollection})
+
+	m.ingestedBeaconsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_ingested_beacons_total",
+		Help: "Total number of beacons pushed to the ingestion endpoint",
+	}, []string{labelChainHash, labelResult})
+
+	m.gasPrice = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_gas_price_wei",
+		Help: "Gas price in wei used for the last SetRandomness transaction",
+	}, []string{labelChainID, labelStrategy})
+
+	gasBuckets := prometheus.ExponentialBuckets(25_000, 1.5, 12)
+
+	m.gasEstimated = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "drand_set_randomness_gas_estimated",
+		Help:    "Estimated gas of SetRandomness transactions",
+		Buckets: gasBuckets,
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.gasUsed = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "drand_set_randomness_gas_used",
+		Help:    "Gas used by mined SetRandomness transactions",
+		Buckets: gasBuckets,
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.gasUsedToEstimatedRatio = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "drand_set_randomness_gas_used_to_estimated_ratio",
+		Help:    "Ratio of gas used to estimated gas of SetRandomness transactions",
+		Buckets: prometheus.LinearBuckets(0.5, 0.1, 11),
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.queueLength = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_submission_queue_length",
+		Help: "Number of rounds waiting to be submitted per scheduler lane",
+	}, []string{labelLane})
+
+	m.pipelineInFlight = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_submission_pipeline_in_flight",
+		Help: "Number of catch-up rounds broadcast by the submission pipeline and not mined yet",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.pipelineRequeuedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_submission_pipeline_requeued_total",
+		Help: "Total number of pipelined rounds requeued after a round in flight failed",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.receiptMismatchesTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_receipt_log_mismatches_total",
+		Help: "Total number of RandomnessUpdated events of receipts not matching the submitted rounds",
+	}, []string{labelChainID, labelOracleAddress, labelReason})
+
+	m.circuitBreakerOpen = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_circuit_breaker_open",
+		Help: "Whether the financial circuit breaker is open and submissions are paused",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.roundLag = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_oracle_round_lag",
+		Help: "Drand network round minus the latest round set on the Oracle",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.submissionLatency = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "drand_submission_latency_seconds",
+		Help:    "Time from a drand round becoming available to its SetRandomness transaction being confirmed",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.feePaid = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "drand_set_randomness_fee_wei",
+		Help:    "Gas fee paid per SetRandomness transaction in wei",
+		Buckets: prometheus.ExponentialBuckets(1e12, 4, 12),
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.calldataBytes = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "drand_submission_calldata_bytes",
+		Help:    "Calldata size in bytes of SetRandomness transactions by calldata form",
+		Buckets: prometheus.ExponentialBuckets(128, 2, 10),
+	}, []string{labelChainID, labelOracleAddress, labelForm})
+
+	m.auditEntriesTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_audit_entries_total",
+		Help: "Total number of submission decisions recorded to the audit log by decision and result",
+	}, []string{labelChainID, labelOracleAddress, labelDecision, labelResult})
+
+	m.catchUpEstimatedCost = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_catch_up_estimated_cost_wei",
+		Help: "Estimated cost in wei of the last catch-up, computed before it started",
+	}, []string{labelChainID, labelOracleAddress})
+
+	m.nonceGaps = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_sender_nonce_gaps",
+		Help: "Number of nonces handed out by the updater that the node has neither mined nor pending",
+	}, []string{labelChainID, labelUpdaterAddress})
+
+	m.nonceGapsFilledTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_sender_nonce_gaps_filled_total",
+		Help: "Total number of nonce gaps filled with a replayed or no-op transaction",
+	}, []string{labelChainID, labelUpdaterAddress})
+
+	m.fallbackRoundsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_fallback_rounds_total",
+		Help: "Total number of rounds read from a fallback source because the drand relays failed",
+	}, []string{labelChainHash, labelSource})
+
+	m.feesTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_set_randomness_fees_wei_total",
+		Help: "Total gas fee in wei paid for mined SetRandomness transactions",
+	}, []string{labelChainID, labelOracleAddress, labelResult})
+
+	m.gasUsedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_set_randomness_gas_used_total",
+		Help: "Total gas used by mined SetRandomness transactions",
+	}, []string{labelChainID, labelOracleAddress, labelResult})
+
+	m.valueTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_set_randomness_value_wei_total",
+		Help: "Total submission fee in wei sent as msg.value with mined SetRandomness transactions",
+	}, []string{labelChainID, labelOracleAddress, labelResult})
+
+	m.retriesTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_retries_total",
+		Help: "Total number of retries of failed drand fetches, RPC reads and broadcasts",
+	}, []string{labelOperation})
+
+	m.retriesExhaustedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_retries_exhausted_total",
+		Help: "Total number of operations that failed on every attempt",
+	}, []string{labelOperation})
+
+	m.retryCircuitOpen = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_retry_circuit_open",
+		Help: "Whether the circuit breaker of an operation is open after repeated failures",
+	}, []string{labelOperation})
+
+	m.retryCircuitOpenedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_retry_circuit_opened_total",
+		Help: "Total number of times the circuit breaker of an operation opened",
+	}, []string{labelOperation})
+
+	m.compromiseDetectedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_compromise_detected_total",
+		Help: "Total number of oracle rounds or drand info failing a security check",
+	}, []string{labelChainHash, labelOracleAddress, labelCheck})
+
+	m.pauseProposalsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_pause_proposals_total",
+		Help: "Total number of oracle pause proposals submitted to the owner Safe",
+	}, []string{labelOracleAddress, labelResult})
+
+	m.archiveVerifiedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_archive_verified_rounds_total",
+		Help: "Total number of indexed rounds re-verified, by result: valid or the failed check",
+	}, []string{labelChainHash, labelOracleAddress, labelResult})
+
+	m.archivePass = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_archive_verification_pass",
+		Help: "Current pass of the archive verification over the rounds index",
+	}, []string{labelChainHash, labelOracleAddress})
+
+	m.topUpsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_sender_topups_total",
+		Help: "Total number of sender top-ups from the treasury, by result: success, failed or cooldown",
+	}, []string{labelChainID, labelUpdaterAddress, labelResult})
+
+	m.topUpWeiTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "drand_sender_topup_wei_total",
+		Help: "Total amount transferred to the sender by the treasury in wei",
+	}, []string{labelChainID, labelUpdaterAddress})
+
+	m.archiveProgress = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "drand_archive_verification_progress_ratio",
+		Help: "Share of the indexed rounds verified in the current archive verification pass",
+	}, []string{labelChainHash, labelOracleAddress})
+
 	return m
 }
 
@@ -120,6 +480,60 @@ func (m *Metrics) SetOracleRound(round float64) {
 	).Set(round)
 }
 
+func (m *Metrics) SetChainClockSkew(skew time.Duration) {
+	m.chainClockSkew.WithLabelValues(fmt.Sprintf("%d", m.chainID)).Set(skew.Seconds())
+}
+
+func (m *Metrics) IncChainClockDelay(result string) {
+	m.chainClockDelaysTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), result).Inc()
+}
+
+func (m *Metrics) IncSelfTest(stage, result string) {
+	m.selfTestsTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), stage, result).Inc()
+}
+
+func (m *Metrics) SetSelfTestSuccess(t time.Time) {
+	m.selfTestLastSuccess.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex()).Set(float64(t.Unix()))
+}
+
+func (m *Metrics) SetStandbyReady(ready bool) {
+	value := 0.0
+	if ready {
+		value = 1
+	}
+	m.standbyReady.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex()).Set(value)
+}
+
+func (m *Metrics) SetAuthorization(paused, signerAuthorized bool) {
+	var pausedValue, authorizedValue float64
+	if paused {
+		pausedValue = 1
+	}
+	if signerAuthorized {
+		authorizedValue = 1
+	}
+	m.oraclePaused.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex()).Set(pausedValue)
+	m.signerAuthorized.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex()).Set(authorizedValue)
+}
+
+func (m *Metrics) IncSubmissionHeld(check string) {
+	m.submissionsHeldTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), check).Inc()
+}
+
+func (m *Metrics) IncRoundCovered(owner string) {
+	m.roundsCoveredTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), owner).Inc()
+}
+
+func (m *Metrics) IncConfirmation(result string) {
+	m.confirmationsTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), result).Inc()
+}
+
+func (m *Metrics) SetCommitted(block, round uint64) {
+	chainID := fmt.Sprintf("%d", m.chainID)
+	m.committedBlock.WithLabelValues(chainID, m.oracleAddress.Hex()).Set(float64(block))
+	m.committedRound.WithLabelValues(chainID, m.oracleAddress.Hex()).Set(float64(round))
+}
+
 func (m *Metrics) IncSetRandomnessSuccess() {
 	m.setRandomnessSuccessTotal.WithLabelValues(
 		fmt.Sprintf("%d", m.chainID),
@@ -127,10 +541,43 @@ func (m *Metrics) IncSetRandomnessSuccess() {
 	).Inc()
 }
 
-func (m *Metrics) IncSetRandomnessFailure() {
+func (m *Metrics) IncKeyRotation(key, result string) {
+	m.keyRotationsTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), key, result).Inc()
+}
+
+func (m *Metrics) SetKeyRotated(key string) {
+	m.keyRotatedAt.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), key).SetToCurrentTime()
+}
+
+func (m *Metrics) SetHibernating(hibernating bool) {
+	value := 0.0
+	if hibernating {
+		value = 1
+	}
+	m.hibernating.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex()).Set(value)
+}
+
+func (m *Metrics) IncHibernationWakeup(reason string) {
+	m.hibernationWakeupsTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), reason).Inc()
+}
+
+func (m *Metrics) SetSubmissionsPaused(paused bool) {
+	value := 0.0
+	if paused {
+		value = 1
+	}
+	m.submissionsPaused.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex()).Set(value)
+}
+
+func (m *Metrics) IncReleasePublished(result string) {
+	m.releasesPublishedTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), result).Inc()
+}
+
+func (m *Metrics) IncSetRandomnessFailure(reason string) {
 	m.setRandomnessFailureTotal.WithLabelValues(
 		fmt.Sprintf("%d", m.chainID),
 		m.oracleAddress.Hex(),
+		reason,
 	).Inc()
 }
 
@@ -144,3 +591,192 @@ func (m *Metrics) SetUpdaterBalance(wei string) {
 		m.updaterAddress.Hex(),
 	).Set(b)
 }
+
+func (m *Metrics) SetStateStoreSize(collection string, bytes int64) {
+	m.stateStoreSize.WithLabelValues(collection).Set(float64(bytes))
+}
+
+func (m *Metrics) AddStateStoreRemoved(collection string, records int) {
+	m.stateStoreRemovedTotal.WithLabelValues(collection).Add(float64(records))
+}
+
+func (m *Metrics) IncIngestedBeacon(result string) {
+	m.ingestedBeaconsTotal.WithLabelValues(m.chainHash, result).Inc()
+}
+
+func (m *Metrics) SetGasPrice(strategy string, wei *big.Int) {
+	price, _ := new(big.Float).SetInt(wei).Float64()
+	m.gasPrice.WithLabelValues(fmt.Sprintf("%d", m.chainID), strategy).Set(price)
+}
+
+// ObserveGasUsage records the estimated and used gas of a mined transaction. A
+// zero estimate means the estimation failed and only the used gas is recorded.
+func (m *Metrics) ObserveGasUsage(estimated, used uint64) {
+	chainID := fmt.Sprintf("%d", m.chainID)
+	m.gasUsed.WithLabelValues(chainID, m.oracleAddress.Hex()).Observe(float64(used))
+	if estimated == 0 {
+		return
+	}
+	m.gasEstimated.WithLabelValues(chainID, m.oracleAddress.Hex()).Observe(float64(estimated))
+	m.gasUsedToEstimatedRatio.WithLabelValues(chainID, m.oracleAddress.Hex()).Observe(float64(used) / float64(estimated))
+}
+
+func (m *Metrics) SetQueueLength(lanes map[Lane]int) {
+	for lane, length := range lanes {
+		m.queueLength.WithLabelValues(string(lane)).Set(float64(length))
+	}
+}
+
+func (m *Metrics) SetPipelineInFlight(rounds int) {
+	m.pipelineInFlight.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex()).Set(float64(rounds))
+}
+
+func (m *Metrics) IncPipelineRequeued() {
+	m.pipelineRequeuedTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex()).Inc()
+}
+
+func (m *Metrics) IncReceiptMismatch(reason string) {
+	m.receiptMismatchesTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.oracleAddress.Hex(), reason).Inc()
+}
+
+func (m *Metrics) SetCircuitBreakerOpen(open bool) {
+	var value float64
+	if open {
+		value = 1
+	}
+	m.circuitBreakerOpen.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.oracleAddress.Hex(),
+	).Set(value)
+}
+
+func (m *Metrics) SetRoundLag(drandRound, oracleRound uint64) {
+	var lag float64
+	if drandRound > oracleRound {
+		lag = float64(drandRound - oracleRound)
+	}
+	m.roundLag.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.oracleAddress.Hex(),
+	).Set(lag)
+}
+
+func (m *Metrics) ObserveSubmissionLatency(latency time.Duration) {
+	m.submissionLatency.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.oracleAddress.Hex(),
+	).Observe(latency.Seconds())
+}
+
+func (m *Metrics) ObserveFeePaid(wei *big.Int) {
+	fee, _ := new(big.Float).SetInt(wei).Float64()
+	m.feePaid.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.oracleAddress.Hex(),
+	).Observe(fee)
+}
+
+// ObserveCalldataBytes records the calldata size of a submission
+func (m *Metrics) ObserveCalldataBytes(form CalldataForm, size int) {
+	m.calldataBytes.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.oracleAddress.Hex(),
+		string(form),
+	).Observe(float64(size))
+}
+
+// IncAuditEntry counts a decision recorded, or failing to be recorded, to
+// the audit log
+func (m *Metrics) IncAuditEntry(decision, result string) {
+	m.auditEntriesTotal.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.oracleAddress.Hex(),
+		decision,
+		result,
+	).Inc()
+}
+
+func (m *Metrics) SetCatchUpEstimatedCost(wei string) {
+	cost, _ := new(big.Float).SetString(wei)
+	c, _ := cost.Float64()
+	m.catchUpEstimatedCost.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.oracleAddress.Hex(),
+	).Set(c)
+}
+
+func (m *Metrics) SetNonceGaps(gaps int) {
+	m.nonceGaps.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.updaterAddress.Hex(),
+	).Set(float64(gaps))
+}
+
+func (m *Metrics) AddNonceGapsFilled(filled int) {
+	m.nonceGapsFilledTotal.WithLabelValues(
+		fmt.Sprintf("%d", m.chainID),
+		m.updaterAddress.Hex(),
+	).Add(float64(filled))
+}
+
+func (m *Metrics) IncFallbackRound(source string) {
+	m.fallbackRoundsTotal.WithLabelValues(m.chainHash, source).Inc()
+}
+
+func (m *Metrics) IncRetry(operation string) {
+	m.retriesTotal.WithLabelValues(operation).Inc()
+}
+
+func (m *Metrics) IncRetryExhausted(operation string) {
+	m.retriesExhaustedTotal.WithLabelValues(operation).Inc()
+}
+
+func (m *Metrics) SetRetryCircuitOpen(operation string, open bool) {
+	var value float64
+	if open {
+		value = 1
+		m.retryCircuitOpenedTotal.WithLabelValues(operation).Inc()
+	}
+	m.retryCircuitOpen.WithLabelValues(operation).Set(value)
+}
+
+// AddTransactionCost adds a mined transaction to the cost counters, result
+// being success or failure
+func (m *Metrics) AddTransactionCost(result string, gasUsed uint64, fee, value *big.Int) {
+	chainID := fmt.Sprintf("%d", m.chainID)
+	feeWei, _ := new(big.Float).SetInt(fee).Float64()
+	valueWei, _ := new(big.Float).SetInt(value).Float64()
+	m.feesTotal.WithLabelValues(chainID, m.oracleAddress.Hex(), result).Add(feeWei)
+	m.gasUsedTotal.WithLabelValues(chainID, m.oracleAddress.Hex(), result).Add(float64(gasUsed))
+	m.valueTotal.WithLabelValues(chainID, m.oracleAddress.Hex(), result).Add(valueWei)
+}
+
+func (m *Metrics) IncCompromiseDetected(check string) {
+	m.compromiseDetectedTotal.WithLabelValues(m.chainHash, m.oracleAddress.Hex(), check).Inc()
+}
+
+func (m *Metrics) IncPauseProposal(result string) {
+	m.pauseProposalsTotal.WithLabelValues(m.oracleAddress.Hex(), result).Inc()
+}
+
+func (m *Metrics) IncArchiveVerified(result string) {
+	m.archiveVerifiedTotal.WithLabelValues(m.chainHash, m.oracleAddress.Hex(), result).Inc()
+}
+
+func (m *Metrics) SetArchiveProgress(pass, verified, rounds int) {
+	ratio := 1.0
+	if rounds > 0 {
+		ratio = float64(verified) / float64(rounds)
+	}
+	m.archivePass.WithLabelValues(m.chainHash, m.oracleAddress.Hex()).Set(float64(pass))
+	m.archiveProgress.WithLabelValues(m.chainHash, m.oracleAddress.Hex()).Set(ratio)
+}
+
+func (m *Metrics) IncTopUp(result string) {
+	m.topUpsTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.updaterAddress.Hex(), result).Inc()
+}
+
+func (m *Metrics) AddTopUpAmount(wei *big.Int) {
+	amount, _ := new(big.Float).SetInt(wei).Float64()
+	m.topUpWeiTotal.WithLabelValues(fmt.Sprintf("%d", m.chainID), m.updaterAddress.Hex()).Add(amount)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/sender"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+const nonceCheckInterval = 1 * time.Minute
+
+// monitorNonces periodically reconciles the sender's nonces with the node and
+// fills the gaps left by dropped transactions. A gap is only filled once it has
+// been seen on two consecutive checks, so a transaction that has not reached
+// every node behind the RPC yet is not replaced.
+func (u *Updater) monitorNonces(ctx context.Context) error {
+	// The account poller feeds the nonces to monitorAccounts
+	if u.options.DryRun || u.options.Accounts != nil {
+		return nil
+	}
+	var previousGaps []uint64
+	for {
+		if err := u.idleWait(ctx, nonceCheckInterval); err != nil {
+			return err
+		}
+		previousGaps = u.checkNonces(ctx, previousGaps)
+	}
+}
+
+// checkNonces reconciles the nonces once and returns the gaps left unfilled
+func (u *Updater) checkNonces(ctx context.Context, previousGaps []uint64) []uint64 {
+	state, err := u.nonces.State(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to reconcile sender nonces")
+		return previousGaps
+	}
+	return u.nonceStateChecked(ctx, state, previousGaps)
+}
+
+// nonceStateChecked reports the gaps of a reconciled nonce state, fills the
+// ones already seen on the previous check, and returns the gaps left unfilled
+func (u *Updater) nonceStateChecked(ctx context.Context, state sender.NonceState, previousGaps []uint64) []uint64 {
+	u.metrics.SetNonceGaps(len(state.Gaps))
+	if len(state.Gaps) == 0 {
+		return nil
+	}
+
+	log.Warn().
+		Uint64("next", state.Next).
+		Uint64("pending", state.Pending).
+		Uint64("latest", state.Latest).
+		Interface("gaps", state.Gaps).
+		Msg("Detected sender nonce gaps")
+	if len(previousGaps) == 0 || previousGaps[0] != state.Gaps[0] {
+		return state.Gaps
+	}
+
+	if !u.isLeader() {
+		return state.Gaps
+	}
+	gasPrice, err := u.options.GasStrategy.GasPrice(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get gas price to fill nonce gaps")
+		return state.Gaps
+	}
+	filled, err := u.nonces.FillGaps(ctx, u.chainID, gasPrice)
+	u.metrics.AddNonceGapsFilled(len(filled))
+	if err != nil {
+		return state.Gaps
+	}
+	return nil
+}
+
+// newNonceManager is a helper for NewUpdater, whose sender parameter shadows the package
+func newNonceManager(rpcClient *ethclient.Client, s *sender.Sender) *sender.NonceManager {
+	return sender.NewNonceManager(rpcClient, s)
+}
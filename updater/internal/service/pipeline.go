@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/pipeline"
+	"drand-oracle-updater/internal/retry"
+	"sync"
+	"sync/atomic"
+
+	"github.com/drand/drand/client"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// presignature is the EIP-712 signature of a round signed ahead of its
+// submission
+type presignature struct {
+	signer    common.Address
+	signature []byte
+}
+
+// fetchBacklog fetches the rounds from round up to latest that the catch-up
+// policy submits, and queues them in order. Rounds are fetched, verified by
+// the drand client, and signed ahead when enabled, by bounded pools of
+// workers. The first round failing is returned, the rounds following it are
+// not queued.
+func (u *Updater) fetchBacklog(ctx context.Context, round, latest uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rounds := make(chan *roundData)
+	go func() {
+		defer close(rounds)
+		for ; round <= latest; round = u.catchUpNext(round+1, latest) {
+			_, span := u.startRoundSpan(ctx, round, LaneBackfill)
+			select {
+			case rounds <- &roundData{round: round, span: span}:
+			case <-ctx.Done():
+				endSpan(span, ctx.Err())
+				return
+			}
+		}
+	}()
+
+	stages := []pipeline.Stage[*roundData]{{
+		Name:    "fetch",
+		Workers: u.options.CatchUpFetchWorkers,
+		Process: u.fetchRound,
+	}}
+	if u.options.CatchUpSignWorkers > 0 {
+		stages = append(stages, pipeline.Stage[*roundData]{
+			Name:    "sign",
+			Workers: u.options.CatchUpSignWorkers,
+			Process: u.presignRound,
+		})
+	}
+
+	var failed error
+	for result := range pipeline.Run(ctx, rounds, stages...) {
+		rd := result.Item
+		if failed != nil {
+			rd.endSpan(failed)
+			continue
+		}
+		err := result.Err
+		if err == nil {
+			err = u.scheduler.Push(ctx, rd, LaneBackfill)
+			if err == nil {
+				continue
+			}
+		}
+		// The rounds in flight are drained, failing with the cancelled context
+		rd.endSpan(err)
+		if result.Stage != "" && ctx.Err() == nil {
+			log.Error().Err(err).Uint64("round", rd.round).Str("stage", result.Stage).Msg("Catch-up pipeline stage failed")
+		}
+		failed = err
+		cancel()
+	}
+	return failed
+}
+
+// fetchRound fetches a catch-up round from the drand network
+func (u *Updater) fetchRound(ctx context.Context, rd *roundData) (*roundData, error) {
+	release, err := u.options.Limits.AcquireCatchUp(ctx)
+	if err != nil {
+		return rd, err
+	}
+	// The slot bounds the concurrent fetches, queued rounds are bounded by
+	// the queue
+	defer release()
+	fetchCtx, span := tracer.Start(rd.context(ctx), "drand_fetch")
+	result, err := retry.Value(fetchCtx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+		return u.drandClient.Get(ctx, rd.round)
+	})
+	endSpan(span, err)
+	if err != nil {
+		return rd, err
+	}
+	rd.randomness = result.Randomness()
+	rd.signature = result.Signature()
+	rd.source = u.observeSource(result)
+	return rd, nil
+}
+
+// presignRound signs the EIP-712 message of a catch-up round ahead of its
+// submission, with the current signer
+func (u *Updater) presignRound(ctx context.Context, rd *roundData) (*roundData, error) {
+	// A signature by a signer rotated in meanwhile is signed again on
+	// submission, its address not matching
+	address := u.signer.Address()
+	signCtx, span := tracer.Start(rd.context(ctx), "sign")
+	signature, err := u.signer.SignSetRandomness(signCtx, rd.round, u.roundTimestamp(rd.round), [32]byte(rd.randomness), rd.signature)
+	endSpan(span, err)
+	if err != nil {
+		return rd, err
+	}
+	rd.presigned = &presignature{signer: address, signature: signature}
+	return rd, nil
+}
+
+// submissionPipeline holds the catch-up rounds broadcast before the previous
+// rounds are mined. Transactions are broadcast in round order with increasing
+// nonces, so they are mined in order too. A failed round fails the rounds
+// broadcast after it, which are requeued once every round in flight is mined.
+type submissionPipeline struct {
+	// slots bounds the rounds broadcast and not mined yet
+	slots chan struct{}
+	// pending are the broadcast rounds in order, confirmed by the goroutine of startPipeline
+	pending chan *pendingRound
+	// round is the latest round broadcast, 0 while no round is in flight
+	round atomic.Uint64
+
+	mu       sync.Mutex
+	inFlight int
+	// idle is closed while no round is in flight
+	idle chan struct{}
+	// release releases the submissions, held while rounds are in flight so
+	// that other submissions and signer rotations wait for them
+	release func()
+	// failed is set once a round in flight failed, until the pipeline drained
+	failed bool
+	// requeue are the rounds to requeue once the pipeline drained
+	requeue []*roundData
+}
+
+func newSubmissionPipeline(depth int) *submissionPipeline {
+	if depth <= 1 {
+		return nil
+	}
+	idle := make(chan struct{})
+	close(idle)
+	return &submissionPipeline{
+		slots: make(chan struct{}, depth),
+		idle:  idle,
+	}
+}
+
+// submittedRound returns the latest round set on the oracle or broadcast by
+// the submission pipeline
+func (u *Updater) submittedRound() uint64 {
+	latest := u.GetLatestOracleRound()
+	if u.pipelined != nil {
+		latest = max(latest, u.pipelined.round.Load())
+	}
+	return latest
+}
+
+// pipelinable reports whether a popped round is broadcast without waiting
+// for the rounds in flight: a catch-up round submitted alone in its own
+// transaction, at its first attempt
+func (u *Updater) pipelinable(rd *roundData, batch []*roundData) bool {
+	return u.pipelined != nil && rd.lane == LaneBackfill && len(batch) == 1 && rd.attempts == 0 &&
+		!u.options.DryRun && u.options.UserOperations == nil
+}
+
+// startPipeline starts confirming the rounds broadcast by the submission
+// pipeline with ctx. The returned function waits for the rounds in flight to
+// be confirmed.
+func (u *Updater) startPipeline(ctx context.Context) (wait func()) {
+	p := u.pipelined
+	if p == nil {
+		return func() {}
+	}
+	p.pending = make(chan *pendingRound, cap(p.slots))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for pending := range p.pending {
+			// Rounds broadcast after a failed round are still waited for,
+			// as their nonces are used
+			err := u.chain.confirmRound(pending.rd.context(ctx), pending)
+			u.roundConfirmed(pending.rd, err)
+		}
+	}()
+	return func() {
+		close(p.pending)
+		<-done
+	}
+}
+
+// pipelineRound broadcasts a catch-up round without waiting for the rounds
+// in flight to be mined, its confirmation being left to the pipeline. A
+// round that cannot be broadcast is requeued once the pipeline drained, and
+// retried then as any other round.
+func (u *Updater) pipelineRound(ctx context.Context, rd *roundData) error {
+	p := u.pipelined
+	for _, wait := range []func(context.Context) error{u.waitForResume, u.waitForBreaker, u.waitForAuthorization} {
+		if err := wait(ctx); err != nil {
+			rd.endSpan(err)
+			return err
+		}
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		rd.endSpan(ctx.Err())
+		return ctx.Err()
+	}
+
+	p.mu.Lock()
+	if p.failed {
+		// The round would fail along with the rounds in flight
+		p.requeue = append(p.requeue, rd)
+		p.mu.Unlock()
+		<-p.slots
+		return nil
+	}
+	if p.inFlight == 0 {
+		// Only processRounds broadcasts, so no round is in flight while the
+		// submissions are acquired
+		p.mu.Unlock()
+		release, err := u.acquireSubmissions(ctx)
+		if err != nil {
+			<-p.slots
+			rd.endSpan(err)
+			return err
+		}
+		p.mu.Lock()
+		p.release = release
+		p.idle = make(chan struct{})
+	}
+	p.inFlight++
+	p.mu.Unlock()
+	u.metrics.SetPipelineInFlight(len(p.slots))
+	u.setInFlightRound(rd.round)
+
+	attemptCtx, span := tracer.Start(rd.context(ctx), "submit", trace.WithAttributes(
+		attrAttempt.Int(1),
+		attrFirstRound.Int64(int64(rd.round)),
+		attrLastRound.Int64(int64(rd.round)),
+	))
+	pending, err := u.chain.broadcastRound(attemptCtx, rd)
+	endSpan(span, err)
+	if err != nil || pending == nil {
+		u.roundConfirmed(rd, err)
+		return nil
+	}
+	p.round.Store(rd.round)
+	p.pending <- pending
+	return nil
+}
+
+// roundConfirmed ends the submission of a round in flight, requeueing the
+// failed rounds once the last one in flight ended
+func (u *Updater) roundConfirmed(rd *roundData, err error) {
+	p := u.pipelined
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight--
+	<-p.slots
+	u.metrics.SetPipelineInFlight(len(p.slots))
+
+	if err != nil {
+		// Only the first failed round counts an attempt, the following
+		// rounds failing because of it
+		if !p.failed {
+			rd.attempts++
+			log.Warn().Err(err).Uint64("round", rd.round).Msg("Pipelined round failed, requeueing the rounds in flight")
+		}
+		p.failed = true
+		p.requeue = append(p.requeue, rd)
+	} else {
+		rd.endSpan(nil)
+		u.debugRoundsProcessed(1)
+	}
+	if p.inFlight > 0 {
+		return
+	}
+
+	p.round.Store(0)
+	latestOracleRound := u.GetLatestOracleRound()
+	for _, requeued := range p.requeue {
+		if requeued.round <= latestOracleRound {
+			requeued.endSpan(nil)
+			continue
+		}
+		u.metrics.IncPipelineRequeued()
+		u.scheduler.Requeue(requeued)
+	}
+	p.requeue = nil
+	p.failed = false
+	p.release()
+	p.release = nil
+	close(p.idle)
+	u.setInFlightRound(0)
+	// Rounds ahead of the oracle were submittable while in flight
+	u.scheduler.Wake()
+}
+
+// drainPipeline waits for the rounds in flight to be confirmed, before a
+// round is submitted outside the pipeline
+func (u *Updater) drainPipeline(ctx context.Context) error {
+	p := u.pipelined
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	idle := p.idle
+	p.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
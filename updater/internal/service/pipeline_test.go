@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/chaintest"
+	"drand-oracle-updater/internal/retry"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testBlockTime is the interval blocks are mined at by the tests
+const testBlockTime = 100 * time.Millisecond
+
+// startProcessing submits the queued rounds until the test ends
+func startProcessing(tb testing.TB, u *Updater) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = u.processRounds(ctx, ctx)
+	}()
+	tb.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// catchUpBacklog has drand produce rounds new rounds, and catches them up
+// through the catch-up pipeline until the oracle is set to the last one
+func catchUpBacklog(tb testing.TB, u *Updater, drand *testDrand, rounds uint64) {
+	tb.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	from := u.GetLatestOracleRound() + 1
+	latest := from + rounds - 1
+	drand.latest.Store(latest)
+	if err := u.fetchBacklog(ctx, from, latest); err != nil {
+		tb.Fatalf("fetching backlog: %v", err)
+	}
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for u.GetLatestOracleRound() < latest {
+		select {
+		case <-ctx.Done():
+			tb.Fatalf("oracle at round %d, want %d", u.GetLatestOracleRound(), latest)
+		case <-ticker.C:
+		}
+	}
+}
+
+// benchBacklog is the number of rounds caught up by a benchmark iteration
+const benchBacklog = 16
+
+func BenchmarkCatchUp(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		options Options
+	}{
+		{"sequential", Options{CatchUpFetchWorkers: 1, InFlightRounds: 1}},
+		{"pipelined", Options{CatchUpFetchWorkers: 4, CatchUpSignWorkers: 2, InFlightRounds: 8}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			backend := chaintest.New(b, chaintest.EVM, nil)
+			drand := newTestDrand(b, 0)
+			bm.options.QueueSize = benchBacklog
+			u := newTestUpdater(b, backend, drand, bm.options)
+			backend.AutoCommit(b, testBlockTime)
+			startProcessing(b, u)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				catchUpBacklog(b, u, drand, benchBacklog)
+			}
+			b.ReportMetric(float64(b.N*benchBacklog)/b.Elapsed().Seconds(), "rounds/s")
+		})
+	}
+}
+
+func TestSubmissionPipelineOrder(t *testing.T) {
+	chaintest.Run(t, func(t *testing.T, b *chaintest.Backend) {
+		const rounds = 12
+		drand := newTestDrand(t, 0)
+		u := newTestUpdater(t, b, drand, Options{CatchUpFetchWorkers: 4, InFlightRounds: 4, QueueSize: rounds})
+		b.AutoCommit(t, testBlockTime)
+		startProcessing(t, u)
+		catchUpBacklog(t, u, drand, rounds)
+
+		txs, err := u.store.Transactions(time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatalf("transactions: %v", err)
+		}
+		if len(txs) != rounds {
+			t.Fatalf("recorded %d transactions, want %d", len(txs), rounds)
+		}
+		blocks := make(map[uint64]bool)
+		for i, tx := range txs {
+			if tx.Round != uint64(i+1) {
+				t.Errorf("transaction %d set round %d, want %d", i, tx.Round, i+1)
+			}
+			if i > 0 && (tx.Nonce <= txs[i-1].Nonce || tx.BlockNumber < txs[i-1].BlockNumber) {
+				t.Errorf("round %d mined at nonce %d in block %d, before round %d at nonce %d in block %d",
+					tx.Round, tx.Nonce, tx.BlockNumber, txs[i-1].Round, txs[i-1].Nonce, txs[i-1].BlockNumber)
+			}
+			blocks[tx.BlockNumber] = true
+		}
+		// Rounds broadcast without waiting for the previous ones share blocks
+		if len(blocks) == rounds {
+			t.Errorf("every round was mined in its own block, the rounds were not pipelined")
+		}
+	})
+}
+
+// inFlight puts rounds in flight in the submission pipeline as pipelineRound
+// does, counting the releases of the submissions
+func inFlight(u *Updater, released *int, rounds ...uint64) []*roundData {
+	p := u.pipelined
+	p.release = func() { *released++ }
+	p.idle = make(chan struct{})
+	var rds []*roundData
+	for _, round := range rounds {
+		p.slots <- struct{}{}
+		p.inFlight++
+		p.round.Store(round)
+		rds = append(rds, &roundData{round: round, lane: LaneBackfill})
+	}
+	return rds
+}
+
+func TestSubmissionPipelineRequeue(t *testing.T) {
+	b := chaintest.New(t, chaintest.EVM, nil)
+	u := newTestUpdater(t, b, newTestDrand(t, 4), Options{InFlightRounds: 4, QueueSize: 4})
+	p := u.pipelined
+	released := 0
+	rds := inFlight(u, &released, 1, 2, 3)
+
+	u.roundSet(1, 0)
+	u.roundConfirmed(rds[0], nil)
+	u.roundConfirmed(rds[1], errors.New("transaction reverted"))
+	if !p.failed {
+		t.Fatal("pipeline not failed after a failed round")
+	}
+
+	// A round popped after the failure is not broadcast, it would fail along
+	rd4 := &roundData{round: 4, lane: LaneBackfill}
+	if err := u.pipelineRound(context.Background(), rd4); err != nil {
+		t.Fatalf("pipelining round 4: %v", err)
+	}
+	if len(p.slots) != 1 {
+		t.Errorf("%d rounds in flight, want 1", len(p.slots))
+	}
+	if released != 0 {
+		t.Error("submissions released while a round is in flight")
+	}
+
+	u.roundConfirmed(rds[2], errors.New("nonce too high"))
+	if p.failed || p.requeue != nil || p.inFlight != 0 || p.round.Load() != 0 {
+		t.Errorf("pipeline not reset once drained: failed %v, requeued %d, in flight %d, round %d",
+			p.failed, len(p.requeue), p.inFlight, p.round.Load())
+	}
+	if released != 1 {
+		t.Errorf("submissions released %d times, want 1", released)
+	}
+	select {
+	case <-p.idle:
+	default:
+		t.Error("pipeline not idle once drained")
+	}
+	// Only the first failed round counts an attempt
+	if rds[1].attempts != 1 || rds[2].attempts != 0 || rd4.attempts != 0 {
+		t.Errorf("attempts = %d, %d, %d, want 1, 0, 0", rds[1].attempts, rds[2].attempts, rd4.attempts)
+	}
+	queued := u.scheduler.Status().Rounds
+	slices.Sort(queued)
+	if !slices.Equal(queued, []uint64{2, 3, 4}) {
+		t.Errorf("requeued rounds %v, want [2 3 4]", queued)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rd, err := u.scheduler.Pop(ctx)
+	if err != nil {
+		t.Fatalf("popping: %v", err)
+	}
+	if rd.round != 2 {
+		t.Errorf("popped round %d first, want 2", rd.round)
+	}
+	if u.pipelinable(rd, []*roundData{rd}) {
+		t.Error("failed round submitted through the pipeline again")
+	}
+}
+
+func TestSubmissionPipelineSkipsSetRounds(t *testing.T) {
+	b := chaintest.New(t, chaintest.EVM, nil)
+	u := newTestUpdater(t, b, newTestDrand(t, 3), Options{InFlightRounds: 4, QueueSize: 4})
+	released := 0
+	rds := inFlight(u, &released, 1, 2, 3)
+
+	u.roundConfirmed(rds[0], errors.New("timeout"))
+	// The transactions were mined after all, as seen by the watcher
+	u.roundSet(2, 0)
+	u.roundConfirmed(rds[1], errors.New("timeout"))
+	u.roundConfirmed(rds[2], errors.New("timeout"))
+
+	if queued := u.scheduler.Status().Rounds; !slices.Equal(queued, []uint64{3}) {
+		t.Errorf("requeued rounds %v, want [3]", queued)
+	}
+	if released != 1 {
+		t.Errorf("submissions released %d times, want 1", released)
+	}
+}
+
+func TestFetchBacklogFailure(t *testing.T) {
+	b := chaintest.New(t, chaintest.EVM, nil)
+	drand := newTestDrand(t, 8)
+	drand.failing.Store(5)
+	u := newTestUpdater(t, b, drand, Options{
+		CatchUpFetchWorkers: 4,
+		InFlightRounds:      4,
+		QueueSize:           8,
+		Retry:               retry.Policy{MaxAttempts: 1},
+	})
+
+	if err := u.fetchBacklog(context.Background(), 1, 8); err == nil {
+		t.Fatal("backlog fetched despite a failing round")
+	}
+	// The rounds following the failed round are not queued
+	queued := u.scheduler.Status().Rounds
+	slices.Sort(queued)
+	if !slices.Equal(queued, []uint64{1, 2, 3, 4}) {
+		t.Errorf("queued rounds %v, want [1 2 3 4]", queued)
+	}
+}
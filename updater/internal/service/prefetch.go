@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// earliestSetTime returns the first instant the contract accepts a round, or
+// the zero time when it enforces no delay
+func (u *Updater) earliestSetTime(roundTimestamp uint64) time.Time {
+	if u.options.MinSetDelay <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(roundTimestamp), 0).Add(u.options.MinSetDelay)
+}
+
+// awaitSetWindow simulates the setRandomness call of a prepared round at the
+// earliest block timestamp the contract accepts it, then waits for that
+// instant, so that the transaction is broadcast as soon as it is permitted.
+//
+// The simulation overrides the block timestamp, which not every node
+// supports. It only warns when it fails, as the round is submitted anyway.
+func (u *Updater) awaitSetWindow(
+	ctx context.Context,
+	random binding.IDrandOracleRandom,
+	signature []byte,
+	gasPrice *big.Int,
+	earliest time.Time,
+) error {
+	wait := time.Until(earliest)
+	if wait <= 0 {
+		return nil
+	}
+
+	msg, err := u.setRandomnessMsg(random, signature, gasPrice)
+	if err != nil {
+		return err
+	}
+	_, err = gethclient.New(u.rpcClient.Client()).CallContractWithBlockOverrides(ctx, msg, nil, nil, gethclient.BlockOverrides{
+		Time: uint64(earliest.Unix()),
+	})
+	if err != nil {
+		log.Warn().Err(err).Uint64("round", random.Round).Msg("Failed to simulate setRandomness at the earliest permitted time")
+	}
+
+	log.Info().
+		Uint64("round", random.Round).
+		Dur("wait", wait).
+		Time("earliest", earliest).
+		Msg("Round prepared, waiting for the contract's set delay")
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"drand-oracle-updater/alerting"
+	"drand-oracle-updater/binding"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Reasons of receipt mismatches
+const (
+	receiptMissing    = "missing"
+	receiptDuplicate  = "duplicate"
+	receiptRandomness = "randomness"
+	receiptSignature  = "signature"
+	receiptUnexpected = "unexpected"
+	receiptMalformed  = "malformed"
+)
+
+// receiptMismatch is a difference between the RandomnessUpdated events of a
+// receipt and the rounds submitted by its transaction
+type receiptMismatch struct {
+	reason string
+	round  uint64
+}
+
+// verifyReceipt checks that the logs of a successful transaction or user
+// operation emit exactly one RandomnessUpdated event from the oracle for each
+// submitted round, with the submitted randomness and signature, and no event
+// for other rounds. A mismatch means the binding drifted from the deployed
+// ABI or the oracle proxy does not run the expected implementation. It is
+// counted and alerted, but does not fail the round, as the transaction is
+// already mined.
+func (u *Updater) verifyReceipt(logs []*types.Log, txHash string, submitted ...binding.IDrandOracleRandom) {
+	contractABI, err := binding.BindingMetaData.GetAbi()
+	if err != nil {
+		log.Error().Err(err).Str("hash", txHash).Msg("Failed to parse the oracle ABI, receipt not verified")
+		return
+	}
+	topic := contractABI.Events["RandomnessUpdated"].ID
+	mismatches := receiptMismatches(u.binding, u.oracleAddress, topic, logs, submitted)
+	for _, mismatch := range mismatches {
+		u.metrics.IncReceiptMismatch(mismatch.reason)
+		log.Error().
+			Uint64("round", mismatch.round).
+			Str("hash", txHash).
+			Str("reason", mismatch.reason).
+			Msg("RandomnessUpdated event of receipt does not match the submitted round")
+	}
+	if len(mismatches) == 0 {
+		return
+	}
+
+	first := mismatches[0]
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionReceiptMismatch,
+		Severity:  alerting.SeverityCritical,
+		Summary:   fmt.Sprintf("RandomnessUpdated event of round %d does not match the submitted round: %s", first.round, first.reason),
+		Details: map[string]string{
+			"hash":       txHash,
+			"round":      strconv.FormatUint(first.round, 10),
+			"reason":     first.reason,
+			"mismatches": strconv.Itoa(len(mismatches)),
+		},
+	})
+}
+
+// receiptMismatches compares the RandomnessUpdated events, of the given topic,
+// emitted by oracle among logs to the submitted rounds
+func receiptMismatches(b *binding.Binding, oracle common.Address, topic common.Hash, logs []*types.Log, submitted []binding.IDrandOracleRandom) []receiptMismatch {
+	events := make(map[uint64][]*binding.BindingRandomnessUpdated)
+	var mismatches []receiptMismatch
+	for _, l := range logs {
+		if l.Address != oracle || len(l.Topics) == 0 || l.Topics[0] != topic {
+			continue
+		}
+		event, err := b.ParseRandomnessUpdated(*l)
+		if err != nil {
+			// The event signature matches but its data does not decode
+			mismatches = append(mismatches, receiptMismatch{reason: receiptMalformed})
+			continue
+		}
+		events[event.Round] = append(events[event.Round], event)
+	}
+
+	for _, random := range submitted {
+		emitted := events[random.Round]
+		delete(events, random.Round)
+		switch {
+		case len(emitted) == 0:
+			mismatches = append(mismatches, receiptMismatch{reason: receiptMissing, round: random.Round})
+			continue
+		case len(emitted) > 1:
+			mismatches = append(mismatches, receiptMismatch{reason: receiptDuplicate, round: random.Round})
+		}
+		event := emitted[0]
+		if event.Randomness != random.Randomness {
+			log.Debug().
+				Uint64("round", random.Round).
+				Str("submitted", hex.EncodeToString(random.Randomness[:])).
+				Str("emitted", hex.EncodeToString(event.Randomness[:])).
+				Msg("Receipt randomness mismatch")
+			mismatches = append(mismatches, receiptMismatch{reason: receiptRandomness, round: random.Round})
+		}
+		if !bytes.Equal(event.Signature, random.Signature) {
+			mismatches = append(mismatches, receiptMismatch{reason: receiptSignature, round: random.Round})
+		}
+	}
+	for round := range events {
+		mismatches = append(mismatches, receiptMismatch{reason: receiptUnexpected, round: round})
+	}
+	return mismatches
+}
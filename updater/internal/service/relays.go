@@ -0,0 +1,16 @@
+package service
+
+import "drand-oracle-updater/internal/drandpool"
+
+// DrandRelays reports the health and selection of the drand relays
+type DrandRelays interface {
+	Status() []drandpool.RelayStatus
+}
+
+// DrandRelays returns the health of the drand relays, in configuration order
+func (u *Updater) DrandRelays() []drandpool.RelayStatus {
+	if u.options.DrandRelays == nil {
+		return []drandpool.RelayStatus{}
+	}
+	return u.options.DrandRelays.Status()
+}
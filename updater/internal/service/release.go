@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"drand-oracle-updater/internal/release"
+	"drand-oracle-updater/internal/store"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// releaseCheckInterval is the interval between checks for days to publish
+	releaseCheckInterval = 1 * time.Hour
+
+	// releaseGrace is how long after the end of a day its rounds are
+	// published, so that the rounds set in its last blocks are indexed
+	releaseGrace = 15 * time.Minute
+
+	// anchorPrefix prefixes the data of the transaction anchoring a release
+	anchorPrefix = "drand-oracle-release:"
+)
+
+// Releases publishes each day of rounds set on the oracle as a signed file
+// pinned to IPFS
+type Releases struct {
+	// Key signs the releases
+	Key *ecdsa.PrivateKey
+
+	// Pinner pins the release files
+	Pinner release.Pinner
+
+	// Anchor records the CID of every release on-chain, in the data of a
+	// zero-value transaction of the sender to itself
+	Anchor bool
+}
+
+// Releases returns the published releases
+func (u *Updater) Releases() ([]store.Release, error) {
+	return u.store.Releases()
+}
+
+// publishReleases publishes the days of rounds once over, every release check
+// interval. A replica only publishes while it is the leader.
+func (u *Updater) publishReleases(ctx context.Context) error {
+	if u.options.Releases == nil {
+		return nil
+	}
+	for {
+		if u.isLeader() {
+			u.publishDueReleases(ctx)
+		}
+		if err := u.idleWait(ctx, releaseCheckInterval); err != nil {
+			return nil
+		}
+	}
+}
+
+// publishDueReleases publishes the days following the latest release, or
+// yesterday without any release, whose end is past the grace period
+func (u *Updater) publishDueReleases(ctx context.Context) {
+	releases, err := u.store.Releases()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read releases")
+		return
+	}
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	day := today.AddDate(0, 0, -1)
+	if len(releases) > 0 {
+		last, err := time.Parse(release.DateLayout, releases[len(releases)-1].Date)
+		if err == nil {
+			day = last.AddDate(0, 0, 1)
+		}
+	}
+	for ; !day.AddDate(0, 0, 1).Add(releaseGrace).After(now); day = day.AddDate(0, 0, 1) {
+		if err := u.publishRelease(ctx, day); err != nil {
+			u.metrics.IncReleasePublished("failure")
+			log.Error().Err(err).Str("date", day.Format(release.DateLayout)).Msg("Failed to publish release, retrying next check")
+			return
+		}
+	}
+}
+
+// publishRelease verifies, signs, pins and records the rounds set during a day
+func (u *Updater) publishRelease(ctx context.Context, day time.Time) error {
+	date := day.Format(release.DateLayout)
+	indexed, err := u.store.RoundsBetween(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("error reading rounds index: %w", err)
+	}
+	if len(indexed) == 0 {
+		log.Debug().Str("date", date).Msg("No round set during the day, nothing to publish")
+		return nil
+	}
+	byRound := make(map[uint64]store.Round, len(indexed))
+	for _, round := range indexed {
+		if _, ok := byRound[round.Round]; !ok {
+			byRound[round.Round] = round
+		}
+	}
+	rounds := make([]store.Round, 0, len(byRound))
+	for _, round := range byRound {
+		rounds = append(rounds, round)
+	}
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i].Round < rounds[j].Round })
+
+	bundle := release.Bundle{
+		Version:   release.Version,
+		Date:      date,
+		ChainHash: u.drandInfo.HashString(),
+		ChainID:   u.chainID,
+		Oracle:    u.oracleAddress.Hex(),
+		Scheme:    u.drandInfo.Scheme,
+		PublicKey: u.drandInfo.PublicKey.String(),
+		Beacons:   make([]release.Beacon, len(rounds)),
+	}
+	for i, round := range rounds {
+		var previous *store.Round
+		if i > 0 && rounds[i-1].Round == round.Round-1 {
+			previous = &rounds[i-1]
+		}
+		if check, detail, _ := u.verifyArchivedRound(ctx, round, previous); check != "" {
+			return fmt.Errorf("round %d failed the %s check: %s", round.Round, check, detail)
+		}
+		bundle.Beacons[i] = release.Beacon{
+			Round:       round.Round,
+			Randomness:  round.Randomness,
+			Signature:   round.Signature,
+			BlockTime:   round.Timestamp,
+			BlockNumber: round.BlockNumber,
+			TxHash:      round.TxHash,
+			LogIndex:    round.LogIndex,
+		}
+	}
+
+	signed, err := release.Sign(bundle, u.options.Releases.Key)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(signed)
+	if err != nil {
+		return err
+	}
+	cid, err := u.options.Releases.Pinner.Pin(ctx, fmt.Sprintf("drand-oracle-%s-%s.json", u.oracleAddress.Hex(), date), data)
+	if err != nil {
+		return fmt.Errorf("error pinning release: %w", err)
+	}
+
+	record := store.Release{
+		Date:        date,
+		CID:         cid,
+		FirstRound:  rounds[0].Round,
+		LastRound:   rounds[len(rounds)-1].Round,
+		Beacons:     len(rounds),
+		Publisher:   signed.Publisher,
+		PublishedAt: time.Now().UTC(),
+	}
+	if u.options.Releases.Anchor {
+		tx, err := u.anchorRelease(ctx, date, cid)
+		if err != nil {
+			// The release is pinned, it is recorded unanchored rather than
+			// pinned again
+			log.Error().Err(err).Str("date", date).Str("cid", cid).Msg("Failed to anchor release on-chain")
+		} else {
+			record.AnchorTxHash = tx.Hash().Hex()
+		}
+	}
+	if err := u.store.AppendRelease(record); err != nil {
+		return fmt.Errorf("error recording release: %w", err)
+	}
+
+	u.metrics.IncReleasePublished("success")
+	log.Info().
+		Str("date", date).
+		Str("cid", cid).
+		Int("beacons", record.Beacons).
+		Str("anchor_tx_hash", record.AnchorTxHash).
+		Msg("Release published to IPFS")
+	details := map[string]string{
+		"date":        date,
+		"cid":         cid,
+		"first_round": fmt.Sprintf("%d", record.FirstRound),
+		"last_round":  fmt.Sprintf("%d", record.LastRound),
+	}
+	if record.AnchorTxHash != "" {
+		details["anchor_tx_hash"] = record.AnchorTxHash
+	}
+	u.recordEvent(kindReleasePublished, record.LastRound, fmt.Sprintf("Rounds of %s published to IPFS as %s", date, cid), details)
+	return nil
+}
+
+// anchorRelease records the CID of a release in the data of a zero-value
+// transaction of the sender to itself, and waits for it to be mined
+func (u *Updater) anchorRelease(ctx context.Context, date, cid string) (*types.Transaction, error) {
+	if u.options.DryRun || u.options.UserOperations != nil {
+		return nil, fmt.Errorf("releases are not anchored in dry runs nor with user operations")
+	}
+	from := u.sender.Address()
+	data := []byte(anchorPrefix + date + ":" + cid)
+	gas, err := u.rpcClient.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &from, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("error estimating gas: %w", err)
+	}
+	gasPrice, err := u.options.GasStrategy.GasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting gas price: %w", err)
+	}
+	nonce, err := u.nonces.Next(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sender nonce: %w", err)
+	}
+	tx, err := u.sender.SignerFn()(from, types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &from,
+		Value:    new(big.Int),
+		Gas:      gas,
+		GasPrice: gasPrice,
+		Data:     data,
+	}))
+	if err != nil {
+		u.nonces.Release(nonce)
+		return nil, fmt.Errorf("error signing anchor transaction: %w", err)
+	}
+	if err := u.broadcast(ctx, tx); err != nil {
+		u.nonces.Release(nonce)
+		return nil, fmt.Errorf("error broadcasting anchor transaction: %w", err)
+	}
+	u.nonces.Sent(tx)
+	log.Info().Str("hash", tx.Hash().Hex()).Str("cid", cid).Msg("Release anchor transaction sent")
+	receipt, err := u.waitMined(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for anchor transaction: %w", err)
+	}
+	u.nonces.Confirm(nonce)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("anchor transaction %s failed", tx.Hash().Hex())
+	}
+	return tx, nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/binding"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Reasons a setRandomness transaction failed
+const (
+	// reasonAlreadySet is a round set by another updater first
+	reasonAlreadySet = "already_set"
+	// reasonOutOfOrder is a round that does not follow the oracle's latest round
+	reasonOutOfOrder = "out_of_order"
+	// reasonUnauthorized is a signature the oracle does not accept
+	reasonUnauthorized = "unauthorized"
+	// reasonPaused is an oracle paused when the transaction was mined
+	reasonPaused = "paused"
+	// reasonTimestampInFuture is a round the oracle does not accept yet at the
+	// block's timestamp
+	reasonTimestampInFuture = "timestamp_in_future"
+	// reasonInvalidInput is a round with empty fields
+	reasonInvalidInput = "invalid_input"
+	// reasonOutOfGas is a transaction that used its whole gas limit
+	reasonOutOfGas = "out_of_gas"
+	// reasonUnknown is a revert that could not be replayed or decoded
+	reasonUnknown = "unknown"
+)
+
+// revertReasons maps the oracle's custom errors to failure reasons
+var revertReasons = map[string]string{
+	"InvalidSignature":           reasonUnauthorized,
+	"ECDSAInvalidSignature":      reasonUnauthorized,
+	"OwnableUnauthorizedAccount": reasonUnauthorized,
+	"EnforcedPause":              reasonPaused,
+	"InvalidRoundTimestamp":      reasonTimestampInFuture,
+	"InvalidInput":               reasonInvalidInput,
+}
+
+// revert is the classified failure of a setRandomness transaction
+type revert struct {
+	// reason is the failure class, the reason label of the failure metric
+	reason string
+	// decoded is the custom error name or the revert string, empty when the
+	// revert could not be decoded
+	decoded string
+}
+
+// classifyRevert replays a failed transaction setting rounds from first with
+// eth_call on the state of the block before it was mined, decodes the revert
+// and classifies it. A transaction that used its whole gas limit ran out of gas
+// without replaying it.
+func (u *Updater) classifyRevert(ctx context.Context, msg ethereum.CallMsg, block *big.Int, first uint64, gasUsed uint64) revert {
+	if msg.Gas > 0 && gasUsed >= msg.Gas {
+		return revert{reason: reasonOutOfGas}
+	}
+	var parent *big.Int
+	if block != nil && block.Sign() > 0 {
+		parent = new(big.Int).Sub(block, big.NewInt(1))
+	}
+	_, err := u.rpcClient.CallContract(ctx, msg, parent)
+	if err == nil {
+		// The transaction succeeds on the previous block, so it failed because
+		// of a transaction mined before it in the same block, such as another
+		// updater setting the round
+		if u.roundSetAt(ctx, block, first) {
+			return revert{reason: reasonAlreadySet}
+		}
+		return revert{reason: reasonUnknown}
+	}
+
+	name, ok := decodeRevert(err)
+	if !ok {
+		return revert{reason: reasonUnknown}
+	}
+	if name == "InvalidRound" {
+		if u.roundSetAt(ctx, parent, first) {
+			return revert{reason: reasonAlreadySet, decoded: name}
+		}
+		return revert{reason: reasonOutOfOrder, decoded: name}
+	}
+	if reason, ok := revertReasons[name]; ok {
+		return revert{reason: reason, decoded: name}
+	}
+	return revert{reason: reasonUnknown, decoded: name}
+}
+
+// roundSetAt reports whether the oracle's latest round at a block is at least
+// round, false when it cannot be read
+func (u *Updater) roundSetAt(ctx context.Context, block *big.Int, round uint64) bool {
+	latest, err := u.binding.LatestRound(&bind.CallOpts{Context: ctx, BlockNumber: block})
+	return err == nil && latest >= round
+}
+
+// failedTxMsg returns the call replaying a mined transaction of the sender
+func (u *Updater) failedTxMsg(tx *types.Transaction) ethereum.CallMsg {
+	return ethereum.CallMsg{
+		From:     u.sender.Address(),
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}
+}
+
+// decodeRevert returns the oracle's custom error or the revert string of a
+// reverted call. It reports false for errors without revert data, such as
+// transport failures.
+func decodeRevert(err error) (string, bool) {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return "", false
+	}
+	encoded, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return "", false
+	}
+	data, decodeErr := hexutil.Decode(encoded)
+	if decodeErr != nil || len(data) < 4 {
+		return "", false
+	}
+	if reason, unpackErr := abi.UnpackRevert(data); unpackErr == nil {
+		return reason, true
+	}
+	contractABI, abiErr := binding.BindingMetaData.GetAbi()
+	if abiErr != nil {
+		return "", false
+	}
+	for name, contractErr := range contractABI.Errors {
+		if bytes.Equal(contractErr.ID[:4], data[:4]) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"drand-oracle-updater/alerting"
+	"drand-oracle-updater/internal/store"
+	"drand-oracle-updater/sender"
+	"drand-oracle-updater/signer"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Rotated keys, the key label of the rotation metrics
+const (
+	keySender = "sender"
+	keySigner = "signer"
+)
+
+// defaultDrainTimeout bounds the drain of a rotation when none is configured
+const defaultDrainTimeout = 5 * time.Minute
+
+// ErrNoNextKey is returned when a rotation is requested without a key and no
+// next key is configured
+var ErrNoNextKey = errors.New("no next key given or configured")
+
+// KeyRotation holds the keys staged for a rotation. A rotation is triggered
+// through the admin API, with a staged key or one passed along.
+type KeyRotation struct {
+	// NextSenderKey is the key the sender rotates to, nil when none is staged
+	NextSenderKey sender.KeyProvider
+
+	// NextSignerKey is the key the signer rotates to, nil when none is staged
+	NextSignerKey *ecdsa.PrivateKey
+
+	// Scheme is the digest scheme of the rotated signer
+	Scheme signer.Scheme
+
+	// DrainTimeout bounds the wait for the transactions of the current key,
+	// the rotation is abandoned past it. 0 waits defaultDrainTimeout.
+	DrainTimeout time.Duration
+}
+
+// SignerRotation describes a completed signer rotation
+type SignerRotation struct {
+	From common.Address `json:"from"`
+	To   common.Address `json:"to"`
+	// TxHash is the setSigner transaction handing the oracle over, empty when
+	// the oracle's signer was already changed outside the updater
+	TxHash    string    `json:"tx_hash,omitempty"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+// newRotatingSigner is a helper for NewUpdater, whose signer parameter shadows the package
+func newRotatingSigner(s signer.SetRandomnessSigner) *signer.RotatingSigner {
+	return signer.NewRotatingSigner(s)
+}
+
+func (u *Updater) drainTimeout() time.Duration {
+	if u.options.KeyRotation.DrainTimeout > 0 {
+		return u.options.KeyRotation.DrainTimeout
+	}
+	return defaultDrainTimeout
+}
+
+// acquireSubmissions waits for the round being submitted, which returns once
+// its transaction is mined, and holds the following rounds back until the
+// returned release is called
+func (u *Updater) acquireSubmissions(ctx context.Context) (release func(), err error) {
+	select {
+	case u.submissions <- struct{}{}:
+		return func() { <-u.submissions }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// submitRoundsHeld submits rounds once no signer rotation holds them back
+func (u *Updater) submitRoundsHeld(ctx context.Context, batch []*roundData) error {
+	release, err := u.acquireSubmissions(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return u.submitRounds(ctx, batch)
+}
+
+// RotateSender switches the sender to next, or to the staged next sender key
+// when nil. The sender is shared by the pipelines, which all hold their
+// transactions back while those of the current key are drained.
+func (u *Updater) RotateSender(ctx context.Context, next sender.KeyProvider) (sender.Rotation, error) {
+	if next == nil {
+		next = u.options.KeyRotation.NextSenderKey
+	}
+	if next == nil {
+		return sender.Rotation{}, ErrNoNextKey
+	}
+	if u.options.UserOperations != nil {
+		return sender.Rotation{}, errors.New("rounds are sent as user operations, the sender key is not used")
+	}
+
+	log.Info().
+		Str("from", u.sender.Address().Hex()).
+		Str("to", next.Address().Hex()).
+		Dur("drain_timeout", u.drainTimeout()).
+		Msg("Rotating sender key")
+	rotation, err := u.nonces.Rotate(ctx, next, u.drainTimeout())
+	if err != nil {
+		u.metrics.IncKeyRotation(keySender, "failure")
+		log.Error().Err(err).Str("to", next.Address().Hex()).Msg("Failed to rotate sender key, keeping the current key")
+		return sender.Rotation{}, err
+	}
+	u.keyRotated(keySender, rotation.From, rotation.To, "")
+	return rotation, nil
+}
+
+// RotateSigner switches the signer to next, or to the staged next signer key
+// when nil. The pipeline's rounds are held back while the round being
+// submitted is mined. Unless the oracle's signer already is next, the current
+// signer hands the oracle over with setSigner, which requires a local key.
+// Rounds following the setSigner transaction are signed by next.
+func (u *Updater) RotateSigner(ctx context.Context, next *ecdsa.PrivateKey) (SignerRotation, error) {
+	if next == nil {
+		next = u.options.KeyRotation.NextSignerKey
+	}
+	if next == nil {
+		return SignerRotation{}, ErrNoNextKey
+	}
+	nextSigner := signer.NewSigner(u.chainID, u.oracleAddress, next, u.options.KeyRotation.Scheme)
+
+	rotation, err := u.rotateSigner(ctx, nextSigner)
+	if err != nil {
+		u.metrics.IncKeyRotation(keySigner, "failure")
+		log.Error().Err(err).Str("to", nextSigner.Address().Hex()).Msg("Failed to rotate signer key, keeping the current key")
+		return SignerRotation{}, err
+	}
+	u.keyRotated(keySigner, rotation.From, rotation.To, rotation.TxHash)
+	return rotation, nil
+}
+
+func (u *Updater) rotateSigner(ctx context.Context, next *signer.Signer) (SignerRotation, error) {
+	current := u.signer.Current()
+	rotation := SignerRotation{From: current.Address(), To: next.Address()}
+	if rotation.From == rotation.To {
+		return SignerRotation{}, fmt.Errorf("signer already uses key %s", rotation.To.Hex())
+	}
+	log.Info().
+		Str("from", rotation.From.Hex()).
+		Str("to", rotation.To.Hex()).
+		Dur("drain_timeout", u.drainTimeout()).
+		Msg("Rotating signer key")
+
+	drainCtx, cancel := context.WithTimeout(ctx, u.drainTimeout())
+	defer cancel()
+	release, err := u.acquireSubmissions(drainCtx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return SignerRotation{}, sender.ErrDrainTimeout
+		}
+		return SignerRotation{}, err
+	}
+	defer release()
+
+	u.invalidateCalls(methodSigner)
+	oracleSigner, err := u.binding.Signer(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return SignerRotation{}, fmt.Errorf("error getting signer from Drand Oracle contract: %w", err)
+	}
+	if oracleSigner != rotation.To {
+		local, ok := current.(*signer.Signer)
+		if !ok {
+			return SignerRotation{}, errors.New("the current signer cannot sign setSigner with a remote or threshold key, call setSigner on the oracle first")
+		}
+		if oracleSigner != rotation.From {
+			return SignerRotation{}, fmt.Errorf("the oracle's signer %s is neither the current nor the next signer", oracleSigner.Hex())
+		}
+		tx, err := u.setSigner(ctx, local, rotation.To)
+		if err != nil {
+			return SignerRotation{}, err
+		}
+		rotation.TxHash = tx.Hash().Hex()
+	}
+
+	u.signer.Rotate(next)
+	rotation.RotatedAt = time.Now().UTC()
+	return rotation, nil
+}
+
+// setSigner hands the oracle over to newSigner with a setSigner transaction
+// authorized by the current signer, and waits for it to be mined
+func (u *Updater) setSigner(ctx context.Context, current *signer.Signer, newSigner common.Address) (*types.Transaction, error) {
+	signature, err := current.SignSetSigner(newSigner)
+	if err != nil {
+		return nil, fmt.Errorf("error signing setSigner: %w", err)
+	}
+	gasPrice, err := u.options.GasStrategy.GasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting gas price: %w", err)
+	}
+	nonce, err := u.nonces.Next(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sender nonce: %w", err)
+	}
+	tx, err := u.binding.SetSigner(&bind.TransactOpts{
+		From:     u.sender.Address(),
+		Nonce:    new(big.Int).SetUint64(nonce),
+		Signer:   u.sender.SignerFn(),
+		GasPrice: gasPrice,
+		Context:  ctx,
+		NoSend:   true,
+	}, newSigner, signature)
+	if err != nil {
+		u.nonces.Release(nonce)
+		return nil, fmt.Errorf("error building setSigner transaction: %w", err)
+	}
+	if err := u.broadcast(ctx, tx); err != nil {
+		u.nonces.Release(nonce)
+		return nil, fmt.Errorf("error broadcasting setSigner transaction: %w", err)
+	}
+	u.nonces.Sent(tx)
+	log.Info().Str("hash", tx.Hash().Hex()).Str("signer", newSigner.Hex()).Msg("setSigner transaction sent")
+	receipt, err := u.waitMined(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for setSigner transaction: %w", err)
+	}
+	u.nonces.Confirm(nonce)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("setSigner transaction %s failed", tx.Hash().Hex())
+	}
+	u.invalidateCalls(methodSigner)
+	return tx, nil
+}
+
+// keyRotated records a completed rotation in the metrics, the timeline, the
+// annotations and the alerts, so that dashboards and operators see which rounds
+// each key set
+func (u *Updater) keyRotated(key string, from, to common.Address, txHash string) {
+	u.metrics.IncKeyRotation(key, "success")
+	u.metrics.SetKeyRotated(key)
+	log.Info().
+		Str("key", key).
+		Str("from", from.Hex()).
+		Str("to", to.Hex()).
+		Str("hash", txHash).
+		Msg("Key rotated")
+
+	details := map[string]string{
+		"key":  key,
+		"from": from.Hex(),
+		"to":   to.Hex(),
+	}
+	if txHash != "" {
+		details["tx_hash"] = txHash
+	}
+	// The event and the annotation mark the first round set with the new key
+	note := fmt.Sprintf("%s key rotated from %s to %s", key, from.Hex(), to.Hex())
+	first := u.GetLatestOracleRound() + 1
+	u.recordEvent(kindKeyRotated, first, note, details)
+	if _, err := u.AddAnnotation(store.Annotation{
+		FromRound: first,
+		ToRound:   first,
+		Note:      note,
+		Author:    "updater",
+	}); err != nil {
+		log.Warn().Err(err).Msg("Failed to annotate key rotation")
+	}
+
+	u.sendAlert(alerting.Alert{
+		Condition: alerting.ConditionKeyRotated,
+		Severity:  alerting.SeverityInfo,
+		Summary:   note,
+		Details:   details,
+	})
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"drand-oracle-updater/internal/store"
+	"time"
+
+	"github.com/drand/drand/chain"
+)
+
+// RoundInclusion is a round as set on-chain, with the block that set it
+type RoundInclusion struct {
+	Round uint64 `json:"round"`
+	// RoundTimestamp is the time the drand network produced the round
+	RoundTimestamp uint64 `json:"round_timestamp"`
+	Randomness     string `json:"randomness"`
+	Signature      string `json:"signature"`
+	BlockNumber    uint64 `json:"block_number"`
+	BlockTimestamp uint64 `json:"block_timestamp"`
+	TxHash         string `json:"tx_hash"`
+	// Delay is the number of seconds between the round being produced and set on-chain
+	Delay uint64 `json:"delay_seconds"`
+	// Committed is set once the block is committed under the finality policy
+	Committed bool `json:"committed"`
+}
+
+// RoundAtTime is the oracle state as seen by a transaction in a block with a given timestamp
+type RoundAtTime struct {
+	// Timestamp is the block timestamp queried
+	Timestamp uint64 `json:"timestamp"`
+	// DrandRound is the latest round the drand network had produced at Timestamp
+	DrandRound uint64 `json:"drand_round"`
+	// Lag is the number of rounds the oracle was behind the drand network
+	Lag uint64 `json:"lag"`
+	// RoundInclusion is the latest round set on-chain in a block with a
+	// timestamp at or before Timestamp
+	RoundInclusion
+}
+
+// RoundAt returns the latest round set on the oracle at a block timestamp of
+// the destination chain, from the local rounds index. The round set in a
+// block with that exact timestamp counts as set, as transactions of later
+// blocks with the same timestamp read it.
+func (u *Updater) RoundAt(timestamp uint64) (*RoundAtTime, error) {
+	indexed, ok, err := u.store.LatestRoundAt(time.Unix(int64(timestamp), 0))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRoundNotFound
+	}
+
+	result := &RoundAtTime{
+		Timestamp:      timestamp,
+		DrandRound:     chain.CurrentRound(int64(timestamp), u.drandInfo.Period, u.drandInfo.GenesisTime),
+		RoundInclusion: u.roundInclusion(indexed),
+	}
+	if result.DrandRound > result.Round {
+		result.Lag = result.DrandRound - result.Round
+	}
+	return result, nil
+}
+
+// RoundInclusion returns the block that set a round on the oracle, from the
+// local rounds index
+func (u *Updater) RoundInclusion(round uint64) (*RoundInclusion, error) {
+	indexed, ok, err := u.store.IndexedRound(round)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRoundNotFound
+	}
+	inclusion := u.roundInclusion(indexed)
+	return &inclusion, nil
+}
+
+func (u *Updater) roundInclusion(indexed store.Round) RoundInclusion {
+	roundTimestamp := uint64(chain.TimeOfRound(u.drandInfo.Period, u.drandInfo.GenesisTime, indexed.Round))
+	blockTimestamp := uint64(indexed.Timestamp.Unix())
+	inclusion := RoundInclusion{
+		Round:          indexed.Round,
+		RoundTimestamp: roundTimestamp,
+		Randomness:     indexed.Randomness,
+		Signature:      indexed.Signature,
+		BlockNumber:    indexed.BlockNumber,
+		BlockTimestamp: blockTimestamp,
+		TxHash:         indexed.TxHash,
+		Committed:      u.committedBlock(indexed.BlockNumber),
+	}
+	if blockTimestamp > roundTimestamp {
+		inclusion.Delay = blockTimestamp - roundTimestamp
+	}
+	return inclusion
+}
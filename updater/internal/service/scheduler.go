@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Lane is the origin of a queued round
+type Lane string
+
+const (
+	// LaneCritical holds rounds submitted on an operator's request
+	LaneCritical Lane = "critical"
+	// LaneRequested holds rounds pushed to the ingestion endpoint
+	LaneRequested Lane = "requested"
+	// LaneLive holds rounds received from the drand network watcher
+	LaneLive Lane = "live"
+	// LaneBackfill holds rounds fetched while catching up
+	LaneBackfill Lane = "backfill"
+)
+
+// lanes lists the lanes from most to least urgent
+var lanes = []Lane{LaneCritical, LaneRequested, LaneLive, LaneBackfill}
+
+// Policy is a submission ordering policy
+type Policy string
+
+const (
+	// PolicyFIFO submits rounds in the order they were queued
+	PolicyFIFO Policy = "fifo"
+	// PolicyNewestFirst submits the highest queued round first
+	PolicyNewestFirst Policy = "newest-first"
+	// PolicyRequestedFirst submits critical and requested rounds before the others
+	PolicyRequestedFirst Policy = "requested-first"
+	// PolicyCriticalLane submits critical rounds first and the others in FIFO order
+	PolicyCriticalLane Policy = "critical-lane"
+	// PolicyBackfillLast submits backfill rounds only when no other round is queued
+	PolicyBackfillLast Policy = "backfill-last"
+)
+
+// ParsePolicy parses a scheduler policy name
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyFIFO, PolicyNewestFirst, PolicyRequestedFirst, PolicyCriticalLane, PolicyBackfillLast:
+		return p, nil
+	case "":
+		return PolicyFIFO, nil
+	default:
+		return "", fmt.Errorf("unknown scheduler policy %q", s)
+	}
+}
+
+// QueueStatus describes the composition of the submission queue
+type QueueStatus struct {
+	Policy   Policy       `json:"policy"`
+	Length   int          `json:"length"`
+	Capacity int          `json:"capacity"`
+	Lanes    map[Lane]int `json:"lanes"`
+	Rounds   []uint64     `json:"rounds,omitempty"`
+}
+
+type queuedRound struct {
+	*roundData
+	seq uint64
+}
+
+// scheduler is the priority queue of rounds waiting to be submitted.
+//
+// The Drand Oracle contract only accepts the round following its latest round,
+// so the policy decides between the rounds that are submittable, as reported by
+// the ready function, and rounds ahead of the oracle wait in the queue rather
+// than being dropped. Queues hold at most a few dozen rounds, so lookups scan
+// the queue instead of maintaining a heap.
+type scheduler struct {
+	policy   Policy
+	capacity int
+	ready    func(round uint64) bool
+	onChange func(lanes map[Lane]int)
+	// degraded shrinks the queue to degradedQueueSize rounds, as the process
+	// approaches its resource limits
+	degraded func() bool
+
+	mu    sync.Mutex
+	queue []*queuedRound
+	seq   uint64
+	// signal is closed and replaced whenever waiters should re-check the queue
+	signal chan struct{}
+}
+
+// degradedQueueSize is the capacity of the queue while degraded
+const degradedQueueSize = 4
+
+func newScheduler(policy Policy, capacity int, ready func(round uint64) bool, onChange func(lanes map[Lane]int), degraded func() bool) *scheduler {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &scheduler{
+		policy:   policy,
+		capacity: capacity,
+		ready:    ready,
+		onChange: onChange,
+		degraded: degraded,
+		signal:   make(chan struct{}),
+	}
+}
+
+// limit returns the number of rounds the queue holds before blocking pushes
+func (s *scheduler) limit() int {
+	if s.degraded != nil && s.degraded() {
+		return min(s.capacity, degradedQueueSize)
+	}
+	return s.capacity
+}
+
+// Push queues a round, blocking while the queue is full. Rounds already queued
+// are ignored, and submittable rounds are never blocked so that the queue cannot
+// fill up with rounds waiting on a round that cannot be queued.
+func (s *scheduler) Push(ctx context.Context, rd *roundData, lane Lane) error {
+	for {
+		s.mu.Lock()
+		if s.indexOf(rd.round) >= 0 {
+			s.mu.Unlock()
+			rd.endSpan(nil)
+			return nil
+		}
+		if len(s.queue) < s.limit() || s.ready(rd.round) {
+			s.enqueue(rd, lane)
+			s.mu.Unlock()
+			return nil
+		}
+		signal := s.signal
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-signal:
+		}
+	}
+}
+
+// Requeue queues back a round popped from its lane, without blocking on a
+// full queue as the round was already accounted for
+func (s *scheduler) Requeue(rd *roundData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.indexOf(rd.round) >= 0 {
+		rd.endSpan(nil)
+		return
+	}
+	s.enqueue(rd, rd.lane)
+}
+
+// enqueue appends a round to the queue, it must be called with the lock held
+func (s *scheduler) enqueue(rd *roundData, lane Lane) {
+	rd.queuedAt = time.Now()
+	rd.lane = lane
+	s.seq++
+	s.queue = append(s.queue, &queuedRound{roundData: rd, seq: s.seq})
+	s.changed()
+}
+
+// Pop removes and returns the submittable round with the highest priority,
+// blocking until there is one
+func (s *scheduler) Pop(ctx context.Context) (*roundData, error) {
+	// The oracle round may have moved since the last Pop, so blocked pushes of
+	// rounds that became submittable must re-check
+	s.mu.Lock()
+	s.broadcast()
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		best := -1
+		for i, q := range s.queue {
+			if !s.ready(q.round) {
+				continue
+			}
+			if best < 0 || s.less(q, s.queue[best]) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			rd := s.queue[best].roundData
+			s.queue = append(s.queue[:best], s.queue[best+1:]...)
+			s.changed()
+			s.mu.Unlock()
+			return rd, nil
+		}
+		signal := s.signal
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-signal:
+		}
+	}
+}
+
+// PopFollowing removes and returns the queued backfill rounds directly
+// following round, in order, at most limit of them
+func (s *scheduler) PopFollowing(round uint64, limit int) []*roundData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var following []*roundData
+	for next := round + 1; len(following) < limit; next++ {
+		i := s.indexOf(next)
+		if i < 0 || s.queue[i].lane != LaneBackfill {
+			break
+		}
+		following = append(following, s.queue[i].roundData)
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+	}
+	if len(following) > 0 {
+		s.changed()
+	}
+	return following
+}
+
+// Wake makes waiters re-check the queue, after the oracle round moved
+func (s *scheduler) Wake() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcast()
+}
+
+// Len returns the number of queued rounds
+func (s *scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Status returns the composition of the queue, rounds sorted in policy order
+func (s *scheduler) Status() QueueStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue := append([]*queuedRound(nil), s.queue...)
+	sort.Slice(queue, func(i, j int) bool { return s.less(queue[i], queue[j]) })
+
+	status := QueueStatus{
+		Policy:   s.policy,
+		Length:   len(queue),
+		Capacity: s.capacity,
+		Lanes:    s.laneCounts(),
+	}
+	for _, q := range queue {
+		status.Rounds = append(status.Rounds, q.round)
+	}
+	return status
+}
+
+// less reports whether a should be submitted before b under the policy
+func (s *scheduler) less(a, b *queuedRound) bool {
+	switch s.policy {
+	case PolicyNewestFirst:
+		if a.round != b.round {
+			return a.round > b.round
+		}
+	case PolicyRequestedFirst:
+		if ar, br := a.lane == LaneCritical || a.lane == LaneRequested, b.lane == LaneCritical || b.lane == LaneRequested; ar != br {
+			return ar
+		}
+	case PolicyCriticalLane:
+		if ac, bc := a.lane == LaneCritical, b.lane == LaneCritical; ac != bc {
+			return ac
+		}
+	case PolicyBackfillLast:
+		if ab, bb := a.lane == LaneBackfill, b.lane == LaneBackfill; ab != bb {
+			return bb
+		}
+		if a.round != b.round {
+			return a.round < b.round
+		}
+	}
+	return a.seq < b.seq
+}
+
+func (s *scheduler) indexOf(round uint64) int {
+	for i, q := range s.queue {
+		if q.round == round {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *scheduler) laneCounts() map[Lane]int {
+	counts := make(map[Lane]int, len(lanes))
+	for _, lane := range lanes {
+		counts[lane] = 0
+	}
+	for _, q := range s.queue {
+		counts[q.lane]++
+	}
+	return counts
+}
+
+// changed wakes up waiters and reports the lane counts, it must be called with
+// the lock held
+func (s *scheduler) changed() {
+	s.broadcast()
+	if s.onChange != nil {
+		s.onChange(s.laneCounts())
+	}
+}
+
+// broadcast wakes up all waiters, it must be called with the lock held
+func (s *scheduler) broadcast() {
+	close(s.signal)
+	s.signal = make(chan struct{})
+}
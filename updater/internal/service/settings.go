@@ -0,0 +1,61 @@
+package service
+
+import (
+	"drand-oracle-updater/internal/retry"
+	"math/big"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Settings are the tunables that can be changed while the updater runs
+type Settings struct {
+	// MaxRetries is the maximum number of attempts of a round
+	MaxRetries int
+	// AlertAfterRetries is the number of failed attempts of a round after which
+	// an alert is sent, 0 alerts only once all retries are exhausted
+	AlertAfterRetries int
+	// MinSenderBalance is the sender balance below which the updater is not ready
+	MinSenderBalance *big.Int
+	// MaxRoundLag is the drand to oracle round lag above which the updater is not ready
+	MaxRoundLag uint64
+	// AlertRepeatInterval is the minimum interval between two alerts for the
+	// same condition
+	AlertRepeatInterval time.Duration
+	// Retry is the retry policy of drand fetches, RPC reads and broadcasts
+	Retry retry.Policy
+}
+
+// Settings returns the current tunables
+func (u *Updater) Settings() Settings {
+	u.settingsMutex.RLock()
+	defer u.settingsMutex.RUnlock()
+	return u.settings
+}
+
+// ApplySettings replaces the tunables. A round being retried keeps the
+// settings it started with, and a call being retried its retry policy.
+func (u *Updater) ApplySettings(settings Settings) {
+	u.settingsMutex.Lock()
+	u.settings = settings
+	u.settingsMutex.Unlock()
+	u.options.Alerts.SetRepeatInterval(settings.AlertRepeatInterval)
+	for _, retrier := range []*retry.Retrier{u.drandRetrier, u.rpcRetrier, u.broadcastRetrier} {
+		retrier.SetPolicy(settings.Retry)
+	}
+
+	event := log.Info().
+		Int("max_retries", settings.MaxRetries).
+		Int("alert_after_retries", settings.AlertAfterRetries).
+		Uint64("max_round_lag", settings.MaxRoundLag).
+		Dur("alert_repeat_interval", settings.AlertRepeatInterval).
+		Int("retry_max_attempts", settings.Retry.MaxAttempts).
+		Dur("retry_initial_backoff", settings.Retry.InitialBackoff).
+		Dur("retry_max_backoff", settings.Retry.MaxBackoff).
+		Int("retry_breaker_threshold", settings.Retry.BreakerThreshold).
+		Dur("retry_breaker_cooldown", settings.Retry.BreakerCooldown)
+	if settings.MinSenderBalance != nil {
+		event = event.Str("min_sender_balance_wei", settings.MinSenderBalance.String())
+	}
+	event.Msg("Settings applied")
+}
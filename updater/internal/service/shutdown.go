@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownCheckpoint is the name of the state store checkpoint written on shutdown
+const shutdownCheckpoint = "shutdown"
+
+// ErrStopping is returned for work submitted while the updater is stopping
+var ErrStopping = errors.New("updater is stopping")
+
+// ShutdownState is persisted when the updater stops
+type ShutdownState struct {
+	StoppedAt   time.Time `json:"stopped_at"`
+	OracleRound uint64    `json:"oracle_round"`
+	DrandRound  uint64    `json:"drand_round"`
+	// Clean is false when the in-flight round did not confirm before the shutdown deadline
+	Clean         bool     `json:"clean"`
+	InFlightRound uint64   `json:"in_flight_round,omitempty"`
+	QueuedRounds  []uint64 `json:"queued_rounds,omitempty"`
+}
+
+// Stop gracefully stops the updater: it stops fetching and accepting new
+// rounds, waits for the in-flight transaction to confirm until ctx expires,
+// and persists the updater state. Start returns once Stop completes.
+func (u *Updater) Stop(ctx context.Context) error {
+	u.stopMutex.Lock()
+	cancelIntake, cancelSubmit := u.cancelIntake, u.cancelSubmit
+	u.stopMutex.Unlock()
+	if cancelIntake == nil {
+		return errors.New("updater is not running")
+	}
+	if !u.stopping.CompareAndSwap(false, true) {
+		return ErrStopping
+	}
+
+	u.inFlightRoundMutex.RLock()
+	inFlightRound := u.inFlightRound
+	u.inFlightRoundMutex.RUnlock()
+	log.Info().Uint64("in_flight_round", inFlightRound).Msg("Stopping updater, draining in-flight submission...")
+	cancelIntake()
+
+	clean := true
+	select {
+	case <-u.done:
+		log.Info().Msg("In-flight submission drained")
+	case <-ctx.Done():
+		clean = false
+		log.Warn().Uint64("in_flight_round", inFlightRound).Msg("Shutdown deadline reached before the in-flight submission confirmed")
+		cancelSubmit()
+		<-u.done
+	}
+
+	return u.persistShutdownState(clean, inFlightRound)
+}
+
+func (u *Updater) persistShutdownState(clean bool, inFlightRound uint64) error {
+	u.latestDrandRoundMutex.RLock()
+	drandRound := u.latestDrandRound
+	u.latestDrandRoundMutex.RUnlock()
+
+	state := ShutdownState{
+		StoppedAt:    time.Now().UTC(),
+		OracleRound:  u.GetLatestOracleRound(),
+		DrandRound:   drandRound,
+		Clean:        clean,
+		QueuedRounds: u.scheduler.Status().Rounds,
+	}
+	if !clean {
+		state.InFlightRound = inFlightRound
+	}
+	summary := "Updater stopped"
+	if !clean {
+		summary = "Updater stopped before its in-flight submission confirmed"
+	}
+	u.recordEvent(kindStopped, state.OracleRound, summary, map[string]string{
+		"clean":           fmt.Sprintf("%t", clean),
+		"in_flight_round": fmt.Sprintf("%d", state.InFlightRound),
+	})
+	if err := u.store.SaveCheckpoint(shutdownCheckpoint, state); err != nil {
+		log.Error().Err(err).Msg("Failed to persist shutdown state")
+		return err
+	}
+	log.Info().Uint64("oracle_round", state.OracleRound).Bool("clean", clean).Msg("Persisted shutdown state")
+	return nil
+}
+
+// logPreviousShutdown reports how the previous run stopped
+func (u *Updater) logPreviousShutdown() {
+	var state ShutdownState
+	ok, err := u.store.LoadCheckpoint(shutdownCheckpoint, &state)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read previous shutdown state")
+		return
+	}
+	if !ok {
+		return
+	}
+	if !state.Clean {
+		log.Warn().
+			Time("stopped_at", state.StoppedAt).
+			Uint64("in_flight_round", state.InFlightRound).
+			Msg("Previous run stopped before its in-flight submission confirmed, the sender may have a pending transaction")
+		return
+	}
+	log.Info().Time("stopped_at", state.StoppedAt).Uint64("oracle_round", state.OracleRound).Msg("Previous run stopped cleanly")
+}
+
+// ignoreStop turns the cancellation of a goroutine by Stop into a clean exit
+func (u *Updater) ignoreStop(err error) error {
+	if u.stopping.Load() && errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func (u *Updater) checkNotStopping() CheckResult {
+	result := CheckResult{Name: "not_stopping", OK: !u.stopping.Load()}
+	if !result.OK {
+		result.Error = "updater is stopping"
+	}
+	return result
+}
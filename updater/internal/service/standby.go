@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/retry"
+	"drand-oracle-updater/signer"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/drand/drand/client"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// ColdStandby is the leader election of a cold standby instance, never
+// elected: it follows drand and the oracle with warm connections but leaves
+// every round to the primary instance until it is reconfigured to take over
+type ColdStandby struct{}
+
+func (ColdStandby) IsLeader() bool {
+	return false
+}
+
+// SelfTest is the end-to-end self-test of the submission path, run
+// periodically against a dedicated test oracle
+type SelfTest struct {
+	// Oracle is the address of the test oracle, which must serve the drand
+	// network of the pipeline
+	Oracle  common.Address
+	Binding *binding.Binding
+	// Signer signs for the test oracle, whose EIP-712 domain differs from the
+	// pipeline's oracle
+	Signer signer.SetRandomnessSigner
+	// Interval is the delay between self-tests
+	Interval time.Duration
+}
+
+// Self-test stages
+const (
+	selfTestStageOracle   = "oracle"
+	selfTestStageFetch    = "fetch"
+	selfTestStageVerify   = "verify"
+	selfTestStageSign     = "sign"
+	selfTestStageSubmit   = "submit"
+	selfTestStageConfirm  = "confirm"
+	selfTestStageReadBack = "read_back"
+)
+
+// SelfTestStatus is the outcome of the latest self-tests
+type SelfTestStatus struct {
+	Oracle      string     `json:"oracle"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Round       uint64     `json:"round,omitempty"`
+	TxHash      string     `json:"tx_hash,omitempty"`
+	// FailedStage and Error describe the latest self-test when it failed
+	FailedStage string `json:"failed_stage,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// Ready reports whether the latest self-test succeeded, less than two
+	// intervals ago
+	Ready bool `json:"ready"`
+}
+
+// selfTestState is the state of the self-tests
+type selfTestState struct {
+	mu     sync.Mutex
+	status SelfTestStatus
+}
+
+// SelfTest returns the outcome of the latest self-tests, nil without self-test
+func (u *Updater) SelfTest() *SelfTestStatus {
+	if u.options.SelfTest == nil {
+		return nil
+	}
+	u.selfTest.mu.Lock()
+	defer u.selfTest.mu.Unlock()
+	status := u.selfTest.status
+	status.Oracle = u.options.SelfTest.Oracle.Hex()
+	status.Ready = status.Ready && status.LastSuccess != nil && time.Since(*status.LastSuccess) < 2*u.options.SelfTest.Interval
+	return &status
+}
+
+// runSelfTests runs the self-test at startup, then every interval
+func (u *Updater) runSelfTests(ctx context.Context) error {
+	if u.options.SelfTest == nil {
+		return nil
+	}
+	for {
+		u.runSelfTest(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(u.options.SelfTest.Interval):
+		}
+	}
+}
+
+// runSelfTest fetches the round following the test oracle's latest round,
+// verifies and signs it, submits it to the test oracle with the sender, waits
+// for its confirmation and reads it back, recording the outcome
+func (u *Updater) runSelfTest(ctx context.Context) {
+	started := time.Now()
+	ctx, span := tracer.Start(ctx, "self_test")
+	round, txHash, stage, err := u.selfTestRound(ctx)
+	endSpan(span, err)
+	if ctx.Err() != nil {
+		return
+	}
+
+	u.selfTest.mu.Lock()
+	status := &u.selfTest.status
+	status.LastRun = &started
+	status.Round, status.TxHash = round, txHash
+	if err != nil {
+		status.FailedStage, status.Error, status.Ready = stage, err.Error(), false
+	} else {
+		status.FailedStage, status.Error, status.Ready = "", "", true
+		status.LastSuccess = &started
+	}
+	u.selfTest.mu.Unlock()
+
+	if err != nil {
+		u.metrics.IncSelfTest(stage, "failure")
+		u.metrics.SetStandbyReady(false)
+		log.Error().Err(err).Str("stage", stage).Uint64("round", round).Msg("Self-test failed")
+		return
+	}
+	u.metrics.IncSelfTest("", "success")
+	u.metrics.SetStandbyReady(true)
+	u.metrics.SetSelfTestSuccess(started)
+	log.Info().
+		Uint64("round", round).
+		Str("hash", txHash).
+		Dur("duration", time.Since(started)).
+		Msg("Self-test succeeded")
+}
+
+// selfTestRound runs the stages of a self-test, returning the stage that failed
+func (u *Updater) selfTestRound(ctx context.Context) (round uint64, txHash string, stage string, err error) {
+	test := u.options.SelfTest
+
+	stage = selfTestStageOracle
+	var latest uint64
+	err = traceStage(ctx, stage, func(ctx context.Context) error {
+		opts := &bind.CallOpts{Context: ctx}
+		chainHash, err := test.Binding.CHAINHASH(opts)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(chainHash[:], u.drandInfo.Hash()) {
+			return errors.New("the test oracle serves another drand network")
+		}
+		latest, err = test.Binding.LatestRound(opts)
+		return err
+	})
+	if err != nil {
+		return 0, "", stage, err
+	}
+
+	// An empty test oracle starts at the latest drand round
+	stage = selfTestStageFetch
+	var result client.Result
+	err = traceStage(ctx, stage, func(ctx context.Context) error {
+		next := latest + 1
+		if latest == 0 {
+			next = 0
+		}
+		result, err = retry.Value(ctx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+			return u.drandClient.Get(ctx, next)
+		})
+		return err
+	})
+	if err != nil {
+		return 0, "", stage, err
+	}
+	round = result.Round()
+	random := binding.IDrandOracleRandom{
+		Round:      round,
+		Timestamp:  u.roundTimestamp(round),
+		Randomness: [32]byte(result.Randomness()),
+		Signature:  result.Signature(),
+	}
+
+	stage = selfTestStageVerify
+	err = traceStage(ctx, stage, func(ctx context.Context) error {
+		return u.verifyFetchedRound(ctx, round, result.Randomness(), result.Signature())
+	})
+	if err != nil {
+		return round, "", stage, err
+	}
+
+	stage = selfTestStageSign
+	var signature []byte
+	err = traceStage(ctx, stage, func(ctx context.Context) error {
+		signature, err = test.Signer.SignSetRandomness(ctx, round, random.Timestamp, random.Randomness, random.Signature)
+		return err
+	})
+	if err != nil {
+		return round, "", stage, err
+	}
+
+	stage = selfTestStageSubmit
+	var tx *types.Transaction
+	var nonce uint64
+	err = traceStage(ctx, stage, func(ctx context.Context) error {
+		tx, nonce, err = u.submitSelfTest(ctx, random, signature)
+		return err
+	})
+	if err != nil {
+		return round, "", stage, err
+	}
+	txHash = tx.Hash().Hex()
+
+	stage = selfTestStageConfirm
+	receipt, err := u.waitMined(ctx, tx)
+	if err != nil {
+		return round, txHash, stage, err
+	}
+	u.nonces.Confirm(nonce)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return round, txHash, stage, errors.New("self-test transaction reverted")
+	}
+
+	stage = selfTestStageReadBack
+	err = traceStage(ctx, stage, func(ctx context.Context) error {
+		stored, err := test.Binding.GetRandomnessFromRound(&bind.CallOpts{Context: ctx, BlockNumber: receipt.BlockNumber}, round)
+		if err != nil {
+			return err
+		}
+		if stored.Randomness != random.Randomness || !bytes.Equal(stored.Signature, random.Signature) {
+			return fmt.Errorf("the test oracle stores randomness %x for round %d", stored.Randomness, round)
+		}
+		return nil
+	})
+	return round, txHash, stage, err
+}
+
+// submitSelfTest sends the setRandomness transaction of the self-test with the
+// sender, at the gas price of the strategy. Dry runs simulate it instead and
+// fail the stage, as nothing is broadcast.
+func (u *Updater) submitSelfTest(ctx context.Context, random binding.IDrandOracleRandom, signature []byte) (*types.Transaction, uint64, error) {
+	test := u.options.SelfTest
+	gasPrice, err := u.options.GasStrategy.GasPrice(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting gas price: %w", err)
+	}
+	if u.options.DryRun {
+		_, err := test.Binding.SetRandomness(&bind.TransactOpts{
+			Context:  ctx,
+			From:     u.sender.Address(),
+			Signer:   u.sender.SignerFn(),
+			GasPrice: gasPrice,
+			Value:    u.options.SubmissionFee,
+			NoSend:   true,
+		}, random, signature)
+		if err != nil {
+			return nil, 0, err
+		}
+		return nil, 0, errors.New("dry run, the self-test transaction is not broadcast")
+	}
+
+	nonce, err := u.nonces.Next(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting sender nonce: %w", err)
+	}
+	tx, err := test.Binding.SetRandomness(&bind.TransactOpts{
+		From:     u.sender.Address(),
+		Nonce:    new(big.Int).SetUint64(nonce),
+		Signer:   u.sender.SignerFn(),
+		GasLimit: u.setRandomnessGasLimit,
+		GasPrice: gasPrice,
+		Value:    u.options.SubmissionFee,
+		NoSend:   true,
+	}, random, signature)
+	if err != nil {
+		u.nonces.Release(nonce)
+		return nil, 0, err
+	}
+	if err := u.broadcast(ctx, tx); err != nil {
+		u.nonces.Release(nonce)
+		return nil, 0, err
+	}
+	u.nonces.Sent(tx)
+	return tx, nonce, nil
+}
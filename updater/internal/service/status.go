@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/canary"
+	"drand-oracle-updater/internal/limits"
+	"drand-oracle-updater/internal/store"
+	"drand-oracle-updater/internal/topup"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// ErrRoundNotFound is returned when a round has not been set on the oracle
+var ErrRoundNotFound = errors.New("round not found")
+
+// Status is a snapshot of the updater and oracle state
+type Status struct {
+	Pipeline string `json:"pipeline,omitempty"`
+	// Role is leader or standby with leader election, active with regions
+	Role string `json:"role,omitempty"`
+	// Region is the region of this updater among active regions
+	Region        string `json:"region,omitempty"`
+	ChainID       int64  `json:"chain_id"`
+	ChainHash     string `json:"chain_hash"`
+	OracleAddress string `json:"oracle_address"`
+	SignerAddress string `json:"signer_address"`
+	SenderAddress string `json:"sender_address"`
+	SenderBalance string `json:"sender_balance_wei,omitempty"`
+	// LastTopUp is the last top-up of the sender from the treasury
+	LastTopUp          *topup.TopUp  `json:"last_top_up,omitempty"`
+	DrandRound         uint64        `json:"drand_round"`
+	OracleRound        uint64        `json:"oracle_round"`
+	InFlightRound      uint64        `json:"in_flight_round,omitempty"`
+	PendingSubmissions int           `json:"pending_submissions"`
+	Queue              QueueStatus   `json:"queue"`
+	CircuitBreaker     BreakerStatus `json:"circuit_breaker"`
+	// SubmissionsPause is the operator pause of submissions
+	SubmissionsPause SubmissionsPause `json:"submissions_pause"`
+	// Authorization is the latest check that the oracle is not paused and
+	// its signer is the configured signer, nil before the first submission
+	Authorization *AuthorizationStatus `json:"authorization,omitempty"`
+	// Finality is the oracle state at the latest committed block, nil when the
+	// latest block is considered committed
+	Finality *Finality `json:"finality,omitempty"`
+	// Resources is the resource usage and degradation state of the process,
+	// nil without resource limits
+	Resources *limits.Status `json:"resources,omitempty"`
+	// ArchiveVerification is the progress of the archive verification
+	ArchiveVerification *ArchiveVerification `json:"archive_verification,omitempty"`
+	// SelfTest is the outcome of the latest self-tests, nil without self-test
+	SelfTest *SelfTestStatus `json:"self_test,omitempty"`
+	// Canary is the state of the canary rollout, nil without canary
+	Canary *canary.Status `json:"canary,omitempty"`
+	// Hibernation is the hibernation state, nil without hibernation
+	Hibernation *HibernationStatus `json:"hibernation,omitempty"`
+	// Annotations are the operational notes covering the current time or the
+	// latest oracle round
+	Annotations []store.Annotation `json:"annotations,omitempty"`
+	// Dependencies is the health of the external dependencies readiness is
+	// computed from
+	Dependencies DependencyMatrix `json:"dependencies"`
+}
+
+// Round is a round as stored in the Drand Oracle contract
+type Round struct {
+	Round      uint64    `json:"round"`
+	Timestamp  uint64    `json:"timestamp"`
+	Time       time.Time `json:"time"`
+	Randomness string    `json:"randomness"`
+	Signature  string    `json:"signature"`
+	// Committed is set once the round is set at the committed block under
+	// the finality policy
+	Committed bool `json:"committed"`
+	// Annotations are the operational notes covering the round or its time
+	Annotations []store.Annotation `json:"annotations,omitempty"`
+}
+
+// Status returns the current state of the updater
+func (u *Updater) Status() Status {
+	u.latestDrandRoundMutex.RLock()
+	drandRound := u.latestDrandRound
+	u.latestDrandRoundMutex.RUnlock()
+
+	u.inFlightRoundMutex.RLock()
+	inFlightRound := u.inFlightRound
+	u.inFlightRoundMutex.RUnlock()
+
+	queue := u.scheduler.Status()
+	pending := queue.Length
+	if inFlightRound != 0 {
+		pending++
+	}
+
+	status := Status{
+		Pipeline:            u.options.Pipeline,
+		Role:                u.role(),
+		ChainID:             u.chainID,
+		ChainHash:           u.drandInfo.HashString(),
+		OracleAddress:       u.oracleAddress.Hex(),
+		SignerAddress:       u.signer.Address().Hex(),
+		SenderAddress:       u.sender.Address().Hex(),
+		DrandRound:          drandRound,
+		OracleRound:         u.GetLatestOracleRound(),
+		InFlightRound:       inFlightRound,
+		PendingSubmissions:  pending,
+		Queue:               queue,
+		CircuitBreaker:      u.CircuitBreaker(),
+		SubmissionsPause:    u.SubmissionsPause(),
+		ArchiveVerification: u.ArchiveVerification(),
+		Annotations:         u.activeAnnotations(),
+		Dependencies:        u.Dependencies(),
+	}
+
+	u.senderBalanceMutex.RLock()
+	if u.senderBalance != nil {
+		status.SenderBalance = u.senderBalance.String()
+	}
+	u.senderBalanceMutex.RUnlock()
+	status.Finality = u.Finality()
+	status.Resources = u.options.Limits.Status()
+	status.SelfTest = u.SelfTest()
+	status.Authorization = u.Authorization()
+	status.Hibernation = u.Hibernation()
+	if u.options.Canary != nil {
+		canaryStatus := u.options.Canary.Controller.Status()
+		status.Canary = &canaryStatus
+	}
+	if u.options.Ownership != nil {
+		status.Region = u.options.Ownership.Region()
+	}
+	if u.options.TopUps != nil {
+		status.LastTopUp = u.options.TopUps.Last()
+	}
+
+	return status
+}
+
+// OracleRound reads a round from the Drand Oracle contract
+func (u *Updater) OracleRound(ctx context.Context, round uint64) (*Round, error) {
+	if round == 0 || round > u.GetLatestOracleRound() {
+		return nil, ErrRoundNotFound
+	}
+
+	random, err := u.binding.GetRandomnessFromRound(&bind.CallOpts{Context: ctx}, round)
+	if err != nil {
+		return nil, err
+	}
+	if random.Round == 0 {
+		return nil, ErrRoundNotFound
+	}
+
+	result := &Round{
+		Round:      random.Round,
+		Timestamp:  random.Timestamp,
+		Time:       time.Unix(int64(random.Timestamp), 0).UTC(),
+		Randomness: hex.EncodeToString(random.Randomness[:]),
+		Signature:  hex.EncodeToString(random.Signature),
+		Committed:  u.committedRound(random.Round),
+	}
+	if annotations, err := u.store.Annotations(); err == nil {
+		result.Annotations = store.Covering(annotations, result.Round, result.Time)
+	}
+	return result, nil
+}
+
+func (u *Updater) setInFlightRound(round uint64) {
+	u.inFlightRoundMutex.Lock()
+	u.inFlightRound = round
+	u.inFlightRoundMutex.Unlock()
+}
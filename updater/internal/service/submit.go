@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/retry"
+	"fmt"
+
+	"github.com/drand/drand/client"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/rs/zerolog/log"
+)
+
+// SubmitRounds submits the rounds from to to once, outside of the updater
+// loop, and returns the number of rounds submitted. Rounds already set on the
+// oracle are skipped. The contract only accepts the round following its latest
+// round, so the first remaining round must be that round, or the genesis round
+// on an empty oracle. from 0 starts at that round, to 0 submits up to the
+// latest drand round.
+func (u *Updater) SubmitRounds(ctx context.Context, from, to uint64) (int, error) {
+	latestRound, err := u.binding.LatestRound(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return 0, fmt.Errorf("error getting latest round from Drand Oracle contract: %w", err)
+	}
+	u.latestOracleRoundMutex.Lock()
+	u.latestOracleRound = latestRound
+	u.latestOracleRoundMutex.Unlock()
+	u.indexedRoundMutex.Lock()
+	u.indexedRound = latestRound
+	u.indexedRoundMutex.Unlock()
+
+	if to == 0 {
+		result, err := retry.Value(ctx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+			return u.drandClient.Get(ctx, 0)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error getting latest round from Drand network: %w", err)
+		}
+		to = result.Round()
+	}
+	if latestRound == 0 && from == 0 {
+		from = u.genesisRound
+	}
+	if from <= latestRound {
+		log.Info().Uint64("from", from).Uint64("oracle_round", latestRound).Msg("Skipping rounds already set on the oracle")
+		from = latestRound + 1
+	}
+	if from > to {
+		return 0, nil
+	}
+	if latestRound == 0 && from != u.genesisRound {
+		return 0, fmt.Errorf("the oracle is empty, the first round must be the genesis round %d", u.genesisRound)
+	}
+	if latestRound != 0 && from != latestRound+1 {
+		return 0, fmt.Errorf("round %d is not next, the oracle's latest round is %d", from, latestRound)
+	}
+	if err := u.validateSubmissionFee(ctx); err != nil {
+		return 0, err
+	}
+
+	submitted := 0
+	for round := from; round <= to; round++ {
+		if err := u.submitRound(ctx, round); err != nil {
+			return submitted, err
+		}
+		submitted++
+	}
+	return submitted, nil
+}
+
+// submitRound fetches a round from the drand network and submits it, traced
+// as a round of the critical lane
+func (u *Updater) submitRound(ctx context.Context, round uint64) (err error) {
+	ctx, span := u.startRoundSpan(ctx, round, LaneCritical)
+	defer func() { endSpan(span, err) }()
+
+	fetchCtx, fetchSpan := tracer.Start(ctx, "drand_fetch")
+	result, err := retry.Value(fetchCtx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+		return u.drandClient.Get(ctx, round)
+	})
+	endSpan(fetchSpan, err)
+	if err != nil {
+		return fmt.Errorf("error getting round %d from Drand network: %w", round, err)
+	}
+	submitCtx, submitSpan := tracer.Start(ctx, "submit")
+	err = u.processRound(submitCtx, result.Round(), result.Randomness(), result.Signature(), u.observeSource(result))
+	endSpan(submitSpan, err)
+	if err != nil {
+		return fmt.Errorf("error submitting round %d: %w", round, err)
+	}
+	return nil
+}
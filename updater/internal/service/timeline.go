@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/store"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Sources of timeline events
+const (
+	sourceChain   = "chain"
+	sourceUpdater = "updater"
+)
+
+// Kinds of timeline events
+const (
+	// Oracle changes
+	kindRoundSet       = "round_set"
+	kindSignerUpdated  = "signer_updated"
+	kindOraclePaused   = "oracle_paused"
+	kindOracleUnpaused = "oracle_unpaused"
+
+	// Updater changes
+	kindStarted               = "started"
+	kindStopped               = "stopped"
+	kindLeaderElected         = "leader_elected"
+	kindLeaderLost            = "leader_lost"
+	kindCircuitBreakerTripped = "circuit_breaker_tripped"
+	kindCircuitBreakerClosed  = "circuit_breaker_closed"
+	kindAlert                 = "alert"
+	kindAlertResolved         = "alert_resolved"
+	kindKeyRotated            = "key_rotated"
+	kindHibernated            = "hibernated"
+	kindWoken                 = "woken"
+	kindSubmissionsPaused     = "submissions_paused"
+	kindSubmissionsResumed    = "submissions_resumed"
+	kindReleasePublished      = "release_published"
+	kindSubmissionReorged     = "submission_reorged"
+	kindDecommissioned        = "decommissioned"
+	kindDebugWindowStarted    = "debug_window_started"
+	kindDebugWindowEnded      = "debug_window_ended"
+	kindAnnotation            = "annotation"
+)
+
+// chainEventLookback is how many blocks back the log of an oracle change is
+// looked up once the change is observed
+const chainEventLookback = 1000
+
+// TimelineEvent is an entry of the timeline
+type TimelineEvent struct {
+	Time    time.Time         `json:"time"`
+	Source  string            `json:"source"`
+	Kind    string            `json:"kind"`
+	Round   uint64            `json:"round,omitempty"`
+	Summary string            `json:"summary"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Timeline merges the rounds set on the oracle, the oracle changes observed by
+// the updater, the updater's own events and the annotations with a timestamp
+// in [from, to) into one feed ordered by time. A zero bound leaves that side
+// open. kinds selects the kinds of events, all when empty.
+func (u *Updater) Timeline(from, to time.Time, kinds []string) ([]TimelineEvent, error) {
+	selected := func(kind string) bool {
+		return len(kinds) == 0 || slices.Contains(kinds, kind)
+	}
+
+	var timeline []TimelineEvent
+	if selected(kindRoundSet) {
+		rounds, err := u.store.RoundsBetween(from, to)
+		if err != nil {
+			return nil, fmt.Errorf("error reading rounds index: %w", err)
+		}
+		for _, r := range rounds {
+			timeline = append(timeline, TimelineEvent{
+				Time:    r.Timestamp,
+				Source:  sourceChain,
+				Kind:    kindRoundSet,
+				Round:   r.Round,
+				Summary: fmt.Sprintf("Round %d set", r.Round),
+				Details: map[string]string{
+					"tx_hash":      r.TxHash,
+					"block_number": fmt.Sprintf("%d", r.BlockNumber),
+				},
+			})
+		}
+	}
+
+	events, err := u.store.Events(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error reading events: %w", err)
+	}
+	for _, e := range events {
+		if selected(e.Kind) {
+			timeline = append(timeline, TimelineEvent{
+				Time:    e.Timestamp,
+				Source:  e.Source,
+				Kind:    e.Kind,
+				Round:   e.Round,
+				Summary: e.Summary,
+				Details: e.Details,
+			})
+		}
+	}
+
+	if selected(kindAnnotation) {
+		annotations, err := u.store.Annotations()
+		if err != nil {
+			return nil, fmt.Errorf("error reading annotations: %w", err)
+		}
+		for _, a := range annotations {
+			if a.From == nil || (!from.IsZero() && a.From.Before(from)) || (!to.IsZero() && !a.From.Before(to)) {
+				continue
+			}
+			details := map[string]string{"id": a.ID}
+			if a.Author != "" {
+				details["author"] = a.Author
+			}
+			if a.To != nil {
+				details["to"] = a.To.Format(time.RFC3339)
+			}
+			timeline = append(timeline, TimelineEvent{
+				Time:    *a.From,
+				Source:  sourceUpdater,
+				Kind:    kindAnnotation,
+				Round:   a.FromRound,
+				Summary: a.Note,
+				Details: details,
+			})
+		}
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Time.Before(timeline[j].Time) })
+	return timeline, nil
+}
+
+// recordEvent appends an updater event to the timeline
+func (u *Updater) recordEvent(kind string, round uint64, summary string, details map[string]string) {
+	u.appendEvent(store.Event{
+		Timestamp: time.Now().UTC(),
+		Source:    sourceUpdater,
+		Kind:      kind,
+		Round:     round,
+		Summary:   summary,
+		Details:   details,
+	})
+}
+
+func (u *Updater) appendEvent(event store.Event) {
+	if err := u.store.AppendEvent(event); err != nil {
+		log.Warn().Err(err).Str("kind", event.Kind).Msg("Failed to record timeline event")
+	}
+}
+
+// recordOracleChange appends an oracle change observed by an authorization
+// check to the timeline. The change is timestamped with the block of its
+// event when it is found among the latest blocks, with the time it was
+// observed otherwise.
+func (u *Updater) recordOracleChange(ctx context.Context, kind string, eventName string, summary string, details map[string]string) {
+	event := store.Event{
+		Timestamp: time.Now().UTC(),
+		Source:    sourceChain,
+		Kind:      kind,
+		Summary:   summary,
+		Details:   details,
+	}
+	if found, ok := u.findOracleLog(ctx, eventName); ok {
+		event.Details["tx_hash"] = found.TxHash.Hex()
+		event.Details["block_number"] = fmt.Sprintf("%d", found.BlockNumber)
+		header, err := u.rpcClient.HeaderByNumber(ctx, new(big.Int).SetUint64(found.BlockNumber))
+		if err == nil {
+			event.Timestamp = time.Unix(int64(header.Time), 0).UTC()
+		}
+	} else {
+		event.Details["observed"] = "true"
+	}
+	u.appendEvent(event)
+}
+
+// findOracleLog returns the latest log of an oracle event among the latest
+// chainEventLookback blocks
+func (u *Updater) findOracleLog(ctx context.Context, eventName string) (types.Log, bool) {
+	contractABI, err := binding.BindingMetaData.GetAbi()
+	if err != nil {
+		return types.Log{}, false
+	}
+	abiEvent, ok := contractABI.Events[eventName]
+	if !ok {
+		return types.Log{}, false
+	}
+	latest, err := u.rpcClient.BlockNumber(ctx)
+	if err != nil {
+		return types.Log{}, false
+	}
+	query := ethereum.FilterQuery{
+		ToBlock:   new(big.Int).SetUint64(latest),
+		Addresses: []common.Address{u.oracleAddress},
+		Topics:    [][]common.Hash{{abiEvent.ID}},
+	}
+	if latest > chainEventLookback {
+		query.FromBlock = new(big.Int).SetUint64(latest - chainEventLookback)
+	}
+	logs, err := u.rpcClient.FilterLogs(ctx, query)
+	if err != nil || len(logs) == 0 {
+		return types.Log{}, false
+	}
+	return logs[len(logs)-1], true
+}
+
+// ParseTimelineKinds splits a comma separated list of event kinds
+func ParseTimelineKinds(value string) []string {
+	var kinds []string
+	for _, kind := range strings.Split(value, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
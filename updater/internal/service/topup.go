@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/internal/topup"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// fundPollInterval is the delay between balance checks while waiting for the
+// sender to be funded at startup
+const fundPollInterval = 5 * time.Second
+
+// topUp tops up the sender from the treasury when its balance fell below the
+// floor. Dry runs never move funds.
+func (u *Updater) topUp(ctx context.Context, balance *big.Int) {
+	if u.options.TopUps == nil || u.options.DryRun {
+		return
+	}
+	topUp, err := u.options.TopUps.Check(ctx, u.sender.Address(), balance)
+	switch {
+	case errors.Is(err, topup.ErrCoolingDown):
+		u.metrics.IncTopUp("cooldown")
+		log.Warn().Str("balance", balance.String()).Msg("Sender balance is below the top-up floor, waiting for the top-up cooldown")
+	case err != nil:
+		u.metrics.IncTopUp("failed")
+		log.Error().Err(err).Str("balance", balance.String()).Msg("Failed to top up the sender")
+	case topUp != nil:
+		amount, _ := new(big.Int).SetString(topUp.Amount, 10)
+		u.metrics.IncTopUp("success")
+		u.metrics.AddTopUpAmount(amount)
+		log.Info().
+			Str("treasury", topUp.Treasury).
+			Str("amount", topUp.Amount).
+			Str("reference", topUp.Reference).
+			Str("balance", balance.String()).
+			Msg("Sender topped up from the treasury")
+	}
+}
+
+// fundSender tops up the sender at startup when its balance is below the
+// floor, and waits up to FundWait for the funds to arrive, so that a freshly
+// derived sender of an ephemeral deployment is funded before its first
+// submission. The updater starts anyway when the wait times out, the balance
+// checks topping the sender up once the cooldown has passed.
+func (u *Updater) fundSender(ctx context.Context) {
+	if u.options.TopUps == nil || u.options.DryRun || u.options.FundWait <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, u.options.FundWait)
+	defer cancel()
+
+	address := u.sender.Address()
+	requested := false
+	for {
+		balance, err := u.rpcClient.BalanceAt(ctx, address, nil)
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Msg("Failed to get sender balance while waiting for it to be funded")
+			}
+		case u.options.TopUps.Amount(balance) == nil:
+			if requested {
+				log.Info().Str("address", address.Hex()).Str("balance", balance.String()).Msg("Sender funded")
+			}
+			return
+		case !requested:
+			// A single top-up is requested, the pipelines sharing the sender
+			// finding the cooldown running and waiting for its funds
+			log.Info().Str("address", address.Hex()).Str("balance", balance.String()).Msg("Sender balance is below the top-up floor, funding it before starting")
+			u.topUp(ctx, balance)
+			requested = true
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			log.Warn().Str("address", address.Hex()).Dur("wait", u.options.FundWait).Msg("Sender not funded in time, starting anyway")
+			return
+		case <-time.After(fundPollInterval):
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces the lifecycle of rounds: drand fetch, queue, signing,
+// broadcast and confirmation. It is a no-op unless a tracer provider is
+// installed.
+var tracer = otel.Tracer("drand-oracle-updater/internal/service")
+
+// Span attributes, round numbers being correlated with on-chain data
+const (
+	attrRound      = attribute.Key("drand.round")
+	attrFirstRound = attribute.Key("drand.first_round")
+	attrLastRound  = attribute.Key("drand.last_round")
+	attrSource     = attribute.Key("drand.source")
+	attrLane       = attribute.Key("drand.lane")
+	attrPipeline   = attribute.Key("oracle.pipeline")
+	attrChainID    = attribute.Key("oracle.chain_id")
+	attrOracle     = attribute.Key("oracle.address")
+	attrAttempt    = attribute.Key("oracle.attempt")
+	attrTxHash     = attribute.Key("tx.hash")
+	attrNonce      = attribute.Key("tx.nonce")
+	attrBlock      = attribute.Key("tx.block_number")
+	attrGasUsed    = attribute.Key("tx.gas_used")
+	attrTxStatus   = attribute.Key("tx.status")
+)
+
+// startRoundSpan starts the root span of a round, ended once the round is
+// submitted or dropped
+func (u *Updater) startRoundSpan(ctx context.Context, round uint64, lane Lane, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	opts = append(opts, trace.WithNewRoot(), trace.WithAttributes(
+		attrRound.Int64(int64(round)),
+		attrLane.String(string(lane)),
+		attrPipeline.String(u.options.Pipeline),
+		attrChainID.Int64(u.chainID),
+		attrOracle.String(u.oracleAddress.Hex()),
+	))
+	return tracer.Start(ctx, "round", opts...)
+}
+
+// traceReceivedRound starts the root span of a round received from drand,
+// from the time drand produced it, the time until it was received being
+// traced as the drand fetch
+func (u *Updater) traceReceivedRound(ctx context.Context, round uint64, lane Lane) trace.Span {
+	produced := time.Unix(int64(u.roundTimestamp(round)), 0)
+	ctx, span := u.startRoundSpan(ctx, round, lane, trace.WithTimestamp(produced))
+	_, fetch := tracer.Start(ctx, "drand_fetch", trace.WithTimestamp(produced))
+	fetch.End()
+	return span
+}
+
+// traceQueued records the time a round spent in the submission queue
+func (rd *roundData) traceQueued() {
+	if rd.span == nil || rd.queuedAt.IsZero() {
+		return
+	}
+	_, span := tracer.Start(trace.ContextWithSpan(context.Background(), rd.span), "queue", trace.WithTimestamp(rd.queuedAt))
+	span.End()
+}
+
+// context returns ctx carrying the round's span
+func (rd *roundData) context(ctx context.Context) context.Context {
+	if rd.span == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, rd.span)
+}
+
+// endSpan ends the round's span, with the error it failed with if any
+func (rd *roundData) endSpan(err error) {
+	if rd.span == nil {
+		return
+	}
+	endSpan(rd.span, err)
+}
+
+// endRoundSpans ends the spans of the rounds of a batch
+func endRoundSpans(batch []*roundData, err error) {
+	for _, rd := range batch {
+		rd.endSpan(err)
+	}
+}
+
+// endSpan ends a span, recording the error it failed with if any
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceStage runs a stage of a round's submission in a child span
+func traceStage(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, name)
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// roundLinks links a batch span to the spans of the rounds it submits
+func roundLinks(batch []*roundData) []trace.Link {
+	var links []trace.Link
+	for _, rd := range batch {
+		if rd.span != nil {
+			links = append(links, trace.Link{
+				SpanContext: rd.span.SpanContext(),
+				Attributes:  []attribute.KeyValue{attrRound.Int64(int64(rd.round))},
+			})
+		}
+	}
+	return links
+}
+
+// waitMined waits for a transaction to be mined, traced as its confirmation
+func (u *Updater) waitMined(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	ctx, span := tracer.Start(ctx, "confirm", trace.WithAttributes(attrTxHash.String(tx.Hash().Hex())))
+	defer span.End()
+	receipt, err := bind.WaitMined(ctx, u.rpcClient, tx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(
+		attrBlock.Int64(receipt.BlockNumber.Int64()),
+		attrGasUsed.Int64(int64(receipt.GasUsed)),
+		attrTxStatus.Int64(int64(receipt.Status)),
+	)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		span.SetStatus(codes.Error, "transaction reverted")
+	}
+	return receipt, nil
+}
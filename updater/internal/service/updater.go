@@ -3,13 +3,30 @@ package service
 import (
 	"bytes"
 	"context"
+	"drand-oracle-updater/alerting"
 	"drand-oracle-updater/binding"
+	"drand-oracle-updater/gas"
+	"drand-oracle-updater/internal/accounts"
+	"drand-oracle-updater/internal/audit"
+	"drand-oracle-updater/internal/finality"
+	"drand-oracle-updater/internal/limits"
+	"drand-oracle-updater/internal/notify"
+	"drand-oracle-updater/internal/records"
+	"drand-oracle-updater/internal/retry"
+	"drand-oracle-updater/internal/store"
+	"drand-oracle-updater/internal/topup"
+	"drand-oracle-updater/internal/version"
+	"drand-oracle-updater/internal/watcher"
 	"drand-oracle-updater/sender"
 	"drand-oracle-updater/signer"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"math"
+	"math/big"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/drand/drand/chain"
@@ -18,7 +35,9 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -38,6 +57,9 @@ type Updater struct {
 	// binding is the Drand Oracle contract binding
 	binding *binding.Binding
 
+	// chain broadcasts the rounds' transactions and confirms them once mined
+	chain evmSubmitter
+
 	// chainID is the chain ID
 	chainID int64
 
@@ -47,11 +69,12 @@ type Updater struct {
 	// genesisRound is the round at which oracle starts tracking
 	genesisRound uint64
 
-	// roundChan is the channel for processing rounds
-	roundChan chan *roundData
+	// scheduler queues the rounds waiting to be submitted
+	scheduler *scheduler
 
-	// maxRetries is the maximum number of retries for processing a round
-	maxRetries int
+	// settings are the tunables that can be changed while running
+	settings      Settings
+	settingsMutex sync.RWMutex
 
 	// latestOracleRound keeps track of the latest round processed by the Oracle
 	latestOracleRound      uint64
@@ -61,22 +84,376 @@ type Updater struct {
 	latestDrandRound      uint64
 	latestDrandRoundMutex sync.RWMutex
 
-	// signer is the signer for the Drand Oracle contract
-	signer *signer.Signer
+	// lastDrandRoundAt is when the latest round was received from the Drand network
+	lastDrandRoundAt time.Time
+
+	// running is set while the updater goroutines are running
+	running atomic.Bool
+
+	// stopping is set once Stop has been called
+	stopping atomic.Bool
+
+	// leading is whether this replica was the leader as of the latest check,
+	// with leader election
+	leading atomic.Bool
+
+	// raisedAlerts are the alert conditions raised and not resolved since
+	raisedAlerts raisedAlerts
+
+	// pauseProposed is set once a pause of the oracle has been proposed
+	pauseProposed atomic.Bool
+
+	// cancelIntake and cancelSubmit stop the intake of new rounds and the
+	// submissions, they are set by Start
+	cancelIntake context.CancelFunc
+	cancelSubmit context.CancelFunc
+	stopMutex    sync.Mutex
+
+	// intakeCtx is the context of the intake of new rounds, set by Start
+	intakeCtx context.Context
+
+	// catchingUp is set while catching up
+	catchingUp atomic.Bool
+
+	// pause is the operator pause of submissions
+	pause pauseState
+
+	// done is closed when Start returns
+	done chan struct{}
+
+	// inFlightRound is the round currently being submitted, 0 if none
+	inFlightRound      uint64
+	inFlightRoundMutex sync.RWMutex
+
+	// senderBalance is the last observed balance of the sender
+	senderBalance      *big.Int
+	senderBalanceMutex sync.RWMutex
+
+	// signer is the signer for the Drand Oracle contract, switched by a
+	// signer rotation
+	signer *signer.RotatingSigner
 
 	// sender is the sender for the Drand Oracle contract
 	sender *sender.Sender
 
+	// nonces hands out the sender's nonces and fills gaps left by dropped transactions
+	nonces *sender.NonceManager
+
+	// submissions is held while a round is submitted, and by a signer rotation
+	// to hold rounds back
+	submissions chan struct{}
+
+	// pipelined holds the catch-up rounds broadcast ahead of the mining of
+	// the previous ones, nil without a submission pipeline
+	pipelined *submissionPipeline
+
+	// watcher follows the oracle's RandomnessUpdated events
+	watcher *watcher.Watcher
+
+	// hibernation tracks whether the updater hibernates
+	hibernation hibernationState
+
+	// finality resolves the committed block, and committed is the oracle
+	// state at that block
+	finality       *finality.Tracker
+	committed      Finality
+	committedMutex sync.RWMutex
+
+	// confirmations are the mined submissions whose block is not committed yet
+	confirmations      []pendingConfirmation
+	confirmationsMutex sync.Mutex
+
+	// probes are the latest probes of the dependencies without a health of
+	// their own
+	probes dependencyProbes
+
+	// selfTest is the outcome of the latest self-tests
+	selfTest selfTestState
+
+	// indexedRound is the latest round appended to the rounds index
+	indexedRound      uint64
+	indexedRoundMutex sync.Mutex
+
+	// store is the local state store
+	store *store.Store
+
+	// options holds the optional tunables of the updater
+	options Options
+
+	// vacuumMutex serializes scheduled and admin triggered compactions
+	vacuumMutex sync.Mutex
+
+	// breaker pauses submissions after too many losses on failed transactions
+	breaker *lossBreaker
+
+	// authorization is the latest check that the oracle accepts the
+	// updater's rounds
+	authorization authorizationState
+
+	// archiveProgress is the progress of the archive verification
+	archiveProgress ArchiveVerification
+	archiveMutex    sync.RWMutex
+
+	// catchUpGate holds the catch-up cost estimate and its approval
+	catchUpGate catchUpGate
+
+	// drandRetrier, rpcRetrier and broadcastRetrier retry transient failures
+	// of drand fetches, RPC reads and transaction broadcasts
+	drandRetrier     *retry.Retrier
+	rpcRetrier       *retry.Retrier
+	broadcastRetrier *retry.Retrier
+
+	// dryRunGasEstimate is the last setRandomness gas estimate in dry run mode
+	dryRunGasEstimate uint64
+
+	// compactCalldata is set when the oracle supports the compact calldata
+	// form, negotiated at startup
+	compactCalldata bool
+
 	// Metrics instance
 	metrics *Metrics
 }
 
 const balanceUpdateInterval = 1 * time.Minute
 
+const defaultEventsPollInterval = 12 * time.Second
+
+// catchUpRetryInterval is the delay before the catch-up starts over after a
+// drand fetch failed on every attempt
+const catchUpRetryInterval = 10 * time.Second
+
+// errAlreadyKnown is the error message of geth based nodes for a transaction
+// already in their pool
+const errAlreadyKnown = "already known"
+
+// Options holds the optional tunables of the updater
+type Options struct {
+	// SubmissionFee is the msg.value sent with every setRandomness transaction
+	SubmissionFee *big.Int
+
+	// MinSenderBalance is the sender balance below which the updater is not ready
+	MinSenderBalance *big.Int
+
+	// MaxRoundLag is the drand to oracle round lag above which the updater is not ready
+	MaxRoundLag uint64
+
+	// Retention is the retention policy of the local state store
+	Retention store.Retention
+
+	// CompactionInterval is the interval between state store compactions, 0 disables them
+	CompactionInterval time.Duration
+
+	// ArchiveVerifyRate is the number of indexed rounds re-verified per
+	// second, 0 disables the archive verification
+	ArchiveVerifyRate float64
+
+	// ArchiveVerifyInterval is the delay between archive verification passes
+	ArchiveVerifyInterval time.Duration
+
+	// RoundObjects uploads every indexed round to the archive bucket, nil
+	// when no bucket is configured
+	RoundObjects *records.RoundObjects
+
+	// DryRun simulates setRandomness transactions instead of broadcasting them
+	DryRun bool
+
+	// GasStrategy prices setRandomness transactions, nil uses the RPC suggested gas price
+	GasStrategy gas.Strategy
+
+	// SchedulerPolicy orders the rounds waiting to be submitted
+	SchedulerPolicy Policy
+
+	// QueueSize is the number of rounds the submission queue holds before blocking producers
+	QueueSize int
+
+	// LossLimit is the amount spent on failed transactions within LossWindow
+	// above which submissions are paused, nil or 0 disables the circuit breaker
+	LossLimit  *big.Int
+	LossWindow time.Duration
+
+	// LossCooldown is how long the circuit breaker stays open, 0 until it is reset
+	LossCooldown time.Duration
+
+	// Alerts sends push alerts, nil disables alerting
+	Alerts *alerting.Dispatcher
+
+	// AlertAfterRetries is the number of failed attempts of a round after which
+	// an alert is sent, 0 alerts only once all retries are exhausted
+	AlertAfterRetries int
+
+	// CatchUpCostCeiling is the estimated catch-up cost above which the catch-up
+	// waits for approval, nil or 0 disables the ceiling
+	CatchUpCostCeiling *big.Int
+
+	// CatchUpRequireApproval makes every catch-up wait for approval
+	CatchUpRequireApproval bool
+
+	// CatchUpPolicy selects the rounds submitted while catching up, the zero
+	// value submits every round
+	CatchUpPolicy CatchUpPolicy
+
+	// BatchSize is the maximum number of consecutive catch-up rounds set by
+	// one setRandomnessBatch transaction, 1 or less submits rounds one by one
+	BatchSize int
+
+	// DeploymentLabels are attached to every metric
+	DeploymentLabels map[string]string
+
+	// Pipeline names the drand network to oracle pipeline when several run in
+	// one process, it is attached to every metric as the pipeline label
+	Pipeline string
+
+	// Nonces is the sender's nonce manager, shared by the pipelines using the
+	// same sender. nil creates one for this updater.
+	Nonces *sender.NonceManager
+
+	// EventsClient subscribes to the oracle's events, it must be connected over
+	// WebSocket or IPC. nil polls logs over the RPC client only.
+	EventsClient *ethclient.Client
+
+	// EventsPollInterval is the interval between log queries while not subscribed
+	EventsPollInterval time.Duration
+
+	// MinSetDelay is the minimum delay the oracle contract enforces between a
+	// round's drand timestamp and setting it. Rounds are prepared during the
+	// delay and broadcast once it elapses. 0 when the contract enforces none.
+	MinSetDelay time.Duration
+
+	// ChainClockMaxWait is how long a round waits for the chain's latest block
+	// timestamp to reach the round's, 0 disables the chain clock check
+	ChainClockMaxWait time.Duration
+
+	// Retry is the retry policy of drand fetches, RPC reads and broadcasts,
+	// zero fields use retry.DefaultPolicy
+	Retry retry.Policy
+
+	// UserOperations sends setRandomness calls as ERC-4337 user operations of a
+	// smart account instead of transactions of the sender, nil sends transactions
+	UserOperations *sender.UserOpSender
+
+	// Leader elects the replica submitting transactions among redundant
+	// replicas, nil submits unconditionally
+	Leader LeaderElection
+
+	// Ownership partitions rounds among active regions, nil submits every
+	// round
+	Ownership RoundOwnership
+
+	// PauseProposer proposes pausing the oracle to its owner when a security
+	// check fails, nil only alerts
+	PauseProposer PauseProposer
+
+	// TopUps tops up the sender from a treasury when its balance runs low,
+	// shared by the pipelines using the same sender. nil disables top-ups.
+	TopUps *topup.Manager
+	// FundWait is how long Start waits for the sender to be topped up to the
+	// floor before checking the oracle, 0 starts right away
+	FundWait time.Duration
+
+	// Accounts polls the balances and nonces of the managed accounts in
+	// batches, shared by the pipelines. Each pipeline polls its sender
+	// itself when nil.
+	Accounts *accounts.Poller
+
+	// KeyRotation holds the sender and signer keys staged for a rotation
+	KeyRotation KeyRotation
+
+	// Hibernation hibernates the updater while no request event is emitted.
+	// nil never hibernates.
+	Hibernation *Hibernation
+
+	// CallCache caches the oracle's view calls made through the binding, nil
+	// when they are not cached
+	CallCache CallCache
+
+	// DrandRelays reports the health of the drand relays, nil when they are
+	// not tracked
+	DrandRelays DrandRelays
+
+	// RPCEndpoints reports the health of the pooled RPC endpoints, nil with a
+	// single endpoint, whose health is probed by the readiness check
+	RPCEndpoints RPCEndpoints
+
+	// Releases publishes each day of rounds to IPFS, nil disables releases
+	Releases *Releases
+
+	// Limits degrades background work as the process approaches its memory
+	// and goroutine limits, shared by the pipelines. nil never degrades.
+	Limits *limits.Governor
+
+	// Finality resolves the blocks the chain considers committed, the zero
+	// value considers the latest block committed
+	Finality finality.Policy
+
+	// ConfirmCommitted follows every mined submission until its block is
+	// committed, and catches up again on the rounds of those reorged out
+	ConfirmCommitted bool
+
+	// CalldataForm is the form of the setRandomness calldata, negotiated
+	// with the oracle at startup. The zero value is CalldataAuto.
+	CalldataForm CalldataForm
+
+	// Audit records every submission decision, shared by the pipelines. nil
+	// disables the audit log.
+	Audit *audit.Log
+
+	// Consumers posts the rounds landing on the oracle to the consumer
+	// webhooks, shared by the pipelines. nil disables the notifications.
+	Consumers *notify.Notifier
+
+	// CatchUpFetchWorkers is the number of catch-up rounds fetched from the
+	// drand network concurrently, queued in order, 1 or less fetches them one
+	// by one
+	CatchUpFetchWorkers int
+
+	// CatchUpSignWorkers is the number of catch-up rounds signed concurrently
+	// ahead of their submission, 0 signs them as they are submitted
+	CatchUpSignWorkers int
+
+	// InFlightRounds is the depth of the submission pipeline: the number of
+	// catch-up rounds broadcast before the previous ones are mined, 1 or less
+	// waits for every round to be mined
+	InFlightRounds int
+
+	// SelfTest periodically submits a round to a test oracle end to end,
+	// proving a standby instance ready to take over. nil disables it.
+	SelfTest *SelfTest
+
+	// Canary rolls out an alternative gas strategy or batch size to a share
+	// of the rounds. nil submits every round the same way.
+	Canary *Canary
+}
+
 type roundData struct {
 	round      uint64
 	randomness []byte
 	signature  []byte
+	// source is where the round was read from when not from the drand relays
+	source string
+	// span is the root span of the round's lifecycle
+	span trace.Span
+	// queuedAt is the time the round was queued at
+	queuedAt time.Time
+	// lane is the lane the round was queued in
+	lane Lane
+	// attempts is the number of failed submissions of a round requeued by the
+	// submission pipeline
+	attempts int
+	// presigned is the EIP-712 signature signed ahead by the catch-up
+	// pipeline, nil if none
+	presigned *presignature
+}
+
+// observeSource returns the source of a drand result, empty for the drand
+// relays, and counts the rounds read from a fallback source
+func (u *Updater) observeSource(result client.Result) string {
+	r, ok := result.(interface{ Source() string })
+	if !ok {
+		return ""
+	}
+	source := r.Source()
+	u.metrics.IncFallbackRound(source)
+	return source
 }
 
 func NewUpdater(
@@ -88,8 +465,10 @@ func NewUpdater(
 	binding *binding.Binding,
 	genesisRound uint64,
 	maxRetries int,
-	signer *signer.Signer,
+	signer signer.SetRandomnessSigner,
 	sender *sender.Sender,
+	store *store.Store,
+	options Options,
 ) (*Updater, error) {
 	// Set a timeout for the Drand info request
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -101,38 +480,98 @@ func NewUpdater(
 		return nil, err
 	}
 
+	if err := validateConstLabels(options.DeploymentLabels); err != nil {
+		return nil, err
+	}
+	constLabels := make(map[string]string, len(options.DeploymentLabels)+1)
+	for k, v := range options.DeploymentLabels {
+		constLabels[k] = v
+	}
+	if options.Pipeline != "" {
+		constLabels[labelPipeline] = options.Pipeline
+	}
+	if options.GasStrategy == nil {
+		options.GasStrategy = gas.NewRPCStrategy(rpcClient)
+	}
+	if options.EventsPollInterval <= 0 {
+		options.EventsPollInterval = defaultEventsPollInterval
+	}
+
 	updater := &Updater{
 		drandClient:           drandClient,
+		drandInfo:             drandInfo,
 		rpcClient:             rpcClient,
 		setRandomnessGasLimit: setRandomnessGasLimit,
 		chainID:               chainID,
 		oracleAddress:         oracleAddress,
 		binding:               binding,
 		genesisRound:          genesisRound,
-		roundChan:             make(chan *roundData, 1),
-		maxRetries:            maxRetries,
 		latestOracleRound:     0,
 		latestDrandRound:      0,
-		signer:                signer,
+		signer:                newRotatingSigner(signer),
 		sender:                sender,
+		store:                 store,
+		options:               options,
+		done:                  make(chan struct{}),
+		submissions:           make(chan struct{}, 1),
+		pipelined:             newSubmissionPipeline(options.InFlightRounds),
+		breaker:               newLossBreaker(options.LossLimit, options.LossWindow, options.LossCooldown),
 		metrics: NewMetrics(
 			chainID,
 			oracleAddress,
 			sender.Address(),
 			drandInfo,
+			constLabels,
 		),
 	}
+	updater.settings = Settings{
+		MaxRetries:          maxRetries,
+		AlertAfterRetries:   options.AlertAfterRetries,
+		MinSenderBalance:    options.MinSenderBalance,
+		MaxRoundLag:         options.MaxRoundLag,
+		AlertRepeatInterval: options.Alerts.RepeatInterval(),
+		Retry:               options.Retry,
+	}
+	updater.chain = evmSubmitter{updater}
+	updater.hibernation.wake = make(chan struct{}, 1)
+	updater.drandRetrier = retry.New("drand", options.Retry, updater.metrics)
+	updater.rpcRetrier = retry.New("rpc", options.Retry, updater.metrics)
+	updater.broadcastRetrier = retry.New("broadcast", options.Retry, updater.metrics)
+	updater.watcher, err = newWatcher(rpcClient, binding, oracleAddress, options)
+	if err != nil {
+		return nil, err
+	}
+	updater.finality = finality.NewTracker(rpcClient, options.Finality)
+	updater.nonces = options.Nonces
+	if updater.nonces == nil {
+		updater.nonces = newNonceManager(rpcClient, sender)
+	}
+	updater.scheduler = newScheduler(options.SchedulerPolicy, options.QueueSize, updater.submittable, updater.metrics.SetQueueLength, options.Limits.Degraded)
 	return updater, nil
 }
 
+// submittable reports whether a round can be processed now: the genesis round,
+// the round following the oracle's latest round or the latest round broadcast
+// by the submission pipeline, or a stale round to be skipped.
+// Any round is when the catch-up policy skips rounds.
+func (u *Updater) submittable(round uint64) bool {
+	return round == u.genesisRound || round <= u.submittedRound()+1 || u.options.CatchUpPolicy.Skips()
+}
+
 func (u *Updater) Start(ctx context.Context) error {
+	u.fundSender(ctx)
+
 	// Get the earliest and latest round from the Drand Oracle contract
-	earliestRound, err := u.binding.EarliestRound(nil)
+	earliestRound, err := retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) (uint64, error) {
+		return u.binding.EarliestRound(&bind.CallOpts{Context: ctx})
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get earliest round from Drand Oracle contract")
 		return err
 	}
-	latestRound, err := u.binding.LatestRound(nil)
+	latestRound, err := retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) (uint64, error) {
+		return u.binding.LatestRound(&bind.CallOpts{Context: ctx})
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get latest round from Drand Oracle contract")
 		return err
@@ -140,53 +579,134 @@ func (u *Updater) Start(ctx context.Context) error {
 	u.latestOracleRoundMutex.Lock()
 	u.latestOracleRound = latestRound
 	u.latestOracleRoundMutex.Unlock()
+	u.indexedRoundMutex.Lock()
+	u.indexedRound = latestRound
+	u.indexedRoundMutex.Unlock()
 	log.Info().Msgf("Oracle: Earliest round: %d, Latest round: %d", earliestRound, latestRound)
 
 	// Get the latest round from the Drand network
-	latestDrandRound, err := u.drandClient.Get(ctx, 0)
+	latestDrandRound, err := retry.Value(ctx, u.drandRetrier, func(ctx context.Context) (client.Result, error) {
+		return u.drandClient.Get(ctx, 0)
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get latest round from Drand network")
 		return err
 	}
 	u.latestDrandRoundMutex.Lock()
 	u.latestDrandRound = latestDrandRound.Round()
+	u.lastDrandRoundAt = time.Now()
 	u.latestDrandRoundMutex.Unlock()
 	log.Info().Msgf("Drand: Latest round: %d", u.latestDrandRound)
+	u.updateRoundLag()
 
 	// Get and validate the Drand info against the Oracle contract
-	u.drandInfo, err = u.drandClient.Info(ctx)
+	u.drandInfo, err = retry.Value(ctx, u.drandRetrier, u.drandClient.Info)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get Drand info")
 		return err
 	}
-	chainHash, err := u.binding.CHAINHASH(nil)
+	chainHash, err := retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) ([32]byte, error) {
+		return u.binding.CHAINHASH(&bind.CallOpts{Context: ctx})
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get chain hash from Drand Oracle contract")
 		return err
 	}
 	if !bytes.Equal(chainHash[:], u.drandInfo.Hash()) {
+		u.reportCompromise(ctx, checkChainInfo, "The oracle chain hash does not match the drand network", map[string]string{
+			"oracle_chain_hash": hex.EncodeToString(chainHash[:]),
+			"drand_chain_hash":  u.drandInfo.HashString(),
+		})
 		err = errors.New("chain hash mismatch")
 		return err
 	}
 
-	// Start the updater goroutines
+	// Validate the submission fee against the Oracle contract
+	if err := u.validateSubmissionFee(ctx); err != nil {
+		log.Error().Err(err).Msg("Invalid submission fee")
+		return err
+	}
+
+	if err := u.negotiateCalldata(ctx); err != nil {
+		log.Error().Err(err).Msg("Invalid calldata form")
+		return err
+	}
+
+	// Validate that the oracle accepts the rounds the catch-up policy skips
+	if err := u.checkCatchUpPolicy(ctx); err != nil {
+		log.Error().Err(err).Msg("Invalid catch-up policy")
+		return err
+	}
+
+	u.logPreviousShutdown()
+	u.recordEvent(kindStarted, latestRound, "Updater started", map[string]string{
+		"version":     version.Get().Version,
+		"drand_round": fmt.Sprintf("%d", latestDrandRound.Round()),
+	})
+
+	// Start the updater goroutines. Stop cancels the intake of new rounds first
+	// and lets the in-flight submission confirm before cancelling submissions.
 	errg, gCtx := errgroup.WithContext(ctx)
+	intakeCtx, cancelIntake := context.WithCancel(gCtx)
+	defer cancelIntake()
+	submitCtx, cancelSubmit := context.WithCancel(gCtx)
+	defer cancelSubmit()
+	u.stopMutex.Lock()
+	u.cancelIntake, u.cancelSubmit = cancelIntake, cancelSubmit
+	u.intakeCtx = intakeCtx
+	u.stopMutex.Unlock()
+	defer close(u.done)
+
+	errg.Go(func() error {
+		return u.ignoreStop(u.processRounds(submitCtx, intakeCtx))
+	})
+	errg.Go(func() error {
+		return u.ignoreStop(u.catchUp(intakeCtx))
+	})
+	errg.Go(func() error {
+		return u.ignoreStop(u.watchNewRounds(intakeCtx))
+	})
 	errg.Go(func() error {
-		return u.processRounds(gCtx)
+		return u.ignoreStop(u.monitorBalance(intakeCtx))
 	})
 	errg.Go(func() error {
-		return u.catchUp(gCtx)
+		return u.ignoreStop(u.compactStore(intakeCtx))
 	})
 	errg.Go(func() error {
-		return u.watchNewRounds(gCtx)
+		return u.ignoreStop(u.monitorNonces(intakeCtx))
 	})
 	errg.Go(func() error {
-		return u.monitorBalance(gCtx)
+		return u.ignoreStop(u.verifyArchive(intakeCtx))
 	})
+	errg.Go(func() error {
+		return u.ignoreStop(u.watchOracleRounds(intakeCtx))
+	})
+	errg.Go(func() error {
+		return u.ignoreStop(u.trackCommitted(intakeCtx))
+	})
+	errg.Go(func() error {
+		return u.ignoreStop(u.runSelfTests(intakeCtx))
+	})
+	errg.Go(func() error {
+		return u.ignoreStop(u.runHibernation(intakeCtx))
+	})
+	errg.Go(func() error {
+		return u.ignoreStop(u.publishReleases(intakeCtx))
+	})
+
+	u.running.Store(true)
+	defer u.running.Store(false)
 	return errg.Wait()
 }
 
 func (u *Updater) catchUp(ctx context.Context) error {
+	// The running catch-up picks up every round up to the latest drand round
+	if !u.catchingUp.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer u.catchingUp.Store(false)
+
+	estimated := false
 	for {
 		u.latestDrandRoundMutex.Lock()
 		latestDrandRound := u.latestDrandRound
@@ -207,23 +727,35 @@ func (u *Updater) catchUp(ctx context.Context) error {
 		} else {
 			currentRound = latestOracleRound + 1
 		}
+		currentRound = u.catchUpNext(u.catchUpStart(currentRound), latestDrandRound)
 
-		for currentRound <= latestDrandRound {
-			result, err := u.drandClient.Get(ctx, currentRound)
-			if err != nil {
-				log.Error().Err(err).Uint64("round", currentRound).Msg("Failed to get round from Drand network")
+		// Estimate the cost of the initial backlog only, later iterations just
+		// pick up the rounds produced in the meantime
+		if !estimated && currentRound <= latestDrandRound {
+			if err := u.gateCatchUp(ctx, currentRound, latestDrandRound); err != nil {
 				return err
 			}
+			estimated = true
+		}
 
+		if currentRound > latestDrandRound {
+			continue
+		}
+		if err := u.fetchBacklog(ctx, currentRound, latestDrandRound); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Start over once the drand relays had time to recover,
+			// rather than stopping the updater
+			log.Error().
+				Err(err).
+				Uint64("from_round", currentRound).
+				Dur("retry_in", catchUpRetryInterval).
+				Msg("Failed to catch up rounds from Drand network")
 			select {
-			case u.roundChan <- &roundData{
-				round:      result.Round(),
-				randomness: result.Randomness(),
-				signature:  result.Signature(),
-			}:
-				currentRound++
 			case <-ctx.Done():
 				return ctx.Err()
+			case <-time.After(catchUpRetryInterval):
 			}
 		}
 	}
@@ -234,61 +766,172 @@ func (u *Updater) watchNewRounds(ctx context.Context) error {
 	for result := range u.drandClient.Watch(ctx) {
 		u.latestDrandRoundMutex.Lock()
 		u.latestDrandRound = result.Round()
+		u.lastDrandRoundAt = time.Now()
 		u.metrics.SetDrandRound(float64(result.Round()))
 		u.latestDrandRoundMutex.Unlock()
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case u.roundChan <- &roundData{
+		u.updateRoundLag()
+		// Rounds produced while hibernating are caught up on wake-up
+		if u.hibernating() {
+			continue
+		}
+		span := u.traceReceivedRound(ctx, result.Round(), LaneLive)
+		err := u.scheduler.Push(ctx, &roundData{
 			round:      result.Round(),
 			randomness: result.Randomness(),
 			signature:  result.Signature(),
-		}:
+			source:     u.observeSource(result),
+			span:       span,
+		}, LaneLive)
+		if err != nil {
+			endSpan(span, err)
+			return err
 		}
 	}
 	return nil
 }
 
-func (u *Updater) processRounds(ctx context.Context) error {
+// processRounds submits queued rounds until intakeCtx is cancelled. The round
+// in flight at that point is still submitted with ctx.
+func (u *Updater) processRounds(ctx context.Context, intakeCtx context.Context) error {
+	// The rounds broadcast by the submission pipeline are confirmed before
+	// returning
+	defer u.startPipeline(ctx)()
 	for {
-		select {
-		case <-ctx.Done():
+		rd, err := u.scheduler.Pop(intakeCtx)
+		if err != nil {
 			log.Debug().Msg("processRounds goroutine cancelled")
-			return ctx.Err()
-		case rd := <-u.roundChan:
-			var err error
-			for attempt := 0; attempt < u.maxRetries; attempt++ {
-				err = u.processRound(ctx, rd.round, rd.randomness, rd.signature)
-				if err == nil {
-					break
-				}
+			return err
+		}
 
-				if attempt < u.maxRetries-1 {
-					backoffDuration := time.Duration(math.Pow(2, float64(attempt))) * time.Second
-					log.Warn().
-						Err(err).
-						Uint64("round", rd.round).
-						Int("attempt", attempt+1).
-						Dur("backoff", backoffDuration).
-						Msg("Retrying round processing after backoff")
-
-					select {
-					case <-ctx.Done():
-						return ctx.Err()
-					case <-time.After(backoffDuration):
-						continue
-					}
-				}
+		// A standby replica only submits rounds the leader did not set once
+		// it took over
+		elected, err := u.awaitLeadership(intakeCtx, rd.round)
+		if err != nil {
+			return err
+		}
+		if !elected {
+			u.auditDecision(audit.DecisionSkipped, rd.round, 0, rd.signature, "set by the leader", "")
+			rd.endSpan(nil)
+			continue
+		}
+		owned, err := u.awaitOwnership(intakeCtx, rd.round)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			u.auditDecision(audit.DecisionSkipped, rd.round, 0, rd.signature, "set by the owner region", "")
+			rd.endSpan(nil)
+			continue
+		}
+
+		// Queued catch-up rounds following the round are submitted along
+		// with it when batching
+		batch := u.collectBatch(rd)
+		for _, queued := range batch {
+			queued.traceQueued()
+		}
+		if u.pipelinable(rd, batch) {
+			if err := u.pipelineRound(ctx, rd); err != nil {
+				return err
+			}
+			continue
+		}
+		// Other rounds are submitted once the pipelined rounds are mined,
+		// unless a failed pipelined round must be submitted first
+		if err := u.drainPipeline(ctx); err != nil {
+			endRoundSpans(batch, err)
+			return err
+		}
+		if !u.submittable(rd.round) {
+			for _, queued := range batch {
+				u.scheduler.Requeue(queued)
+			}
+			continue
+		}
+
+		// Settings changed while a round is retried apply from the next round
+		settings := u.Settings()
+		u.setInFlightRound(rd.round)
+		processingStart := time.Now()
+		// A round requeued by the submission pipeline already failed once
+		for attempt := min(rd.attempts, settings.MaxRetries-1); attempt < settings.MaxRetries; attempt++ {
+			if err := u.waitForResume(ctx); err != nil {
+				endRoundSpans(batch, err)
+				return err
+			}
+			if err := u.waitForBreaker(ctx); err != nil {
+				endRoundSpans(batch, err)
+				return err
+			}
+			if err := u.waitForAuthorization(ctx); err != nil {
+				endRoundSpans(batch, err)
+				return err
+			}
+			attemptCtx, span := tracer.Start(rd.context(ctx), "submit", trace.WithLinks(roundLinks(batch[1:])...), trace.WithAttributes(
+				attrAttempt.Int(attempt+1),
+				attrFirstRound.Int64(int64(batch[0].round)),
+				attrLastRound.Int64(int64(batch[len(batch)-1].round)),
+			))
+			err = u.submitRoundsHeld(attemptCtx, batch)
+			endSpan(span, err)
+			if err == nil {
+				break
+			}
+			if attempt+1 == settings.AlertAfterRetries && attempt < settings.MaxRetries-1 {
+				u.alertRoundFailed(rd.round, attempt+1, err)
+			}
+			if u.stopping.Load() {
+				log.Warn().Err(err).Uint64("round", rd.round).Msg("Not retrying round, updater is stopping")
+				u.setInFlightRound(0)
+				endRoundSpans(batch, err)
+				return nil
 			}
 
-			if err != nil {
-				log.Error().
+			if attempt < settings.MaxRetries-1 {
+				backoffDuration := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+				log.Warn().
 					Err(err).
 					Uint64("round", rd.round).
-					Msg("Failed to process round after all retries")
-				return err
+					Int("attempt", attempt+1).
+					Dur("backoff", backoffDuration).
+					Msg("Retrying round processing after backoff")
+
+				select {
+				case <-ctx.Done():
+					endRoundSpans(batch, ctx.Err())
+					return ctx.Err()
+				case <-intakeCtx.Done():
+					// Stopping, the round is picked up again on the next start
+					u.setInFlightRound(0)
+					endRoundSpans(batch, intakeCtx.Err())
+					return intakeCtx.Err()
+				case <-time.After(backoffDuration):
+					continue
+				}
 			}
 		}
+
+		u.setInFlightRound(0)
+		endRoundSpans(batch, err)
+		log.Debug().
+			Err(err).
+			Uint64("from_round", batch[0].round).
+			Uint64("to_round", batch[len(batch)-1].round).
+			Str("source", rd.source).
+			Dur("queued_for", processingStart.Sub(rd.queuedAt)).
+			Dur("duration", time.Since(processingStart)).
+			Msg("Round processed")
+		u.debugRoundsProcessed(len(batch))
+
+		if err != nil {
+			log.Error().
+				Err(err).
+				Uint64("round", rd.round).
+				Msg("Failed to process round after all retries")
+			u.auditDecision(audit.DecisionAbandoned, rd.round, batch[len(batch)-1].round, rd.signature, err.Error(), "")
+			u.alertRoundFailed(rd.round, settings.MaxRetries, err)
+			return err
+		}
 	}
 }
 
@@ -297,104 +940,161 @@ func (u *Updater) processRound(
 	round uint64,
 	randomness []byte,
 	signature []byte,
+	source string,
 ) error {
-	u.latestOracleRoundMutex.Lock()
-	defer u.latestOracleRoundMutex.Unlock()
-	if round != u.genesisRound && u.latestOracleRound+1 != round {
-		log.Info().
-			Uint64("latestOracleRound", u.latestOracleRound).
-			Uint64("round", round).
-			Msg("Skipping irrelevant round")
-		return nil
-	}
-
-	roundTimestamp := uint64(u.drandInfo.GenesisTime) + uint64(round-1)*uint64(u.drandInfo.Period.Seconds())
+	return u.processRoundData(ctx, &roundData{round: round, randomness: randomness, signature: signature, source: source})
+}
 
-	log.Info().
-		Uint64("round", round).
-		Time("timestamp", time.Unix(int64(roundTimestamp), 0)).
-		Str("randomness", hex.EncodeToString(randomness)).
-		Str("signature", hex.EncodeToString(signature)).
-		Msg("Processing round")
+// processRoundData submits a round and waits for its transaction to be mined
+func (u *Updater) processRoundData(ctx context.Context, rd *roundData) error {
+	return u.chain.submit(ctx, rd)
+}
 
-	eip712Signature, err := u.signer.SignSetRandomness(round, roundTimestamp, [32]byte(randomness), signature)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to sign set randomness")
-		return err
+// signRound returns the EIP-712 signature of a round, the one signed ahead by
+// the catch-up pipeline unless the signer was rotated since
+func (u *Updater) signRound(ctx context.Context, rd *roundData, roundTimestamp uint64) ([]byte, error) {
+	if rd.presigned != nil && rd.presigned.signer == u.signer.Current().Address() {
+		return rd.presigned.signature, nil
 	}
+	signCtx, span := tracer.Start(ctx, "sign")
+	eip712Signature, err := u.signer.SignSetRandomness(signCtx, rd.round, roundTimestamp, [32]byte(rd.randomness), rd.signature)
+	endSpan(span, err)
+	return eip712Signature, err
+}
 
-	// Get current gas price suggestion from the network
-	gasPrice, err := u.rpcClient.SuggestGasPrice(ctx)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to get suggested gas price")
+// roundSet advances the oracle round after the updater set a round
+func (u *Updater) roundSet(round uint64, roundTimestamp uint64) {
+	u.invalidateCalls(methodLatestRound, methodEarliestRound)
+	u.latestOracleRoundMutex.Lock()
+	// The watcher may already have seen this or a later round
+	u.latestOracleRound = max(u.latestOracleRound, round)
+	u.latestOracleRoundMutex.Unlock()
+	u.metrics.SetOracleRound(float64(round))
+	u.metrics.ObserveSubmissionLatency(time.Since(time.Unix(int64(roundTimestamp), 0)))
+	u.updateRoundLag()
+	u.metrics.IncSetRandomnessSuccess()
+}
+
+// broadcast sends a signed transaction, retrying transport failures. Errors
+// returned by the node, such as an underpriced transaction or a used nonce,
+// are not retried.
+func (u *Updater) broadcast(ctx context.Context, tx *types.Transaction) error {
+	return u.broadcastRetrier.Do(ctx, func(ctx context.Context) error {
+		err := u.rpcClient.SendTransaction(ctx, tx)
+		if err == nil {
+			return nil
+		}
+		// A previous attempt reached the node despite failing
+		if strings.Contains(err.Error(), errAlreadyKnown) {
+			return nil
+		}
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) {
+			return retry.Permanent(err)
+		}
 		return err
+	})
+}
+
+// recordTransaction persists a mined transaction setting batchSize rounds from
+// round on, 1 unless batched, to the local state store. Failures are logged but
+// never fail the round, as the transaction is already on-chain.
+func (u *Updater) recordTransaction(round uint64, batchSize int, source string, tx *types.Transaction, receipt *types.Receipt) {
+	effectiveGasPrice := effectiveGasPrice(tx, receipt)
+	fee := transactionFee(tx, receipt)
+	result := "success"
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		result = "failure"
 	}
+	u.metrics.AddTransactionCost(result, receipt.GasUsed, fee, tx.Value())
 
-	tx, err := u.binding.SetRandomness(
-		&bind.TransactOpts{
-			From:     u.sender.Address(),
-			Signer:   u.sender.SignerFn(),
-			GasLimit: u.setRandomnessGasLimit,
-			GasPrice: gasPrice,
-		},
-		binding.IDrandOracleRandom{
-			Round:      round,
-			Timestamp:  roundTimestamp,
-			Randomness: [32]byte(randomness),
-			Signature:  signature,
-		},
-		eip712Signature,
-	)
-	if err != nil {
-		return err
+	record := store.Transaction{
+		Timestamp:         time.Now().UTC(),
+		ChainID:           u.chainID,
+		Round:             round,
+		Source:            source,
+		TxHash:            tx.Hash().Hex(),
+		From:              u.sender.Address().Hex(),
+		Nonce:             tx.Nonce(),
+		BlockNumber:       receipt.BlockNumber.Uint64(),
+		GasLimit:          tx.Gas(),
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: effectiveGasPrice.String(),
+		Fee:               fee.String(),
+		Value:             tx.Value().String(),
+		Status:            receipt.Status,
 	}
-	receipt, err := bind.WaitMined(ctx, u.rpcClient, tx)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to wait for transaction to be mined")
-		return err
+	if batchSize > 1 {
+		record.BatchSize = batchSize
 	}
-
-	if receipt.Status != types.ReceiptStatusSuccessful {
-		u.metrics.IncSetRandomnessFailure()
-		err = errors.New("set randomness transaction failed")
-		return err
-	} else {
-		log.Info().Uint64("round", round).Str("hash", tx.Hash().Hex()).Msg("Set randomness transaction successful")
-		u.latestOracleRound = round
-		u.metrics.SetOracleRound(float64(round))
-		u.metrics.IncSetRandomnessSuccess()
+	if err := u.store.AppendTransaction(record); err != nil {
+		log.Error().Err(err).Str("hash", tx.Hash().Hex()).Msg("Failed to record transaction")
 	}
-	return nil
 }
 
 // Add a getter method for safe access
 func (u *Updater) GetLatestOracleRound() uint64 {
-	u.latestOracleRoundMutex.Lock()
-	defer u.latestOracleRoundMutex.Unlock()
+	u.latestOracleRoundMutex.RLock()
+	defer u.latestOracleRoundMutex.RUnlock()
 	return u.latestOracleRound
 }
 
 func (u *Updater) monitorBalance(ctx context.Context) error {
-	ticker := time.NewTicker(balanceUpdateInterval)
-	defer ticker.Stop()
-
+	if u.options.Accounts != nil {
+		return u.monitorAccounts(ctx)
+	}
+	u.updateBalance(ctx)
 	for {
-		select {
-		case <-ctx.Done():
+		if err := u.idleWait(ctx, balanceUpdateInterval); err != nil {
 			return nil
-		case <-ticker.C:
-			balance, err := u.rpcClient.BalanceAt(ctx, u.sender.Address(), nil)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to get updater balance")
-				continue
-			}
+		}
+		u.updateBalance(ctx)
+	}
+}
 
-			u.metrics.SetUpdaterBalance(balance.String())
+func (u *Updater) updateBalance(ctx context.Context) {
+	balance, err := retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) (*big.Int, error) {
+		return u.rpcClient.BalanceAt(ctx, u.sender.Address(), nil)
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get updater balance")
+		return
+	}
+	u.balanceChecked(ctx, balance)
+}
 
-			log.Debug().
-				Str("address", u.sender.Address().Hex()).
-				Str("balance", balance.String()).
-				Msg("Updated balance metric")
-		}
+// balanceChecked records the sender balance, alerting and topping the sender
+// up when it runs low
+func (u *Updater) balanceChecked(ctx context.Context, balance *big.Int) {
+	u.senderBalanceMutex.Lock()
+	u.senderBalance = balance
+	u.senderBalanceMutex.Unlock()
+	u.metrics.SetUpdaterBalance(balance.String())
+	u.checkLowBalance(balance)
+	u.topUp(ctx, balance)
+
+	log.Debug().
+		Str("address", u.sender.Address().Hex()).
+		Str("balance", balance.String()).
+		Msg("Updated balance metric")
+}
+
+func effectiveGasPrice(tx *types.Transaction, receipt *types.Receipt) *big.Int {
+	if receipt.EffectiveGasPrice != nil {
+		return receipt.EffectiveGasPrice
 	}
+	return tx.GasPrice()
+}
+
+// transactionFee returns the gas fee paid by a mined transaction
+func transactionFee(tx *types.Transaction, receipt *types.Receipt) *big.Int {
+	return new(big.Int).Mul(effectiveGasPrice(tx, receipt), new(big.Int).SetUint64(receipt.GasUsed))
+}
+
+// updateRoundLag refreshes the round lag metric from the latest drand and oracle rounds
+func (u *Updater) updateRoundLag() {
+	u.latestDrandRoundMutex.RLock()
+	drandRound := u.latestDrandRound
+	u.latestDrandRoundMutex.RUnlock()
+	u.metrics.SetRoundLag(drandRound, u.GetLatestOracleRound())
 }
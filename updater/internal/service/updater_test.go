@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/chaintest"
+	"drand-oracle-updater/internal/chains"
+	"drand-oracle-updater/internal/store"
+	"drand-oracle-updater/sender"
+	"drand-oracle-updater/signer"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drand/drand/chain"
+	"github.com/drand/drand/client"
+	"github.com/drand/drand/crypto"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// testPeriod is the round period of the test drand network
+const testPeriod = 3 * time.Second
+
+// testGenesisAge is how long ago the test drand network started, so that the
+// rounds of the tests are in the past
+const testGenesisAge = 30 * 24 * time.Hour
+
+// testResult is a round of the test drand network
+type testResult struct {
+	round      uint64
+	randomness []byte
+	signature  []byte
+}
+
+func (r *testResult) Round() uint64      { return r.round }
+func (r *testResult) Randomness() []byte { return r.randomness }
+func (r *testResult) Signature() []byte  { return r.signature }
+
+// testDrand is a drand network serving deterministic rounds, the latest round
+// being set by the test
+type testDrand struct {
+	info   *chain.Info
+	latest atomic.Uint64
+	// failing is a round whose fetch fails, 0 for none
+	failing atomic.Uint64
+}
+
+// newTestDrand returns a drand network at round latest
+func newTestDrand(tb testing.TB, latest uint64) *testDrand {
+	tb.Helper()
+	scheme, err := crypto.SchemeFromName(crypto.SigsOnG1ID)
+	if err != nil {
+		tb.Fatalf("drand scheme: %v", err)
+	}
+	d := &testDrand{info: &chain.Info{
+		PublicKey:   scheme.KeyGroup.Point().Base(),
+		Period:      testPeriod,
+		Scheme:      scheme.Name,
+		GenesisTime: time.Now().Add(-testGenesisAge).Unix(),
+		GenesisSeed: []byte("drand-oracle-updater test"),
+	}}
+	d.latest.Store(latest)
+	return d
+}
+
+func (d *testDrand) result(round uint64) *testResult {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], round)
+	signature := sha256.Sum256(append([]byte("signature"), b[:]...))
+	randomness := sha256.Sum256(signature[:])
+	return &testResult{round: round, randomness: randomness[:], signature: signature[:]}
+}
+
+func (d *testDrand) Get(_ context.Context, round uint64) (client.Result, error) {
+	if round == 0 {
+		round = d.latest.Load()
+	}
+	if round == d.failing.Load() {
+		return nil, fmt.Errorf("round %d unavailable", round)
+	}
+	return d.result(round), nil
+}
+
+func (d *testDrand) Watch(ctx context.Context) <-chan client.Result {
+	results := make(chan client.Result)
+	go func() {
+		<-ctx.Done()
+		close(results)
+	}()
+	return results
+}
+
+func (d *testDrand) Info(context.Context) (*chain.Info, error) { return d.info, nil }
+
+func (d *testDrand) RoundAt(t time.Time) uint64 {
+	return chain.CurrentRound(t.Unix(), d.info.Period, d.info.GenesisTime)
+}
+
+func (d *testDrand) Close() error { return nil }
+
+// stubOracle is the runtime code of a contract stopping on every call, so
+// that every setRandomness transaction succeeds, without RandomnessUpdated
+// events
+var stubOracle = []byte{0x00}
+
+// creationCode returns the code deploying a contract running runtime, of at
+// most 255 bytes
+func creationCode(runtime []byte) []byte {
+	n := byte(len(runtime))
+	return append([]byte{
+		0x60, n, 0x60, 0x0c, 0x60, 0x00, 0x39, // CODECOPY(0, 12, n)
+		0x60, n, 0x60, 0x00, 0xf3, // RETURN(0, n)
+	}, runtime...)
+}
+
+// deployContract deploys a contract running runtime from the backend's account
+func deployContract(tb testing.TB, b *chaintest.Backend, runtime []byte) common.Address {
+	tb.Helper()
+	ctx := context.Background()
+	gasPrice, err := b.Client.SuggestGasPrice(ctx)
+	if err != nil {
+		tb.Fatalf("gas price: %v", err)
+	}
+	nonce, err := b.Client.PendingNonceAt(ctx, b.Address)
+	if err != nil {
+		tb.Fatalf("nonce: %v", err)
+	}
+	tx, err := types.SignNewTx(b.Key, types.LatestSignerForChainID(chaintest.ChainID), &types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Gas:      100_000,
+		Data:     creationCode(runtime),
+	})
+	if err != nil {
+		tb.Fatalf("signing deployment: %v", err)
+	}
+	if err := b.Client.SendTransaction(ctx, tx); err != nil {
+		tb.Fatalf("deploying contract: %v", err)
+	}
+	b.Commit()
+	receipt, err := b.Client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		tb.Fatalf("deployment receipt: %v", err)
+	}
+	return receipt.ContractAddress
+}
+
+// testPipelines numbers the updaters of the tests, whose metrics are told
+// apart by their pipeline label
+var testPipelines atomic.Uint64
+
+// newTestUpdater returns an updater submitting the rounds of drand to a stub
+// oracle deployed on the backend, from the backend's account
+func newTestUpdater(tb testing.TB, b *chaintest.Backend, drand *testDrand, options Options) *Updater {
+	tb.Helper()
+	oracle := deployContract(tb, b, stubOracle)
+	oracleBinding, err := binding.NewBinding(oracle, b.Client)
+	if err != nil {
+		tb.Fatalf("binding: %v", err)
+	}
+	st, err := store.Open(tb.TempDir())
+	if err != nil {
+		tb.Fatalf("store: %v", err)
+	}
+	signerKey, err := ethcrypto.GenerateKey()
+	if err != nil {
+		tb.Fatalf("signer key: %v", err)
+	}
+	chainID := chaintest.ChainID.Int64()
+	options.Pipeline = fmt.Sprintf("test-%d", testPipelines.Add(1))
+	u, err := NewUpdater(
+		drand,
+		b.Client,
+		500_000,
+		chainID,
+		oracle,
+		oracleBinding,
+		1,
+		3,
+		signer.NewSigner(chainID, oracle, signerKey, signer.EIP712{}),
+		sender.NewSender(chainID, sender.NewLocalKey(b.Key)),
+		st,
+		options,
+	)
+	if err != nil {
+		tb.Fatalf("updater: %v", err)
+	}
+	return u
+}
+
+func TestEVMSubmitter(t *testing.T) {
+	chaintest.Run(t, func(t *testing.T, b *chaintest.Backend) {
+		drand := newTestDrand(t, 2)
+		u := newTestUpdater(t, b, drand, Options{})
+		b.AutoCommit(t, 10*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		var submitter chains.ChainSubmitter = u.chain
+		if family := submitter.Family(); family != chains.FamilyEVM {
+			t.Errorf("family = %q, want %q", family, chains.FamilyEVM)
+		}
+		// Rounds are set through the updater's submission path, in order. The
+		// stub oracle does not answer latestRound, so only the updater's view
+		// of the oracle is checked.
+		for round := uint64(1); round <= 2; round++ {
+			result := drand.result(round)
+			if err := submitter.Submit(ctx, chains.NewBeacon(drand.info, round, result.randomness, result.signature)); err != nil {
+				t.Fatalf("submitting round %d: %v", round, err)
+			}
+			if latest := u.GetLatestOracleRound(); latest != round {
+				t.Errorf("oracle round = %d, want %d", latest, round)
+			}
+		}
+		if txs, err := u.store.Transactions(time.Time{}, time.Time{}); err != nil || len(txs) != 2 {
+			t.Errorf("recorded %d transactions, %v, want 2", len(txs), err)
+		}
+	})
+}
+
+func TestProcessRoundFees(t *testing.T) {
+	chaintest.Run(t, func(t *testing.T, b *chaintest.Backend) {
+		drand := newTestDrand(t, 1)
+		u := newTestUpdater(t, b, drand, Options{})
+		b.AutoCommit(t, 10*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		result := drand.result(1)
+		if err := u.processRound(ctx, 1, result.randomness, result.signature, "test"); err != nil {
+			t.Fatalf("processing round: %v", err)
+		}
+		if latest := u.GetLatestOracleRound(); latest != 1 {
+			t.Errorf("oracle round = %d, want 1", latest)
+		}
+
+		txs, err := u.store.Transactions(time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatalf("transactions: %v", err)
+		}
+		if len(txs) != 1 {
+			t.Fatalf("recorded %d transactions, want 1", len(txs))
+		}
+		recorded := txs[0]
+		receipt, err := b.Client.TransactionReceipt(ctx, common.HexToHash(recorded.TxHash))
+		if err != nil {
+			t.Fatalf("receipt: %v", err)
+		}
+		if recorded.GasUsed != receipt.GasUsed {
+			t.Errorf("recorded gas used = %d, want %d", recorded.GasUsed, receipt.GasUsed)
+		}
+		wantFee := new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+		if recorded.Fee != wantFee.String() {
+			t.Errorf("recorded fee = %s, want %s", recorded.Fee, wantFee)
+		}
+
+		if b.Family != chaintest.Arbitrum {
+			return
+		}
+		// The updater bids a gas price above the base fee, of which Arbitrum
+		// only charges the base fee, along with the L1 data gas
+		header, err := b.Client.HeaderByNumber(ctx, receipt.BlockNumber)
+		if err != nil {
+			t.Fatalf("block: %v", err)
+		}
+		if recorded.EffectiveGasPrice != header.BaseFee.String() {
+			t.Errorf("recorded gas price = %s, want the base fee %s", recorded.EffectiveGasPrice, header.BaseFee)
+		}
+		tx, _, err := b.Client.TransactionByHash(ctx, common.HexToHash(recorded.TxHash))
+		if err != nil {
+			t.Fatalf("transaction: %v", err)
+		}
+		if minGas := params.TxGas + chaintest.L1GasUsed(tx.Data()); recorded.GasUsed < minGas {
+			t.Errorf("recorded gas used = %d, want at least the L1 gas and intrinsic gas %d", recorded.GasUsed, minGas)
+		}
+	})
+}
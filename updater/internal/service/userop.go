@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/audit"
+	"drand-oracle-updater/internal/store"
+	"drand-oracle-updater/sender"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// submitUserOperation sets a round through a user operation of the smart
+// account. Its nonce key is derived from the oracle address, so that the
+// pipelines sharing the account do not wait on each other's nonces.
+func (u *Updater) submitUserOperation(
+	ctx context.Context,
+	round uint64,
+	roundTimestamp uint64,
+	source string,
+	random binding.IDrandOracleRandom,
+	eip712Signature []byte,
+	gasPrice *big.Int,
+) error {
+	data, form, err := u.setRandomnessCalldata(random, eip712Signature)
+	if err != nil {
+		return err
+	}
+
+	userOps := u.options.UserOperations
+	nonceKey := new(big.Int).SetBytes(u.oracleAddress.Bytes())
+	sentAt := time.Now()
+	op, hash, err := userOps.Send(ctx, nonceKey, u.oracleAddress, u.options.SubmissionFee, data, gasPrice)
+	if err != nil {
+		log.Error().Err(err).Uint64("round", round).Msg("Failed to send set randomness user operation")
+		return err
+	}
+	log.Info().Uint64("round", round).Str("user_op_hash", hash.Hex()).Msg("Sent set randomness user operation")
+	// The inner call's calldata, the account wrapping it in its own call
+	u.metrics.ObserveCalldataBytes(form, len(data))
+	receipt, err := userOps.WaitIncluded(ctx, hash)
+	if err != nil {
+		log.Error().Err(err).Str("user_op_hash", hash.Hex()).Msg("Failed to wait for user operation to be included")
+		return err
+	}
+
+	fee := receipt.ActualGasCost.ToInt()
+	gasUsed := receipt.ActualGasUsed.ToInt().Uint64()
+	u.recordUserOperation(round, source, op, receipt)
+	u.indexLogs(receipt.Logs)
+	u.metrics.ObserveGasUsage(0, gasUsed)
+	u.metrics.ObserveFeePaid(fee)
+
+	u.observeCanary(round, 1, fee, time.Since(sentAt), !receipt.Success)
+
+	if !receipt.Success {
+		// The account's call is replayed from the account, its gas limit
+		// being unknown
+		msg := ethereum.CallMsg{From: receipt.Sender, To: &u.oracleAddress, Value: u.options.SubmissionFee, Data: data}
+		var block *big.Int
+		if receipt.Receipt.BlockNumber != nil {
+			block = receipt.Receipt.BlockNumber.ToInt()
+		}
+		failure := u.classifyRevert(ctx, msg, block, round, 0)
+		u.metrics.IncSetRandomnessFailure(failure.reason)
+		u.recordLoss(round, fee)
+		log.Error().
+			Uint64("round", round).
+			Str("user_op_hash", hash.Hex()).
+			Str("reason", failure.reason).
+			Str("revert", failure.decoded).
+			Msg("Set randomness user operation failed")
+		u.auditDecision(audit.DecisionReverted, round, 0, random.Signature, failure.reason, receipt.Receipt.TransactionHash.Hex())
+		return fmt.Errorf("set randomness user operation failed: %s", failure.reason)
+	}
+	log.Info().
+		Uint64("round", round).
+		Str("user_op_hash", hash.Hex()).
+		Str("hash", receipt.Receipt.TransactionHash.Hex()).
+		Msg("Set randomness user operation successful")
+	u.auditDecision(audit.DecisionSubmitted, round, 0, random.Signature, "", receipt.Receipt.TransactionHash.Hex())
+	u.verifyReceipt(receipt.Logs, receipt.Receipt.TransactionHash.Hex(), random)
+	u.roundSet(round, roundTimestamp)
+	return nil
+}
+
+// recordUserOperation persists an included user operation to the local state
+// store, its fee being the gas cost charged to the paymaster or the account
+func (u *Updater) recordUserOperation(round uint64, source string, op *sender.UserOperation, receipt *sender.UserOperationReceipt) {
+	fee := receipt.ActualGasCost.ToInt()
+	gasUsed := receipt.ActualGasUsed.ToInt()
+	effectiveGasPrice := new(big.Int)
+	if gasUsed.Sign() > 0 {
+		effectiveGasPrice.Div(fee, gasUsed)
+	}
+	value := u.options.SubmissionFee
+	if value == nil {
+		value = new(big.Int)
+	}
+	status := types.ReceiptStatusFailed
+	result := "failure"
+	if receipt.Success {
+		status = types.ReceiptStatusSuccessful
+		result = "success"
+	}
+	u.metrics.AddTransactionCost(result, gasUsed.Uint64(), fee, value)
+
+	var blockNumber uint64
+	if receipt.Receipt.BlockNumber != nil {
+		blockNumber = receipt.Receipt.BlockNumber.ToInt().Uint64()
+	}
+	err := u.store.AppendTransaction(store.Transaction{
+		Timestamp:         time.Now().UTC(),
+		ChainID:           u.chainID,
+		Round:             round,
+		Source:            source,
+		TxHash:            receipt.Receipt.TransactionHash.Hex(),
+		From:              op.Sender.Hex(),
+		Nonce:             op.Sequence(),
+		BlockNumber:       blockNumber,
+		GasLimit:          op.GasLimit(),
+		GasUsed:           gasUsed.Uint64(),
+		EffectiveGasPrice: effectiveGasPrice.String(),
+		Fee:               fee.String(),
+		Value:             value.String(),
+		Status:            status,
+		UserOpHash:        receipt.UserOpHash.Hex(),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("user_op_hash", receipt.UserOpHash.Hex()).Msg("Failed to record user operation")
+	}
+}
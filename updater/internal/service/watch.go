@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"drand-oracle-updater/internal/notify"
+	"drand-oracle-updater/internal/retry"
+	"drand-oracle-updater/internal/store"
+	"drand-oracle-updater/internal/watcher"
+	"encoding/hex"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// newWatcher is a helper for NewUpdater, whose binding parameter shadows the
+// package. It subscribes through the events client when one is configured.
+func newWatcher(rpcClient *ethclient.Client, oracle *binding.Binding, oracleAddress common.Address, options Options) (*watcher.Watcher, error) {
+	var wsBinding *binding.Binding
+	if options.EventsClient != nil {
+		var err error
+		wsBinding, err = binding.NewBinding(oracleAddress, options.EventsClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return watcher.New(rpcClient, oracle, wsBinding, options.EventsPollInterval), nil
+}
+
+// watchOracleRounds follows the oracle's RandomnessUpdated events from the
+// current block, so that rounds written by other updaters are learned
+// immediately and not submitted again
+func (u *Updater) watchOracleRounds(ctx context.Context) error {
+	from, err := retry.Value(ctx, u.rpcRetrier, u.rpcClient.BlockNumber)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get block number to watch oracle rounds from")
+		return err
+	}
+	return u.watcher.Run(ctx, from, u.handleRandomnessUpdated)
+}
+
+// handleRandomnessUpdated advances the oracle round, indexes and verifies the
+// round of a RandomnessUpdated event
+func (u *Updater) handleRandomnessUpdated(event *binding.BindingRandomnessUpdated) {
+	u.indexEvent(event, event.Raw)
+	u.invalidateCalls(methodLatestRound, methodEarliestRound)
+	go u.verifyOracleRound(event)
+
+	u.latestOracleRoundMutex.Lock()
+	advanced := event.Round > u.latestOracleRound
+	if advanced {
+		u.latestOracleRound = event.Round
+	}
+	u.latestOracleRoundMutex.Unlock()
+	if !advanced {
+		return
+	}
+
+	log.Info().
+		Uint64("round", event.Round).
+		Str("hash", event.Raw.TxHash.Hex()).
+		Msg("Oracle round advanced by RandomnessUpdated event")
+	u.metrics.SetOracleRound(float64(event.Round))
+	u.updateRoundLag()
+	// Queued rounds up to the new oracle round are now skipped, and the next
+	// one became submittable
+	u.scheduler.Wake()
+}
+
+// indexRound adds the RandomnessUpdated event of a mined transaction to the
+// local rounds index. Failures are logged only, a reindex rebuilds the index.
+func (u *Updater) indexRound(receipt *types.Receipt) {
+	u.indexLogs(receipt.Logs)
+}
+
+// indexLogs adds the RandomnessUpdated events among logs to the rounds index
+func (u *Updater) indexLogs(logs []*types.Log) {
+	for _, l := range logs {
+		if l.Address != u.oracleAddress {
+			continue
+		}
+		event, err := u.binding.ParseRandomnessUpdated(*l)
+		if err != nil {
+			continue
+		}
+		u.indexEvent(event, *l)
+	}
+}
+
+// blockTimeTimeout bounds the retries of the header fetch of a round's block
+const blockTimeTimeout = 1 * time.Minute
+
+// blockTime returns the timestamp of a block, retrying transient RPC failures
+func (u *Updater) blockTime(number uint64) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), blockTimeTimeout)
+	defer cancel()
+	header, err := retry.Value(ctx, u.rpcRetrier, func(ctx context.Context) (*types.Header, error) {
+		return u.rpcClient.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(header.Time), 0).UTC(), nil
+}
+
+// indexEvent appends a round to the rounds index. Rounds are seen both from the
+// receipts of the updater's own transactions and from the watcher, so rounds
+// already indexed are skipped. A round whose block header cannot be fetched is
+// not indexed rather than indexed with a wrong time: it is indexed when seen
+// again, or by a reindex.
+func (u *Updater) indexEvent(event *binding.BindingRandomnessUpdated, l types.Log) {
+	if u.roundIndexed(event.Round) {
+		return
+	}
+	blockTime, err := u.blockTime(l.BlockNumber)
+	if err != nil {
+		log.Error().Err(err).Uint64("round", event.Round).Uint64("block", l.BlockNumber).Msg("Failed to get block header, round not indexed")
+		return
+	}
+
+	u.indexedRoundMutex.Lock()
+	defer u.indexedRoundMutex.Unlock()
+	// The round may have been indexed while the header was fetched
+	if event.Round <= u.indexedRound {
+		return
+	}
+	err = u.store.AppendRound(store.Round{
+		Timestamp:   blockTime,
+		Round:       event.Round,
+		Randomness:  hex.EncodeToString(event.Randomness[:]),
+		Signature:   hex.EncodeToString(event.Signature),
+		BlockNumber: l.BlockNumber,
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	})
+	if err != nil {
+		log.Error().Err(err).Uint64("round", event.Round).Msg("Failed to index round")
+		return
+	}
+	u.indexedRound = event.Round
+	u.notifyConsumers(event, l, blockTime)
+}
+
+// roundIndexed reports whether a round is at or below the latest indexed round
+func (u *Updater) roundIndexed(round uint64) bool {
+	u.indexedRoundMutex.Lock()
+	defer u.indexedRoundMutex.Unlock()
+	return round <= u.indexedRound
+}
+
+// notifyConsumers posts a round set on the oracle, by the updater or another
+// one, to the consumer webhooks
+func (u *Updater) notifyConsumers(event *binding.BindingRandomnessUpdated, l types.Log, blockTime time.Time) {
+	if u.options.Consumers == nil {
+		return
+	}
+	u.options.Consumers.Notify(notify.Round{
+		Pipeline:    u.options.Pipeline,
+		ChainID:     u.chainID,
+		Oracle:      u.oracleAddress.Hex(),
+		Round:       event.Round,
+		Randomness:  hex.EncodeToString(event.Randomness[:]),
+		Signature:   hex.EncodeToString(event.Signature),
+		TxHash:      l.TxHash.Hex(),
+		BlockNumber: l.BlockNumber,
+		BlockTime:   blockTime,
+	})
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const annotationsCollection = "annotations"
+
+// Annotation is an operational note attached to a range of rounds, a period
+// of time, or both, such as "relay outage" or "gas spike incident". Records
+// carry no timestamp nor round field, so compaction keeps them.
+type Annotation struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// FromRound and ToRound bound the annotated rounds, inclusive. A zero
+	// FromRound annotates no round, a zero ToRound leaves the range open.
+	FromRound uint64 `json:"from_round,omitempty"`
+	ToRound   uint64 `json:"to_round,omitempty"`
+	// From and To bound the annotated period, [From, To). A nil From annotates
+	// no period, a nil To leaves the period open.
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+	Note   string     `json:"note"`
+	Author string     `json:"author,omitempty"`
+	// Deleted marks the deletion of the annotation with the same ID
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// CoversRound reports whether the annotation applies to a round
+func (a Annotation) CoversRound(round uint64) bool {
+	return a.FromRound > 0 && round >= a.FromRound && (a.ToRound == 0 || round <= a.ToRound)
+}
+
+// CoversTime reports whether the annotation applies to a point in time
+func (a Annotation) CoversTime(t time.Time) bool {
+	return a.From != nil && !t.Before(*a.From) && (a.To == nil || t.Before(*a.To))
+}
+
+// Overlaps reports whether the annotated period overlaps [from, to), a zero
+// bound leaving that side open
+func (a Annotation) Overlaps(from, to time.Time) bool {
+	if a.From == nil {
+		return false
+	}
+	if !to.IsZero() && !a.From.Before(to) {
+		return false
+	}
+	return a.To == nil || from.IsZero() || a.To.After(from)
+}
+
+// Covering returns the annotations covering a round or a point in time, such
+// as the round of a transaction or its timestamp
+func Covering(annotations []Annotation, round uint64, t time.Time) []Annotation {
+	var covering []Annotation
+	for _, a := range annotations {
+		if a.CoversRound(round) || a.CoversTime(t) {
+			covering = append(covering, a)
+		}
+	}
+	return covering
+}
+
+// AppendAnnotation records an annotation
+func (s *Store) AppendAnnotation(annotation Annotation) error {
+	return s.appendRecord(annotationsCollection, annotation)
+}
+
+// DeleteAnnotation records the deletion of an annotation
+func (s *Store) DeleteAnnotation(id string) error {
+	return s.appendRecord(annotationsCollection, Annotation{ID: id, CreatedAt: time.Now().UTC(), Deleted: true})
+}
+
+// Annotations returns the annotations that were not deleted, in creation order
+func (s *Store) Annotations() ([]Annotation, error) {
+	var annotations []Annotation
+	deleted := make(map[string]bool)
+	err := s.readRecords(annotationsCollection, func(data []byte) error {
+		var annotation Annotation
+		if err := json.Unmarshal(data, &annotation); err != nil {
+			return err
+		}
+		if annotation.Deleted {
+			deleted[annotation.ID] = true
+			return nil
+		}
+		annotations = append(annotations, annotation)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	kept := annotations[:0]
+	for _, annotation := range annotations {
+		if !deleted[annotation.ID] {
+			kept = append(kept, annotation)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoints are small JSON documents tracking the progress of long running
+// jobs. They are kept next to the collections but are not collections, so
+// compaction leaves them alone.
+
+func (s *Store) checkpointPath(name string) string {
+	return filepath.Join(s.dir, name+".checkpoint.json")
+}
+
+// SaveCheckpoint atomically replaces a checkpoint
+func (s *Store) SaveCheckpoint(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.checkpointPath(name) + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.checkpointPath(name))
+}
+
+// LoadCheckpoint reads a checkpoint into v and reports whether it exists
+func (s *Store) LoadCheckpoint(name string, v any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.checkpointPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// DeleteCheckpoint removes a checkpoint
+func (s *Store) DeleteCheckpoint(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.checkpointPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Retention defines which records are kept when compacting the store
+type Retention struct {
+	// MaxAge drops records older than this duration, 0 keeps all
+	MaxAge time.Duration
+	// MaxRounds drops records more than this many rounds behind the latest round, 0 keeps all
+	MaxRounds uint64
+	// RoundsMaxAge drops indexed rounds set in blocks older than this
+	// duration, 0 keeps all
+	RoundsMaxAge time.Duration
+	// RoundsMaxRounds drops indexed rounds more than this many rounds behind
+	// the latest round, 0 keeps all
+	RoundsMaxRounds uint64
+}
+
+// CompactionResult describes the compaction of a single collection
+type CompactionResult struct {
+	Collection  string `json:"collection"`
+	Kept        int    `json:"kept"`
+	Removed     int    `json:"removed"`
+	BytesBefore int64  `json:"bytes_before"`
+	BytesAfter  int64  `json:"bytes_after"`
+}
+
+// prunableCollections are the collections MaxAge and MaxRounds apply to. The
+// rounds index has its own retention, as the round lookups read it, and
+// annotations and releases are lifetime records, never pruned.
+var prunableCollections = []string{transactionsCollection, eventsCollection}
+
+// recordKey holds the fields every record is expected to carry for retention
+type recordKey struct {
+	Timestamp time.Time `json:"timestamp"`
+	Round     uint64    `json:"round"`
+}
+
+// Collections returns the names of all collections in the store
+func (s *Store) Collections() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	collections := make([]string, len(matches))
+	for i, match := range matches {
+		collections[i] = strings.TrimSuffix(filepath.Base(match), ".jsonl")
+	}
+	return collections, nil
+}
+
+// Sizes returns the on-disk size in bytes of every collection
+func (s *Store) Sizes() (map[string]int64, error) {
+	collections, err := s.Collections()
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(collections))
+	for _, collection := range collections {
+		info, err := os.Stat(s.path(collection))
+		if err != nil {
+			return nil, err
+		}
+		sizes[collection] = info.Size()
+	}
+	return sizes, nil
+}
+
+// Compact rewrites the prunable collections and the rounds index, dropping the
+// records outside of the retention policy. Collections are replaced atomically so a crash never loses
+// kept records.
+func (s *Store) Compact(retention Retention, latestRound uint64, now time.Time) ([]CompactionResult, error) {
+	collections, err := s.Collections()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []CompactionResult
+	for _, collection := range collections {
+		var keep func(key recordKey) bool
+		switch {
+		case slices.Contains(prunableCollections, collection):
+			keep = retained(retention.MaxAge, retention.MaxRounds, latestRound, now)
+		case collection == roundsCollection && (retention.RoundsMaxAge > 0 || retention.RoundsMaxRounds > 0):
+			// The highest indexed round is kept whatever its age, so that a
+			// resumed reindex does not index the rounds again
+			_, highest := s.IndexedRounds()
+			retainedRound := retained(retention.RoundsMaxAge, retention.RoundsMaxRounds, latestRound, now)
+			keep = func(key recordKey) bool {
+				return key.Round >= highest || retainedRound(key)
+			}
+		default:
+			continue
+		}
+		result, err := s.compactCollection(collection, keep)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+
+	// Remove temporary files left behind by interrupted compactions
+	leftovers, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl.tmp"))
+	if err != nil {
+		return results, err
+	}
+	for _, leftover := range leftovers {
+		if err := os.Remove(leftover); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// retained returns whether a record is within maxAge of now and maxRounds of
+// latestRound, a zero bound keeping every record
+func retained(maxAge time.Duration, maxRounds, latestRound uint64, now time.Time) func(key recordKey) bool {
+	return func(key recordKey) bool {
+		if maxAge > 0 && !key.Timestamp.IsZero() && now.Sub(key.Timestamp) > maxAge {
+			return false
+		}
+		if maxRounds > 0 && key.Round > 0 && key.Round+maxRounds <= latestRound {
+			return false
+		}
+		return true
+	}
+}
+
+func (s *Store) compactCollection(collection string, keep func(key recordKey) bool) (CompactionResult, error) {
+	result := CompactionResult{Collection: collection}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(collection)
+	in, err := os.Open(path)
+	if err != nil {
+		return result, err
+	}
+	defer in.Close()
+	if info, err := in.Stat(); err == nil {
+		result.BytesBefore = info.Size()
+	}
+
+	tmpPath := path + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode)
+	if err != nil {
+		return result, err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var key recordKey
+		// Keep records that cannot be interpreted rather than silently losing data
+		if err := json.Unmarshal(line, &key); err == nil && !keep(key) {
+			result.Removed++
+			continue
+		}
+		result.Kept++
+		if _, err := w.Write(line); err != nil {
+			return result, err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return result, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	if err := w.Flush(); err != nil {
+		return result, err
+	}
+	if err := out.Sync(); err != nil {
+		return result, err
+	}
+	if info, err := out.Stat(); err == nil {
+		result.BytesAfter = info.Size()
+	}
+
+	if result.Removed == 0 {
+		return result, os.Remove(tmpPath)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return result, err
+	}
+	if collection == roundsCollection {
+		// The kept records moved, the index is rebuilt before the store is
+		// unlocked
+		return result, s.indexRounds()
+	}
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+// openTestStore returns a store holding rounds 1 to n, set one a minute from
+// start, along with a transaction for every round
+func openTestStore(t *testing.T, start time.Time, n uint64) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	for round := uint64(1); round <= n; round++ {
+		timestamp := start.Add(time.Duration(round) * time.Minute)
+		if err := s.AppendRound(Round{Timestamp: timestamp, Round: round, Randomness: "aa", Signature: "bb"}); err != nil {
+			t.Fatalf("appending round %d: %v", round, err)
+		}
+		if err := s.AppendTransaction(Transaction{Timestamp: timestamp, Round: round}); err != nil {
+			t.Fatalf("appending transaction %d: %v", round, err)
+		}
+	}
+	return s
+}
+
+func TestCompactRounds(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		retention Retention
+		// kept is the lowest round kept
+		kept uint64
+	}{
+		{"max age", Retention{RoundsMaxAge: 30 * time.Minute}, 70},
+		{"max rounds", Retention{RoundsMaxRounds: 10}, 91},
+		{"both", Retention{RoundsMaxAge: 30 * time.Minute, RoundsMaxRounds: 10}, 91},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestStore(t, start, 100)
+			before, err := s.Sizes()
+			if err != nil {
+				t.Fatalf("sizes: %v", err)
+			}
+
+			now := start.Add(100 * time.Minute)
+			if _, err := s.Compact(tt.retention, 100, now); err != nil {
+				t.Fatalf("compacting: %v", err)
+			}
+
+			after, err := s.Sizes()
+			if err != nil {
+				t.Fatalf("sizes: %v", err)
+			}
+			if after[roundsCollection] >= before[roundsCollection] {
+				t.Errorf("rounds collection of %d bytes, was %d", after[roundsCollection], before[roundsCollection])
+			}
+			// The transactions follow their own retention
+			if after[transactionsCollection] != before[transactionsCollection] {
+				t.Errorf("transactions collection of %d bytes, was %d", after[transactionsCollection], before[transactionsCollection])
+			}
+			if count, highest := s.IndexedRounds(); count != int(100-tt.kept+1) || highest != 100 {
+				t.Errorf("index holds %d rounds up to %d, want %d up to 100", count, highest, 100-tt.kept+1)
+			}
+			if _, ok, err := s.IndexedRound(tt.kept - 1); ok || err != nil {
+				t.Errorf("round %d indexed after compaction: %v", tt.kept-1, err)
+			}
+			// The offsets of the kept rounds follow the rewritten collection
+			for _, number := range []uint64{tt.kept, 100} {
+				round, ok, err := s.IndexedRound(number)
+				if err != nil || !ok || round.Round != number {
+					t.Errorf("round %d = %d, %v, %v, want indexed", number, round.Round, ok, err)
+				}
+			}
+			round, ok, err := s.LatestRoundAt(start.Add(95 * time.Minute))
+			if err != nil || !ok || round.Round != 95 {
+				t.Errorf("round at minute 95 = %d, %v, %v, want 95", round.Round, ok, err)
+			}
+			if _, ok, _ := s.LatestRoundAt(start.Add(time.Duration(tt.kept-1) * time.Minute)); ok {
+				t.Errorf("round found before round %d, the first kept", tt.kept)
+			}
+
+			// The index loaded from the compacted collection is the same
+			reopened, err := Open(s.dir)
+			if err != nil {
+				t.Fatalf("reopening store: %v", err)
+			}
+			if count, _ := reopened.IndexedRounds(); count != int(100-tt.kept+1) {
+				t.Errorf("reopened index holds %d rounds, want %d", count, 100-tt.kept+1)
+			}
+		})
+	}
+}
+
+func TestCompactKeepsHighestRound(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := openTestStore(t, start, 10)
+	if _, err := s.Compact(Retention{RoundsMaxAge: time.Hour}, 10, start.Add(24*time.Hour)); err != nil {
+		t.Fatalf("compacting: %v", err)
+	}
+	if count, highest := s.IndexedRounds(); count != 1 || highest != 10 {
+		t.Errorf("index holds %d rounds up to %d, want round 10 only", count, highest)
+	}
+}
+
+func TestCompactKeepsRoundsByDefault(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := openTestStore(t, start, 10)
+	results, err := s.Compact(Retention{MaxAge: time.Minute}, 10, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("compacting: %v", err)
+	}
+	for _, result := range results {
+		if result.Collection == roundsCollection {
+			t.Errorf("rounds collection compacted without a rounds retention: %+v", result)
+		}
+	}
+	if count, _ := s.IndexedRounds(); count != 10 {
+		t.Errorf("index holds %d rounds, want 10", count)
+	}
+}
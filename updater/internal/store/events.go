@@ -0,0 +1,70 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const eventsCollection = "events"
+
+// Event is a notable change of the updater or of the oracle it observed, such
+// as a restart, a leadership change, a tripped circuit breaker, an alert or a
+// pause of the oracle, kept for the timeline
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Source is "chain" for changes of the oracle, "updater" otherwise
+	Source  string            `json:"source"`
+	Kind    string            `json:"kind"`
+	Round   uint64            `json:"round,omitempty"`
+	Summary string            `json:"summary"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// AppendEvent records an event
+func (s *Store) AppendEvent(event Event) error {
+	return s.appendRecord(eventsCollection, event)
+}
+
+// Events returns the recorded events with a timestamp in [from, to), in the
+// order they were recorded. A zero from or to leaves that side of the range
+// open.
+func (s *Store) Events(from, to time.Time) ([]Event, error) {
+	var events []Event
+	err := s.readRecords(eventsCollection, func(data []byte) error {
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return err
+		}
+		if !from.IsZero() && event.Timestamp.Before(from) {
+			return nil
+		}
+		if !to.IsZero() && !event.Timestamp.Before(to) {
+			return nil
+		}
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// RoundsBetween returns the indexed rounds set in a block with a timestamp in
+// [from, to), in the order they were indexed. A zero from or to leaves that
+// side of the range open.
+func (s *Store) RoundsBetween(from, to time.Time) ([]Round, error) {
+	var rounds []Round
+	err := s.readRecords(roundsCollection, func(data []byte) error {
+		var round Round
+		if err := json.Unmarshal(data, &round); err != nil {
+			return err
+		}
+		if !from.IsZero() && round.Timestamp.Before(from) {
+			return nil
+		}
+		if !to.IsZero() && !round.Timestamp.Before(to) {
+			return nil
+		}
+		rounds = append(rounds, round)
+		return nil
+	})
+	return rounds, err
+}
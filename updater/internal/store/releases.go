@@ -0,0 +1,43 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const releasesCollection = "releases"
+
+// Release is a day of rounds published to IPFS. Records carry no timestamp
+// nor round field, so compaction keeps them.
+type Release struct {
+	// Date is the UTC day of the blocks the rounds were set in
+	Date        string    `json:"date"`
+	CID         string    `json:"cid"`
+	FirstRound  uint64    `json:"first_round"`
+	LastRound   uint64    `json:"last_round"`
+	Beacons     int       `json:"beacons"`
+	Publisher   string    `json:"publisher"`
+	PublishedAt time.Time `json:"published_at"`
+	// AnchorTxHash is the transaction recording the CID on-chain, empty when
+	// it is not anchored
+	AnchorTxHash string `json:"anchor_tx_hash,omitempty"`
+}
+
+// AppendRelease records a published release
+func (s *Store) AppendRelease(release Release) error {
+	return s.appendRecord(releasesCollection, release)
+}
+
+// Releases returns the published releases in the order they were published
+func (s *Store) Releases() ([]Release, error) {
+	var releases []Release
+	err := s.readRecords(releasesCollection, func(data []byte) error {
+		var release Release
+		if err := json.Unmarshal(data, &release); err != nil {
+			return err
+		}
+		releases = append(releases, release)
+		return nil
+	})
+	return releases, err
+}
@@ -0,0 +1,246 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+const roundsCollection = "rounds"
+
+// Round is a RandomnessUpdated event emitted by the Drand Oracle contract
+type Round struct {
+	// Timestamp is the time of the block the event was emitted in
+	Timestamp   time.Time `json:"timestamp"`
+	Round       uint64    `json:"round"`
+	Randomness  string    `json:"randomness"`
+	Signature   string    `json:"signature"`
+	BlockNumber uint64    `json:"block_number"`
+	TxHash      string    `json:"tx_hash"`
+	LogIndex    uint      `json:"log_index"`
+}
+
+// AppendRound records an indexed round
+func (s *Store) AppendRound(round Round) error {
+	return s.appendRecord(roundsCollection, round)
+}
+
+// Rounds returns the indexed rounds in the order they were indexed
+func (s *Store) Rounds() ([]Round, error) {
+	var rounds []Round
+	err := s.readRecords(roundsCollection, func(data []byte) error {
+		var round Round
+		if err := json.Unmarshal(data, &round); err != nil {
+			return err
+		}
+		rounds = append(rounds, round)
+		return nil
+	})
+	return rounds, err
+}
+
+// LatestRoundAt returns the highest round set in a block with a timestamp at or
+// before t, false if no indexed round was set by then
+func (s *Store) LatestRoundAt(t time.Time) (Round, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset, ok := s.rounds.latestAt(t)
+	if !ok {
+		return Round{}, false, nil
+	}
+	round, err := s.readRoundAt(offset)
+	return round, err == nil, err
+}
+
+// IndexedRound returns the indexed event of a round, false if it is not indexed
+func (s *Store) IndexedRound(number uint64) (Round, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset, ok := s.rounds.lookup(number)
+	if !ok {
+		return Round{}, false, nil
+	}
+	round, err := s.readRoundAt(offset)
+	return round, err == nil, err
+}
+
+// IndexedRounds returns the number of distinct indexed rounds and the highest
+// one, 0 when none is indexed
+func (s *Store) IndexedRounds() (int, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.rounds.byRound) == 0 {
+		return 0, 0
+	}
+	return len(s.rounds.byRound), s.rounds.byRound[len(s.rounds.byRound)-1].round
+}
+
+// ScanRounds calls fn for every indexed round in the order they were indexed,
+// with the position of the record in the collection. Records that cannot be
+// decoded are passed with their decoding error rather than failing the scan.
+func (s *Store) ScanRounds(fn func(record int, round Round, err error) error) error {
+	record := 0
+	return s.readRecords(roundsCollection, func(data []byte) error {
+		var round Round
+		err := json.Unmarshal(data, &round)
+		record++
+		return fn(record, round, err)
+	})
+}
+
+// ResetRounds wipes the rounds index
+func (s *Store) ResetRounds() error {
+	if err := s.removeCollection(roundsCollection); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rounds = roundIndex{}
+	return nil
+}
+
+func (s *Store) removeCollection(collection string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(collection)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// roundEntry locates the record of a round in the rounds collection
+type roundEntry struct {
+	round uint64
+	// timestamp is the block timestamp, in Unix nanoseconds
+	timestamp int64
+	offset    int64
+}
+
+// roundIndex is an in-memory index of the rounds collection, loaded when the
+// store is opened and extended on every append, so that the round lookups
+// served to API clients are binary searches rather than scans of the whole
+// collection. It is rebuilt when a compaction prunes the collection, so the
+// rounds retention bounds it along with the collection.
+type roundIndex struct {
+	// byRound holds the first record of every round, sorted by round
+	byRound []roundEntry
+	// byTime holds every record sorted by timestamp, in insertion order among
+	// equal timestamps
+	byTime []roundEntry
+	// highest holds, for every position of byTime, the position of the
+	// highest round up to it
+	highest []int
+}
+
+// add indexes the record of a round at offset. Rounds are usually indexed in
+// timestamp order, which appends to the index.
+func (x *roundIndex) add(round Round, offset int64) {
+	entry := roundEntry{round: round.Round, timestamp: round.Timestamp.UnixNano(), offset: offset}
+
+	i, found := slices.BinarySearchFunc(x.byRound, entry.round, func(e roundEntry, round uint64) int {
+		return cmp.Compare(e.round, round)
+	})
+	if !found {
+		x.byRound = slices.Insert(x.byRound, i, entry)
+	}
+
+	i = sort.Search(len(x.byTime), func(i int) bool { return x.byTime[i].timestamp > entry.timestamp })
+	x.byTime = slices.Insert(x.byTime, i, entry)
+	x.highest = slices.Insert(x.highest, i, i)
+	for ; i < len(x.byTime); i++ {
+		x.highest[i] = i
+		if i > 0 && x.byTime[x.highest[i-1]].round >= x.byTime[i].round {
+			x.highest[i] = x.highest[i-1]
+		}
+	}
+}
+
+// lookup returns the offset of the first record of a round
+func (x *roundIndex) lookup(round uint64) (int64, bool) {
+	i, found := slices.BinarySearchFunc(x.byRound, round, func(e roundEntry, round uint64) int {
+		return cmp.Compare(e.round, round)
+	})
+	if !found {
+		return 0, false
+	}
+	return x.byRound[i].offset, true
+}
+
+// latestAt returns the offset of the record of the highest round with a
+// timestamp at or before t
+func (x *roundIndex) latestAt(t time.Time) (int64, bool) {
+	nanos := t.UnixNano()
+	i := sort.Search(len(x.byTime), func(i int) bool { return x.byTime[i].timestamp > nanos })
+	if i == 0 {
+		return 0, false
+	}
+	return x.byTime[x.highest[i-1]].offset, true
+}
+
+// loadRoundIndex builds the index of the rounds collection. Records that
+// cannot be decoded are left out, as ScanRounds reports them.
+func (s *Store) loadRoundIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.indexRounds()
+}
+
+// indexRounds builds the index of the rounds collection, the store being
+// locked
+func (s *Store) indexRounds() error {
+	s.rounds = roundIndex{}
+
+	f, err := os.Open(s.path(roundsCollection))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var round Round
+			if json.Unmarshal(line, &round) == nil {
+				s.rounds.add(round, offset)
+			}
+		}
+		offset += int64(len(line))
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readRoundAt reads the record of a round at offset, the store being locked
+func (s *Store) readRoundAt(offset int64) (Round, error) {
+	f, err := os.Open(s.path(roundsCollection))
+	if err != nil {
+		return Round{}, err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(io.NewSectionReader(f, offset, math.MaxInt64-offset)).ReadBytes('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+		return Round{}, err
+	}
+	var round Round
+	if err := json.Unmarshal(line, &round); err != nil {
+		return Round{}, err
+	}
+	return round, nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const fileMode = 0o644
+
+// Store is a small append-only local state store. Each collection is kept in
+// its own JSON lines file under the state directory.
+type Store struct {
+	dir  string
+	mu   sync.Mutex
+	sink Sink
+	// rounds locates the records of the rounds collection
+	rounds roundIndex
+}
+
+// Sink receives a copy of every record appended to the store, such as an
+// archive in another format. The record is already stored, so a sink handles
+// its own failures.
+type Sink interface {
+	Write(collection string, record any)
+}
+
+// Sinks copies every record to several sinks, in order
+type Sinks []Sink
+
+// Write copies a record to every sink
+func (s Sinks) Write(collection string, record any) {
+	for _, sink := range s {
+		sink.Write(collection, record)
+	}
+}
+
+// Open creates the state directory if needed and returns a store rooted at it
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, errors.New("state directory is not set")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir}
+	if err := s.loadRoundIndex(); err != nil {
+		return nil, fmt.Errorf("error loading rounds index: %w", err)
+	}
+	return s, nil
+}
+
+// SetSink sets the sink records are copied to once appended, nil disables it
+func (s *Store) SetSink(sink Sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sink = sink
+}
+
+// Dir returns the state directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) path(collection string) string {
+	return filepath.Join(s.dir, collection+".jsonl")
+}
+
+// appendRecord appends a single JSON encoded record to a collection
+func (s *Store) appendRecord(collection string, record any) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path(collection), os.O_CREATE|os.O_APPEND|os.O_WRONLY, fileMode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if round, ok := record.(Round); ok && collection == roundsCollection {
+		s.rounds.add(round, offset)
+	}
+	if s.sink != nil {
+		s.sink.Write(collection, record)
+	}
+	return nil
+}
+
+// readRecords calls fn for every record of a collection, in insertion order.
+// A missing collection is treated as empty.
+func (s *Store) readRecords(collection string, fn func(data []byte) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path(collection))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
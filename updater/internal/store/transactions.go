@@ -0,0 +1,58 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const transactionsCollection = "transactions"
+
+// Transaction is a SetRandomness transaction sent by the updater's sender
+type Transaction struct {
+	Timestamp         time.Time `json:"timestamp"`
+	ChainID           int64     `json:"chain_id"`
+	Round             uint64    `json:"round"`
+	Source            string    `json:"source,omitempty"`
+	TxHash            string    `json:"tx_hash"`
+	From              string    `json:"from"`
+	Nonce             uint64    `json:"nonce"`
+	BlockNumber       uint64    `json:"block_number"`
+	GasLimit          uint64    `json:"gas_limit"`
+	GasUsed           uint64    `json:"gas_used"`
+	EffectiveGasPrice string    `json:"effective_gas_price_wei"`
+	Fee               string    `json:"fee_wei"`
+	Value             string    `json:"value_wei,omitempty"`
+	Status            uint64    `json:"status"`
+	// UserOpHash is the hash of the ERC-4337 user operation that set the round,
+	// TxHash being the hash of the bundle transaction including it
+	UserOpHash string `json:"user_op_hash,omitempty"`
+	// BatchSize is the number of rounds set by a setRandomnessBatch
+	// transaction, from Round on, 0 for a setRandomness transaction
+	BatchSize int `json:"batch_size,omitempty"`
+}
+
+// AppendTransaction records a mined transaction
+func (s *Store) AppendTransaction(tx Transaction) error {
+	return s.appendRecord(transactionsCollection, tx)
+}
+
+// Transactions returns the recorded transactions with a timestamp in [from, to).
+// A zero from or to leaves that side of the range open.
+func (s *Store) Transactions(from, to time.Time) ([]Transaction, error) {
+	var txs []Transaction
+	err := s.readRecords(transactionsCollection, func(data []byte) error {
+		var tx Transaction
+		if err := json.Unmarshal(data, &tx); err != nil {
+			return err
+		}
+		if !from.IsZero() && tx.Timestamp.Before(from) {
+			return nil
+		}
+		if !to.IsZero() && !tx.Timestamp.Before(to) {
+			return nil
+		}
+		txs = append(txs, tx)
+		return nil
+	})
+	return txs, err
+}
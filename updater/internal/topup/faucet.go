@@ -0,0 +1,91 @@
+package topup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// faucetTimeout bounds a request to the faucet API
+const faucetTimeout = 30 * time.Second
+
+// Faucet is an external funding API, such as a custody platform or a testnet
+// faucet, sending the transfer on the updater's behalf
+type Faucet struct {
+	url        string
+	token      string
+	chainID    int64
+	httpClient *http.Client
+}
+
+// faucetRequest is the body POSTed to the faucet API
+type faucetRequest struct {
+	ChainID int64  `json:"chain_id"`
+	Address string `json:"address"`
+	Amount  string `json:"amount_wei"`
+}
+
+// faucetResponse is the optional body of the faucet API response
+type faucetResponse struct {
+	TxHash string `json:"tx_hash"`
+	ID     string `json:"id"`
+}
+
+// NewFaucet returns a treasury requesting transfers from the faucet API at
+// url, with token sent as a bearer token if set
+func NewFaucet(url, token string, chainID int64) *Faucet {
+	return &Faucet{
+		url:        url,
+		token:      token,
+		chainID:    chainID,
+		httpClient: &http.Client{Timeout: faucetTimeout},
+	}
+}
+
+// Name identifies the treasury as the faucet API
+func (f *Faucet) Name() string {
+	return "faucet"
+}
+
+// Transfer requests amount wei for the address. The faucet API answers with
+// a 2xx status, and optionally the transaction hash or an ID of the request.
+func (f *Faucet) Transfer(ctx context.Context, to common.Address, amount *big.Int) (string, error) {
+	body, err := json.Marshal(faucetRequest{ChainID: f.chainID, Address: to.Hex(), Amount: amount.String()})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("faucet returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var response faucetResponse
+	if json.Unmarshal(data, &response) == nil {
+		if response.TxHash != "" {
+			return response.TxHash, nil
+		}
+		return response.ID, nil
+	}
+	return "", nil
+}
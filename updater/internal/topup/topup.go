@@ -0,0 +1,154 @@
+// Package topup refills the sender from a treasury when its balance runs low,
+// so that the updater does not miss rounds for lack of gas
+package topup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrCoolingDown is returned while the cooldown since the last top-up runs
+var ErrCoolingDown = errors.New("top-up cooling down")
+
+// Treasury transfers funds to the sender
+type Treasury interface {
+	// Name identifies the treasury in logs and metrics
+	Name() string
+	// Transfer sends amount wei to the address and returns a reference to
+	// the transfer, such as its transaction hash
+	Transfer(ctx context.Context, to common.Address, amount *big.Int) (string, error)
+}
+
+// Config is the top-up policy
+type Config struct {
+	// Floor is the balance below which the sender is topped up
+	Floor *big.Int
+	// Target is the balance a top-up brings the sender to, nil or 0 always
+	// transfers MaxAmount
+	Target *big.Int
+	// MaxAmount caps the amount of a single top-up
+	MaxAmount *big.Int
+	// Cooldown is the minimum delay between top-ups, attempted or not
+	Cooldown time.Duration
+}
+
+// TopUp is a transfer made by the treasury
+type TopUp struct {
+	Treasury  string    `json:"treasury"`
+	Amount    string    `json:"amount_wei"`
+	Reference string    `json:"reference,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Manager tops up a sender, shared by the pipelines using the same sender so
+// that a low balance triggers a single transfer
+type Manager struct {
+	treasury Treasury
+	config   Config
+
+	// transfer serializes the top-ups
+	transfer sync.Mutex
+
+	mu          sync.Mutex
+	lastAttempt time.Time
+	last        *TopUp
+}
+
+// NewManager returns a manager topping up from the treasury
+func NewManager(treasury Treasury, config Config) (*Manager, error) {
+	if config.Floor == nil || config.Floor.Sign() <= 0 {
+		return nil, errors.New("top-up floor must be positive")
+	}
+	if config.MaxAmount == nil || config.MaxAmount.Sign() <= 0 {
+		return nil, errors.New("maximum top-up amount must be positive")
+	}
+	if config.Target != nil && config.Target.Sign() > 0 && config.Target.Cmp(config.Floor) <= 0 {
+		return nil, fmt.Errorf("top-up target %s must be above the floor %s", config.Target, config.Floor)
+	}
+	return &Manager{treasury: treasury, config: config}, nil
+}
+
+// Treasury returns the name of the treasury
+func (m *Manager) Treasury() string {
+	return m.treasury.Name()
+}
+
+// TreasuryAddress returns the account of the treasury, false when it is not
+// an account held by the updater, such as a funding API
+func (m *Manager) TreasuryAddress() (common.Address, bool) {
+	wallet, ok := m.treasury.(*Wallet)
+	if !ok {
+		return common.Address{}, false
+	}
+	return wallet.Address(), true
+}
+
+// Amount returns the amount a top-up transfers to a sender holding balance,
+// nil when the balance is at or above the floor
+func (m *Manager) Amount(balance *big.Int) *big.Int {
+	if balance.Cmp(m.config.Floor) >= 0 {
+		return nil
+	}
+	amount := new(big.Int).Set(m.config.MaxAmount)
+	if m.config.Target != nil && m.config.Target.Sign() > 0 {
+		missing := new(big.Int).Sub(m.config.Target, balance)
+		if missing.Cmp(amount) < 0 {
+			amount = missing
+		}
+	}
+	return amount
+}
+
+// Check tops up the sender when its balance is below the floor. It returns
+// the top-up made, nil when none was needed, and ErrCoolingDown when one is
+// needed but the last top-up is too recent.
+func (m *Manager) Check(ctx context.Context, to common.Address, balance *big.Int) (*TopUp, error) {
+	amount := m.Amount(balance)
+	if amount == nil {
+		return nil, nil
+	}
+
+	// Pipelines sharing the sender wait for a transfer in progress, then
+	// find the cooldown running rather than starting their own
+	m.transfer.Lock()
+	defer m.transfer.Unlock()
+	m.mu.Lock()
+	if !m.lastAttempt.IsZero() && time.Since(m.lastAttempt) < m.config.Cooldown {
+		m.mu.Unlock()
+		return nil, ErrCoolingDown
+	}
+	m.lastAttempt = time.Now()
+	m.mu.Unlock()
+
+	topUp := &TopUp{Treasury: m.treasury.Name(), Amount: amount.String(), Time: time.Now().UTC()}
+	reference, err := m.treasury.Transfer(ctx, to, amount)
+	topUp.Reference = reference
+	if err != nil {
+		topUp.Error = err.Error()
+	}
+	m.mu.Lock()
+	m.last = topUp
+	m.mu.Unlock()
+	if err != nil {
+		return topUp, fmt.Errorf("error topping up from %s: %w", m.treasury.Name(), err)
+	}
+	return topUp, nil
+}
+
+// Last returns the last top-up attempted, nil if none
+func (m *Manager) Last() *TopUp {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		return nil
+	}
+	last := *m.last
+	return &last
+}
@@ -0,0 +1,113 @@
+package topup
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// transferGas is the gas of a plain value transfer to an externally owned account
+const transferGas = 21000
+
+// confirmTimeout bounds the wait for a treasury transfer to be mined
+const confirmTimeout = 5 * time.Minute
+
+// Wallet is a treasury account whose key is held by the updater
+type Wallet struct {
+	chainID   *big.Int
+	key       *ecdsa.PrivateKey
+	rpcClient *ethclient.Client
+}
+
+// NewWallet returns a treasury sending transfers signed with key
+func NewWallet(chainID int64, key *ecdsa.PrivateKey, rpcClient *ethclient.Client) *Wallet {
+	return &Wallet{chainID: big.NewInt(chainID), key: key, rpcClient: rpcClient}
+}
+
+// Address returns the address of the treasury account
+func (w *Wallet) Address() common.Address {
+	return crypto.PubkeyToAddress(w.key.PublicKey)
+}
+
+// Name identifies the treasury by its address
+func (w *Wallet) Name() string {
+	return "wallet:" + w.Address().Hex()
+}
+
+// Transfer sends amount wei to the address and waits for the transaction to
+// be mined
+func (w *Wallet) Transfer(ctx context.Context, to common.Address, amount *big.Int) (string, error) {
+	from := w.Address()
+	balance, err := w.rpcClient.BalanceAt(ctx, from, nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting treasury balance: %w", err)
+	}
+	nonce, err := w.rpcClient.PendingNonceAt(ctx, from)
+	if err != nil {
+		return "", fmt.Errorf("error getting treasury nonce: %w", err)
+	}
+	txData, feeCap, err := w.txData(ctx, nonce, to, amount)
+	if err != nil {
+		return "", err
+	}
+	cost := new(big.Int).Add(amount, new(big.Int).Mul(feeCap, big.NewInt(transferGas)))
+	if balance.Cmp(cost) < 0 {
+		return "", fmt.Errorf("treasury balance %s wei is below the top-up cost %s wei", balance, cost)
+	}
+	tx, err := types.SignNewTx(w.key, types.LatestSignerForChainID(w.chainID), txData)
+	if err != nil {
+		return "", err
+	}
+	if err := w.rpcClient.SendTransaction(ctx, tx); err != nil {
+		return "", fmt.Errorf("error sending top-up transaction: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+	receipt, err := bind.WaitMined(ctx, w.rpcClient, tx)
+	if err != nil {
+		return tx.Hash().Hex(), fmt.Errorf("error waiting for top-up transaction: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return tx.Hash().Hex(), fmt.Errorf("top-up transaction failed")
+	}
+	return tx.Hash().Hex(), nil
+}
+
+// txData returns the transfer transaction and its maximum gas price, a
+// dynamic fee transaction on chains with a base fee
+func (w *Wallet) txData(ctx context.Context, nonce uint64, to common.Address, amount *big.Int) (types.TxData, *big.Int, error) {
+	head, err := w.rpcClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting latest block: %w", err)
+	}
+	if head.BaseFee == nil {
+		gasPrice, err := w.rpcClient.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting gas price: %w", err)
+		}
+		return &types.LegacyTx{Nonce: nonce, GasPrice: gasPrice, Gas: transferGas, To: &to, Value: amount}, gasPrice, nil
+	}
+	tip, err := w.rpcClient.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting gas tip: %w", err)
+	}
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	return &types.DynamicFeeTx{
+		ChainID:   w.chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       transferGas,
+		To:        &to,
+		Value:     amount,
+	}, feeCap, nil
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// tracesPath is the OTLP/HTTP path of trace exports
+const tracesPath = "/v1/traces"
+
+// httpClient is an otlptrace.Client sending binary protobuf exports over
+// HTTP, as specified by OTLP/HTTP
+type httpClient struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPClient(opts Options) (*httpClient, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expected an http or https URL", opts.Endpoint)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + tracesPath
+	return &httpClient{
+		url:     endpoint.String(),
+		headers: opts.Headers,
+		client:  &http.Client{Timeout: opts.Timeout},
+	}, nil
+}
+
+func (c *httpClient) Start(context.Context) error {
+	return nil
+}
+
+func (c *httpClient) Stop(context.Context) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// UploadTraces posts an ExportTraceServiceRequest, whose only field is the
+// repeated resource spans
+func (c *httpClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	var body []byte
+	for _, spans := range protoSpans {
+		data, err := proto.Marshal(spans)
+		if err != nil {
+			return err
+		}
+		body = protowire.AppendTag(body, 1, protowire.BytesType)
+		body = protowire.AppendBytes(body, data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP collector returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
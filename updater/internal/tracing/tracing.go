@@ -0,0 +1,94 @@
+// Package tracing exports OpenTelemetry traces of the updater to an OTLP
+// collector over HTTP
+package tracing
+
+import (
+	"context"
+	"drand-oracle-updater/internal/version"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Options configures the trace exporter
+type Options struct {
+	// Endpoint is the base URL of the OTLP/HTTP collector, traces being sent
+	// to its /v1/traces path. Empty disables tracing.
+	Endpoint string
+	// Headers are sent with every export, such as an API key
+	Headers map[string]string
+	// ServiceName is the service.name resource attribute
+	ServiceName string
+	// SampleRatio is the share of round traces sampled, from 0 to 1
+	SampleRatio float64
+	// Timeout bounds a single export
+	Timeout time.Duration
+	// Attributes are added to the resource, such as the deployment labels
+	Attributes map[string]string
+}
+
+// Setup installs the global tracer provider exporting to the collector and
+// returns its shutdown function, which flushes the pending spans. Without an
+// endpoint, the global no-op provider is left in place.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid trace sample ratio %v, must be between 0 and 1", opts.SampleRatio)
+	}
+	client, err := newHTTPClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(opts.ServiceName),
+		semconv.ServiceVersion(version.Version),
+	}
+	for k, v := range opts.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// ParseHeaders parses OTLP exporter headers, comma separated key=value pairs
+// with URL encoded values as in OTEL_EXPORTER_OTLP_HEADERS
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected key=value", pair)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid header %q: %w", key, err)
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+	return headers, nil
+}
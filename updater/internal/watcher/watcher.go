@@ -0,0 +1,185 @@
+// Package watcher follows the RandomnessUpdated events of a Drand Oracle
+// contract, so that rounds written by any updater are learned immediately.
+package watcher
+
+import (
+	"context"
+	"drand-oracle-updater/binding"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog/log"
+)
+
+// maxPollBlocks bounds the block range of a log query when polling
+const maxPollBlocks = 2000
+
+// resubscribeInterval is how long logs are polled after a subscription failed
+// before subscribing again
+const resubscribeInterval = 1 * time.Minute
+
+// Handler is called for every RandomnessUpdated event, in chain order
+type Handler func(event *binding.BindingRandomnessUpdated)
+
+// position is the location of a log in the chain
+type position struct {
+	block uint64
+	index uint
+}
+
+func (p position) after(o position) bool {
+	return p.block > o.block || (p.block == o.block && p.index > o.index)
+}
+
+// Watcher subscribes to RandomnessUpdated events over WebSocket when a
+// subscription client is available, and polls logs over HTTP otherwise or
+// while the subscription is down
+type Watcher struct {
+	rpcClient    *ethclient.Client
+	binding      *binding.Binding
+	wsBinding    *binding.Binding
+	pollInterval atomic.Int64
+
+	// last is the position of the last handled event
+	last position
+}
+
+// New returns a watcher polling with binding, and subscribing with wsBinding
+// when it is not nil
+func New(rpcClient *ethclient.Client, binding *binding.Binding, wsBinding *binding.Binding, pollInterval time.Duration) *Watcher {
+	w := &Watcher{
+		rpcClient: rpcClient,
+		binding:   binding,
+		wsBinding: wsBinding,
+	}
+	w.pollInterval.Store(int64(pollInterval))
+	return w
+}
+
+// SetPollInterval changes the interval between log queries, from the next
+// query on
+func (w *Watcher) SetPollInterval(pollInterval time.Duration) {
+	w.pollInterval.Store(int64(pollInterval))
+}
+
+// Run calls handle for every event from block from on, until ctx is done
+func (w *Watcher) Run(ctx context.Context, from uint64, handle Handler) error {
+	next := from
+	for {
+		if w.wsBinding != nil {
+			var err error
+			next, err = w.subscribe(ctx, next, handle)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warn().Err(err).Dur("retry_in", resubscribeInterval).Msg("RandomnessUpdated subscription failed, polling logs")
+		}
+
+		var deadline time.Time
+		if w.wsBinding != nil {
+			deadline = time.Now().Add(resubscribeInterval)
+		}
+		var err error
+		next, err = w.poll(ctx, next, deadline, handle)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// subscribe follows events through a subscription until it fails. Events
+// emitted since next are fetched once subscribed, so none are missed in
+// between. It returns the next block to fetch events from.
+func (w *Watcher) subscribe(ctx context.Context, next uint64, handle Handler) (uint64, error) {
+	sink := make(chan *binding.BindingRandomnessUpdated, 16)
+	sub, err := w.wsBinding.WatchRandomnessUpdated(&bind.WatchOpts{Context: ctx}, sink)
+	if err != nil {
+		return next, err
+	}
+	defer sub.Unsubscribe()
+	log.Info().Msg("Subscribed to RandomnessUpdated events")
+
+	next, err = w.fetch(ctx, next, handle)
+	if err != nil {
+		return next, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return next, ctx.Err()
+		case err := <-sub.Err():
+			return next, err
+		case event := <-sink:
+			w.handle(event, handle)
+			if event.Raw.BlockNumber >= next {
+				next = event.Raw.BlockNumber
+			}
+		}
+	}
+}
+
+// poll fetches new events every poll interval until the deadline, or forever
+// when the deadline is zero. It returns the next block to fetch events from.
+func (w *Watcher) poll(ctx context.Context, next uint64, deadline time.Time, handle Handler) (uint64, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return next, ctx.Err()
+		case <-time.After(time.Duration(w.pollInterval.Load())):
+			var err error
+			next, err = w.fetch(ctx, next, handle)
+			if err != nil {
+				log.Warn().Err(err).Uint64("from_block", next).Msg("Failed to poll RandomnessUpdated events")
+			}
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				return next, nil
+			}
+		}
+	}
+}
+
+// fetch handles the events from block next up to the latest block and returns
+// the next block to fetch from. The latest block is fetched again next time,
+// as logs of the same block may only become visible later.
+func (w *Watcher) fetch(ctx context.Context, next uint64, handle Handler) (uint64, error) {
+	latest, err := w.rpcClient.BlockNumber(ctx)
+	if err != nil {
+		return next, err
+	}
+	for next <= latest {
+		to := min(next+maxPollBlocks-1, latest)
+		it, err := w.binding.FilterRandomnessUpdated(&bind.FilterOpts{Start: next, End: &to, Context: ctx})
+		if err != nil {
+			return next, err
+		}
+		for it.Next() {
+			w.handle(it.Event, handle)
+		}
+		err = it.Error()
+		it.Close()
+		if err != nil {
+			return next, err
+		}
+		if to == latest {
+			return latest, nil
+		}
+		next = to + 1
+	}
+	return next, nil
+}
+
+// handle passes an event on unless it was removed by a reorg or already handled
+func (w *Watcher) handle(event *binding.BindingRandomnessUpdated, handle Handler) {
+	if event.Raw.Removed {
+		return
+	}
+	p := position{block: event.Raw.BlockNumber, index: event.Raw.Index}
+	if !p.after(w.last) {
+		return
+	}
+	w.last = p
+	handle(event)
+}
@@ -0,0 +1,39 @@
+package sender
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// KeyProvider holds the sender's key and signs its transactions
+type KeyProvider interface {
+	// Address returns the address of the key
+	Address() common.Address
+	// SignTx signs a transaction for a chain
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// LocalKey is a private key held in memory
+type LocalKey struct {
+	address    common.Address
+	privateKey *ecdsa.PrivateKey
+}
+
+func NewLocalKey(privateKey *ecdsa.PrivateKey) *LocalKey {
+	return &LocalKey{
+		address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		privateKey: privateKey,
+	}
+}
+
+func (k *LocalKey) Address() common.Address {
+	return k.address
+}
+
+func (k *LocalKey) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), k.privateKey)
+}
@@ -0,0 +1,164 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultLedgerPath is the derivation path of the first Ledger Live account
+const DefaultLedgerPath = "m/44'/60'/0'/0/0"
+
+// ledgerPollInterval is the interval between attempts to reach the device
+const ledgerPollInterval = 2 * time.Second
+
+// ErrLedgerTimeout is returned when a transaction is not confirmed on the device in time
+var ErrLedgerTimeout = errors.New("ledger confirmation timed out")
+
+// Ledger is a key held on a Ledger device connected over USB. Every
+// transaction has to be confirmed on the device, and the Ethereum app must be
+// open with blind signing enabled, as setRandomness is a contract call.
+type Ledger struct {
+	wallet  accounts.Wallet
+	account accounts.Account
+	timeout time.Duration
+
+	// mu serializes the transactions sent to the device
+	mu sync.Mutex
+	// signing is set while a transaction awaits its confirmation on the
+	// device, including after SignTx timed out
+	signing bool
+}
+
+// NewLedger opens the first Ledger device found and derives the account at
+// path, DefaultLedgerPath when empty, waiting until ctx is done for the device to be plugged in and
+// unlocked with the Ethereum app open. timeout bounds the confirmation of
+// each transaction on the device.
+func NewLedger(ctx context.Context, path string, timeout time.Duration) (*Ledger, error) {
+	if path == "" {
+		path = DefaultLedgerPath
+	}
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid derivation path %q: %w", path, err)
+	}
+	hub, err := usbwallet.NewLedgerHub()
+	if err != nil {
+		return nil, fmt.Errorf("error accessing USB devices: %w", err)
+	}
+
+	prompted := false
+	for {
+		account, wallet, err := openLedger(hub, derivationPath)
+		if err == nil {
+			log.Info().Str("url", wallet.URL().String()).Str("path", path).Str("address", account.Address.Hex()).Msg("Ledger connected")
+			return &Ledger{wallet: wallet, account: account, timeout: timeout}, nil
+		}
+		if !prompted {
+			log.Warn().Err(err).Msg("Connect and unlock the Ledger, and open the Ethereum app")
+			prompted = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("ledger not available: %w", err)
+		case <-time.After(ledgerPollInterval):
+		}
+	}
+}
+
+// openLedger opens the first device of the hub and derives the account at path
+func openLedger(hub *usbwallet.Hub, path accounts.DerivationPath) (accounts.Account, accounts.Wallet, error) {
+	wallets := hub.Wallets()
+	if len(wallets) == 0 {
+		return accounts.Account{}, nil, errors.New("no ledger found")
+	}
+	wallet := wallets[0]
+	if err := wallet.Open(""); err != nil && !errors.Is(err, accounts.ErrWalletAlreadyOpen) {
+		return accounts.Account{}, nil, err
+	}
+	account, err := wallet.Derive(path, true)
+	if err != nil {
+		// The device is reopened on the next attempt, once the app is open
+		_ = wallet.Close()
+		return accounts.Account{}, nil, err
+	}
+	return account, wallet, nil
+}
+
+func (l *Ledger) Address() common.Address {
+	return l.account.Address
+}
+
+// SignTx asks for the transaction to be confirmed on the device. The Ledger
+// Ethereum app only signs legacy transactions over USB.
+func (l *Ledger) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if tx.Type() != types.LegacyTxType {
+		return nil, fmt.Errorf("ledger cannot sign transactions of type %d", tx.Type())
+	}
+	var to string
+	if tx.To() != nil {
+		to = tx.To().Hex()
+	}
+	log.Warn().
+		Uint64("nonce", tx.Nonce()).
+		Str("to", to).
+		Str("value", tx.Value().String()).
+		Dur("timeout", l.timeout).
+		Msg("Confirm the transaction on the Ledger")
+
+	// The device shows one transaction at a time, and the wallet blocks the
+	// others until it is confirmed or rejected
+	l.mu.Lock()
+	if l.signing {
+		l.mu.Unlock()
+		return nil, fmt.Errorf("%w: a previous transaction still awaits its confirmation on the device", ErrLedgerTimeout)
+	}
+	l.signing = true
+	l.mu.Unlock()
+
+	type result struct {
+		tx  *types.Transaction
+		err error
+	}
+	done := make(chan result)
+	abandoned := make(chan struct{})
+	go func() {
+		signed, err := l.wallet.SignTx(l.account, tx, chainID)
+		l.mu.Lock()
+		l.signing = false
+		l.mu.Unlock()
+		select {
+		case <-abandoned:
+			if err == nil {
+				log.Warn().Uint64("nonce", tx.Nonce()).Msg("Transaction confirmed on the Ledger after its timeout, not broadcast")
+			}
+		case done <- result{signed, err}:
+		}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, fmt.Errorf("ledger refused the transaction: %w", r.err)
+		}
+		return r.tx, nil
+	case <-time.After(l.timeout):
+		// The device keeps waiting for the confirmation, and new transactions
+		// are refused until it is confirmed or rejected
+		close(abandoned)
+		return nil, ErrLedgerTimeout
+	}
+}
+
+// Close releases the device
+func (l *Ledger) Close() error {
+	return l.wallet.Close()
+}
@@ -0,0 +1,206 @@
+package sender
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// noopGasLimit is the gas of a plain value transfer, used by gap filling transactions
+const noopGasLimit = 21000
+
+// NonceClient is the subset of the RPC client used by the nonce manager
+type NonceClient interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// NonceManager hands out the sender's nonces. It tracks the nonces used by the
+// updater, reconciles them with the node's pending nonce so that transactions
+// sent from the same address by other tools are accounted for, and detects and
+// fills the gaps left by dropped transactions.
+type NonceManager struct {
+	client NonceClient
+	sender *Sender
+
+	// fillMutex serializes gap filling by the updaters sharing the manager
+	fillMutex sync.Mutex
+	// rotation is held by a key rotation while it drains the transactions of
+	// the current key, holding new nonces back
+	rotation sync.RWMutex
+
+	mu sync.Mutex
+	// next is the next nonce to hand out
+	next uint64
+	// inFlight holds the transactions sent with nonces that are not confirmed yet
+	inFlight map[uint64]*types.Transaction
+}
+
+// NonceState is a snapshot of the nonce manager and the node's view of the sender
+type NonceState struct {
+	// Next is the next nonce the manager hands out
+	Next uint64 `json:"next"`
+	// Pending is the node's pending nonce
+	Pending uint64 `json:"pending"`
+	// Latest is the node's nonce at the latest block, the number of mined transactions
+	Latest   uint64   `json:"latest"`
+	InFlight []uint64 `json:"in_flight,omitempty"`
+	// Gaps are nonces handed out that the node does not know about
+	Gaps []uint64 `json:"gaps,omitempty"`
+}
+
+func NewNonceManager(client NonceClient, sender *Sender) *NonceManager {
+	return &NonceManager{
+		client:   client,
+		sender:   sender,
+		inFlight: make(map[uint64]*types.Transaction),
+	}
+}
+
+// Next reserves the next nonce. The node's pending nonce is checked every time,
+// so nonces used by transactions sent outside the updater are skipped.
+func (m *NonceManager) Next(ctx context.Context) (uint64, error) {
+	m.rotation.RLock()
+	defer m.rotation.RUnlock()
+	pending, err := m.client.PendingNonceAt(ctx, m.sender.Address())
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if pending > m.next {
+		if m.next != 0 {
+			log.Warn().Uint64("local", m.next).Uint64("pending", pending).Msg("Sender nonce advanced outside the updater")
+		}
+		m.next = pending
+	}
+	nonce := m.next
+	m.next++
+	return nonce, nil
+}
+
+// Sent records the transaction sent with a reserved nonce
+func (m *NonceManager) Sent(tx *types.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[tx.Nonce()] = tx
+}
+
+// Release returns a reserved nonce whose transaction was not sent. Only the
+// last reserved nonce can be handed out again, others are left as gaps.
+func (m *NonceManager) Release(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if nonce+1 == m.next {
+		m.next = nonce
+	}
+}
+
+// Confirm forgets the transaction of a nonce once it is mined
+func (m *NonceManager) Confirm(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.inFlight, nonce)
+}
+
+// State reconciles the local nonces with the node and reports the gaps: nonces
+// handed out below the local next nonce that the node has neither mined nor
+// holds in its mempool.
+func (m *NonceManager) State(ctx context.Context) (NonceState, error) {
+	pending, err := m.client.PendingNonceAt(ctx, m.sender.Address())
+	if err != nil {
+		return NonceState{}, err
+	}
+	latest, err := m.client.NonceAt(ctx, m.sender.Address(), nil)
+	if err != nil {
+		return NonceState{}, err
+	}
+	return m.Reconcile(pending, latest), nil
+}
+
+// Reconcile reconciles the local nonces with the node's pending and latest
+// nonces of the sender, as polled by the caller, and reports the gaps as
+// State does
+func (m *NonceManager) Reconcile(pending, latest uint64) NonceState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Mined transactions are no longer in flight
+	for nonce := range m.inFlight {
+		if nonce < latest {
+			delete(m.inFlight, nonce)
+		}
+	}
+	if pending > m.next {
+		m.next = pending
+	}
+
+	state := NonceState{Next: m.next, Pending: pending, Latest: latest}
+	for nonce := range m.inFlight {
+		state.InFlight = append(state.InFlight, nonce)
+	}
+	sort.Slice(state.InFlight, func(i, j int) bool { return state.InFlight[i] < state.InFlight[j] })
+	for nonce := pending; nonce < m.next; nonce++ {
+		state.Gaps = append(state.Gaps, nonce)
+	}
+	return state
+}
+
+// FillGaps sends a transaction for every gap: the original transaction when it
+// is known, so that the dropped submission lands, or a zero value transfer to
+// the sender itself otherwise. It returns the nonces that were filled.
+func (m *NonceManager) FillGaps(ctx context.Context, chainID int64, gasPrice *big.Int) ([]uint64, error) {
+	m.fillMutex.Lock()
+	defer m.fillMutex.Unlock()
+
+	state, err := m.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var filled []uint64
+	for _, nonce := range state.Gaps {
+		m.mu.Lock()
+		tx := m.inFlight[nonce]
+		m.mu.Unlock()
+
+		kind := "replay"
+		if tx == nil {
+			kind = "noop"
+			tx, err = m.sender.noopTransaction(chainID, nonce, gasPrice)
+			if err != nil {
+				return filled, err
+			}
+		}
+		if err := m.client.SendTransaction(ctx, tx); err != nil {
+			log.Error().Err(err).Uint64("nonce", nonce).Str("kind", kind).Msg("Failed to fill nonce gap")
+			return filled, err
+		}
+		m.Sent(tx)
+		filled = append(filled, nonce)
+		log.Warn().Uint64("nonce", nonce).Str("kind", kind).Str("hash", tx.Hash().Hex()).Msg("Filled nonce gap")
+	}
+	return filled, nil
+}
+
+// noopTransaction builds a signed zero value transfer from the sender to itself
+func (s *Sender) noopTransaction(chainID int64, nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	s.mu.RLock()
+	address, key := s.address, s.key
+	s.mu.RUnlock()
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Gas:      noopGasLimit,
+		To:       &address,
+		Value:    new(big.Int),
+	})
+	return key.SignTx(tx, big.NewInt(chainID))
+}
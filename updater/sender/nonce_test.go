@@ -0,0 +1,153 @@
+package sender
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testChainID = 1337
+
+// fakeNode is the node's view of the sender's nonces, recording the
+// transactions sent to it
+type fakeNode struct {
+	pending uint64
+	latest  uint64
+	sent    []*types.Transaction
+}
+
+func (n *fakeNode) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return n.pending, nil
+}
+
+func (n *fakeNode) NonceAt(context.Context, common.Address, *big.Int) (uint64, error) {
+	return n.latest, nil
+}
+
+func (n *fakeNode) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	n.sent = append(n.sent, tx)
+	return nil
+}
+
+func newTestNonceManager(t *testing.T, node *fakeNode) (*NonceManager, *Sender) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	sender := NewSender(testChainID, NewLocalKey(key))
+	return NewNonceManager(node, sender), sender
+}
+
+// reserve hands out n nonces
+func reserve(t *testing.T, m *NonceManager, n int) []uint64 {
+	t.Helper()
+	var nonces []uint64
+	for i := 0; i < n; i++ {
+		nonce, err := m.Next(context.Background())
+		if err != nil {
+			t.Fatalf("next nonce: %v", err)
+		}
+		nonces = append(nonces, nonce)
+	}
+	return nonces
+}
+
+// sendWith records a transaction sent with nonce
+func sendWith(m *NonceManager, nonce uint64) *types.Transaction {
+	tx := types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: big.NewInt(1), Gas: 100_000})
+	m.Sent(tx)
+	return tx
+}
+
+func TestNonceManagerNext(t *testing.T) {
+	node := &fakeNode{pending: 5}
+	m, _ := newTestNonceManager(t, node)
+	if nonces := reserve(t, m, 2); !slices.Equal(nonces, []uint64{5, 6}) {
+		t.Fatalf("nonces = %v, want [5 6]", nonces)
+	}
+	// Transactions sent outside the updater advance the pending nonce
+	node.pending = 9
+	if nonces := reserve(t, m, 1); !slices.Equal(nonces, []uint64{9}) {
+		t.Errorf("nonces = %v, want [9]", nonces)
+	}
+}
+
+func TestNonceManagerRelease(t *testing.T) {
+	m, _ := newTestNonceManager(t, &fakeNode{})
+	reserve(t, m, 3)
+
+	// Only the last reserved nonce is reused, others are left as gaps
+	m.Release(1)
+	m.Release(2)
+	if nonces := reserve(t, m, 1); !slices.Equal(nonces, []uint64{2}) {
+		t.Errorf("nonces = %v, want [2]", nonces)
+	}
+	if state := m.Reconcile(1, 1); !slices.Equal(state.Gaps, []uint64{1, 2}) {
+		t.Errorf("gaps = %v, want [1 2]", state.Gaps)
+	}
+}
+
+func TestNonceManagerGaps(t *testing.T) {
+	node := &fakeNode{}
+	m, sender := newTestNonceManager(t, node)
+	reserve(t, m, 4)
+	sent := map[uint64]*types.Transaction{}
+	for _, nonce := range []uint64{0, 1, 3} {
+		sent[nonce] = sendWith(m, nonce)
+	}
+
+	// Nonce 0 is mined, 1 was dropped from the mempool and 2 was never sent,
+	// so that 3 is stuck behind them
+	node.pending, node.latest = 1, 1
+	state, err := m.State(context.Background())
+	if err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	if !slices.Equal(state.Gaps, []uint64{1, 2, 3}) || !slices.Equal(state.InFlight, []uint64{1, 3}) {
+		t.Fatalf("gaps %v and in flight %v, want [1 2 3] and [1 3]", state.Gaps, state.InFlight)
+	}
+
+	filled, err := m.FillGaps(context.Background(), testChainID, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("filling gaps: %v", err)
+	}
+	if !slices.Equal(filled, []uint64{1, 2, 3}) || len(node.sent) != 3 {
+		t.Fatalf("filled %v with %d transactions, want [1 2 3]", filled, len(node.sent))
+	}
+	// The dropped transactions are sent again, the unused nonce is taken by a
+	// zero value transfer to the sender
+	for _, nonce := range []uint64{1, 3} {
+		if node.sent[nonce-1] != sent[nonce] {
+			t.Errorf("nonce %d filled with %s, want the dropped transaction", nonce, node.sent[nonce-1].Hash())
+		}
+	}
+	noop := node.sent[1]
+	if noop.Nonce() != 2 || noop.To() == nil || *noop.To() != sender.Address() || noop.Value().Sign() != 0 || noop.Gas() != noopGasLimit {
+		t.Errorf("nonce 2 filled with a transfer of %s to %v at nonce %d, want a zero value transfer to the sender", noop.Value(), noop.To(), noop.Nonce())
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(testChainID)), noop)
+	if err != nil || from != sender.Address() {
+		t.Errorf("gap filler signed by %s, %v, want the sender", from, err)
+	}
+
+	// Once the node holds them, there is no gap left and the next nonce follows
+	node.pending = 4
+	if state := m.Reconcile(node.pending, node.latest); len(state.Gaps) != 0 {
+		t.Errorf("gaps = %v after filling, want none", state.Gaps)
+	}
+	if nonces := reserve(t, m, 1); !slices.Equal(nonces, []uint64{4}) {
+		t.Errorf("nonces = %v, want [4]", nonces)
+	}
+	// Mined transactions are no longer in flight
+	node.latest = 4
+	m.Confirm(3)
+	if state := m.Reconcile(node.pending, node.latest); len(state.InFlight) != 0 {
+		t.Errorf("in flight = %v once mined, want none", state.InFlight)
+	}
+}
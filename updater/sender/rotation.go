@@ -0,0 +1,89 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// drainPollInterval is the delay between two checks of the mined nonce while
+// draining a key
+const drainPollInterval = 2 * time.Second
+
+// ErrDrainTimeout is returned when the transactions of the current key are not
+// all mined within the drain timeout. The sender keeps its current key.
+var ErrDrainTimeout = errors.New("timed out draining the transactions of the current key")
+
+// Rotation describes a completed key rotation
+type Rotation struct {
+	From common.Address `json:"from"`
+	To   common.Address `json:"to"`
+	// Drained is the number of transactions of the previous key waited for
+	Drained   uint64    `json:"drained"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+// Rotate switches the sender to the next key without a restart. New nonces are
+// held back while the transactions already sent with the current key are
+// mined, then the key is switched and the nonces start over from the next
+// key's pending nonce. The sender keeps its current key when the drain does not
+// complete within drainTimeout or ctx is cancelled.
+func (m *NonceManager) Rotate(ctx context.Context, next KeyProvider, drainTimeout time.Duration) (Rotation, error) {
+	from := m.sender.Address()
+	if next.Address() == from {
+		return Rotation{}, fmt.Errorf("sender already uses key %s", from.Hex())
+	}
+
+	// Updaters reserving a nonce wait until the rotation completes
+	m.rotation.Lock()
+	defer m.rotation.Unlock()
+
+	m.mu.Lock()
+	handedOut := m.next
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, drainTimeout)
+	defer cancel()
+	var drained uint64
+	for {
+		latest, err := m.client.NonceAt(ctx, from, nil)
+		if err == nil {
+			if drained == 0 && handedOut > latest {
+				drained = handedOut - latest
+			}
+			if latest >= handedOut {
+				break
+			}
+			log.Info().
+				Str("address", from.Hex()).
+				Uint64("mined", latest).
+				Uint64("next", handedOut).
+				Msg("Draining transactions of the current sender key")
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return Rotation{}, ErrDrainTimeout
+			}
+			return Rotation{}, ctx.Err()
+		case <-time.After(drainPollInterval):
+		}
+	}
+
+	m.sender.setKey(next)
+	m.mu.Lock()
+	m.next = 0
+	m.inFlight = make(map[uint64]*types.Transaction)
+	m.mu.Unlock()
+	return Rotation{
+		From:      from,
+		To:        next.Address(),
+		Drained:   drained,
+		RotatedAt: time.Now().UTC(),
+	}, nil
+}
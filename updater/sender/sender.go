@@ -1,41 +1,77 @@
 package sender
 
 import (
-	"crypto/ecdsa"
 	"errors"
 	"math/big"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 type Sender struct {
-	chainID    int64
-	address    common.Address
-	privateKey *ecdsa.PrivateKey
+	chainID int64
+
+	// mu guards the key, switched by a rotation
+	mu      sync.RWMutex
+	address common.Address
+	key     KeyProvider
+
+	// lastSignAt and lastSignErr are the outcome of the latest transaction
+	// signed by the key
+	lastSignMutex sync.Mutex
+	lastSignAt    time.Time
+	lastSignErr   error
 }
 
-func NewSender(chainID int64, privateKey *ecdsa.PrivateKey) *Sender {
-	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+func NewSender(chainID int64, key KeyProvider) *Sender {
 	return &Sender{
-		chainID:    chainID,
-		address:    address,
-		privateKey: privateKey,
+		chainID: chainID,
+		address: key.Address(),
+		key:     key,
 	}
 }
 
 func (s *Sender) Address() common.Address {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.address
 }
 
 func (s *Sender) SignerFn() bind.SignerFn {
 	return func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
-		signer := types.LatestSignerForChainID(big.NewInt(s.chainID))
-		if address != s.address {
+		s.mu.RLock()
+		key := s.key
+		current := s.address
+		s.mu.RUnlock()
+		if address != current {
 			return nil, errors.New("invalid sender address")
 		}
-		return types.SignTx(tx, signer, s.privateKey)
+		signed, err := key.SignTx(tx, big.NewInt(s.chainID))
+		s.lastSignMutex.Lock()
+		s.lastSignAt, s.lastSignErr = time.Now(), err
+		s.lastSignMutex.Unlock()
+		return signed, err
 	}
 }
+
+// LastSign returns the time and error of the latest transaction signed by the
+// key, a zero time before its first transaction
+func (s *Sender) LastSign() (time.Time, error) {
+	s.lastSignMutex.Lock()
+	defer s.lastSignMutex.Unlock()
+	return s.lastSignAt, s.lastSignErr
+}
+
+// setKey switches the key signing the sender's transactions
+func (s *Sender) setKey(key KeyProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.address = key.Address()
+	s.key = key
+	s.lastSignMutex.Lock()
+	s.lastSignAt, s.lastSignErr = time.Time{}, nil
+	s.lastSignMutex.Unlock()
+}
@@ -0,0 +1,269 @@
+package sender
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// EntryPointV06 is the address of the ERC-4337 v0.6 EntryPoint contract
+var EntryPointV06 = common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")
+
+// userOpReceiptPollInterval is the interval between user operation receipt queries
+const userOpReceiptPollInterval = 2 * time.Second
+
+// dummySignature stands in for the owner signature while estimating gas, so
+// that the account's signature check costs as much as with a real signature
+var dummySignature = hexutil.MustDecode("0xfffffffffffffffffffffffffffffff0000000000000000000000000000000007aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1c")
+
+var (
+	uint256Type, _ = abi.NewType("uint256", "", nil)
+	uint192Type, _ = abi.NewType("uint192", "", nil)
+	addressType, _ = abi.NewType("address", "", nil)
+	bytesType, _   = abi.NewType("bytes", "", nil)
+	bytes32Type, _ = abi.NewType("bytes32", "", nil)
+
+	// getNonce(address sender, uint192 key) of the EntryPoint
+	getNonceMethod = abi.NewMethod("getNonce", "getNonce", abi.Function, "view", false, false,
+		abi.Arguments{{Name: "sender", Type: addressType}, {Name: "key", Type: uint192Type}},
+		abi.Arguments{{Name: "nonce", Type: uint256Type}})
+	// execute(address dest, uint256 value, bytes func) of the smart account
+	executeMethod = abi.NewMethod("execute", "execute", abi.Function, "nonpayable", false, false,
+		abi.Arguments{{Name: "dest", Type: addressType}, {Name: "value", Type: uint256Type}, {Name: "func", Type: bytesType}},
+		nil)
+)
+
+// UserOperation is an ERC-4337 v0.6 user operation
+type UserOperation struct {
+	Sender               common.Address `json:"sender"`
+	Nonce                *hexutil.Big   `json:"nonce"`
+	InitCode             hexutil.Bytes  `json:"initCode"`
+	CallData             hexutil.Bytes  `json:"callData"`
+	CallGasLimit         *hexutil.Big   `json:"callGasLimit"`
+	VerificationGasLimit *hexutil.Big   `json:"verificationGasLimit"`
+	PreVerificationGas   *hexutil.Big   `json:"preVerificationGas"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	PaymasterAndData     hexutil.Bytes  `json:"paymasterAndData"`
+	Signature            hexutil.Bytes  `json:"signature"`
+}
+
+// Hash returns the hash of the user operation signed by the account owner
+func (op *UserOperation) Hash(entryPoint common.Address, chainID int64) common.Hash {
+	packed, _ := abi.Arguments{
+		{Type: addressType}, {Type: uint256Type}, {Type: bytes32Type}, {Type: bytes32Type},
+		{Type: uint256Type}, {Type: uint256Type}, {Type: uint256Type}, {Type: uint256Type}, {Type: uint256Type},
+		{Type: bytes32Type},
+	}.Pack(
+		op.Sender,
+		op.Nonce.ToInt(),
+		crypto.Keccak256Hash(op.InitCode),
+		crypto.Keccak256Hash(op.CallData),
+		op.CallGasLimit.ToInt(),
+		op.VerificationGasLimit.ToInt(),
+		op.PreVerificationGas.ToInt(),
+		op.MaxFeePerGas.ToInt(),
+		op.MaxPriorityFeePerGas.ToInt(),
+		crypto.Keccak256Hash(op.PaymasterAndData),
+	)
+	encoded, _ := abi.Arguments{{Type: bytes32Type}, {Type: addressType}, {Type: uint256Type}}.Pack(
+		crypto.Keccak256Hash(packed),
+		entryPoint,
+		big.NewInt(chainID),
+	)
+	return crypto.Keccak256Hash(encoded)
+}
+
+// Sequence is the nonce sequence of the user operation within its nonce key,
+// the low 64 bits of its nonce
+func (op *UserOperation) Sequence() uint64 {
+	return new(big.Int).And(op.Nonce.ToInt(), new(big.Int).SetUint64(math.MaxUint64)).Uint64()
+}
+
+// GasLimit is the total gas the user operation may use
+func (op *UserOperation) GasLimit() uint64 {
+	return op.CallGasLimit.ToInt().Uint64() + op.VerificationGasLimit.ToInt().Uint64() + op.PreVerificationGas.ToInt().Uint64()
+}
+
+// UserOperationReceipt is the receipt of an included user operation
+type UserOperationReceipt struct {
+	UserOpHash common.Hash    `json:"userOpHash"`
+	Sender     common.Address `json:"sender"`
+	Nonce      *hexutil.Big   `json:"nonce"`
+	// Success is false when the call of the account reverted, the bundle
+	// transaction itself succeeding
+	Success       bool         `json:"success"`
+	ActualGasCost *hexutil.Big `json:"actualGasCost"`
+	ActualGasUsed *hexutil.Big `json:"actualGasUsed"`
+	// Logs are the logs emitted by the user operation
+	Logs    []*types.Log `json:"logs"`
+	Receipt struct {
+		TransactionHash common.Hash  `json:"transactionHash"`
+		BlockNumber     *hexutil.Big `json:"blockNumber"`
+	} `json:"receipt"`
+}
+
+// UserOpSender sends calls as ERC-4337 user operations of a smart account
+// through a bundler, the gas being sponsored by a paymaster. The account must
+// be owned by the sender key and expose execute(address,uint256,bytes), like
+// the reference SimpleAccount.
+type UserOpSender struct {
+	chainID          int64
+	account          common.Address
+	entryPoint       common.Address
+	paymasterAndData []byte
+	privateKey       *ecdsa.PrivateKey
+	bundler          *rpc.Client
+	rpcClient        *ethclient.Client
+}
+
+func NewUserOpSender(
+	chainID int64,
+	privateKey *ecdsa.PrivateKey,
+	account common.Address,
+	entryPoint common.Address,
+	paymasterAndData []byte,
+	bundler *rpc.Client,
+	rpcClient *ethclient.Client,
+) *UserOpSender {
+	return &UserOpSender{
+		chainID:          chainID,
+		account:          account,
+		entryPoint:       entryPoint,
+		paymasterAndData: paymasterAndData,
+		privateKey:       privateKey,
+		bundler:          bundler,
+		rpcClient:        rpcClient,
+	}
+}
+
+// Address returns the address of the smart account
+func (s *UserOpSender) Address() common.Address {
+	return s.account
+}
+
+// EntryPoint returns the address of the EntryPoint contract
+func (s *UserOpSender) EntryPoint() common.Address {
+	return s.entryPoint
+}
+
+// Send sends a call of the smart account to the bundler and returns the user
+// operation. key is the EntryPoint nonce key: senders waiting for their user
+// operations to be included before sending the next one must use distinct keys.
+func (s *UserOpSender) Send(ctx context.Context, key *big.Int, to common.Address, value *big.Int, data []byte, gasPrice *big.Int) (*UserOperation, common.Hash, error) {
+	if value == nil {
+		value = new(big.Int)
+	}
+	nonce, err := s.nonce(ctx, key)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	callData, err := packCall(executeMethod, to, value, data)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	op := &UserOperation{
+		Sender:               s.account,
+		Nonce:                (*hexutil.Big)(nonce),
+		InitCode:             hexutil.Bytes{},
+		CallData:             callData,
+		CallGasLimit:         new(hexutil.Big),
+		VerificationGasLimit: new(hexutil.Big),
+		PreVerificationGas:   new(hexutil.Big),
+		MaxFeePerGas:         (*hexutil.Big)(gasPrice),
+		MaxPriorityFeePerGas: (*hexutil.Big)(gasPrice),
+		PaymasterAndData:     s.paymasterAndData,
+		Signature:            dummySignature,
+	}
+
+	var estimate struct {
+		CallGasLimit         *hexutil.Big `json:"callGasLimit"`
+		VerificationGasLimit *hexutil.Big `json:"verificationGasLimit"`
+		PreVerificationGas   *hexutil.Big `json:"preVerificationGas"`
+	}
+	if err := s.bundler.CallContext(ctx, &estimate, "eth_estimateUserOperationGas", op, s.entryPoint); err != nil {
+		return nil, common.Hash{}, fmt.Errorf("error estimating user operation gas: %w", err)
+	}
+	if estimate.CallGasLimit == nil || estimate.VerificationGasLimit == nil || estimate.PreVerificationGas == nil {
+		return nil, common.Hash{}, errors.New("incomplete user operation gas estimate")
+	}
+	op.CallGasLimit = estimate.CallGasLimit
+	op.VerificationGasLimit = estimate.VerificationGasLimit
+	op.PreVerificationGas = estimate.PreVerificationGas
+
+	hash := op.Hash(s.entryPoint, s.chainID)
+	signature, err := crypto.Sign(accounts.TextHash(hash[:]), s.privateKey)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	op.Signature = signature
+
+	var sent common.Hash
+	if err := s.bundler.CallContext(ctx, &sent, "eth_sendUserOperation", op, s.entryPoint); err != nil {
+		return nil, common.Hash{}, err
+	}
+	if sent != hash {
+		return nil, common.Hash{}, fmt.Errorf("bundler returned user operation hash %s, expected %s", sent.Hex(), hash.Hex())
+	}
+	return op, hash, nil
+}
+
+// WaitIncluded waits for a user operation to be included in a block
+func (s *UserOpSender) WaitIncluded(ctx context.Context, hash common.Hash) (*UserOperationReceipt, error) {
+	ticker := time.NewTicker(userOpReceiptPollInterval)
+	defer ticker.Stop()
+	for {
+		var receipt *UserOperationReceipt
+		err := s.bundler.CallContext(ctx, &receipt, "eth_getUserOperationReceipt", hash)
+		if err == nil && receipt != nil {
+			return receipt, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// nonce reads the account's next nonce for a key from the EntryPoint
+func (s *UserOpSender) nonce(ctx context.Context, key *big.Int) (*big.Int, error) {
+	data, err := packCall(getNonceMethod, s.account, key)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.rpcClient.CallContract(ctx, ethereum.CallMsg{To: &s.entryPoint, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading account nonce: %w", err)
+	}
+	values, err := getNonceMethod.Outputs.Unpack(result)
+	if err != nil {
+		return nil, fmt.Errorf("error reading account nonce: %w", err)
+	}
+	return values[0].(*big.Int), nil
+}
+
+// packCall returns the calldata of a method call
+func packCall(method abi.Method, args ...any) ([]byte, error) {
+	packed, err := method.Inputs.Pack(args...)
+	if err != nil {
+		return nil, err
+	}
+	return append(slices.Clone(method.ID), packed...), nil
+}
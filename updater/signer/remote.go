@@ -0,0 +1,112 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const remoteSignerTimeout = 10 * time.Second
+
+// RemoteSigner requests SetRandomness signatures from a remote signing service.
+// Returned signatures are verified against the expected signer address.
+type RemoteSigner struct {
+	chainID            int64
+	drandOracleAddress common.Address
+	address            common.Address
+	url                string
+	scheme             Scheme
+	httpClient         *http.Client
+}
+
+type remoteSignRequest struct {
+	Scheme            string         `json:"scheme"`
+	ChainID           int64          `json:"chain_id"`
+	VerifyingContract common.Address `json:"verifying_contract"`
+	Round             uint64         `json:"round"`
+	Timestamp         uint64         `json:"timestamp"`
+	Randomness        hexutil.Bytes  `json:"randomness"`
+	Signature         hexutil.Bytes  `json:"signature"`
+	Digest            hexutil.Bytes  `json:"digest"`
+}
+
+type remoteSignResponse struct {
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+func NewRemoteSigner(
+	chainID int64,
+	drandOracleAddress common.Address,
+	address common.Address,
+	url string,
+	scheme Scheme,
+) *RemoteSigner {
+	return &RemoteSigner{
+		chainID:            chainID,
+		drandOracleAddress: drandOracleAddress,
+		address:            address,
+		url:                url,
+		scheme:             scheme,
+		httpClient:         &http.Client{Timeout: remoteSignerTimeout},
+	}
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *RemoteSigner) SignSetRandomness(ctx context.Context, round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error) {
+	digest, err := s.scheme.Digest(s.chainID, s.drandOracleAddress, round, timestamp, randomness, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(remoteSignRequest{
+		Scheme:            s.scheme.Name(),
+		ChainID:           s.chainID,
+		VerifyingContract: s.drandOracleAddress,
+		Round:             round,
+		Timestamp:         timestamp,
+		Randomness:        randomness[:],
+		Signature:         signature,
+		Digest:            digest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer %s returned %s", s.address.Hex(), resp.Status)
+	}
+
+	var result remoteSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	// Never trust the remote signer, the signature must recover to its configured address
+	recovered, err := RecoverAddress(digest, result.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer %s returned an invalid signature: %w", s.address.Hex(), err)
+	}
+	if recovered != s.address {
+		return nil, fmt.Errorf("remote signer %s returned a signature from %s", s.address.Hex(), recovered.Hex())
+	}
+	return result.Signature, nil
+}
@@ -0,0 +1,66 @@
+package signer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RotatingSigner is a SetRandomnessSigner whose signer can be switched while
+// the updater runs, to rotate the signer key without a restart
+type RotatingSigner struct {
+	mu      sync.RWMutex
+	current SetRandomnessSigner
+
+	// lastSignAt and lastSignErr are the outcome of the latest signature of
+	// the current signer
+	lastSignMutex sync.Mutex
+	lastSignAt    time.Time
+	lastSignErr   error
+}
+
+func NewRotatingSigner(current SetRandomnessSigner) *RotatingSigner {
+	return &RotatingSigner{current: current}
+}
+
+// Current returns the signer in use
+func (s *RotatingSigner) Current() SetRandomnessSigner {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Rotate switches to the next signer
+func (s *RotatingSigner) Rotate(next SetRandomnessSigner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = next
+	s.lastSignMutex.Lock()
+	s.lastSignAt, s.lastSignErr = time.Time{}, nil
+	s.lastSignMutex.Unlock()
+}
+
+func (s *RotatingSigner) Address() common.Address {
+	return s.Current().Address()
+}
+
+func (s *RotatingSigner) SignSetRandomness(ctx context.Context, round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error) {
+	eip712Signature, err := s.Current().SignSetRandomness(ctx, round, timestamp, randomness, signature)
+	// A cancelled signature says nothing of the signer
+	if ctx.Err() == nil {
+		s.lastSignMutex.Lock()
+		s.lastSignAt, s.lastSignErr = time.Now(), err
+		s.lastSignMutex.Unlock()
+	}
+	return eip712Signature, err
+}
+
+// LastSign returns the time and error of the latest signature of the current
+// signer, a zero time before its first signature
+func (s *RotatingSigner) LastSign() (time.Time, error) {
+	s.lastSignMutex.Lock()
+	defer s.lastSignMutex.Unlock()
+	return s.lastSignAt, s.lastSignErr
+}
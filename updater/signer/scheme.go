@@ -0,0 +1,41 @@
+package signer
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SchemeEIP712 is the name of the EIP-712 signature scheme
+const SchemeEIP712 = "eip712"
+
+// Scheme is the payload a SetRandomness signature is computed over, which must
+// match the verification of the oracle contract version. A scheme is only added
+// along with the contract verifying it.
+type Scheme interface {
+	Name() string
+	// Digest returns the hash signed to authorize a SetRandomness call
+	Digest(chainID int64, drandOracleAddress common.Address, round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error)
+}
+
+// ParseScheme returns the signature scheme of a name, EIP-712 when empty
+func ParseScheme(name string) (Scheme, error) {
+	switch name {
+	case SchemeEIP712, "":
+		return EIP712{}, nil
+	default:
+		return nil, fmt.Errorf("unknown signature scheme %q, expected %s", name, SchemeEIP712)
+	}
+}
+
+// EIP712 signs the SetRandomness typed data, under the DrandOracle domain of
+// the chain ID and oracle address
+type EIP712 struct{}
+
+func (EIP712) Name() string {
+	return SchemeEIP712
+}
+
+func (EIP712) Digest(chainID int64, drandOracleAddress common.Address, round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error) {
+	return TypedDataHash(SetRandomnessTypedData(chainID, drandOracleAddress, round, timestamp, randomness, signature))
+}
@@ -1,31 +1,40 @@
 package signer
 
 import (
+	"context"
 	"crypto/ecdsa"
-	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
+// SetRandomnessSigner authorizes SetRandomness payloads for the Drand Oracle contract
+type SetRandomnessSigner interface {
+	// Address returns the signer address, or the address of the first member of a threshold signer
+	Address() common.Address
+	// SignSetRandomness returns the signature to pass as the _signature argument of setRandomness
+	SignSetRandomness(ctx context.Context, round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error)
+}
+
 type Signer struct {
 	chainID            int64
 	drandOracleAddress common.Address
 	privateKey         *ecdsa.PrivateKey
+	scheme             Scheme
 }
 
 func NewSigner(
 	chainID int64,
 	drandOracleAddress common.Address,
 	privateKey *ecdsa.PrivateKey,
+	scheme Scheme,
 ) *Signer {
 	return &Signer{
 		chainID:            chainID,
 		drandOracleAddress: drandOracleAddress,
 		privateKey:         privateKey,
+		scheme:             scheme,
 	}
 }
 
@@ -33,58 +42,26 @@ func (s *Signer) Address() common.Address {
 	return crypto.PubkeyToAddress(s.privateKey.PublicKey)
 }
 
-func (s *Signer) SignSetRandomness(round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error) {
-	typeData := &apitypes.TypedData{
-		Types: apitypes.Types{
-			// SetRandomness(uint64 round,uint64 timestamp,bytes32 randomness,bytes signature)
-			"SetRandomness": []apitypes.Type{
-				{Name: "round", Type: "uint64"},
-				{Name: "timestamp", Type: "uint64"},
-				{Name: "randomness", Type: "bytes32"},
-				{Name: "signature", Type: "bytes"},
-			},
-			// EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)
-			"EIP712Domain": []apitypes.Type{
-				{Name: "name", Type: "string"},
-				{Name: "version", Type: "string"},
-				{Name: "chainId", Type: "uint256"},
-				{Name: "verifyingContract", Type: "address"},
-			}},
-		PrimaryType: "SetRandomness",
-		Domain: apitypes.TypedDataDomain{
-			Name:              "DrandOracle",
-			Version:           "1.0.0",
-			ChainId:           math.NewHexOrDecimal256(s.chainID),
-			VerifyingContract: s.drandOracleAddress.Hex(),
-		},
-		Message: apitypes.TypedDataMessage{
-			"round":      math.NewHexOrDecimal256(int64(round)),
-			"timestamp":  math.NewHexOrDecimal256(int64(timestamp)),
-			"randomness": randomness,
-			"signature":  signature,
-		},
+func (s *Signer) SignSetRandomness(ctx context.Context, round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error) {
+	digest, err := s.scheme.Digest(s.chainID, s.drandOracleAddress, round, timestamp, randomness, signature)
+	if err != nil {
+		return nil, err
 	}
+	return s.Sign(digest)
+}
 
-	return s.SignEIP712TypedMessage(typeData)
+// SignSetSigner returns the signature to pass as the _signature argument of
+// setSigner, handing the oracle over to newSigner
+func (s *Signer) SignSetSigner(newSigner common.Address) ([]byte, error) {
+	return s.SignEIP712TypedMessage(SetSignerTypedData(s.chainID, s.drandOracleAddress, newSigner))
 }
 
 func (s *Signer) SignEIP712TypedMessage(typedData *apitypes.TypedData) (signature []byte, err error) {
-	var typedDataHash hexutil.Bytes
-	typedDataHash, err = typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	hash, err := TypedDataHash(typedData)
 	if err != nil {
 		return
 	}
-
-	var domainSeparator hexutil.Bytes
-	domainSeparator, err = typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
-	if err != nil {
-		return
-	}
-
-	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
-	hash := crypto.Keccak256Hash(rawData)
-
-	return s.Sign(hash.Bytes())
+	return s.Sign(hash)
 }
 
 func (s *Signer) Sign(msg []byte) ([]byte, error) {
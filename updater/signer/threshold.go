@@ -0,0 +1,112 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// ThresholdSigner collects SetRandomness signatures from several signers and
+// aggregates them once the threshold is reached. The aggregated signature is the
+// concatenation of the individual 65 byte signatures, sorted by ascending signer
+// address, which is the layout expected by N-of-M oracle contracts.
+type ThresholdSigner struct {
+	signers   []SetRandomnessSigner
+	threshold int
+}
+
+type memberSignature struct {
+	address   common.Address
+	signature []byte
+	err       error
+}
+
+func NewThresholdSigner(threshold int, signers ...SetRandomnessSigner) (*ThresholdSigner, error) {
+	if len(signers) == 0 {
+		return nil, errors.New("no signers configured")
+	}
+	if threshold < 1 || threshold > len(signers) {
+		return nil, fmt.Errorf("invalid signer threshold %d for %d signers", threshold, len(signers))
+	}
+	seen := make(map[common.Address]bool, len(signers))
+	for _, s := range signers {
+		if seen[s.Address()] {
+			return nil, fmt.Errorf("duplicate signer %s", s.Address().Hex())
+		}
+		seen[s.Address()] = true
+	}
+	return &ThresholdSigner{
+		signers:   signers,
+		threshold: threshold,
+	}, nil
+}
+
+// Address returns the address of the first member, SIGNER_PRIVATE_KEY's, which
+// stands for the signer set wherever a single address is expected: the
+// status, the logs, the audit entries and the comparison with the oracle's
+// signer. It is not the address of a signature, see Addresses for the members.
+func (s *ThresholdSigner) Address() common.Address {
+	return s.signers[0].Address()
+}
+
+// Addresses returns the addresses of all member signers
+func (s *ThresholdSigner) Addresses() []common.Address {
+	addresses := make([]common.Address, len(s.signers))
+	for i, member := range s.signers {
+		addresses[i] = member.Address()
+	}
+	return addresses
+}
+
+func (s *ThresholdSigner) Threshold() int {
+	return s.threshold
+}
+
+func (s *ThresholdSigner) SignSetRandomness(ctx context.Context, round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error) {
+	if len(s.signers) == 1 {
+		return s.signers[0].SignSetRandomness(ctx, round, timestamp, randomness, signature)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan memberSignature, len(s.signers))
+	for _, member := range s.signers {
+		go func(member SetRandomnessSigner) {
+			sig, err := member.SignSetRandomness(ctx, round, timestamp, randomness, signature)
+			results <- memberSignature{address: member.Address(), signature: sig, err: err}
+		}(member)
+	}
+
+	var collected []memberSignature
+	var errs []error
+	for range s.signers {
+		result := <-results
+		if result.err != nil {
+			log.Warn().Err(result.err).Str("signer", result.address.Hex()).Uint64("round", round).Msg("Signer failed to sign set randomness")
+			errs = append(errs, result.err)
+			continue
+		}
+		collected = append(collected, result)
+		if len(collected) == s.threshold {
+			return aggregate(collected), nil
+		}
+	}
+	return nil, fmt.Errorf("collected %d of %d required signatures: %w", len(collected), s.threshold, errors.Join(errs...))
+}
+
+func aggregate(signatures []memberSignature) []byte {
+	sort.Slice(signatures, func(i, j int) bool {
+		return bytes.Compare(signatures[i].address[:], signatures[j].address[:]) < 0
+	})
+	var out []byte
+	for _, sig := range signatures {
+		out = append(out, sig.signature...)
+	}
+	return out
+}
@@ -0,0 +1,159 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fakeMember is a member signer returning a fixed signature or error
+type fakeMember struct {
+	address common.Address
+	err     error
+	// hang blocks signing until the context is cancelled
+	hang bool
+}
+
+func (m fakeMember) Address() common.Address { return m.address }
+
+// signature is the 65 byte signature of the member, filled with the last byte
+// of its address
+func (m fakeMember) signature() []byte {
+	return bytes.Repeat([]byte{m.address[common.AddressLength-1]}, 65)
+}
+
+func (m fakeMember) SignSetRandomness(ctx context.Context, round uint64, timestamp uint64, randomness [32]byte, signature []byte) ([]byte, error) {
+	if m.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.signature(), nil
+}
+
+func member(last byte) fakeMember {
+	return fakeMember{address: common.BytesToAddress([]byte{0x0a, last})}
+}
+
+func failingMember(last byte, err error) fakeMember {
+	m := member(last)
+	m.err = err
+	return m
+}
+
+func hangingMember(last byte) fakeMember {
+	m := member(last)
+	m.hang = true
+	return m
+}
+
+// concat returns the signatures of members concatenated in the given order
+func concat(members ...fakeMember) []byte {
+	var out []byte
+	for _, m := range members {
+		out = append(out, m.signature()...)
+	}
+	return out
+}
+
+func TestThresholdSignerSign(t *testing.T) {
+	errDown := errors.New("signer down")
+	tests := []struct {
+		name      string
+		threshold int
+		members   []fakeMember
+		want      []byte
+		// wantErr is a substring of the error, empty for success
+		wantErr string
+	}{
+		{
+			name:      "sorted by address",
+			threshold: 3,
+			members:   []fakeMember{member(0x03), member(0x01), member(0x02)},
+			want:      concat(member(0x01), member(0x02), member(0x03)),
+		},
+		{
+			name:      "single member",
+			threshold: 1,
+			members:   []fakeMember{member(0x07)},
+			want:      member(0x07).signature(),
+		},
+		{
+			name:      "threshold after failures",
+			threshold: 2,
+			members:   []fakeMember{failingMember(0x01, errDown), member(0x03), failingMember(0x04, errDown), member(0x02)},
+			want:      concat(member(0x02), member(0x03)),
+		},
+		{
+			name:      "threshold before slow members",
+			threshold: 2,
+			members:   []fakeMember{hangingMember(0x01), member(0x05), member(0x04)},
+			want:      concat(member(0x04), member(0x05)),
+		},
+		{
+			name:      "threshold not reached",
+			threshold: 3,
+			members:   []fakeMember{member(0x01), failingMember(0x02, errDown), member(0x03)},
+			wantErr:   "collected 2 of 3 required signatures",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members := make([]SetRandomnessSigner, len(tt.members))
+			for i, m := range tt.members {
+				members[i] = m
+			}
+			s, err := NewThresholdSigner(tt.threshold, members...)
+			if err != nil {
+				t.Fatalf("new threshold signer: %v", err)
+			}
+			got, err := s.SignSetRandomness(context.Background(), 1, 1, [32]byte{}, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.Is(err, errDown) {
+					t.Fatalf("error = %v, want %q wrapping the member error", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("signing: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("signature = %x, want %x", got, tt.want)
+			}
+			// The first member stands for the set
+			if s.Address() != tt.members[0].address {
+				t.Errorf("address = %s, want the first member %s", s.Address(), tt.members[0].address)
+			}
+		})
+	}
+}
+
+func TestNewThresholdSigner(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		members   []fakeMember
+	}{
+		{"no members", 1, nil},
+		{"zero threshold", 0, []fakeMember{member(0x01)}},
+		{"threshold above members", 3, []fakeMember{member(0x01), member(0x02)}},
+		{"duplicate member", 2, []fakeMember{member(0x01), member(0x01)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members := make([]SetRandomnessSigner, len(tt.members))
+			for i, m := range tt.members {
+				members[i] = m
+			}
+			if _, err := NewThresholdSigner(tt.threshold, members...); err == nil {
+				t.Error("threshold signer created")
+			}
+		})
+	}
+}
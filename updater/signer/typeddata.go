@@ -0,0 +1,114 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// SetRandomnessTypedData returns the EIP-712 typed data authorizing a SetRandomness call
+func SetRandomnessTypedData(
+	chainID int64,
+	drandOracleAddress common.Address,
+	round uint64,
+	timestamp uint64,
+	randomness [32]byte,
+	signature []byte,
+) *apitypes.TypedData {
+	return &apitypes.TypedData{
+		Types: apitypes.Types{
+			// SetRandomness(uint64 round,uint64 timestamp,bytes32 randomness,bytes signature)
+			"SetRandomness": []apitypes.Type{
+				{Name: "round", Type: "uint64"},
+				{Name: "timestamp", Type: "uint64"},
+				{Name: "randomness", Type: "bytes32"},
+				{Name: "signature", Type: "bytes"},
+			},
+			// EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)
+			"EIP712Domain": []apitypes.Type{
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			}},
+		PrimaryType: "SetRandomness",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "DrandOracle",
+			Version:           "1.0.0",
+			ChainId:           math.NewHexOrDecimal256(chainID),
+			VerifyingContract: drandOracleAddress.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"round":      math.NewHexOrDecimal256(int64(round)),
+			"timestamp":  math.NewHexOrDecimal256(int64(timestamp)),
+			"randomness": randomness,
+			"signature":  signature,
+		},
+	}
+}
+
+// SetSignerTypedData returns the EIP-712 typed data authorizing a setSigner
+// call, signed by the current signer to hand over to newSigner
+func SetSignerTypedData(chainID int64, drandOracleAddress common.Address, newSigner common.Address) *apitypes.TypedData {
+	return &apitypes.TypedData{
+		Types: apitypes.Types{
+			// SetSigner(address signer)
+			"SetSigner": []apitypes.Type{
+				{Name: "signer", Type: "address"},
+			},
+			"EIP712Domain": []apitypes.Type{
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			}},
+		PrimaryType: "SetSigner",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "DrandOracle",
+			Version:           "1.0.0",
+			ChainId:           math.NewHexOrDecimal256(chainID),
+			VerifyingContract: drandOracleAddress.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"signer": newSigner.Hex(),
+		},
+	}
+}
+
+// TypedDataHash returns the EIP-712 digest of typed data
+func TypedDataHash(typedData *apitypes.TypedData) ([]byte, error) {
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+
+	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
+	return crypto.Keccak256Hash(rawData).Bytes(), nil
+}
+
+// RecoverAddress recovers the address that produced a 65 byte signature over hash
+func RecoverAddress(hash []byte, signature []byte) (common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, errors.New("invalid signature length")
+	}
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	// Transform V from 27/28 back to 0/1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}